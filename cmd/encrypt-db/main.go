@@ -0,0 +1,67 @@
+// Package main provides a utility that encrypts an existing plaintext
+// database for use with TIMELOG_DB_ENCRYPTION_KEY.
+//
+// It must be built like the server, with -tags sqlcipher against SQLCipher:
+//
+//	TIMELOG_DB_ENCRYPTION_KEY_FILE=/run/secrets/dbkey encrypt-db -in timelog.db -out timelog.enc.db
+//
+// The server must be stopped first. Once the copy is written, replace the
+// original with it and start the server with the same key.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"time-tracker/internal/app"
+	"time-tracker/internal/shared/database"
+)
+
+func main() {
+	in := flag.String("in", os.Getenv("TIMELOG_DB_PATH"), "plaintext database to encrypt (default $TIMELOG_DB_PATH)")
+	out := flag.String("out", "", "path of the encrypted copy; must not exist")
+	flag.Parse()
+
+	if err := run(*in, *out); err != nil {
+		log.Fatalf("encrypt-db: %v", err)
+	}
+	log.Printf("Wrote encrypted copy of %s to %s", *in, *out)
+}
+
+// run encrypts in to out with the key from TIMELOG_DB_ENCRYPTION_KEY or
+// its _FILE variant.
+func run(in, out string) error {
+	if in == "" || out == "" {
+		return fmt.Errorf("both -in and -out are required")
+	}
+	if _, err := os.Stat(out); err == nil {
+		return fmt.Errorf("%s already exists", out)
+	}
+	if _, err := os.Stat(in); err != nil {
+		return err
+	}
+
+	key, err := app.SecretEnv("TIMELOG_DB_ENCRYPTION_KEY")
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("set TIMELOG_DB_ENCRYPTION_KEY or TIMELOG_DB_ENCRYPTION_KEY_FILE")
+	}
+
+	// Fold the WAL into the file so the copy has every committed write
+	db, err := database.New(in)
+	if err != nil {
+		return err
+	}
+	_, err = db.Checkpoint(context.Background())
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	return database.Encrypt(context.Background(), in, out, key)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"time-tracker/internal/app"
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/middleware"
+	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/users"
+)
+
+// command is a subcommand; run parses args itself and writes output other
+// than errors to stdout.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{"serve", "run the server (the default)", serve},
+	{"export", "write every session of a user to a CSV file", export},
+	{"backup", "write a consistent copy of the database", backup},
+	{"check-config", "check the configuration and exit", checkConfig},
+	{"healthcheck", "check that the running server is ready", healthcheck},
+}
+
+// usageError is an error in the command line rather than in carrying the
+// command out.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func newUsageError(format string, args ...interface{}) error {
+	return usageError(fmt.Sprintf(format, args...))
+}
+
+// errBadFlags is returned for flags the flag package has already reported.
+var errBadFlags = errors.New("invalid flags")
+
+// parseFlags parses the flags of a command, which takes no other arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errBadFlags
+	}
+	if fs.NArg() > 0 {
+		return newUsageError("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// run runs the command named by the first argument, or serve if there is
+// none, and returns the exit status: 0 on success, 2 for a bad command
+// line and 1 for any other failure.
+func run(args []string, stdout, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage(stdout)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args, stdout, stderr)
+		var usageErr usageError
+		switch {
+		case err == nil || errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errBadFlags):
+			return 2
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			return 2
+		default:
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: server [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "server <command> -h" for the flags of a command.`)
+}
+
+// export writes every session of the --user as CSV to --out, or to stdout
+// for "-".
+func export(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	out := fs.String("out", "", `CSV file to write, or "-" for standard output`)
+	user := fs.String("user", "default", "name of the user whose sessions to export")
+	loadConfig := addConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return newUsageError("--out is required")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	db, err := openDatabase(cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	userID, err := userIDByName(db, *user)
+	if err != nil {
+		return err
+	}
+	ctx := auth.WithUserID(context.Background(), userID)
+	svc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+
+	if *out == "-" {
+		if _, err := stdout.Write(utils.UTF8BOM); err != nil {
+			return err
+		}
+		return svc.ExportCSVToContext(ctx, stdout, nil, nil, ',')
+	}
+
+	// Written under a temporary name, so a failed export leaves no partial file
+	tmp, err := os.CreateTemp(filepath.Dir(*out), ".export_*.csv.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(utils.UTF8BOM); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := svc.ExportCSVToContext(ctx, tmp, nil, nil, ','); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return fmt.Errorf("failed to move export into place: %w", err)
+	}
+	fmt.Fprintf(stdout, "Exported sessions to %s\n", *out)
+	return nil
+}
+
+// userIDByName returns the ID of the named user.
+func userIDByName(db *database.DB, name string) (int64, error) {
+	list, err := users.NewUserRepository(db).ListContext(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range list {
+		if u.Name == name {
+			return u.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no user named %q", name)
+}
+
+// backup writes a snapshot of the database to --out, which must not exist.
+// It is safe to run while the server is using the database.
+func backup(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	out := fs.String("out", "", "path of the backup; must not exist")
+	loadConfig := addConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return newUsageError("--out is required")
+	}
+	if _, err := os.Stat(*out); err == nil {
+		return fmt.Errorf("%s already exists", *out)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	db, err := openDatabase(cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.SnapshotTo(context.Background(), *out); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Backed up %s to %s\n", cfg.DBPath, *out)
+	return nil
+}
+
+// checkConfig loads the configuration and reports whether it is valid,
+// without starting anything.
+func checkConfig(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	loadConfig := addConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.ConfigFile != "" {
+		fmt.Fprintf(stdout, "Configuration OK (%s)\n", cfg.ConfigFile)
+	} else {
+		fmt.Fprintln(stdout, "Configuration OK")
+	}
+	return nil
+}
+
+// healthcheckTimeout bounds the whole readiness request, so a hung server
+// fails the check rather than stalling it.
+const healthcheckTimeout = 2 * time.Second
+
+// healthcheck requests /readyz from the running server and fails unless it
+// answers 200. It needs no curl, for containers without a shell. The server
+// is reached at --url, or else at the configured listen address, over TCP
+// or its Unix socket.
+func healthcheck(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", "", "URL to request instead of /readyz at the configured address")
+	loadConfig := addConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: healthcheckTimeout}
+	target := *url
+	if target == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		network, address, err := cfg.LocalAddr()
+		if err != nil {
+			return err
+		}
+		scheme, host := "http", address
+		if network == "unix" {
+			// The host only fills in the URL; every request goes to the socket
+			host = "localhost"
+		}
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+		}
+		if cfg.TLSEnabled() {
+			scheme = "https"
+			// The certificate names the public host, not the loopback
+			// address, and the probe sends nothing secret
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		client.Transport = transport
+		target = scheme + "://" + host + "/readyz"
+	}
+
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", target, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// openDatabase opens the configured database for a command that does not
+// start the server. It must exist already; an in-memory one would start
+// out empty. At debug level, statements are logged to logOut.
+func openDatabase(cfg *app.Config, logOut io.Writer) (*database.DB, error) {
+	if database.IsMemoryPath(cfg.DBPath) {
+		return nil, fmt.Errorf("the database is in memory; set TIMELOG_DB_PATH or --db-path")
+	}
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db, err := cfg.OpenDatabase(middleware.NewLogger(logOut, cfg.LogFormat, cfg.LogLevel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
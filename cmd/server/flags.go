@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"time-tracker/internal/app"
+)
+
+// configFlags are the settings that can be given as flags, named like the
+// config file keys with dashes. Secrets are left out on purpose: command
+// lines are visible to every user of the machine.
+var configFlags = []struct {
+	name  string
+	usage string
+}{
+	{"port", "port to listen on, on all interfaces"},
+	{"listen", "address to listen on: host:port, :port or unix:/path"},
+	{"db-path", "SQLite database path"},
+	{"db-driver", "storage backend"},
+	{"db-busy-timeout", "how long a write waits for a lock, such as 5s"},
+	{"db-integrity-check", "startup integrity check, quick or full"},
+	{"tz", "time zone for display, such as Asia/Shanghai"},
+	{"templates-dir", "directory to load the web templates from"},
+	{"basic-user", "web login user name"},
+	{"rate-limit", "requests per minute per client"},
+	{"rate-limit-exempt", "comma-separated paths that are not rate limited"},
+	{"log-format", "log format, json or text"},
+	{"log-level", "least severe level logged: debug, info, warn or error"},
+	{"max-body-bytes", "largest accepted JSON request body"},
+	{"bulk-assign-max", "most sessions tagged by one bulk assignment"},
+	{"seed-tags", "tags to create on startup, name:#RRGGBB,..."},
+	{"trusted-proxies", "comma-separated CIDRs of trusted reverse proxies"},
+	{"api-allow-cidrs", "comma-separated CIDRs allowed to call the API"},
+	{"cors-origins", "comma-separated origins allowed to call the API"},
+	{"csp-script-src", "extra CSP script-src sources"},
+	{"csp-style-src", "extra CSP style-src sources"},
+	{"hsts-max-age", "Strict-Transport-Security max-age in seconds"},
+	{"tls-cert", "TLS certificate file"},
+	{"tls-key", "TLS private key file"},
+	{"http-redirect-port", "plain HTTP port redirecting to HTTPS"},
+	{"auto-export-dir", "directory for scheduled CSV exports"},
+	{"auto-export-interval", "scheduled export interval"},
+	{"auto-export-retain", "scheduled exports to keep"},
+	{"stream-heartbeat", "session stream heartbeat interval"},
+	{"maintenance-interval", "WAL checkpoint interval"},
+	{"vacuum-interval", "incremental vacuum interval, 0 to disable"},
+	{"backup-dir", "directory for scheduled backups"},
+	{"backup-interval", "scheduled backup interval"},
+	{"backup-keep", "scheduled backups to keep"},
+	{"retention-days", "days to keep stopped sessions, 0 to keep all"},
+	{"retention-mode", "delete or anonymize old sessions"},
+}
+
+// addConfigFlags adds --config and the config flags to fs. The returned
+// function loads the configuration once fs is parsed: flags given
+// override the environment, which overrides the config file.
+func addConfigFlags(fs *flag.FlagSet) func() (*app.Config, error) {
+	configPath := fs.String("config", "", "TOML config file (default $TIMELOG_CONFIG)")
+	values := make(map[string]*string, len(configFlags))
+	for _, f := range configFlags {
+		env := "TIMELOG_" + strings.ToUpper(strings.ReplaceAll(f.name, "-", "_"))
+		values[f.name] = fs.String(f.name, "", f.usage+" ($"+env+")")
+	}
+
+	return func() (*app.Config, error) {
+		path := *configPath
+		if path == "" {
+			path = os.Getenv("TIMELOG_CONFIG")
+		}
+		// Only flags given on the command line override other sources
+		set := map[string]string{}
+		fs.Visit(func(f *flag.Flag) {
+			if v, ok := values[f.Name]; ok {
+				set[f.Name] = *v
+			}
+		})
+		return app.LoadConfigFlags(path, set)
+	}
+}
@@ -1,66 +1,142 @@
 // Package main provides the entry point for the time tracker server.
+//
+// Without a command, or with serve, it runs the server. Other commands work
+// on the database directly and can run next to the server:
+//
+//	server export --out sessions.csv
+//	server backup --out timelog-backup.db
+//	server check-config
+//	server healthcheck
+//
+// Every command takes --config and flags for the settings otherwise read
+// from TIMELOG_* variables; run a command with -h to list them.
 package main
 
 import (
-	"log"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"time-tracker/internal/app"
+	"time-tracker/internal/shared/buildinfo"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/middleware"
 )
 
 // logStartup logs startup information without exposing sensitive values.
-func logStartup(cfg *app.Config) {
-	log.Println("Starting Time Tracker server...")
-	log.Printf("Database path: %s", cfg.DBPath)
-	log.Printf("Timezone: %s", cfg.Timezone)
-	log.Printf("Rate limit: %d requests/minute", cfg.RateLimit)
-	log.Printf("Port: %s", cfg.Port)
-
-	// Log API key prefix only (first 4 characters for debugging)
-	if len(cfg.APIKey) >= 4 {
-		log.Printf("API Key: %s...", cfg.APIKey[:4])
+func logStartup(logger *slog.Logger, cfg *app.Config) {
+	info := buildinfo.Get()
+	logger.Info("starting Time Tracker server", "version", info.Version, "commit", info.Commit, "built", info.BuildDate, "go", info.GoVersion)
+	if cfg.ConfigFile != "" {
+		logger.Info("config file", "path", cfg.ConfigFile)
 	}
+	logger.Info("database", "path", cfg.DBPath, "encrypted", cfg.DBEncryptionKey != "")
+	if database.IsMemoryPath(cfg.DBPath) {
+		logger.Warn("using an in-memory database; all data is lost when the server stops")
+	}
+	logger.Info("settings", "tz", cfg.Timezone, "rate_limit", cfg.RateLimit, "listen", cfg.ListenAddr(), "log_level", cfg.LogLevel.String())
+	if cfg.Tracing.Endpoint != "" {
+		logger.Info("tracing enabled", "endpoint", cfg.Tracing.Endpoint, "service", cfg.Tracing.ServiceName)
+	}
+	if cfg.BackupDir != "" {
+		logger.Info("backup enabled", "interval", cfg.BackupInterval.String(), "dir", cfg.BackupDir, "keep", cfg.BackupKeep)
+	}
+	if cfg.TLSEnabled() {
+		logger.Info("TLS enabled", "certificate", cfg.TLSCert, "http_redirect_port", cfg.HTTPRedirectPort)
+	}
+
+	// Log API key prefixes only (first 4 characters for debugging)
+	prefixes := make([]string, len(cfg.APIKeys))
+	for i, key := range cfg.APIKeys {
+		prefixes[i] = key[:4] + "..."
+	}
+	logger.Info("API keys", "count", len(cfg.APIKeys), "prefixes", strings.Join(prefixes, ", "))
 
 	// Log Basic Auth status without exposing credentials
-	if cfg.BasicUser != "" && cfg.BasicPass != "" {
-		log.Println("Basic Auth: enabled")
+	if creds := cfg.Credentials(); creds.BasicAuthEnabled() {
+		logger.Info("basic auth enabled", "bcrypt", cfg.BasicPassHash != "")
 	} else {
-		log.Println("Basic Auth: disabled (web interface unprotected)")
+		logger.Warn("basic auth disabled; the web interface is unprotected until a user with a password is added")
+	}
+}
+
+// waitForShutdown blocks until SIGINT or SIGTERM, reloading the
+// configuration on every SIGHUP in the meantime.
+func waitForShutdown(logger *slog.Logger, a *app.App) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case <-hup:
+			logger.Info("received SIGHUP, reloading configuration")
+			if err := a.Reload(); err != nil {
+				logger.Error("reload failed, keeping current configuration", "error", err)
+			}
+		case <-quit:
+			return
+		}
 	}
 }
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// serve runs the server until SIGINT or SIGTERM.
+func serve(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	loadConfig := addConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
 	// Load configuration
-	cfg, err := app.LoadConfig()
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Configuration error: %v", err)
+		return fmt.Errorf("configuration error: %w", err)
 	}
 
+	// Everything logs through this logger. It also becomes the default, so
+	// output of the log package gets the same format and level. The level
+	// can change on reload.
+	level := new(slog.LevelVar)
+	level.Set(cfg.LogLevel)
+	logger := middleware.NewLogger(stderr, cfg.LogFormat, level)
+	slog.SetDefault(logger)
+
 	// Log startup info (without sensitive values)
-	logStartup(cfg)
+	logStartup(logger, cfg)
 
 	// Create and wire application
-	a, err := app.New(cfg)
+	a, err := app.New(cfg, logger)
 	if err != nil {
-		log.Fatalf("Failed to create app: %v", err)
+		return fmt.Errorf("failed to create app: %w", err)
 	}
+	a.SetLogLevel(level)
 
 	// Start server in a goroutine
 	go func() {
 		if err := a.Run(); err != nil {
-			log.Fatalf("Server error: %v", err)
+			logger.Error("server error", "error", err)
+			os.Exit(1)
 		}
 	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
+	waitForShutdown(logger, a)
 
 	// Shutdown the server
 	if err := a.Shutdown(); err != nil {
-		log.Fatalf("Shutdown error: %v", err)
+		return fmt.Errorf("shutdown error: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/app"
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/middleware"
+	"time-tracker/internal/users"
+)
+
+const testAPIKey = "test-api-key-32-chars-minimum!!!"
+
+// runCommand runs the command line args and returns the exit status and
+// output.
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// newTestDatabase creates a database file holding one session.
+func newTestDatabase(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "timelog.db")
+	db, err := database.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	svc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+	if _, err := svc.StartSession(&sessions.SessionStart{Category: "work", Task: "write the report"}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_Commands(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	code, _, stderr := runCommand(t, "frobnicate")
+	if code != 2 || !strings.Contains(stderr, `unknown command "frobnicate"`) || !strings.Contains(stderr, "check-config") {
+		t.Errorf("unknown command: exit %d, stderr %q", code, stderr)
+	}
+
+	code, stdout, _ := runCommand(t, "help")
+	if code != 0 || !strings.Contains(stdout, "export") {
+		t.Errorf("help: exit %d, stdout %q", code, stdout)
+	}
+
+	if code, _, _ := runCommand(t, "check-config", "-h"); code != 0 {
+		t.Errorf("check-config -h: exit %d", code)
+	}
+	if code, _, _ := runCommand(t, "check-config", "--no-such-flag"); code != 2 {
+		t.Errorf("unknown flag: exit %d", code)
+	}
+	if code, _, stderr := runCommand(t, "check-config", "extra"); code != 2 || !strings.Contains(stderr, `unexpected argument "extra"`) {
+		t.Errorf("extra argument: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_RATE_LIMIT", "")
+
+	code, stdout, _ := runCommand(t, "check-config")
+	if code != 0 || !strings.Contains(stdout, "Configuration OK") {
+		t.Errorf("valid configuration: exit %d, stdout %q", code, stdout)
+	}
+
+	code, _, stderr := runCommand(t, "check-config", "--rate-limit", "-5")
+	if code != 1 || !strings.Contains(stderr, "TIMELOG_RATE_LIMIT must be a positive integer") {
+		t.Errorf("invalid flag value: exit %d, stderr %q", code, stderr)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("rate_limt = 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCommand(t, "check-config", "--config", path)
+	if code != 1 || !strings.Contains(stderr, `unknown key "rate_limt"`) {
+		t.Errorf("bad config file: exit %d, stderr %q", code, stderr)
+	}
+
+	t.Setenv("TIMELOG_API_KEY", "")
+	if code, _, _ := runCommand(t, "check-config"); code != 1 {
+		t.Errorf("missing API key: exit %d", code)
+	}
+}
+
+func TestAddConfigFlags_Precedence(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_PORT", "8000")
+	t.Setenv("TIMELOG_TZ", "Asia/Tokyo")
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("port = 7000\ntz = \"Europe/Paris\"\nrate_limit = 30\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loadConfig := addConfigFlags(fs)
+	if err := fs.Parse([]string{"--config", path, "--port", "9000"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" || cfg.Timezone != "Asia/Tokyo" || cfg.RateLimit != 30 {
+		t.Errorf("got port %s, tz %s, rate limit %d; want the flag, env and file values", cfg.Port, cfg.Timezone, cfg.RateLimit)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	dbPath := newTestDatabase(t)
+	out := filepath.Join(t.TempDir(), "sessions.csv")
+
+	code, _, stderr := runCommand(t, "export", "--db-path", dbPath, "--out", out)
+	if code != 0 {
+		t.Fatalf("export: exit %d, stderr %q", code, stderr)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "write the report") {
+		t.Errorf("export lacks the session:\n%s", data)
+	}
+
+	code, stdout, _ := runCommand(t, "export", "--db-path", dbPath, "--out", "-")
+	if code != 0 || !strings.Contains(stdout, "write the report") {
+		t.Errorf("export to stdout: exit %d, stdout %q", code, stdout)
+	}
+
+	if code, _, _ := runCommand(t, "export", "--db-path", dbPath); code != 2 {
+		t.Errorf("export without --out: exit %d", code)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	if code, _, _ := runCommand(t, "export", "--db-path", missing, "--out", out); code != 1 {
+		t.Errorf("export of a missing database: exit %d", code)
+	}
+	if _, err := os.Stat(missing); err == nil {
+		t.Error("export created the missing database")
+	}
+}
+
+func TestExport_User(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	dbPath := newTestDatabase(t)
+	db, err := database.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partner, err := users.NewUserRepository(db).CreateContext(context.Background(), "partner", "unused-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+	if _, err := svc.StartSessionContext(auth.WithUserID(context.Background(), partner.ID), &sessions.SessionStart{Category: "work", Task: "plan the trip"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	code, stdout, stderr := runCommand(t, "export", "--db-path", dbPath, "--out", "-", "--user", "partner")
+	if code != 0 || !strings.Contains(stdout, "plan the trip") || strings.Contains(stdout, "write the report") {
+		t.Errorf("export --user partner: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	code, _, stderr = runCommand(t, "export", "--db-path", dbPath, "--out", "-", "--user", "nobody")
+	if code != 1 || !strings.Contains(stderr, `no user named "nobody"`) {
+		t.Errorf("export --user nobody: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	dbPath := newTestDatabase(t)
+	out := filepath.Join(t.TempDir(), "backup.db")
+
+	code, _, stderr := runCommand(t, "backup", "--db-path", dbPath, "--out", out)
+	if code != 0 {
+		t.Fatalf("backup: exit %d, stderr %q", code, stderr)
+	}
+	db, err := database.New(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count)
+	db.Close()
+	if err != nil || count != 1 {
+		t.Errorf("backup holds %d sessions (%v), want 1", count, err)
+	}
+
+	code, _, stderr = runCommand(t, "backup", "--db-path", dbPath, "--out", out)
+	if code != 1 || !strings.Contains(stderr, "already exists") {
+		t.Errorf("backup over an existing file: exit %d, stderr %q", code, stderr)
+	}
+}
+
+// serveTestApp serves the app configured by the environment on ln until the
+// test ends.
+func serveTestApp(t *testing.T, ln net.Listener) {
+	t.Helper()
+	cfg, err := app.LoadConfigFlags("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.New(cfg, middleware.NewLogger(io.Discard, "text", slog.LevelInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- a.Serve(ln) }()
+	t.Cleanup(func() {
+		if err := a.Shutdown(); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+}
+
+func TestHealthcheck(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_DB_PATH", filepath.Join(t.TempDir(), "timelog.db"))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	serveTestApp(t, ln)
+
+	if code, _, stderr := runCommand(t, "healthcheck", "--listen", addr); code != 0 {
+		t.Errorf("ready server: exit %d, stderr %q", code, stderr)
+	}
+	if code, _, stderr := runCommand(t, "healthcheck", "--url", "http://"+addr+"/readyz"); code != 0 {
+		t.Errorf("--url: exit %d, stderr %q", code, stderr)
+	}
+
+	unready := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"ok":false,"db":"database is locked"}`, http.StatusServiceUnavailable)
+	}))
+	defer unready.Close()
+	code, _, stderr := runCommand(t, "healthcheck", "--url", unready.URL+"/readyz")
+	if code != 1 || !strings.Contains(stderr, "503") || !strings.Contains(stderr, "database is locked") {
+		t.Errorf("unready server: exit %d, stderr %q", code, stderr)
+	}
+
+	// Nothing listens on the port once the probe listener is closed
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := probe.Addr().String()
+	probe.Close()
+	if code, _, stderr := runCommand(t, "healthcheck", "--listen", closed); code != 1 || !strings.Contains(stderr, "healthcheck:") {
+		t.Errorf("no server: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestHealthcheck_UnixSocket(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_DB_PATH", filepath.Join(t.TempDir(), "timelog.db"))
+
+	path := filepath.Join(t.TempDir(), "timelog.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveTestApp(t, ln)
+
+	if code, _, stderr := runCommand(t, "healthcheck", "--listen", "unix:"+path); code != 0 {
+		t.Errorf("ready server: exit %d, stderr %q", code, stderr)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"time-tracker/pkg/client"
+)
+
+// watchRefresh is how often status --watch asks the server again, to notice
+// the session being stopped or replaced elsewhere. The elapsed time is
+// redrawn every second in between.
+const watchRefresh = 10 * time.Second
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("tt "+name, flag.ContinueOnError)
+}
+
+func start(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("start")
+	var tags stringList
+	fs.Var(&tags, "tag", "tag the session with the named tag (repeatable)")
+	note := fs.String("note", "", "note for the session")
+	jsonOut := fs.Bool("json", false, "print the session as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 2 {
+		return newUsageError("expected at most a category and a task, got %d arguments", len(positional))
+	}
+	req := client.StartRequest{}
+	if len(positional) > 0 {
+		req.Category = positional[0]
+	}
+	if len(positional) > 1 {
+		req.Task = positional[1]
+	}
+	if *note != "" {
+		req.Note = note
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	tagIDs, err := resolveTags(ctx, c, tags)
+	if err != nil {
+		return err
+	}
+	session, err := c.StartSession(ctx, req)
+	if err != nil {
+		return err
+	}
+	if len(tagIDs) > 0 {
+		if err := c.AssignTags(ctx, session.ID, tagIDs); err != nil {
+			return fmt.Errorf("session #%d started, but tagging it failed: %w", session.ID, err)
+		}
+		session.Tags = append(session.Tags, tags...)
+	}
+
+	if *jsonOut {
+		return writeJSON(stdout, session)
+	}
+	fmt.Fprintf(stdout, "Started %s at %s\n", describeSession(session), formatClock(session.StartedAt))
+	return nil
+}
+
+// resolveTags looks up the IDs of the named tags before anything is
+// started, so a typo does not leave an untagged session running.
+func resolveTags(ctx context.Context, c *client.Client, names []string) ([]int64, error) {
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		tags, err := c.ListTags(ctx, client.TagListOptions{NamePrefix: name})
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, tag := range tags {
+			if tag.Name == name {
+				ids = append(ids, tag.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, newUsageError("no tag named %q", name)
+		}
+	}
+	return ids, nil
+}
+
+func stop(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("stop")
+	note := fs.String("note", "", "note for the session")
+	jsonOut := fs.Bool("json", false, "print the session as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return newUsageError("unexpected arguments: %s", strings.Join(positional, " "))
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	var req *client.StopRequest
+	if *note != "" {
+		req = &client.StopRequest{Note: note}
+	}
+	session, err := c.StopSession(ctx, req)
+	if errors.Is(err, client.ErrNotFound) {
+		return errNotRunning
+	}
+	if err != nil {
+		return err
+	}
+
+	if *jsonOut {
+		return writeJSON(stdout, session)
+	}
+	var elapsed time.Duration
+	if session.DurationSec != nil {
+		elapsed = time.Duration(*session.DurationSec) * time.Second
+	}
+	fmt.Fprintf(stdout, "Stopped %s after %s\n", describeSession(session), formatDuration(elapsed))
+	return nil
+}
+
+func status(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("status")
+	jsonOut := fs.Bool("json", false, "print the status as JSON")
+	watch := fs.Bool("watch", false, "keep the elapsed time up to date until interrupted")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return newUsageError("unexpected arguments: %s", strings.Join(positional, " "))
+	}
+	if *jsonOut && *watch {
+		return newUsageError("--json and --watch cannot be combined")
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	current, err := c.Current(ctx)
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		return writeJSON(stdout, current)
+	}
+	if !*watch {
+		fmt.Fprintln(stdout, statusLine(current, time.Now()))
+		return nil
+	}
+	return watchStatus(ctx, c, current, stdout)
+}
+
+// watchStatus redraws the status line every second until ctx is done.
+func watchStatus(ctx context.Context, c *client.Client, current *client.Current, stdout io.Writer) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	fetched := time.Now()
+	for {
+		fmt.Fprintf(stdout, "\r\033[K%s", statusLine(current, time.Now()))
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(stdout)
+			return nil
+		case now := <-ticker.C:
+			if now.Sub(fetched) < watchRefresh {
+				continue
+			}
+			next, err := c.Current(ctx)
+			if ctx.Err() != nil {
+				fmt.Fprintln(stdout)
+				return nil
+			}
+			if err != nil {
+				fmt.Fprintln(stdout)
+				return err
+			}
+			current, fetched = next, now
+		}
+	}
+}
+
+// statusLine describes the current status as of now.
+func statusLine(current *client.Current, now time.Time) string {
+	if !current.Running || current.Session == nil {
+		return "Not running"
+	}
+	s := current.Session
+	return fmt.Sprintf("Running %s for %s (since %s)", describeSession(s), formatDuration(now.Sub(s.StartedAt)), formatClock(s.StartedAt))
+}
+
+func logSessions(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("log")
+	today := fs.Bool("today", false, "only sessions started today")
+	week := fs.Bool("week", false, "only sessions started this week")
+	category := fs.String("category", "", "only sessions in this category")
+	limit := fs.Int("limit", 20, "show at most this many sessions; 0 shows all")
+	jsonOut := fs.Bool("json", false, "print the sessions as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return newUsageError("unexpected arguments: %s", strings.Join(positional, " "))
+	}
+	if *today && *week {
+		return newUsageError("--today and --week cannot be combined")
+	}
+	if *limit < 0 {
+		return newUsageError("--limit must not be negative")
+	}
+
+	opts := client.ListOptions{Filter: client.Filter{Category: *category}}
+	switch {
+	case *today:
+		opts.Range = "today"
+	case *week:
+		opts.Range = "this_week"
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	sessions := []client.Session{}
+	it := c.Sessions(ctx, opts)
+	for (*limit == 0 || len(sessions) < *limit) && it.Next() {
+		sessions = append(sessions, it.Session())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	if *jsonOut {
+		return writeJSON(stdout, sessions)
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(stdout, "No sessions")
+		return nil
+	}
+	return writeTable(stdout, sessions, time.Now())
+}
+
+// writeTable prints sessions as aligned columns followed by their total
+// time. Running sessions count up to now.
+func writeTable(w io.Writer, sessions []client.Session, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTART\tEND\tDURATION\tCATEGORY\tTASK")
+	var total time.Duration
+	for i := range sessions {
+		s := &sessions[i]
+		end := "running"
+		elapsed := now.Sub(s.StartedAt)
+		if s.EndedAt != nil {
+			end = formatClock(*s.EndedAt)
+			elapsed = s.EndedAt.Sub(s.StartedAt)
+		}
+		if s.DurationSec != nil {
+			elapsed = time.Duration(*s.DurationSec) * time.Second
+		}
+		total += elapsed
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			s.ID, s.StartedAt.Local().Format("2006-01-02 15:04"), end, formatDuration(elapsed), s.Category, s.Task)
+	}
+	fmt.Fprintf(tw, "\t\tTOTAL\t%s\t\t\n", formatDuration(total))
+	return tw.Flush()
+}
+
+// describeSession names a session for a one-line message.
+func describeSession(s *client.Session) string {
+	desc := fmt.Sprintf("#%d %s: %s", s.ID, s.Category, s.Task)
+	if len(s.Tags) > 0 {
+		desc += " [" + strings.Join(s.Tags, ", ") + "]"
+	}
+	return desc
+}
+
+// formatClock formats t as a local time of day.
+func formatClock(t time.Time) string {
+	return t.Local().Format("15:04")
+}
+
+// formatDuration formats d as hours, minutes and seconds: "1h02m03s",
+// "2m03s" or "3s".
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	secs := int64(d / time.Second)
+	h, m, s := secs/3600, secs/60%60, secs%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm%02ds", h, m, s)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultURL is the server's address with its default port.
+const defaultURL = "http://localhost:7070"
+
+// config is where the server is and how to authenticate.
+type config struct {
+	URL    string
+	APIKey string
+}
+
+// configPath returns the config file's path, ~/.config/timetracker/config
+// unless XDG_CONFIG_HOME says otherwise.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "timetracker", "config"), nil
+}
+
+// loadConfig reads the config file, if there is one, and lets TIMELOG_URL
+// and TIMELOG_API_KEY override it.
+func loadConfig() (*config, error) {
+	cfg := &config{URL: defaultURL}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := readConfigFile(path, cfg); err != nil {
+		return nil, err
+	}
+	if v := os.Getenv("TIMELOG_URL"); v != "" {
+		cfg.URL = v
+	}
+	if v := os.Getenv("TIMELOG_API_KEY"); v != "" {
+		cfg.APIKey = v
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("no API key: set TIMELOG_API_KEY or api_key in %s", path)
+	}
+	return cfg, nil
+}
+
+// readConfigFile reads "key = value" lines into cfg. Values may be quoted;
+// lines starting with # are comments. A missing file is not an error.
+func readConfigFile(path string, cfg *config) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		switch key {
+		case "url":
+			cfg.URL = value
+		case "api_key":
+			cfg.APIKey = value
+		default:
+			return fmt.Errorf("%s:%d: unknown key %q", path, lineNo, key)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,183 @@
+// Package main provides tt, a terminal client for the time tracker:
+//
+//	tt start work "fix importer" --tag deep
+//	tt stop --note "done"
+//	tt status --watch
+//	tt log --today
+//
+// The server and API key are read from ~/.config/timetracker/config:
+//
+//	url = "http://localhost:7070"
+//	api_key = "..."
+//
+// TIMELOG_URL and TIMELOG_API_KEY override the file. Every command takes
+// --json to print the API's JSON instead of text, for scripts.
+//
+// The exit status is 0 on success, 1 on failure, 2 for a bad command line
+// and 3 when the running session is in the way: starting while one runs,
+// or stopping while none does.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"time-tracker/pkg/client"
+)
+
+// Exit statuses.
+const (
+	exitFailure  = 1
+	exitUsage    = 2
+	exitConflict = 3
+)
+
+// command is a subcommand; run parses args itself and writes its output
+// to stdout.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"start", "start a session: tt start [category] [task] [--tag name]...", start},
+	{"stop", "stop the running session", stop},
+	{"status", "show the running session", status},
+	{"log", "list sessions", logSessions},
+}
+
+// usageError is an error in the command line rather than in carrying the
+// command out.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func newUsageError(format string, args ...interface{}) error {
+	return usageError(fmt.Sprintf(format, args...))
+}
+
+// errBadFlags is returned for flags the flag package has already reported.
+var errBadFlags = errors.New("invalid flags")
+
+// errNotRunning is returned when a command needs a running session.
+var errNotRunning = errors.New("no session is running")
+
+// parseArgs parses fs's flags wherever they appear among the positional
+// arguments, which it returns, so "tt start work --tag deep" works.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errBadFlags
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newClient creates a client for the configured server.
+func newClient() (*client.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg.URL, cfg.APIKey), nil
+}
+
+// run runs the command named by the first argument and returns the exit
+// status.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		w := stdout
+		if len(args) == 0 {
+			w = stderr
+		}
+		printUsage(w)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return 0
+	}
+
+	name, args := args[0], args[1:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(ctx, args, stdout)
+		var usageErr usageError
+		switch {
+		case err == nil || errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errBadFlags):
+			return exitUsage
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(stderr, "tt %s: %v\n", name, err)
+			return exitUsage
+		case errors.Is(err, client.ErrConflict) || errors.Is(err, errNotRunning):
+			fmt.Fprintf(stderr, "tt %s: %v\n", name, describeError(err))
+			return exitConflict
+		default:
+			fmt.Fprintf(stderr, "tt %s: %v\n", name, describeError(err))
+			return exitFailure
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+	printUsage(stderr)
+	return exitUsage
+}
+
+// describeError explains an API error in words, without the error code.
+func describeError(err error) string {
+	var conflict *client.ConflictError
+	if errors.As(err, &conflict) && conflict.CurrentSession != nil {
+		s := conflict.CurrentSession
+		since := formatClock(s.StartedAt)
+		if s.ElapsedSec != nil {
+			since += ", " + formatDuration(time.Duration(*s.ElapsedSec)*time.Second)
+		}
+		return fmt.Sprintf("a session is already running: %s in %s (#%d, since %s)", s.Task, s.Category, s.ID, since)
+	}
+	var limited *client.RateLimitError
+	if errors.As(err, &limited) {
+		return fmt.Sprintf("too many requests; retry in %s", limited.RetryAfter)
+	}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Message
+	}
+	return err.Error()
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: tt <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "tt <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/app"
+	"time-tracker/internal/shared/middleware"
+	"time-tracker/pkg/client"
+)
+
+const testAPIKey = "test-api-key-32-chars-minimum!!!"
+
+// serveTestApp serves the application in-process and points tt at it
+// through the environment, with an empty config directory.
+func serveTestApp(t *testing.T) *client.Client {
+	t.Helper()
+	cfg := &app.Config{
+		APIKey:    testAPIKey,
+		APIKeys:   []string{testAPIKey},
+		DBPath:    filepath.Join(t.TempDir(), "tt_test.db"),
+		Timezone:  "UTC",
+		RateLimit: 1000,
+		Port:      "0",
+
+		RateLimitExemptPaths: middleware.DefaultRateLimitExemptPaths,
+	}
+	a, err := app.New(cfg, middleware.NewLogger(io.Discard, "text", slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		srv.Close()
+		a.Shutdown()
+	})
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TIMELOG_URL", srv.URL)
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	return client.New(srv.URL, testAPIKey)
+}
+
+// runCommand runs the command line args and returns the exit status and
+// output.
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	return runCommandContext(context.Background(), args...)
+}
+
+func runCommandContext(ctx context.Context, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	if code, _, stderr := runCommand(t); code != exitUsage || !strings.Contains(stderr, "Usage: tt") {
+		t.Errorf("no command: exit %d, stderr %q", code, stderr)
+	}
+	if code, stdout, _ := runCommand(t, "help"); code != 0 || !strings.Contains(stdout, "status") {
+		t.Errorf("help: exit %d, stdout %q", code, stdout)
+	}
+	if code, _, stderr := runCommand(t, "frobnicate"); code != exitUsage || !strings.Contains(stderr, `unknown command "frobnicate"`) {
+		t.Errorf("unknown command: exit %d, stderr %q", code, stderr)
+	}
+	if code, _, stderr := runCommand(t, "start", "a", "b", "c"); code != exitUsage || !strings.Contains(stderr, "at most a category and a task") {
+		t.Errorf("too many arguments: exit %d, stderr %q", code, stderr)
+	}
+	if code, _, stderr := runCommand(t, "log", "--today", "--week"); code != exitUsage || !strings.Contains(stderr, "cannot be combined") {
+		t.Errorf("--today --week: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	c := serveTestApp(t)
+	if _, err := c.CreateTag(context.Background(), client.TagCreate{Name: "deep"}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCommand(t, "start", "work", "fix importer", "--tag", "deep")
+	if code != 0 || !strings.Contains(stdout, "Started #1 work: fix importer [deep]") {
+		t.Fatalf("start: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	tags, err := c.SessionTags(context.Background(), 1)
+	if err != nil || len(tags) != 1 || tags[0].Name != "deep" {
+		t.Errorf("session tags = %+v, %v", tags, err)
+	}
+
+	code, _, stderr = runCommand(t, "start", "study")
+	if code != exitConflict || !strings.Contains(stderr, "already running: fix importer in work (#1") {
+		t.Errorf("start while running: exit %d, stderr %q", code, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, "status")
+	if code != 0 || !strings.Contains(stdout, "Running #1 work: fix importer for") {
+		t.Errorf("status: exit %d, stdout %q", code, stdout)
+	}
+
+	code, stdout, stderr = runCommand(t, "stop", "--note", "done", "--json")
+	var stopped client.Session
+	if code != 0 || json.Unmarshal([]byte(stdout), &stopped) != nil {
+		t.Fatalf("stop --json: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	if stopped.Status != "stopped" || stopped.Note == nil || *stopped.Note != "done" {
+		t.Errorf("stopped session = %+v", stopped)
+	}
+
+	if code, _, stderr := runCommand(t, "stop"); code != exitConflict || !strings.Contains(stderr, "no session is running") {
+		t.Errorf("stop while stopped: exit %d, stderr %q", code, stderr)
+	}
+	if code, stdout, _ := runCommand(t, "status"); code != 0 || strings.TrimSpace(stdout) != "Not running" {
+		t.Errorf("status while stopped: exit %d, stdout %q", code, stdout)
+	}
+
+	code, _, stderr = runCommand(t, "start", "work", "--tag", "shallow")
+	if code != exitUsage || !strings.Contains(stderr, `no tag named "shallow"`) {
+		t.Errorf("unknown tag: exit %d, stderr %q", code, stderr)
+	}
+	if current, err := c.Current(context.Background()); err != nil || current.Running {
+		t.Errorf("unknown tag started a session: %+v, %v", current, err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	c := serveTestApp(t)
+	ctx := context.Background()
+	for _, task := range []string{"first", "second", "third"} {
+		if _, err := c.StartSession(ctx, client.StartRequest{Category: "work", Task: task}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.StopSession(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := runCommand(t, "log", "--today")
+	if code != 0 {
+		t.Fatalf("log: exit %d, stderr %q", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[0], "DURATION") || !strings.Contains(lines[4], "TOTAL") {
+		t.Errorf("log table:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "third") || !strings.Contains(stdout, "first") {
+		t.Errorf("log table is missing sessions:\n%s", stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "log", "--limit", "2", "--json")
+	var sessions []client.Session
+	if code != 0 || json.Unmarshal([]byte(stdout), &sessions) != nil || len(sessions) != 2 {
+		t.Errorf("log --json: exit %d, stdout %q", code, stdout)
+	}
+
+	if code, stdout, _ := runCommand(t, "log", "--category", "study"); code != 0 || strings.TrimSpace(stdout) != "No sessions" {
+		t.Errorf("empty log: exit %d, stdout %q", code, stdout)
+	}
+}
+
+func TestStatus_Watch(t *testing.T) {
+	c := serveTestApp(t)
+	if _, err := c.StartSession(context.Background(), client.StartRequest{Category: "work", Task: "watched"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	code, stdout, stderr := runCommandContext(ctx, "status", "--watch")
+	if code != 0 {
+		t.Fatalf("status --watch: exit %d, stderr %q", code, stderr)
+	}
+	if n := strings.Count(stdout, "\r\033[K"); n < 2 {
+		t.Errorf("status --watch drew %d times, want at least 2: %q", n, stdout)
+	}
+	if !strings.Contains(stdout, "Running #1 work: watched") || !strings.HasSuffix(stdout, "\n") {
+		t.Errorf("status --watch output %q", stdout)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	c := serveTestApp(t)
+	url := os.Getenv("TIMELOG_URL")
+	t.Setenv("TIMELOG_URL", "")
+	t.Setenv("TIMELOG_API_KEY", "")
+
+	if code, _, stderr := runCommand(t, "status"); code != exitFailure || !strings.Contains(stderr, "no API key") {
+		t.Errorf("no API key: exit %d, stderr %q", code, stderr)
+	}
+
+	path, err := configPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	config := "# tt settings\nurl = \"" + url + "\"\napi_key = " + testAPIKey + "\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StartSession(context.Background(), client.StartRequest{Category: "work", Task: "configured"}); err != nil {
+		t.Fatal(err)
+	}
+	if code, stdout, stderr := runCommand(t, "status"); code != 0 || !strings.Contains(stdout, "configured") {
+		t.Errorf("status with a config file: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	if err := os.WriteFile(path, []byte("server = x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runCommand(t, "status"); code != exitFailure || !strings.Contains(stderr, `unknown key "server"`) {
+		t.Errorf("bad config file: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{-time.Second, "0s"},
+		{3 * time.Second, "3s"},
+		{2*time.Minute + 3*time.Second, "2m03s"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1h02m03s"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.in); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -15,7 +15,6 @@ fi
 # 2. 创建数据目录
 echo "创建数据目录..."
 mkdir -p /vol1/1000/docker/timejl/data
-mkdir -p /vol1/1000/docker/timejl/templates
 
 # 3. 停止旧容器
 echo "停止旧容器..."
@@ -0,0 +1,362 @@
+// Package admin provides maintenance endpoints for operators.
+package admin
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/jobs"
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/shared/validation"
+)
+
+// AdminHandler handles HTTP requests for administrative operations.
+type AdminHandler struct {
+	db          *database.DB
+	audit       *audit.Logger
+	maintenance *jobs.Maintenance
+	sessions    *sessions.SessionService
+	anonymize   bool
+	config      func() interface{}
+	logger      *slog.Logger
+}
+
+// NewAdminHandler creates a new AdminHandler.
+func NewAdminHandler(db *database.DB) *AdminHandler {
+	return &AdminHandler{db: db, logger: slog.Default()}
+}
+
+// SetLogger sets the logger for failures after a response has started.
+func (h *AdminHandler) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		h.logger = logger
+	}
+}
+
+// SetAudit enables the audit log endpoint and records backup downloads.
+func (h *AdminHandler) SetAudit(logger *audit.Logger) {
+	h.audit = logger
+}
+
+// SetMaintenance enables the maintenance endpoint.
+func (h *AdminHandler) SetMaintenance(m *jobs.Maintenance) {
+	h.maintenance = m
+}
+
+// SetPurge enables the purge endpoint. anonymize is the mode used when the
+// request does not name one.
+func (h *AdminHandler) SetPurge(svc *sessions.SessionService, anonymize bool) {
+	h.sessions = svc
+	h.anonymize = anonymize
+}
+
+// SetConfig enables the config endpoint. report returns the effective
+// configuration with its secrets already redacted.
+func (h *AdminHandler) SetConfig(report func() interface{}) {
+	h.config = report
+}
+
+// Backup handles GET /api/v1/admin/backup - downloads an online backup of the database.
+// The snapshot is written to a temporary file that is removed once the response completes.
+func (h *AdminHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "timelog-backup-*")
+	if err != nil {
+		errors.WriteError(w, fmt.Errorf("failed to create backup directory: %w", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "backup.db")
+	if err := h.db.BackupTo(path); err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		errors.WriteError(w, fmt.Errorf("failed to open backup: %w", err))
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		errors.WriteError(w, fmt.Errorf("failed to stat backup: %w", err))
+		return
+	}
+
+	h.audit.Record(r, audit.EventBackupDownloaded, map[string]interface{}{"bytes": info.Size()})
+
+	filename := fmt.Sprintf("timelog_backup_%s.db", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if _, err := io.Copy(w, f); err != nil {
+		h.logger.Error("backup download failed", "error", err)
+	}
+}
+
+// Audit handles GET /api/v1/admin/audit - lists audit log entries, newest first.
+// Supports event_type, from and to (RFC3339 or YYYY-MM-DD, UTC; to is
+// exclusive) and limit/offset pagination.
+func (h *AdminHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+	if h.audit == nil {
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+		return
+	}
+
+	query := r.URL.Query()
+	var filter audit.Filter
+	if eventType := validation.SanitizeQueryParam(query.Get("event_type")); eventType != "" {
+		filter.EventType = &eventType
+	}
+	if from := query.Get("from"); from != "" {
+		bound, err := validation.ParseTimeBound(from, time.UTC, false)
+		if err != nil {
+			errors.WriteError(w, errors.ValidationError("from must be an RFC3339 timestamp or YYYY-MM-DD date"))
+			return
+		}
+		filter.From = &bound
+	}
+	if to := query.Get("to"); to != "" {
+		bound, err := validation.ParseTimeBound(to, time.UTC, true)
+		if err != nil {
+			errors.WriteError(w, errors.ValidationError("to must be an RFC3339 timestamp or YYYY-MM-DD date"))
+			return
+		}
+		filter.To = &bound
+	}
+
+	limit, offset := utils.ParsePaginationParams(query, config.DefaultPageSize, config.MaxAuditPageSize)
+	page, err := h.audit.List(filter, limit, offset)
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// Maintenance handles /api/v1/admin/maintenance. GET returns the report of
+// the last run; POST checkpoints the WAL now, and with vacuum=true also runs
+// an incremental vacuum. The run waits for in-flight queries on the shared
+// connection rather than interrupting them.
+func (h *AdminHandler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	if h.maintenance == nil {
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+		return
+	}
+
+	var report *jobs.MaintenanceReport
+	switch r.Method {
+	case http.MethodGet:
+		report = h.maintenance.Last()
+		if report == nil {
+			errors.WriteError(w, errors.NotFoundError("Maintenance has not run yet"))
+			return
+		}
+	case http.MethodPost:
+		vacuum := r.URL.Query().Get("vacuum") == "true"
+		report = h.maintenance.RunNow(r.Context(), vacuum)
+		h.audit.Record(r, audit.EventMaintenanceRun, map[string]interface{}{"vacuum": vacuum, "error": report.Error})
+		if report.Error != "" {
+			errors.WriteError(w, errors.InternalError())
+			return
+		}
+	default:
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// Purge handles POST /api/v1/admin/purge?before=... - deletes stopped sessions
+// that ended before the given RFC3339 timestamp or YYYY-MM-DD date (UTC).
+// mode=delete|anonymize overrides the configured retention mode. The running
+// session is never touched.
+func (h *AdminHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
+		return
+	}
+	if h.sessions == nil {
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+		return
+	}
+
+	query := r.URL.Query()
+	beforeStr := query.Get("before")
+	if beforeStr == "" {
+		errors.WriteError(w, errors.ValidationError("before is required"))
+		return
+	}
+	bound, err := validation.ParseTimeBound(beforeStr, time.UTC, false)
+	if err != nil {
+		errors.WriteError(w, errors.ValidationError("before must be an RFC3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+	before, err := time.Parse(time.RFC3339, bound)
+	if err != nil {
+		errors.WriteError(w, errors.ValidationError("before must be an RFC3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+
+	anonymize := h.anonymize
+	switch query.Get("mode") {
+	case "":
+	case "delete":
+		anonymize = false
+	case "anonymize":
+		anonymize = true
+	default:
+		errors.WriteError(w, errors.ValidationError("mode must be delete or anonymize"))
+		return
+	}
+
+	count, err := h.sessions.PurgeBeforeContext(r.Context(), before, anonymize)
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	h.audit.Record(r, audit.EventSessionsPurged, map[string]interface{}{
+		"before":    bound,
+		"anonymize": anonymize,
+		"count":     count,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"removed":   count,
+		"before":    bound,
+		"anonymize": anonymize,
+	})
+}
+
+// Export handles GET /api/v1/admin/export - downloads every session, tag and
+// tag assignment as a JSON document with their IDs, for moving the data to
+// another instance with Import.
+func (h *AdminHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	dump, err := h.db.Export(r.Context())
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	h.audit.Record(r, audit.EventDataExported, map[string]interface{}{
+		"sessions": len(dump.Sessions),
+		"tags":     len(dump.Tags),
+	})
+
+	filename := fmt.Sprintf("timelog_export_%s.json", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	_ = json.NewEncoder(w).Encode(dump)
+}
+
+// Import handles POST /api/v1/admin/import - loads a document written by
+// Export, keeping its IDs, in one transaction. It is refused with 409 when
+// the database already holds sessions or tags unless force=true, which
+// replaces them.
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+
+	var dump database.Dump
+	body := http.MaxBytesReader(w, r.Body, config.MaxImportBytes)
+	if err := json.NewDecoder(body).Decode(&dump); err != nil {
+		errors.WriteError(w, errors.ValidationError("Invalid JSON document"))
+		return
+	}
+
+	counts, err := h.db.Import(r.Context(), &dump, force)
+	switch {
+	case stderrors.Is(err, database.ErrNotEmpty):
+		errors.WriteError(w, errors.NewConflictError("Database already holds sessions or tags; pass force=true to replace them", nil))
+		return
+	case stderrors.Is(err, database.ErrInvalidDump):
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	case err != nil:
+		errors.WriteError(w, err)
+		return
+	}
+	h.audit.Record(r, audit.EventDataImported, map[string]interface{}{
+		"sessions": counts.Sessions,
+		"tags":     counts.Tags,
+		"force":    force,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(counts)
+}
+
+// Config handles GET /api/v1/admin/config - returns the configuration the
+// server is running with, secrets redacted. The response is never cached.
+func (h *AdminHandler) Config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+	if h.config == nil {
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(h.config())
+}
+
+// ServeHTTP implements http.Handler for routing admin requests.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v1/admin/backup":
+		h.Backup(w, r)
+	case "/api/v1/admin/audit":
+		h.Audit(w, r)
+	case "/api/v1/admin/maintenance":
+		h.Maintenance(w, r)
+	case "/api/v1/admin/purge":
+		h.Purge(w, r)
+	case "/api/v1/admin/export":
+		h.Export(w, r)
+	case "/api/v1/admin/import":
+		h.Import(w, r)
+	case "/api/v1/admin/config":
+		h.Config(w, r)
+	default:
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+	}
+}
@@ -0,0 +1,367 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/jobs"
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/tags"
+)
+
+func TestAdminHandler_Backup(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	for i := 0; i < 5; i++ {
+		if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'task', '2024-03-01T10:00:00Z', 'stopped')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/backup", nil)
+	w := httptest.NewRecorder()
+	NewAdminHandler(db).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	disposition := w.Header().Get("Content-Disposition")
+	if !strings.Contains(disposition, "timelog_backup_") || !strings.Contains(disposition, ".db") {
+		t.Fatalf("unexpected Content-Disposition %q", disposition)
+	}
+
+	// Restore the downloaded file and compare
+	restored := filepath.Join(t.TempDir(), "restored.db")
+	f, err := os.Create(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(f, w.Body); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	restoredDB, err := database.New(restored)
+	if err != nil {
+		t.Fatalf("failed to open restored backup: %v", err)
+	}
+	defer restoredDB.Close()
+
+	var count int
+	if err := restoredDB.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("expected 5 sessions in restored backup, got %d", count)
+	}
+}
+
+func TestAdminHandler_Audit(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	h := NewAdminHandler(db)
+	h.SetAudit(audit.NewLogger(db))
+
+	// Downloading a backup is itself audited
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/admin/backup", nil))
+
+	tests := []struct {
+		query string
+		code  int
+		total int64
+	}{
+		{"", http.StatusOK, 1},
+		{"?event_type=backup_downloaded", http.StatusOK, 1},
+		{"?event_type=auth_failed", http.StatusOK, 0},
+		{"?to=2000-01-01", http.StatusOK, 0},
+		{"?from=not-a-date", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit"+tt.query, nil))
+		if w.Code != tt.code {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.code, w.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var page struct {
+			Items []audit.Entry `json:"items"`
+			Total int64         `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%q: failed to decode response: %v", tt.query, err)
+		}
+		if page.Total != tt.total {
+			t.Errorf("%q: expected total %d, got %d", tt.query, tt.total, page.Total)
+		}
+	}
+}
+
+func TestAdminHandler_Maintenance(t *testing.T) {
+	db := database.NewForTesting(t)
+	h := NewAdminHandler(db)
+
+	// Disabled until a Maintenance is set
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/maintenance", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without maintenance, got %d", w.Code)
+	}
+
+	m := jobs.NewMaintenance(context.Background(), db, time.Hour, 0, nil)
+	defer m.Stop()
+	h.SetMaintenance(m)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/maintenance", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 before the first run, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/maintenance?vacuum=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report jobs.MaintenanceReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Checkpoint == nil || report.Vacuum == nil {
+		t.Fatalf("expected checkpoint and vacuum results, got %+v", report)
+	}
+
+	// The last report stays available
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/maintenance", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/maintenance", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Fatalf("expected status 405 allowing GET and POST for DELETE, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestAdminHandler_Purge(t *testing.T) {
+	db := database.NewForTesting(t)
+	h := NewAdminHandler(db)
+	h.SetAudit(audit.NewLogger(db))
+
+	// Disabled until a session service is set
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/purge?before=2024-01-01", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without purge, got %d", w.Code)
+	}
+
+	h.SetPurge(sessions.NewSessionService(sessions.NewSessionRepository(db)), false)
+	for _, stmt := range []string{
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES ('work', 'old', '2023-01-01T09:00:00Z', '2023-01-01T10:00:00Z', 3600, 'stopped')`,
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES ('work', 'new', '2024-06-01T09:00:00Z', '2024-06-01T10:00:00Z', 3600, 'stopped')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'ancient but running', '2020-01-01T09:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		method string
+		query  string
+		code   int
+	}{
+		{http.MethodGet, "before=2024-01-01", http.StatusMethodNotAllowed},
+		{http.MethodPost, "", http.StatusBadRequest},
+		{http.MethodPost, "before=last-year", http.StatusBadRequest},
+		{http.MethodPost, "before=2024-01-01&mode=shred", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, "/api/v1/admin/purge?"+tt.query, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s %q: expected status %d, got %d", tt.method, tt.query, tt.code, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/purge?before=2024-01-01", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Removed int64 `json:"removed"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Removed != 1 {
+		t.Errorf("removed = %d, want 1", resp.Removed)
+	}
+
+	rows, err := db.Query("SELECT task FROM sessions ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var left []string
+	for rows.Next() {
+		var task string
+		if err := rows.Scan(&task); err != nil {
+			t.Fatal(err)
+		}
+		left = append(left, task)
+	}
+	if strings.Join(left, ",") != "new,ancient but running" {
+		t.Errorf("sessions left = %v, want new and the running one", left)
+	}
+
+	var audited int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE event_type = ?", audit.EventSessionsPurged).Scan(&audited); err != nil {
+		t.Fatal(err)
+	}
+	if audited != 1 {
+		t.Errorf("expected 1 %s audit entry, got %d", audit.EventSessionsPurged, audited)
+	}
+}
+
+func TestAdminHandler_ExportImport(t *testing.T) {
+	src := database.NewForTesting(t)
+	srcSessions := sessions.NewSessionService(sessions.NewSessionRepository(src))
+	srcTags := tags.NewTagService(tags.NewTagRepository(src))
+
+	parent, err := srcTags.Create(&tags.TagCreate{Name: "deep", Color: "#112233"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := srcTags.Create(&tags.TagCreate{Name: "focus", Color: "#445566", ParentID: &parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := "with a note"
+	for i, task := range []string{"first", "second", "third"} {
+		session, err := srcSessions.StartSession(&models.SessionStart{Category: "work", Task: task, Note: &note})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srcTags.AssignToSession(session.ID, []int64{parent.ID, child.ID}[:i%2+1]); err != nil {
+			t.Fatal(err)
+		}
+		// Leave the last one running
+		if i < 2 {
+			if _, err := srcSessions.StopSession(&models.SessionStop{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	NewAdminHandler(src).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	exported := w.Body.String()
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(exported), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"schema_version", "sessions", "tags", "session_tags"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("export has no %q field", key)
+		}
+	}
+
+	dst := database.NewForTesting(t)
+	h := NewAdminHandler(dst)
+	importDoc := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/import"+query, strings.NewReader(exported)))
+		return w
+	}
+	if w := importDoc(""); w.Code != http.StatusOK {
+		t.Fatalf("import: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := importDoc(""); w.Code != http.StatusConflict {
+		t.Errorf("second import: expected status 409, got %d", w.Code)
+	}
+	if w := importDoc("?force=true"); w.Code != http.StatusOK {
+		t.Errorf("forced import: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	dstSessions := sessions.NewSessionService(sessions.NewSessionRepository(dst))
+	dstTags := tags.NewTagService(tags.NewTagRepository(dst))
+	want, err := srcSessions.GetSessions(100, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dstSessions.GetSessions(100, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported sessions differ:\n got %+v\nwant %+v", got, want)
+	}
+	for _, session := range want.Items {
+		wantTags, err := srcTags.ListForSession(session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotTags, err := dstTags.ListForSession(session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotTags, wantTags) {
+			t.Errorf("session %d tags = %+v, want %+v", session.ID, gotTags, wantTags)
+		}
+	}
+
+	for _, body := range []string{"not json", `{"schema_version": 99}`} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/import?force=true", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestAdminHandler_Config(t *testing.T) {
+	h := NewAdminHandler(database.NewForTesting(t))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("without a report: status %d, want 404", w.Code)
+	}
+
+	h.SetConfig(func() interface{} { return map[string]int{"rate_limit": 60} })
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"rate_limit":60}` {
+		t.Errorf("got %d %s", w.Code, w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/config", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want 405", w.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/validation"
+)
+
+type APIKeysHandler struct {
+	service *APIKeyService
+	audit   *audit.Logger
+}
+
+func NewAPIKeysHandler(svc *APIKeyService) *APIKeysHandler {
+	return &APIKeysHandler{service: svc}
+}
+
+// SetAudit records key creation and revocation to the audit log.
+func (h *APIKeysHandler) SetAudit(logger *audit.Logger) {
+	h.audit = logger
+}
+
+func (h *APIKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/api/v1/admin/keys":
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+		}
+	case strings.HasPrefix(path, "/api/v1/admin/keys/"):
+		if r.Method == http.MethodDelete {
+			h.Revoke(w, r)
+		} else {
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodDelete))
+		}
+	default:
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+	}
+}
+
+// Create handles POST /api/v1/admin/keys - creates a key.
+// The plaintext key is only included in this response.
+func (h *APIKeysHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input APIKeyCreate
+	if err := validation.DecodeJSON(w, r, &input, config.MaxJSONBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	created, err := h.service.CreateContext(r.Context(), &input)
+	if err != nil {
+		if strings.Contains(err.Error(), "validation error") {
+			errors.WriteError(w, errors.ValidationErrorFor(err))
+			return
+		}
+		errors.WriteError(w, err)
+		return
+	}
+	h.audit.Record(r, audit.EventAPIKeyCreated, map[string]interface{}{"id": created.ID, "name": created.Name, "scope": created.Scope})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+// List handles GET /api/v1/admin/keys - lists keys without their secrets.
+func (h *APIKeysHandler) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.List()
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(keys)
+}
+
+// Revoke handles DELETE /api/v1/admin/keys/:id - revokes a key.
+// The key is kept, with revoked_at set, so its history stays visible.
+func (h *APIKeysHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/keys/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		errors.WriteError(w, errors.ValidationError("Invalid id"))
+		return
+	}
+
+	revoked, err := h.service.Revoke(id)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			errors.WriteError(w, errors.NotFoundError("API key not found"))
+			return
+		}
+		errors.WriteError(w, err)
+		return
+	}
+	h.audit.Record(r, audit.EventAPIKeyRevoked, map[string]interface{}{"id": revoked.ID, "name": revoked.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(revoked)
+}
@@ -0,0 +1,80 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/shared/auth"
+)
+
+func TestAPIKeysHandler(t *testing.T) {
+	svc, _ := setupTestService(t)
+	defer svc.Stop()
+	h := NewAPIKeysHandler(svc)
+
+	createReq := newRequest(http.MethodPost, "/api/v1/admin/keys", strings.NewReader(`{"name":"laptop","scope":"read"}`))
+	createW := httptest.NewRecorder()
+	h.ServeHTTP(createW, createReq)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", createW.Code, createW.Body.String())
+	}
+	var created CreatedAPIKey
+	if err := json.NewDecoder(createW.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode create response: %v", err)
+	}
+	if created.ID == 0 || created.Key == "" {
+		t.Fatalf("expected id and key in create response, got %+v", created)
+	}
+
+	// The list never contains the key or its hash
+	listW := httptest.NewRecorder()
+	h.ServeHTTP(listW, newRequest(http.MethodGet, "/api/v1/admin/keys", nil))
+	if listW.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", listW.Code)
+	}
+	if strings.Contains(listW.Body.String(), created.Key) || strings.Contains(listW.Body.String(), hashKey(created.Key)) {
+		t.Fatal("list response must not expose the key")
+	}
+	var keys []APIKey
+	if err := json.NewDecoder(listW.Body).Decode(&keys); err != nil || len(keys) != 1 {
+		t.Fatalf("expected one key, got %v (%v)", keys, err)
+	}
+
+	revokeW := httptest.NewRecorder()
+	h.ServeHTTP(revokeW, newRequest(http.MethodDelete, "/api/v1/admin/keys/1", nil))
+	if revokeW.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", revokeW.Code)
+	}
+	if _, _, ok := svc.LookupAPIKey(created.Key); ok {
+		t.Fatal("expected revoked key to be rejected")
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodDelete, "/api/v1/admin/keys/99", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/admin/keys/abc", "", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/admin/keys", `{"name":""}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/admin/keys", `not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, newRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, rr.Code)
+		}
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
+}
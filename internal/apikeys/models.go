@@ -0,0 +1,68 @@
+// Package apikeys manages API keys stored in the database, which can be
+// created and revoked per device at runtime.
+package apikeys
+
+import (
+	"errors"
+
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/validation"
+)
+
+// APIKey describes a stored key. The key itself is never stored or returned
+// after creation.
+type APIKey struct {
+	ID         int64   `json:"id"`
+	UserID     int64   `json:"user_id"`
+	Name       string  `json:"name"`
+	Scope      string  `json:"scope"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+	RevokedAt  *string `json:"revoked_at"`
+}
+
+// CreatedAPIKey is returned once when a key is created and carries the
+// plaintext key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyCreate struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// UserID is the user the key acts for; the caller if unset. Only an
+	// admin may create keys for another user
+	UserID int64 `json:"user_id"`
+}
+
+var (
+	ErrNameRequired = errors.New("name is required")
+	ErrNameTooLong  = errors.New("name is too long")
+	ErrInvalidScope = errors.New("scope must be full or read")
+	ErrKeyNotFound  = errors.New("api key not found")
+	ErrUnknownUser  = errors.New("user does not exist")
+	ErrForeignUser  = errors.New("only an admin may create keys for another user")
+)
+
+// Validate sanitizes the input; an empty scope defaults to full access. The
+// user is checked against the caller by APIKeyService.CreateContext.
+func (k *APIKeyCreate) Validate() error {
+	k.Name = validation.SanitizeString(k.Name)
+	if k.Name == "" {
+		return ErrNameRequired
+	}
+	if !validation.ValidateStringLength(k.Name, 1, config.MaxAPIKeyNameLength) {
+		return ErrNameTooLong
+	}
+
+	switch k.Scope {
+	case "":
+		k.Scope = auth.ScopeFull
+	case auth.ScopeFull, auth.ScopeRead:
+	default:
+		return ErrInvalidScope
+	}
+	return nil
+}
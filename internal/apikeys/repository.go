@@ -0,0 +1,158 @@
+package apikeys
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+type APIKeyRepository struct {
+	db *database.DB
+}
+
+func NewAPIKeyRepository(db *database.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// apiKeyColumns is the column list shared by every key query.
+const apiKeyColumns = "id, user_id, name, scope, created_at, last_used_at, revoked_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var k APIKey
+	var lastUsedAt, revokedAt sql.NullString
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Scope, &k.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.String
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.String
+	}
+	return &k, nil
+}
+
+// activeKey is the part of a non-revoked key needed to authenticate requests.
+type activeKey struct {
+	id     int64
+	userID int64
+	scope  string
+}
+
+func (r *APIKeyRepository) Create(userID int64, name, keyHash, scope string) (*APIKey, error) {
+	res, err := r.db.Exec(
+		`INSERT INTO api_keys (user_id, name, key_hash, scope, created_at) VALUES (?, ?, ?, ?, strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
+		userID, name, keyHash, scope,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert api key: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return r.GetByID(id)
+}
+
+func (r *APIKeyRepository) GetByID(id int64) (*APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key: %w", err)
+	}
+	return k, nil
+}
+
+// UserExists reports whether there is a user with the given ID.
+func (r *APIKeyRepository) UserExists(userID int64) (bool, error) {
+	var exists bool
+	if err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to query user: %w", err)
+	}
+	return exists, nil
+}
+
+// List returns all keys, including revoked ones, oldest first.
+func (r *APIKeyRepository) List() ([]APIKey, error) {
+	rows, err := r.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke marks the key as revoked. Revoking an already revoked key keeps
+// the original revocation time.
+func (r *APIKeyRepository) Revoke(id int64) error {
+	_, err := r.db.Exec(
+		`UPDATE api_keys SET revoked_at = strftime('%Y-%m-%dT%H:%M:%SZ','now') WHERE id = ? AND revoked_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return nil
+}
+
+// ActiveKeys returns the non-revoked keys indexed by key hash.
+func (r *APIKeyRepository) ActiveKeys() (map[string]activeKey, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, key_hash, scope FROM api_keys WHERE revoked_at IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]activeKey)
+	for rows.Next() {
+		var hash string
+		var k activeKey
+		if err := rows.Scan(&k.id, &k.userID, &hash, &k.scope); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys[hash] = k
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// TouchLastUsed records when each key was last used, in one transaction.
+func (r *APIKeyRepository) TouchLastUsed(used map[int64]time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, at := range used {
+		if _, err := tx.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at.UTC().Format(time.RFC3339), id); err != nil {
+			return fmt.Errorf("failed to update api key last use: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit last use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
+)
+
+// keyPrefix marks generated keys so they are recognisable in config files.
+const keyPrefix = "tt_"
+
+// hashKey returns the stored form of a key. Generated keys carry 256 bits of
+// randomness, so a fast hash is enough to keep them unrecoverable.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyService manages stored API keys. Active keys are cached in memory by
+// hash so authentication needs no query; the cache is reloaded after every
+// create and revoke. Last-use times are collected in memory and written
+// periodically instead of once per request.
+type APIKeyService struct {
+	repo   *APIKeyRepository
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	active map[string]activeKey
+
+	usedMu sync.Mutex
+	used   map[int64]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewAPIKeyService loads the active keys and starts recording last use.
+// Call Stop to write pending last-use times and end it. A nil logger logs
+// to slog.Default().
+func NewAPIKeyService(repo *APIKeyRepository, logger *slog.Logger) (*APIKeyService, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &APIKeyService{
+		repo:   repo,
+		logger: logger,
+		used:   make(map[int64]time.Time),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// refresh reloads the active key cache from the database.
+func (s *APIKeyService) refresh() error {
+	active, err := s.repo.ActiveKeys()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.active = active
+	s.mu.Unlock()
+	return nil
+}
+
+// run flushes last-use times until Stop is called.
+func (s *APIKeyService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(config.APIKeyLastUsedFlushEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flushLastUsed()
+		case <-s.stop:
+			s.flushLastUsed()
+			return
+		}
+	}
+}
+
+// flushLastUsed writes the collected last-use times. On failure they are
+// kept and retried with the next flush.
+func (s *APIKeyService) flushLastUsed() {
+	s.usedMu.Lock()
+	used := s.used
+	s.used = make(map[int64]time.Time)
+	s.usedMu.Unlock()
+
+	if len(used) == 0 {
+		return
+	}
+	if err := s.repo.TouchLastUsed(used); err != nil {
+		s.logger.Error("failed to record API key use", "error", err)
+		s.usedMu.Lock()
+		for id, at := range used {
+			if _, ok := s.used[id]; !ok {
+				s.used[id] = at
+			}
+		}
+		s.usedMu.Unlock()
+	}
+}
+
+// Stop writes pending last-use times and stops the background flush.
+// It may be called more than once.
+func (s *APIKeyService) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// LookupAPIKey implements auth.KeyLookup.
+func (s *APIKeyService) LookupAPIKey(key string) (string, int64, bool) {
+	s.mu.RLock()
+	k, ok := s.active[hashKey(key)]
+	s.mu.RUnlock()
+	if !ok {
+		return "", 0, false
+	}
+
+	s.usedMu.Lock()
+	s.used[k.id] = time.Now()
+	s.usedMu.Unlock()
+	return k.scope, k.userID, true
+}
+
+// Create generates a new key as an admin acting for the default user, as
+// from the command line. The plaintext key is only available in the result.
+func (s *APIKeyService) Create(input *APIKeyCreate) (*CreatedAPIKey, error) {
+	return s.CreateContext(auth.WithAdmin(auth.DefaultUserContext()), input)
+}
+
+// CreateContext generates a new key for the user ctx acts for, or with
+// input.UserID set, for that user, which ctx must be an admin for unless it
+// is the caller.
+func (s *APIKeyService) CreateContext(ctx context.Context, input *APIKeyCreate) (*CreatedAPIKey, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	caller, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case input.UserID == 0:
+		input.UserID = caller
+	case input.UserID != caller && !auth.IsAdmin(ctx):
+		return nil, fmt.Errorf("validation error: %w", ErrForeignUser)
+	}
+	exists, err := s.repo.UserExists(input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("validation error: %w", ErrUnknownUser)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	created, err := s.repo.Create(input.UserID, input.Name, hashKey(key), input.Scope)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: *created, Key: key}, nil
+}
+
+func (s *APIKeyService) List() ([]APIKey, error) {
+	return s.repo.List()
+}
+
+// Revoke revokes a key; it stops working immediately.
+// Returns ErrKeyNotFound if the key does not exist.
+func (s *APIKeyService) Revoke(id int64) (*APIKey, error) {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	if err := s.repo.Revoke(id); err != nil {
+		return nil, err
+	}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
@@ -0,0 +1,189 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+)
+
+func setupTestService(t *testing.T) (*APIKeyService, *APIKeyRepository) {
+	t.Helper()
+
+	db := database.NewForTesting(t)
+
+	repo := NewAPIKeyRepository(db)
+	svc, err := NewAPIKeyService(repo, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return svc, repo
+}
+
+func TestAPIKeyService_CreateLookupRevoke(t *testing.T) {
+	svc, _ := setupTestService(t)
+	defer svc.Stop()
+
+	created, err := svc.Create(&APIKeyCreate{Name: " laptop ", Scope: auth.ScopeRead})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Name != "laptop" || created.Scope != auth.ScopeRead {
+		t.Fatalf("unexpected key: %+v", created.APIKey)
+	}
+	if !strings.HasPrefix(created.Key, keyPrefix) || len(created.Key) < 32 {
+		t.Fatalf("unexpected generated key %q", created.Key)
+	}
+
+	scope, userID, ok := svc.LookupAPIKey(created.Key)
+	if !ok || scope != auth.ScopeRead || userID != database.DefaultUserID {
+		t.Fatalf("expected new key to be found with read scope for the default user, got %q, %d, %v", scope, userID, ok)
+	}
+	if _, _, ok := svc.LookupAPIKey(created.Key + "x"); ok {
+		t.Fatal("expected unknown key to be rejected")
+	}
+
+	revoked, err := svc.Revoke(created.ID)
+	if err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if revoked.RevokedAt == nil {
+		t.Fatal("expected revoked_at to be set")
+	}
+	if _, _, ok := svc.LookupAPIKey(created.Key); ok {
+		t.Fatal("expected revoked key to be rejected")
+	}
+
+	if _, err := svc.Revoke(999); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestAPIKeyService_KeyForUser(t *testing.T) {
+	db := database.NewForTesting(t)
+	if _, err := db.Exec(`INSERT INTO users (id, name, created_at) VALUES (2, 'partner', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewAPIKeyService(NewAPIKeyRepository(db), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Stop()
+
+	created, err := svc.Create(&APIKeyCreate{Name: "partner phone", UserID: 2})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.UserID != 2 {
+		t.Errorf("created key user_id = %d, want 2", created.UserID)
+	}
+	if _, userID, ok := svc.LookupAPIKey(created.Key); !ok || userID != 2 {
+		t.Errorf("LookupAPIKey = user %d, %v; want user 2", userID, ok)
+	}
+}
+
+func TestAPIKeyService_KeyOwner(t *testing.T) {
+	db := database.NewForTesting(t)
+	if _, err := db.Exec(`INSERT INTO users (id, name, created_at) VALUES (2, 'partner', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewAPIKeyService(NewAPIKeyRepository(db), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Stop()
+	partner := auth.WithUserID(context.Background(), 2)
+
+	// A key without a user belongs to the caller
+	created, err := svc.CreateContext(partner, &APIKeyCreate{Name: "partner phone"})
+	if err != nil {
+		t.Fatalf("CreateContext failed: %v", err)
+	}
+	if created.UserID != 2 {
+		t.Errorf("created key user_id = %d, want the caller", created.UserID)
+	}
+
+	// Only an admin may create keys for someone else
+	if _, err := svc.CreateContext(partner, &APIKeyCreate{Name: "stolen", UserID: database.DefaultUserID}); !errors.Is(err, ErrForeignUser) {
+		t.Errorf("non-admin key for another user: %v, want ErrForeignUser", err)
+	}
+	if _, err := svc.CreateContext(auth.WithAdmin(partner), &APIKeyCreate{Name: "granted", UserID: database.DefaultUserID}); err != nil {
+		t.Errorf("admin key for another user: %v", err)
+	}
+
+	// Nobody authenticated may create a key at all
+	if _, err := svc.CreateContext(context.Background(), &APIKeyCreate{Name: "anonymous"}); !errors.Is(err, auth.ErrNoUser) {
+		t.Errorf("key without a user: %v, want ErrNoUser", err)
+	}
+}
+
+func TestAPIKeyService_Validation(t *testing.T) {
+	svc, _ := setupTestService(t)
+	defer svc.Stop()
+
+	created, err := svc.Create(&APIKeyCreate{Name: "phone"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Scope != auth.ScopeFull {
+		t.Errorf("expected default scope %q, got %q", auth.ScopeFull, created.Scope)
+	}
+
+	for _, input := range []APIKeyCreate{
+		{Name: "  "},
+		{Name: strings.Repeat("a", 101)},
+		{Name: "tablet", Scope: "admin"},
+		{Name: "tablet", UserID: 42},
+	} {
+		if _, err := svc.Create(&input); err == nil || !strings.Contains(err.Error(), "validation error") {
+			t.Errorf("expected validation error for %+v, got %v", input, err)
+		}
+	}
+}
+
+func TestAPIKeyService_LastUsedIsDeferred(t *testing.T) {
+	svc, repo := setupTestService(t)
+
+	created, err := svc.Create(&APIKeyCreate{Name: "ci"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, _, ok := svc.LookupAPIKey(created.Key); !ok {
+		t.Fatal("expected key to be found")
+	}
+
+	// Nothing is written per request
+	k, _ := repo.GetByID(created.ID)
+	if k.LastUsedAt != nil {
+		t.Fatal("expected last_used_at to be written asynchronously")
+	}
+
+	// Stop flushes pending last-use times
+	svc.Stop()
+	k, _ = repo.GetByID(created.ID)
+	if k.LastUsedAt == nil {
+		t.Fatal("expected last_used_at after flush")
+	}
+}
+
+func TestAPIKeyService_CacheLoadedOnStart(t *testing.T) {
+	svc, repo := setupTestService(t)
+	created, err := svc.Create(&APIKeyCreate{Name: "server"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	svc.Stop()
+
+	// A new service, as after a restart, knows existing keys
+	restarted, err := NewAPIKeyService(repo, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer restarted.Stop()
+	if _, _, ok := restarted.LookupAPIKey(created.Key); !ok {
+		t.Fatal("expected stored key to be valid after restart")
+	}
+}
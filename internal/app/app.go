@@ -5,32 +5,81 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
-	"path/filepath"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"time-tracker/internal/admin"
+	"time-tracker/internal/apikeys"
+	"time-tracker/internal/audit"
 	"time-tracker/internal/handler"
+	"time-tracker/internal/importer"
+	"time-tracker/internal/jobs"
+	"time-tracker/internal/presets"
+	"time-tracker/internal/reminders"
 
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/buildinfo"
+	"time-tracker/internal/shared/config"
 	"time-tracker/internal/shared/database"
 	"time-tracker/internal/shared/middleware"
+	"time-tracker/internal/shared/tracing"
 	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
 	"time-tracker/internal/shared/health"
 	"time-tracker/internal/tags"
+	"time-tracker/internal/users"
 	"time-tracker/internal/web"
 )
 
 // App holds the application dependencies and HTTP server.
 type App struct {
 	cfg         *Config
+	logger      *slog.Logger
+	logLevel    *slog.LevelVar
+	reloadMu    sync.Mutex
 	db          *database.DB
 	tz          *time.Location
 	server      *http.Server
 	rateLimiter *middleware.RateLimiter
+	credentials *auth.CredentialStore
+	apiKeys     *apikeys.APIKeyService
+	audit       *audit.Logger
+	webSessions *auth.WebSessionStore
+
+	// events feeds the session streams, which are ended by closing it
+	events *sessions.Events
+
+	// redirectServer answers plain HTTP with redirects to https, if configured
+	redirectServer *http.Server
+
+	// jobsCtx is cancelled at the start of Shutdown to stop background jobs
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+
+	autoExporter *jobs.AutoExporter
+	autoBackup   *jobs.AutoBackup
+	maintenance  *jobs.Maintenance
+	retention    *jobs.Retention
+	reminders    *jobs.ReminderChecker
+
+	// traceExporter sends spans to the OTLP endpoint, if configured
+	traceExporter *tracing.OTLPExporter
 }
 
-// New creates and wires all application dependencies.
-func New(cfg *Config) (*App, error) {
+// New creates and wires all application dependencies. Everything logs to
+// logger; nil means slog.Default().
+func New(cfg *Config, logger *slog.Logger) (*App, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	// Parse timezone
 	tz, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
@@ -38,60 +87,229 @@ func New(cfg *Config) (*App, error) {
 	}
 
 	// Initialize database
-	db, err := database.New(cfg.DBPath)
+	db, err := cfg.OpenDatabase(logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
+	if err := repairRunningSessions(db, cfg.RepairRefuse, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	// Initialize repositories
 	sessionRepo := sessions.NewSessionRepository(db)
+	sessionRepo.SetLogger(logger)
 	tagsRepo := tags.NewTagRepository(db)
 
 	// Initialize services
 	sessionService := sessions.NewSessionService(sessionRepo)
+	sessionService.SetDefaults(models.Defaults{Category: cfg.DefaultCategory, Task: cfg.DefaultTask})
+	// The web list offers pages up to MaxListPageSize whatever the API limit
+	sessionService.SetMaxPageSize(max(cfg.MaxPageSize, config.MaxListPageSize))
 	tagsService := tags.NewTagService(tagsRepo)
+	tagsService.SetBulkAssignMax(cfg.BulkAssignMax)
+
+	// Seed default tags (idempotent, existing names are skipped)
+	if len(cfg.SeedTags) > 0 {
+		created, err := tagsService.Seed(cfg.SeedTags)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to seed tags: %w", err)
+		}
+		logger.Info("seeded default tags", "created", created, "total", len(cfg.SeedTags))
+	}
 
 	// Initialize handlers
 	sessionsHandler := handler.NewSessionsHandler(sessionService)
+	sessionsHandler.SetTimezone(tz)
+	sessionsHandler.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	sessionsHandler.SetStreamHeartbeat(cfg.StreamHeartbeat)
+	sessionsHandler.SetLogger(logger)
+	sessionsHandler.SetMaxPageSize(cfg.MaxPageSize)
 	tagsHandler := tags.NewTagsHandler(tagsService)
-	healthHandler := health.NewHealthHandler()
+	tagsHandler.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	presetService := presets.NewPresetService(presets.NewPresetRepository(db), sessionService, tagsService)
+	presetsHandler := presets.NewPresetsHandler(presetService)
+	presetsHandler.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	reminderService := reminders.NewReminderService(reminders.NewRuleRepository(db))
+	sessionService.SetReminders(reminderService)
+	remindersHandler := reminders.NewRemindersHandler(reminderService)
+	remindersHandler.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	healthHandler := health.NewHealthHandler(db)
 
-	absTemplates, err := filepath.Abs("templates")
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve templates path: %w", err)
+	// Templates are embedded; a directory can be set to edit them without rebuilding
+	var templatesFS fs.FS
+	if cfg.TemplatesDir != "" {
+		templatesFS = os.DirFS(cfg.TemplatesDir)
 	}
-	webHandler, err := web.NewWebHandler(sessionService, absTemplates, tz, cfg.APIKey)
+	webHandler, err := web.NewWebHandler(sessionService, tagsService, templatesFS, tz)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize web handler: %w", err)
 	}
+	webHandler.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	webHandler.SetStreamHeartbeat(cfg.StreamHeartbeat)
+	webHandler.SetPresets(presetService)
+	webHandler.SetLogger(logger)
 
 	// Initialize rate limiter
 	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit)
 
 	// Create router with all routes
-	mux := NewRouter(cfg, sessionsHandler, tagsHandler, healthHandler, webHandler)
+	adminHandler := admin.NewAdminHandler(db)
+	adminHandler.SetLogger(logger)
+	togglImporter := importer.NewImporter(sessionRepo, tagsService)
+	togglImporter.SetDefaults(sessionService.Defaults())
+	importHandler := importer.NewImportHandler(togglImporter)
+	importHandler.SetTimezone(tz)
+
+	// Keys managed through the admin API; the configured keys stay valid alongside them
+	apiKeyService, err := apikeys.NewAPIKeyService(apikeys.NewAPIKeyRepository(db), logger)
+	if err != nil {
+		rateLimiter.Stop()
+		db.Close()
+		return nil, fmt.Errorf("failed to load api keys: %w", err)
+	}
+	apiKeysHandler := apikeys.NewAPIKeysHandler(apiKeyService)
+
+	// Users besides the default one, who sign in with their own password
+	userService := users.NewUserService(users.NewUserRepository(db), logger)
+	usersHandler := users.NewUsersHandler(userService)
+
+	// Record failed logins, key changes and destructive operations
+	auditLogger := audit.NewLogger(db)
+	auditLogger.SetLogger(logger)
+	adminHandler.SetAudit(auditLogger)
+	apiKeysHandler.SetAudit(auditLogger)
+	usersHandler.SetAudit(auditLogger)
+	tagsHandler.SetAudit(auditLogger)
+	importHandler.SetAudit(auditLogger)
+	webHandler.SetAudit(auditLogger)
+	adminHandler.SetPurge(sessionService, cfg.RetentionAnonymize)
+
+	credentials := auth.NewCredentialStore(cfg.Credentials())
+	credentials.SetKeyLookup(apiKeyService)
+	credentials.SetUserLookup(userService)
+	credentials.SetAuditRecorder(auditLogger)
+	webSessions := auth.NewWebSessionStore(config.WebSessionTTL)
+	webHandler.SetAuth(credentials, webSessions)
+	mux := NewRouter(cfg, credentials, webSessions, sessionsHandler, tagsHandler, healthHandler, webHandler, adminHandler, apiKeysHandler, usersHandler, importHandler, presetsHandler, remindersHandler)
 
 	// Apply global middleware chain
-	finalHandler := setupMiddlewareChain(mux, rateLimiter)
+	finalHandler := setupMiddlewareChain(mux, rateLimiter, middleware.RateLimitOptions{ExemptPaths: cfg.RateLimitExemptPaths}, cfg.Security, cfg.TrustedProxies, logger)
 
-	return &App{
+	a := &App{
 		cfg:         cfg,
+		logger:      logger,
 		db:          db,
 		tz:          tz,
 		server: &http.Server{
-			Addr:    ":" + cfg.Port,
-			Handler: finalHandler,
+			Addr:     cfg.ListenAddr(),
+			Handler:  finalHandler,
+			ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		},
 		rateLimiter: rateLimiter,
-	}, nil
+		credentials: credentials,
+		apiKeys:     apiKeyService,
+		audit:       auditLogger,
+		webSessions: webSessions,
+		events:      sessionService.Events(),
+	}
+	a.jobsCtx, a.cancelJobs = context.WithCancel(context.Background())
+	adminHandler.SetConfig(a.configReport)
+
+	// Load the TLS certificate up front so a bad file fails startup
+	if cfg.TLSEnabled() {
+		tlsConfig, err := newTLSConfig(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			a.cancelJobs()
+			apiKeyService.Stop()
+			rateLimiter.Stop()
+			db.Close()
+			return nil, err
+		}
+		a.server.TLSConfig = tlsConfig
+
+		if cfg.HTTPRedirectPort != "" {
+			a.redirectServer = &http.Server{
+				Addr:     ":" + cfg.HTTPRedirectPort,
+				Handler:  httpsRedirectHandler(cfg.Port),
+				ErrorLog: a.server.ErrorLog,
+			}
+		}
+	}
+
+	// Export traces if an OTLP endpoint is configured
+	if cfg.Tracing.Endpoint != "" {
+		a.traceExporter = tracing.NewOTLPExporter(cfg.Tracing, logger)
+		tracing.SetExporter(a.traceExporter)
+	}
+
+	// Start scheduled CSV export if configured
+	if cfg.AutoExportDir != "" {
+		a.autoExporter = jobs.NewAutoExporter(a.jobsCtx, sessionService, userService, cfg.AutoExportDir, cfg.AutoExportInterval, cfg.AutoExportRetain, tz, logger)
+	}
+
+	// Checkpoint the WAL, and vacuum if configured, on a schedule
+	if cfg.MaintenanceInterval > 0 {
+		a.maintenance = jobs.NewMaintenance(a.jobsCtx, db, cfg.MaintenanceInterval, cfg.VacuumInterval, logger)
+		adminHandler.SetMaintenance(a.maintenance)
+	}
+
+	// Start scheduled database backup if configured
+	if cfg.BackupDir != "" {
+		a.autoBackup = jobs.NewAutoBackup(a.jobsCtx, db, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep, logger)
+		healthHandler.SetBackupCheck(a.autoBackup.Err)
+	}
+
+	// Purge sessions past the retention period, if one is configured
+	if cfg.RetentionDays > 0 {
+		maxAge := time.Duration(cfg.RetentionDays) * 24 * time.Hour
+		a.retention = jobs.NewRetention(a.jobsCtx, sessionService, maxAge, cfg.RetentionAnonymize, config.RetentionInterval, auditLogger, logger)
+	}
+
+	// Fire long-session reminders
+	if cfg.ReminderInterval > 0 {
+		a.reminders = jobs.NewReminderChecker(a.jobsCtx, sessionService, reminderService, cfg.ReminderInterval, logger)
+	}
+
+	return a, nil
+}
+
+// repairRunningSessions checks that no user has more than one running
+// session, which would leave all but one beyond reach of the stop action.
+// It stops the extras, or with refuse set returns an error so the server
+// does not start until someone looks.
+func repairRunningSessions(db *database.DB, refuse bool, logger *slog.Logger) error {
+	ctx := context.Background()
+	extra, err := db.ExtraRunningSessions(ctx)
+	if err != nil {
+		return err
+	}
+	if extra == 0 {
+		return nil
+	}
+	if refuse {
+		logger.Error("found more than one running session per user, refusing to start", "extra", extra)
+		return fmt.Errorf("%d extra running sessions found; stop them or set TIMELOG_REPAIR_MODE=stop", extra)
+	}
+	stopped, err := db.StopExtraRunning(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Error("found more than one running session per user, stopped all but the most recent", "stopped", stopped)
+	return nil
 }
 
 // setupMiddlewareChain creates the middleware chain in the correct order.
-func setupMiddlewareChain(mux *http.ServeMux, rateLimiter *middleware.RateLimiter) http.Handler {
+func setupMiddlewareChain(mux *http.ServeMux, rateLimiter *middleware.RateLimiter, rateLimit middleware.RateLimitOptions, security middleware.SecurityOptions, trustedProxies []netip.Prefix, requestLogger *slog.Logger) http.Handler {
 	var finalHandler http.Handler = mux
 
+	// Turn handler panics into 500 responses, innermost so the
+	// middlewares below still see and log the response
+	finalHandler = middleware.RecoveryMiddleware(requestLogger)(finalHandler)
+
 	// Apply rate limiting
-	finalHandler = middleware.RateLimitMiddleware(rateLimiter)(finalHandler)
+	finalHandler = middleware.RateLimitWithOptions(rateLimiter, rateLimit)(finalHandler)
 
 	// Apply nonce middleware (CSP)
 	nonceMiddleware := func(next http.Handler) http.Handler {
@@ -109,37 +327,243 @@ func setupMiddlewareChain(mux *http.ServeMux, rateLimiter *middleware.RateLimite
 	finalHandler = nonceMiddleware(finalHandler)
 
 	// Apply security headers
-	finalHandler = middleware.SecurityHeadersMiddleware(finalHandler)
+	finalHandler = middleware.SecurityHeadersWithOptions(security)(finalHandler)
+
+	// Trace requests when an OTLP endpoint is configured
+	finalHandler = middleware.TracingMiddleware(finalHandler)
+
+	// Log every request, including those rejected by the middlewares above
+	finalHandler = middleware.RequestLoggingMiddleware(requestLogger)(finalHandler)
+
+	// Resolve the client address once, honoring forwarding headers only from trusted proxies
+	finalHandler = middleware.ClientIPMiddleware(trustedProxies)(finalHandler)
+
+	// Assign request IDs first so every log line can carry one
+	finalHandler = middleware.RequestIDMiddleware(finalHandler)
+
+	// Identify the build on every response
+	finalHandler = middleware.VersionHeaderMiddleware(buildinfo.Version)(finalHandler)
 
 	return finalHandler
 }
 
-// Run starts the HTTP server and blocks until shutdown.
+// Run starts the HTTP server on the configured address, a TCP address or a
+// Unix socket, and the HTTPS redirect listener if configured, and blocks
+// until shutdown.
 func (a *App) Run() error {
-	log.Printf("Server listening on %s", a.server.Addr)
-	if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	ln, err := listen(a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("server error: %w", err)
+	}
+
+	if a.redirectServer != nil {
+		go func() {
+			a.logger.Info("redirecting HTTP to HTTPS", "addr", a.redirectServer.Addr)
+			if err := a.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				a.logger.Error("redirect server error", "error", err)
+			}
+		}()
+	}
+
+	return a.Serve(ln)
+}
+
+// Serve accepts connections on ln until Shutdown is called, using TLS if
+// configured. Run calls it with a listener on the configured address.
+func (a *App) Serve(ln net.Listener) error {
+	var err error
+	if a.server.TLSConfig == nil {
+		a.logger.Info("server listening", "network", ln.Addr().Network(), "addr", ln.Addr().String())
+		err = a.server.Serve(ln)
+	} else {
+		a.logger.Info("server listening", "network", ln.Addr().Network(), "addr", ln.Addr().String(), "tls", true)
+		// The certificate is already in TLSConfig
+		err = a.server.ServeTLS(ln, "", "")
+	}
+	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("server error: %w", err)
 	}
 	return nil
 }
 
+// Handler returns the App's HTTP handler with its middleware, for serving
+// it from a test server.
+func (a *App) Handler() http.Handler {
+	return a.server.Handler
+}
+
+// configReport returns the effective configuration for the admin API,
+// including the credentials and settings applied by Reload.
+func (a *App) configReport() interface{} {
+	a.reloadMu.Lock()
+	cfg := *a.cfg
+	a.reloadMu.Unlock()
+
+	creds := a.credentials.Load()
+	cfg.APIKeys = creds.APIKeys
+	cfg.BasicUser = creds.BasicUser
+	cfg.BasicPass = creds.BasicPass
+	cfg.BasicPassHash = creds.BasicPassHash
+	cfg.AdminKey = creds.AdminKey
+	return cfg.Report(time.Now())
+}
+
+// SetLogLevel gives the App the level of its logger, so Reload can change
+// it. Without one a changed log level needs a restart.
+func (a *App) SetLogLevel(level *slog.LevelVar) {
+	if level != nil {
+		a.logLevel = level
+	}
+}
+
+// Reload re-reads the configuration and applies what can change without a
+// restart: the API keys, Basic Auth and admin credentials, the rate limit,
+// the log level, the scheduled export and backup intervals and the
+// retention period. Other settings only take effect on restart; changes to
+// them are logged and ignored. On error nothing changes.
+func (a *App) Reload() error {
+	cfg, err := LoadConfigFlags(a.cfg.ConfigFile, a.cfg.ConfigFlags)
+	if err != nil {
+		return fmt.Errorf("failed to reload configuration: %w", err)
+	}
+
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	ignored := []string{}
+	if cfg.ListenAddr() != a.cfg.ListenAddr() {
+		ignored = append(ignored, "TIMELOG_LISTEN")
+	}
+	if cfg.DBPath != a.cfg.DBPath {
+		ignored = append(ignored, "TIMELOG_DB_PATH")
+	}
+	if cfg.Timezone != a.cfg.Timezone {
+		ignored = append(ignored, "TIMELOG_TZ")
+	}
+	if strings.Join(cfg.RateLimitExemptPaths, ",") != strings.Join(a.cfg.RateLimitExemptPaths, ",") {
+		ignored = append(ignored, "TIMELOG_RATE_LIMIT_EXEMPT")
+	}
+	if cfg.LogFormat != a.cfg.LogFormat {
+		ignored = append(ignored, "TIMELOG_LOG_FORMAT")
+	}
+	// Jobs are only started or stopped on restart
+	if cfg.AutoExportDir != a.cfg.AutoExportDir {
+		ignored = append(ignored, "TIMELOG_AUTO_EXPORT_DIR")
+	}
+	if cfg.BackupDir != a.cfg.BackupDir {
+		ignored = append(ignored, "TIMELOG_BACKUP_DIR")
+	}
+	if (cfg.RetentionDays > 0) != (a.cfg.RetentionDays > 0) {
+		ignored = append(ignored, "TIMELOG_RETENTION_DAYS")
+	}
+	if len(ignored) > 0 {
+		a.logger.Warn("reload: ignoring changes that need a restart", "settings", strings.Join(ignored, ", "))
+	}
+
+	applied := []string{}
+	if cfg.RateLimit != a.cfg.RateLimit {
+		a.rateLimiter.SetLimit(cfg.RateLimit)
+		a.cfg.RateLimit = cfg.RateLimit
+		applied = append(applied, "TIMELOG_RATE_LIMIT")
+	}
+	if cfg.LogLevel != a.cfg.LogLevel && a.logLevel != nil {
+		a.logLevel.Set(cfg.LogLevel)
+		a.cfg.LogLevel = cfg.LogLevel
+		applied = append(applied, "TIMELOG_LOG_LEVEL")
+	}
+	if cfg.AutoExportInterval != a.cfg.AutoExportInterval && a.autoExporter != nil {
+		a.autoExporter.SetInterval(cfg.AutoExportInterval)
+		a.cfg.AutoExportInterval = cfg.AutoExportInterval
+		applied = append(applied, "TIMELOG_AUTO_EXPORT_INTERVAL")
+	}
+	if cfg.BackupInterval != a.cfg.BackupInterval && a.autoBackup != nil {
+		a.autoBackup.SetInterval(cfg.BackupInterval)
+		a.cfg.BackupInterval = cfg.BackupInterval
+		applied = append(applied, "TIMELOG_BACKUP_INTERVAL")
+	}
+	if cfg.RetentionDays != a.cfg.RetentionDays && a.retention != nil && cfg.RetentionDays > 0 {
+		a.retention.SetMaxAge(time.Duration(cfg.RetentionDays) * 24 * time.Hour)
+		a.cfg.RetentionDays = cfg.RetentionDays
+		applied = append(applied, "TIMELOG_RETENTION_DAYS")
+	}
+	if len(applied) > 0 {
+		a.logger.Info("reload: settings updated", "settings", strings.Join(applied, ", "))
+	}
+
+	// Changed web credentials log out every web session
+	current := a.credentials.Load()
+	webChanged := cfg.BasicUser != current.BasicUser || cfg.BasicPass != current.BasicPass || cfg.BasicPassHash != current.BasicPassHash
+	if webChanged {
+		a.webSessions.Clear()
+	}
+	a.audit.Log(audit.EventCredentialsReloaded, "system", "", map[string]interface{}{
+		"api_keys":          len(cfg.APIKeys),
+		"web_login_changed": webChanged,
+		"admin_key_changed": cfg.AdminKey != current.AdminKey,
+	})
+	a.credentials.Store(cfg.Credentials())
+	a.logger.Info("reload: credentials updated", "api_keys", len(cfg.APIKeys))
+	return nil
+}
+
 // Shutdown gracefully shuts down the server.
 func (a *App) Shutdown() error {
-	log.Println("Shutting down server...")
+	a.logger.Info("shutting down server")
+
+	// Tell background jobs to stop; they are waited for below
+	a.cancelJobs()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// End the session streams, which would otherwise hold Shutdown open
+	// until the timeout
+	a.events.Close()
+
+	// Stop accepting connections and let in-flight requests finish while
+	// the database is still open
+	if a.redirectServer != nil {
+		if err := a.redirectServer.Shutdown(ctx); err != nil {
+			a.logger.Warn("redirect server forced to shutdown", "error", err)
+		}
+	}
+	shutdownErr := a.server.Shutdown(ctx)
+
+	// Wait for a running scheduled export; a running backup is interrupted
+	if a.autoExporter != nil {
+		a.autoExporter.Stop()
+	}
+	if a.autoBackup != nil {
+		a.autoBackup.Stop()
+	}
+	if a.maintenance != nil {
+		a.maintenance.Stop()
+	}
+	if a.retention != nil {
+		a.retention.Stop()
+	}
+	if a.reminders != nil {
+		a.reminders.Stop()
+	}
 
 	// Stop rate limiter cleanup goroutine
 	a.rateLimiter.Stop()
 
+	// Write pending API key last-use times
+	a.apiKeys.Stop()
+
 	// Close database
 	a.db.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := a.server.Shutdown(ctx); err != nil {
-		return fmt.Errorf("server forced to shutdown: %w", err)
+	// Send the remaining spans
+	if a.traceExporter != nil {
+		tracing.SetExporter(nil)
+		a.traceExporter.Shutdown()
 	}
 
-	log.Println("Server exited properly")
+	if shutdownErr != nil {
+		return fmt.Errorf("server forced to shutdown: %w", shutdownErr)
+	}
+	a.logger.Info("server exited properly")
 	return nil
 }
@@ -1,5 +1,462 @@
 package app
 
-import "testing"
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/middleware"
+	"time-tracker/internal/shared/tracing"
+)
 
 func TestApp_Compile(t *testing.T) {}
+
+// newTestApp builds an App with Basic Auth enabled.
+func newTestApp(t *testing.T, apiKey string) *App {
+	t.Helper()
+	return newTestAppWith(t, apiKey, nil)
+}
+
+// newTestAppWith is like newTestApp, with changes to the configuration
+// made by configure.
+func newTestAppWith(t *testing.T, apiKey string, configure func(*Config)) *App {
+	t.Helper()
+
+	cfg := &Config{
+		APIKey:    apiKey,
+		APIKeys:   []string{apiKey},
+		DBPath:    filepath.Join(t.TempDir(), "app_test.db"),
+		Timezone:  "UTC",
+		BasicUser: "admin",
+		BasicPass: "secret123",
+		RateLimit: 100,
+		Port:      "0",
+
+		RateLimitExemptPaths: middleware.DefaultRateLimitExemptPaths,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	a, err := New(cfg, middleware.NewLogger(io.Discard, "text", slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+	t.Cleanup(func() {
+		a.cancelJobs()
+		a.apiKeys.Stop()
+		a.rateLimiter.Stop()
+		a.db.Close()
+	})
+	return a
+}
+
+func TestApp_RepairsRunningSessions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "app_test.db")
+	db, err := database.New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	for _, stmt := range []string{
+		"DROP INDEX idx_sessions_single_running_per_user",
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'first', '2024-01-01T09:00:00Z', 'running')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'second', '2024-01-01T10:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	cfg := &Config{
+		APIKey:       testAPIKey,
+		APIKeys:      []string{testAPIKey},
+		DBPath:       dbPath,
+		Timezone:     "UTC",
+		RateLimit:    100,
+		Port:         "0",
+		RepairRefuse: true,
+	}
+	if _, err := New(cfg, middleware.NewLogger(io.Discard, "text", slog.LevelInfo)); err == nil || !strings.Contains(err.Error(), "1 extra running sessions") {
+		t.Fatalf("New error = %v, want a refusal", err)
+	}
+
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) {
+		cfg.DBPath = dbPath
+	})
+	var running []string
+	rows, err := a.db.Query("SELECT task FROM sessions WHERE status = 'running'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var task string
+		if err := rows.Scan(&task); err != nil {
+			t.Fatal(err)
+		}
+		running = append(running, task)
+	}
+	if len(running) != 1 || running[0] != "second" {
+		t.Errorf("running sessions = %v, want only second", running)
+	}
+}
+
+func TestApp_WebPagesDoNotExposeAPIKey(t *testing.T) {
+	apiKey := "secret-api-key-that-must-not-leak-0123456789"
+	a := newTestApp(t, apiKey)
+
+	// A running session renders the timer and edit controls
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(`{"category":"work","task":"review"}`))
+	req.Header.Set("X-API-Key", apiKey)
+	rr := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated && rr.Code != http.StatusOK {
+		t.Fatalf("failed to start session: %d %s", rr.Code, rr.Body.String())
+	}
+
+	for _, path := range []string{"/web/sessions", "/web/tags", "/web/login"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetBasicAuth("admin", "secret123")
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), apiKey) {
+			t.Errorf("%s: rendered HTML contains the API key", path)
+		}
+	}
+}
+
+func TestApp_ManagedAPIKeys(t *testing.T) {
+	envKey := "bootstrap-api-key-32-chars-minimum!!"
+	a := newTestApp(t, envKey)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/v1/admin/keys", envKey, `{"name":"phone","scope":"read"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("failed to create key: %d %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		ID  int64  `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr := do(http.MethodGet, "/api/v1/sessions", created.Key, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected read key to list sessions, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/v1/sessions/start", created.Key, `{"category":"work","task":"x"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("expected read key to be refused writes, got %d", rr.Code)
+	}
+
+	if rr := do(http.MethodDelete, "/api/v1/admin/keys/1", envKey, ""); rr.Code != http.StatusOK {
+		t.Fatalf("failed to revoke key: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/api/v1/sessions", created.Key, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked key to be rejected, got %d", rr.Code)
+	}
+
+	// The configured key keeps working whatever is stored
+	if rr := do(http.MethodGet, "/api/v1/sessions", envKey, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected configured key to keep working, got %d", rr.Code)
+	}
+}
+
+func TestApp_Tracing(t *testing.T) {
+	apiKey := "tracing-api-key-32-chars-minimum!!!"
+	a := newTestApp(t, apiKey)
+
+	rec := &tracing.Recorder{}
+	tracing.SetExporter(rec)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	spans := map[string]*tracing.Span{}
+	for _, s := range rec.Spans() {
+		spans[s.Name] = s
+	}
+	server := spans["GET /api/v1/sessions"]
+	if server == nil {
+		t.Fatalf("expected a server span, got %v", spans)
+	}
+	if server.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("server span did not continue the incoming trace")
+	}
+	for _, name := range []string{"sqlite list_sessions"} {
+		s := spans[name]
+		if s == nil {
+			t.Errorf("expected span %q", name)
+			continue
+		}
+		if s.Parent != server.Context.SpanID || s.Context.TraceID != server.Context.TraceID {
+			t.Errorf("span %q is not a child of the server span", name)
+		}
+	}
+}
+
+func TestApp_VersionEndpoint(t *testing.T) {
+	a := newTestApp(t, "version-api-key-32-chars-minimum!!!!")
+
+	// No credentials needed, and every response identifies the build
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	rr := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-TimeTracker-Version") != "dev" {
+		t.Errorf("expected version header, got %q", rr.Header().Get("X-TimeTracker-Version"))
+	}
+	if rr.Header().Get("X-RateLimit-Limit") != "" {
+		t.Error("expected /version to be exempt from rate limiting")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	rr = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("X-TimeTracker-Version") != "dev" {
+		t.Errorf("expected version header on error responses, got %d %q", rr.Code, rr.Header().Get("X-TimeTracker-Version"))
+	}
+}
+
+func TestApp_ShutdownDrainsInFlightRequests(t *testing.T) {
+	apiKey := "shutdown-test-key-0123456789abcdef"
+	a := newTestApp(t, apiKey)
+
+	// Hold the request inside the handler until Shutdown has started
+	started := make(chan struct{})
+	release := make(chan struct{})
+	next := a.server.Handler
+	a.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		next.ServeHTTP(w, r)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- a.Serve(ln) }()
+
+	type result struct {
+		status int
+		err    error
+	}
+	resp := make(chan result, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/api/v1/sessions", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			resp <- result{err: err}
+			return
+		}
+		res.Body.Close()
+		resp <- result{status: res.StatusCode}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached the handler")
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- a.Shutdown() }()
+
+	// Shutdown must wait for the in-flight request
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	r := <-resp
+	if r.err != nil {
+		t.Fatalf("request failed: %v", r.err)
+	}
+	if r.status != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", r.status, http.StatusOK)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+// setReloadEnv makes the environment match the configuration of a, so a
+// reload only sees the changes a test makes. The environment cannot hold
+// port 0, so a must be configured with another.
+func setReloadEnv(t *testing.T, a *App) {
+	t.Helper()
+	for key, value := range map[string]string{
+		"TIMELOG_API_KEY":    a.cfg.APIKey,
+		"TIMELOG_DB_PATH":    a.cfg.DBPath,
+		"TIMELOG_TZ":         a.cfg.Timezone,
+		"TIMELOG_BASIC_USER": a.cfg.BasicUser,
+		"TIMELOG_BASIC_PASS": a.cfg.BasicPass,
+		"TIMELOG_RATE_LIMIT": "100",
+		"TIMELOG_PORT":       a.cfg.Port,
+		"TIMELOG_LOG_FORMAT": a.cfg.LogFormat,
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func TestApp_ReloadAppliesSettings(t *testing.T) {
+	exportDir := t.TempDir()
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) {
+		cfg.LogFormat = "text"
+		cfg.Port = "8080"
+		cfg.AutoExportDir = exportDir
+		cfg.AutoExportInterval = time.Hour
+	})
+	level := new(slog.LevelVar)
+	a.SetLogLevel(level)
+
+	setReloadEnv(t, a)
+	t.Setenv("TIMELOG_RATE_LIMIT", "2")
+	t.Setenv("TIMELOG_LOG_LEVEL", "debug")
+	t.Setenv("TIMELOG_AUTO_EXPORT_DIR", exportDir)
+	t.Setenv("TIMELOG_AUTO_EXPORT_INTERVAL", "2h")
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+		req.Header.Set("X-API-Key", testAPIKey)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+		want := http.StatusOK
+		if i == 3 {
+			want = http.StatusTooManyRequests
+		}
+		if rr.Code != want {
+			t.Errorf("request %d: status %d, want %d", i, rr.Code, want)
+		}
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("log level = %v, want debug", level.Level())
+	}
+	if a.cfg.AutoExportInterval != 2*time.Hour {
+		t.Errorf("auto export interval = %v, want 2h", a.cfg.AutoExportInterval)
+	}
+}
+
+func TestApp_ReloadRejectsRestartSettings(t *testing.T) {
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) {
+		cfg.LogFormat = "text"
+		cfg.Port = "8080"
+	})
+	var logs strings.Builder
+	a.logger = middleware.NewLogger(&logs, "text", slog.LevelInfo)
+
+	setReloadEnv(t, a)
+	t.Setenv("TIMELOG_TZ", "Asia/Tokyo")
+	t.Setenv("TIMELOG_PORT", "9999")
+	t.Setenv("TIMELOG_DB_PATH", filepath.Join(t.TempDir(), "other.db"))
+	dbPath := a.cfg.DBPath
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if a.cfg.Timezone != "UTC" || a.cfg.Port != "8080" || a.cfg.DBPath != dbPath {
+		t.Errorf("restart settings changed to tz %s, port %s, db %s", a.cfg.Timezone, a.cfg.Port, a.cfg.DBPath)
+	}
+	out := logs.String()
+	for _, setting := range []string{"TIMELOG_TZ", "TIMELOG_LISTEN", "TIMELOG_DB_PATH"} {
+		if !strings.Contains(out, setting) {
+			t.Errorf("no restart warning for %s in %q", setting, out)
+		}
+	}
+	if !strings.Contains(out, "level=WARN") {
+		t.Errorf("restart warning not logged at warn level: %q", out)
+	}
+}
+
+func TestApp_AdminConfigRedactsSecrets(t *testing.T) {
+	const adminKey = "admin-key-that-must-not-leak-0123456789"
+	const encryptionKey = "encryption-key-that-must-not-leak"
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) {
+		cfg.AdminKey = adminKey
+		cfg.Timezone = "Asia/Shanghai"
+	})
+	// Changed after the database is opened, so only the report sees them
+	a.cfg.DBEncryptionKey = encryptionKey
+	a.cfg.DBPath = "relative.db"
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-Admin-Key", adminKey)
+	rr := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	body := rr.Body.String()
+	for _, secret := range []string{testAPIKey, adminKey, encryptionKey, a.cfg.BasicPass} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response contains the secret %q: %s", secret, body)
+		}
+	}
+
+	var report ConfigReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.APIKeyPrefixes) != 1 || report.APIKeyPrefixes[0] != testAPIKey[:4]+"..." {
+		t.Errorf("api_keys = %v, want the key's prefix", report.APIKeyPrefixes)
+	}
+	if report.BasicPass != "set" || report.AdminKey != "set" || report.DBEncryptionKey != "set" || report.BasicPassHash != "unset" {
+		t.Errorf("secrets reported as %q, %q, %q, %q", report.BasicPass, report.AdminKey, report.DBEncryptionKey, report.BasicPassHash)
+	}
+	if report.TimezoneOffset != "+08:00" {
+		t.Errorf("tz_offset = %q, want +08:00", report.TimezoneOffset)
+	}
+	if !filepath.IsAbs(report.DBAbsPath) || filepath.Base(report.DBAbsPath) != "relative.db" {
+		t.Errorf("db_abs_path = %q, want relative.db made absolute", report.DBAbsPath)
+	}
+
+	// The admin key is still required
+	req.Header.Del("X-Admin-Key")
+	rr = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code == http.StatusOK {
+		t.Error("config served without the admin key")
+	}
+}
@@ -2,39 +2,347 @@ package app
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/middleware"
+	"time-tracker/internal/shared/tracing"
+	"time-tracker/internal/tags"
 )
 
-// Config holds the application configuration loaded from environment variables.
+// Config holds the application configuration loaded from environment
+// variables and the optional config file.
 type Config struct {
+	// ConfigFile is the TOML file settings were read from, if any, and
+	// ConfigFlags the settings given on the command line, by config file key
+	ConfigFile  string
+	ConfigFlags map[string]string
+
 	APIKey    string
+	APIKeys   []string // TIMELOG_API_KEY followed by TIMELOG_API_KEYS
 	DBPath    string
 	Timezone  string
 	BasicUser string
 	BasicPass string
 	RateLimit int
 	Port      string
+
+	// Listen is the address to listen on: "host:port", ":port" or
+	// "unix:/path/to/socket". For a TCP address, Port is its port.
+	Listen string
+
+	// DefaultCategory and DefaultTask are given to sessions started without
+	// one; MaxPageSize is the largest page the session list API returns
+	DefaultCategory string
+	DefaultTask     string
+	MaxPageSize     int
+
+	// DBBusyTimeout is how long a write waits for a lock held by another
+	// connection; 0 uses database.DefaultBusyTimeout
+	DBBusyTimeout time.Duration
+
+	// DBFullIntegrityCheck runs the full integrity_check at startup and in
+	// readiness checks instead of quick_check
+	DBFullIntegrityCheck bool
+
+	// DBEncryptionKey opens the database with SQLCipher; empty leaves it
+	// unencrypted
+	DBEncryptionKey string
+
+	// TemplatesDir loads the web templates and static assets from a
+	// directory instead of the ones embedded in the binary
+	TemplatesDir string
+
+	// RateLimitExemptPaths are not rate limited; entries ending in "/" are prefixes
+	RateLimitExemptPaths []string
+
+	// BasicPassHash is a bcrypt hash of the web password, used instead of BasicPass
+	BasicPassHash string
+
+	BulkAssignMax int
+	SeedTags      []tags.TagCreate
+
+	// MaxBodyBytes limits JSON request bodies
+	MaxBodyBytes int64
+
+	// AdminKey, if set, is required in X-Admin-Key on /api/v1/admin/ routes
+	AdminKey string
+
+	// TLS is enabled when both TLSCert and TLSKey are set. HTTPRedirectPort,
+	// if set, serves plain HTTP redirects to https.
+	TLSCert          string
+	TLSKey           string
+	HTTPRedirectPort string
+
+	// LogFormat is the log format, "json" or "text", and LogLevel the
+	// least severe level logged
+	LogFormat string
+	LogLevel  slog.Level
+
+	// Tracing is read from the standard OTEL_* variables; disabled without an endpoint
+	Tracing tracing.Config
+
+	// Security extends the default security headers (extra CSP sources, HSTS)
+	Security middleware.SecurityOptions
+
+	// TrustedProxies are the reverse proxies whose forwarding headers are honored
+	TrustedProxies []netip.Prefix
+
+	// APIAllowCIDRs restricts /api/* to these client networks; empty means no restriction
+	APIAllowCIDRs []netip.Prefix
+
+	// CORSOrigins are the origins allowed to call /api/* from a browser; empty disables CORS
+	CORSOrigins []string
+
+	// Scheduled CSV export, disabled when AutoExportDir is empty
+	AutoExportDir      string
+	AutoExportInterval time.Duration
+	AutoExportRetain   int
+
+	// StreamHeartbeat is how often the session event streams send the elapsed time
+	StreamHeartbeat time.Duration
+
+	// WAL checkpoint interval, and incremental vacuum interval (0 disables vacuum)
+	MaintenanceInterval time.Duration
+	VacuumInterval      time.Duration
+
+	// Scheduled database backup, disabled when BackupDir is empty
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// Stopped sessions older than RetentionDays are purged daily, or only
+	// stripped of their text when RetentionAnonymize is set; 0 keeps everything
+	RetentionDays      int
+	RetentionAnonymize bool
+
+	// How often the running session is checked against the reminder rules
+	ReminderInterval time.Duration
+
+	// RepairRefuse refuses to start when a user has several running
+	// sessions instead of stopping all but the most recent one
+	RepairRefuse bool
 }
 
-// LoadConfig loads configuration from environment variables.
+// LoadConfig loads configuration from environment variables and the
+// config file named by TIMELOG_CONFIG, if set.
 // Returns an error if required configuration is missing or invalid.
 func LoadConfig() (*Config, error) {
+	return LoadConfigFile(os.Getenv("TIMELOG_CONFIG"))
+}
+
+// LoadConfigFile loads configuration from the TOML config file at path and
+// environment variables, which override the file setting by setting. Each
+// key of the file is a variable name without the TIMELOG_ prefix, in lower
+// case; keys in a table take the table name as a prefix. An empty path
+// loads configuration from the environment only.
+func LoadConfigFile(path string) (*Config, error) {
+	return LoadConfigFlags(path, nil)
+}
+
+// ConfigError lists every invalid setting found while loading the
+// configuration.
+type ConfigError struct {
+	Errs []error
+}
+
+func (e *ConfigError) Error() string {
+	if len(e.Errs) == 1 {
+		return e.Errs[0].Error()
+	}
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors:\n  %s", len(e.Errs), strings.Join(msgs, "\n  "))
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As.
+func (e *ConfigError) Unwrap() []error {
+	return e.Errs
+}
+
+// LoadConfigFlags is like LoadConfigFile, with flags overriding both the
+// environment and the file. flags are keyed like the config file, e.g.
+// "db_path" or "db-path".
+func LoadConfigFlags(path string, flags map[string]string) (*Config, error) {
+	src, err := newConfigSource(path)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range flags {
+		src.flags[configEnvName(key)] = value
+		src.flagKeys[configEnvName(key)] = key
+	}
+	cfg, errs := loadConfig(src)
+	errs = append(errs, src.checkUnused()...)
+	if len(errs) > 0 {
+		return nil, &ConfigError{Errs: errs}
+	}
+	cfg.ConfigFile = path
+	cfg.ConfigFlags = flags
+	return cfg, nil
+}
+
+// loadConfig reads the configuration from src. Rather than stopping at the
+// first invalid setting it returns an error for each, so they can all be
+// fixed in one pass.
+func loadConfig(src *configSource) (*Config, []error) {
 	cfg := &Config{
-		APIKey:    os.Getenv("TIMELOG_API_KEY"),
-		DBPath:    os.Getenv("TIMELOG_DB_PATH"),
-		Timezone:  os.Getenv("TIMELOG_TZ"),
-		BasicUser: os.Getenv("TIMELOG_BASIC_USER"),
-		BasicPass: os.Getenv("TIMELOG_BASIC_PASS"),
-		Port:      os.Getenv("TIMELOG_PORT"),
+		DBPath:    src.get("TIMELOG_DB_PATH"),
+		Timezone:  src.get("TIMELOG_TZ"),
+		BasicUser: src.get("TIMELOG_BASIC_USER"),
+		Port:      src.get("TIMELOG_PORT"),
+
+		AutoExportDir: src.get("TIMELOG_AUTO_EXPORT_DIR"),
+		BackupDir:     src.get("TIMELOG_BACKUP_DIR"),
+		TemplatesDir:  src.get("TIMELOG_TEMPLATES_DIR"),
+		LogFormat:     src.get("TIMELOG_LOG_FORMAT"),
+
+		TLSCert:          src.get("TIMELOG_TLS_CERT"),
+		TLSKey:           src.get("TIMELOG_TLS_KEY"),
+		HTTPRedirectPort: src.get("TIMELOG_HTTP_REDIRECT_PORT"),
+	}
+
+	var errs []error
+
+	// Secrets may also be read from files, e.g. Docker or Kubernetes secret mounts
+	var err error
+	if cfg.APIKey, err = src.secret("TIMELOG_API_KEY"); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.BasicPass, err = src.secret("TIMELOG_BASIC_PASS"); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.BasicPassHash, err = src.secret("TIMELOG_BASIC_PASS_HASH"); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.AdminKey, err = src.secret("TIMELOG_ADMIN_KEY"); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.DBEncryptionKey, err = src.secret("TIMELOG_DB_ENCRYPTION_KEY"); err != nil {
+		errs = append(errs, err)
+	}
+	apiKeysSpec, err := src.secret("TIMELOG_API_KEYS")
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Validate API keys (at least one required, each minimum 32 characters)
+	if cfg.APIKey != "" {
+		if len(cfg.APIKey) < 32 {
+			errs = append(errs, fmt.Errorf("TIMELOG_API_KEY must be at least 32 characters long"))
+		}
+		cfg.APIKeys = append(cfg.APIKeys, cfg.APIKey)
+	}
+	if apiKeysSpec != "" {
+		keys, err := parseAPIKeys(apiKeysSpec)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, key := range keys {
+			if !slices.Contains(cfg.APIKeys, key) {
+				cfg.APIKeys = append(cfg.APIKeys, key)
+			}
+		}
+	}
+	if len(cfg.APIKeys) == 0 && apiKeysSpec == "" {
+		errs = append(errs, fmt.Errorf("TIMELOG_API_KEY or TIMELOG_API_KEYS is required"))
+	}
+	if cfg.APIKey == "" && len(cfg.APIKeys) > 0 {
+		cfg.APIKey = cfg.APIKeys[0]
+	}
+
+	// Validate the web password hash; a malformed hash would lock everyone out
+	if cfg.BasicPassHash != "" {
+		if cfg.BasicPass != "" {
+			errs = append(errs, fmt.Errorf("set only one of TIMELOG_BASIC_PASS and TIMELOG_BASIC_PASS_HASH"))
+		}
+		if _, err := bcrypt.Cost([]byte(cfg.BasicPassHash)); err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_BASIC_PASS_HASH is not a valid bcrypt hash: %w", err))
+		}
+	}
+
+	// Validate TLS settings; certificate files are loaded by App.New
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		errs = append(errs, fmt.Errorf("TIMELOG_TLS_CERT and TIMELOG_TLS_KEY must be set together"))
+	}
+	if cfg.HTTPRedirectPort != "" && !cfg.TLSEnabled() {
+		errs = append(errs, fmt.Errorf("TIMELOG_HTTP_REDIRECT_PORT requires TIMELOG_TLS_CERT and TIMELOG_TLS_KEY"))
+	}
+
+	// Parse security header options
+	if spec := src.get("TIMELOG_CSP_SCRIPT_SRC"); spec != "" {
+		sources, err := middleware.ParseCSPSources(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_CSP_SCRIPT_SRC: %w", err))
+		}
+		cfg.Security.ScriptSrc = sources
+	}
+	if spec := src.get("TIMELOG_CSP_STYLE_SRC"); spec != "" {
+		sources, err := middleware.ParseCSPSources(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_CSP_STYLE_SRC: %w", err))
+		}
+		cfg.Security.StyleSrc = sources
+	}
+	if maxAgeStr := src.get("TIMELOG_HSTS_MAX_AGE"); maxAgeStr != "" {
+		maxAge, err := strconv.Atoi(maxAgeStr)
+		if err != nil || maxAge < 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_HSTS_MAX_AGE must be a non-negative number of seconds"))
+		}
+		// HSTS is only meaningful when this server terminates TLS
+		if maxAge > 0 && !cfg.TLSEnabled() {
+			errs = append(errs, fmt.Errorf("TIMELOG_HSTS_MAX_AGE requires TIMELOG_TLS_CERT and TIMELOG_TLS_KEY"))
+		}
+		cfg.Security.HSTSMaxAge = maxAge
 	}
 
-	// Validate API key (required, minimum 32 characters)
-	if cfg.APIKey == "" {
-		return nil, fmt.Errorf("TIMELOG_API_KEY is required")
+	// Tracing export
+	tracingCfg, err := tracing.ConfigFromEnv()
+	if err != nil {
+		errs = append(errs, err)
 	}
-	if len(cfg.APIKey) < 32 {
-		return nil, fmt.Errorf("TIMELOG_API_KEY must be at least 32 characters long")
+	cfg.Tracing = tracingCfg
+
+	// Parse trusted reverse proxies
+	if spec := src.get("TIMELOG_TRUSTED_PROXIES"); spec != "" {
+		prefixes, err := middleware.ParseCIDRs(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_TRUSTED_PROXIES: %w", err))
+		}
+		cfg.TrustedProxies = prefixes
+	}
+
+	// Parse API client allowlist
+	if spec := src.get("TIMELOG_API_ALLOW_CIDRS"); spec != "" {
+		prefixes, err := middleware.ParseCIDRs(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_API_ALLOW_CIDRS: %w", err))
+		}
+		cfg.APIAllowCIDRs = prefixes
+	}
+
+	// Parse CORS origins
+	if spec := src.get("TIMELOG_CORS_ORIGINS"); spec != "" {
+		origins, err := middleware.ParseCORSOrigins(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_CORS_ORIGINS: %w", err))
+		}
+		cfg.CORSOrigins = origins
 	}
 
 	// Set defaults
@@ -44,21 +352,343 @@ func LoadConfig() (*Config, error) {
 	if cfg.Timezone == "" {
 		cfg.Timezone = "UTC"
 	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("TIMELOG_TZ %q is not a valid time zone, such as UTC or Asia/Shanghai", cfg.Timezone))
+	}
 	if cfg.Port == "" {
 		cfg.Port = "7070"
 	}
+	// TIMELOG_LISTEN takes precedence; TIMELOG_PORT listens on all interfaces
+	if cfg.Listen = src.get("TIMELOG_LISTEN"); cfg.Listen == "" {
+		if !validPort(cfg.Port) {
+			errs = append(errs, fmt.Errorf("TIMELOG_PORT must be a port number from 1 to 65535"))
+		}
+		cfg.Listen = ":" + cfg.Port
+	} else if network, address, err := parseListenAddr(cfg.Listen); err != nil {
+		errs = append(errs, err)
+	} else if network == "tcp" {
+		_, cfg.Port, _ = net.SplitHostPort(address)
+	} else if cfg.HTTPRedirectPort != "" {
+		errs = append(errs, fmt.Errorf("TIMELOG_HTTP_REDIRECT_PORT requires a TCP address in TIMELOG_LISTEN"))
+	}
+	if cfg.HTTPRedirectPort != "" && !validPort(cfg.HTTPRedirectPort) {
+		errs = append(errs, fmt.Errorf("TIMELOG_HTTP_REDIRECT_PORT must be a port number from 1 to 65535"))
+	}
+	switch cfg.LogFormat {
+	case "":
+		cfg.LogFormat = "json"
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("TIMELOG_LOG_FORMAT must be json or text"))
+	}
+	switch src.get("TIMELOG_LOG_LEVEL") {
+	case "debug":
+		cfg.LogLevel = slog.LevelDebug
+	case "", "info":
+		cfg.LogLevel = slog.LevelInfo
+	case "warn":
+		cfg.LogLevel = slog.LevelWarn
+	case "error":
+		cfg.LogLevel = slog.LevelError
+	default:
+		errs = append(errs, fmt.Errorf("TIMELOG_LOG_LEVEL must be debug, info, warn or error"))
+	}
 
 	// Parse rate limit
-	rateLimitStr := os.Getenv("TIMELOG_RATE_LIMIT")
+	rateLimitStr := src.get("TIMELOG_RATE_LIMIT")
 	if rateLimitStr == "" {
 		cfg.RateLimit = 100
 	} else {
 		rateLimit, err := strconv.Atoi(rateLimitStr)
 		if err != nil || rateLimit <= 0 {
-			return nil, fmt.Errorf("TIMELOG_RATE_LIMIT must be a positive integer")
+			errs = append(errs, fmt.Errorf("TIMELOG_RATE_LIMIT must be a positive integer"))
 		}
 		cfg.RateLimit = rateLimit
 	}
 
-	return cfg, nil
+	// Parse rate limit exemptions
+	cfg.RateLimitExemptPaths = middleware.DefaultRateLimitExemptPaths
+	if spec := src.get("TIMELOG_RATE_LIMIT_EXEMPT"); spec != "" {
+		paths, err := middleware.ParseExemptPaths(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_RATE_LIMIT_EXEMPT: %w", err))
+		}
+		cfg.RateLimitExemptPaths = paths
+	}
+
+	// Parse bulk tag assignment cap
+	bulkAssignMaxStr := src.get("TIMELOG_BULK_ASSIGN_MAX")
+	if bulkAssignMaxStr == "" {
+		cfg.BulkAssignMax = config.MaxBulkAssign
+	} else {
+		bulkAssignMax, err := strconv.Atoi(bulkAssignMaxStr)
+		if err != nil || bulkAssignMax <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_BULK_ASSIGN_MAX must be a positive integer"))
+		}
+		cfg.BulkAssignMax = bulkAssignMax
+	}
+
+	// Session defaults and paging
+	cfg.DefaultCategory = config.DefaultCategory
+	if category := src.get("TIMELOG_DEFAULT_CATEGORY"); category != "" {
+		category = strings.TrimSpace(category)
+		if category == "" || utf8.RuneCountInString(category) > models.CategoryMaxLen {
+			errs = append(errs, fmt.Errorf("TIMELOG_DEFAULT_CATEGORY must be 1 to %d characters, not only spaces", models.CategoryMaxLen))
+		}
+		cfg.DefaultCategory = category
+	}
+	cfg.DefaultTask = config.DefaultTask
+	if task := src.get("TIMELOG_DEFAULT_TASK"); task != "" {
+		task = strings.TrimSpace(task)
+		if task == "" || utf8.RuneCountInString(task) > models.TaskMaxLen {
+			errs = append(errs, fmt.Errorf("TIMELOG_DEFAULT_TASK must be 1 to %d characters, not only spaces", models.TaskMaxLen))
+		}
+		cfg.DefaultTask = task
+	}
+	maxPageSizeStr := src.get("TIMELOG_MAX_PAGE_SIZE")
+	if maxPageSizeStr == "" {
+		cfg.MaxPageSize = config.MaxPageSize
+	} else {
+		maxPageSize, err := strconv.Atoi(maxPageSizeStr)
+		if err != nil || maxPageSize < config.DefaultPageSize || maxPageSize > config.MaxPageSizeLimit {
+			errs = append(errs, fmt.Errorf("TIMELOG_MAX_PAGE_SIZE must be an integer from %d to %d", config.DefaultPageSize, config.MaxPageSizeLimit))
+		}
+		cfg.MaxPageSize = maxPageSize
+	}
+
+	// Parse JSON body size limit
+	maxBodyBytesStr := src.get("TIMELOG_MAX_BODY_BYTES")
+	if maxBodyBytesStr == "" {
+		cfg.MaxBodyBytes = config.MaxJSONBodyBytes
+	} else {
+		maxBodyBytes, err := strconv.ParseInt(maxBodyBytesStr, 10, 64)
+		if err != nil || maxBodyBytes <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_MAX_BODY_BYTES must be a positive integer"))
+		}
+		cfg.MaxBodyBytes = maxBodyBytes
+	}
+
+	// Parse tags to seed on startup
+	if spec := src.get("TIMELOG_SEED_TAGS"); spec != "" {
+		seeds, err := tags.ParseSeedTags(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TIMELOG_SEED_TAGS: %w", err))
+		}
+		cfg.SeedTags = seeds
+	}
+
+	// Parse scheduled export settings
+	intervalStr := src.get("TIMELOG_AUTO_EXPORT_INTERVAL")
+	if intervalStr == "" {
+		cfg.AutoExportInterval = config.DefaultAutoExportInterval
+	} else {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_AUTO_EXPORT_INTERVAL must be a positive duration such as 24h"))
+		}
+		cfg.AutoExportInterval = interval
+	}
+
+	retainStr := src.get("TIMELOG_AUTO_EXPORT_RETAIN")
+	if retainStr == "" {
+		cfg.AutoExportRetain = config.DefaultAutoExportRetain
+	} else {
+		retain, err := strconv.Atoi(retainStr)
+		if err != nil || retain <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_AUTO_EXPORT_RETAIN must be a positive integer"))
+		}
+		cfg.AutoExportRetain = retain
+	}
+
+	if busyStr := src.get("TIMELOG_DB_BUSY_TIMEOUT"); busyStr != "" {
+		timeout, err := time.ParseDuration(busyStr)
+		if err != nil || timeout <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_DB_BUSY_TIMEOUT must be a positive duration such as 5s"))
+		}
+		cfg.DBBusyTimeout = timeout
+	}
+
+	switch src.get("TIMELOG_DB_INTEGRITY_CHECK") {
+	case "", "quick":
+	case "full":
+		cfg.DBFullIntegrityCheck = true
+	default:
+		errs = append(errs, fmt.Errorf("TIMELOG_DB_INTEGRITY_CHECK must be quick or full"))
+	}
+
+	streamStr := src.get("TIMELOG_STREAM_HEARTBEAT")
+	if streamStr == "" {
+		cfg.StreamHeartbeat = config.DefaultStreamHeartbeat
+	} else {
+		interval, err := time.ParseDuration(streamStr)
+		if err != nil || interval <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_STREAM_HEARTBEAT must be a positive duration such as 15s"))
+		}
+		cfg.StreamHeartbeat = interval
+	}
+
+	// Parse database maintenance settings
+	maintenanceStr := src.get("TIMELOG_MAINTENANCE_INTERVAL")
+	if maintenanceStr == "" {
+		cfg.MaintenanceInterval = config.DefaultMaintenanceInterval
+	} else {
+		interval, err := time.ParseDuration(maintenanceStr)
+		if err != nil || interval <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_MAINTENANCE_INTERVAL must be a positive duration such as 24h"))
+		}
+		cfg.MaintenanceInterval = interval
+	}
+
+	if vacuumStr := src.get("TIMELOG_VACUUM_INTERVAL"); vacuumStr != "" {
+		interval, err := time.ParseDuration(vacuumStr)
+		if err != nil || interval < 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_VACUUM_INTERVAL must be a duration such as 720h, or 0 to disable"))
+		}
+		cfg.VacuumInterval = interval
+	}
+
+	// Parse the reminder check interval
+	reminderStr := src.get("TIMELOG_REMINDER_INTERVAL")
+	if reminderStr == "" {
+		cfg.ReminderInterval = config.DefaultReminderInterval
+	} else {
+		interval, err := time.ParseDuration(reminderStr)
+		if err != nil || interval <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_REMINDER_INTERVAL must be a positive duration such as 1m"))
+		}
+		cfg.ReminderInterval = interval
+	}
+
+	// Parse scheduled backup settings
+	backupIntervalStr := src.get("TIMELOG_BACKUP_INTERVAL")
+	if backupIntervalStr == "" {
+		cfg.BackupInterval = config.DefaultBackupInterval
+	} else {
+		interval, err := time.ParseDuration(backupIntervalStr)
+		if err != nil || interval <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_BACKUP_INTERVAL must be a positive duration such as 24h"))
+		}
+		cfg.BackupInterval = interval
+	}
+
+	keepStr := src.get("TIMELOG_BACKUP_KEEP")
+	if keepStr == "" {
+		cfg.BackupKeep = config.DefaultBackupKeep
+	} else {
+		keep, err := strconv.Atoi(keepStr)
+		if err != nil || keep <= 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_BACKUP_KEEP must be a positive integer"))
+		}
+		cfg.BackupKeep = keep
+	}
+
+	// Parse data retention settings
+	if daysStr := src.get("TIMELOG_RETENTION_DAYS"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			errs = append(errs, fmt.Errorf("TIMELOG_RETENTION_DAYS must be a positive number of days, or 0 to keep everything"))
+		}
+		cfg.RetentionDays = days
+	}
+
+	switch mode := src.get("TIMELOG_RETENTION_MODE"); mode {
+	case "", "delete":
+	case "anonymize":
+		cfg.RetentionAnonymize = true
+	default:
+		errs = append(errs, fmt.Errorf("TIMELOG_RETENTION_MODE must be delete or anonymize"))
+	}
+
+	switch mode := src.get("TIMELOG_REPAIR_MODE"); mode {
+	case "", "stop":
+	case "refuse":
+		cfg.RepairRefuse = true
+	default:
+		errs = append(errs, fmt.Errorf("TIMELOG_REPAIR_MODE must be stop or refuse"))
+	}
+
+	return cfg, errs
+}
+
+// validPort reports whether port is a TCP port number a server can listen on.
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// SecretEnv returns the value of the environment variable name, or the
+// contents of the file named by name_FILE with trailing newlines removed.
+// Setting both is an error, as is an unreadable or empty file. Errors never
+// include the secret itself.
+func SecretEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("set only one of %s and %s_FILE", name, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s_FILE: %s is empty", name, path)
+	}
+	return secret, nil
+}
+
+// OpenDatabase opens and migrates the configured database. Statements are
+// logged to logger at debug level; it may be nil.
+func (c *Config) OpenDatabase(logger *slog.Logger) (*database.DB, error) {
+	return database.Open(c.DBPath, database.Options{
+		BusyTimeout:        c.DBBusyTimeout,
+		FullIntegrityCheck: c.DBFullIntegrityCheck,
+		EncryptionKey:      c.DBEncryptionKey,
+		Logger:             logger,
+	})
+}
+
+// ListenAddr returns the address to listen on, Listen or else ":" + Port.
+func (c *Config) ListenAddr() string {
+	if c.Listen != "" {
+		return c.Listen
+	}
+	return ":" + c.Port
+}
+
+// TLSEnabled reports whether the server should serve HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Credentials returns the secrets checked by the auth middlewares.
+func (c *Config) Credentials() auth.Credentials {
+	return auth.Credentials{
+		APIKeys:   c.APIKeys,
+		BasicUser: c.BasicUser,
+		BasicPass: c.BasicPass,
+		AdminKey:  c.AdminKey,
+
+		BasicPassHash: c.BasicPassHash,
+	}
+}
+
+// parseAPIKeys parses a comma-separated list of API keys.
+func parseAPIKeys(spec string) ([]string, error) {
+	keys := []string{}
+	for i, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if len(key) < 32 {
+			return nil, fmt.Errorf("TIMELOG_API_KEYS: key %d must be at least 32 characters long", i+1)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
 }
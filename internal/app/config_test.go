@@ -0,0 +1,599 @@
+package app
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/shared/config"
+)
+
+const testAPIKey = "test-api-key-32-chars-minimum!!!"
+
+func TestLoadConfig_BasicPassHash(t *testing.T) {
+	const hash = "$2b$04$N9qo8uLOickgx2ZMRZoMyejk78QHndYRSfXgQ03pFoR4FPDPXsrBm"
+
+	t.Run("valid hash", func(t *testing.T) {
+		t.Setenv("TIMELOG_API_KEY", testAPIKey)
+		t.Setenv("TIMELOG_BASIC_USER", "admin")
+		t.Setenv("TIMELOG_BASIC_PASS", "")
+		t.Setenv("TIMELOG_BASIC_PASS_HASH", hash)
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		creds := cfg.Credentials()
+		if !creds.VerifyPassword("admin", "secret123") || creds.VerifyPassword("admin", "wrong") {
+			t.Fatal("expected hash to be used for password checks")
+		}
+	})
+
+	t.Run("both plaintext and hash", func(t *testing.T) {
+		t.Setenv("TIMELOG_API_KEY", testAPIKey)
+		t.Setenv("TIMELOG_BASIC_USER", "admin")
+		t.Setenv("TIMELOG_BASIC_PASS", "secret123")
+		t.Setenv("TIMELOG_BASIC_PASS_HASH", hash)
+
+		if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "only one") {
+			t.Fatalf("expected conflict error, got %v", err)
+		}
+	})
+
+	t.Run("malformed hash fails closed", func(t *testing.T) {
+		for _, bad := range []string{"secret123", hash[:40], "$2b$99$" + hash[7:]} {
+			t.Setenv("TIMELOG_API_KEY", testAPIKey)
+			t.Setenv("TIMELOG_BASIC_USER", "admin")
+			t.Setenv("TIMELOG_BASIC_PASS", "")
+			t.Setenv("TIMELOG_BASIC_PASS_HASH", bad)
+
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("expected %q to be rejected at startup", bad)
+			}
+		}
+	})
+}
+
+func TestLoadConfig_Retention(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RetentionDays != 0 || cfg.RetentionAnonymize {
+		t.Errorf("retention enabled by default: days = %d, anonymize = %v", cfg.RetentionDays, cfg.RetentionAnonymize)
+	}
+
+	t.Setenv("TIMELOG_RETENTION_DAYS", "365")
+	t.Setenv("TIMELOG_RETENTION_MODE", "anonymize")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RetentionDays != 365 || !cfg.RetentionAnonymize {
+		t.Errorf("days = %d, anonymize = %v, want 365 and true", cfg.RetentionDays, cfg.RetentionAnonymize)
+	}
+
+	for _, tt := range []struct{ days, mode string }{
+		{"-1", "delete"},
+		{"a year", "delete"},
+		{"30", "shred"},
+	} {
+		t.Setenv("TIMELOG_RETENTION_DAYS", tt.days)
+		t.Setenv("TIMELOG_RETENTION_MODE", tt.mode)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("days %q, mode %q: expected an error", tt.days, tt.mode)
+		}
+	}
+}
+
+func TestLoadConfig_RepairMode(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RepairRefuse {
+		t.Error("expected extra running sessions to be stopped by default")
+	}
+
+	t.Setenv("TIMELOG_REPAIR_MODE", "refuse")
+	if cfg, err = LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.RepairRefuse {
+		t.Error("expected RepairRefuse to be set")
+	}
+
+	t.Setenv("TIMELOG_REPAIR_MODE", "ignore")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected an error for an unknown repair mode")
+	}
+}
+
+func TestLoadConfig_DBBusyTimeout(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	t.Setenv("TIMELOG_DB_BUSY_TIMEOUT", "250ms")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DBBusyTimeout != 250*time.Millisecond {
+		t.Errorf("DBBusyTimeout = %v, want 250ms", cfg.DBBusyTimeout)
+	}
+
+	for _, value := range []string{"0", "-1s", "soon"} {
+		t.Setenv("TIMELOG_DB_BUSY_TIMEOUT", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestLoadConfig_LogLevel(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	for value, want := range map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		t.Setenv("TIMELOG_LOG_LEVEL", value)
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", value, err)
+		}
+		if cfg.LogLevel != want {
+			t.Errorf("%q: LogLevel = %v, want %v", value, cfg.LogLevel, want)
+		}
+	}
+
+	t.Setenv("TIMELOG_LOG_LEVEL", "verbose")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestLoadConfig_StreamHeartbeat(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StreamHeartbeat != 15*time.Second {
+		t.Errorf("StreamHeartbeat = %v, want 15s", cfg.StreamHeartbeat)
+	}
+
+	t.Setenv("TIMELOG_STREAM_HEARTBEAT", "5s")
+	if cfg, err = LoadConfig(); err != nil || cfg.StreamHeartbeat != 5*time.Second {
+		t.Errorf("StreamHeartbeat = %v, %v, want 5s", cfg.StreamHeartbeat, err)
+	}
+
+	for _, value := range []string{"0", "-1s", "often"} {
+		t.Setenv("TIMELOG_STREAM_HEARTBEAT", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestLoadConfig_DBIntegrityCheck(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	for value, want := range map[string]bool{"": false, "quick": false, "full": true} {
+		t.Setenv("TIMELOG_DB_INTEGRITY_CHECK", value)
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", value, err)
+		}
+		if cfg.DBFullIntegrityCheck != want {
+			t.Errorf("%q: DBFullIntegrityCheck = %v, want %v", value, cfg.DBFullIntegrityCheck, want)
+		}
+	}
+
+	t.Setenv("TIMELOG_DB_INTEGRITY_CHECK", "thorough")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func writeSecretFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_DBEncryptionKey(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_DB_ENCRYPTION_KEY_FILE", writeSecretFile(t, "db secret\n"))
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DBEncryptionKey != "db secret" {
+		t.Errorf("DBEncryptionKey = %q, want the file contents", cfg.DBEncryptionKey)
+	}
+}
+
+func TestLoadConfig_SecretFiles(t *testing.T) {
+	t.Run("file used when plain variable unset", func(t *testing.T) {
+		t.Setenv("TIMELOG_API_KEY", "")
+		t.Setenv("TIMELOG_API_KEY_FILE", writeSecretFile(t, testAPIKey+"\n"))
+		t.Setenv("TIMELOG_BASIC_USER", "admin")
+		t.Setenv("TIMELOG_BASIC_PASS_FILE", writeSecretFile(t, "secret123\r\n"))
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.APIKey != testAPIKey {
+			t.Errorf("expected API key from file with newline trimmed, got %q", cfg.APIKey)
+		}
+		if cfg.BasicPass != "secret123" {
+			t.Errorf("expected Basic password from file with newline trimmed, got %q", cfg.BasicPass)
+		}
+	})
+
+	t.Run("only trailing newlines are trimmed", func(t *testing.T) {
+		t.Setenv("TIMELOG_API_KEY_FILE", writeSecretFile(t, testAPIKey))
+		t.Setenv("TIMELOG_BASIC_USER", "admin")
+		t.Setenv("TIMELOG_BASIC_PASS_FILE", writeSecretFile(t, " pass word \n\n"))
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.BasicPass != " pass word " {
+			t.Errorf("expected surrounding spaces to be kept, got %q", cfg.BasicPass)
+		}
+	})
+
+	const secret = "do-not-echo-this-secret-value-0123456789"
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "both plain and file",
+			env:     map[string]string{"TIMELOG_API_KEY": secret, "TIMELOG_API_KEY_FILE": writeSecretFile(t, secret)},
+			wantErr: "only one of TIMELOG_API_KEY and TIMELOG_API_KEY_FILE",
+		},
+		{
+			name:    "missing file",
+			env:     map[string]string{"TIMELOG_API_KEY_FILE": filepath.Join(t.TempDir(), "missing")},
+			wantErr: "TIMELOG_API_KEY_FILE",
+		},
+		{
+			name:    "empty file",
+			env:     map[string]string{"TIMELOG_API_KEY_FILE": writeSecretFile(t, "\n")},
+			wantErr: "is empty",
+		},
+		{
+			name: "password both plain and file",
+			env: map[string]string{
+				"TIMELOG_API_KEY":         testAPIKey,
+				"TIMELOG_BASIC_PASS":      secret,
+				"TIMELOG_BASIC_PASS_FILE": writeSecretFile(t, secret),
+			},
+			wantErr: "only one of TIMELOG_BASIC_PASS and TIMELOG_BASIC_PASS_FILE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIMELOG_API_KEY", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Fatalf("error message contains the secret: %v", err)
+			}
+		})
+	}
+}
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_Precedence(t *testing.T) {
+	const otherKey = "other-api-key-32-chars-minimum!!!"
+	file := `
+api_key = "` + testAPIKey + `"
+port = "8080"
+tz = "Asia/Shanghai"
+rate_limit = 50
+
+[backup]
+dir = "/var/backups/timelog"
+`
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		flags map[string]string
+		want  Config
+	}{
+		{
+			name: "file only",
+			file: file,
+			want: Config{APIKey: testAPIKey, Port: "8080", Timezone: "Asia/Shanghai", RateLimit: 50, BackupDir: "/var/backups/timelog"},
+		},
+		{
+			name: "env only",
+			env:  map[string]string{"TIMELOG_API_KEY": otherKey, "TIMELOG_PORT": "9090", "TIMELOG_RATE_LIMIT": "20"},
+			want: Config{APIKey: otherKey, Port: "9090", Timezone: "UTC", RateLimit: 20},
+		},
+		{
+			name: "env overrides file setting by setting",
+			file: file,
+			env:  map[string]string{"TIMELOG_PORT": "9090", "TIMELOG_BACKUP_DIR": "/backups"},
+			want: Config{APIKey: testAPIKey, Port: "9090", Timezone: "Asia/Shanghai", RateLimit: 50, BackupDir: "/backups"},
+		},
+		{
+			name: "empty env leaves file setting",
+			file: file,
+			env:  map[string]string{"TIMELOG_TZ": ""},
+			want: Config{APIKey: testAPIKey, Port: "8080", Timezone: "Asia/Shanghai", RateLimit: 50, BackupDir: "/var/backups/timelog"},
+		},
+		{
+			name: "secret file overrides file secret",
+			file: file,
+			env:  map[string]string{"TIMELOG_API_KEY_FILE": writeSecretFile(t, otherKey+"\n")},
+			want: Config{APIKey: otherKey, Port: "8080", Timezone: "Asia/Shanghai", RateLimit: 50, BackupDir: "/var/backups/timelog"},
+		},
+		{
+			name:  "flags override env and file",
+			file:  file,
+			env:   map[string]string{"TIMELOG_PORT": "9090", "TIMELOG_RATE_LIMIT": "20"},
+			flags: map[string]string{"port": "7171", "backup-dir": "/flag/backups"},
+			want:  Config{APIKey: testAPIKey, Port: "7171", Timezone: "Asia/Shanghai", RateLimit: 20, BackupDir: "/flag/backups"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"TIMELOG_API_KEY", "TIMELOG_API_KEY_FILE", "TIMELOG_PORT", "TIMELOG_TZ", "TIMELOG_RATE_LIMIT", "TIMELOG_BACKUP_DIR"} {
+				t.Setenv(name, tt.env[name])
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file)
+			}
+
+			cfg, err := LoadConfigFlags(path, tt.flags)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.ConfigFile != path {
+				t.Errorf("ConfigFile = %q, want %q", cfg.ConfigFile, path)
+			}
+			got := Config{APIKey: cfg.APIKey, Port: cfg.Port, Timezone: cfg.Timezone, RateLimit: cfg.RateLimit, BackupDir: cfg.BackupDir}
+			if got.APIKey != tt.want.APIKey || got.Port != tt.want.Port || got.Timezone != tt.want.Timezone ||
+				got.RateLimit != tt.want.RateLimit || got.BackupDir != tt.want.BackupDir {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "unknown key", file: "prot = \"8080\"\n", wantErr: `unknown key "prot"`},
+		{name: "unknown table key", file: "[backup]\ndirectory = \"/b\"\n", wantErr: `unknown key "backup_directory"`},
+		{name: "invalid value", file: "rate_limit = -1\n", wantErr: "TIMELOG_RATE_LIMIT must be a positive integer"},
+		{name: "syntax error", file: "port = \n", wantErr: "line 1: expected a value"},
+		{name: "same setting twice", file: "backup_dir = \"/a\"\n[backup]\ndir = \"/b\"\n", wantErr: "set twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigFile(writeConfigFile(t, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := LoadConfigFile(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+	if _, err := LoadConfigFlags("", map[string]string{"prot": "80"}); err == nil || !strings.Contains(err.Error(), `unknown setting "prot"`) {
+		t.Errorf("expected an unknown flag to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_ConfigEnv(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_PORT", "")
+	t.Setenv("TIMELOG_CONFIG", writeConfigFile(t, "port = 8181\n"))
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Port != "8181" {
+		t.Errorf("Port = %q, want the config file setting", cfg.Port)
+	}
+}
+
+func TestLoadConfig_InvalidSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"unknown time zone", map[string]string{"TIMELOG_TZ": "Mars/Olympus"}, `TIMELOG_TZ "Mars/Olympus" is not a valid time zone`},
+		{"port not a number", map[string]string{"TIMELOG_PORT": "abc"}, "TIMELOG_PORT must be a port number"},
+		{"port zero", map[string]string{"TIMELOG_PORT": "0"}, "TIMELOG_PORT must be a port number"},
+		{"port out of range", map[string]string{"TIMELOG_PORT": "70000"}, "TIMELOG_PORT must be a port number"},
+		{"redirect port", map[string]string{"TIMELOG_TLS_CERT": "cert.pem", "TIMELOG_TLS_KEY": "key.pem", "TIMELOG_HTTP_REDIRECT_PORT": "http"}, "TIMELOG_HTTP_REDIRECT_PORT must be a port number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIMELOG_API_KEY", testAPIKey)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ReportsEveryError(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", "short")
+	t.Setenv("TIMELOG_TZ", "Nowhere")
+	t.Setenv("TIMELOG_PORT", "abc")
+	t.Setenv("TIMELOG_RATE_LIMIT", "-1")
+
+	_, err := LoadConfigFlags("", map[string]string{"prot": "80"})
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a ConfigError, got %v", err)
+	}
+	want := []string{
+		"TIMELOG_API_KEY must be at least 32 characters",
+		"TIMELOG_TZ",
+		"TIMELOG_PORT",
+		"TIMELOG_RATE_LIMIT",
+		`unknown setting "prot"`,
+	}
+	if len(cfgErr.Errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(cfgErr.Errs), len(want), err)
+	}
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "5 errors:\n") {
+		t.Errorf("message %q does not start with the error count", msg)
+	}
+	for _, w := range want {
+		if !strings.Contains(msg, w) {
+			t.Errorf("message lacks %q:\n%s", w, msg)
+		}
+	}
+}
+
+func TestLoadConfig_Listen(t *testing.T) {
+	tests := []struct {
+		name       string
+		listen     string
+		port       string
+		wantListen string
+		wantPort   string
+		wantErr    string
+	}{
+		{name: "port fallback", port: "8080", wantListen: ":8080", wantPort: "8080"},
+		{name: "default", wantListen: ":7070", wantPort: "7070"},
+		{name: "loopback", listen: "127.0.0.1:9000", port: "8080", wantListen: "127.0.0.1:9000", wantPort: "9000"},
+		{name: "all interfaces", listen: ":9000", wantListen: ":9000", wantPort: "9000"},
+		{name: "unix socket", listen: "unix:/run/timetracker.sock", wantListen: "unix:/run/timetracker.sock", wantPort: "7070"},
+		{name: "no port", listen: "localhost", wantErr: "TIMELOG_LISTEN must be"},
+		{name: "bad port", listen: "127.0.0.1:99999", wantErr: "TIMELOG_LISTEN must be"},
+		{name: "no socket path", listen: "unix:", wantErr: "lacks a socket path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIMELOG_API_KEY", testAPIKey)
+			t.Setenv("TIMELOG_LISTEN", tt.listen)
+			t.Setenv("TIMELOG_PORT", tt.port)
+
+			cfg, err := LoadConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.ListenAddr() != tt.wantListen || cfg.Port != tt.wantPort {
+				t.Errorf("listen %q, port %q; want %q, %q", cfg.ListenAddr(), cfg.Port, tt.wantListen, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ListenUnixRedirect(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_LISTEN", "unix:/run/timetracker.sock")
+	t.Setenv("TIMELOG_TLS_CERT", "cert.pem")
+	t.Setenv("TIMELOG_TLS_KEY", "key.pem")
+	t.Setenv("TIMELOG_HTTP_REDIRECT_PORT", "80")
+
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "requires a TCP address") {
+		t.Errorf("expected a redirect port error, got %v", err)
+	}
+}
+
+func TestLoadConfig_SessionDefaults(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DefaultCategory != config.DefaultCategory || cfg.DefaultTask != config.DefaultTask || cfg.MaxPageSize != config.MaxPageSize {
+		t.Errorf("got %q, %q, %d; want the built-in defaults", cfg.DefaultCategory, cfg.DefaultTask, cfg.MaxPageSize)
+	}
+
+	t.Setenv("TIMELOG_DEFAULT_CATEGORY", " misc ")
+	t.Setenv("TIMELOG_DEFAULT_TASK", "untitled")
+	t.Setenv("TIMELOG_MAX_PAGE_SIZE", "500")
+	if cfg, err = LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DefaultCategory != "misc" || cfg.DefaultTask != "untitled" || cfg.MaxPageSize != 500 {
+		t.Errorf("got %q, %q, %d; want misc, untitled, 500", cfg.DefaultCategory, cfg.DefaultTask, cfg.MaxPageSize)
+	}
+
+	for name, value := range map[string]string{
+		"TIMELOG_DEFAULT_CATEGORY": "   ",
+		"TIMELOG_DEFAULT_TASK":     strings.Repeat("x", 201),
+		"TIMELOG_MAX_PAGE_SIZE":    "5000",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("expected an error for %s=%q, got %v", name, value, err)
+			}
+		})
+	}
+	t.Setenv("TIMELOG_MAX_PAGE_SIZE", "0")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected an error for a zero page size")
+	}
+}
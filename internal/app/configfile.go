@@ -0,0 +1,413 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// configSource looks settings up by environment variable name, in the
+// command line flags first, then the environment and then the config file.
+// Lookups are recorded so flags and keys in the file that no setting reads
+// can be reported.
+type configSource struct {
+	path     string
+	flags    map[string]string // values by environment variable name
+	flagKeys map[string]string // flag names by environment variable name
+	file     map[string]string
+	keys     map[string]string // file keys by environment variable name
+	used     map[string]bool
+}
+
+// newConfigSource reads the config file at path; an empty path reads
+// settings from the environment only.
+func newConfigSource(path string) (*configSource, error) {
+	src := &configSource{
+		path:     path,
+		flags:    map[string]string{},
+		flagKeys: map[string]string{},
+		file:     map[string]string{},
+		keys:     map[string]string{},
+		used:     map[string]bool{},
+	}
+	if path == "" {
+		return src, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	values, err := parseConfigFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	for key, value := range values {
+		name := configEnvName(key)
+		if other, ok := src.keys[name]; ok {
+			return nil, fmt.Errorf("config file %s: keys %q and %q set the same setting", path, other, key)
+		}
+		src.file[name] = value
+		src.keys[name] = key
+	}
+	return src, nil
+}
+
+// configEnvName returns the environment variable a config file key stands
+// for: db_path and [db] path both set TIMELOG_DB_PATH.
+func configEnvName(key string) string {
+	return "TIMELOG_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// get returns the setting name from the flags or the environment, or from
+// the config file when the variable is unset or empty.
+func (s *configSource) get(name string) string {
+	s.used[name] = true
+	if value, ok := s.flags[name]; ok {
+		return value
+	}
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return s.file[name]
+}
+
+// secret is like get, but the environment may also name a file holding
+// the value in name_FILE (see SecretEnv).
+func (s *configSource) secret(name string) (string, error) {
+	s.used[name] = true
+	if value, ok := s.flags[name]; ok {
+		return value, nil
+	}
+	value, err := SecretEnv(name)
+	if err != nil || value != "" {
+		return value, err
+	}
+	return s.file[name], nil
+}
+
+// checkUnused reports the flags and config file keys that no setting read,
+// which are most likely typos.
+func (s *configSource) checkUnused() []error {
+	var errs []error
+	for _, name := range sortedKeys(s.flagKeys) {
+		if !s.used[name] {
+			errs = append(errs, fmt.Errorf("unknown setting %q", s.flagKeys[name]))
+		}
+	}
+	for _, name := range sortedKeys(s.keys) {
+		if !s.used[name] {
+			errs = append(errs, fmt.Errorf("config file %s: unknown key %q", s.path, s.keys[name]))
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseConfigFile parses the subset of TOML used by config files: tables,
+// and keys set to strings, integers, booleans or arrays of those. Keys in
+// a table are prefixed with the table name and an underscore, so the map
+// is flat. Array elements are joined with commas, like list settings in
+// the environment.
+func parseConfigFile(src string) (map[string]string, error) {
+	p := &tomlParser{src: src, line: 1}
+	values := map[string]string{}
+	table := ""
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return values, nil
+		}
+		if p.peek() == '[' {
+			p.pos++
+			if p.peek() == '[' {
+				return nil, p.errorf("arrays of tables are not supported")
+			}
+			p.skipSpaces()
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if p.peek() != ']' {
+				return nil, p.errorf("expected ] after table name")
+			}
+			p.pos++
+			if err := p.endOfLine(); err != nil {
+				return nil, err
+			}
+			table = key + "_"
+			continue
+		}
+
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.peek() != '=' {
+			return nil, p.errorf("expected = after key %q", key)
+		}
+		p.pos++
+		p.skipSpaces()
+		value, err := p.value(true)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+		key = table + key
+		if _, ok := values[key]; ok {
+			return nil, p.errorf("key %q is set twice", key)
+		}
+		values[key] = value
+	}
+}
+
+// tomlParser reads TOML from src, tracking the line for error messages.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skipSpaces skips spaces and tabs.
+func (p *tomlParser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine skips the rest of a line, which may only hold a comment.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpaces()
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	return nil
+}
+
+// key reads a bare, quoted or dotted key. The parts of a dotted key are
+// joined with underscores.
+func (p *tomlParser) key() (string, error) {
+	var parts []string
+	for {
+		var part string
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			s, err := p.str()
+			if err != nil {
+				return "", err
+			}
+			part = s
+		default:
+			start := p.pos
+			for isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return "", p.errorf("expected a key")
+			}
+			part = p.src[start:p.pos]
+		}
+		parts = append(parts, part)
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return strings.Join(parts, "_"), nil
+		}
+		p.pos++
+		p.skipSpaces()
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value reads a string, integer, boolean or, if arrays is set, an array.
+func (p *tomlParser) value(arrays bool) (string, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) || strings.HasPrefix(p.src[p.pos:], "'''") {
+			return "", p.errorf("multi-line strings are not supported")
+		}
+		return p.str()
+	case c == '[':
+		if !arrays {
+			return "", p.errorf("nested arrays are not supported")
+		}
+		return p.array()
+	case strings.HasPrefix(p.src[p.pos:], "true"):
+		p.pos += len("true")
+		return "true", nil
+	case strings.HasPrefix(p.src[p.pos:], "false"):
+		p.pos += len("false")
+		return "false", nil
+	case c == '+' || c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for isBareKeyChar(p.peek()) || p.peek() == '.' || p.peek() == ':' {
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		n, err := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), 10, 64)
+		if err != nil {
+			return "", p.errorf("unsupported value %q: only strings, integers, booleans and arrays are supported", text)
+		}
+		return strconv.FormatInt(n, 10), nil
+	default:
+		return "", p.errorf("expected a value")
+	}
+}
+
+// array reads an array of scalars, which may span lines, and joins its
+// elements with commas.
+func (p *tomlParser) array() (string, error) {
+	p.pos++ // [
+	var elems []string
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return strings.Join(elems, ","), nil
+		}
+		if p.eof() {
+			return "", p.errorf("unterminated array")
+		}
+		elem, err := p.value(false)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(elem, ",") {
+			return "", p.errorf("array elements cannot contain commas")
+		}
+		elems = append(elems, elem)
+		p.skipBlank()
+		switch {
+		case p.eof():
+			return "", p.errorf("unterminated array")
+		case p.peek() == ',':
+			p.pos++
+		case p.peek() == ']':
+		default:
+			return "", p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+// str reads a basic ("...") or literal ('...') single-line string.
+func (p *tomlParser) str() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && quote == '"':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// escape reads the escape sequence after a backslash in a basic string.
+func (p *tomlParser) escape(b *strings.Builder) error {
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'u', 'U':
+		digits := 4
+		if c == 'U' {
+			digits = 8
+		}
+		if p.pos+digits > len(p.src) {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		p.pos += digits
+		b.WriteRune(rune(code))
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	src := `# Time Tracker settings
+tz = "Asia/Shanghai"   # trailing comment
+log_format = 'text'
+rate_limit = 1_000
+db.busy_timeout = "5s"
+"basic_user" = "adminé"
+api_keys = [
+  "key-one", # first
+  "key-two",
+]
+
+[retention]
+days = 90
+mode = "anonymize"
+`
+	got, err := parseConfigFile(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"tz":              "Asia/Shanghai",
+		"log_format":      "text",
+		"rate_limit":      "1000",
+		"db_busy_timeout": "5s",
+		"basic_user":      "adminé",
+		"api_keys":        "key-one,key-two",
+		"retention_days":  "90",
+		"retention_mode":  "anonymize",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{src: "port", wantErr: "line 1: expected = after key"},
+		{src: "\n\nport = \"80", wantErr: "line 3: unterminated string"},
+		{src: "port = 80 80", wantErr: "after value"},
+		{src: "ratio = 1.5", wantErr: "unsupported value"},
+		{src: "started = 2024-01-01", wantErr: "unsupported value"},
+		{src: `note = """x"""`, wantErr: "multi-line strings"},
+		{src: "[[servers]]", wantErr: "arrays of tables"},
+		{src: "a = [[1]]", wantErr: "nested arrays"},
+		{src: `a = ["x,y"]`, wantErr: "cannot contain commas"},
+		{src: "a = [1, 2", wantErr: "unterminated array"},
+		{src: "a = 1\na = 2", wantErr: `line 2: key "a" is set twice`},
+		{src: `a = "\q"`, wantErr: `invalid escape \q`},
+	}
+	for _, tt := range tests {
+		_, err := parseConfigFile(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.wantErr, err)
+		}
+	}
+}
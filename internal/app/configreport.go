@@ -0,0 +1,176 @@
+package app
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+// ConfigReport is the effective configuration as served by
+// GET /api/v1/admin/config. Keys are named like the config file's. Secrets
+// never appear: API keys are shown by their first characters, other secrets
+// only as "set" or "unset".
+type ConfigReport struct {
+	ConfigFile string `json:"config_file,omitempty"`
+
+	Listen           string `json:"listen"`
+	TLSCert          string `json:"tls_cert,omitempty"`
+	TLSKey           string `json:"tls_key,omitempty"`
+	HTTPRedirectPort string `json:"http_redirect_port,omitempty"`
+
+	Timezone       string `json:"tz"`
+	TimezoneOffset string `json:"tz_offset"`
+
+	DBPath           string   `json:"db_path"`
+	DBAbsPath        string   `json:"db_abs_path"`
+	DBBusyTimeout    string   `json:"db_busy_timeout"`
+	DBIntegrity      string   `json:"db_integrity_check"`
+	DBEncryptionKey  string   `json:"db_encryption_key"`
+	TemplatesDir     string   `json:"templates_dir,omitempty"`
+	APIKeyPrefixes   []string `json:"api_keys"`
+	BasicUser        string   `json:"basic_user,omitempty"`
+	BasicPass        string   `json:"basic_pass"`
+	BasicPassHash    string   `json:"basic_pass_hash"`
+	AdminKey         string   `json:"admin_key"`
+	RateLimit        int      `json:"rate_limit"`
+	RateLimitExempt  []string `json:"rate_limit_exempt"`
+	LogFormat        string   `json:"log_format"`
+	LogLevel         string   `json:"log_level"`
+	MaxBodyBytes     int64    `json:"max_body_bytes"`
+	BulkAssignMax    int      `json:"bulk_assign_max"`
+	DefaultCategory  string   `json:"default_category"`
+	DefaultTask      string   `json:"default_task"`
+	MaxPageSize      int      `json:"max_page_size"`
+	SeedTags         int      `json:"seed_tags"`
+	TrustedProxies   []string `json:"trusted_proxies"`
+	APIAllowCIDRs    []string `json:"api_allow_cidrs"`
+	CORSOrigins      []string `json:"cors_origins"`
+	CSPScriptSrc     []string `json:"csp_script_src"`
+	CSPStyleSrc      []string `json:"csp_style_src"`
+	HSTSMaxAge       int      `json:"hsts_max_age"`
+	StreamHeartbeat  string   `json:"stream_heartbeat"`
+	Maintenance      string   `json:"maintenance_interval"`
+	VacuumInterval   string   `json:"vacuum_interval"`
+	ReminderInterval string   `json:"reminder_interval"`
+	AutoExportDir    string   `json:"auto_export_dir,omitempty"`
+	AutoExportEvery  string   `json:"auto_export_interval,omitempty"`
+	AutoExportRetain int      `json:"auto_export_retain,omitempty"`
+	BackupDir        string   `json:"backup_dir,omitempty"`
+	BackupInterval   string   `json:"backup_interval,omitempty"`
+	BackupKeep       int      `json:"backup_keep,omitempty"`
+	RetentionDays    int      `json:"retention_days"`
+	RetentionMode    string   `json:"retention_mode"`
+	RepairMode       string   `json:"repair_mode"`
+
+	// Tracing shows the endpoint without any password in it, and only the
+	// names of the headers, which usually carry a token
+	TracingEndpoint string   `json:"otel_endpoint,omitempty"`
+	TracingHeaders  []string `json:"otel_headers,omitempty"`
+	TracingService  string   `json:"otel_service_name,omitempty"`
+}
+
+// Report returns the configuration with secrets redacted, and with values
+// derived from it as of now: the time zone's current UTC offset and the
+// database's absolute path.
+func (c *Config) Report(now time.Time) ConfigReport {
+	r := ConfigReport{
+		ConfigFile:       c.ConfigFile,
+		Listen:           c.ListenAddr(),
+		TLSCert:          c.TLSCert,
+		TLSKey:           c.TLSKey,
+		HTTPRedirectPort: c.HTTPRedirectPort,
+		Timezone:         c.Timezone,
+		DBPath:           c.DBPath,
+		DBAbsPath:        c.DBPath,
+		DBBusyTimeout:    c.DBBusyTimeout.String(),
+		DBIntegrity:      "quick",
+		DBEncryptionKey:  setOrUnset(c.DBEncryptionKey),
+		TemplatesDir:     c.TemplatesDir,
+		APIKeyPrefixes:   make([]string, len(c.APIKeys)),
+		BasicUser:        c.BasicUser,
+		BasicPass:        setOrUnset(c.BasicPass),
+		BasicPassHash:    setOrUnset(c.BasicPassHash),
+		AdminKey:         setOrUnset(c.AdminKey),
+		RateLimit:        c.RateLimit,
+		RateLimitExempt:  c.RateLimitExemptPaths,
+		LogFormat:        c.LogFormat,
+		LogLevel:         c.LogLevel.String(),
+		MaxBodyBytes:     c.MaxBodyBytes,
+		BulkAssignMax:    c.BulkAssignMax,
+		DefaultCategory:  c.DefaultCategory,
+		DefaultTask:      c.DefaultTask,
+		MaxPageSize:      c.MaxPageSize,
+		SeedTags:         len(c.SeedTags),
+		TrustedProxies:   make([]string, len(c.TrustedProxies)),
+		APIAllowCIDRs:    make([]string, len(c.APIAllowCIDRs)),
+		CORSOrigins:      c.CORSOrigins,
+		CSPScriptSrc:     c.Security.ScriptSrc,
+		CSPStyleSrc:      c.Security.StyleSrc,
+		HSTSMaxAge:       c.Security.HSTSMaxAge,
+		StreamHeartbeat:  c.StreamHeartbeat.String(),
+		Maintenance:      c.MaintenanceInterval.String(),
+		VacuumInterval:   c.VacuumInterval.String(),
+		ReminderInterval: c.ReminderInterval.String(),
+		RetentionDays:    c.RetentionDays,
+		RetentionMode:    "delete",
+		RepairMode:       "stop",
+		TracingService:   c.Tracing.ServiceName,
+	}
+	if loc, err := time.LoadLocation(c.Timezone); err == nil {
+		r.TimezoneOffset = now.In(loc).Format("-07:00")
+	}
+	if !database.IsMemoryPath(c.DBPath) {
+		if abs, err := filepath.Abs(c.DBPath); err == nil {
+			r.DBAbsPath = abs
+		}
+	}
+	if c.DBFullIntegrityCheck {
+		r.DBIntegrity = "full"
+	}
+	for i, key := range c.APIKeys {
+		r.APIKeyPrefixes[i] = key[:4] + "..."
+	}
+	for i, prefix := range c.TrustedProxies {
+		r.TrustedProxies[i] = prefix.String()
+	}
+	for i, prefix := range c.APIAllowCIDRs {
+		r.APIAllowCIDRs[i] = prefix.String()
+	}
+	if c.AutoExportDir != "" {
+		r.AutoExportDir = c.AutoExportDir
+		r.AutoExportEvery = c.AutoExportInterval.String()
+		r.AutoExportRetain = c.AutoExportRetain
+	}
+	if c.BackupDir != "" {
+		r.BackupDir = c.BackupDir
+		r.BackupInterval = c.BackupInterval.String()
+		r.BackupKeep = c.BackupKeep
+	}
+	if c.RetentionAnonymize {
+		r.RetentionMode = "anonymize"
+	}
+	if c.RepairRefuse {
+		r.RepairMode = "refuse"
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err == nil {
+			r.TracingEndpoint = u.Redacted()
+		}
+		for name := range c.Tracing.Headers {
+			r.TracingHeaders = append(r.TracingHeaders, name)
+		}
+		sort.Strings(r.TracingHeaders)
+	}
+	return r
+}
+
+// setOrUnset reports whether a secret is configured without revealing it.
+func setOrUnset(secret string) string {
+	if secret == "" {
+		return "unset"
+	}
+	return "set"
+}
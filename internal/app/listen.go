@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixPrefix marks a listen address as the path of a Unix socket.
+const unixPrefix = "unix:"
+
+// socketMode lets the owner and group, such as a reverse proxy's, connect to
+// the Unix socket.
+const socketMode = 0o660
+
+// parseListenAddr splits a listen address into the network and address for
+// net.Listen. It accepts "host:port", ":port" and "unix:/path".
+func parseListenAddr(addr string) (network, address string, err error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if path == "" {
+			return "", "", fmt.Errorf("TIMELOG_LISTEN %q lacks a socket path", addr)
+		}
+		return "unix", path, nil
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil || !validPort(port) {
+		return "", "", fmt.Errorf("TIMELOG_LISTEN must be host:port, :port or unix:/path, got %q", addr)
+	}
+	return "tcp", addr, nil
+}
+
+// listen opens a listener on addr. A Unix socket left behind by a server
+// that did not shut down cleanly is replaced; one still in use is not. The
+// socket file is removed again when the listener is closed.
+func listen(addr string) (net.Listener, error) {
+	network, address, err := parseListenAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := os.Chmod(address, socketMode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket at path unless a server is accepting
+// connections on it. Any other kind of file is left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
+
+// LocalAddr returns the network and address a client on the same machine
+// dials to reach the server. A server listening on every interface is
+// reached over loopback.
+func (c *Config) LocalAddr() (network, address string, err error) {
+	network, address, err = parseListenAddr(c.ListenAddr())
+	if err != nil || network == "unix" {
+		return network, address, err
+	}
+	host, port, _ := net.SplitHostPort(address)
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return network, net.JoinHostPort(host, port), nil
+}
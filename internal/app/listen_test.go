@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestApp runs a until the test ends, with Run listening on addr.
+func runTestApp(t *testing.T, addr string) *App {
+	t.Helper()
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) { cfg.Listen = addr })
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.Run() }()
+	t.Cleanup(func() {
+		if err := a.Shutdown(); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-runErr; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+	return a
+}
+
+// getHealth requests /healthz through client, retrying until the server is
+// up.
+func getHealth(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Get(url + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /healthz = %d", resp.StatusCode)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server never answered: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestApp_ListenTCP(t *testing.T) {
+	// Find a free loopback port for the app to listen on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	runTestApp(t, addr)
+	getHealth(t, http.DefaultClient, "http://"+addr)
+}
+
+func TestApp_ListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timelog.sock")
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) { cfg.Listen = "unix:" + path })
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.Run() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	getHealth(t, client, "http://timelog")
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != socketMode {
+		t.Errorf("socket permissions = %o, want %o", perm, socketMode)
+	}
+
+	if err := a.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after shutdown: %v", err)
+	}
+}
+
+// A reverse proxy on the Unix socket is trusted for X-Forwarded-For, so the
+// allowlist, rate limiter and audit log see the real client rather than one
+// shared, unparseable peer address.
+func TestApp_UnixSocketClientIP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timelog.sock")
+	a := newTestAppWith(t, testAPIKey, func(cfg *Config) {
+		cfg.Listen = "unix:" + path
+		cfg.APIAllowCIDRs = []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}
+	})
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.Run() }()
+	t.Cleanup(func() {
+		if err := a.Shutdown(); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-runErr; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	getHealth(t, client, "http://timelog")
+
+	get := func(forwardedFor, key string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "http://timelog/api/v1/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		req.Header.Set("X-API-Key", key)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := get("203.0.113.7", testAPIKey); code != http.StatusOK {
+		t.Errorf("allowed client through the socket = %d, want 200", code)
+	}
+	if code := get("198.51.100.1", testAPIKey); code != http.StatusForbidden {
+		t.Errorf("other client through the socket = %d, want 403", code)
+	}
+	// Without the header the peer is the proxy itself, on loopback
+	if code := get("", testAPIKey); code != http.StatusForbidden {
+		t.Errorf("socket peer without X-Forwarded-For = %d, want 403", code)
+	}
+
+	if code := get("198.51.100.1, 203.0.113.9", "wrong-key"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong key = %d, want 401", code)
+	}
+	var ip string
+	if err := a.db.QueryRow("SELECT ip FROM audit_log WHERE event_type = 'auth_failed' ORDER BY id DESC LIMIT 1").Scan(&ip); err != nil {
+		t.Fatal(err)
+	}
+	if ip != "203.0.113.9" {
+		t.Errorf("audit log recorded %q, want the forwarded client 203.0.113.9", ip)
+	}
+}
+
+func TestListen_UnixSocketInUse(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket left behind by a crashed server is replaced
+	stale := filepath.Join(dir, "stale.sock")
+	old, err := net.ListenUnix("unix", &net.UnixAddr{Name: stale, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.SetUnlinkOnClose(false)
+	old.Close()
+	ln, err := listen("unix:" + stale)
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+
+	// One still accepting connections is not
+	if _, err := listen("unix:" + stale); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("socket in use: got %v", err)
+	}
+	ln.Close()
+
+	// Nor is a file that is not a socket
+	file := filepath.Join(dir, "data.db")
+	if err := os.WriteFile(file, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen("unix:" + file); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("regular file: got %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
+
+func TestConfig_LocalAddr(t *testing.T) {
+	tests := []struct {
+		cfg             Config
+		network, target string
+	}{
+		{Config{Port: "8080"}, "tcp", "127.0.0.1:8080"},
+		{Config{Listen: "0.0.0.0:9000"}, "tcp", "127.0.0.1:9000"},
+		{Config{Listen: "[::]:9000"}, "tcp", "[::1]:9000"},
+		{Config{Listen: "192.0.2.7:9000"}, "tcp", "192.0.2.7:9000"},
+		{Config{Listen: "unix:/run/timelog.sock"}, "unix", "/run/timelog.sock"},
+	}
+	for _, tt := range tests {
+		network, target, err := tt.cfg.LocalAddr()
+		if err != nil || network != tt.network || target != tt.target {
+			t.Errorf("LocalAddr() for %q = %s %s, %v; want %s %s", tt.cfg.ListenAddr(), network, target, err, tt.network, tt.target)
+		}
+	}
+}
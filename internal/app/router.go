@@ -2,29 +2,67 @@ package app
 
 import (
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"time-tracker/internal/admin"
+	"time-tracker/internal/apikeys"
 	"time-tracker/internal/handler"
+	"time-tracker/internal/importer"
+	"time-tracker/internal/openapi"
+	"time-tracker/internal/presets"
+	"time-tracker/internal/reminders"
 	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/middleware"
 	"time-tracker/internal/tags"
+	"time-tracker/internal/users"
 	"time-tracker/internal/shared/health"
 	"time-tracker/internal/web"
 )
 
+// compressiblePaths are the session list and text export endpoints served with gzip.
+var compressiblePaths = map[string]bool{
+	"/api/v1/sessions":        true,
+	"/api/v1/sessions.csv":    true,
+	"/api/v1/sessions.json":   true,
+	"/api/v1/sessions.ndjson": true,
+}
+
 // NewRouter creates and configures the HTTP router with all routes.
 func NewRouter(
 	cfg *Config,
+	creds *auth.CredentialStore,
+	webSessions *auth.WebSessionStore,
 	sessionsHandler *handler.SessionsHandler,
 	tagsHandler *tags.TagsHandler,
 	healthHandler *health.HealthHandler,
 	webHandler *web.WebHandler,
+	adminHandler *admin.AdminHandler,
+	apiKeysHandler *apikeys.APIKeysHandler,
+	usersHandler *users.UsersHandler,
+	importHandler *importer.ImportHandler,
+	presetsHandler *presets.PresetsHandler,
+	remindersHandler *reminders.RemindersHandler,
 ) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	// Health endpoint (no authentication required)
+	// Liveness, readiness and version endpoints (no authentication required)
 	mux.Handle("/healthz", healthHandler)
+	mux.Handle("/readyz", healthHandler)
+	mux.Handle("/version", healthHandler)
+
+	// The API description (no authentication required)
+	mux.Handle("/openapi.json", openapi.Handler())
+
+	// List and export responses are compressed for clients that accept gzip
+	compressedSessions := middleware.GzipMiddleware(config.GzipMinSize)(sessionsHandler)
+
+	// Admin endpoints reach every user's data, so they additionally require
+	// the admin key, or without one the default user
+	adminRoutes := creds.AdminKeyMiddleware()(adminHandler)
+	apiKeysRoutes := creds.AdminKeyMiddleware()(apiKeysHandler)
+	usersRoutes := creds.AdminKeyMiddleware()(usersHandler)
 
 	// API endpoints (require API key authentication)
 	apiHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -34,19 +72,42 @@ func NewRouter(
 		// Session-tags association endpoints go to tags handler
 		case strings.HasPrefix(path, "/api/v1/sessions/") && (strings.HasSuffix(path, "/tags") || strings.Contains(path, "/tags/")):
 			tagsHandler.ServeHTTP(w, r)
+		// Session import
+		case path == "/api/v1/sessions/import":
+			importHandler.ServeHTTP(w, r)
+		// Session list and exports, compressed when accepted
+		case compressiblePaths[path]:
+			compressedSessions.ServeHTTP(w, r)
 		// Other sessions endpoints
 		case strings.HasPrefix(path, "/api/v1/sessions"):
 			sessionsHandler.ServeHTTP(w, r)
 		// Tags endpoints
 		case strings.HasPrefix(path, "/api/v1/tags"):
 			tagsHandler.ServeHTTP(w, r)
+		// Quick-start presets
+		case path == "/api/v1/presets" || strings.HasPrefix(path, "/api/v1/presets/"):
+			presetsHandler.ServeHTTP(w, r)
+		// Long-session reminder rules
+		case path == "/api/v1/reminders" || strings.HasPrefix(path, "/api/v1/reminders/"):
+			remindersHandler.ServeHTTP(w, r)
+		// API key management
+		case path == "/api/v1/admin/keys" || strings.HasPrefix(path, "/api/v1/admin/keys/"):
+			apiKeysRoutes.ServeHTTP(w, r)
+		// User management
+		case path == "/api/v1/admin/users":
+			usersRoutes.ServeHTTP(w, r)
+		// Admin endpoints
+		case strings.HasPrefix(path, "/api/v1/admin/"):
+			adminRoutes.ServeHTTP(w, r)
 		default:
-			http.NotFound(w, r)
+			errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
 		}
 	})
 
-	// Apply API key middleware to API routes (also allow Basic Auth for web interface)
-	mux.Handle("/api/", auth.APIKeyMiddleware(cfg.APIKey, cfg.BasicUser, cfg.BasicPass)(apiHandler))
+	// Apply API key middleware to API routes (also allow Basic Auth for web interface),
+	// rejecting clients outside the allowlist before authentication runs.
+	// CORS preflights carry no credentials, so they are answered first.
+	mux.Handle("/api/", middleware.CORSMiddleware(cfg.CORSOrigins)(middleware.IPAllowlistMiddleware(cfg.APIAllowCIDRs)(creds.APIKeyMiddleware()(apiHandler))))
 
 	// Web endpoints (require Basic Auth if configured)
 	webMux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -54,7 +115,7 @@ func NewRouter(
 	})
 
 	// CSV export endpoints (also require Basic Auth if configured)
-	csvHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	csvHandler := middleware.GzipMiddleware(config.GzipMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		switch path {
 		case "/sessions.csv":
@@ -62,34 +123,32 @@ func NewRouter(
 		default:
 			http.NotFound(w, r)
 		}
-	})
+	}))
 
-	// Apply Basic Auth middleware if credentials are configured
-	if cfg.BasicUser != "" && cfg.BasicPass != "" {
-		mux.Handle("/web/", auth.BasicAuthMiddleware(cfg.BasicUser, cfg.BasicPass)(webMux))
-		mux.Handle("/sessions.csv", auth.BasicAuthMiddleware(cfg.BasicUser, cfg.BasicPass)(csvHandler))
-	} else {
-		mux.Handle("/web/", webMux)
-		mux.Handle("/sessions.csv", csvHandler)
-	}
+	// Require a login cookie or Basic Auth (a no-op while no credentials are configured)
+	mux.Handle("/web/", creds.WebAuthMiddleware(webSessions)(webMux))
+	mux.Handle("/sessions.csv", creds.WebAuthMiddleware(webSessions)(csvHandler))
 
-	// Redirect root path to /web/sessions
+	// Redirect root path to /web/sessions; other unknown paths get the 404 page,
+	// or a JSON error for API-looking paths the /api/ route does not cover
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
 			http.Redirect(w, r, "/web/sessions", http.StatusFound)
 			return
 		}
-		http.NotFound(w, r)
+		if strings.HasPrefix(r.URL.Path, "/api") {
+			errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+			return
+		}
+		webHandler.NotFound(w, r)
 	})
 
-	// Static files from templates/static
-	absTemplates, err := filepath.Abs("templates")
-	if err == nil {
-		staticPath := filepath.Join(absTemplates, "static")
-		if _, err := os.Stat(staticPath); err == nil {
-			mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticPath))))
-		}
-	}
+	// Files browsers and crawlers ask for at the root (no authentication required)
+	mux.Handle("/favicon.ico", webHandler.StaticFile("favicon.ico"))
+	mux.Handle("/robots.txt", webHandler.StaticFile("robots.txt"))
+
+	// Static files, embedded unless TIMELOG_TEMPLATES_DIR is set
+	mux.Handle("/static/", webHandler.Static())
 
 	return mux
 }
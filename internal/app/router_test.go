@@ -0,0 +1,163 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter_NotFound(t *testing.T) {
+	apiKey := "router-api-key-32-chars-minimum!!!!!"
+	a := newTestApp(t, apiKey)
+
+	serve := func(path string, authenticate func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authenticate != nil {
+			authenticate(req)
+		}
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+	withKey := func(req *http.Request) { req.Header.Set("X-API-Key", apiKey) }
+	withLogin := func(req *http.Request) { req.SetBasicAuth("admin", "secret123") }
+
+	// API paths answer with the JSON error envelope
+	for _, path := range []string{"/api/v1/nope", "/api/v2/sessions", "/apiv1/sessions"} {
+		rr := serve(path, withKey)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected JSON, got %q", path, ct)
+		}
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error.Code != "NOT_FOUND" {
+			t.Errorf("%s: expected NOT_FOUND error, got %s", path, rr.Body.String())
+		}
+	}
+
+	// Web and other paths get the HTML page
+	for _, tc := range []struct {
+		path         string
+		authenticate func(*http.Request)
+	}{
+		{"/web/nope", withLogin},
+		{"/web/sessions/abc/edit", withLogin},
+		{"/wp-login.php", nil},
+	} {
+		rr := serve(tc.path, tc.authenticate)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", tc.path, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: expected HTML, got %q", tc.path, ct)
+		}
+		if !strings.Contains(rr.Body.String(), "页面不存在") {
+			t.Errorf("%s: expected the 404 page, got %s", tc.path, rr.Body.String())
+		}
+	}
+}
+
+func TestRouter_Unauthorized(t *testing.T) {
+	apiKey := "router-api-key-32-chars-minimum!!!!!"
+	a := newTestAppWith(t, apiKey, func(cfg *Config) { cfg.AdminKey = "router-admin-key" })
+
+	for _, tc := range []struct {
+		name      string
+		method    string
+		path      string
+		header    http.Header
+		challenge bool
+	}{
+		{"missing API key", http.MethodGet, "/api/v1/sessions", nil, false},
+		{"wrong admin key", http.MethodGet, "/api/v1/admin/keys", http.Header{"X-Api-Key": {apiKey}, "X-Admin-Key": {"wrong"}}, false},
+		{"wrong Basic Auth", http.MethodGet, "/web/sessions", http.Header{"Authorization": {"Basic YWRtaW46d3Jvbmc="}}, true},
+		{"no web login", http.MethodPost, "/web/sessions/start", nil, false},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		for k, v := range tc.header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", tc.name, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected JSON, got %q", tc.name, ct)
+		}
+		if got := rr.Header().Get("WWW-Authenticate") != ""; got != tc.challenge {
+			t.Errorf("%s: WWW-Authenticate present = %v, want %v", tc.name, got, tc.challenge)
+		}
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error.Code != "UNAUTHORIZED" || body.Error.Message == "" {
+			t.Errorf("%s: expected UNAUTHORIZED error, got %s", tc.name, rr.Body.String())
+		}
+	}
+}
+
+func TestRouter_AdminWithoutAdminKey(t *testing.T) {
+	apiKey := "router-api-key-32-chars-minimum!!!!!"
+	a := newTestApp(t, apiKey)
+
+	serve := func(method, path, body string, authenticate func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authenticate(req)
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+	withKey := func(req *http.Request) { req.Header.Set("X-API-Key", apiKey) }
+	asPartner := func(req *http.Request) { req.SetBasicAuth("partner", "partner-pass") }
+
+	// The default user administers the server while no admin key is set
+	if rr := serve(http.MethodPost, "/api/v1/admin/users", `{"name":"partner","password":"partner-pass"}`, withKey); rr.Code != http.StatusCreated {
+		t.Fatalf("default user creating a user: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Other users keep their own data but are refused the admin endpoints
+	if rr := serve(http.MethodGet, "/api/v1/sessions", "", asPartner); rr.Code != http.StatusOK {
+		t.Fatalf("partner listing sessions: expected 200, got %d", rr.Code)
+	}
+	for _, path := range []string{"/api/v1/admin/keys", "/api/v1/admin/users", "/api/v1/admin/export"} {
+		if rr := serve(http.MethodGet, path, "", asPartner); rr.Code != http.StatusForbidden {
+			t.Errorf("partner GET %s: expected 403, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestRouter_RootFiles(t *testing.T) {
+	a := newTestApp(t, "router-api-key-32-chars-minimum!!!!!")
+
+	// Served without credentials, and revalidated rather than cached for good
+	for path, want := range map[string]string{
+		"/favicon.ico": "\x00\x00\x01\x00",
+		"/robots.txt":  "User-agent: *",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rr.Code)
+		}
+		if !strings.HasPrefix(rr.Body.String(), want) {
+			t.Errorf("%s: unexpected body %q", path, rr.Body.String())
+		}
+		if rr.Header().Get("Cache-Control") != "no-cache" || rr.Header().Get("ETag") == "" {
+			t.Errorf("%s: expected no-cache with an ETag, got %q %q", path, rr.Header().Get("Cache-Control"), rr.Header().Get("ETag"))
+		}
+	}
+}
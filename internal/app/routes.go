@@ -0,0 +1,74 @@
+package app
+
+import "net/http"
+
+// route is an endpoint the router serves. Path parameters are written
+// {name}, as in the OpenAPI document.
+type route struct {
+	method string
+	path   string
+}
+
+// apiRoutes lists every endpoint NewRouter serves other than the web
+// interface's pages and assets. The handlers dispatch on the path
+// themselves, so this table is what keeps internal/openapi/openapi.json in
+// step with them: the router tests check that each route is served and
+// documented, and that nothing else is documented. Add new endpoints here.
+var apiRoutes = []route{
+	{http.MethodPost, "/api/v1/sessions/start"},
+	{http.MethodPost, "/api/v1/sessions/stop"},
+	{http.MethodGet, "/api/v1/sessions/current"},
+	{http.MethodGet, "/api/v1/sessions/stream"},
+	{http.MethodGet, "/api/v1/sessions"},
+	{http.MethodGet, "/api/v1/sessions.csv"},
+	{http.MethodGet, "/api/v1/sessions.json"},
+	{http.MethodGet, "/api/v1/sessions.ndjson"},
+	{http.MethodGet, "/api/v1/sessions.xlsx"},
+	{http.MethodGet, "/api/v1/sessions.ics"},
+	{http.MethodPost, "/api/v1/sessions/import"},
+	{http.MethodGet, "/api/v1/sessions/{id}/tags"},
+	{http.MethodPost, "/api/v1/sessions/{id}/tags"},
+	{http.MethodDelete, "/api/v1/sessions/{id}/tags/{tag_id}"},
+	{http.MethodGet, "/sessions.csv"},
+
+	{http.MethodGet, "/api/v1/tags"},
+	{http.MethodPost, "/api/v1/tags"},
+	{http.MethodGet, "/api/v1/tags/stats"},
+	{http.MethodGet, "/api/v1/tags/{id}"},
+	{http.MethodPatch, "/api/v1/tags/{id}"},
+	{http.MethodDelete, "/api/v1/tags/{id}"},
+	{http.MethodPost, "/api/v1/tags/{id}/bulk-assign"},
+
+	{http.MethodGet, "/api/v1/presets"},
+	{http.MethodPost, "/api/v1/presets"},
+	{http.MethodPost, "/api/v1/presets/reorder"},
+	{http.MethodGet, "/api/v1/presets/{id}"},
+	{http.MethodPatch, "/api/v1/presets/{id}"},
+	{http.MethodDelete, "/api/v1/presets/{id}"},
+	{http.MethodPost, "/api/v1/presets/{id}/start"},
+
+	{http.MethodGet, "/api/v1/reminders"},
+	{http.MethodPost, "/api/v1/reminders"},
+	{http.MethodGet, "/api/v1/reminders/{id}"},
+	{http.MethodPatch, "/api/v1/reminders/{id}"},
+	{http.MethodDelete, "/api/v1/reminders/{id}"},
+
+	{http.MethodGet, "/api/v1/admin/keys"},
+	{http.MethodPost, "/api/v1/admin/keys"},
+	{http.MethodDelete, "/api/v1/admin/keys/{id}"},
+	{http.MethodGet, "/api/v1/admin/users"},
+	{http.MethodPost, "/api/v1/admin/users"},
+	{http.MethodGet, "/api/v1/admin/backup"},
+	{http.MethodGet, "/api/v1/admin/audit"},
+	{http.MethodGet, "/api/v1/admin/maintenance"},
+	{http.MethodPost, "/api/v1/admin/maintenance"},
+	{http.MethodPost, "/api/v1/admin/purge"},
+	{http.MethodGet, "/api/v1/admin/export"},
+	{http.MethodPost, "/api/v1/admin/import"},
+	{http.MethodGet, "/api/v1/admin/config"},
+
+	{http.MethodGet, "/healthz"},
+	{http.MethodGet, "/readyz"},
+	{http.MethodGet, "/version"},
+	{http.MethodGet, "/openapi.json"},
+}
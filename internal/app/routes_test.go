@@ -0,0 +1,190 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/openapi"
+)
+
+// specOperations returns the operations of the OpenAPI document as
+// "METHOD /path" strings.
+func specOperations(t *testing.T) map[string]bool {
+	t.Helper()
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("openapi.json does not parse: %v", err)
+	}
+	ops := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" {
+				ops[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	return ops
+}
+
+func TestRoutes_MatchOpenAPI(t *testing.T) {
+	ops := specOperations(t)
+
+	seen := make(map[string]bool)
+	for _, rt := range apiRoutes {
+		key := rt.method + " " + rt.path
+		if seen[key] {
+			t.Errorf("%s is listed twice in apiRoutes", key)
+		}
+		seen[key] = true
+		if !ops[key] {
+			t.Errorf("%s is routed but missing from openapi.json", key)
+		}
+	}
+	for key := range ops {
+		if !seen[key] {
+			t.Errorf("%s is in openapi.json but not in apiRoutes", key)
+		}
+	}
+}
+
+// TestRoutes_Served checks that the router serves every route in apiRoutes,
+// so the table cannot list endpoints that were removed or mistyped. A
+// route counts as served when its handler answers, even with an error
+// about the request; unknown paths get "Endpoint not found" or the HTML
+// 404 page, and known paths called with another method a 405.
+func TestRoutes_Served(t *testing.T) {
+	apiKey := "routes-api-key-32-chars-minimum!!!!!"
+	a := newTestAppWith(t, apiKey, func(cfg *Config) {
+		cfg.MaintenanceInterval = time.Hour
+		cfg.RateLimit = 1000
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		// The session stream runs until the client goes away
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(method, path, nil).WithContext(ctx)
+		req.Header.Set("X-API-Key", apiKey)
+		req.SetBasicAuth("admin", "secret123")
+		if strings.HasPrefix(path, "/api/") {
+			req.Header.Del("Authorization")
+		}
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+	unrouted := func(rr *httptest.ResponseRecorder) bool {
+		body := rr.Body.String()
+		return strings.Contains(body, "Endpoint not found") ||
+			rr.Code == http.StatusMethodNotAllowed ||
+			strings.Contains(body, "Method not allowed") ||
+			(rr.Code == http.StatusNotFound && strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html"))
+	}
+
+	for _, rt := range apiRoutes {
+		path := strings.NewReplacer("{id}", "1", "{tag_id}", "1").Replace(rt.path)
+		if rr := serve(rt.method, path); unrouted(rr) {
+			t.Errorf("%s %s is not served: %d %s", rt.method, rt.path, rr.Code, rr.Body.String())
+		}
+	}
+
+	// The check itself must tell unknown routes apart
+	for _, rt := range []route{
+		{http.MethodGet, "/api/v1/sessions/nope"},
+		{http.MethodPut, "/api/v1/tags"},
+		{http.MethodGet, "/nope.json"},
+	} {
+		if rr := serve(rt.method, rt.path); !unrouted(rr) {
+			t.Errorf("%s %s looks served: %d %s", rt.method, rt.path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestRoutes_MethodNotAllowed checks that known paths called with a method
+// they do not take answer 405 with the methods they do take in Allow,
+// rather than a 400 or a 404, for the API and the web pages alike.
+func TestRoutes_MethodNotAllowed(t *testing.T) {
+	apiKey := "routes-api-key-32-chars-minimum!!!!!"
+	a := newTestAppWith(t, apiKey, func(cfg *Config) { cfg.MaintenanceInterval = time.Hour })
+
+	for _, tt := range []struct {
+		method, path, allow string
+	}{
+		{http.MethodDelete, "/api/v1/admin/backup", "GET"},
+		{http.MethodPost, "/api/v1/admin/audit", "GET"},
+		{http.MethodDelete, "/api/v1/admin/maintenance", "GET, POST"},
+		{http.MethodGet, "/api/v1/admin/purge", "POST"},
+		{http.MethodPost, "/api/v1/admin/export", "GET"},
+		{http.MethodGet, "/api/v1/admin/import", "POST"},
+		{http.MethodPost, "/api/v1/admin/config", "GET"},
+		{http.MethodPut, "/api/v1/admin/keys", "GET, POST"},
+		{http.MethodGet, "/api/v1/admin/keys/1", "DELETE"},
+		{http.MethodDelete, "/api/v1/admin/users", "GET, POST"},
+		{http.MethodGet, "/api/v1/sessions/import", "POST"},
+		{http.MethodPut, "/api/v1/presets", "GET, POST"},
+		{http.MethodGet, "/api/v1/presets/reorder", "POST"},
+		{http.MethodGet, "/api/v1/presets/1/start", "POST"},
+		{http.MethodPost, "/api/v1/presets/1", "GET, PATCH, DELETE"},
+		{http.MethodPut, "/api/v1/reminders", "GET, POST"},
+		{http.MethodPost, "/api/v1/reminders/1", "GET, PATCH, DELETE"},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusMethodNotAllowed || !strings.Contains(rr.Body.String(), "METHOD_NOT_ALLOWED") {
+			t.Errorf("%s %s: expected 405 METHOD_NOT_ALLOWED, got %d %s", tt.method, tt.path, rr.Code, rr.Body.String())
+		}
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+	}
+
+	for _, tt := range []struct {
+		method, path, allow string
+	}{
+		{http.MethodPut, "/web/sessions", "GET"},
+		{http.MethodPut, "/web/sessions/partials/table", "GET"},
+		{http.MethodPut, "/web/sessions/stream", "GET"},
+		{http.MethodPut, "/web/sessions/1/edit", "GET, POST"},
+		{http.MethodPut, "/web/tags", "GET"},
+		{http.MethodPut, "/web/docs", "GET"},
+		{http.MethodPut, "/web/login", "GET, POST"},
+		{http.MethodGet, "/web/logout", "POST"},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.SetBasicAuth("admin", "secret123")
+		rr := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected 405, got %d %s", tt.method, tt.path, rr.Code, rr.Body.String())
+		}
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+	}
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	a := newTestApp(t, "router-api-key-32-chars-minimum!!!!!")
+
+	// Served without credentials
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the JSON document, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr.Body.String() != string(openapi.Spec) {
+		t.Error("served document differs from the embedded one")
+	}
+}
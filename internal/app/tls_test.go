@@ -0,0 +1,152 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and key for localhost.
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	cfg, err := newTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || len(cfg.Certificates) != 1 {
+		t.Fatalf("unexpected TLS config: %+v", cfg)
+	}
+
+	if _, err := newTLSConfig(certFile, filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Fatal("expected error for unreadable key file")
+	}
+	if _, err := newTLSConfig(keyFile, certFile); err == nil {
+		t.Fatal("expected error for swapped certificate and key")
+	}
+}
+
+func TestLoadConfig_TLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		port    string
+		wantErr string
+	}{
+		{"disabled", "", "", "", ""},
+		{"both set", "cert.pem", "key.pem", "", ""},
+		{"with redirect", "cert.pem", "key.pem", "80", ""},
+		{"cert only", "cert.pem", "", "", "set together"},
+		{"key only", "", "key.pem", "", "set together"},
+		{"redirect without TLS", "", "", "80", "requires"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIMELOG_API_KEY", testAPIKey)
+			t.Setenv("TIMELOG_TLS_CERT", tt.cert)
+			t.Setenv("TIMELOG_TLS_KEY", tt.key)
+			t.Setenv("TIMELOG_HTTP_REDIRECT_PORT", tt.port)
+
+			_, err := LoadConfig()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsPort string
+		host      string
+		target    string
+		want      string
+	}{
+		{"443", "example.com", "/web/sessions?page=2", "https://example.com/web/sessions?page=2"},
+		{"443", "example.com:80", "/", "https://example.com/"},
+		{"8443", "example.com:8080", "/api/v1/sessions", "https://example.com:8443/api/v1/sessions"},
+		{"8443", "[2001:db8::1]:8080", "/", "https://[2001:db8::1]:8443/"},
+		{"443", "[2001:db8::1]:80", "/", "https://[2001:db8::1]/"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Host = tt.host
+		rr := httptest.NewRecorder()
+		httpsRedirectHandler(tt.httpsPort).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s: expected 301, got %d", tt.host, tt.target, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s%s: expected Location %q, got %q", tt.host, tt.target, tt.want, got)
+		}
+	}
+}
+
+func TestLoadConfig_HSTS(t *testing.T) {
+	t.Setenv("TIMELOG_API_KEY", testAPIKey)
+	t.Setenv("TIMELOG_HSTS_MAX_AGE", "31536000")
+
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "requires") {
+		t.Fatalf("expected HSTS without TLS to be rejected, got %v", err)
+	}
+
+	t.Setenv("TIMELOG_TLS_CERT", "cert.pem")
+	t.Setenv("TIMELOG_TLS_KEY", "key.pem")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Security.HSTSMaxAge != 31536000 {
+		t.Errorf("expected HSTS max-age 31536000, got %d", cfg.Security.HSTSMaxAge)
+	}
+}
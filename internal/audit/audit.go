@@ -0,0 +1,159 @@
+// Package audit records security-relevant events such as failed logins,
+// API key changes and destructive operations.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/middleware"
+)
+
+// Event types.
+const (
+	EventAuthFailed          = auth.EventAuthFailed
+	EventAPIKeyCreated       = "api_key_created"
+	EventAPIKeyRevoked       = "api_key_revoked"
+	EventCredentialsReloaded = "credentials_reloaded"
+	EventBackupDownloaded    = "backup_downloaded"
+	EventDataExported        = "data_exported"
+	EventDataImported        = "data_imported"
+	EventMaintenanceRun      = "maintenance_run"
+	EventSessionDeleted      = "session_deleted"
+	EventSessionsImported    = "sessions_imported"
+	EventSessionsPurged      = "sessions_purged"
+	EventTagDeleted          = "tag_deleted"
+	EventTagBulkAssigned     = "tag_bulk_assigned"
+	EventUserCreated         = "user_created"
+)
+
+// Entry is a recorded event.
+type Entry struct {
+	ID        int64           `json:"id"`
+	Timestamp string          `json:"timestamp"`
+	EventType string          `json:"event_type"`
+	Actor     string          `json:"actor"`
+	IP        string          `json:"ip"`
+	Details   json.RawMessage `json:"details"`
+}
+
+// Filter narrows the entries returned by List. From and To are RFC3339 UTC
+// bounds; To is exclusive.
+type Filter struct {
+	EventType *string
+	From      *string
+	To        *string
+}
+
+// Logger writes audit entries to the database. Writes are best-effort:
+// failures are logged and never returned, so auditing cannot fail the
+// operation being audited. A nil Logger records nothing.
+type Logger struct {
+	db     *database.DB
+	logger *slog.Logger
+}
+
+func NewLogger(db *database.DB) *Logger {
+	return &Logger{db: db, logger: slog.Default()}
+}
+
+// SetLogger sets where failures to record an event are logged.
+func (l *Logger) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		l.logger = logger
+	}
+}
+
+// Record records an event for r, attributed to the identity that made it.
+// It implements auth.AuditRecorder.
+func (l *Logger) Record(r *http.Request, eventType string, details map[string]interface{}) {
+	l.RecordActor(r, eventType, auth.Actor(r), details)
+}
+
+// RecordActor is like Record with an explicit actor, for events where the
+// identity is not in the request headers, such as a login form.
+func (l *Logger) RecordActor(r *http.Request, eventType, actor string, details map[string]interface{}) {
+	l.Log(eventType, actor, middleware.ClientIP(r), details)
+}
+
+// Log records an event that did not come from a request.
+func (l *Logger) Log(eventType, actor, ip string, details map[string]interface{}) {
+	if l == nil {
+		return
+	}
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		l.logger.Error("audit: failed to encode details", "event", eventType, "error", err)
+		detailsJSON = []byte("{}")
+	}
+
+	_, err = l.db.Exec(
+		`INSERT INTO audit_log (timestamp, event_type, actor, ip, details) VALUES (?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), eventType, actor, ip, string(detailsJSON),
+	)
+	if err != nil {
+		l.logger.Error("audit: failed to record event", "event", eventType, "error", err)
+	}
+}
+
+// List returns entries matching filter, newest first.
+func (l *Logger) List(filter Filter, limit, offset int) (*models.PaginatedResponse[Entry], error) {
+	var conditions []string
+	var args []interface{}
+	if filter.EventType != nil {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, *filter.EventType)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, *filter.To)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count audit log: %w", err)
+	}
+
+	rows, err := l.db.Query(
+		`SELECT id, timestamp, event_type, actor, ip, details FROM audit_log`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	items := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var details string
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.EventType, &e.Actor, &e.IP, &details); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Details = json.RawMessage(details)
+		items = append(items, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+
+	return models.NewPaginatedResponse(items, total, limit, offset), nil
+}
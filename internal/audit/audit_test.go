@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+)
+
+func setupTestLogger(t *testing.T) (*Logger, *database.DB) {
+	t.Helper()
+
+	db := database.NewForTesting(t)
+	return NewLogger(db), db
+}
+
+func TestLogger_RecordsFailedBasicAuth(t *testing.T) {
+	logger, _ := setupTestLogger(t)
+
+	store := auth.NewCredentialStore(auth.Credentials{BasicUser: "admin", BasicPass: "secret123"})
+	store.SetAuditRecorder(logger)
+	handler := store.BasicAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(user, pass string) int {
+		req := httptest.NewRequest(http.MethodGet, "/web/sessions", nil)
+		req.RemoteAddr = "192.0.2.10:51234"
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := request("admin", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if code := request("admin", "secret123"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	page, err := logger.List(Filter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if page.Total != 1 {
+		t.Fatalf("expected only the failed attempt to be recorded, got %d entries", page.Total)
+	}
+
+	e := page.Items[0]
+	if e.EventType != EventAuthFailed || e.Actor != "user:admin" || e.IP != "192.0.2.10" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	var details map[string]string
+	if err := json.Unmarshal(e.Details, &details); err != nil {
+		t.Fatalf("details are not JSON: %v", err)
+	}
+	if details["reason"] != "invalid_basic_auth" || details["path"] != "/web/sessions" {
+		t.Errorf("unexpected details: %v", details)
+	}
+}
+
+func TestLogger_RecordsAuthenticatedActor(t *testing.T) {
+	logger, _ := setupTestLogger(t)
+
+	apiKey := "test-api-key-32-chars-minimum!!!"
+	store := auth.NewCredentialStore(auth.Credentials{APIKeys: []string{apiKey}})
+	handler := store.APIKeyMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Record(r, EventTagDeleted, map[string]interface{}{"tag_id": 1})
+	}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/tags/1", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	page, err := logger.List(Filter{}, 10, 0)
+	if err != nil || page.Total != 1 {
+		t.Fatalf("expected one entry, got %v (%v)", page, err)
+	}
+	if page.Items[0].Actor != "key:test..." {
+		t.Errorf("expected actor to be the key prefix, got %q", page.Items[0].Actor)
+	}
+}
+
+func TestLogger_ListFilters(t *testing.T) {
+	logger, db := setupTestLogger(t)
+
+	for _, row := range []struct{ ts, event string }{
+		{"2024-03-01T10:00:00Z", EventAuthFailed},
+		{"2024-03-02T10:00:00Z", EventAPIKeyCreated},
+		{"2024-03-03T10:00:00Z", EventAuthFailed},
+	} {
+		if _, err := db.Exec(`INSERT INTO audit_log (timestamp, event_type, actor, ip, details) VALUES (?, ?, '', '', '{}')`, row.ts, row.event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventType := EventAuthFailed
+	page, err := logger.List(Filter{EventType: &eventType}, 10, 0)
+	if err != nil || page.Total != 2 {
+		t.Fatalf("expected 2 auth failures, got %v (%v)", page, err)
+	}
+	if page.Items[0].Timestamp != "2024-03-03T10:00:00Z" {
+		t.Errorf("expected newest first, got %s", page.Items[0].Timestamp)
+	}
+
+	from, to := "2024-03-02T00:00:00Z", "2024-03-03T00:00:00Z"
+	page, err = logger.List(Filter{From: &from, To: &to}, 10, 0)
+	if err != nil || page.Total != 1 || page.Items[0].EventType != EventAPIKeyCreated {
+		t.Fatalf("expected one entry in range, got %v (%v)", page, err)
+	}
+
+	page, err = logger.List(Filter{}, 1, 1)
+	if err != nil || page.Total != 3 || len(page.Items) != 1 || page.Items[0].Timestamp != "2024-03-02T10:00:00Z" {
+		t.Fatalf("unexpected page: %v (%v)", page, err)
+	}
+}
+
+func TestLogger_BestEffort(t *testing.T) {
+	logger, db := setupTestLogger(t)
+	db.Close()
+
+	// Must not panic or fail the caller
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	logger.Record(req, EventAuthFailed, nil)
+
+	var nilLogger *Logger
+	nilLogger.Record(req, EventAuthFailed, nil)
+}
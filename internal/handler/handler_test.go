@@ -1,45 +1,29 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"time-tracker/internal/sessions"
 	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
 	"time-tracker/internal/shared/database"
 	"time-tracker/internal/shared/errors"
 	"time-tracker/internal/shared/health"
 )
 
-// setupTestDB creates a temporary database for testing.
-func setupTestDB(t *testing.T) (*database.DB, func()) {
-	t.Helper()
-
-	tmpFile, err := os.CreateTemp("", "handler_test_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	db, err := database.New(tmpFile.Name())
-	if err != nil {
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	cleanup := func() {
-		db.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return db, cleanup
-}
-
 // ============================================
 // Health Handler Tests
 // ============================================
@@ -47,9 +31,9 @@ func setupTestDB(t *testing.T) (*database.DB, func()) {
 // TestHealthHandler_Check tests GET /healthz endpoint.
 // **Validates: Requirements 6.1, 6.2**
 func TestHealthHandler_Check(t *testing.T) {
-	handler := health.NewHealthHandler()
+	handler := health.NewHealthHandler(nil)
 
-	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req := newRequest(http.MethodGet, "/healthz", nil)
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
@@ -69,9 +53,9 @@ func TestHealthHandler_Check(t *testing.T) {
 }
 
 func TestHealthHandler_MethodNotAllowed(t *testing.T) {
-	handler := health.NewHealthHandler()
+	handler := health.NewHealthHandler(nil)
 
-	req := httptest.NewRequest(http.MethodPost, "/healthz", nil)
+	req := newRequest(http.MethodPost, "/healthz", nil)
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
@@ -81,26 +65,189 @@ func TestHealthHandler_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+func TestHealthHandler_Ready(t *testing.T) {
+	// Needs a file on disk to delete
+	db, err := database.New(filepath.Join(t.TempDir(), "ready.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+	handler := health.NewHealthHandler(db)
+
+	ready := func() (int, health.HealthResponse) {
+		req := newRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var resp health.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := ready(); code != http.StatusOK || !resp.OK || resp.DB != "ok" {
+		t.Fatalf("expected healthy database, got %d %+v", code, resp)
+	}
+
+	// A deleted database file makes the instance unready
+	if err := os.Rename(db.Path(), db.Path()+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.OK || !strings.Contains(resp.DB, "no such file") {
+		t.Fatalf("expected missing file to be reported, got %d %+v", code, resp)
+	}
+	os.Rename(db.Path()+".moved", db.Path())
+
+	// So does a closed database, while liveness is unaffected
+	db.Close()
+	code, resp := ready()
+	if code != http.StatusServiceUnavailable || resp.OK || !strings.Contains(resp.DB, "closed") {
+		t.Fatalf("expected closed database to be reported, got %d %+v", code, resp)
+	}
+	req := newRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected liveness to stay ok, got %d", w.Code)
+	}
+}
+
+func TestHealthHandler_ReadyIntegrity(t *testing.T) {
+	handler := health.NewHealthHandler(database.NewForTesting(t))
+
+	ready := func() health.HealthResponse {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, "/readyz", nil))
+		var resp health.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %+v", w.Code, resp)
+		}
+		return resp
+	}
+
+	first := ready()
+	if first.Integrity != "ok" {
+		t.Errorf("integrity = %q, want ok", first.Integrity)
+	}
+	if _, err := time.Parse(time.RFC3339, first.IntegrityCheckedAt); err != nil {
+		t.Errorf("integrity_checked_at = %q: %v", first.IntegrityCheckedAt, err)
+	}
+
+	// The result is reused rather than checked on every request
+	time.Sleep(1100 * time.Millisecond)
+	if second := ready(); second.IntegrityCheckedAt != first.IntegrityCheckedAt {
+		t.Errorf("integrity rechecked at %s, want cached %s", second.IntegrityCheckedAt, first.IntegrityCheckedAt)
+	}
+}
+
+func TestHealthHandler_ReadyBackupCheck(t *testing.T) {
+	handler := health.NewHealthHandler(database.NewForTesting(t))
+	var backupErr error
+	handler.SetBackupCheck(func() error { return backupErr })
+
+	ready := func() (int, health.HealthResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, "/readyz", nil))
+		var resp health.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := ready(); code != http.StatusOK || !resp.OK || resp.Backup != "ok" {
+		t.Fatalf("expected healthy backups, got %d %+v", code, resp)
+	}
+
+	backupErr = fmt.Errorf("disk full")
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.OK || resp.DB != "ok" || resp.Backup != "disk full" {
+		t.Fatalf("expected backup failure to be reported, got %d %+v", code, resp)
+	}
+}
+
+func TestHealthHandler_ReadyRunningSessions(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler := health.NewHealthHandler(db)
+
+	ready := func() (int, health.HealthResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, "/readyz", nil))
+		var resp health.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := ready(); code != http.StatusOK || resp.Running != "ok" {
+		t.Fatalf("expected running sessions ok, got %d %+v", code, resp)
+	}
+
+	for _, stmt := range []string{
+		"DROP INDEX idx_sessions_single_running_per_user",
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'first', '2024-01-01T09:00:00Z', 'running')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'second', '2024-01-01T10:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.OK || resp.Running != "1 extra running sessions" {
+		t.Fatalf("expected extra running session to be reported, got %d %+v", code, resp)
+	}
+}
+
+func TestHealthHandler_Version(t *testing.T) {
+	handler := health.NewHealthHandler(nil)
+
+	req := newRequest(http.MethodGet, "/version", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, field := range []string{"version", "commit", "build_date", "go_version"} {
+		if s, ok := resp[field].(string); !ok || s == "" {
+			t.Errorf("expected non-empty string %s, got %v", field, resp[field])
+		}
+	}
+	if resp["version"] != "dev" {
+		t.Errorf("expected dev version without ldflags, got %v", resp["version"])
+	}
+	if uptime, ok := resp["uptime_sec"].(float64); !ok || uptime < 0 {
+		t.Errorf("expected numeric uptime_sec, got %v", resp["uptime_sec"])
+	}
+	if len(resp) != 5 {
+		t.Errorf("unexpected fields in %v", resp)
+	}
+}
+
 // ============================================
 // Sessions Handler Tests
 // ============================================
 
-func setupSessionsHandler(t *testing.T) (*SessionsHandler, func()) {
-	db, cleanup := setupTestDB(t)
+func setupSessionsHandler(t *testing.T) *SessionsHandler {
+	db := database.NewForTesting(t)
 	repo := sessions.NewSessionRepository(db)
 	svc := sessions.NewSessionService(repo)
-	handler := NewSessionsHandler(svc)
-	return handler, cleanup
+	return NewSessionsHandler(svc)
 }
 
 // TestSessionsHandler_Start tests POST /api/v1/sessions/start endpoint.
 // **Validates: Requirements 2.1**
 func TestSessionsHandler_Start(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	body := `{"category":"study","task":"reading"}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -132,12 +279,11 @@ func TestSessionsHandler_Start(t *testing.T) {
 // TestSessionsHandler_Start_Conflict tests conflict when session already running.
 // **Validates: Requirements 2.2**
 func TestSessionsHandler_Start_Conflict(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	// Start first session
 	body := `{"category":"study","task":"reading"}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w := httptest.NewRecorder()
 	handler.Start(w, req)
 
@@ -147,7 +293,7 @@ func TestSessionsHandler_Start_Conflict(t *testing.T) {
 
 	// Try to start second session
 	body = `{"category":"work","task":"coding"}`
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req = newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w = httptest.NewRecorder()
 	handler.Start(w, req)
 
@@ -163,26 +309,32 @@ func TestSessionsHandler_Start_Conflict(t *testing.T) {
 	if resp.Error.Code != "CONFLICT" {
 		t.Fatalf("expected error code 'CONFLICT', got %q", resp.Error.Code)
 	}
-	if resp.Error.CurrentSession == nil {
+	current := resp.Error.CurrentSession
+	if current == nil {
 		t.Fatal("expected current_session in conflict response")
 	}
+	if current.Category != "study" || current.Task != "reading" || current.Status != "running" {
+		t.Errorf("expected the running study/reading session, got %+v", current)
+	}
+	if current.ElapsedSec == nil || *current.ElapsedSec < 0 {
+		t.Errorf("expected a non-negative elapsed_sec, got %v", current.ElapsedSec)
+	}
 }
 
 // TestSessionsHandler_Stop tests POST /api/v1/sessions/stop endpoint.
 // **Validates: Requirements 2.3, 2.4**
 func TestSessionsHandler_Stop(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	// Start a session first
 	body := `{"category":"study","task":"reading"}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w := httptest.NewRecorder()
 	handler.Start(w, req)
 
 	// Stop the session with optional updates
 	body = `{"note":"completed chapter 1","mood":"good"}`
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/stop", strings.NewReader(body))
+	req = newRequest(http.MethodPost, "/api/v1/sessions/stop", strings.NewReader(body))
 	w = httptest.NewRecorder()
 	handler.Stop(w, req)
 
@@ -209,13 +361,97 @@ func TestSessionsHandler_Stop(t *testing.T) {
 	}
 }
 
+// TestSessionsHandler_StopClearsDetails tests that null clears a field on
+// stop, an omitted one is kept and an empty string is stored as given.
+func TestSessionsHandler_StopClearsDetails(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	body := `{"category":"study","task":"reading","note":"chapter 1","location":"library","mood":"good"}`
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Start(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body = `{"note":null,"mood":""}`
+	req = newRequest(http.MethodPost, "/api/v1/sessions/stop", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.Stop(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.SessionResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Note != nil {
+		t.Errorf("expected null to clear the note, got %q", *resp.Note)
+	}
+	if resp.Location == nil || *resp.Location != "library" {
+		t.Errorf("expected the omitted location to be kept, got %v", resp.Location)
+	}
+	if resp.Mood == nil || *resp.Mood != "" {
+		t.Errorf("expected an empty mood, got %v", resp.Mood)
+	}
+}
+
+// TestSessionsHandler_StopChunked tests that a body of unknown length, as
+// sent with chunked transfer encoding, is applied, and an empty one is no
+// updates.
+func TestSessionsHandler_StopChunked(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	for _, tt := range []struct {
+		name, body, wantNote string
+	}{
+		{"with updates", `{"note":"sent in chunks"}`, "sent in chunks"},
+		{"empty", ``, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(`{"category":"study","task":"reading"}`))
+			w := httptest.NewRecorder()
+			handler.Start(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+			}
+
+			// A reader httptest cannot size leaves ContentLength at -1
+			req = newRequest(http.MethodPost, "/api/v1/sessions/stop", io.MultiReader(strings.NewReader(tt.body)))
+			if req.ContentLength != -1 {
+				t.Fatalf("expected an unknown content length, got %d", req.ContentLength)
+			}
+			w = httptest.NewRecorder()
+			handler.Stop(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp models.SessionResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Status != "stopped" {
+				t.Fatalf("expected status 'stopped', got %q", resp.Status)
+			}
+			note := ""
+			if resp.Note != nil {
+				note = *resp.Note
+			}
+			if note != tt.wantNote {
+				t.Errorf("note = %q, want %q", note, tt.wantNote)
+			}
+		})
+	}
+}
+
 // TestSessionsHandler_Stop_NoRunning tests stopping when no session is running.
 // **Validates: Requirements 2.5**
 func TestSessionsHandler_Stop_NoRunning(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
+	req := newRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
 	w := httptest.NewRecorder()
 	handler.Stop(w, req)
 
@@ -236,11 +472,10 @@ func TestSessionsHandler_Stop_NoRunning(t *testing.T) {
 // TestSessionsHandler_Current tests GET /api/v1/sessions/current endpoint.
 // **Validates: Requirements 2.6**
 func TestSessionsHandler_Current(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	// Test when no session is running
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/current", nil)
+	req := newRequest(http.MethodGet, "/api/v1/sessions/current", nil)
 	w := httptest.NewRecorder()
 	handler.Current(w, req)
 
@@ -259,12 +494,12 @@ func TestSessionsHandler_Current(t *testing.T) {
 
 	// Start a session
 	body := `{"category":"study","task":"reading"}`
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req = newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w = httptest.NewRecorder()
 	handler.Start(w, req)
 
 	// Test when session is running
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions/current", nil)
+	req = newRequest(http.MethodGet, "/api/v1/sessions/current", nil)
 	w = httptest.NewRecorder()
 	handler.Current(w, req)
 
@@ -287,27 +522,26 @@ func TestSessionsHandler_Current(t *testing.T) {
 // TestSessionsHandler_List tests GET /api/v1/sessions endpoint.
 // **Validates: Requirements 2.7**
 func TestSessionsHandler_List(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	// Create and stop a session
 	body := `{"category":"study","task":"reading"}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w := httptest.NewRecorder()
 	handler.Start(w, req)
 
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
+	req = newRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
 	w = httptest.NewRecorder()
 	handler.Stop(w, req)
 
 	// Start another session (running)
 	body = `{"category":"work","task":"coding"}`
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req = newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w = httptest.NewRecorder()
 	handler.Start(w, req)
 
 	// List all sessions
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	req = newRequest(http.MethodGet, "/api/v1/sessions", nil)
 	w = httptest.NewRecorder()
 	handler.List(w, req)
 
@@ -327,27 +561,26 @@ func TestSessionsHandler_List(t *testing.T) {
 
 // TestSessionsHandler_List_StatusFilter tests status filtering.
 func TestSessionsHandler_List_StatusFilter(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	// Create and stop a session
 	body := `{"category":"study","task":"reading"}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w := httptest.NewRecorder()
 	handler.Start(w, req)
 
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
+	req = newRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
 	w = httptest.NewRecorder()
 	handler.Stop(w, req)
 
 	// Start another session (running)
 	body = `{"category":"work","task":"coding"}`
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req = newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w = httptest.NewRecorder()
 	handler.Start(w, req)
 
 	// Filter by status=running
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions?status=running", nil)
+	req = newRequest(http.MethodGet, "/api/v1/sessions?status=running", nil)
 	w = httptest.NewRecorder()
 	handler.List(w, req)
 
@@ -368,24 +601,76 @@ func TestSessionsHandler_List_StatusFilter(t *testing.T) {
 	}
 }
 
+// TestSessionsHandler_List_Sort tests the sort parameter.
+func TestSessionsHandler_List_Sort(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	for _, tt := range []struct {
+		query string
+		code  int
+	}{
+		{"sort=started_at", http.StatusOK},
+		{"sort=updated_at", http.StatusOK},
+		{"sort=task", http.StatusBadRequest},
+	} {
+		req := newRequest(http.MethodGet, "/api/v1/sessions?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		handler.List(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.query, tt.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestSessionsHandler_List_MaxPageSize tests that the page size cap can be raised.
+func TestSessionsHandler_List_MaxPageSize(t *testing.T) {
+	handler := setupSessionsHandler(t)
+	for i := 0; i < 12; i++ {
+		if _, err := handler.service.StartSession(&sessions.SessionStart{Category: "work", Task: fmt.Sprintf("task %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := handler.service.StopSession(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func() int {
+		req := newRequest(http.MethodGet, "/api/v1/sessions?limit=50", nil)
+		w := httptest.NewRecorder()
+		handler.List(w, req)
+		var resp models.PaginatedResponse[models.SessionResponse]
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return len(resp.Items)
+	}
+	if got := count(); got != config.MaxPageSize {
+		t.Errorf("default cap: got %d sessions, want %d", got, config.MaxPageSize)
+	}
+	handler.SetMaxPageSize(20)
+	if got := count(); got != 12 {
+		t.Errorf("raised cap: got %d sessions, want 12", got)
+	}
+}
+
 // TestSessionsHandler_ExportCSV tests GET /api/v1/sessions.csv endpoint.
 // **Validates: Requirements 3.2, 3.4, 3.5**
 func TestSessionsHandler_ExportCSV(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	// Create and stop a session
 	body := `{"category":"study","task":"reading"}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
 	w := httptest.NewRecorder()
 	handler.Start(w, req)
 
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
+	req = newRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
 	w = httptest.NewRecorder()
 	handler.Stop(w, req)
 
 	// Export CSV
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions.csv", nil)
+	req = newRequest(http.MethodGet, "/api/v1/sessions.csv", nil)
 	w = httptest.NewRecorder()
 	handler.ExportCSV(w, req)
 
@@ -412,9 +697,193 @@ func TestSessionsHandler_ExportCSV(t *testing.T) {
 	}
 }
 
+func TestSessionsHandler_ExportCSV_Columns(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(`{"category":"study","task":"reading"}`))
+	w := httptest.NewRecorder()
+	handler.Start(w, req)
+
+	req = newRequest(http.MethodGet, "/api/v1/sessions.csv?columns=task,id,status", nil)
+	w = httptest.NewRecorder()
+	handler.ExportCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	lines := strings.Split(strings.TrimSpace(string(w.Body.Bytes()[3:])), "\n")
+	if len(lines) != 2 || lines[0] != "task,id,status" || lines[1] != "reading,1,running" {
+		t.Fatalf("unexpected CSV: %q", lines)
+	}
+
+	// Unknown columns are rejected and listed
+	req = newRequest(http.MethodGet, "/api/v1/sessions.csv?columns=id,foo,bar", nil)
+	w = httptest.NewRecorder()
+	handler.ExportCSV(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var errResp errors.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if errResp.Error.Code != "VALIDATION_ERROR" || !strings.Contains(errResp.Error.Message, "foo, bar") {
+		t.Fatalf("unexpected error response: %+v", errResp)
+	}
+}
+
+func TestSessionsHandler_ExportCSV_Delimiter(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(`{"category":"study","task":"reading, chapter 3; notes"}`))
+	w := httptest.NewRecorder()
+	handler.Start(w, req)
+
+	req = newRequest(http.MethodGet, "/api/v1/sessions.csv?delimiter=semicolon&columns=id,task,status", nil)
+	w = httptest.NewRecorder()
+	handler.ExportCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "_semicolon.csv") {
+		t.Fatalf("expected filename to name the delimiter, got %q", disposition)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(w.Body.Bytes()[3:]))
+	reader.Comma = ';'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse semicolon CSV: %v", err)
+	}
+	if len(records) != 2 || len(records[0]) != 3 || records[1][1] != "reading, chapter 3; notes" {
+		t.Fatalf("unexpected records: %q", records)
+	}
+
+	// Tab-separated output
+	req = newRequest(http.MethodGet, "/api/v1/sessions.csv?delimiter=tab&columns=id,status", nil)
+	w = httptest.NewRecorder()
+	handler.ExportCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(w.Body.Bytes()[3:])), "\n"); lines[0] != "id\tstatus" {
+		t.Fatalf("unexpected tab CSV header: %q", lines[0])
+	}
+
+	// Unknown delimiters are rejected
+	req = newRequest(http.MethodGet, "/api/v1/sessions.csv?delimiter=pipe", nil)
+	w = httptest.NewRecorder()
+	handler.ExportCSV(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var errResp errors.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if errResp.Error.Code != "VALIDATION_ERROR" {
+		t.Fatalf("unexpected error response: %+v", errResp)
+	}
+}
+
+func TestSessionsHandler_ExportJSON(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	for _, body := range []string{`{"category":"study","task":"reading"}`, `{"category":"work","task":"coding"}`} {
+		req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.Start(w, req)
+
+		req = newRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
+		w = httptest.NewRecorder()
+		handler.Stop(w, req)
+	}
+
+	req := newRequest(http.MethodGet, "/api/v1/sessions.json?category=work&include_tags=true", nil)
+	w := httptest.NewRecorder()
+	handler.ExportJSON(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), ".json") {
+		t.Fatalf("expected JSON attachment, got %q", w.Header().Get("Content-Disposition"))
+	}
+
+	var items []models.SessionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+		t.Fatalf("failed to decode export: %v", err)
+	}
+	if len(items) != 1 || items[0].Category != "work" || items[0].DurationSec == nil {
+		t.Fatalf("unexpected export: %+v", items)
+	}
+
+	// Empty result is still a valid array
+	req = newRequest(http.MethodGet, "/api/v1/sessions.json?from=2000-01-01&to=2000-01-02", nil)
+	w = httptest.NewRecorder()
+	handler.ExportJSON(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil || len(items) != 0 {
+		t.Fatalf("expected empty array, got %q (%v)", w.Body.String(), err)
+	}
+
+	// Invalid bounds are rejected before streaming
+	req = newRequest(http.MethodGet, "/api/v1/sessions.json?from=yesterday", nil)
+	w = httptest.NewRecorder()
+	handler.ExportJSON(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestSessionsHandler_ExportNDJSON(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	for _, body := range []string{`{"category":"study","task":"reading"}`, `{"category":"work","task":"coding"}`} {
+		req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.Start(w, req)
+
+		req = newRequest(http.MethodPost, "/api/v1/sessions/stop", nil)
+		w = httptest.NewRecorder()
+		handler.Stop(w, req)
+	}
+
+	req := newRequest(http.MethodGet, "/api/v1/sessions.ndjson", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected Content-Type application/x-ndjson, got %q", ct)
+	}
+
+	body := w.Body.String()
+	if !strings.HasSuffix(body, "\n") {
+		t.Fatal("expected final line to be newline-terminated")
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), body)
+	}
+	for _, line := range lines {
+		var session models.SessionResponse
+		if err := json.Unmarshal([]byte(line), &session); err != nil {
+			t.Fatalf("line is not a JSON object: %q", line)
+		}
+		if session.Status != "stopped" {
+			t.Fatalf("unexpected session: %+v", session)
+		}
+	}
+}
+
 func TestSessionsHandler_ServeHTTP_Routing(t *testing.T) {
-	handler, cleanup := setupSessionsHandler(t)
-	defer cleanup()
+	handler := setupSessionsHandler(t)
 
 	tests := []struct {
 		method string
@@ -428,6 +897,8 @@ func TestSessionsHandler_ServeHTTP_Routing(t *testing.T) {
 		{http.MethodGet, "/api/v1/sessions.csv", "", http.StatusOK},
 		{http.MethodPost, "/api/v1/sessions/stop", "", http.StatusOK}, // Now has running session
 		{http.MethodGet, "/api/v1/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/sessions/start", "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/v1/sessions", "", http.StatusMethodNotAllowed},
 	}
 
 	for _, tt := range tests {
@@ -438,7 +909,7 @@ func TestSessionsHandler_ServeHTTP_Routing(t *testing.T) {
 			bodyReader = bytes.NewReader(nil)
 		}
 
-		req := httptest.NewRequest(tt.method, tt.path, bodyReader)
+		req := newRequest(tt.method, tt.path, bodyReader)
 		w := httptest.NewRecorder()
 		handler.ServeHTTP(w, req)
 
@@ -447,3 +918,253 @@ func TestSessionsHandler_ServeHTTP_Routing(t *testing.T) {
 		}
 	}
 }
+
+func TestSessionsHandler_MethodNotAllowed(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	for _, tt := range []struct {
+		method, path, allow string
+	}{
+		{http.MethodGet, "/api/v1/sessions/start", "POST"},
+		{http.MethodPut, "/api/v1/sessions/stop", "POST"},
+		{http.MethodPost, "/api/v1/sessions/current", "GET"},
+		{http.MethodPost, "/api/v1/sessions/stream", "GET"},
+		{http.MethodPost, "/api/v1/sessions.csv", "GET"},
+	} {
+		req := newRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status 405, got %d", tt.method, tt.path, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+		var resp errors.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error.Code != "METHOD_NOT_ALLOWED" {
+			t.Errorf("%s %s: expected code METHOD_NOT_ALLOWED, got %+v (%v)", tt.method, tt.path, resp, err)
+		}
+	}
+}
+
+func TestSessionsHandler_Start_StrictJSON(t *testing.T) {
+	handler := setupSessionsHandler(t)
+	handler.SetMaxBodyBytes(256)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+	}{
+		{"unknown field", `{"catagory":"study","task":"reading"}`, `unknown field "catagory"`},
+		{"oversized", `{"category":"study","task":"` + strings.Repeat("a", 300) + `"}`, "request body must not exceed 256 bytes"},
+		{"trailing garbage", `{"category":"study","task":"reading"}}`, "request body must contain a single JSON value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.Start(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Error struct{ Code, Message string }
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != "VALIDATION_ERROR" || resp.Error.Message != tt.wantMsg {
+				t.Errorf("expected VALIDATION_ERROR %q, got %s %q", tt.wantMsg, resp.Error.Code, resp.Error.Message)
+			}
+		})
+	}
+
+	// Nothing was started by the rejected requests
+	req := newRequest(http.MethodGet, "/api/v1/sessions/current", nil)
+	w := httptest.NewRecorder()
+	handler.Current(w, req)
+	if strings.Contains(w.Body.String(), "reading") {
+		t.Errorf("expected no running session, got %s", w.Body.String())
+	}
+}
+
+// TestSessionsHandler_Start_FieldErrors tests that every field failing
+// validation is reported, and that a single one keeps its plain message.
+func TestSessionsHandler_Start_FieldErrors(t *testing.T) {
+	handler := setupSessionsHandler(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantMsg    string
+		wantFields map[string]string
+	}{
+		{
+			"note and mood",
+			`{"category":"study","task":"reading","note":"` + strings.Repeat("n", models.NoteMaxLen+1) + `","mood":"` + strings.Repeat("m", models.MoodMaxLen+1) + `"}`,
+			models.ErrNoteTooLong.Error() + "; " + models.ErrMoodTooLong.Error(),
+			map[string]string{"note": models.ErrNoteTooLong.Error(), "mood": models.ErrMoodTooLong.Error()},
+		},
+		{
+			"mood only",
+			`{"category":"study","task":"reading","mood":"` + strings.Repeat("m", models.MoodMaxLen+1) + `"}`,
+			models.ErrMoodTooLong.Error(),
+			map[string]string{"mood": models.ErrMoodTooLong.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/api/v1/sessions/start", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.Start(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp errors.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != "VALIDATION_ERROR" || resp.Error.Message != tt.wantMsg {
+				t.Errorf("expected VALIDATION_ERROR %q, got %s %q", tt.wantMsg, resp.Error.Code, resp.Error.Message)
+			}
+			if len(resp.Error.Fields) != len(tt.wantFields) {
+				t.Fatalf("fields = %v, want %v", resp.Error.Fields, tt.wantFields)
+			}
+			for field, msg := range tt.wantFields {
+				if resp.Error.Fields[field] != msg {
+					t.Errorf("fields[%q] = %q, want %q", field, resp.Error.Fields[field], msg)
+				}
+			}
+		})
+	}
+}
+
+// readEvent reads the next event from a Server-Sent Events stream.
+func readEvent(t *testing.T, r *bufio.Reader) (name, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestSessionsHandler_Stream(t *testing.T) {
+	handler := setupSessionsHandler(t)
+	handler.SetStreamHeartbeat(time.Hour)
+	server := httptest.NewServer(asDefaultUser(handler))
+	// Close waits for the handler, so this also checks it ends on disconnect
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/sessions/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+
+	if name, data := readEvent(t, body); name != "snapshot" || data != `{"running":false}` {
+		t.Fatalf("first event = %s %s, want a snapshot of no session", name, data)
+	}
+
+	if _, err := handler.service.StartSession(&models.SessionStart{Category: "work", Task: "streamed"}); err != nil {
+		t.Fatal(err)
+	}
+	name, data := readEvent(t, body)
+	var current sessions.CurrentSessionResponse
+	if err := json.Unmarshal([]byte(data), &current); err != nil {
+		t.Fatalf("invalid event data %q: %v", data, err)
+	}
+	if name != "started" || !current.Running || current.Session.Task != "streamed" {
+		t.Errorf("second event = %s %s, want the started session", name, data)
+	}
+}
+
+func TestSessionsHandler_StreamHeartbeat(t *testing.T) {
+	handler := setupSessionsHandler(t)
+	handler.SetStreamHeartbeat(10 * time.Millisecond)
+	if _, err := handler.service.StartSession(&models.SessionStart{Category: "work", Task: "running"}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(asDefaultUser(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/sessions/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := bufio.NewReader(resp.Body)
+
+	readEvent(t, body)
+	name, data := readEvent(t, body)
+	var beat struct {
+		Running    bool   `json:"running"`
+		ElapsedSec *int64 `json:"elapsed_sec"`
+	}
+	if err := json.Unmarshal([]byte(data), &beat); err != nil {
+		t.Fatalf("invalid event data %q: %v", data, err)
+	}
+	if name != "heartbeat" || !beat.Running || beat.ElapsedSec == nil {
+		t.Errorf("second event = %s %s, want a heartbeat with elapsed_sec", name, data)
+	}
+
+	// Closing the events, as Shutdown does, ends the stream
+	handler.service.Events().Close()
+	if _, err := io.ReadAll(body); err != nil {
+		t.Errorf("stream did not end cleanly: %v", err)
+	}
+}
+
+func TestHeartbeat_FutureStart(t *testing.T) {
+	// A start ahead of the server clock, from clock skew or an edit, must
+	// not make the stream report a negative elapsed time
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	beat := heartbeat(&sessions.CurrentSessionResponse{
+		Running: true,
+		Session: &models.SessionResponse{StartedAt: future},
+	})
+	if beat.ElapsedSec == nil || *beat.ElapsedSec != 0 {
+		t.Errorf("elapsed_sec for a future start = %v, want 0", beat.ElapsedSec)
+	}
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	beat = heartbeat(&sessions.CurrentSessionResponse{
+		Running: true,
+		Session: &models.SessionResponse{StartedAt: past},
+	})
+	if beat.ElapsedSec == nil || *beat.ElapsedSec < 60 {
+		t.Errorf("elapsed_sec a minute after the start = %v, want at least 60", beat.ElapsedSec)
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
+}
+
+// asDefaultUser makes every request to h act for the default user, for tests
+// that reach it over a real connection.
+func asDefaultUser(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), database.DefaultUserID)))
+	})
+}
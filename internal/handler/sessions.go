@@ -2,8 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,46 +18,89 @@ import (
 	"time-tracker/internal/shared/errors"
 	"time-tracker/internal/shared/utils"
 	"time-tracker/internal/shared/validation"
+	"time-tracker/internal/shared/xlsx"
 )
 
 // SessionsHandler handles HTTP requests for session operations.
 type SessionsHandler struct {
-	service *sessions.SessionService
+	service         *sessions.SessionService
+	timezone        *time.Location
+	maxBodyBytes    int64
+	streamHeartbeat time.Duration
+	maxPageSize     int
+	logger          *slog.Logger
 }
 
 // NewSessionsHandler creates a new SessionsHandler.
 func NewSessionsHandler(svc *sessions.SessionService) *SessionsHandler {
-	return &SessionsHandler{service: svc}
+	return &SessionsHandler{
+		service:         svc,
+		timezone:        time.UTC,
+		maxBodyBytes:    config.MaxJSONBodyBytes,
+		streamHeartbeat: config.DefaultStreamHeartbeat,
+		maxPageSize:     config.MaxPageSize,
+		logger:          slog.Default(),
+	}
+}
+
+// SetTimezone sets the timezone used for date-time cells in spreadsheet exports.
+func (h *SessionsHandler) SetTimezone(tz *time.Location) {
+	if tz != nil {
+		h.timezone = tz
+	}
+}
+
+// SetMaxBodyBytes overrides the maximum size of a JSON request body.
+func (h *SessionsHandler) SetMaxBodyBytes(max int64) {
+	if max > 0 {
+		h.maxBodyBytes = max
+	}
+}
+
+// SetMaxPageSize overrides the largest limit accepted when listing sessions.
+func (h *SessionsHandler) SetMaxPageSize(max int) {
+	if max > 0 {
+		h.maxPageSize = max
+	}
+}
+
+// SetLogger sets the logger for failures after a response has started.
+func (h *SessionsHandler) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		h.logger = logger
+	}
+}
+
+// SetStreamHeartbeat sets how often the session stream sends the elapsed time.
+func (h *SessionsHandler) SetStreamHeartbeat(interval time.Duration) {
+	if interval > 0 {
+		h.streamHeartbeat = interval
+	}
 }
 
 // Start handles POST /api/v1/sessions/start - starts a new session.
 func (h *SessionsHandler) Start(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		errors.WriteError(w, errors.ValidationError("Method not allowed"))
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
 		return
 	}
 
 	var input models.SessionStart
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		errors.WriteError(w, errors.ValidationError("Invalid JSON body"))
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
 		return
 	}
 
-	session, err := h.service.StartSession(&input)
+	session, err := h.service.StartSessionContext(r.Context(), &input)
 	if err != nil {
 		// Check for conflict error (session already running)
 		if err == sessions.ErrSessionAlreadyRunning && session != nil {
-			conflictErr := errors.NewConflictError("A session is already running", map[string]interface{}{
-				"id":         session.ID,
-				"task":       session.Task,
-				"started_at": session.StartedAt,
-			})
-			errors.WriteError(w, conflictErr)
+			errors.WriteError(w, errors.NewConflictError("A session is already running", session))
 			return
 		}
 		// Check if it's a validation error
 		if strings.Contains(err.Error(), "validation error") {
-			errors.WriteError(w, errors.ValidationError(strings.TrimPrefix(err.Error(), "validation error: ")))
+			errors.WriteError(w, errors.ValidationErrorFor(err))
 			return
 		}
 		errors.WriteError(w, err)
@@ -68,21 +115,22 @@ func (h *SessionsHandler) Start(w http.ResponseWriter, r *http.Request) {
 // Stop handles POST /api/v1/sessions/stop - stops the current session.
 func (h *SessionsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		errors.WriteError(w, errors.ValidationError("Method not allowed"))
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
 		return
 	}
 
-	var input *models.SessionStop
-	// Body is optional for stop
-	if r.ContentLength > 0 {
-		input = &models.SessionStop{}
-		if err := json.NewDecoder(r.Body).Decode(input); err != nil {
-			errors.WriteError(w, errors.ValidationError("Invalid JSON body"))
+	// Body is optional for stop. A chunked body has no ContentLength, so
+	// it is decoded whatever the header says and an empty one means no updates
+	input := &models.SessionStop{}
+	if err := validation.DecodeJSON(w, r, input, h.maxBodyBytes); err != nil {
+		if !stderrors.Is(err, validation.ErrEmptyBody) {
+			errors.WriteError(w, errors.ValidationError(err.Error()))
 			return
 		}
+		input = nil
 	}
 
-	session, err := h.service.StopSession(input)
+	session, err := h.service.StopSessionContext(r.Context(), input)
 	if err != nil {
 		if err == sessions.ErrNoRunningSession {
 			errors.WriteError(w, errors.NotFoundError("No running session found"))
@@ -90,7 +138,7 @@ func (h *SessionsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 		}
 		// Check if it's a validation error
 		if strings.Contains(err.Error(), "validation error") {
-			errors.WriteError(w, errors.ValidationError(strings.TrimPrefix(err.Error(), "validation error: ")))
+			errors.WriteError(w, errors.ValidationErrorFor(err))
 			return
 		}
 		errors.WriteError(w, err)
@@ -104,11 +152,11 @@ func (h *SessionsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 // Current handles GET /api/v1/sessions/current - gets the current session status.
 func (h *SessionsHandler) Current(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		errors.WriteError(w, errors.ValidationError("Method not allowed"))
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
 		return
 	}
 
-	result, err := h.service.GetCurrent()
+	result, err := h.service.GetCurrentContext(r.Context())
 	if err != nil {
 		errors.WriteError(w, err)
 		return
@@ -118,38 +166,36 @@ func (h *SessionsHandler) Current(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// Stream handles GET /api/v1/sessions/stream - streams the current session
+// as Server-Sent Events.
+func (h *SessionsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	StreamSession(w, r, h.service, h.streamHeartbeat, h.logger)
+}
+
 // List handles GET /api/v1/sessions - retrieves paginated sessions.
 func (h *SessionsHandler) List(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		errors.WriteError(w, errors.ValidationError("Method not allowed"))
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
 		return
 	}
 
 	// Parse and sanitize query parameters
 	query := r.URL.Query()
 
-	limit, offset := utils.ParsePaginationParams(query, 10, config.MaxPageSize)
+	limit, offset := utils.ParsePaginationParams(query, config.DefaultPageSize, h.maxPageSize)
 
-	// Sanitize status filter
-	var status *string
-	if s := query.Get("status"); s != "" {
-		sanitized := validation.SanitizeString(s)
-		if sanitized != "" {
-			status = &sanitized
-		}
-	}
-
-	// Sanitize category filter
-	var category *string
-	if c := query.Get("category"); c != "" {
-		sanitized := validation.SanitizeString(c)
-		if sanitized != "" {
-			category = &sanitized
-		}
+	filter, err := h.parseSessionFilter(query)
+	if err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
 	}
 
-	result, err := h.service.GetSessions(limit, offset, status, category)
+	result, err := h.service.GetSessionsContext(r.Context(), limit, offset, filter)
 	if err != nil {
+		if strings.Contains(err.Error(), "validation error") {
+			errors.WriteError(w, errors.ValidationErrorFor(err))
+			return
+		}
 		errors.WriteError(w, err)
 		return
 	}
@@ -159,61 +205,240 @@ func (h *SessionsHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 // ExportCSV handles GET /api/v1/sessions.csv - exports sessions as CSV.
+// columns=id,task,... selects and orders the output columns;
+// delimiter=comma|semicolon|tab sets the field separator.
 func (h *SessionsHandler) ExportCSV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		errors.WriteError(w, errors.ValidationError("Method not allowed"))
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
 		return
 	}
 
-	// Parse and sanitize query parameters
 	query := r.URL.Query()
-
-	// Sanitize status filter
-	var status *string
-	if s := query.Get("status"); s != "" {
-		sanitized := validation.SanitizeString(s)
-		if sanitized != "" {
-			status = &sanitized
-		}
+	filter, err := h.parseSessionFilter(query)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
 	}
 
-	// Sanitize category filter
-	var category *string
-	if c := query.Get("category"); c != "" {
-		sanitized := validation.SanitizeString(c)
-		if sanitized != "" {
-			category = &sanitized
-		}
+	columns, err := sessions.ParseCSVColumns(query.Get("columns"))
+	if err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
 	}
 
-	csvData, err := h.service.ExportCSV(status, category)
+	delimiter := query.Get("delimiter")
+	comma, err := sessions.ParseCSVDelimiter(delimiter)
 	if err != nil {
-		errors.WriteError(w, err)
+		errors.WriteError(w, errors.ValidationError(err.Error()))
 		return
 	}
 
 	// Set headers for CSV download
 	filename := fmt.Sprintf("sessions_%s.csv", time.Now().Format("20060102"))
+	if delimiter != "" && delimiter != "comma" {
+		filename = fmt.Sprintf("sessions_%s_%s.csv", time.Now().Format("20060102"), delimiter)
+	}
 	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
-	w.Write(csvData)
+
+	// Write UTF-8 BOM for Excel compatibility
+	w.Write(utils.UTF8BOM)
+
+	// Headers are already sent once streaming starts, so failures can only be logged.
+	if err := h.service.ExportCSVToContext(r.Context(), w, filter, columns, comma); err != nil {
+		h.logger.Error("CSV export failed", "error", err)
+	}
+}
+
+// ExportJSON handles GET /api/v1/sessions.json - streams sessions as a JSON array.
+// Supports status, category, from, to and tag_id filters; include_tags=true adds tag names.
+func (h *SessionsHandler) ExportJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := h.parseSessionFilter(query)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
+	}
+	includeTags := query.Get("include_tags") == "true"
+
+	filename := fmt.Sprintf("sessions_%s.json", time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Headers are already sent once streaming starts, so failures can only be logged.
+	if err := h.service.ExportJSONContext(r.Context(), w, filter, includeTags); err != nil {
+		h.logger.Error("JSON export failed", "error", err)
+	}
+}
+
+// ExportNDJSON handles GET /api/v1/sessions.ndjson - streams sessions as
+// newline-delimited JSON. Accepts the same parameters as ExportJSON.
+func (h *SessionsHandler) ExportNDJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := h.parseSessionFilter(query)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
+	}
+	includeTags := query.Get("include_tags") == "true"
+
+	filename := fmt.Sprintf("sessions_%s.ndjson", time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// The status code is already sent, so a failure just ends the stream early;
+	// consumers detect it by the truncated final line.
+	if err := h.service.ExportNDJSONContext(r.Context(), w, filter, includeTags); err != nil {
+		h.logger.Error("NDJSON export failed", "error", err)
+	}
+}
+
+// ExportXLSX handles GET /api/v1/sessions.xlsx - exports sessions as an Excel workbook.
+// Supports the same status, category, from, to and tag_id filters as the JSON export.
+func (h *SessionsHandler) ExportXLSX(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	filter, err := h.parseSessionFilter(r.URL.Query())
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
+	}
+
+	filename := fmt.Sprintf("sessions_%s.xlsx", time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", xlsx.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Headers are already sent once streaming starts, so failures can only be logged.
+	if err := h.service.ExportXLSXContext(r.Context(), w, filter, h.timezone); err != nil {
+		h.logger.Error("XLSX export failed", "error", err)
+	}
+}
+
+// ExportICS handles GET /api/v1/sessions.ics - exports sessions as an iCalendar feed.
+// Supports status, category, from, to and tag_id filters; include_running=true emits
+// running sessions ending now instead of skipping them.
+func (h *SessionsHandler) ExportICS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := h.parseSessionFilter(query)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
+	}
+	includeRunning := query.Get("include_running") == "true"
+
+	filename := fmt.Sprintf("sessions_%s.ics", time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Headers are already sent once streaming starts, so failures can only be logged.
+	if err := h.service.ExportICSContext(r.Context(), w, filter, includeRunning, time.Now()); err != nil {
+		h.logger.Error("ICS export failed", "error", err)
+	}
+}
+
+// parseSessionFilter reads the sanitized status, category, from, to, tag_id
+// and sort query parameters. A relative range (e.g. range=this_month) is resolved in the
+// handler's timezone and cannot be combined with from/to.
+func (h *SessionsHandler) parseSessionFilter(query url.Values) (*models.SessionFilter, error) {
+	filter := &models.SessionFilter{
+		Status:   sanitizedParam(query, "status"),
+		Category: sanitizedParam(query, "category"),
+		From:     sanitizedParam(query, "from"),
+		To:       sanitizedParam(query, "to"),
+		Sort:     validation.SanitizeString(query.Get("sort")),
+	}
+
+	if name := sanitizedParam(query, "range"); name != nil {
+		if filter.From != nil || filter.To != nil {
+			return nil, fmt.Errorf("range cannot be combined with from or to")
+		}
+		from, to, err := validation.ResolveRange(*name, time.Now(), h.timezone)
+		if err != nil {
+			return nil, err
+		}
+		filter.From, filter.To = &from, &to
+	}
+
+	if tagStr := query.Get("tag_id"); tagStr != "" {
+		tagID, err := strconv.ParseInt(tagStr, 10, 64)
+		if err != nil {
+			return nil, models.ErrInvalidTagID
+		}
+		filter.TagID = &tagID
+	}
+
+	return filter, nil
+}
+
+// sanitizedParam returns the sanitized query parameter, or nil when it is empty.
+func sanitizedParam(query url.Values, key string) *string {
+	sanitized := validation.SanitizeString(query.Get(key))
+	if sanitized == "" {
+		return nil
+	}
+	return &sanitized
 }
 
-// ServeHTTP implements http.Handler for routing session requests.
+// ServeHTTP implements http.Handler for routing session requests. Each
+// handler checks the method itself, so a known path called with the wrong
+// method gets a 405.
 func (h *SessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 
 	switch {
-	case path == "/api/v1/sessions/start" && r.Method == http.MethodPost:
+	case path == "/api/v1/sessions/start":
 		h.Start(w, r)
-	case path == "/api/v1/sessions/stop" && r.Method == http.MethodPost:
+	case path == "/api/v1/sessions/stop":
 		h.Stop(w, r)
-	case path == "/api/v1/sessions/current" && r.Method == http.MethodGet:
+	case path == "/api/v1/sessions/current":
 		h.Current(w, r)
-	case path == "/api/v1/sessions" && r.Method == http.MethodGet:
+	case path == "/api/v1/sessions/stream":
+		h.Stream(w, r)
+	case path == "/api/v1/sessions":
 		h.List(w, r)
-	case path == "/api/v1/sessions.csv" && r.Method == http.MethodGet:
+	case path == "/api/v1/sessions.csv":
 		h.ExportCSV(w, r)
+	case path == "/api/v1/sessions.json":
+		h.ExportJSON(w, r)
+	case path == "/api/v1/sessions.ndjson":
+		h.ExportNDJSON(w, r)
+	case path == "/api/v1/sessions.xlsx":
+		h.ExportXLSX(w, r)
+	case path == "/api/v1/sessions.ics":
+		h.ExportICS(w, r)
 	default:
 		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
 	}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/errors"
+)
+
+// heartbeatData is the payload of a heartbeat event.
+type heartbeatData struct {
+	Running    bool   `json:"running"`
+	ElapsedSec *int64 `json:"elapsed_sec,omitempty"`
+}
+
+// StreamSession serves the current session as Server-Sent Events: first a
+// "snapshot" event with the current session, then a "heartbeat" event with
+// the elapsed time every interval, and after each change to the user's
+// sessions an event named for it (started, stopped, updated or deleted) with
+// the current session as it is now. It returns when the client disconnects or the service's events are
+// closed on shutdown. Failures after the response has started go to logger.
+func StreamSession(w http.ResponseWriter, r *http.Request, svc *sessions.SessionService, interval time.Duration, logger *slog.Logger) {
+	if r.Method != http.MethodGet {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		return
+	}
+
+	userID, err := auth.RequireUserID(r.Context())
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+
+	// Subscribe before the snapshot so no change falls between the two
+	events, unsubscribe := svc.Events().Subscribe()
+	defer unsubscribe()
+
+	current, err := svc.GetCurrentContext(r.Context())
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep reverse proxies such as nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if writeEvent(w, rc, "snapshot", current) != nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.UserID != userID {
+				continue
+			}
+			current, err = svc.GetCurrentContext(r.Context())
+			if err != nil {
+				if r.Context().Err() == nil {
+					logger.Error("session stream: failed to get current session", "error", err)
+				}
+				return
+			}
+			if writeEvent(w, rc, event.Type, current) != nil {
+				return
+			}
+		case <-ticker.C:
+			if writeEvent(w, rc, "heartbeat", heartbeat(current)) != nil {
+				return
+			}
+		}
+	}
+}
+
+// heartbeat returns the running state of current with the elapsed time
+// worked out from its start, so heartbeats do not query the database. Like
+// the current session endpoint it reports zero for a start in the future.
+func heartbeat(current *sessions.CurrentSessionResponse) heartbeatData {
+	if !current.Running || current.Session == nil {
+		return heartbeatData{}
+	}
+	data := heartbeatData{Running: true}
+	if elapsed, err := sessions.ElapsedSince(current.Session.StartedAt); err == nil {
+		data.ElapsedSec = &elapsed
+	}
+	return data
+}
+
+// writeEvent writes one event with data encoded as JSON and flushes it. An
+// error means the client is gone.
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/errors"
+)
+
+// ImportHandler handles HTTP requests for importing sessions.
+type ImportHandler struct {
+	importer *Importer
+	timezone *time.Location
+	audit    *audit.Logger
+}
+
+// NewImportHandler creates a new ImportHandler.
+func NewImportHandler(im *Importer) *ImportHandler {
+	return &ImportHandler{importer: im, timezone: time.UTC}
+}
+
+// SetAudit records imports to the audit log.
+func (h *ImportHandler) SetAudit(logger *audit.Logger) {
+	h.audit = logger
+}
+
+// SetTimezone sets the timezone used when the request does not pass tz.
+func (h *ImportHandler) SetTimezone(tz *time.Location) {
+	if tz != nil {
+		h.timezone = tz
+	}
+}
+
+// Import handles POST /api/v1/sessions/import?format=toggl - imports a CSV file
+// sent as the request body. tz names the timezone of the file's local times.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
+		return
+	}
+
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "toggl" {
+		errors.WriteError(w, errors.ValidationError("format must be one of: toggl"))
+		return
+	}
+
+	loc := h.timezone
+	if tz := query.Get("tz"); tz != "" {
+		parsed, err := time.LoadLocation(tz)
+		if err != nil {
+			errors.WriteError(w, errors.ValidationError("invalid tz: "+tz))
+			return
+		}
+		loc = parsed
+	}
+
+	body := http.MaxBytesReader(w, r.Body, config.MaxImportBytes)
+	result, err := h.importer.ImportTogglContext(r.Context(), body, loc)
+	if err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	h.audit.Record(r, audit.EventSessionsImported, map[string]interface{}{"format": "toggl", "imported": result.Imported})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ServeHTTP implements http.Handler for the import endpoint.
+func (h *ImportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/sessions/import" {
+		h.Import(w, r)
+		return
+	}
+	errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+}
@@ -0,0 +1,107 @@
+// Package importer brings sessions recorded in other time trackers into the database.
+package importer
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/sessions/repository"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/tags"
+)
+
+// RowError reports why a line of an imported file was skipped.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportResult summarizes an import.
+type ImportResult struct {
+	Imported int        `json:"imported"`
+	Errors   []RowError `json:"errors"`
+}
+
+// Importer creates sessions and tags from external exports, owned by the
+// user the context acts for.
+type Importer struct {
+	sessions *repository.SessionRepository
+	tags     *tags.TagService
+	defaults models.Defaults
+}
+
+// NewImporter creates a new Importer.
+func NewImporter(sessionRepo *repository.SessionRepository, tagService *tags.TagService) *Importer {
+	return &Importer{sessions: sessionRepo, tags: tagService, defaults: models.BuiltinDefaults}
+}
+
+// SetDefaults sets the category and task given to imported rows without
+// one, normally those of the session service.
+func (im *Importer) SetDefaults(defaults models.Defaults) {
+	im.defaults = defaults
+}
+
+// ImportToggl imports a Toggl detailed report CSV, interpreting its local
+// dates and times in loc. Tags are created by name when missing.
+// Invalid rows are skipped and reported; valid rows are imported.
+func (im *Importer) ImportToggl(r io.Reader, loc *time.Location) (*ImportResult, error) {
+	return im.ImportTogglContext(auth.DefaultUserContext(), r, loc)
+}
+
+// ImportTogglContext is like ImportToggl but takes a context carrying the
+// user the sessions and tags are imported for.
+func (im *Importer) ImportTogglContext(ctx context.Context, r io.Reader, loc *time.Location) (*ImportResult, error) {
+	rows, rowErrors, err := ParseToggl(r, loc, im.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Errors: rowErrors}
+	for i := range rows {
+		if err := im.importRow(ctx, &rows[i]); err != nil {
+			result.Errors = append(result.Errors, RowError{
+				Line:    rows[i].Line,
+				Message: strings.TrimPrefix(err.Error(), "validation error: "),
+			})
+			continue
+		}
+		result.Imported++
+	}
+
+	return result, nil
+}
+
+func (im *Importer) importRow(ctx context.Context, row *TogglRow) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	tagIDs := make([]int64, 0, len(row.Tags))
+	for _, name := range row.Tags {
+		tag, err := im.tags.EnsureByNameContext(ctx, name)
+		if err != nil {
+			return err
+		}
+		if tag.Archived {
+			return tags.ErrTagArchived
+		}
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	session, err := im.sessions.CreateStoppedContext(ctx, userID, &row.Session,
+		models.FormatRFC3339(row.StartedAt),
+		models.FormatRFC3339(row.EndedAt),
+		models.RoundSeconds(row.EndedAt.Sub(row.StartedAt)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(tagIDs) > 0 {
+		return im.tags.AssignToSessionContext(ctx, session.ID, tagIDs)
+	}
+	return nil
+}
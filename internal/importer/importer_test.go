@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/sessions/repository"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/tags"
+)
+
+func TestImportHandler_Toggl(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	sessionRepo := repository.NewSessionRepository(db)
+	tagService := tags.NewTagService(tags.NewTagRepository(db))
+	handler := NewImportHandler(NewImporter(sessionRepo, tagService))
+
+	fixture, err := os.Open("testdata/toggl_detailed.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	req := newRequest(http.MethodPost, "/api/v1/sessions/import?format=toggl&tz=Asia/Shanghai", fixture)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result ImportResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if result.Imported != 3 {
+		t.Fatalf("expected 3 imported rows, got %d (%+v)", result.Imported, result.Errors)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Line != 4 || result.Errors[1].Line != 5 {
+		t.Fatalf("expected errors on lines 4 and 5, got %+v", result.Errors)
+	}
+
+	sessions, err := sessionRepo.List(database.DefaultUserID, 10, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTask := map[string]models.SessionResponse{}
+	for _, s := range sessions {
+		byTask[s.Task] = s
+	}
+
+	review := byTask["Design review"]
+	if review.Category != "Website" || review.StartedAt != "2024-03-01T01:00:00.000Z" ||
+		review.DurationSec == nil || *review.DurationSec != 5400 || review.DurationMs == nil || *review.DurationMs != 5400000 || review.Status != "stopped" {
+		t.Fatalf("unexpected imported session: %+v", review)
+	}
+	reviewTags, err := tagService.ListForSession(review.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviewTags) != 2 {
+		t.Fatalf("expected 2 tags on imported session, got %+v", reviewTags)
+	}
+
+	// Entries without a project fall back to the default category
+	if byTask["Reading"].Category == "" {
+		t.Fatal("expected default category for entry without project")
+	}
+
+	// Entries spanning midnight keep their real end date
+	deploy := byTask["Late night, deploy"]
+	if deploy.EndedAt == nil || *deploy.EndedAt != "2024-03-02T16:45:00.000Z" {
+		t.Fatalf("unexpected end for overnight entry: %+v", deploy)
+	}
+
+	// Tags are created once by name
+	all, err := tagService.List(tags.TagFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected tags client and design, got %+v", all)
+	}
+}
+
+func TestImportHandler_Validation(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	handler := NewImportHandler(NewImporter(repository.NewSessionRepository(db), tags.NewTagService(tags.NewTagRepository(db))))
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+	}{
+		{"unknown format", "/api/v1/sessions/import?format=clockify", "Description\n"},
+		{"invalid tz", "/api/v1/sessions/import?format=toggl&tz=Mars/Base", "Description\n"},
+		{"not a toggl file", "/api/v1/sessions/import?format=toggl", "id,category,task\n1,a,b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
+}
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"time-tracker/internal/sessions/models"
+)
+
+// TogglRow is one time entry parsed from a Toggl detailed report.
+type TogglRow struct {
+	Line      int
+	Session   models.SessionStart
+	StartedAt time.Time
+	EndedAt   time.Time
+	Tags      []string
+}
+
+// togglRequired are the columns without which a row cannot be imported.
+var togglRequired = []string{"start date", "start time", "end date", "end time"}
+
+// ParseToggl reads a Toggl "detailed report" CSV export. The header row is
+// located automatically and other columns (User, Email, Duration, ...) are
+// ignored. Dates and times are interpreted in loc, and rows without a project
+// or description get the category or task in defaults.
+// Rows that cannot be parsed are reported as RowErrors with their line number
+// instead of aborting the whole file.
+func ParseToggl(r io.Reader, loc *time.Location, defaults models.Defaults) ([]TogglRow, []RowError, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	for _, name := range togglRequired {
+		if _, ok := index[name]; !ok {
+			return nil, nil, fmt.Errorf("missing %q column, expected a Toggl detailed report", name)
+		}
+	}
+
+	rows := []TogglRow{}
+	rowErrors := []RowError{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row, err := parseTogglRecord(field, loc, defaults)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		row.Line = line
+		rows = append(rows, *row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+func parseTogglRecord(field func(string) string, loc *time.Location, defaults models.Defaults) (*TogglRow, error) {
+	start, err := parseTogglTime(field("start date"), field("start time"), loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseTogglTime(field("end date"), field("end time"), loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end is before start")
+	}
+
+	row := &TogglRow{
+		Session: models.SessionStart{
+			Category: field("project"),
+			Task:     field("description"),
+		},
+		StartedAt: start,
+		EndedAt:   end,
+	}
+	if err := row.Session.ValidateWithDefaults(defaults); err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(field("tags"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			row.Tags = append(row.Tags, name)
+		}
+	}
+
+	return row, nil
+}
+
+// parseTogglTime combines Toggl's separate date and time columns.
+func parseTogglTime(date, clock string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, date+" "+clock, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q %q is not a YYYY-MM-DD HH:MM:SS date and time", date, clock)
+}
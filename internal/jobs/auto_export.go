@@ -0,0 +1,195 @@
+// Package jobs provides background tasks that run alongside the HTTP server.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/users"
+)
+
+// AutoExporter periodically writes a full CSV export of every user's sessions
+// to a directory, one file per user, keeping the newest retain files of each.
+// The default user's file is sessions_YYYYMMDD.csv, as before there were
+// users, and any other user's is sessions_user<ID>_YYYYMMDD.csv.
+type AutoExporter struct {
+	service  *sessions.SessionService
+	users    *users.UserService
+	dir      string
+	schedule *schedule
+	retain   int
+	timezone *time.Location
+	logger   *slog.Logger
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewAutoExporter creates an AutoExporter and starts it. The first export runs
+// immediately, then once per interval, until ctx is cancelled or Stop is called.
+// A nil logger logs to slog.Default().
+func NewAutoExporter(ctx context.Context, svc *sessions.SessionService, userSvc *users.UserService, dir string, interval time.Duration, retain int, tz *time.Location, logger *slog.Logger) *AutoExporter {
+	if tz == nil {
+		tz = time.UTC
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	e := &AutoExporter{
+		service:  svc,
+		users:    userSvc,
+		dir:      dir,
+		schedule: newSchedule(interval),
+		retain:   retain,
+		timezone: tz,
+		logger:   logger,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go e.run(ctx)
+	return e
+}
+
+// run exports on every tick until ctx is done. Failures are logged and
+// retried on the next tick.
+func (e *AutoExporter) run(ctx context.Context) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.schedule.get())
+	defer ticker.Stop()
+	for {
+		if paths, err := e.ExportNow(); err != nil {
+			e.logger.Error("auto export failed", "error", err)
+		} else {
+			e.logger.Info("auto export written", "paths", paths)
+		}
+
+		if !e.schedule.wait(ctx, ticker) {
+			return
+		}
+	}
+}
+
+// ExportNow writes today's export for every user and prunes old files,
+// returning the paths written. Each file is written under a temporary name and
+// renamed into place, so a failed export never leaves a truncated snapshot
+// behind.
+func (e *AutoExporter) ExportNow() ([]string, error) {
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	list, err := e.users.List()
+	if err != nil {
+		return nil, err
+	}
+
+	date := time.Now().In(e.timezone).Format("20060102")
+	paths := []string{}
+	for _, u := range list {
+		path, err := e.exportUser(u.ID, date)
+		if err != nil {
+			return paths, fmt.Errorf("user %d: %w", u.ID, err)
+		}
+		paths = append(paths, path)
+		if err := pruneOldest(e.dir, autoExportPattern(u.ID), e.retain); err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
+
+// exportUser writes the export of one user's sessions for the given date.
+func (e *AutoExporter) exportUser(userID int64, date string) (string, error) {
+	name := fmt.Sprintf("sessions_%s.csv", date)
+	if userID != database.DefaultUserID {
+		name = fmt.Sprintf("sessions_user%d_%s.csv", userID, date)
+	}
+	path := filepath.Join(e.dir, name)
+
+	tmp, err := os.CreateTemp(e.dir, ".sessions_*.csv.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(utils.UTF8BOM); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	ctx := auth.WithUserID(context.Background(), userID)
+	if err := e.service.ExportCSVToContext(ctx, tmp, nil, nil, ','); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to move export into place: %w", err)
+	}
+	return path, nil
+}
+
+// autoExportPattern matches the files AutoExporter writes for a user, so
+// pruning never touches another user's exports or anything else in the
+// directory.
+func autoExportPattern(userID int64) *regexp.Regexp {
+	if userID == database.DefaultUserID {
+		return regexp.MustCompile(`^sessions_\d{8}\.csv$`)
+	}
+	return regexp.MustCompile(fmt.Sprintf(`^sessions_user%d_\d{8}\.csv$`, userID))
+}
+
+// pruneOldest removes the files in dir matching pattern beyond the newest
+// keep, relying on the names sorting chronologically. keep <= 0 keeps all.
+func pruneOldest(dir string, pattern *regexp.Regexp, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && pattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) <= keep {
+		return nil
+	}
+
+	sort.Strings(names)
+	for _, name := range names[:len(names)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to prune %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SetInterval changes the time between exports. The next one runs a full new
+// interval from now.
+func (e *AutoExporter) SetInterval(interval time.Duration) {
+	e.schedule.set(interval)
+}
+
+// Stop ends the export loop and waits for a running export to finish.
+// It may be called more than once.
+func (e *AutoExporter) Stop() {
+	e.cancel()
+	<-e.done
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/users"
+)
+
+func setupTestService(t *testing.T) *sessions.SessionService {
+	t.Helper()
+	svc, _ := setupTestServices(t)
+	return svc
+}
+
+// setupTestServices returns session and user services sharing one database.
+func setupTestServices(t *testing.T) (*sessions.SessionService, *users.UserService) {
+	t.Helper()
+
+	db := database.NewForTesting(t)
+
+	return sessions.NewSessionService(sessions.NewSessionRepository(db)), users.NewUserService(users.NewUserRepository(db), nil)
+}
+
+func TestAutoExporter_WritesCSV(t *testing.T) {
+	svc, userSvc := setupTestServices(t)
+	if _, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "report"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "exports")
+	e := NewAutoExporter(context.Background(), svc, userSvc, dir, time.Hour, 7, time.UTC, nil)
+	e.Stop()
+
+	path := filepath.Join(dir, "sessions_"+time.Now().UTC().Format("20060102")+".csv")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected export file: %v", err)
+	}
+	if !bytes.HasPrefix(data, utils.UTF8BOM) {
+		t.Fatal("expected export to start with a UTF-8 BOM")
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data[len(utils.UTF8BOM):])).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 2 || records[1][2] != "report" {
+		t.Fatalf("unexpected export contents: %v", records)
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only the export in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestAutoExporter_Prune(t *testing.T) {
+	svc, userSvc := setupTestServices(t)
+	dir := t.TempDir()
+
+	for _, name := range []string{"sessions_20200101.csv", "sessions_20200102.csv", "sessions_20200103.csv", "notes.csv", "sessions_user2_20200101.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := &AutoExporter{service: svc, users: userSvc, dir: dir, retain: 2, timezone: time.UTC}
+	paths, err := e.ExportNow()
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("ExportNow = %v, %v; want one file", paths, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	// Another user's files are left to that user's retention
+	expected := []string{"notes.csv", "sessions_20200103.csv", "sessions_user2_20200101.csv", filepath.Base(paths[0])}
+	sort.Strings(expected)
+	if len(names) != len(expected) {
+		t.Fatalf("expected %v after pruning, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("expected %v after pruning, got %v", expected, names)
+		}
+	}
+}
+
+func TestAutoExporter_EveryUser(t *testing.T) {
+	svc, userSvc := setupTestServices(t)
+	partner, err := userSvc.Create(&users.UserCreate{Name: "partner", Password: "hunter22"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "mine"}); err != nil {
+		t.Fatal(err)
+	}
+	partnerCtx := auth.WithUserID(context.Background(), partner.ID)
+	if _, err := svc.StartSessionContext(partnerCtx, &models.SessionStart{Category: "work", Task: "theirs"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	e := &AutoExporter{service: svc, users: userSvc, dir: dir, retain: 7, timezone: time.UTC}
+	paths, err := e.ExportNow()
+	if err != nil {
+		t.Fatalf("ExportNow failed: %v", err)
+	}
+
+	date := time.Now().UTC().Format("20060102")
+	want := map[string]string{
+		"sessions_" + date + ".csv": "mine",
+		"sessions_user" + strconv.FormatInt(partner.ID, 10) + "_" + date + ".csv": "theirs",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("ExportNow wrote %v, want %d files", paths, len(want))
+	}
+	for name, task := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		records, err := csv.NewReader(bytes.NewReader(data[len(utils.UTF8BOM):])).ReadAll()
+		if err != nil || len(records) != 2 || records[1][2] != task {
+			t.Errorf("%s = %v, %v; want only the session %q", name, records, err, task)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+// backupName matches the files written by AutoBackup, so pruning never
+// touches anything else in the directory.
+var backupName = regexp.MustCompile(`^timelog_\d{8}_\d{6}\.db$`)
+
+// AutoBackup periodically snapshots the database into a directory as
+// timelog_YYYYMMDD_HHMMSS.db (UTC), keeping the newest keep files.
+type AutoBackup struct {
+	db       *database.DB
+	dir      string
+	schedule *schedule
+	keep     int
+	logger   *slog.Logger
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewAutoBackup creates an AutoBackup and starts it. The first backup runs
+// immediately, then once per interval, until ctx is cancelled or Stop is called.
+// A nil logger logs to slog.Default().
+func NewAutoBackup(ctx context.Context, db *database.DB, dir string, interval time.Duration, keep int, logger *slog.Logger) *AutoBackup {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	b := &AutoBackup{
+		db:       db,
+		dir:      dir,
+		schedule: newSchedule(interval),
+		keep:     keep,
+		logger:   logger,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go b.run(ctx)
+	return b
+}
+
+// run backs up on every tick until ctx is done. Failures are logged, kept for
+// Err and retried on the next tick.
+func (b *AutoBackup) run(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.schedule.get())
+	defer ticker.Stop()
+	for {
+		path, err := b.BackupNow(ctx)
+		if ctx.Err() != nil {
+			// Interrupted by shutdown, not a failure
+			return
+		}
+		b.mu.Lock()
+		b.lastErr = err
+		b.mu.Unlock()
+		if err != nil {
+			b.logger.Error("database backup failed", "error", err)
+		} else {
+			b.logger.Info("database backup written", "path", path)
+		}
+
+		if !b.schedule.wait(ctx, ticker) {
+			return
+		}
+	}
+}
+
+// BackupNow writes a snapshot of the database and prunes old backups.
+// The snapshot is written under a temporary name and renamed into place, so
+// an interrupted backup never leaves a partial file behind.
+func (b *AutoBackup) BackupNow(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := fmt.Sprintf("timelog_%s.db", time.Now().UTC().Format("20060102_150405"))
+	path := filepath.Join(b.dir, name)
+
+	// VACUUM INTO refuses to overwrite, so clear any leftover from a crash
+	tmp := filepath.Join(b.dir, "."+name+".tmp")
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+
+	if err := b.db.SnapshotTo(ctx, tmp); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to move backup into place: %w", err)
+	}
+
+	if err := pruneOldest(b.dir, backupName, b.keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// Err returns the error of the most recent scheduled backup, or nil if it
+// succeeded.
+func (b *AutoBackup) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// SetInterval changes the time between backups. The next one runs a full new
+// interval from now.
+func (b *AutoBackup) SetInterval(interval time.Duration) {
+	b.schedule.set(interval)
+}
+
+// Stop ends the backup loop, interrupting a running backup, and waits for it
+// to return. It may be called more than once.
+func (b *AutoBackup) Stop() {
+	b.cancel()
+	<-b.done
+}
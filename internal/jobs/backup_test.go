@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+// setupBackupDB creates a file-backed database with count stopped sessions.
+func setupBackupDB(t *testing.T, count int) *database.DB {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "backup_test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for i := 0; i < count; i++ {
+		if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'task', '2024-03-01T10:00:00Z', 'stopped')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// listDir returns the sorted names in dir.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestAutoBackup_WritesSnapshots(t *testing.T) {
+	db := setupBackupDB(t, 5)
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	b := NewAutoBackup(context.Background(), db, dir, 20*time.Millisecond, 2, nil)
+
+	// Let several backups run while the database stays writable
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if names, _ := os.ReadDir(dir); len(names) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no backup was written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'late', '2024-03-02T10:00:00Z', 'stopped')`); err != nil {
+		t.Fatalf("database not writable during backups: %v", err)
+	}
+	b.Stop()
+
+	if err := b.Err(); err != nil {
+		t.Fatalf("expected backups to succeed, got %v", err)
+	}
+
+	names := listDir(t, dir)
+	if len(names) == 0 || len(names) > 2 {
+		t.Fatalf("expected 1 or 2 backups, got %v", names)
+	}
+	for _, name := range names {
+		if !backupName.MatchString(name) {
+			t.Fatalf("unexpected file %q in backup directory", name)
+		}
+	}
+
+	restored, err := database.New(filepath.Join(dir, names[0]))
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	defer restored.Close()
+
+	var count int
+	if err := restored.QueryRow("SELECT COUNT(*) FROM sessions WHERE task = 'task'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("expected 5 sessions in backup, got %d", count)
+	}
+}
+
+func TestAutoBackup_Prune(t *testing.T) {
+	db := setupBackupDB(t, 1)
+	dir := t.TempDir()
+
+	old := []string{"timelog_20200101_000000.db", "timelog_20200102_000000.db", "timelog_20200103_000000.db"}
+	for _, name := range append(old, "keep-me.db") {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := &AutoBackup{db: db, dir: dir, keep: 2}
+	path, err := b.BackupNow(context.Background())
+	if err != nil {
+		t.Fatalf("BackupNow failed: %v", err)
+	}
+
+	expected := []string{"keep-me.db", "timelog_20200103_000000.db", filepath.Base(path)}
+	sort.Strings(expected)
+	if names := listDir(t, dir); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v after pruning, got %v", expected, names)
+	}
+}
+
+func TestAutoBackup_ReportsFailure(t *testing.T) {
+	db := setupBackupDB(t, 1)
+
+	// A file where the directory should be makes every backup fail
+	dir := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(dir, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewAutoBackup(context.Background(), db, dir, time.Hour, 2, nil)
+	defer b.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the failed backup to be reported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAutoBackup_StopInterruptsCleanly(t *testing.T) {
+	db := setupBackupDB(t, 1)
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewAutoBackup(ctx, db, dir, time.Hour, 2, nil)
+	cancel()
+	b.Stop()
+
+	// Whether or not the first backup finished, no temporary file remains
+	// and cancellation is not reported as a failure
+	for _, name := range listDir(t, dir) {
+		if !backupName.MatchString(name) {
+			t.Fatalf("unexpected file %q left behind", name)
+		}
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("expected no error after cancellation, got %v", err)
+	}
+}
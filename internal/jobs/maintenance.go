@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+// MaintenanceReport describes one maintenance run.
+type MaintenanceReport struct {
+	StartedAt  time.Time                  `json:"started_at"`
+	Checkpoint *database.CheckpointResult `json:"checkpoint,omitempty"`
+	Vacuum     *database.VacuumResult     `json:"vacuum,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+}
+
+// Maintenance keeps the database file in shape: every interval it truncates
+// the WAL, and every vacuumEvery it also runs an incremental vacuum. Runs go
+// through the shared connection, so they queue behind requests instead of
+// competing with them.
+type Maintenance struct {
+	db          *database.DB
+	interval    time.Duration
+	vacuumEvery time.Duration
+	logger      *slog.Logger
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	// runMu serializes scheduled and manual runs
+	runMu      sync.Mutex
+	mu         sync.Mutex
+	last       *MaintenanceReport
+	lastVacuum time.Time
+}
+
+// NewMaintenance creates a Maintenance and starts it. The first run is one
+// interval after start. vacuumEvery <= 0 disables the scheduled vacuum.
+// A nil logger logs to slog.Default().
+func NewMaintenance(ctx context.Context, db *database.DB, interval, vacuumEvery time.Duration, logger *slog.Logger) *Maintenance {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m := &Maintenance{
+		db:          db,
+		interval:    interval,
+		vacuumEvery: vacuumEvery,
+		logger:      logger,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		lastVacuum:  time.Now(),
+	}
+	go m.run(ctx)
+	return m
+}
+
+// run performs maintenance on every tick until ctx is done.
+func (m *Maintenance) run(ctx context.Context) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		m.mu.Lock()
+		vacuum := m.vacuumEvery > 0 && time.Since(m.lastVacuum) >= m.vacuumEvery
+		m.mu.Unlock()
+		m.RunNow(ctx, vacuum)
+	}
+}
+
+// RunNow checkpoints the WAL and, if vacuum is set, runs an incremental
+// vacuum. Timings and failures are logged; the report is also kept for Last.
+func (m *Maintenance) RunNow(ctx context.Context, vacuum bool) *MaintenanceReport {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	report := &MaintenanceReport{StartedAt: time.Now().UTC()}
+	checkpoint, err := m.db.Checkpoint(ctx)
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Checkpoint = checkpoint
+		m.logger.Info("wal checkpoint", "pages", checkpoint.CheckpointedPages, "duration_ms", checkpoint.DurationMs, "busy", checkpoint.Busy)
+	}
+
+	if err == nil && vacuum {
+		result, err := m.db.IncrementalVacuum(ctx)
+		if err != nil {
+			report.Error = err.Error()
+		} else {
+			report.Vacuum = result
+			m.logger.Info("vacuum", "freed_pages", result.FreedPages, "duration_ms", result.DurationMs, "full", result.Full)
+			m.mu.Lock()
+			m.lastVacuum = time.Now()
+			m.mu.Unlock()
+		}
+	}
+
+	if report.Error != "" {
+		m.logger.Error("database maintenance failed", "error", report.Error)
+	}
+	m.mu.Lock()
+	m.last = report
+	m.mu.Unlock()
+	return report
+}
+
+// Last returns the report of the most recent run, or nil before the first.
+func (m *Maintenance) Last() *MaintenanceReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Stop ends the maintenance loop and waits for a running pass to finish.
+// It may be called more than once.
+func (m *Maintenance) Stop() {
+	m.cancel()
+	<-m.done
+}
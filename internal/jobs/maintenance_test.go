@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMaintenance_RunsOnSchedule(t *testing.T) {
+	db := setupBackupDB(t, 3)
+
+	m := NewMaintenance(context.Background(), db, 20*time.Millisecond, time.Nanosecond, nil)
+	defer m.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Last() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("maintenance never ran")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	report := m.Last()
+	if report.Error != "" {
+		t.Fatalf("maintenance failed: %s", report.Error)
+	}
+	if report.Checkpoint == nil || report.Vacuum == nil {
+		t.Fatalf("expected checkpoint and vacuum results, got %+v", report)
+	}
+}
+
+func TestMaintenance_RunNow(t *testing.T) {
+	db := setupBackupDB(t, 3)
+
+	m := NewMaintenance(context.Background(), db, time.Hour, 0, nil)
+	defer m.Stop()
+
+	if m.Last() != nil {
+		t.Fatal("expected no report before the first run")
+	}
+	report := m.RunNow(context.Background(), false)
+	if report.Error != "" {
+		t.Fatalf("maintenance failed: %s", report.Error)
+	}
+	if report.Checkpoint == nil || report.Vacuum != nil {
+		t.Fatalf("expected only a checkpoint, got %+v", report)
+	}
+	if m.Last() != report {
+		t.Fatal("expected Last to return the manual run")
+	}
+}
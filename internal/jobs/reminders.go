@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"time-tracker/internal/reminders"
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
+)
+
+// ReminderPayload is the JSON body posted to a rule's webhook when it fires.
+type ReminderPayload struct {
+	Event      string                  `json:"event"`
+	Rule       reminders.Rule          `json:"rule"`
+	Session    *models.SessionResponse `json:"session"`
+	ElapsedSec int64                   `json:"elapsed_sec"`
+	FiredAt    string                  `json:"fired_at"`
+}
+
+// ReminderChecker fires reminder rules for running sessions. Once per
+// interval, for every user with rules, it compares how long the user's
+// running session has run with the user's rules for its category, and fires each rule whose threshold has passed and that has
+// not yet fired for the session. Firing is recorded before the webhook is
+// called, so a failed delivery is logged and not retried.
+type ReminderChecker struct {
+	sessions  *sessions.SessionService
+	reminders *reminders.ReminderService
+	interval  time.Duration
+	client    *http.Client
+	now       func() time.Time
+	logger    *slog.Logger
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewReminderChecker creates a ReminderChecker and starts it. The first
+// check runs immediately, then once per interval, until ctx is cancelled
+// or Stop is called. A nil logger logs to slog.Default().
+func NewReminderChecker(ctx context.Context, sessionSvc *sessions.SessionService, reminderSvc *reminders.ReminderService, interval time.Duration, logger *slog.Logger) *ReminderChecker {
+	return newReminderChecker(ctx, sessionSvc, reminderSvc, interval, time.Now, logger)
+}
+
+// newReminderChecker is NewReminderChecker with the clock to check against.
+func newReminderChecker(ctx context.Context, sessionSvc *sessions.SessionService, reminderSvc *reminders.ReminderService, interval time.Duration, now func() time.Time, logger *slog.Logger) *ReminderChecker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c := &ReminderChecker{
+		sessions:  sessionSvc,
+		reminders: reminderSvc,
+		interval:  interval,
+		client:    &http.Client{Timeout: config.ReminderWebhookTimeout},
+		now:       now,
+		logger:    logger,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go c.run(ctx)
+	return c
+}
+
+// run checks on every tick until ctx is done. Failures are logged and
+// retried on the next tick.
+func (c *ReminderChecker) run(ctx context.Context) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := c.CheckNow(ctx); err != nil && ctx.Err() == nil {
+			c.logger.Error("reminder check failed", "error", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckNow fires the rules that are due for each user's running session
+// and returns how many fired.
+func (c *ReminderChecker) CheckNow(ctx context.Context) (int, error) {
+	owners, err := c.reminders.OwnersContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	fired := 0
+	for _, userID := range owners {
+		n, err := c.checkUser(auth.WithUserID(ctx, userID))
+		fired += n
+		if err != nil {
+			return fired, err
+		}
+	}
+	return fired, nil
+}
+
+// checkUser fires the rules that are due for the running session of the
+// user in ctx and returns how many fired.
+func (c *ReminderChecker) checkUser(ctx context.Context) (int, error) {
+	current, err := c.sessions.GetCurrentContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !current.Running {
+		return 0, nil
+	}
+	session := current.Session
+	startedAt, err := time.Parse(time.RFC3339, session.StartedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse started_at: %w", err)
+	}
+	now := c.now()
+	elapsed := int64(now.Sub(startedAt).Seconds())
+
+	rules, err := c.reminders.ListContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	fired := 0
+	for _, rule := range rules {
+		if elapsed < rule.ThresholdSec || !rule.Matches(session.Category) {
+			continue
+		}
+		recorded, err := c.reminders.RecordFireContext(ctx, session.ID, rule.ID, now)
+		if err != nil {
+			return fired, err
+		}
+		if !recorded {
+			continue
+		}
+		fired++
+		c.notify(ctx, ReminderPayload{
+			Event:      "session.reminder",
+			Rule:       rule,
+			Session:    session,
+			ElapsedSec: elapsed,
+			FiredAt:    now.UTC().Format(time.RFC3339),
+		})
+	}
+	return fired, nil
+}
+
+// notify logs a fired rule and posts it to the rule's webhook, if it has
+// one.
+func (c *ReminderChecker) notify(ctx context.Context, payload ReminderPayload) {
+	c.logger.Info("session reminder", "rule_id", payload.Rule.ID, "session_id", payload.Session.ID,
+		"category", payload.Session.Category, "elapsed_sec", payload.ElapsedSec)
+	if payload.Rule.WebhookURL == nil {
+		return
+	}
+	if err := c.post(ctx, *payload.Rule.WebhookURL, payload); err != nil {
+		c.logger.Error("reminder webhook failed", "rule_id", payload.Rule.ID, "error", err)
+	}
+}
+
+func (c *ReminderChecker) post(ctx context.Context, url string, payload ReminderPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Stop ends the checker and waits for a running check to finish. It may
+// be called more than once.
+func (c *ReminderChecker) Stop() {
+	c.cancel()
+	<-c.done
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"time-tracker/internal/reminders"
+	"time-tracker/internal/sessions"
+)
+
+func TestReminderChecker_FiresOncePerSession(t *testing.T) {
+	db := setupBackupDB(t, 0)
+	if _, err := db.Exec(`INSERT INTO sessions (id, category, task, started_at, status) VALUES (1, 'work', 'report', '2024-03-01T10:00:00Z', 'running')`); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var posted []ReminderPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload ReminderPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+		posted = append(posted, payload)
+	}))
+	defer srv.Close()
+
+	reminderSvc := reminders.NewReminderService(reminders.NewRuleRepository(db))
+	sessionSvc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+	sessionSvc.SetReminders(reminderSvc)
+	var ruleIDs []int64
+	for _, input := range []reminders.RuleCreate{
+		{Category: "work", ThresholdSec: 600},
+		{ThresholdSec: 1800, WebhookURL: srv.URL},
+		{Category: "play", ThresholdSec: 60},
+	} {
+		rule, err := reminderSvc.Create(&input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ruleIDs = append(ruleIDs, rule.ID)
+	}
+
+	// The checker's own first check runs at the starting time, before any
+	// threshold; from then on the test drives it
+	now := started.Add(5 * time.Minute)
+	c := newReminderChecker(context.Background(), sessionSvc, reminderSvc, time.Hour, func() time.Time { return now }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Stop()
+
+	ctx := context.Background()
+	check := func(advance time.Duration, want int) {
+		t.Helper()
+		now = started.Add(advance)
+		fired, err := c.CheckNow(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fired != want {
+			t.Errorf("after %v fired %d rules, want %d", advance, fired, want)
+		}
+	}
+	check(5*time.Minute, 0)
+	check(10*time.Minute, 1)
+	check(20*time.Minute, 0)
+	check(30*time.Minute, 1)
+	check(3*time.Hour, 0)
+
+	if len(posted) != 1 || posted[0].Rule.ID != ruleIDs[1] || posted[0].Session.ID != 1 || posted[0].ElapsedSec != 1800 {
+		t.Errorf("webhook got %+v", posted)
+	}
+
+	current, err := sessionSvc.GetCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(current.RemindersFired, ruleIDs[:2]) {
+		t.Errorf("reminders_fired = %v, want %v", current.RemindersFired, ruleIDs[:2])
+	}
+
+	// A new session is reminded afresh
+	if _, err := db.Exec(`UPDATE sessions SET status = 'stopped', ended_at = '2024-03-01T13:00:00Z' WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, category, task, started_at, status) VALUES (2, 'work', 'review', '2024-03-01T13:00:00Z', 'running')`); err != nil {
+		t.Fatal(err)
+	}
+	check(3*time.Hour+15*time.Minute, 1)
+	if current, _ := sessionSvc.GetCurrent(); !slices.Equal(current.RemindersFired, ruleIDs[:1]) {
+		t.Errorf("second session reminders_fired = %v", current.RemindersFired)
+	}
+}
+
+func TestReminderChecker_NothingRunning(t *testing.T) {
+	db := setupBackupDB(t, 3)
+	reminderSvc := reminders.NewReminderService(reminders.NewRuleRepository(db))
+	if _, err := reminderSvc.Create(&reminders.RuleCreate{ThresholdSec: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sessionSvc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+
+	c := NewReminderChecker(context.Background(), sessionSvc, reminderSvc, time.Hour, nil)
+	defer c.Stop()
+	if fired, err := c.CheckNow(context.Background()); err != nil || fired != 0 {
+		t.Errorf("CheckNow = %d, %v; want nothing fired", fired, err)
+	}
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/sessions"
+)
+
+// Retention enforces the data retention policy: once per interval it deletes,
+// or anonymizes, stopped sessions that ended more than maxAge ago. Each run
+// is logged and recorded in the audit log.
+type Retention struct {
+	svc       *sessions.SessionService
+	mu        sync.Mutex
+	maxAge    time.Duration
+	anonymize bool
+	interval  time.Duration
+	audit     *audit.Logger
+	logger    *slog.Logger
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewRetention creates a Retention and starts it. The first purge runs
+// immediately, then once per interval, until ctx is cancelled or Stop is
+// called. auditLog may be nil; a nil logger logs to slog.Default().
+func NewRetention(ctx context.Context, svc *sessions.SessionService, maxAge time.Duration, anonymize bool, interval time.Duration, auditLog *audit.Logger, logger *slog.Logger) *Retention {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Retention{
+		svc:       svc,
+		maxAge:    maxAge,
+		anonymize: anonymize,
+		interval:  interval,
+		audit:     auditLog,
+		logger:    logger,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go r.run(ctx)
+	return r
+}
+
+// run purges on every tick until ctx is done. Failures are logged and
+// retried on the next tick.
+func (r *Retention) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.PurgeNow(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("retention purge failed", "error", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// PurgeNow applies the policy once and returns the number of sessions
+// affected.
+func (r *Retention) PurgeNow(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	before := time.Now().Add(-r.maxAge)
+	r.mu.Unlock()
+	count, err := r.svc.PurgeBeforeContext(ctx, before, r.anonymize)
+	if err != nil {
+		return 0, err
+	}
+
+	mode := "deleted"
+	if r.anonymize {
+		mode = "anonymized"
+	}
+	r.logger.Info("retention purge", "mode", mode, "count", count, "before", before.UTC().Format(time.RFC3339))
+	r.audit.Log(audit.EventSessionsPurged, "system", "", map[string]interface{}{
+		"before":    before.UTC().Format(time.RFC3339),
+		"anonymize": r.anonymize,
+		"count":     count,
+	})
+	return count, nil
+}
+
+// SetMaxAge changes how old a stopped session must be to be purged, from
+// the next purge on.
+func (r *Retention) SetMaxAge(maxAge time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxAge = maxAge
+}
+
+// Stop ends the retention loop and waits for a running purge to finish.
+// It may be called more than once.
+func (r *Retention) Stop() {
+	r.cancel()
+	<-r.done
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/sessions"
+)
+
+func TestRetention_PurgesOnStart(t *testing.T) {
+	db := setupBackupDB(t, 0)
+	for _, stmt := range []string{
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES ('work', 'old', '2020-01-01T09:00:00Z', '2020-01-01T10:00:00Z', 3600, 'stopped')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'running', '2020-01-01T11:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+	r := NewRetention(context.Background(), svc, 30*24*time.Hour, false, time.Hour, audit.NewLogger(db), nil)
+	defer r.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var audited int
+		if err := db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE event_type = ?", audit.EventSessionsPurged).Scan(&audited); err != nil {
+			t.Fatal(err)
+		}
+		if audited > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("retention never ran")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var task string
+	if err := db.QueryRow("SELECT task FROM sessions").Scan(&task); err != nil {
+		t.Fatal(err)
+	}
+	if task != "running" {
+		t.Errorf("remaining session = %q, want the running one", task)
+	}
+}
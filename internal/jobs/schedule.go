@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// schedule is a job's run interval, which may be changed while the job runs.
+type schedule struct {
+	mu       sync.Mutex
+	interval time.Duration
+	changed  chan struct{}
+}
+
+func newSchedule(interval time.Duration) *schedule {
+	return &schedule{interval: interval, changed: make(chan struct{}, 1)}
+}
+
+func (s *schedule) get() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
+// set changes the interval. The waiting job restarts its ticker at once, so
+// the next run is one new interval from now. Intervals below 1 are ignored.
+func (s *schedule) set(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.mu.Lock()
+	if s.interval == interval {
+		s.mu.Unlock()
+		return
+	}
+	s.interval = interval
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+		// A change is already pending; it will read the new interval
+	}
+}
+
+// wait blocks until ticker fires, restarting it whenever the interval
+// changes. It returns false once ctx is done.
+func (s *schedule) wait(ctx context.Context, ticker *time.Ticker) bool {
+	for {
+		select {
+		case <-ticker.C:
+			return true
+		case <-s.changed:
+			ticker.Reset(s.get())
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
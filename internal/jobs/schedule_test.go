@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedule_SetRestartsTicker(t *testing.T) {
+	s := newSchedule(time.Hour)
+	ticker := time.NewTicker(s.get())
+	defer ticker.Stop()
+
+	s.set(10 * time.Millisecond)
+	s.set(0) // ignored
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if !s.wait(ctx, ticker) {
+		t.Fatal("ticker was not restarted with the new interval")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for a 10ms interval", elapsed)
+	}
+	if got := s.get(); got != 10*time.Millisecond {
+		t.Errorf("interval = %v, want 10ms", got)
+	}
+}
+
+func TestSchedule_WaitStopsOnCancel(t *testing.T) {
+	s := newSchedule(time.Hour)
+	ticker := time.NewTicker(s.get())
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.wait(ctx, ticker) {
+		t.Error("wait reported a tick after cancellation")
+	}
+}
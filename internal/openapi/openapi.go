@@ -0,0 +1,47 @@
+// Package openapi embeds the OpenAPI 3 description of the HTTP API and
+// serves it at /openapi.json.
+package openapi
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+)
+
+// Spec is the OpenAPI document. Keep it in sync with the router; the app
+// package's tests check that every route has an entry.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// etag identifies this build's document, so clients can revalidate it.
+var etag = func() string {
+	sum := sha256.Sum256(Spec)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}()
+
+// Handler serves the document for GET and HEAD requests. It needs no
+// authentication; it describes the API, not its data.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(Spec)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write(Spec)
+	})
+}
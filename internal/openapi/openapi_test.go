@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// collectRefs returns every $ref value in v.
+func collectRefs(v interface{}, refs []string) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				refs = append(refs, ref)
+				continue
+			}
+			refs = collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			refs = collectRefs(child, refs)
+		}
+	}
+	return refs
+}
+
+// resolve follows a local reference such as "#/components/schemas/Tag".
+func resolve(doc map[string]interface{}, ref string) bool {
+	if !strings.HasPrefix(ref, "#/") {
+		return false
+	}
+	var node interface{} = doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if node, ok = m[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpec_Valid(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(Spec, &doc); err != nil {
+		t.Fatalf("spec does not parse: %v", err)
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		t.Errorf("openapi = %v, want 3.x", doc["openapi"])
+	}
+
+	for _, ref := range collectRefs(doc, nil) {
+		if !resolve(doc, ref) {
+			t.Errorf("unresolved $ref %q", ref)
+		}
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		t.Fatal("spec has no paths")
+	}
+	operationIDs := map[string]string{}
+	for path, item := range paths {
+		for method, op := range item.(map[string]interface{}) {
+			if method == "parameters" {
+				continue
+			}
+			op := op.(map[string]interface{})
+			id, _ := op["operationId"].(string)
+			if id == "" {
+				t.Errorf("%s %s has no operationId", method, path)
+			} else if other, ok := operationIDs[id]; ok {
+				t.Errorf("operationId %q is used by %s and %s %s", id, other, method, path)
+			}
+			operationIDs[id] = method + " " + path
+			if responses, _ := op["responses"].(map[string]interface{}); len(responses) == 0 {
+				t.Errorf("%s %s has no responses", method, path)
+			}
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" || rr.Body.Len() != len(Spec) {
+		t.Fatalf("GET: %d %q, %d bytes", rr.Code, rr.Header().Get("Content-Type"), rr.Body.Len())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("revalidation: %d with %d bytes, want 304", rr.Code, rr.Body.Len())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: %d, Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,207 @@
+package presets
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/validation"
+)
+
+type PresetsHandler struct {
+	service      *PresetService
+	maxBodyBytes int64
+}
+
+func NewPresetsHandler(svc *PresetService) *PresetsHandler {
+	return &PresetsHandler{service: svc, maxBodyBytes: config.MaxJSONBodyBytes}
+}
+
+// SetMaxBodyBytes overrides the maximum size of a JSON request body.
+func (h *PresetsHandler) SetMaxBodyBytes(max int64) {
+	if max > 0 {
+		h.maxBodyBytes = max
+	}
+}
+
+func (h *PresetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/api/v1/presets":
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+		}
+	case path == "/api/v1/presets/reorder":
+		if r.Method == http.MethodPost {
+			h.Reorder(w, r)
+		} else {
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
+		}
+	case strings.HasPrefix(path, "/api/v1/presets/") && strings.HasSuffix(path, "/start"):
+		if r.Method == http.MethodPost {
+			h.Start(w, r)
+		} else {
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
+		}
+	case strings.HasPrefix(path, "/api/v1/presets/"):
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		case http.MethodPatch:
+			h.Update(w, r)
+		case http.MethodDelete:
+			h.Delete(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPatch, http.MethodDelete))
+		}
+	default:
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+	}
+}
+
+// Create handles POST /api/v1/presets
+func (h *PresetsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input PresetCreate
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	created, err := h.service.CreateContext(r.Context(), &input)
+	if err != nil {
+		writePresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+// List handles GET /api/v1/presets - every preset in position order.
+func (h *PresetsHandler) List(w http.ResponseWriter, r *http.Request) {
+	items, err := h.service.ListContext(r.Context())
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// Get handles GET /api/v1/presets/:id
+func (h *PresetsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := presetID(w, r, "")
+	if !ok {
+		return
+	}
+	preset, err := h.service.GetContext(r.Context(), id)
+	if err != nil {
+		writePresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preset)
+}
+
+// Update handles PATCH /api/v1/presets/:id
+func (h *PresetsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := presetID(w, r, "")
+	if !ok {
+		return
+	}
+	var input PresetUpdate
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	preset, err := h.service.UpdateContext(r.Context(), id, &input)
+	if err != nil {
+		writePresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preset)
+}
+
+// Delete handles DELETE /api/v1/presets/:id
+func (h *PresetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := presetID(w, r, "")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteContext(r.Context(), id); err != nil {
+		writePresetError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reorder handles POST /api/v1/presets/reorder - sets the order of the
+// presets from {"ids": [...]}, which lists every preset once.
+func (h *PresetsHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	var input PresetReorder
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	items, err := h.service.ReorderContext(r.Context(), &input)
+	if err != nil {
+		writePresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// Start handles POST /api/v1/presets/:id/start - starts a session from the
+// preset, answering like POST /api/v1/sessions/start.
+func (h *PresetsHandler) Start(w http.ResponseWriter, r *http.Request) {
+	id, ok := presetID(w, r, "/start")
+	if !ok {
+		return
+	}
+	session, err := h.service.StartContext(r.Context(), id)
+	if err != nil {
+		if err == sessions.ErrSessionAlreadyRunning && session != nil {
+			errors.WriteError(w, errors.NewConflictError("A session is already running", session))
+			return
+		}
+		writePresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(session)
+}
+
+// presetID reads the ID from /api/v1/presets/:id followed by suffix, writing
+// the error response if it is not a valid ID.
+func presetID(w http.ResponseWriter, r *http.Request, suffix string) (int64, bool) {
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/presets/"), suffix)
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		errors.WriteError(w, errors.ValidationError("Invalid id"))
+		return 0, false
+	}
+	return id, true
+}
+
+func writePresetError(w http.ResponseWriter, err error) {
+	if err == ErrPresetNotFound {
+		errors.WriteError(w, errors.NotFoundError("Preset not found"))
+		return
+	}
+	if strings.Contains(err.Error(), "validation error") {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
+	}
+	errors.WriteError(w, err)
+}
@@ -0,0 +1,93 @@
+package presets
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/shared/auth"
+)
+
+func TestPresetsHandler(t *testing.T) {
+	svc, db := setupTestService(t)
+	if _, err := db.Exec(`INSERT INTO tags (id, name, created_at) VALUES (1, 'deep', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	h := NewPresetsHandler(svc)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, newRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	createW := do(http.MethodPost, "/api/v1/presets", `{"name":"Review","category":"work","task":"code review","tag_ids":[1]}`)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", createW.Code, createW.Body.String())
+	}
+	var created Preset
+	if err := json.NewDecoder(createW.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if do(http.MethodPost, "/api/v1/presets", `{"name":"Read","task":"reading"}`).Code != http.StatusCreated {
+		t.Fatal("second create failed")
+	}
+
+	// tag_ids is always a list
+	listW := do(http.MethodGet, "/api/v1/presets", "")
+	if listW.Code != http.StatusOK || !strings.Contains(listW.Body.String(), `"tag_ids":[]`) {
+		t.Fatalf("list: %d %s", listW.Code, listW.Body.String())
+	}
+
+	reorderW := do(http.MethodPost, "/api/v1/presets/reorder", `{"ids":[2,1]}`)
+	var list []Preset
+	if err := json.NewDecoder(reorderW.Body).Decode(&list); err != nil || len(list) != 2 || list[0].Name != "Read" {
+		t.Fatalf("reorder: %d %+v %v", reorderW.Code, list, err)
+	}
+
+	patchW := do(http.MethodPatch, "/api/v1/presets/1", `{"task":"deep review"}`)
+	if patchW.Code != http.StatusOK || !strings.Contains(patchW.Body.String(), `"task":"deep review"`) {
+		t.Fatalf("patch: %d %s", patchW.Code, patchW.Body.String())
+	}
+
+	startW := do(http.MethodPost, "/api/v1/presets/1/start", "")
+	if startW.Code != http.StatusCreated || !strings.Contains(startW.Body.String(), `"task":"deep review"`) {
+		t.Fatalf("start: %d %s", startW.Code, startW.Body.String())
+	}
+	conflictW := do(http.MethodPost, "/api/v1/presets/2/start", "")
+	if conflictW.Code != http.StatusConflict || !strings.Contains(conflictW.Body.String(), "deep review") {
+		t.Fatalf("start while running: %d %s", conflictW.Code, conflictW.Body.String())
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/presets/1", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/presets/99", "", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/presets/abc", "", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/presets", `{"name":""}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/presets", `{"name":"x","tag_ids":[7]}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/presets/reorder", `{"ids":[1]}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/presets/99/start", "", http.StatusNotFound},
+		{http.MethodPatch, "/api/v1/presets/99", `{"task":"x"}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/presets/2", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/presets/2", "", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/presets/1", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.method, tt.path, tt.body); rr.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
+}
@@ -0,0 +1,146 @@
+// Package presets manages quick-start presets: a named category and task,
+// with tags, that starts a session in one step.
+package presets
+
+import (
+	"errors"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/validation"
+)
+
+// Preset is a saved category, task and tags to start sessions from.
+// Presets are listed by Position, lowest first.
+type Preset struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Category  string  `json:"category"`
+	Task      string  `json:"task"`
+	TagIDs    []int64 `json:"tag_ids"`
+	Position  int     `json:"position"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// PresetCreate is the input for creating a preset. An empty category or
+// task gets the same default as a session started without one.
+type PresetCreate struct {
+	Name     string  `json:"name"`
+	Category string  `json:"category"`
+	Task     string  `json:"task"`
+	TagIDs   []int64 `json:"tag_ids"`
+}
+
+// PresetUpdate holds the fields of a PATCH request. Nil fields are left
+// unchanged; an empty tag_ids list removes every tag.
+type PresetUpdate struct {
+	Name     *string  `json:"name,omitempty"`
+	Category *string  `json:"category,omitempty"`
+	Task     *string  `json:"task,omitempty"`
+	TagIDs   *[]int64 `json:"tag_ids,omitempty"`
+}
+
+// PresetReorder lists every preset ID in its new order.
+type PresetReorder struct {
+	IDs []int64 `json:"ids"`
+}
+
+var (
+	ErrNameRequired   = errors.New("name is required")
+	ErrNameTooLong    = errors.New("name must be at most 50 characters")
+	ErrInvalidTagID   = errors.New("tag_ids must be positive tag ids")
+	ErrReorderInvalid = errors.New("ids must list every preset exactly once")
+	ErrPresetNotFound = errors.New("preset not found")
+)
+
+func (p *PresetCreate) Validate() error {
+	return p.ValidateWithDefaults(models.BuiltinDefaults)
+}
+
+// ValidateWithDefaults is like Validate, setting a missing category or task
+// from defaults as starting a session would.
+func (p *PresetCreate) ValidateWithDefaults(defaults models.Defaults) error {
+	p.Name = validation.SanitizeString(p.Name)
+	if p.Name == "" {
+		return ErrNameRequired
+	}
+	if !validation.ValidateStringLength(p.Name, 1, config.MaxPresetNameLength) {
+		return ErrNameTooLong
+	}
+
+	// A preset must be able to start a session, so it follows the same rules
+	start := sessions.SessionStart{Category: p.Category, Task: p.Task}
+	if err := start.ValidateWithDefaults(defaults); err != nil {
+		return err
+	}
+	p.Category, p.Task = start.Category, start.Task
+
+	tagIDs, err := uniqueTagIDs(p.TagIDs)
+	if err != nil {
+		return err
+	}
+	p.TagIDs = tagIDs
+	return nil
+}
+
+func (p *PresetUpdate) Validate() error {
+	return p.ValidateWithDefaults(models.BuiltinDefaults)
+}
+
+// ValidateWithDefaults is like Validate, setting a category or task
+// changed to empty from defaults.
+func (p *PresetUpdate) ValidateWithDefaults(defaults models.Defaults) error {
+	if p.Name != nil {
+		name := validation.SanitizeString(*p.Name)
+		if name == "" {
+			return ErrNameRequired
+		}
+		if !validation.ValidateStringLength(name, 1, config.MaxPresetNameLength) {
+			return ErrNameTooLong
+		}
+		p.Name = &name
+	}
+	if p.Category != nil || p.Task != nil {
+		start := sessions.SessionStart{Category: "-", Task: "-"}
+		if p.Category != nil {
+			start.Category = *p.Category
+		}
+		if p.Task != nil {
+			start.Task = *p.Task
+		}
+		if err := start.ValidateWithDefaults(defaults); err != nil {
+			return err
+		}
+		if p.Category != nil {
+			p.Category = &start.Category
+		}
+		if p.Task != nil {
+			p.Task = &start.Task
+		}
+	}
+	if p.TagIDs != nil {
+		tagIDs, err := uniqueTagIDs(*p.TagIDs)
+		if err != nil {
+			return err
+		}
+		p.TagIDs = &tagIDs
+	}
+	return nil
+}
+
+// uniqueTagIDs checks tag IDs and drops repeats, keeping the first of each.
+func uniqueTagIDs(ids []int64) ([]int64, error) {
+	out := make([]int64, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, ErrInvalidTagID
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
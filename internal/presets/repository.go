@@ -0,0 +1,213 @@
+package presets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"time-tracker/internal/shared/database"
+)
+
+type PresetRepository struct {
+	db *database.DB
+}
+
+func NewPresetRepository(db *database.DB) *PresetRepository {
+	return &PresetRepository{db: db}
+}
+
+// presetColumns is the column list shared by every preset query.
+const presetColumns = "id, name, category, task, position, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPreset(row rowScanner) (*Preset, error) {
+	p := Preset{TagIDs: []int64{}}
+	if err := row.Scan(&p.ID, &p.Name, &p.Category, &p.Task, &p.Position, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateContext inserts a preset after the user's last one.
+func (r *PresetRepository) CreateContext(ctx context.Context, userID int64, input *PresetCreate) (*Preset, error) {
+	var id int64
+	err := r.db.InTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO presets (user_id, name, category, task, position, created_at)
+			 VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(position) + 1, 0) FROM presets WHERE user_id = ?), strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
+			userID, input.Name, input.Category, input.Task, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert preset: %w", err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		return setTags(ctx, tx, id, input.TagIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByIDContext(ctx, userID, id)
+}
+
+// GetByIDContext returns the user's preset with its tags, or nil if the user
+// has none.
+func (r *PresetRepository) GetByIDContext(ctx context.Context, userID, id int64) (*Preset, error) {
+	p, err := scanPreset(r.db.Reader().QueryRowContext(ctx, `SELECT `+presetColumns+` FROM presets WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preset: %w", err)
+	}
+
+	rows, err := r.db.Reader().QueryContext(ctx, `SELECT tag_id FROM preset_tags WHERE preset_id = ? ORDER BY tag_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preset tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tagID int64
+		if err := rows.Scan(&tagID); err != nil {
+			return nil, fmt.Errorf("failed to scan preset tag: %w", err)
+		}
+		p.TagIDs = append(p.TagIDs, tagID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate preset tags: %w", err)
+	}
+	return p, nil
+}
+
+// ListContext returns every preset of the user with its tags, in position order.
+func (r *PresetRepository) ListContext(ctx context.Context, userID int64) ([]Preset, error) {
+	rows, err := r.db.Reader().QueryContext(ctx, `SELECT `+presetColumns+` FROM presets WHERE user_id = ? ORDER BY position, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query presets: %w", err)
+	}
+	defer rows.Close()
+
+	presets := []Preset{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		p, err := scanPreset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan preset: %w", err)
+		}
+		index[p.ID] = len(presets)
+		presets = append(presets, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate presets: %w", err)
+	}
+	rows.Close()
+
+	tagRows, err := r.db.Reader().QueryContext(ctx,
+		`SELECT pt.preset_id, pt.tag_id FROM preset_tags pt
+		 INNER JOIN presets p ON p.id = pt.preset_id
+		 WHERE p.user_id = ?
+		 ORDER BY pt.preset_id, pt.tag_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preset tags: %w", err)
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var presetID, tagID int64
+		if err := tagRows.Scan(&presetID, &tagID); err != nil {
+			return nil, fmt.Errorf("failed to scan preset tag: %w", err)
+		}
+		if i, ok := index[presetID]; ok {
+			presets[i].TagIDs = append(presets[i].TagIDs, tagID)
+		}
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate preset tags: %w", err)
+	}
+	return presets, nil
+}
+
+// UpdateContext changes the given fields of one of the user's presets.
+func (r *PresetRepository) UpdateContext(ctx context.Context, userID, id int64, input *PresetUpdate) error {
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		if err := checkOwner(ctx, tx, userID, id); err != nil {
+			return err
+		}
+		for _, field := range []struct {
+			column string
+			value  *string
+		}{
+			{"name", input.Name},
+			{"category", input.Category},
+			{"task", input.Task},
+		} {
+			if field.value == nil {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE presets SET `+field.column+` = ? WHERE id = ? AND user_id = ?`, *field.value, id, userID); err != nil {
+				return fmt.Errorf("failed to update preset %s: %w", field.column, err)
+			}
+		}
+		if input.TagIDs == nil {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM preset_tags WHERE preset_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to clear preset tags: %w", err)
+		}
+		return setTags(ctx, tx, id, *input.TagIDs)
+	})
+}
+
+// ReorderContext gives each of the user's presets in ids its index as position.
+func (r *PresetRepository) ReorderContext(ctx context.Context, userID int64, ids []int64) error {
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		for position, id := range ids {
+			if _, err := tx.ExecContext(ctx, `UPDATE presets SET position = ? WHERE id = ? AND user_id = ?`, position, id, userID); err != nil {
+				return fmt.Errorf("failed to reorder presets: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteContext removes one of the user's presets and its tag associations.
+func (r *PresetRepository) DeleteContext(ctx context.Context, userID, id int64) error {
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		if err := checkOwner(ctx, tx, userID, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM preset_tags WHERE preset_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to remove preset tags: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM presets WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete preset: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkOwner returns ErrPresetNotFound unless the user owns the preset.
+func checkOwner(ctx context.Context, tx *sql.Tx, userID, id int64) error {
+	var owned bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM presets WHERE id = ? AND user_id = ?)`, id, userID).Scan(&owned); err != nil {
+		return fmt.Errorf("failed to query preset: %w", err)
+	}
+	if !owned {
+		return ErrPresetNotFound
+	}
+	return nil
+}
+
+func setTags(ctx context.Context, tx *sql.Tx, presetID int64, tagIDs []int64) error {
+	for _, tagID := range tagIDs {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO preset_tags (preset_id, tag_id) VALUES (?, ?)`, presetID, tagID); err != nil {
+			return fmt.Errorf("failed to add preset tag: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package presets
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/tags"
+)
+
+// PresetService manages the presets of the user each context acts for and
+// starts sessions from them.
+type PresetService struct {
+	repo     *PresetRepository
+	sessions *sessions.SessionService
+	tags     *tags.TagService
+}
+
+func NewPresetService(repo *PresetRepository, sessionSvc *sessions.SessionService, tagSvc *tags.TagService) *PresetService {
+	return &PresetService{repo: repo, sessions: sessionSvc, tags: tagSvc}
+}
+
+// Create adds a preset at the end of the list.
+func (s *PresetService) Create(input *PresetCreate) (*Preset, error) {
+	return s.CreateContext(auth.DefaultUserContext(), input)
+}
+
+// CreateContext is like Create but takes a context for cancellation.
+func (s *PresetService) CreateContext(ctx context.Context, input *PresetCreate) (*Preset, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.ValidateWithDefaults(s.sessions.Defaults()); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if err := s.checkTags(ctx, input.TagIDs); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateContext(ctx, userID, input)
+}
+
+// Get returns a preset. Returns ErrPresetNotFound if it does not exist.
+func (s *PresetService) Get(id int64) (*Preset, error) {
+	return s.GetContext(auth.DefaultUserContext(), id)
+}
+
+// GetContext is like Get but takes a context for cancellation.
+func (s *PresetService) GetContext(ctx context.Context, id int64) (*Preset, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	preset, err := s.repo.GetByIDContext(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if preset == nil {
+		return nil, ErrPresetNotFound
+	}
+	return preset, nil
+}
+
+// List returns every preset in position order.
+func (s *PresetService) List() ([]Preset, error) {
+	return s.ListContext(auth.DefaultUserContext())
+}
+
+// ListContext is like List but takes a context for cancellation.
+func (s *PresetService) ListContext(ctx context.Context) ([]Preset, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListContext(ctx, userID)
+}
+
+// Update changes a preset. Returns ErrPresetNotFound if it does not exist.
+func (s *PresetService) Update(id int64, input *PresetUpdate) (*Preset, error) {
+	return s.UpdateContext(auth.DefaultUserContext(), id, input)
+}
+
+// UpdateContext is like Update but takes a context for cancellation.
+func (s *PresetService) UpdateContext(ctx context.Context, id int64, input *PresetUpdate) (*Preset, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.ValidateWithDefaults(s.sessions.Defaults()); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if _, err := s.GetContext(ctx, id); err != nil {
+		return nil, err
+	}
+	if input.TagIDs != nil {
+		if err := s.checkTags(ctx, *input.TagIDs); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.UpdateContext(ctx, userID, id, input); err != nil {
+		return nil, err
+	}
+	return s.GetContext(ctx, id)
+}
+
+// Reorder sets the order of the presets, which must all be listed once.
+func (s *PresetService) Reorder(input *PresetReorder) ([]Preset, error) {
+	return s.ReorderContext(auth.DefaultUserContext(), input)
+}
+
+// ReorderContext is like Reorder but takes a context for cancellation.
+func (s *PresetService) ReorderContext(ctx context.Context, input *PresetReorder) ([]Preset, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.repo.ListContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.IDs) != len(current) {
+		return nil, fmt.Errorf("validation error: %w", ErrReorderInvalid)
+	}
+	for _, p := range current {
+		if !slices.Contains(input.IDs, p.ID) {
+			return nil, fmt.Errorf("validation error: %w", ErrReorderInvalid)
+		}
+	}
+	if err := s.repo.ReorderContext(ctx, userID, input.IDs); err != nil {
+		return nil, err
+	}
+	return s.repo.ListContext(ctx, userID)
+}
+
+// Delete removes a preset. Returns ErrPresetNotFound if it does not exist.
+func (s *PresetService) Delete(id int64) error {
+	return s.DeleteContext(auth.DefaultUserContext(), id)
+}
+
+// DeleteContext is like Delete but takes a context for cancellation.
+func (s *PresetService) DeleteContext(ctx context.Context, id int64) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := s.GetContext(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteContext(ctx, userID, id)
+}
+
+// Start starts a session with the preset's category and task and tags it
+// with the preset's tags, skipping any archived since the preset was saved.
+// As with any start, it returns sessions.ErrSessionAlreadyRunning and the
+// running session if one is running.
+func (s *PresetService) Start(id int64) (*models.SessionResponse, error) {
+	return s.StartContext(auth.DefaultUserContext(), id)
+}
+
+// StartContext is like Start but takes a context for cancellation.
+func (s *PresetService) StartContext(ctx context.Context, id int64) (*models.SessionResponse, error) {
+	preset, err := s.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look the tags up first, so a failure cannot leave an untagged session
+	var tagIDs []int64
+	for _, tagID := range preset.TagIDs {
+		tag, err := s.tags.GetContext(ctx, tagID)
+		if err == tags.ErrTagNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !tag.Archived {
+			tagIDs = append(tagIDs, tagID)
+		}
+	}
+
+	session, err := s.sessions.StartSessionContext(ctx, &sessions.SessionStart{
+		Category: preset.Category,
+		Task:     preset.Task,
+	})
+	if err != nil {
+		return session, err
+	}
+	if len(tagIDs) > 0 {
+		if err := s.tags.AssignToSessionContext(ctx, session.ID, tagIDs); err != nil {
+			return nil, fmt.Errorf("session %d started without its tags: %w", session.ID, err)
+		}
+	}
+	return session, nil
+}
+
+// checkTags reports a validation error for tags that do not exist or are
+// archived.
+func (s *PresetService) checkTags(ctx context.Context, tagIDs []int64) error {
+	for _, tagID := range tagIDs {
+		tag, err := s.tags.GetContext(ctx, tagID)
+		if err == tags.ErrTagNotFound {
+			return fmt.Errorf("validation error: tag %d not found", tagID)
+		}
+		if err != nil {
+			return err
+		}
+		if tag.Archived {
+			return fmt.Errorf("validation error: tag %d: %w", tagID, tags.ErrTagArchived)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package presets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/tags"
+)
+
+func setupTestService(t *testing.T) (*PresetService, *database.DB) {
+	t.Helper()
+	db := database.NewForTesting(t)
+	svc := NewPresetService(NewPresetRepository(db),
+		sessions.NewSessionService(sessions.NewSessionRepository(db)),
+		tags.NewTagService(tags.NewTagRepository(db)))
+	return svc, db
+}
+
+func TestPresetService_CreateAndReorder(t *testing.T) {
+	svc, db := setupTestService(t)
+	if _, err := db.Exec(`INSERT INTO tags (id, name, created_at, archived) VALUES
+		(1, 'deep', '2024-01-01T00:00:00Z', 0), (2, 'old', '2024-01-01T00:00:00Z', 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int64
+	for _, input := range []PresetCreate{
+		{Name: " Review ", Category: "work", Task: "code review", TagIDs: []int64{1, 1}},
+		{Name: "Read", Task: "reading"},
+		{Name: "Email", Category: "work", Task: "inbox"},
+	} {
+		p, err := svc.Create(&input)
+		if err != nil {
+			t.Fatalf("create %s: %v", input.Name, err)
+		}
+		ids = append(ids, p.ID)
+	}
+
+	first, err := svc.Get(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Name != "Review" || first.Position != 0 || len(first.TagIDs) != 1 || first.TagIDs[0] != 1 {
+		t.Errorf("first preset = %+v", first)
+	}
+	// An empty category gets the session default, so the preset can start
+	if second, _ := svc.Get(ids[1]); second.Category == "" || second.Position != 1 {
+		t.Errorf("second preset = %+v", second)
+	}
+
+	for name, input := range map[string]PresetCreate{
+		"no name":       {Task: "x"},
+		"archived tag":  {Name: "x", TagIDs: []int64{2}},
+		"missing tag":   {Name: "x", TagIDs: []int64{9}},
+		"bad tag id":    {Name: "x", TagIDs: []int64{0}},
+		"task too long": {Name: "x", Task: strings.Repeat("x", 201)},
+	} {
+		if _, err := svc.Create(&input); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	// Reordering needs every preset exactly once
+	for _, order := range [][]int64{{ids[2], ids[0]}, {ids[2], ids[0], ids[0]}, {ids[2], ids[0], 99}} {
+		if _, err := svc.Reorder(&PresetReorder{IDs: order}); !errors.Is(err, ErrReorderInvalid) {
+			t.Errorf("reorder %v: err = %v, want ErrReorderInvalid", order, err)
+		}
+	}
+	list, err := svc.Reorder(&PresetReorder{IDs: []int64{ids[2], ids[0], ids[1]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"Email", "Review", "Read"} {
+		if list[i].Name != want || list[i].Position != i {
+			t.Errorf("list[%d] = %s at %d, want %s at %d", i, list[i].Name, list[i].Position, want, i)
+		}
+	}
+
+	// New presets go last; deleting a tag removes it from presets
+	p, err := svc.Create(&PresetCreate{Name: "Later", Task: "later"})
+	if err != nil || p.Position != 3 {
+		t.Fatalf("preset created after reorder: %+v, %v", p, err)
+	}
+	if err := tags.NewTagService(tags.NewTagRepository(db)).Delete(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if first, _ := svc.Get(ids[0]); len(first.TagIDs) != 0 {
+		t.Errorf("deleted tag still on preset: %v", first.TagIDs)
+	}
+
+	if err := svc.Delete(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get(ids[1]); err != ErrPresetNotFound {
+		t.Errorf("get deleted preset: %v", err)
+	}
+	if err := svc.Delete(ids[1]); err != ErrPresetNotFound {
+		t.Errorf("delete deleted preset: %v", err)
+	}
+}
+
+func TestPresetService_Update(t *testing.T) {
+	svc, db := setupTestService(t)
+	if _, err := db.Exec(`INSERT INTO tags (id, name, created_at) VALUES (1, 'a', '2024-01-01T00:00:00Z'), (2, 'b', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	p, err := svc.Create(&PresetCreate{Name: "Review", Category: "work", Task: "review", TagIDs: []int64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := "deep review"
+	tagIDs := []int64{2}
+	updated, err := svc.Update(p.ID, &PresetUpdate{Task: &task, TagIDs: &tagIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Review" || updated.Category != "work" || updated.Task != "deep review" || len(updated.TagIDs) != 1 || updated.TagIDs[0] != 2 {
+		t.Errorf("updated preset = %+v", updated)
+	}
+
+	empty := " "
+	if _, err := svc.Update(p.ID, &PresetUpdate{Name: &empty}); !errors.Is(err, ErrNameRequired) {
+		t.Errorf("blank name: %v", err)
+	}
+	if _, err := svc.Update(99, &PresetUpdate{Task: &task}); err != ErrPresetNotFound {
+		t.Errorf("update missing preset: %v", err)
+	}
+}
+
+func TestPresetService_Start(t *testing.T) {
+	svc, db := setupTestService(t)
+	if _, err := db.Exec(`INSERT INTO tags (id, name, created_at) VALUES (1, 'deep', '2024-01-01T00:00:00Z'), (2, 'focus', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	p, err := svc.Create(&PresetCreate{Name: "Review", Category: "work", Task: "code review", TagIDs: []int64{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A tag archived after the preset was saved is left off
+	if _, err := db.Exec(`UPDATE tags SET archived = 1 WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := svc.Start(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Category != "work" || session.Task != "code review" || session.Status != "running" {
+		t.Errorf("started session = %+v", session)
+	}
+	sessionTags, err := tags.NewTagService(tags.NewTagRepository(db)).ListForSession(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessionTags) != 1 || sessionTags[0].Name != "deep" {
+		t.Errorf("session tags = %+v, want deep", sessionTags)
+	}
+
+	// The usual conflict rule applies, reporting the running session
+	running, err := svc.Start(p.ID)
+	if err != sessions.ErrSessionAlreadyRunning || running == nil || running.ID != session.ID {
+		t.Errorf("second start: %+v, %v", running, err)
+	}
+	if _, err := svc.Start(99); err != ErrPresetNotFound {
+		t.Errorf("start missing preset: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package reminders
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/validation"
+)
+
+type RemindersHandler struct {
+	service      *ReminderService
+	maxBodyBytes int64
+}
+
+func NewRemindersHandler(svc *ReminderService) *RemindersHandler {
+	return &RemindersHandler{service: svc, maxBodyBytes: config.MaxJSONBodyBytes}
+}
+
+// SetMaxBodyBytes overrides the maximum size of a JSON request body.
+func (h *RemindersHandler) SetMaxBodyBytes(max int64) {
+	if max > 0 {
+		h.maxBodyBytes = max
+	}
+}
+
+func (h *RemindersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/api/v1/reminders":
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+		}
+	case strings.HasPrefix(path, "/api/v1/reminders/"):
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		case http.MethodPatch:
+			h.Update(w, r)
+		case http.MethodDelete:
+			h.Delete(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPatch, http.MethodDelete))
+		}
+	default:
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+	}
+}
+
+// Create handles POST /api/v1/reminders
+func (h *RemindersHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input RuleCreate
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	created, err := h.service.CreateContext(r.Context(), &input)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+// List handles GET /api/v1/reminders - every rule, shortest threshold first.
+func (h *RemindersHandler) List(w http.ResponseWriter, r *http.Request) {
+	items, err := h.service.ListContext(r.Context())
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// Get handles GET /api/v1/reminders/:id
+func (h *RemindersHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+	rule, err := h.service.GetContext(r.Context(), id)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// Update handles PATCH /api/v1/reminders/:id
+func (h *RemindersHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+	var input RuleUpdate
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	rule, err := h.service.UpdateContext(r.Context(), id, &input)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// Delete handles DELETE /api/v1/reminders/:id
+func (h *RemindersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteContext(r.Context(), id); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ruleID reads the ID from /api/v1/reminders/:id, writing the error
+// response if it is not a valid ID.
+func ruleID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/v1/reminders/"), 10, 64)
+	if err != nil || id <= 0 {
+		errors.WriteError(w, errors.ValidationError("Invalid id"))
+		return 0, false
+	}
+	return id, true
+}
+
+func writeRuleError(w http.ResponseWriter, err error) {
+	if err == ErrRuleNotFound {
+		errors.WriteError(w, errors.NotFoundError("Reminder rule not found"))
+		return
+	}
+	if strings.Contains(err.Error(), "validation error") {
+		errors.WriteError(w, errors.ValidationErrorFor(err))
+		return
+	}
+	errors.WriteError(w, err)
+}
@@ -0,0 +1,70 @@
+package reminders
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/shared/auth"
+)
+
+func TestRemindersHandler(t *testing.T) {
+	svc, _ := setupTestService(t)
+	h := NewRemindersHandler(svc)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, newRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	createW := do(http.MethodPost, "/api/v1/reminders", `{"category":"work","threshold_sec":3600}`)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", createW.Code, createW.Body.String())
+	}
+	var created Rule
+	if err := json.NewDecoder(createW.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rule without a webhook only logs, shown as null
+	listW := do(http.MethodGet, "/api/v1/reminders", "")
+	if listW.Code != http.StatusOK || !strings.Contains(listW.Body.String(), `"webhook_url":null`) {
+		t.Fatalf("list: %d %s", listW.Code, listW.Body.String())
+	}
+
+	patchW := do(http.MethodPatch, "/api/v1/reminders/1", `{"webhook_url":"http://localhost:9000/hook"}`)
+	if patchW.Code != http.StatusOK || !strings.Contains(patchW.Body.String(), `"webhook_url":"http://localhost:9000/hook"`) {
+		t.Fatalf("patch: %d %s", patchW.Code, patchW.Body.String())
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/reminders/1", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/reminders/99", "", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/reminders/abc", "", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/reminders", `{"threshold_sec":0}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/reminders", `{"threshold_sec":60,"webhook_url":"hook"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/api/v1/reminders/99", `{"threshold_sec":60}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/reminders/1", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/reminders/1", "", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/reminders/1", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.method, tt.path, tt.body); rr.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
+}
@@ -0,0 +1,97 @@
+// Package reminders manages long-session reminder rules: once a running
+// session in a rule's category has lasted the rule's threshold, the rule
+// fires, posting to its webhook or only logging. Each rule fires at most
+// once per session.
+package reminders
+
+import (
+	"errors"
+	"net/url"
+	"unicode/utf8"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/validation"
+)
+
+// Rule is a reminder rule. A nil Category matches sessions in every
+// category; a nil WebhookURL only logs the reminder.
+type Rule struct {
+	ID           int64   `json:"id"`
+	Category     *string `json:"category"`
+	ThresholdSec int64   `json:"threshold_sec"`
+	WebhookURL   *string `json:"webhook_url"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+// Matches reports whether the rule applies to sessions in category.
+func (r *Rule) Matches(category string) bool {
+	return r.Category == nil || *r.Category == category
+}
+
+// RuleCreate is the input for creating a rule. An empty category matches
+// every category; an empty webhook_url makes the rule log-only.
+type RuleCreate struct {
+	Category     string `json:"category"`
+	ThresholdSec int64  `json:"threshold_sec"`
+	WebhookURL   string `json:"webhook_url"`
+}
+
+// RuleUpdate holds the fields of a PATCH request. Nil fields are left
+// unchanged; an empty category or webhook_url clears it.
+type RuleUpdate struct {
+	Category     *string `json:"category,omitempty"`
+	ThresholdSec *int64  `json:"threshold_sec,omitempty"`
+	WebhookURL   *string `json:"webhook_url,omitempty"`
+}
+
+var (
+	ErrInvalidThreshold = errors.New("threshold_sec must be a positive number of seconds")
+	ErrInvalidWebhook   = errors.New("webhook_url must be an absolute http or https URL")
+	ErrRuleNotFound     = errors.New("reminder rule not found")
+)
+
+func (r *RuleCreate) Validate() error {
+	r.Category = validation.SanitizeString(r.Category)
+	if utf8.RuneCountInString(r.Category) > models.CategoryMaxLen {
+		return models.ErrCategoryTooLong
+	}
+	if r.ThresholdSec <= 0 {
+		return ErrInvalidThreshold
+	}
+	r.WebhookURL = validation.SanitizeString(r.WebhookURL)
+	return validateWebhook(r.WebhookURL)
+}
+
+func (r *RuleUpdate) Validate() error {
+	if r.Category != nil {
+		category := validation.SanitizeString(*r.Category)
+		if utf8.RuneCountInString(category) > models.CategoryMaxLen {
+			return models.ErrCategoryTooLong
+		}
+		r.Category = &category
+	}
+	if r.ThresholdSec != nil && *r.ThresholdSec <= 0 {
+		return ErrInvalidThreshold
+	}
+	if r.WebhookURL != nil {
+		webhook := validation.SanitizeString(*r.WebhookURL)
+		if err := validateWebhook(webhook); err != nil {
+			return err
+		}
+		r.WebhookURL = &webhook
+	}
+	return nil
+}
+
+// validateWebhook accepts an empty URL, for log-only rules, or an absolute
+// http or https URL.
+func validateWebhook(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhook
+	}
+	return nil
+}
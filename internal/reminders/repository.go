@@ -0,0 +1,205 @@
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+type RuleRepository struct {
+	db *database.DB
+}
+
+func NewRuleRepository(db *database.DB) *RuleRepository {
+	return &RuleRepository{db: db}
+}
+
+// ruleColumns is the column list shared by every rule query.
+const ruleColumns = "id, category, threshold_sec, webhook_url, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRule(row rowScanner) (*Rule, error) {
+	var r Rule
+	var category, webhook sql.NullString
+	if err := row.Scan(&r.ID, &category, &r.ThresholdSec, &webhook, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if category.Valid {
+		r.Category = &category.String
+	}
+	if webhook.Valid {
+		r.WebhookURL = &webhook.String
+	}
+	return &r, nil
+}
+
+// nullable stores an empty string as NULL.
+func nullable(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// CreateContext inserts a rule for the user.
+func (r *RuleRepository) CreateContext(ctx context.Context, userID int64, input *RuleCreate) (*Rule, error) {
+	var id int64
+	err := r.db.InTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO reminder_rules (user_id, category, threshold_sec, webhook_url, created_at)
+			 VALUES (?, ?, ?, ?, strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
+			userID, nullable(input.Category), input.ThresholdSec, nullable(input.WebhookURL),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert reminder rule: %w", err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByIDContext(ctx, userID, id)
+}
+
+// GetByIDContext returns the user's rule, or nil if the user has none.
+func (r *RuleRepository) GetByIDContext(ctx context.Context, userID, id int64) (*Rule, error) {
+	rule, err := scanRule(r.db.Reader().QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM reminder_rules WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder rule: %w", err)
+	}
+	return rule, nil
+}
+
+// ListContext returns every rule of the user, shortest threshold first.
+func (r *RuleRepository) ListContext(ctx context.Context, userID int64) ([]Rule, error) {
+	rows, err := r.db.Reader().QueryContext(ctx, `SELECT `+ruleColumns+` FROM reminder_rules WHERE user_id = ? ORDER BY threshold_sec, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []Rule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reminder rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reminder rules: %w", err)
+	}
+	return rules, nil
+}
+
+// UpdateContext changes the given fields of one of the user's rules.
+func (r *RuleRepository) UpdateContext(ctx context.Context, userID, id int64, input *RuleUpdate) error {
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		if input.Category != nil {
+			if _, err := tx.ExecContext(ctx, `UPDATE reminder_rules SET category = ? WHERE id = ? AND user_id = ?`, nullable(*input.Category), id, userID); err != nil {
+				return fmt.Errorf("failed to update reminder rule category: %w", err)
+			}
+		}
+		if input.ThresholdSec != nil {
+			if _, err := tx.ExecContext(ctx, `UPDATE reminder_rules SET threshold_sec = ? WHERE id = ? AND user_id = ?`, *input.ThresholdSec, id, userID); err != nil {
+				return fmt.Errorf("failed to update reminder rule threshold_sec: %w", err)
+			}
+		}
+		if input.WebhookURL != nil {
+			if _, err := tx.ExecContext(ctx, `UPDATE reminder_rules SET webhook_url = ? WHERE id = ? AND user_id = ?`, nullable(*input.WebhookURL), id, userID); err != nil {
+				return fmt.Errorf("failed to update reminder rule webhook_url: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteContext removes one of the user's rules and the record of where it
+// fired.
+func (r *RuleRepository) DeleteContext(ctx context.Context, userID, id int64) error {
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM reminder_fires WHERE rule_id IN (SELECT id FROM reminder_rules WHERE id = ? AND user_id = ?)`, id, userID); err != nil {
+			return fmt.Errorf("failed to remove reminder fires: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM reminder_rules WHERE id = ? AND user_id = ?`, id, userID); err != nil {
+			return fmt.Errorf("failed to delete reminder rule: %w", err)
+		}
+		return nil
+	})
+}
+
+// OwnersContext returns the IDs of the users that have at least one rule.
+func (r *RuleRepository) OwnersContext(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.Reader().QueryContext(ctx, `SELECT DISTINCT user_id FROM reminder_rules ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder rule owners: %w", err)
+	}
+	defer rows.Close()
+
+	owners := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan reminder rule owner: %w", err)
+		}
+		owners = append(owners, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reminder rule owners: %w", err)
+	}
+	return owners, nil
+}
+
+// RecordFireContext records that a rule fired for a session. It reports
+// false, recording nothing, if the rule had already fired for it.
+func (r *RuleRepository) RecordFireContext(ctx context.Context, sessionID, ruleID int64, at time.Time) (bool, error) {
+	var recorded bool
+	err := r.db.InTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO reminder_fires (session_id, rule_id, fired_at) VALUES (?, ?, ?)`,
+			sessionID, ruleID, at.UTC().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to record reminder fire: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		recorded = n > 0
+		return nil
+	})
+	return recorded, err
+}
+
+// FiredContext returns the IDs of the rules that have fired for a session,
+// in the order they fired.
+func (r *RuleRepository) FiredContext(ctx context.Context, sessionID int64) ([]int64, error) {
+	rows, err := r.db.Reader().QueryContext(ctx,
+		`SELECT rule_id FROM reminder_fires WHERE session_id = ? ORDER BY fired_at, rule_id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder fires: %w", err)
+	}
+	defer rows.Close()
+
+	fired := []int64{}
+	for rows.Next() {
+		var ruleID int64
+		if err := rows.Scan(&ruleID); err != nil {
+			return nil, fmt.Errorf("failed to scan reminder fire: %w", err)
+		}
+		fired = append(fired, ruleID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reminder fires: %w", err)
+	}
+	return fired, nil
+}
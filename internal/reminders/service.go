@@ -0,0 +1,130 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"time-tracker/internal/shared/auth"
+)
+
+// ReminderService manages reminder rules and records where they fired,
+// for the user each context acts for.
+type ReminderService struct {
+	repo *RuleRepository
+}
+
+func NewReminderService(repo *RuleRepository) *ReminderService {
+	return &ReminderService{repo: repo}
+}
+
+// Create adds a rule.
+func (s *ReminderService) Create(input *RuleCreate) (*Rule, error) {
+	return s.CreateContext(auth.DefaultUserContext(), input)
+}
+
+// CreateContext is like Create but takes a context for cancellation.
+func (s *ReminderService) CreateContext(ctx context.Context, input *RuleCreate) (*Rule, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	return s.repo.CreateContext(ctx, userID, input)
+}
+
+// Get returns a rule. Returns ErrRuleNotFound if it does not exist.
+func (s *ReminderService) Get(id int64) (*Rule, error) {
+	return s.GetContext(auth.DefaultUserContext(), id)
+}
+
+// GetContext is like Get but takes a context for cancellation.
+func (s *ReminderService) GetContext(ctx context.Context, id int64) (*Rule, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.repo.GetByIDContext(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+// List returns every rule, shortest threshold first.
+func (s *ReminderService) List() ([]Rule, error) {
+	return s.ListContext(auth.DefaultUserContext())
+}
+
+// ListContext is like List but takes a context for cancellation.
+func (s *ReminderService) ListContext(ctx context.Context) ([]Rule, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListContext(ctx, userID)
+}
+
+// Update changes a rule. Returns ErrRuleNotFound if it does not exist.
+// Sessions the rule already fired for are not reminded again.
+func (s *ReminderService) Update(id int64, input *RuleUpdate) (*Rule, error) {
+	return s.UpdateContext(auth.DefaultUserContext(), id, input)
+}
+
+// UpdateContext is like Update but takes a context for cancellation.
+func (s *ReminderService) UpdateContext(ctx context.Context, id int64, input *RuleUpdate) (*Rule, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if _, err := s.GetContext(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateContext(ctx, userID, id, input); err != nil {
+		return nil, err
+	}
+	return s.GetContext(ctx, id)
+}
+
+// Delete removes a rule. Returns ErrRuleNotFound if it does not exist.
+func (s *ReminderService) Delete(id int64) error {
+	return s.DeleteContext(auth.DefaultUserContext(), id)
+}
+
+// DeleteContext is like Delete but takes a context for cancellation.
+func (s *ReminderService) DeleteContext(ctx context.Context, id int64) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := s.GetContext(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteContext(ctx, userID, id)
+}
+
+// OwnersContext returns the IDs of the users that have at least one rule.
+func (s *ReminderService) OwnersContext(ctx context.Context) ([]int64, error) {
+	return s.repo.OwnersContext(ctx)
+}
+
+// RecordFireContext records that a rule fired for a session at the given
+// time. It reports false if the rule had already fired for the session,
+// so a rule fires at most once per session however often it is checked.
+func (s *ReminderService) RecordFireContext(ctx context.Context, sessionID, ruleID int64, at time.Time) (bool, error) {
+	return s.repo.RecordFireContext(ctx, sessionID, ruleID, at)
+}
+
+// FiredContext returns the IDs of the rules that have fired for a session,
+// in the order they fired.
+func (s *ReminderService) FiredContext(ctx context.Context, sessionID int64) ([]int64, error) {
+	return s.repo.FiredContext(ctx, sessionID)
+}
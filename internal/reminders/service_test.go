@@ -0,0 +1,125 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/shared/database"
+)
+
+func setupTestService(t *testing.T) (*ReminderService, *database.DB) {
+	t.Helper()
+	db := database.NewForTesting(t)
+	return NewReminderService(NewRuleRepository(db)), db
+}
+
+func TestReminderService_CRUD(t *testing.T) {
+	svc, _ := setupTestService(t)
+
+	rule, err := svc.Create(&RuleCreate{Category: " work ", ThresholdSec: 3600, WebhookURL: "https://hooks.example.com/remind"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Category == nil || *rule.Category != "work" || rule.ThresholdSec != 3600 || rule.WebhookURL == nil || rule.CreatedAt == "" {
+		t.Errorf("created rule = %+v", rule)
+	}
+	anyCategory, err := svc.Create(&RuleCreate{ThresholdSec: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anyCategory.Category != nil || anyCategory.WebhookURL != nil || !anyCategory.Matches("anything") {
+		t.Errorf("rule without category or webhook = %+v", anyCategory)
+	}
+
+	list, err := svc.List()
+	if err != nil || len(list) != 2 || list[0].ID != anyCategory.ID {
+		t.Fatalf("list = %+v, %v; want the shortest threshold first", list, err)
+	}
+
+	// Empty strings clear the category and webhook
+	empty, threshold := "", int64(7200)
+	updated, err := svc.Update(rule.ID, &RuleUpdate{Category: &empty, ThresholdSec: &threshold, WebhookURL: &empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Category != nil || updated.WebhookURL != nil || updated.ThresholdSec != 7200 {
+		t.Errorf("updated rule = %+v", updated)
+	}
+
+	for name, input := range map[string]RuleCreate{
+		"no threshold":      {Category: "work"},
+		"negative":          {ThresholdSec: -1},
+		"relative webhook":  {ThresholdSec: 60, WebhookURL: "/hook"},
+		"ftp webhook":       {ThresholdSec: 60, WebhookURL: "ftp://example.com/hook"},
+		"category too long": {ThresholdSec: 60, Category: strings.Repeat("x", 51)},
+	} {
+		if _, err := svc.Create(&input); err == nil || !strings.Contains(err.Error(), "validation error") {
+			t.Errorf("%s: err = %v, want a validation error", name, err)
+		}
+	}
+
+	if err := svc.Delete(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get(rule.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("get deleted rule: err = %v", err)
+	}
+	if _, err := svc.Update(rule.ID, &RuleUpdate{}); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("update deleted rule: err = %v", err)
+	}
+	if err := svc.Delete(rule.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("delete twice: err = %v", err)
+	}
+}
+
+func TestReminderService_RecordFire(t *testing.T) {
+	svc, db := setupTestService(t)
+	if _, err := db.Exec(`INSERT INTO sessions (id, category, task, started_at, status) VALUES (1, 'work', 'report', '2024-03-01T10:00:00Z', 'running')`); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := svc.Create(&RuleCreate{ThresholdSec: 60})
+	second, _ := svc.Create(&RuleCreate{ThresholdSec: 120})
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 10, 5, 0, 0, time.UTC)
+
+	for _, step := range []struct {
+		ruleID int64
+		at     time.Time
+		want   bool
+	}{
+		{second.ID, at, true},
+		{first.ID, at.Add(time.Minute), true},
+		{second.ID, at.Add(2 * time.Minute), false},
+	} {
+		recorded, err := svc.RecordFireContext(ctx, 1, step.ruleID, step.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recorded != step.want {
+			t.Errorf("record rule %d: %v, want %v", step.ruleID, recorded, step.want)
+		}
+	}
+
+	fired, err := svc.FiredContext(ctx, 1)
+	if err != nil || len(fired) != 2 || fired[0] != second.ID || fired[1] != first.ID {
+		t.Errorf("fired = %v, %v; want in firing order", fired, err)
+	}
+
+	// Deleting a rule or the session forgets where it fired
+	if err := svc.Delete(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fired, _ := svc.FiredContext(ctx, 1); len(fired) != 1 {
+		t.Errorf("fired after deleting a rule = %v", fired)
+	}
+	if _, err := db.Exec(`DELETE FROM sessions WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM reminder_fires`).Scan(&left); err != nil || left != 0 {
+		t.Errorf("%d fires left after deleting the session (%v)", left, err)
+	}
+}
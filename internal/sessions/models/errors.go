@@ -0,0 +1,74 @@
+package models
+
+import "strings"
+
+// FieldError is a validation error about one input field. It unwraps to
+// the sentinel error describing the problem, such as ErrNoteTooLong.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error returns the sentinel's message, so a single field error reads as
+// it did before fields were named.
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the sentinel error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects every field that failed validation, in the
+// order the fields were checked. errors.Is matches any of their sentinels.
+type ValidationErrors []*FieldError
+
+// Error joins the field errors' messages.
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, fe := range v {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the field errors.
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, fe := range v {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Fields returns each failed field's message by field name. A field that
+// failed more than once keeps its first message.
+func (v ValidationErrors) Fields() map[string]string {
+	fields := make(map[string]string, len(v))
+	for _, fe := range v {
+		if _, ok := fields[fe.Field]; !ok {
+			fields[fe.Field] = fe.Error()
+		}
+	}
+	return fields
+}
+
+// add records err for field.
+func (v *ValidationErrors) add(field string, err error) {
+	*v = append(*v, &FieldError{Field: field, Err: err})
+}
+
+// err returns v as an error, or nil if no field failed.
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// FieldErr returns a ValidationErrors holding err for field, for checks
+// made outside the Validate methods.
+func FieldErr(field string, err error) error {
+	return ValidationErrors{{Field: field, Err: err}}
+}
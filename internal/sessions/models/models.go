@@ -3,13 +3,16 @@ package models
 
 import (
 	"errors"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"time-tracker/internal/shared/config"
 	"time-tracker/internal/shared/validation"
 )
 
-// Field length constraints
+// Field length constraints, in runes, so a CJK character or an emoji
+// counts once whatever its encoded size
 const (
 	CategoryMinLen = 1
 	CategoryMaxLen = 50
@@ -29,10 +32,16 @@ var (
 	ErrNoteTooLong      = errors.New("note must be at most 1000 characters")
 	ErrLocationTooLong  = errors.New("location must be at most 100 characters")
 	ErrMoodTooLong      = errors.New("mood must be at most 20 characters")
+	ErrInvalidFrom      = errors.New("from must be an RFC3339 timestamp or YYYY-MM-DD date")
+	ErrInvalidTo        = errors.New("to must be an RFC3339 timestamp or YYYY-MM-DD date")
+	ErrInvalidSort      = errors.New("sort must be started_at or updated_at")
+	ErrInvalidTagID     = errors.New("tag_id must be a positive integer")
+	ErrStartedInFuture  = errors.New("started_at must not be in the future")
+	ErrInvalidStartedAt = errors.New("started_at must be an RFC3339 timestamp")
+	ErrInvalidEndedAt   = errors.New("ended_at must be an RFC3339 timestamp")
+	ErrEndedBeforeStart = errors.New("ended_at must not be before started_at")
 )
 
-
-
 // SessionStart represents the input for starting a new session.
 type SessionStart struct {
 	Category string  `json:"category"`
@@ -42,9 +51,25 @@ type SessionStart struct {
 	Mood     *string `json:"mood,omitempty"`
 }
 
+// Defaults are the category and task given to sessions started without one.
+type Defaults struct {
+	Category string
+	Task     string
+}
+
+// BuiltinDefaults are the Defaults used unless others are configured.
+var BuiltinDefaults = Defaults{Category: config.DefaultCategory, Task: config.DefaultTask}
+
 // Validate checks if the SessionStart fields meet the requirements and sanitizes inputs.
 // Special characters are preserved (not escaped) as they are safely stored via parameterized queries.
+// A missing category or task is set from BuiltinDefaults.
 func (s *SessionStart) Validate() error {
+	return s.ValidateWithDefaults(BuiltinDefaults)
+}
+
+// ValidateWithDefaults is like Validate, setting a missing category or task
+// from defaults.
+func (s *SessionStart) ValidateWithDefaults(defaults Defaults) error {
 	// Sanitize inputs
 	s.Category = validation.SanitizeString(s.Category)
 	s.Task = validation.SanitizeString(s.Task)
@@ -53,117 +78,151 @@ func (s *SessionStart) Validate() error {
 	s.Mood = validation.SanitizeStringPtr(s.Mood)
 
 	// Validate required fields
+	var errs ValidationErrors
 	if s.Category == "" {
-		s.Category = config.DefaultCategory
+		s.Category = defaults.Category
 	}
-	if len(s.Category) > CategoryMaxLen {
-		return ErrCategoryTooLong
+	if utf8.RuneCountInString(s.Category) > CategoryMaxLen {
+		errs.add("category", ErrCategoryTooLong)
 	}
 
 	if s.Task == "" {
-		s.Task = config.DefaultTask
+		s.Task = defaults.Task
 	}
-	if len(s.Task) > TaskMaxLen {
-		return ErrTaskTooLong
+	if utf8.RuneCountInString(s.Task) > TaskMaxLen {
+		errs.add("task", ErrTaskTooLong)
 	}
 
-	if s.Note != nil && len(*s.Note) > NoteMaxLen {
-		return ErrNoteTooLong
+	if s.Note != nil && utf8.RuneCountInString(*s.Note) > NoteMaxLen {
+		errs.add("note", ErrNoteTooLong)
 	}
 
-	if s.Location != nil && len(*s.Location) > LocationMaxLen {
-		return ErrLocationTooLong
+	if s.Location != nil && utf8.RuneCountInString(*s.Location) > LocationMaxLen {
+		errs.add("location", ErrLocationTooLong)
 	}
 
-	if s.Mood != nil && len(*s.Mood) > MoodMaxLen {
-		return ErrMoodTooLong
+	if s.Mood != nil && utf8.RuneCountInString(*s.Mood) > MoodMaxLen {
+		errs.add("mood", ErrMoodTooLong)
 	}
 
-	return nil
+	return errs.err()
 }
 
-// SessionStop represents the input for stopping a session.
+// SessionStop represents the input for stopping a session. Note, location
+// and mood are left alone when omitted, cleared when null and otherwise
+// replaced, with an empty string stored as such.
 type SessionStop struct {
-	Note     *string `json:"note,omitempty"`
-	Location *string `json:"location,omitempty"`
-	Mood     *string `json:"mood,omitempty"`
+	Note     Optional[string] `json:"note"`
+	Location Optional[string] `json:"location"`
+	Mood     Optional[string] `json:"mood"`
 }
 
 // Validate checks if the SessionStop fields meet the requirements and sanitizes inputs.
 // Special characters are preserved (not escaped) as they are safely stored via parameterized queries.
+// Every failed field is reported in a ValidationErrors.
 func (s *SessionStop) Validate() error {
-	// Sanitize inputs
-	s.Note = validation.SanitizeStringPtr(s.Note)
-	s.Location = validation.SanitizeStringPtr(s.Location)
-	s.Mood = validation.SanitizeStringPtr(s.Mood)
+	var errs ValidationErrors
+	validateDetails(&errs, &s.Note, &s.Location, &s.Mood)
+	return errs.err()
+}
 
-	if s.Note != nil && len(*s.Note) > NoteMaxLen {
-		return ErrNoteTooLong
+// validateDetails sanitizes the given note, location and mood and adds
+// the ones that are too long to errs. Omitted and null fields pass as they
+// are.
+func validateDetails(errs *ValidationErrors, note, location, mood *Optional[string]) {
+	for _, field := range []*Optional[string]{note, location, mood} {
+		if v := field.Ptr(); v != nil {
+			*field = Some(validation.SanitizeString(*v))
+		}
 	}
 
-	if s.Location != nil && len(*s.Location) > LocationMaxLen {
-		return ErrLocationTooLong
+	if v := note.Ptr(); v != nil && utf8.RuneCountInString(*v) > NoteMaxLen {
+		errs.add("note", ErrNoteTooLong)
 	}
 
-	if s.Mood != nil && len(*s.Mood) > MoodMaxLen {
-		return ErrMoodTooLong
+	if v := location.Ptr(); v != nil && utf8.RuneCountInString(*v) > LocationMaxLen {
+		errs.add("location", ErrLocationTooLong)
 	}
 
-	return nil
+	if v := mood.Ptr(); v != nil && utf8.RuneCountInString(*v) > MoodMaxLen {
+		errs.add("mood", ErrMoodTooLong)
+	}
 }
 
-// SessionUpdate represents the input for updating a session.
+// SessionUpdate represents the input for updating a session. Note, location
+// and mood follow the same omitted, null and value states as in SessionStop,
+// as do ended_at and duration_sec: a null clears them, reopening a stopped
+// session. Setting ended_at on a running session stops it, which must be
+// confirmed with allow_stop.
 type SessionUpdate struct {
-	Category  *string `json:"category,omitempty"`
-	Task      *string `json:"task,omitempty"`
-	Note      *string `json:"note,omitempty"`
-	Location  *string `json:"location,omitempty"`
-	Mood      *string `json:"mood,omitempty"`
-	StartedAt *string `json:"started_at,omitempty"`
-	EndedAt   *string `json:"ended_at,omitempty"`
-	DurationSec *int64 `json:"duration_sec,omitempty"`
+	Category    *string          `json:"category,omitempty"`
+	Task        *string          `json:"task,omitempty"`
+	Note        Optional[string] `json:"note"`
+	Location    Optional[string] `json:"location"`
+	Mood        Optional[string] `json:"mood"`
+	StartedAt   *string          `json:"started_at,omitempty"`
+	EndedAt     Optional[string] `json:"ended_at"`
+	DurationSec Optional[int64]  `json:"duration_sec"`
+	AllowStop   bool             `json:"allow_stop,omitempty"`
+	// Status is set by the service when ended_at changes whether the
+	// session runs; clients cannot set it directly
+	Status *string `json:"-"`
 }
 
-// Validate checks if the SessionUpdate fields meet the requirements.
+// Validate checks if the SessionUpdate fields meet the requirements and
+// normalizes started_at and ended_at to RFC3339 UTC. Every failed field is
+// reported in a ValidationErrors.
 func (s *SessionUpdate) Validate() error {
 	// Sanitize inputs
 	s.Category = validation.SanitizeStringPtr(s.Category)
 	s.Task = validation.SanitizeStringPtr(s.Task)
-	s.Note = validation.SanitizeStringPtr(s.Note)
-	s.Location = validation.SanitizeStringPtr(s.Location)
-	s.Mood = validation.SanitizeStringPtr(s.Mood)
 
+	var errs ValidationErrors
 	if s.Category != nil {
 		if *s.Category == "" {
-			return ErrCategoryRequired
-		}
-		if len(*s.Category) > CategoryMaxLen {
-			return ErrCategoryTooLong
+			errs.add("category", ErrCategoryRequired)
+		} else if utf8.RuneCountInString(*s.Category) > CategoryMaxLen {
+			errs.add("category", ErrCategoryTooLong)
 		}
 	}
 
 	if s.Task != nil {
 		if *s.Task == "" {
-			return ErrTaskRequired
-		}
-		if len(*s.Task) > TaskMaxLen {
-			return ErrTaskTooLong
+			errs.add("task", ErrTaskRequired)
+		} else if utf8.RuneCountInString(*s.Task) > TaskMaxLen {
+			errs.add("task", ErrTaskTooLong)
 		}
 	}
 
-	if s.Note != nil && len(*s.Note) > NoteMaxLen {
-		return ErrNoteTooLong
-	}
+	validateDetails(&errs, &s.Note, &s.Location, &s.Mood)
 
-	if s.Location != nil && len(*s.Location) > LocationMaxLen {
-		return ErrLocationTooLong
+	// Stored timestamps are compared and sorted as text, so they must all
+	// be in the same UTC form whatever offset the client sent
+	if s.StartedAt != nil {
+		if startedAt, err := normalizeRFC3339(*s.StartedAt); err != nil {
+			errs.add("started_at", ErrInvalidStartedAt)
+		} else {
+			s.StartedAt = &startedAt
+		}
 	}
-
-	if s.Mood != nil && len(*s.Mood) > MoodMaxLen {
-		return ErrMoodTooLong
+	if v := s.EndedAt.Ptr(); v != nil {
+		if endedAt, err := normalizeRFC3339(*v); err != nil {
+			errs.add("ended_at", ErrInvalidEndedAt)
+		} else {
+			s.EndedAt = Some(endedAt)
+		}
 	}
 
-	return nil
+	return errs.err()
+}
+
+// normalizeRFC3339 re-serializes an RFC3339 timestamp in UTC.
+func normalizeRFC3339(value string) (string, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return "", err
+	}
+	return FormatRFC3339(t), nil
 }
 
 // SessionStatus represents the status of a session.
@@ -185,23 +244,134 @@ type SessionResponse struct {
 	StartedAt   string  `json:"started_at"`
 	EndedAt     *string `json:"ended_at,omitempty"`
 	DurationSec *int64  `json:"duration_sec,omitempty"`
-	Status      string  `json:"status"`
+	// DurationMs is the time between started_at and ended_at in
+	// milliseconds; only set for sessions that have ended
+	DurationMs *int64   `json:"duration_ms,omitempty"`
+	Status     string   `json:"status"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	Tags       []string `json:"tags,omitempty"`
+	// ElapsedSec is how long a running session has run so far. It is only
+	// set where a running session is reported in place of a new one, as in
+	// a conflict response.
+	ElapsedSec *int64 `json:"elapsed_sec,omitempty"`
+}
+
+// Session sort orders accepted by SessionFilter.Sort. Both sort descending.
+const (
+	SessionSortStartedAt = "started_at"
+	SessionSortUpdatedAt = "updated_at"
+)
+
+// SessionFilter selects sessions for listing and export.
+// From/To accept RFC3339 timestamps or YYYY-MM-DD dates (UTC) and bound
+// started_at; To is exclusive. TagID keeps only sessions carrying that tag.
+// Sort picks the order, started_at by default.
+type SessionFilter struct {
+	Status   *string
+	Category *string
+	From     *string
+	To       *string
+	TagID    *int64
+	Sort     string
+}
+
+// Validate normalizes the date bounds to RFC3339 UTC strings. Every failed
+// parameter is reported in a ValidationErrors.
+func (f *SessionFilter) Validate() error {
+	var errs ValidationErrors
+	if f.From != nil {
+		if from, err := validation.ParseTimeBound(*f.From, time.UTC, false); err != nil {
+			errs.add("from", ErrInvalidFrom)
+		} else {
+			f.From = &from
+		}
+	}
+	if f.To != nil {
+		if to, err := validation.ParseTimeBound(*f.To, time.UTC, true); err != nil {
+			errs.add("to", ErrInvalidTo)
+		} else {
+			f.To = &to
+		}
+	}
+	if f.TagID != nil && *f.TagID <= 0 {
+		errs.add("tag_id", ErrInvalidTagID)
+	}
+	switch f.Sort {
+	case "", SessionSortStartedAt, SessionSortUpdatedAt:
+	default:
+		errs.add("sort", ErrInvalidSort)
+	}
+	return errs.err()
 }
 
-// PaginatedResponse wraps a list of items with pagination metadata.
+// PaginatedResponse wraps a list of items with pagination metadata. Page
+// counts from 1 and is the page holding the item at Offset; HasMore is set
+// while items remain after this page. Build it with NewPaginatedResponse.
 type PaginatedResponse[T any] struct {
-	Items  []T   `json:"items"`
-	Total  int64 `json:"total"`
-	Limit  int   `json:"limit"`
-	Offset int   `json:"offset"`
+	Items      []T   `json:"items"`
+	Total      int64 `json:"total"`
+	Limit      int   `json:"limit"`
+	Offset     int   `json:"offset"`
+	Page       int   `json:"page"`
+	TotalPages int   `json:"total_pages"`
+	HasMore    bool  `json:"has_more"`
 }
 
-// FormatRFC3339 formats a time.Time to RFC3339 UTC string.
+// NewPaginatedResponse returns items as the page at offset, limit items
+// long, of total items. An offset at or past total gives an empty page
+// whatever items holds, and Items is never nil, so it encodes as [].
+func NewPaginatedResponse[T any](items []T, total int64, limit, offset int) *PaginatedResponse[T] {
+	if items == nil || int64(offset) >= total {
+		items = []T{}
+	}
+	page := &PaginatedResponse[T]{
+		Items:   items,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		Page:    1,
+		HasMore: int64(offset+len(items)) < total,
+	}
+	if limit > 0 {
+		page.Page = offset/limit + 1
+		page.TotalPages = int((total + int64(limit) - 1) / int64(limit))
+	}
+	return page
+}
+
+// FormatRFC3339 formats a time.Time to RFC3339 UTC string with milliseconds,
+// as config.TimestampLayout.
 func FormatRFC3339(t time.Time) string {
-	return t.UTC().Format(time.RFC3339)
+	return t.UTC().Format(config.TimestampLayout)
 }
 
-// NowRFC3339 returns the current time as RFC3339 UTC string.
+// NowRFC3339 returns the current time as RFC3339 UTC string with milliseconds.
 func NowRFC3339() string {
 	return FormatRFC3339(time.Now())
-}
\ No newline at end of file
+}
+
+// RoundSeconds returns d in whole seconds, rounded to the nearest, so a
+// 1.9 second session counts as 2 seconds rather than 1.
+func RoundSeconds(d time.Duration) int64 {
+	return int64(d.Round(time.Second) / time.Second)
+}
+
+// SetDurationMs sets DurationMs from StartedAt and EndedAt. It is left nil
+// while the session runs or if either timestamp does not parse, and a
+// negative difference is stored as zero, as for DurationSec.
+func (s *SessionResponse) SetDurationMs() {
+	if s.EndedAt == nil {
+		return
+	}
+	start, err := time.Parse(time.RFC3339, s.StartedAt)
+	if err != nil {
+		return
+	}
+	end, err := time.Parse(time.RFC3339, *s.EndedAt)
+	if err != nil {
+		return
+	}
+	ms := max(end.Sub(start).Milliseconds(), 0)
+	s.DurationMs = &ms
+}
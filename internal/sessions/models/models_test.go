@@ -1,7 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"pgregory.net/rapid"
 	"time-tracker/internal/shared/config"
@@ -55,4 +59,198 @@ func TestSessionStart_MissingTask(t *testing.T) {
 	if session.Task != config.DefaultTask {
 		t.Fatalf("expected default task %q, got %q", config.DefaultTask, session.Task)
 	}
-}
\ No newline at end of file
+}
+// TestSessionStart_ValidateWithDefaults ensures missing fields take the given defaults.
+func TestSessionStart_ValidateWithDefaults(t *testing.T) {
+	defaults := Defaults{Category: "misc", Task: "untitled"}
+
+	session := &SessionStart{Category: " ", Task: ""}
+	if err := session.ValidateWithDefaults(defaults); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if session.Category != "misc" || session.Task != "untitled" {
+		t.Fatalf("expected the given defaults, got %q and %q", session.Category, session.Task)
+	}
+
+	session = &SessionStart{Category: "work", Task: "report"}
+	if err := session.ValidateWithDefaults(defaults); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if session.Category != "work" || session.Task != "report" {
+		t.Fatalf("given fields were replaced: %q and %q", session.Category, session.Task)
+	}
+}
+
+// TestSessionUpdate_NormalizesTimestamps ensures offsets are stored as UTC.
+func TestSessionUpdate_NormalizesTimestamps(t *testing.T) {
+	startedAt, endedAt := "2024-01-02T07:00:00+08:00", "2024-01-02T08:30:00.5+08:00"
+	update := &SessionUpdate{StartedAt: &startedAt, EndedAt: Some(endedAt)}
+	if err := update.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if *update.StartedAt != "2024-01-01T23:00:00.000Z" || *update.EndedAt.Ptr() != "2024-01-02T00:30:00.500Z" {
+		t.Fatalf("expected UTC timestamps, got %q and %q", *update.StartedAt, *update.EndedAt.Ptr())
+	}
+
+	bad := "2024-01-02 07:00"
+	if err := (&SessionUpdate{StartedAt: &bad}).Validate(); !errors.Is(err, ErrInvalidStartedAt) {
+		t.Fatalf("expected ErrInvalidStartedAt, got %v", err)
+	}
+	if err := (&SessionUpdate{EndedAt: Some(bad)}).Validate(); !errors.Is(err, ErrInvalidEndedAt) {
+		t.Fatalf("expected ErrInvalidEndedAt, got %v", err)
+	}
+}
+
+// TestSessionUpdate_OptionalDetails ensures note, location and mood tell
+// omitted, null and empty string apart through decoding and validation.
+func TestSessionUpdate_OptionalDetails(t *testing.T) {
+	var update SessionUpdate
+	if err := json.Unmarshal([]byte(`{"note":null,"location":"  "}`), &update); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := update.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !update.Note.IsNull() {
+		t.Errorf("expected a null note, got %+v", update.Note)
+	}
+	if loc := update.Location.Ptr(); !update.Location.IsSet() || loc == nil || *loc != "" {
+		t.Errorf("expected an empty location, got %+v", update.Location)
+	}
+	if update.Mood.IsSet() {
+		t.Errorf("expected the mood to be omitted, got %+v", update.Mood)
+	}
+
+	long := make([]byte, MoodMaxLen+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	if err := (&SessionStop{Mood: Some(string(long))}).Validate(); !errors.Is(err, ErrMoodTooLong) {
+		t.Fatalf("expected ErrMoodTooLong, got %v", err)
+	}
+}
+
+// TestSessionStart_RuneLengths ensures limits count runes, not bytes.
+func TestSessionStart_RuneLengths(t *testing.T) {
+	// 50 CJK characters are 150 bytes
+	category := strings.Repeat("学", CategoryMaxLen)
+	task := strings.Repeat("😀", TaskMaxLen)
+	session := &SessionStart{Category: category, Task: task}
+	if err := session.Validate(); err != nil {
+		t.Fatalf("expected limits in runes to pass, got %v", err)
+	}
+
+	session = &SessionStart{Category: category + "学", Task: "report"}
+	if err := session.Validate(); !errors.Is(err, ErrCategoryTooLong) {
+		t.Fatalf("expected ErrCategoryTooLong, got %v", err)
+	}
+	session = &SessionStart{Category: "work", Task: task + "😀"}
+	if err := session.Validate(); !errors.Is(err, ErrTaskTooLong) {
+		t.Fatalf("expected ErrTaskTooLong, got %v", err)
+	}
+
+	mood := strings.Repeat("好", MoodMaxLen)
+	if err := (&SessionStop{Mood: Some(mood)}).Validate(); err != nil {
+		t.Fatalf("expected a %d-rune mood to pass, got %v", MoodMaxLen, err)
+	}
+	if err := (&SessionStop{Mood: Some(mood + "好")}).Validate(); !errors.Is(err, ErrMoodTooLong) {
+		t.Fatalf("expected ErrMoodTooLong, got %v", err)
+	}
+}
+
+// TestSessionResponse_SubSecondDurations ensures durations round to the
+// nearest second and duration_ms keeps the milliseconds.
+func TestSessionResponse_SubSecondDurations(t *testing.T) {
+	if got := RoundSeconds(1900 * time.Millisecond); got != 2 {
+		t.Errorf("RoundSeconds(1.9s) = %d, want 2", got)
+	}
+	if got := RoundSeconds(1400 * time.Millisecond); got != 1 {
+		t.Errorf("RoundSeconds(1.4s) = %d, want 1", got)
+	}
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	endedAt := FormatRFC3339(start.Add(1900 * time.Millisecond))
+	if endedAt != "2024-01-01T09:00:01.900Z" {
+		t.Fatalf("FormatRFC3339 = %q, want milliseconds", endedAt)
+	}
+	session := &SessionResponse{StartedAt: FormatRFC3339(start), EndedAt: &endedAt}
+	session.SetDurationMs()
+	if session.DurationMs == nil || *session.DurationMs != 1900 {
+		t.Errorf("DurationMs = %v, want 1900", session.DurationMs)
+	}
+
+	// Whole-second timestamps from before milliseconds still parse
+	legacyEnd := "2024-01-01T09:00:02Z"
+	session = &SessionResponse{StartedAt: "2024-01-01T09:00:00Z", EndedAt: &legacyEnd}
+	session.SetDurationMs()
+	if session.DurationMs == nil || *session.DurationMs != 2000 {
+		t.Errorf("legacy DurationMs = %v, want 2000", session.DurationMs)
+	}
+
+	running := &SessionResponse{StartedAt: FormatRFC3339(start)}
+	running.SetDurationMs()
+	if running.DurationMs != nil {
+		t.Errorf("running session DurationMs = %d, want nil", *running.DurationMs)
+	}
+}
+
+// TestSessionUpdate_CollectsFieldErrors ensures every failed field is
+// reported, by name, and each sentinel still matches with errors.Is.
+func TestSessionUpdate_CollectsFieldErrors(t *testing.T) {
+	long, bad := strings.Repeat("c", CategoryMaxLen+1), "yesterday"
+	update := &SessionUpdate{
+		Category:  &long,
+		Note:      Some(strings.Repeat("n", NoteMaxLen+1)),
+		StartedAt: &bad,
+	}
+	err := update.Validate()
+
+	var fieldErrs ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("expected ValidationErrors, got %T %v", err, err)
+	}
+	want := map[string]error{"category": ErrCategoryTooLong, "note": ErrNoteTooLong, "started_at": ErrInvalidStartedAt}
+	fields := fieldErrs.Fields()
+	if len(fields) != len(want) {
+		t.Fatalf("fields = %v, want %d of them", fields, len(want))
+	}
+	for field, sentinel := range want {
+		if fields[field] != sentinel.Error() {
+			t.Errorf("fields[%q] = %q, want %q", field, fields[field], sentinel)
+		}
+		if !errors.Is(err, sentinel) {
+			t.Errorf("errors.Is(err, %v) = false", sentinel)
+		}
+	}
+
+	// A single failure reads as its sentinel did
+	if err := (&SessionStop{Mood: Some(strings.Repeat("m", MoodMaxLen+1))}).Validate(); err.Error() != ErrMoodTooLong.Error() {
+		t.Errorf("single error message = %q, want %q", err, ErrMoodTooLong)
+	}
+}
+
+// TestNewPaginatedResponse ensures page metadata follows from total, limit
+// and offset, and an offset past the end gives an empty page.
+func TestNewPaginatedResponse(t *testing.T) {
+	page := NewPaginatedResponse([]int{1, 2}, 5, 2, 2)
+	if page.Page != 2 || page.TotalPages != 3 || !page.HasMore {
+		t.Errorf("middle page = %+v, want page 2 of 3 with more", page)
+	}
+
+	page = NewPaginatedResponse([]int{5}, 5, 2, 4)
+	if page.Page != 3 || page.HasMore {
+		t.Errorf("last page = %+v, want page 3 without more", page)
+	}
+
+	page = NewPaginatedResponse[int](nil, 5, 2, 10)
+	if page.Items == nil || len(page.Items) != 0 || page.HasMore || page.TotalPages != 3 {
+		t.Errorf("page past the end = %+v, want empty items", page)
+	}
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"items":[]`) || !strings.Contains(string(data), `"has_more":false`) {
+		t.Errorf("page past the end encodes as %s", data)
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Optional is an update field that tells an omitted field from an explicit
+// null. The zero value is omitted and leaves the column alone; a null clears
+// it; any other value, the empty string included, is stored as given.
+type Optional[T any] struct {
+	set   bool
+	value *T
+}
+
+// Some returns an Optional set to v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{set: true, value: &v}
+}
+
+// Null returns an Optional set to null.
+func Null[T any]() Optional[T] {
+	return Optional[T]{set: true}
+}
+
+// IsSet reports whether the field was given, as null or as a value.
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// IsNull reports whether the field was given as null.
+func (o Optional[T]) IsNull() bool {
+	return o.set && o.value == nil
+}
+
+// Ptr returns the value, or nil if the field was omitted or null.
+func (o Optional[T]) Ptr() *T {
+	return o.value
+}
+
+// Value writes a null field as NULL, so an Optional can be passed to a
+// query as is. T must be a type the driver accepts.
+func (o Optional[T]) Value() (driver.Value, error) {
+	if o.value == nil {
+		return nil, nil
+	}
+	return *o.value, nil
+}
+
+// UnmarshalJSON is only called for fields present in the input, which is
+// how an omitted field stays unset.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*o = Null[T]()
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Some(v)
+	return nil
+}
+
+// MarshalJSON writes an omitted or null field as null.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.value)
+}
@@ -55,7 +55,7 @@ func TestValidation_Property13_SessionStopSecurity(t *testing.T) {
 		malicious := rapid.SampledFrom(maliciousInputs).Draw(t, "malicious")
 
 		session := &SessionStop{
-			Note: &malicious,
+			Note: Some(malicious),
 		}
 
 		err := session.Validate()
@@ -65,8 +65,8 @@ func TestValidation_Property13_SessionStopSecurity(t *testing.T) {
 
 		// Content should be preserved
 		expected := strings.TrimSpace(malicious)
-		if session.Note == nil || *session.Note != expected {
-			t.Fatalf("malicious input not preserved: expected %q, got %v", expected, session.Note)
+		if note := session.Note.Ptr(); note == nil || *note != expected {
+			t.Fatalf("malicious input not preserved: expected %q, got %v", expected, note)
 		}
 	})
 }
@@ -1,15 +1,42 @@
 package repository
 
-import "time-tracker/internal/sessions/models"
+import (
+	"context"
+
+	"time-tracker/internal/sessions/models"
+)
 
 // SessionRepositoryInterface defines the interface for session repository operations.
+// SessionService depends only on this, so tests can substitute a
+// repository that misbehaves in a particular way. Every operation but
+// PurgeBefore acts on the sessions of one user.
 type SessionRepositoryInterface interface {
-	Create(session *models.SessionStart) (*models.SessionResponse, error)
-	Delete(id int64) error
-	GetRunning() (*models.SessionResponse, error)
-	StopRunning(updates *models.SessionStop) (*models.SessionResponse, error)
-	List(limit, offset int, status, category *string) ([]models.SessionResponse, error)
-	Count(status, category *string) (int64, error)
-	GetByID(id int64) (*models.SessionResponse, error)
-	Update(id int64, data *models.SessionUpdate) error
+	Create(userID int64, session *models.SessionStart) (*models.SessionResponse, error)
+	CreateContext(ctx context.Context, userID int64, session *models.SessionStart) (*models.SessionResponse, error)
+	CreateStopped(userID int64, session *models.SessionStart, startedAt, endedAt string, durationSec int64) (*models.SessionResponse, error)
+	CreateStoppedContext(ctx context.Context, userID int64, session *models.SessionStart, startedAt, endedAt string, durationSec int64) (*models.SessionResponse, error)
+	Delete(userID, id int64) error
+	DeleteContext(ctx context.Context, userID, id int64) error
+	GetRunning(userID int64) (*models.SessionResponse, error)
+	GetRunningContext(ctx context.Context, userID int64) (*models.SessionResponse, error)
+	StopRunning(userID int64, updates *models.SessionStop) (*models.SessionResponse, error)
+	StopRunningContext(ctx context.Context, userID int64, updates *models.SessionStop) (*models.SessionResponse, error)
+	List(userID int64, limit, offset int, filter *models.SessionFilter) ([]models.SessionResponse, error)
+	ListContext(ctx context.Context, userID int64, limit, offset int, filter *models.SessionFilter) ([]models.SessionResponse, error)
+	ListWithTotal(userID int64, limit, offset int, filter *models.SessionFilter) ([]models.SessionResponse, int64, error)
+	ListWithTotalContext(ctx context.Context, userID int64, limit, offset int, filter *models.SessionFilter) ([]models.SessionResponse, int64, error)
+	Count(userID int64, filter *models.SessionFilter) (int64, error)
+	CountContext(ctx context.Context, userID int64, filter *models.SessionFilter) (int64, error)
+	Categories(userID int64) ([]string, error)
+	CategoriesContext(ctx context.Context, userID int64) ([]string, error)
+	GetByID(userID, id int64) (*models.SessionResponse, error)
+	GetByIDContext(ctx context.Context, userID, id int64) (*models.SessionResponse, error)
+	Update(userID, id int64, data *models.SessionUpdate) error
+	UpdateContext(ctx context.Context, userID, id int64, data *models.SessionUpdate) error
+	Iterate(userID int64, filter *models.SessionFilter, withTags bool, fn func(*models.SessionResponse) error) error
+	IterateContext(ctx context.Context, userID int64, filter *models.SessionFilter, withTags bool, fn func(*models.SessionResponse) error) error
+	PurgeBefore(before string, anonymize bool) (int64, error)
+	PurgeBeforeContext(ctx context.Context, before string, anonymize bool) (int64, error)
 }
+
+var _ SessionRepositoryInterface = (*SessionRepository)(nil)
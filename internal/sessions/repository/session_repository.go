@@ -1,9 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -13,36 +15,115 @@ import (
 	"time-tracker/internal/shared/utils"
 )
 
-// ErrNoRunningSession is returned when no running session exists.
-var ErrNoRunningSession = errors.New("no running session found")
+// Repository errors
+var (
+	// ErrNoRunningSession is returned when no running session exists.
+	ErrNoRunningSession = errors.New("no running session found")
+	// ErrSessionAlreadyRunning is returned by Create when another session
+	// is already running.
+	ErrSessionAlreadyRunning = errors.New("a session is already running")
+	// ErrSessionNotFound is returned by Update and Delete when the user has
+	// no session with the given ID.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrMultipleRunning is returned when the user has more than one
+	// running session, which the schema should have prevented.
+	ErrMultipleRunning = errors.New("more than one session is running")
+)
 
 // SessionRepository handles database operations for sessions.
 type SessionRepository struct {
-	db *database.DB
+	db     *database.DB
+	logger *slog.Logger
 }
 
 // NewSessionRepository creates a new SessionRepository.
 func NewSessionRepository(db *database.DB) *SessionRepository {
-	return &SessionRepository{db: db}
+	return &SessionRepository{db: db, logger: slog.Default()}
+}
+
+// SetLogger sets where negative durations clamped to zero are reported.
+func (r *SessionRepository) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		r.logger = logger
+	}
+}
+
+// clampDuration returns durationSec, or zero if it is negative. A negative
+// duration means started_at lies after the end, from an edit or a clock that
+// stepped backwards; it is logged rather than stored.
+func (r *SessionRepository) clampDuration(ctx context.Context, id, durationSec int64) int64 {
+	if durationSec >= 0 {
+		return durationSec
+	}
+	r.logger.WarnContext(ctx, "clamped negative session duration to zero", "session_id", id, "duration_sec", durationSec)
+	return 0
 }
 
-// Create inserts a new session with status "running" and returns the complete SessionResponse.
-func (r *SessionRepository) Create(session *models.SessionStart) (*models.SessionResponse, error) {
+// Create inserts a new session for the user with status "running" and returns the complete SessionResponse.
+// If one of the user's sessions is already running it returns that session together with
+// ErrSessionAlreadyRunning.
+func (r *SessionRepository) Create(userID int64, session *models.SessionStart) (*models.SessionResponse, error) {
+	return r.CreateContext(context.Background(), userID, session)
+}
+
+// CreateContext is like Create, recording a span as a child of the one in ctx.
+func (r *SessionRepository) CreateContext(ctx context.Context, userID int64, session *models.SessionStart) (created *models.SessionResponse, err error) {
+	ctx, span := database.StartSpan(ctx, "create_session")
+	defer func() { span.SetError(err); span.End() }()
+
 	startedAt := models.NowRFC3339()
 	status := string(models.SessionStatusRunning)
 
-	result, err := r.db.Exec(
-		`INSERT INTO sessions (category, task, note, location, mood, started_at, status) 
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		session.Category, session.Task, session.Note, session.Location, session.Mood, startedAt, status,
-	)
+	runningStmt, release, err := r.db.Stmt(ctx, runningQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert session: %w", err)
+		return nil, fmt.Errorf("failed to prepare running session query: %w", err)
+	}
+	defer release()
+	insertStmt, releaseInsert, err := r.db.Stmt(ctx, insertRunningQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare session insert: %w", err)
 	}
+	defer releaseInsert()
 
-	id, err := result.LastInsertId()
+	var id int64
+	var running *models.SessionResponse
+	err = r.db.InTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		running, err = getRunning(ctx, tx.StmtContext(ctx, runningStmt), userID)
+		if err != nil {
+			return err
+		}
+		if running != nil {
+			return ErrSessionAlreadyRunning
+		}
+
+		result, err := tx.StmtContext(ctx, insertStmt).ExecContext(ctx,
+			userID, session.Category, session.Task, session.Note, session.Location, session.Mood, startedAt, status,
+			startedAt, startedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert session: %w", err)
+		}
+
+		id, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrSessionAlreadyRunning) {
+		return running, err
+	}
+	if database.IsUniqueViolation(err) {
+		// Another connection started a session after our check
+		running, err := r.GetRunningContext(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return running, ErrSessionAlreadyRunning
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+		return nil, err
 	}
 
 	return &models.SessionResponse{
@@ -54,12 +135,69 @@ func (r *SessionRepository) Create(session *models.SessionStart) (*models.Sessio
 		Mood:      session.Mood,
 		StartedAt: startedAt,
 		Status:    status,
+		CreatedAt: startedAt,
+		UpdatedAt: startedAt,
 	}, nil
 }
 
-// Delete removes a session entry by ID.
-func (r *SessionRepository) Delete(id int64) error {
-	result, err := r.db.Exec("DELETE FROM sessions WHERE id = ?", id)
+// CreateStopped inserts an already finished session for the user with the given times,
+// as used when importing history from other tools.
+func (r *SessionRepository) CreateStopped(userID int64, session *models.SessionStart, startedAt, endedAt string, durationSec int64) (*models.SessionResponse, error) {
+	return r.CreateStoppedContext(context.Background(), userID, session, startedAt, endedAt, durationSec)
+}
+
+// CreateStoppedContext is like CreateStopped, recording a span as a child of the one in ctx.
+func (r *SessionRepository) CreateStoppedContext(ctx context.Context, userID int64, session *models.SessionStart, startedAt, endedAt string, durationSec int64) (created *models.SessionResponse, err error) {
+	ctx, span := database.StartSpan(ctx, "create_session")
+	defer func() { span.SetError(err); span.End() }()
+
+	status := string(models.SessionStatusStopped)
+	now := models.NowRFC3339()
+
+	result, err := r.db.ExecRetry(ctx,
+		`INSERT INTO sessions (user_id, category, task, note, location, mood, started_at, ended_at, duration_sec, status, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, session.Category, session.Task, session.Note, session.Location, session.Mood,
+		startedAt, endedAt, durationSec, status, now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert session: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	created = &models.SessionResponse{
+		ID:          id,
+		Category:    session.Category,
+		Task:        session.Task,
+		Note:        session.Note,
+		Location:    session.Location,
+		Mood:        session.Mood,
+		StartedAt:   startedAt,
+		EndedAt:     &endedAt,
+		DurationSec: &durationSec,
+		Status:      status,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	created.SetDurationMs()
+	return created, nil
+}
+
+// Delete removes one of the user's session entries by ID.
+func (r *SessionRepository) Delete(userID, id int64) error {
+	return r.DeleteContext(context.Background(), userID, id)
+}
+
+// DeleteContext is like Delete, recording a span as a child of the one in ctx.
+func (r *SessionRepository) DeleteContext(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := database.StartSpan(ctx, "delete_session")
+	defer func() { span.SetError(err); span.End() }()
+
+	result, err := r.db.ExecRetry(ctx, "DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
@@ -70,30 +208,70 @@ func (r *SessionRepository) Delete(id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("session not found")
+		return ErrSessionNotFound
 	}
 
 	return nil
 }
 
+// GetRunning returns the user's currently running session, or nil if none exists.
+// Returns ErrMultipleRunning if the user has more than one.
+func (r *SessionRepository) GetRunning(userID int64) (*models.SessionResponse, error) {
+	return r.GetRunningContext(context.Background(), userID)
+}
+
+// GetRunningContext is like GetRunning, recording a span as a child of the one in ctx.
+func (r *SessionRepository) GetRunningContext(ctx context.Context, userID int64) (running *models.SessionResponse, err error) {
+	ctx, span := database.StartSpan(ctx, "get_running_session")
+	defer func() { span.SetError(err); span.End() }()
+
+	stmt, release, err := r.db.ReadStmt(ctx, runningQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare running session query: %w", err)
+	}
+	defer release()
+	return getRunning(ctx, stmt, userID)
+}
+
+// Statements prepared once and reused, as they run on every start, stop and
+// poll of the current session
+const (
+	runningQuery = `SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status, created_at, updated_at 
+		 FROM sessions WHERE user_id = ? AND status = ? LIMIT 2`
+	insertRunningQuery = `INSERT INTO sessions (user_id, category, task, note, location, mood, started_at, status, created_at, updated_at) 
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stopQuery = `UPDATE sessions SET ended_at = ?, duration_sec = ?, status = ?, note = ?, location = ?, mood = ?, updated_at = ? 
+			 WHERE id = ? AND status = ?`
+)
 
-// GetRunning returns the currently running session, or nil if none exists.
-func (r *SessionRepository) GetRunning() (*models.SessionResponse, error) {
+// getRunning returns the user's running session, or nil if none exists,
+// running the prepared runningQuery stmt. It returns ErrMultipleRunning
+// rather than pick one of several, as the others could then never be stopped.
+func getRunning(ctx context.Context, stmt *sql.Stmt, userID int64) (*models.SessionResponse, error) {
 	var session models.SessionResponse
 	var note, location, mood, endedAt sql.NullString
 	var durationSec sql.NullInt64
 
-	err := r.db.QueryRow(
-		`SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status 
-		 FROM sessions WHERE status = ? LIMIT 1`,
-		string(models.SessionStatusRunning),
-	).Scan(&session.ID, &session.Category, &session.Task, &note, &location, &mood,
-		&session.StartedAt, &endedAt, &durationSec, &session.Status)
+	rows, err := stmt.QueryContext(ctx, userID, string(models.SessionStatusRunning))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query running session: %w", err)
+	}
+	defer rows.Close()
 
-	if err == sql.ErrNoRows {
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to query running session: %w", err)
+		}
 		return nil, nil
 	}
-	if err != nil {
+	if err := rows.Scan(&session.ID, &session.Category, &session.Task, &note, &location, &mood,
+		&session.StartedAt, &endedAt, &durationSec, &session.Status, &session.CreatedAt, &session.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to query running session: %w", err)
+	}
+	if rows.Next() {
+		return nil, ErrMultipleRunning
+	}
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to query running session: %w", err)
 	}
 
@@ -112,98 +290,153 @@ func (r *SessionRepository) GetRunning() (*models.SessionResponse, error) {
 	if durationSec.Valid {
 		session.DurationSec = &durationSec.Int64
 	}
+	session.SetDurationMs()
 
 	return &session, nil
 }
 
-// StopRunning stops the currently running session and updates it with the provided data.
-// Returns ErrNoRunningSession if no running session exists.
-func (r *SessionRepository) StopRunning(updates *models.SessionStop) (*models.SessionResponse, error) {
-	// First get the running session
-	running, err := r.GetRunning()
-	if err != nil {
-		return nil, err
-	}
-	if running == nil {
-		return nil, ErrNoRunningSession
-	}
+// StopRunning stops the user's currently running session and updates it with the provided data.
+// A negative duration is stored as zero. Returns ErrNoRunningSession if the
+// user has no running session.
+func (r *SessionRepository) StopRunning(userID int64, updates *models.SessionStop) (*models.SessionResponse, error) {
+	return r.StopRunningContext(context.Background(), userID, updates)
+}
 
-	endedAt := models.NowRFC3339()
+// StopRunningContext is like StopRunning, recording a span as a child of the one in ctx.
+func (r *SessionRepository) StopRunningContext(ctx context.Context, userID int64, updates *models.SessionStop) (stopped *models.SessionResponse, err error) {
+	ctx, span := database.StartSpan(ctx, "stop_session")
+	defer func() { span.SetError(err); span.End() }()
 
-	// Calculate duration
-	startTime, err := time.Parse(time.RFC3339, running.StartedAt)
+	runningStmt, release, err := r.db.Stmt(ctx, runningQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse started_at: %w", err)
+		return nil, fmt.Errorf("failed to prepare running session query: %w", err)
 	}
-	endTime, err := time.Parse(time.RFC3339, endedAt)
+	defer release()
+	stopStmt, releaseStop, err := r.db.Stmt(ctx, stopQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse ended_at: %w", err)
+		return nil, fmt.Errorf("failed to prepare session stop: %w", err)
 	}
-	durationSec := int64(endTime.Sub(startTime).Seconds())
+	defer releaseStop()
 
-	// Merge updates with existing values
-	note := running.Note
-	if updates.Note != nil {
-		note = updates.Note
-	}
-	location := running.Location
-	if updates.Location != nil {
-		location = updates.Location
-	}
-	mood := running.Mood
-	if updates.Mood != nil {
-		mood = updates.Mood
-	}
+	err = r.db.InTx(ctx, func(tx *sql.Tx) error {
+		// First get the running session
+		running, err := getRunning(ctx, tx.StmtContext(ctx, runningStmt), userID)
+		if err != nil {
+			return err
+		}
+		if running == nil {
+			return ErrNoRunningSession
+		}
 
-	_, err = r.db.Exec(
-		`UPDATE sessions SET ended_at = ?, duration_sec = ?, status = ?, note = ?, location = ?, mood = ? 
-		 WHERE id = ?`,
-		endedAt, durationSec, string(models.SessionStatusStopped), note, location, mood, running.ID,
-	)
+		endedAt := models.NowRFC3339()
+
+		// Calculate duration
+		startTime, err := time.Parse(time.RFC3339, running.StartedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse started_at: %w", err)
+		}
+		endTime, err := time.Parse(time.RFC3339, endedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse ended_at: %w", err)
+		}
+		durationSec := r.clampDuration(ctx, running.ID, models.RoundSeconds(endTime.Sub(startTime)))
+
+		// Merge updates with existing values
+		note := running.Note
+		if updates.Note.IsSet() {
+			note = updates.Note.Ptr()
+		}
+		location := running.Location
+		if updates.Location.IsSet() {
+			location = updates.Location.Ptr()
+		}
+		mood := running.Mood
+		if updates.Mood.IsSet() {
+			mood = updates.Mood.Ptr()
+		}
+
+		_, err = tx.StmtContext(ctx, stopStmt).ExecContext(ctx,
+			endedAt, durationSec, string(models.SessionStatusStopped), note, location, mood, endedAt, running.ID,
+			string(models.SessionStatusRunning),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+
+		stopped = &models.SessionResponse{
+			ID:          running.ID,
+			Category:    running.Category,
+			Task:        running.Task,
+			Note:        note,
+			Location:    location,
+			Mood:        mood,
+			StartedAt:   running.StartedAt,
+			EndedAt:     &endedAt,
+			DurationSec: &durationSec,
+			Status:      string(models.SessionStatusStopped),
+			CreatedAt:   running.CreatedAt,
+			UpdatedAt:   endedAt,
+		}
+		stopped.SetDurationMs()
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update session: %w", err)
+		return nil, err
 	}
+	return stopped, nil
+}
 
-	return &models.SessionResponse{
-		ID:          running.ID,
-		Category:    running.Category,
-		Task:        running.Task,
-		Note:        note,
-		Location:    location,
-		Mood:        mood,
-		StartedAt:   running.StartedAt,
-		EndedAt:     &endedAt,
-		DurationSec: &durationSec,
-		Status:      string(models.SessionStatusStopped),
-	}, nil
+// List retrieves the user's sessions with pagination and optional filters.
+// Results are ordered by started_at descending.
+func (r *SessionRepository) List(userID int64, limit, offset int, filter *models.SessionFilter) ([]models.SessionResponse, error) {
+	return r.ListContext(context.Background(), userID, limit, offset, filter)
 }
 
+// ListContext is like List, recording a span as a child of the one in ctx.
+func (r *SessionRepository) ListContext(ctx context.Context, userID int64, limit, offset int, filter *models.SessionFilter) (sessions []models.SessionResponse, err error) {
+	ctx, span := database.StartSpan(ctx, "list_sessions")
+	defer func() { span.SetError(err); span.End() }()
 
-// List retrieves sessions with pagination and optional filters.
-// Results are ordered by started_at descending.
-func (r *SessionRepository) List(limit, offset int, status, category *string) ([]models.SessionResponse, error) {
-	query := "SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status FROM sessions"
-	args := []interface{}{}
-	conditions := []string{}
+	query, args := listQuery(userID, filter)
+	args = append(args, limit, offset)
+	sessions, err = r.list(ctx, query, args, nil)
+	return sessions, err
+}
 
-	if status != nil && *status != "" {
-		conditions = append(conditions, "status = ?")
-		args = append(args, *status)
-	}
+// ListWithTotal is like List but also returns how many of the user's
+// sessions match the filters. Both come from one query, so the total always
+// agrees with the page even while sessions are being added.
+func (r *SessionRepository) ListWithTotal(userID int64, limit, offset int, filter *models.SessionFilter) ([]models.SessionResponse, int64, error) {
+	return r.ListWithTotalContext(context.Background(), userID, limit, offset, filter)
+}
 
-	if category != nil && *category != "" {
-		conditions = append(conditions, "category = ?")
-		args = append(args, *category)
-	}
+// ListWithTotalContext is like ListWithTotal, recording a span as a child of
+// the one in ctx.
+func (r *SessionRepository) ListWithTotalContext(ctx context.Context, userID int64, limit, offset int, filter *models.SessionFilter) (sessions []models.SessionResponse, total int64, err error) {
+	ctx, span := database.StartSpan(ctx, "list_sessions")
+	defer func() { span.SetError(err); span.End() }()
 
-	if len(conditions) > 0 {
-		query += utils.BuildWhereClause(conditions)
+	query, args := listWithTotalQuery(userID, filter)
+	args = append(args, limit, offset)
+	sessions, err = r.list(ctx, query, args, &total)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	query += " ORDER BY started_at DESC LIMIT ? OFFSET ?"
-	args = append(args, limit, offset)
+	// A page past the end has no row to carry the total
+	if len(sessions) == 0 && offset > 0 {
+		query, args := countQuery(userID, filter)
+		if err := r.db.QueryRowCached(ctx, query, args...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("failed to count sessions: %w", err)
+		}
+	}
+	return sessions, total, nil
+}
 
-	rows, err := r.db.Query(query, args...)
+// list runs a list query and scans its sessions. If total is not nil each
+// row carries it as an extra last column.
+func (r *SessionRepository) list(ctx context.Context, query string, args []interface{}, total *int64) ([]models.SessionResponse, error) {
+	rows, err := r.db.QueryCached(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sessions: %w", err)
 	}
@@ -215,8 +448,12 @@ func (r *SessionRepository) List(limit, offset int, status, category *string) ([
 		var note, location, mood, endedAt sql.NullString
 		var durationSec sql.NullInt64
 
-		if err := rows.Scan(&session.ID, &session.Category, &session.Task, &note, &location, &mood,
-			&session.StartedAt, &endedAt, &durationSec, &session.Status); err != nil {
+		dest := []interface{}{&session.ID, &session.Category, &session.Task, &note, &location, &mood,
+			&session.StartedAt, &endedAt, &durationSec, &session.Status, &session.CreatedAt, &session.UpdatedAt}
+		if total != nil {
+			dest = append(dest, total)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("failed to scan session row: %w", err)
 		}
 
@@ -235,6 +472,7 @@ func (r *SessionRepository) List(limit, offset int, status, category *string) ([
 		if durationSec.Valid {
 			session.DurationSec = &durationSec.Int64
 		}
+		session.SetDurationMs()
 
 		sessions = append(sessions, session)
 	}
@@ -246,46 +484,77 @@ func (r *SessionRepository) List(limit, offset int, status, category *string) ([
 	return sessions, nil
 }
 
-// Count returns the total number of sessions matching the filters.
-func (r *SessionRepository) Count(status, category *string) (int64, error) {
-	query := "SELECT COUNT(*) FROM sessions"
-	args := []interface{}{}
-	conditions := []string{}
+// Count returns the total number of the user's sessions matching the filters.
+func (r *SessionRepository) Count(userID int64, filter *models.SessionFilter) (int64, error) {
+	return r.CountContext(context.Background(), userID, filter)
+}
 
-	if status != nil && *status != "" {
-		conditions = append(conditions, "status = ?")
-		args = append(args, *status)
-	}
+// CountContext is like Count, recording a span as a child of the one in ctx.
+func (r *SessionRepository) CountContext(ctx context.Context, userID int64, filter *models.SessionFilter) (count int64, err error) {
+	ctx, span := database.StartSpan(ctx, "count_sessions")
+	defer func() { span.SetError(err); span.End() }()
 
-	if category != nil && *category != "" {
-		conditions = append(conditions, "category = ?")
-		args = append(args, *category)
+	query, args := countQuery(userID, filter)
+	if err := r.db.QueryRowCached(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
 	}
 
-	if len(conditions) > 0 {
-		query += utils.BuildWhereClause(conditions)
+	return count, nil
+}
+
+// Categories returns the distinct categories of the user's sessions, most recently used first.
+func (r *SessionRepository) Categories(userID int64) ([]string, error) {
+	return r.CategoriesContext(context.Background(), userID)
+}
+
+// CategoriesContext is like Categories, recording a span as a child of the one in ctx.
+func (r *SessionRepository) CategoriesContext(ctx context.Context, userID int64) (categories []string, err error) {
+	ctx, span := database.StartSpan(ctx, "list_categories")
+	defer func() { span.SetError(err); span.End() }()
+
+	rows, err := r.db.Reader().QueryContext(ctx,
+		`SELECT category FROM sessions WHERE user_id = ? GROUP BY category ORDER BY MAX(started_at) DESC, category`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
 	}
+	defer rows.Close()
 
-	var count int64
-	if err := r.db.QueryRow(query, args...).Scan(&count); err != nil {
-		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	categories = []string{}
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
 	}
 
-	return count, nil
+	return categories, nil
 }
 
-// GetByID retrieves a session by ID.
-func (r *SessionRepository) GetByID(id int64) (*models.SessionResponse, error) {
+// GetByID retrieves one of the user's sessions by ID, or nil if the user has
+// no session with that ID.
+func (r *SessionRepository) GetByID(userID, id int64) (*models.SessionResponse, error) {
+	return r.GetByIDContext(context.Background(), userID, id)
+}
+
+// GetByIDContext is like GetByID, recording a span as a child of the one in ctx.
+func (r *SessionRepository) GetByIDContext(ctx context.Context, userID, id int64) (found *models.SessionResponse, err error) {
+	ctx, span := database.StartSpan(ctx, "get_session")
+	defer func() { span.SetError(err); span.End() }()
+
 	var session models.SessionResponse
 	var note, location, mood, endedAt sql.NullString
 	var durationSec sql.NullInt64
 
-	err := r.db.QueryRow(
-		`SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status
-		 FROM sessions WHERE id = ?`,
-		id,
+	err = r.db.QueryRowCached(ctx,
+		`SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status, created_at, updated_at
+		 FROM sessions WHERE id = ? AND user_id = ?`,
+		id, userID,
 	).Scan(&session.ID, &session.Category, &session.Task, &note, &location, &mood,
-		&session.StartedAt, &endedAt, &durationSec, &session.Status)
+		&session.StartedAt, &endedAt, &durationSec, &session.Status, &session.CreatedAt, &session.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -309,12 +578,26 @@ func (r *SessionRepository) GetByID(id int64) (*models.SessionResponse, error) {
 	if durationSec.Valid {
 		session.DurationSec = &durationSec.Int64
 	}
+	session.SetDurationMs()
 
 	return &session, nil
 }
 
-// Update updates a session entry.
-func (r *SessionRepository) Update(id int64, data *models.SessionUpdate) error {
+// Update updates one of the user's session entries. A negative duration_sec
+// is stored as zero.
+func (r *SessionRepository) Update(userID, id int64, data *models.SessionUpdate) error {
+	return r.UpdateContext(context.Background(), userID, id, data)
+}
+
+// UpdateContext is like Update, recording a span as a child of the one in ctx.
+func (r *SessionRepository) UpdateContext(ctx context.Context, userID, id int64, data *models.SessionUpdate) (err error) {
+	ctx, span := database.StartSpan(ctx, "update_session")
+	defer func() { span.SetError(err); span.End() }()
+
+	if v := data.DurationSec.Ptr(); v != nil {
+		data.DurationSec = models.Some(r.clampDuration(ctx, id, *v))
+	}
+
 	fieldToCol := map[string]string{
 		"Category":    "category",
 		"Task":        "task",
@@ -324,6 +607,7 @@ func (r *SessionRepository) Update(id int64, data *models.SessionUpdate) error {
 		"StartedAt":   "started_at",
 		"EndedAt":     "ended_at",
 		"DurationSec": "duration_sec",
+		"Status":      "status",
 	}
 
 	updates, args := utils.BuildUpdateQueryFromStruct(data, fieldToCol)
@@ -332,10 +616,13 @@ func (r *SessionRepository) Update(id int64, data *models.SessionUpdate) error {
 		return nil
 	}
 
-	query := "UPDATE sessions SET " + strings.Join(updates, ", ") + " WHERE id = ?"
-	args = append(args, id)
+	updates = append(updates, "updated_at = ?")
+	args = append(args, models.NowRFC3339())
+
+	query := "UPDATE sessions SET " + strings.Join(updates, ", ") + " WHERE id = ? AND user_id = ?"
+	args = append(args, id, userID)
 
-	result, err := r.db.Exec(query, args...)
+	result, err := r.db.ExecRetry(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update session: %w", err)
 	}
@@ -346,7 +633,227 @@ func (r *SessionRepository) Update(id int64, data *models.SessionUpdate) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("session not found")
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// PurgeBefore removes the stopped sessions that ended before the RFC3339
+// cutoff, together with their tag assignments, and returns how many were
+// affected. With anonymize set the rows are kept for their times and
+// category, but task, note, location, mood and tags are cleared instead.
+// Retention applies to every user's sessions; running ones are never touched.
+func (r *SessionRepository) PurgeBefore(before string, anonymize bool) (int64, error) {
+	return r.PurgeBeforeContext(context.Background(), before, anonymize)
+}
+
+// PurgeBeforeContext is like PurgeBefore, recording a span as a child of the one in ctx.
+func (r *SessionRepository) PurgeBeforeContext(ctx context.Context, before string, anonymize bool) (purged int64, err error) {
+	ctx, span := database.StartSpan(ctx, "purge_sessions")
+	defer func() { span.SetError(err); span.End() }()
+
+	var affected int64
+	err = r.db.InTx(ctx, func(tx *sql.Tx) error {
+		stopped := string(models.SessionStatusStopped)
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM session_tags WHERE session_id IN (
+				SELECT id FROM sessions WHERE status = ? AND ended_at < ?)`,
+			stopped, before,
+		); err != nil {
+			return fmt.Errorf("failed to purge session tags: %w", err)
+		}
+
+		var result sql.Result
+		var err error
+		if anonymize {
+			// Rows anonymized by an earlier run are not counted again
+			result, err = tx.ExecContext(ctx,
+				`UPDATE sessions SET task = '', note = NULL, location = NULL, mood = NULL, updated_at = ?
+				 WHERE status = ? AND ended_at < ?
+				 AND (task != '' OR note IS NOT NULL OR location IS NOT NULL OR mood IS NOT NULL)`,
+				models.NowRFC3339(), stopped, before,
+			)
+		} else {
+			result, err = tx.ExecContext(ctx,
+				`DELETE FROM sessions WHERE status = ? AND ended_at < ?`,
+				stopped, before,
+			)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to purge sessions: %w", err)
+		}
+
+		affected, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// listQuery builds the List query for the user's sessions matching filter;
+// the caller appends the limit and offset arguments. Equality filters come
+// first and the order is by started_at alone, so a status and category
+// filter is answered by idx_sessions_user_status_category_started_at in
+// order, without sorting.
+func listQuery(userID int64, filter *models.SessionFilter) (string, []interface{}) {
+	conditions, args := filterConditions(userID, filter)
+	query := "SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status, created_at, updated_at FROM sessions" +
+		utils.BuildWhereClause(conditions) +
+		" ORDER BY " + orderColumn(filter) + " DESC LIMIT ? OFFSET ?"
+	return query, args
+}
+
+// listWithTotalQuery is like listQuery but adds the number of matching rows
+// as a last column. The count is an uncorrelated subquery, which SQLite runs
+// once per query rather than per row, and it reads the same snapshot as the
+// rows around it.
+func listWithTotalQuery(userID int64, filter *models.SessionFilter) (string, []interface{}) {
+	conditions, args := filterConditions(userID, filter)
+	where := utils.BuildWhereClause(conditions)
+	query := "SELECT id, category, task, note, location, mood, started_at, ended_at, duration_sec, status, created_at, updated_at, " +
+		"(SELECT COUNT(*) FROM sessions" + where + ") FROM sessions" + where +
+		" ORDER BY " + orderColumn(filter) + " DESC LIMIT ? OFFSET ?"
+	return query, append(append([]interface{}{}, args...), args...)
+}
+
+// countQuery builds the Count query for the user's sessions matching filter.
+func countQuery(userID int64, filter *models.SessionFilter) (string, []interface{}) {
+	conditions, args := filterConditions(userID, filter)
+	return "SELECT COUNT(*) FROM sessions" + utils.BuildWhereClause(conditions), args
+}
+
+// filterConditions converts a SessionFilter on the user's sessions into
+// WHERE conditions and arguments, equality conditions first in index column
+// order.
+func filterConditions(userID int64, filter *models.SessionFilter) ([]string, []interface{}) {
+	conditions := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	if filter == nil {
+		return conditions, args
+	}
+
+	if filter.Status != nil && *filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, *filter.Status)
+	}
+	if filter.Category != nil && *filter.Category != "" {
+		conditions = append(conditions, "category = ?")
+		args = append(args, *filter.Category)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "started_at < ?")
+		args = append(args, *filter.To)
+	}
+	if filter.TagID != nil {
+		conditions = append(conditions, "id IN (SELECT session_id FROM session_tags WHERE tag_id = ?)")
+		args = append(args, *filter.TagID)
+	}
+
+	return conditions, args
+}
+
+// orderColumn returns the column to sort by, started_at unless the filter
+// asks for updated_at.
+func orderColumn(filter *models.SessionFilter) string {
+	if filter != nil && filter.Sort == models.SessionSortUpdatedAt {
+		return "updated_at"
+	}
+	return "started_at"
+}
+
+// Iterate streams every one of the user's sessions matching the filter to fn, ordered by
+// started_at descending, without loading the result set into memory.
+// When withTags is set each session carries its tag names, sorted by name.
+// The query runs on the read pool, so writes can proceed while a long export
+// streams. fn must still not use the repository: for an in-memory database
+// the query holds the only connection.
+func (r *SessionRepository) Iterate(userID int64, filter *models.SessionFilter, withTags bool, fn func(*models.SessionResponse) error) error {
+	return r.IterateContext(context.Background(), userID, filter, withTags, fn)
+}
+
+// IterateContext is like Iterate, recording a span as a child of the one in ctx.
+func (r *SessionRepository) IterateContext(ctx context.Context, userID int64, filter *models.SessionFilter, withTags bool, fn func(*models.SessionResponse) error) (err error) {
+	ctx, span := database.StartSpan(ctx, "iterate_sessions")
+	defer func() { span.SetError(err); span.End() }()
+
+	columns := "id, category, task, note, location, mood, started_at, ended_at, duration_sec, status, created_at, updated_at"
+	if withTags {
+		columns += `, (SELECT group_concat(name, char(31)) FROM (SELECT t.name FROM session_tags st
+			JOIN tags t ON t.id = st.tag_id WHERE st.session_id = sessions.id ORDER BY t.name))`
+	}
+	query := "SELECT " + columns + " FROM sessions"
+
+	conditions, args := filterConditions(userID, filter)
+	query += utils.BuildWhereClause(conditions)
+	query += " ORDER BY " + orderColumn(filter) + " DESC"
+
+	rows, err := r.db.Reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		// modernc.org/sqlite keeps returning rows after ctx is canceled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var session models.SessionResponse
+		var note, location, mood, endedAt, tagNames sql.NullString
+		var durationSec sql.NullInt64
+
+		dest := []interface{}{&session.ID, &session.Category, &session.Task, &note, &location, &mood,
+			&session.StartedAt, &endedAt, &durationSec, &session.Status, &session.CreatedAt, &session.UpdatedAt}
+		if withTags {
+			dest = append(dest, &tagNames)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to scan session row: %w", err)
+		}
+
+		if note.Valid {
+			session.Note = &note.String
+		}
+		if location.Valid {
+			session.Location = &location.String
+		}
+		if mood.Valid {
+			session.Mood = &mood.String
+		}
+		if endedAt.Valid {
+			session.EndedAt = &endedAt.String
+		}
+		if durationSec.Valid {
+			session.DurationSec = &durationSec.Int64
+		}
+		session.SetDurationMs()
+		if tagNames.Valid && tagNames.String != "" {
+			session.Tags = strings.Split(tagNames.String, "\x1f")
+		}
+
+		if err := fn(&session); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating session rows: %w", err)
+	}
+	// modernc.org/sqlite can also end the rows early without an error,
+	// which would pass for a complete iteration
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	return nil
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/database"
+)
+
+// BenchmarkList_DuringBulkInsert measures List while another goroutine keeps
+// inserting sessions in large transactions, with reads sharing the write
+// connection ("shared") and on the read pool ("read_pool").
+func BenchmarkList_DuringBulkInsert(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		readConns int
+	}{
+		{"shared", -1},
+		{"read_pool", database.DefaultReadConns},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := database.Open(filepath.Join(b.TempDir(), "bench.db"), database.Options{ReadConns: bc.readConns})
+			if err != nil {
+				b.Fatalf("failed to open database: %v", err)
+			}
+			defer db.Close()
+			repo := NewSessionRepository(db)
+			seedSessions(b, db, 1000)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					db.InTx(ctx, func(tx *sql.Tx) error {
+						_, err := tx.ExecContext(ctx, `
+							WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < 5000)
+							INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+							SELECT 'bulk', 'task ' || i, '2023-01-01T09:00:00Z', '2023-01-01T10:00:00Z', 3600, 'stopped'
+							FROM seq`)
+						return err
+					})
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.List(database.DefaultUserID, 50, 0, nil); err != nil {
+					b.Fatalf("List: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			cancel()
+			wg.Wait()
+		})
+	}
+}
+
+// BenchmarkList_FilteredOffset measures a deep page of a list filtered by
+// status and category over 100k sessions, without the composite index
+// ("single_column_indexes") and with it ("composite_index").
+func BenchmarkList_FilteredOffset(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		dropIndex bool
+	}{
+		{"single_column_indexes", true},
+		{"composite_index", false},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := database.New(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("failed to open database: %v", err)
+			}
+			defer db.Close()
+			repo := NewSessionRepository(db)
+			seedSessions(b, db, 100000)
+			// Spread the rows over five categories
+			if _, err := db.Exec("UPDATE sessions SET category = 'c' || (id % 5)"); err != nil {
+				b.Fatal(err)
+			}
+			if bc.dropIndex {
+				if _, err := db.Exec("DROP INDEX idx_sessions_user_status_category_started_at"); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			status, category := "stopped", "c1"
+			filter := &models.SessionFilter{Status: &status, Category: &category}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.List(database.DefaultUserID, 50, 5000, filter); err != nil {
+					b.Fatalf("List: %v", err)
+				}
+				if _, err := repo.Count(database.DefaultUserID, filter); err != nil {
+					b.Fatalf("Count: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// stmtCacheCases opens the benchmark database preparing every query
+// ("unprepared") and with the statement cache ("prepared").
+var stmtCacheCases = []struct {
+	name string
+	size int
+}{
+	{"unprepared", -1},
+	{"prepared", database.DefaultStmtCacheSize},
+}
+
+// BenchmarkGetRunning measures the lookup behind every poll of the current
+// session, from parallel clients.
+func BenchmarkGetRunning(b *testing.B) {
+	for _, bc := range stmtCacheCases {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := database.Open(filepath.Join(b.TempDir(), "bench.db"), database.Options{StmtCacheSize: bc.size})
+			if err != nil {
+				b.Fatalf("failed to open database: %v", err)
+			}
+			defer db.Close()
+			repo := NewSessionRepository(db)
+			seedSessions(b, db, 1000)
+			if _, err := repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "running"}); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := repo.GetRunning(database.DefaultUserID); err != nil {
+						b.Errorf("GetRunning: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkList_Prepared measures a first page of the list with its count,
+// as the sessions page loads it.
+func BenchmarkList_Prepared(b *testing.B) {
+	for _, bc := range stmtCacheCases {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := database.Open(filepath.Join(b.TempDir(), "bench.db"), database.Options{StmtCacheSize: bc.size})
+			if err != nil {
+				b.Fatalf("failed to open database: %v", err)
+			}
+			defer db.Close()
+			repo := NewSessionRepository(db)
+			seedSessions(b, db, 1000)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.List(database.DefaultUserID, 20, 0, nil); err != nil {
+					b.Fatalf("List: %v", err)
+				}
+				if _, err := repo.Count(database.DefaultUserID, nil); err != nil {
+					b.Fatalf("Count: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkList_WithTotal measures a filtered page and its total over 10k
+// sessions as two queries ("list_and_count") and as one ("list_with_total").
+func BenchmarkList_WithTotal(b *testing.B) {
+	db, err := database.New(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	repo := NewSessionRepository(db)
+	seedSessions(b, db, 10000)
+	if _, err := db.Exec("UPDATE sessions SET category = 'c' || (id % 5)"); err != nil {
+		b.Fatal(err)
+	}
+	status, category := "stopped", "c1"
+	filter := &models.SessionFilter{Status: &status, Category: &category}
+
+	b.Run("list_and_count", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.List(database.DefaultUserID, 20, 100, filter); err != nil {
+				b.Fatalf("List: %v", err)
+			}
+			if _, err := repo.Count(database.DefaultUserID, filter); err != nil {
+				b.Fatalf("Count: %v", err)
+			}
+		}
+	})
+	b.Run("list_with_total", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := repo.ListWithTotal(database.DefaultUserID, 20, 100, filter); err != nil {
+				b.Fatalf("ListWithTotal: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,737 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/tracing"
+)
+
+// seedSessions inserts n stopped sessions in a single statement.
+func seedSessions(t testing.TB, db *database.DB, n int) {
+	t.Helper()
+	_, err := db.Exec(`
+		WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < ?)
+		INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+		SELECT 'work', 'task ' || i,
+			strftime('%Y-%m-%dT%H:%M:%SZ', '2024-01-01', '+' || (i * 7919 % ?) || ' minutes'),
+			strftime('%Y-%m-%dT%H:%M:%SZ', '2024-01-01', '+' || (i * 7919 % ? + 1) || ' minutes'),
+			60, 'stopped'
+		FROM seq`, n, n, n)
+	if err != nil {
+		t.Fatalf("failed to seed sessions: %v", err)
+	}
+}
+
+func TestSessionRepository_ListContextCanceled(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+	seedSessions(t, db, 300000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(20*time.Millisecond, cancel)
+	defer timer.Stop()
+	defer cancel()
+
+	// Filtering on category makes SQLite sort every row before returning
+	// the first one, so the cancellation lands mid-query.
+	category := "work"
+	start := time.Now()
+	_, err := repo.ListContext(ctx, database.DefaultUserID, -1, 0, &models.SessionFilter{Category: &category})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ListContext error = %v, want context.Canceled", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("ListContext took %v to notice the cancellation", elapsed)
+	}
+}
+
+func TestSessionRepository_IterateContextCanceled(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+	seedSessions(t, db, 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seen := 0
+	err := repo.IterateContext(ctx, database.DefaultUserID, nil, false, func(*models.SessionResponse) error {
+		seen++
+		if seen == 10 {
+			cancel()
+		}
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("IterateContext error = %v, want context.Canceled", err)
+	}
+	if seen >= 1000 {
+		t.Errorf("IterateContext visited all %d rows after cancellation", seen)
+	}
+}
+
+// queryPlan returns the EXPLAIN QUERY PLAN details for query, one per line.
+func queryPlan(t *testing.T, db *database.DB, query string, args ...interface{}) string {
+	t.Helper()
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	return strings.Join(plan, "\n")
+}
+
+func TestSessionRepository_FilteredListPlan(t *testing.T) {
+	db := database.NewForTesting(t)
+	status, category, from := "stopped", "work", "2024-01-01T00:00:00Z"
+
+	for _, filter := range []*models.SessionFilter{
+		{Status: &status, Category: &category},
+		{Status: &status, Category: &category, From: &from},
+	} {
+		query, args := listQuery(database.DefaultUserID, filter)
+		plan := queryPlan(t, db, query, append(args, 50, 5000)...)
+		if !strings.Contains(plan, "idx_sessions_user_status_category_started_at") || strings.Contains(plan, "TEMP B-TREE") {
+			t.Errorf("list %q plan does not walk the composite index in order:\n%s", query, plan)
+		}
+
+		query, args = countQuery(database.DefaultUserID, filter)
+		plan = queryPlan(t, db, query, args...)
+		if !strings.Contains(plan, "COVERING INDEX idx_sessions_user_status_category_started_at") {
+			t.Errorf("count %q plan does not use the covering index:\n%s", query, plan)
+		}
+	}
+}
+
+func TestSessionRepository_Timestamps(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	created, err := repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "first"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.CreatedAt != created.StartedAt || created.UpdatedAt != created.StartedAt {
+		t.Errorf("new session created_at = %q, updated_at = %q, want %q", created.CreatedAt, created.UpdatedAt, created.StartedAt)
+	}
+
+	// Age the row so the later bumps are observable
+	if _, err := db.Exec("UPDATE sessions SET created_at = '2024-01-01T00:00:00Z', updated_at = '2024-01-01T00:00:00Z'"); err != nil {
+		t.Fatal(err)
+	}
+	stopped, err := repo.StopRunning(database.DefaultUserID, &models.SessionStop{})
+	if err != nil {
+		t.Fatalf("StopRunning: %v", err)
+	}
+	if stopped.CreatedAt != "2024-01-01T00:00:00Z" {
+		t.Errorf("stop changed created_at to %q", stopped.CreatedAt)
+	}
+	if stopped.UpdatedAt != *stopped.EndedAt {
+		t.Errorf("stop set updated_at = %q, want ended_at %q", stopped.UpdatedAt, *stopped.EndedAt)
+	}
+
+	if _, err := db.Exec("UPDATE sessions SET updated_at = '2024-01-01T00:00:00Z'"); err != nil {
+		t.Fatal(err)
+	}
+	task := "renamed"
+	if err := repo.Update(database.DefaultUserID, created.ID, &models.SessionUpdate{Task: &task}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repo.GetByID(database.DefaultUserID, created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.UpdatedAt == "2024-01-01T00:00:00Z" {
+		t.Error("Update did not bump updated_at")
+	}
+	if got.CreatedAt != "2024-01-01T00:00:00Z" {
+		t.Errorf("Update changed created_at to %q", got.CreatedAt)
+	}
+}
+
+func TestSessionRepository_ClampsNegativeDurations(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	created, err := repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "skewed"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// A started_at ahead of the clock, as after an edit or a clock step back
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if _, err := db.Exec("UPDATE sessions SET started_at = ? WHERE id = ?", future, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	stopped, err := repo.StopRunning(database.DefaultUserID, &models.SessionStop{})
+	if err != nil {
+		t.Fatalf("StopRunning: %v", err)
+	}
+	if stopped.DurationSec == nil || *stopped.DurationSec != 0 {
+		t.Errorf("StopRunning duration_sec = %v, want 0", stopped.DurationSec)
+	}
+
+	if err := repo.Update(database.DefaultUserID, created.ID, &models.SessionUpdate{DurationSec: models.Some[int64](-90)}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repo.GetByID(database.DefaultUserID, created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.DurationSec == nil || *got.DurationSec != 0 {
+		t.Errorf("Update stored duration_sec = %v, want 0", got.DurationSec)
+	}
+}
+
+func TestSessionRepository_UpdateOptionalDetails(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	note, location, mood := "note", "desk", "calm"
+	created, err := repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "details", Note: &note, Location: &location, Mood: &mood})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		set   func(*models.SessionUpdate, models.Optional[string])
+		field func(*models.SessionResponse) *string
+	}{
+		{"note", func(u *models.SessionUpdate, v models.Optional[string]) { u.Note = v }, func(s *models.SessionResponse) *string { return s.Note }},
+		{"location", func(u *models.SessionUpdate, v models.Optional[string]) { u.Location = v }, func(s *models.SessionResponse) *string { return s.Location }},
+		{"mood", func(u *models.SessionUpdate, v models.Optional[string]) { u.Mood = v }, func(s *models.SessionResponse) *string { return s.Mood }},
+	} {
+		for _, step := range []struct {
+			value models.Optional[string]
+			want  *string
+		}{
+			{models.Some("given"), strPtr("given")},
+			// Omitted leaves the value alone
+			{models.Optional[string]{}, strPtr("given")},
+			{models.Some(""), strPtr("")},
+			{models.Null[string](), nil},
+		} {
+			// Task keeps the update from being empty when the field is omitted
+			task := "details"
+			update := &models.SessionUpdate{Task: &task}
+			tt.set(update, step.value)
+			if err := repo.Update(database.DefaultUserID, created.ID, update); err != nil {
+				t.Fatalf("Update %s: %v", tt.name, err)
+			}
+			got, err := repo.GetByID(database.DefaultUserID, created.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if value := tt.field(got); (value == nil) != (step.want == nil) || (value != nil && *value != *step.want) {
+				t.Errorf("%s after update %+v = %v, want %v", tt.name, step.value, value, step.want)
+			}
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestSessionRepository_ListSortUpdatedAt(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	for _, stmt := range []string{
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status, created_at, updated_at)
+			VALUES ('work', 'old but edited', '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped', '2024-01-01T10:00:00Z', '2024-03-01T00:00:00Z')`,
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status, created_at, updated_at)
+			VALUES ('work', 'recent', '2024-02-01T09:00:00Z', '2024-02-01T10:00:00Z', 3600, 'stopped', '2024-02-01T10:00:00Z', '2024-02-01T10:00:00Z')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		sort  string
+		first string
+	}{
+		{"", "recent"},
+		{models.SessionSortStartedAt, "recent"},
+		{models.SessionSortUpdatedAt, "old but edited"},
+	} {
+		items, err := repo.List(database.DefaultUserID, 10, 0, &models.SessionFilter{Sort: tt.sort})
+		if err != nil {
+			t.Fatalf("List(sort=%q): %v", tt.sort, err)
+		}
+		if len(items) != 2 || items[0].Task != tt.first {
+			t.Errorf("List(sort=%q) first = %+v, want %q", tt.sort, items, tt.first)
+		}
+	}
+}
+
+func TestSessionRepository_NormalizedOffsets(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	// 2024-01-02T07:00:00+08:00 is 2024-01-01T23:00:00Z: as text it sorts
+	// after the 2024-01-02T01:00:00Z session and falls inside a 2024-01-02
+	// filter, though it started earlier on the 1st. An imported dump is
+	// stored in UTC whatever it holds.
+	utcEnd, offsetEnd, hour := "2024-01-02T02:00:00.000Z", "2024-01-02T08:00:00+08:00", int64(3600)
+	dump := &database.Dump{
+		SchemaVersion: 1,
+		Sessions: []database.DumpSession{
+			{ID: 1, Category: "work", Task: "utc", StartedAt: "2024-01-02T01:00:00.000Z", EndedAt: &utcEnd, DurationSec: &hour, Status: "stopped"},
+			{ID: 2, Category: "work", Task: "offset", StartedAt: "2024-01-02T07:00:00+08:00", EndedAt: &offsetEnd, DurationSec: &hour, Status: "stopped"},
+		},
+	}
+	if _, err := db.Import(context.Background(), dump, false); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	items, err := repo.List(database.DefaultUserID, 10, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Task != "utc" || items[1].Task != "offset" {
+		t.Fatalf("List order = %+v, want utc then offset", items)
+	}
+	if items[1].StartedAt != "2024-01-01T23:00:00.000Z" || *items[1].EndedAt != "2024-01-02T00:00:00.000Z" {
+		t.Errorf("offset session started_at = %q, ended_at = %q, want UTC", items[1].StartedAt, *items[1].EndedAt)
+	}
+
+	day := "2024-01-02"
+	filter := &models.SessionFilter{From: &day, To: &day}
+	if err := filter.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	items, err = repo.List(database.DefaultUserID, 10, 0, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Task != "utc" {
+		t.Errorf("List on 2024-01-02 = %+v, want only utc", items)
+	}
+}
+
+func TestSessionRepository_ListTagFilter(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	for _, stmt := range []string{
+		`INSERT INTO tags (id, name, color, created_at) VALUES (1, 'deep', '#000000', '2024-01-01T00:00:00Z'), (2, 'other', '#000000', '2024-01-01T00:00:00Z')`,
+		`INSERT INTO sessions (id, category, task, started_at, ended_at, duration_sec, status) VALUES
+			(1, 'work', 'both', '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped'),
+			(2, 'work', 'deep only', '2024-01-02T09:00:00Z', '2024-01-02T10:00:00Z', 3600, 'stopped'),
+			(3, 'work', 'untagged', '2024-01-03T09:00:00Z', '2024-01-03T10:00:00Z', 3600, 'stopped')`,
+		`INSERT INTO session_tags (session_id, tag_id) VALUES (1, 1), (1, 2), (2, 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tagID := int64(1)
+	filter := &models.SessionFilter{TagID: &tagID}
+	items, err := repo.List(database.DefaultUserID, 10, 0, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A session with several tags is listed once
+	if len(items) != 2 || items[0].Task != "deep only" || items[1].Task != "both" {
+		t.Errorf("List(tag_id=1) = %+v, want deep only and both", items)
+	}
+	if count, err := repo.Count(database.DefaultUserID, filter); err != nil || count != 2 {
+		t.Errorf("Count(tag_id=1) = %d, %v, want 2", count, err)
+	}
+}
+
+func TestSessionRepository_Categories(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	categories, err := repo.Categories(database.DefaultUserID)
+	if err != nil || len(categories) != 0 {
+		t.Fatalf("empty database: %v, %v", categories, err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES
+		('work', 'a', '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped'),
+		('study', 'b', '2024-01-02T09:00:00Z', '2024-01-02T10:00:00Z', 3600, 'stopped'),
+		('work', 'c', '2024-01-03T09:00:00Z', '2024-01-03T10:00:00Z', 3600, 'stopped')`); err != nil {
+		t.Fatal(err)
+	}
+	categories, err = repo.Categories(database.DefaultUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(categories, ",") != "work,study" {
+		t.Errorf("Categories() = %v, want work then study", categories)
+	}
+}
+
+func TestSessionRepository_PurgeBefore(t *testing.T) {
+	for _, anonymize := range []bool{false, true} {
+		db := database.NewForTesting(t)
+		repo := NewSessionRepository(db)
+
+		for _, stmt := range []string{
+			`INSERT INTO sessions (category, task, note, mood, started_at, ended_at, duration_sec, status)
+				VALUES ('work', 'old', 'private', 'tired', '2023-01-01T09:00:00Z', '2023-01-01T10:00:00Z', 3600, 'stopped')`,
+			`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+				VALUES ('work', 'recent', '2024-06-01T09:00:00Z', '2024-06-01T10:00:00Z', 3600, 'stopped')`,
+			`INSERT INTO sessions (category, task, started_at, status)
+				VALUES ('work', 'running', '2020-01-01T09:00:00Z', 'running')`,
+			`INSERT INTO tags (name, color, created_at) VALUES ('deep', '#000000', '2024-01-01T00:00:00Z')`,
+			`INSERT INTO session_tags (session_id, tag_id) SELECT id, 1 FROM sessions`,
+		} {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		count, err := repo.PurgeBefore("2024-01-01T00:00:00Z", anonymize)
+		if err != nil {
+			t.Fatalf("PurgeBefore(anonymize=%v): %v", anonymize, err)
+		}
+		if count != 1 {
+			t.Errorf("PurgeBefore(anonymize=%v) = %d, want 1", anonymize, count)
+		}
+
+		var sessions, tagged int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("SELECT COUNT(*) FROM session_tags").Scan(&tagged); err != nil {
+			t.Fatal(err)
+		}
+		if tagged != 2 {
+			t.Errorf("anonymize=%v: %d tag assignments left, want 2", anonymize, tagged)
+		}
+
+		if !anonymize {
+			if sessions != 2 {
+				t.Errorf("%d sessions left after delete, want 2", sessions)
+			}
+			continue
+		}
+
+		if sessions != 3 {
+			t.Errorf("%d sessions left after anonymize, want 3", sessions)
+		}
+		var task string
+		var note, mood sql.NullString
+		var duration int64
+		if err := db.QueryRow("SELECT task, note, mood, duration_sec FROM sessions WHERE id = 1").Scan(&task, &note, &mood, &duration); err != nil {
+			t.Fatal(err)
+		}
+		if task != "" || note.Valid || mood.Valid || duration != 3600 {
+			t.Errorf("anonymized row task = %q, note = %v, mood = %v, duration = %d", task, note, mood, duration)
+		}
+
+		// Already anonymized rows are not counted again
+		if count, err := repo.PurgeBefore("2024-01-01T00:00:00Z", true); err != nil || count != 0 {
+			t.Errorf("second anonymize = %d, %v, want 0", count, err)
+		}
+	}
+}
+
+// lockDatabase opens a second connection to the database at path and holds a
+// write transaction on it until the returned function is called.
+func lockDatabase(t *testing.T, path string) (release func()) {
+	t.Helper()
+
+	other, err := database.New(path)
+	if err != nil {
+		t.Fatalf("failed to open second connection: %v", err)
+	}
+	conn, err := other.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("failed to take write lock: %v", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "COMMIT")
+		conn.Close()
+		other.Close()
+	}
+}
+
+func TestSessionRepository_RetriesWhileLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.Open(path, database.Options{BusyTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	repo := NewSessionRepository(db)
+
+	release := lockDatabase(t, path)
+	time.AfterFunc(100*time.Millisecond, release)
+
+	// The lock outlasts the busy timeout, so this only succeeds by retrying
+	if _, err := repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "blocked"}); err != nil {
+		t.Fatalf("Create while locked: %v", err)
+	}
+	if _, err := repo.StopRunning(database.DefaultUserID, &models.SessionStop{}); err != nil {
+		t.Fatalf("StopRunning after lock: %v", err)
+	}
+}
+
+func TestSessionRepository_GivesUpWhileLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.Open(path, database.Options{BusyTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	repo := NewSessionRepository(db)
+
+	release := lockDatabase(t, path)
+	defer release()
+
+	_, err = repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "blocked"})
+	if !database.IsBusy(err) {
+		t.Fatalf("Create error = %v, want a busy error", err)
+	}
+	if _, err := repo.CreateStopped(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "blocked"},
+		"2024-01-01T09:00:00Z", "2024-01-01T10:00:00Z", 3600); !database.IsBusy(err) {
+		t.Fatalf("CreateStopped error = %v, want a busy error", err)
+	}
+}
+
+func TestSessionRepository_GetRunningDetectsMultiples(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	for _, stmt := range []string{
+		"DROP INDEX idx_sessions_single_running_per_user",
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'first', '2024-01-01T09:00:00Z', 'running')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'second', '2024-01-01T10:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	if _, err := repo.GetRunning(database.DefaultUserID); !errors.Is(err, ErrMultipleRunning) {
+		t.Fatalf("GetRunning error = %v, want ErrMultipleRunning", err)
+	}
+	if _, err := repo.StopRunning(database.DefaultUserID, &models.SessionStop{}); !errors.Is(err, ErrMultipleRunning) {
+		t.Fatalf("StopRunning error = %v, want ErrMultipleRunning", err)
+	}
+
+	if _, err := db.StopExtraRunning(context.Background()); err != nil {
+		t.Fatalf("StopExtraRunning failed: %v", err)
+	}
+	running, err := repo.GetRunning(database.DefaultUserID)
+	if err != nil {
+		t.Fatalf("GetRunning after repair: %v", err)
+	}
+	if running == nil || running.Task != "second" {
+		t.Fatalf("running session = %+v, want second", running)
+	}
+}
+
+// TestSessionRepository_ConcurrentPrepared starts and stops sessions while
+// other goroutines poll and list, all sharing the cached statements. Run it
+// with -race.
+func TestSessionRepository_ConcurrentPrepared(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"), database.Options{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	repo := NewSessionRepository(db)
+	seedSessions(t, db, 100)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if _, err := repo.Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "task"}); err != nil {
+				errs <- fmt.Errorf("Create: %w", err)
+				return
+			}
+			if _, err := repo.StopRunning(database.DefaultUserID, &models.SessionStop{}); err != nil {
+				errs <- fmt.Errorf("StopRunning: %w", err)
+				return
+			}
+		}
+	}()
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if _, err := repo.GetRunning(database.DefaultUserID); err != nil {
+					errs <- fmt.Errorf("GetRunning: %w", err)
+					return
+				}
+				if _, err := repo.List(database.DefaultUserID, 10, 0, nil); err != nil {
+					errs <- fmt.Errorf("List: %w", err)
+					return
+				}
+				if _, err := repo.Count(database.DefaultUserID, nil); err != nil {
+					errs <- fmt.Errorf("Count: %w", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	count, err := repo.Count(database.DefaultUserID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 120 {
+		t.Errorf("count = %d, want 120", count)
+	}
+}
+
+func TestSessionRepository_ListWithTotal(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+	seedSessions(t, db, 25)
+
+	status := "stopped"
+	for _, tc := range []struct {
+		limit, offset int
+		items         int
+	}{
+		{10, 0, 10},
+		{10, 20, 5},
+		// Past the end the total still comes back
+		{10, 40, 0},
+	} {
+		sessions, total, err := repo.ListWithTotal(database.DefaultUserID, tc.limit, tc.offset, &models.SessionFilter{Status: &status})
+		if err != nil {
+			t.Fatalf("ListWithTotal(%d, %d): %v", tc.limit, tc.offset, err)
+		}
+		if len(sessions) != tc.items || total != 25 {
+			t.Errorf("ListWithTotal(%d, %d) = %d sessions of %d, want %d of 25", tc.limit, tc.offset, len(sessions), total, tc.items)
+		}
+	}
+
+	running := "running"
+	sessions, total, err := repo.ListWithTotal(database.DefaultUserID, 10, 0, &models.SessionFilter{Status: &running})
+	if err != nil || len(sessions) != 0 || total != 0 {
+		t.Errorf("no matches = %d sessions of %d, %v", len(sessions), total, err)
+	}
+}
+
+// TestSessionRepository_ListWithTotalConsistent lists while another
+// goroutine keeps adding sessions; the total must always match the rows
+// returned with it.
+func TestSessionRepository_ListWithTotalConsistent(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"), database.Options{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	repo := NewSessionRepository(db)
+	seedSessions(t, db, 50)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		session := &models.SessionStart{Category: "work", Task: "task"}
+		for ctx.Err() == nil {
+			repo.CreateStoppedContext(ctx, database.DefaultUserID, session, "2024-01-01T09:00:00.000Z", "2024-01-01T10:00:00.000Z", 3600)
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		sessions, total, err := repo.ListWithTotal(database.DefaultUserID, 100000, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(sessions)) != total {
+			t.Fatalf("listed %d sessions with a total of %d", len(sessions), total)
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestSessionRepository_Spans(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewSessionRepository(db)
+
+	rec := &tracing.Recorder{}
+	tracing.SetExporter(rec)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	ctx := context.Background()
+	created, err := repo.CreateContext(ctx, database.DefaultUserID, &models.SessionStart{Category: "work", Task: "traced"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByIDContext(ctx, database.DefaultUserID, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	note := "edited"
+	if err := repo.UpdateContext(ctx, database.DefaultUserID, created.ID, &models.SessionUpdate{Note: models.Some(note)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.StopRunningContext(ctx, database.DefaultUserID, &models.SessionStop{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.IterateContext(ctx, database.DefaultUserID, nil, false, func(*models.SessionResponse) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	// A failed statement marks its span as failed
+	if _, err := repo.StopRunningContext(ctx, database.DefaultUserID, &models.SessionStop{}); !errors.Is(err, ErrNoRunningSession) {
+		t.Fatalf("expected ErrNoRunningSession, got %v", err)
+	}
+
+	var names []string
+	for _, span := range rec.Spans() {
+		names = append(names, span.Name)
+		var operation interface{}
+		for _, attr := range span.Attributes {
+			if attr.Key == "db.operation.name" {
+				operation = attr.Value
+			}
+		}
+		if "sqlite "+fmt.Sprint(operation) != span.Name {
+			t.Errorf("span %q has db.operation.name %v", span.Name, operation)
+		}
+	}
+	want := []string{"sqlite create_session", "sqlite get_session", "sqlite update_session", "sqlite stop_session", "sqlite iterate_sessions", "sqlite stop_session"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("spans = %v, want %v", names, want)
+	}
+	if spans := rec.Spans(); len(spans) > 0 && spans[len(spans)-1].Error == "" {
+		t.Error("the failed stop's span is not marked as failed")
+	}
+}
@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"os"
 	"strings"
 	"testing"
 
@@ -11,34 +10,10 @@ import (
 	"time-tracker/internal/shared/database"
 )
 
-func setupTestDB(t *testing.T) (*database.DB, func()) {
-	t.Helper()
-
-	tmpFile, err := os.CreateTemp("", "repository_test_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	db, err := database.New(tmpFile.Name())
-	if err != nil {
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	cleanup := func() {
-		db.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return db, cleanup
-}
-
 // TestValidation_Property13_RoundTrip_Session tests that sessions also
 // handle special characters correctly in round-trip.
 func TestValidation_Property13_RoundTrip_Session(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	repo := NewSessionRepository(db)
 
@@ -63,13 +38,13 @@ func TestValidation_Property13_RoundTrip_Session(t *testing.T) {
 		}
 
 		// Store in database
-		created, err := repo.Create(session)
+		created, err := repo.Create(database.DefaultUserID, session)
 		if err != nil {
 			t.Fatalf("failed to create session: %v", err)
 		}
 
 		// Retrieve from database
-		sessions, err := repo.List(10, 0, nil, nil)
+		sessions, err := repo.List(database.DefaultUserID, 10, 0, nil)
 		if err != nil {
 			t.Fatalf("failed to list sessions: %v", err)
 		}
@@ -94,6 +69,6 @@ func TestValidation_Property13_RoundTrip_Session(t *testing.T) {
 		}
 
 		// Clean up - stop the session
-		_, _ = repo.StopRunning(&models.SessionStop{})
+		_, _ = repo.StopRunning(database.DefaultUserID, &models.SessionStop{})
 	})
 }
\ No newline at end of file
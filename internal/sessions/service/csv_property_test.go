@@ -3,8 +3,9 @@ package service
 import (
 	"bytes"
 	"encoding/csv"
-	"os"
+	"fmt"
 	"regexp"
+	"strconv"
 	"testing"
 
 	"pgregory.net/rapid"
@@ -12,6 +13,7 @@ import (
 	"time-tracker/internal/sessions/repository"
 
 	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/utils"
 )
 
 // Feature: time-tracker, Property 8: CSV 导出格式正确性
@@ -22,32 +24,8 @@ import (
 // - Content starts with UTF-8 BOM (0xEF 0xBB 0xBF)
 // - Sessions CSV duration format is H:MM:SS
 
-func setupCSVTestDB(t *testing.T) (*database.DB, func()) {
-	t.Helper()
-
-	tmpFile, err := os.CreateTemp("", "service_csv_test_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	db, err := database.New(tmpFile.Name())
-	if err != nil {
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	cleanup := func() {
-		db.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return db, cleanup
-}
-
 func TestCSVExport_Property8_SessionsFormatCorrectness(t *testing.T) {
-	db, cleanup := setupCSVTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	sessionSvc := NewSessionService(sessionRepo)
@@ -75,7 +53,7 @@ func TestCSVExport_Property8_SessionsFormatCorrectness(t *testing.T) {
 		}
 
 		// Export CSV
-		csvData, err := sessionSvc.ExportCSV(nil, nil)
+		csvData, err := sessionSvc.ExportCSV(nil)
 		if err != nil {
 			t.Fatalf("failed to export CSV: %v", err)
 		}
@@ -100,7 +78,7 @@ func TestCSVExport_Property8_SessionsFormatCorrectness(t *testing.T) {
 			t.Fatal("CSV has no header row")
 		}
 
-		expectedHeader := []string{"id", "category", "task", "note", "location", "mood", "started_at", "ended_at", "duration", "status"}
+		expectedHeader := []string{"id", "category", "task", "note", "location", "mood", "started_at", "ended_at", "duration", "status", "duration_sec", "tags", "created_at", "updated_at"}
 		if len(records[0]) != len(expectedHeader) {
 			t.Fatalf("expected %d columns, got %d", len(expectedHeader), len(records[0]))
 		}
@@ -121,6 +99,15 @@ func TestCSVExport_Property8_SessionsFormatCorrectness(t *testing.T) {
 				if !durationRegex.MatchString(duration) {
 					t.Fatalf("invalid duration format %q, expected H:MM:SS", duration)
 				}
+
+				// duration_sec holds the same value as plain seconds
+				seconds, err := strconv.ParseInt(records[i][10], 10, 64)
+				if err != nil {
+					t.Fatalf("invalid duration_sec %q: %v", records[i][10], err)
+				}
+				if formatted := utils.FormatDuration(&seconds); formatted != duration {
+					t.Fatalf("duration_sec %d formats as %q, but duration is %q", seconds, formatted, duration)
+				}
 			}
 		}
 	})
@@ -134,8 +121,7 @@ func TestCSVExport_Property8_SessionsFormatCorrectness(t *testing.T) {
 // should match the list API results.
 
 func TestCSVExport_Property9_SessionsFilterConsistency(t *testing.T) {
-	db, cleanup := setupCSVTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	sessionSvc := NewSessionService(sessionRepo)
@@ -153,10 +139,16 @@ func TestCSVExport_Property9_SessionsFilterConsistency(t *testing.T) {
 		}
 
 		// Stop some sessions (leave some running would cause conflict, so stop all)
-		_, err = sessionSvc.StopSession(nil)
+		stopped, err := sessionSvc.StopSession(nil)
 		if err != nil {
 			t.Fatalf("failed to stop session: %v", err)
 		}
+
+		// Spread sessions over consecutive days so date filters select subsets
+		day := fmt.Sprintf("2024-03-%02dT10:00:00Z", i+1)
+		if _, err := db.Exec("UPDATE sessions SET started_at = ? WHERE id = ?", day, stopped.ID); err != nil {
+			t.Fatalf("failed to set started_at: %v", err)
+		}
 	}
 
 	rapid.Check(t, func(t *rapid.T) {
@@ -173,14 +165,26 @@ func TestCSVExport_Property9_SessionsFilterConsistency(t *testing.T) {
 			category = &cat
 		}
 
+		var from, to *string
+		if rapid.Bool().Draw(t, "hasFrom") {
+			f := fmt.Sprintf("2024-03-%02d", rapid.IntRange(1, 10).Draw(t, "fromDay"))
+			from = &f
+		}
+		if rapid.Bool().Draw(t, "hasTo") {
+			d := fmt.Sprintf("2024-03-%02d", rapid.IntRange(1, 10).Draw(t, "toDay"))
+			to = &d
+		}
+
+		filter := &models.SessionFilter{Status: status, Category: category, From: from, To: to}
+
 		// Get list results
-		listResult, err := sessionSvc.GetSessions(10000, 0, status, category)
+		listResult, err := sessionSvc.GetSessions(10000, 0, filter)
 		if err != nil {
 			t.Fatalf("failed to get sessions: %v", err)
 		}
 
 		// Get CSV export
-		csvData, err := sessionSvc.ExportCSV(status, category)
+		csvData, err := sessionSvc.ExportCSV(filter)
 		if err != nil {
 			t.Fatalf("failed to export CSV: %v", err)
 		}
@@ -214,6 +218,18 @@ func TestCSVExport_Property9_SessionsFilterConsistency(t *testing.T) {
 			if status != nil && csvStatus != *status {
 				t.Fatalf("CSV row %d has status %q, expected %q", i, csvStatus, *status)
 			}
+
+			// Date bounds are normalized by the filter: from inclusive, to exclusive
+			csvStartedAt := records[i][6]
+			if filter.From != nil && csvStartedAt < *filter.From {
+				t.Fatalf("CSV row %d started at %s, before %s", i, csvStartedAt, *filter.From)
+			}
+			if filter.To != nil && csvStartedAt >= *filter.To {
+				t.Fatalf("CSV row %d started at %s, not before %s", i, csvStartedAt, *filter.To)
+			}
+			if records[i][0] != fmt.Sprintf("%d", listResult.Items[i-1].ID) {
+				t.Fatalf("CSV row %d has id %s, list has %d", i, records[i][0], listResult.Items[i-1].ID)
+			}
 		}
 	})
 }
@@ -0,0 +1,90 @@
+package service
+
+import "sync"
+
+// Session event types, sent as the SSE event name by the session streams.
+const (
+	EventSessionStarted = "started"
+	EventSessionStopped = "stopped"
+	EventSessionUpdated = "updated"
+	EventSessionDeleted = "deleted"
+)
+
+// SessionEvent reports a change made through the SessionService to one of
+// a user's sessions.
+type SessionEvent struct {
+	Type      string
+	SessionID int64
+	UserID    int64
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind by
+// before further events are dropped for it. Subscribers re-read the
+// current session on every event, so a dropped one loses nothing that
+// the next does not carry.
+const subscriberBuffer = 8
+
+// Events is an in-process publisher of session changes. Publishing never
+// blocks: a subscriber that is not keeping up misses events instead.
+type Events struct {
+	mu     sync.Mutex
+	subs   map[chan SessionEvent]struct{}
+	closed bool
+}
+
+// NewEvents creates an Events with no subscribers.
+func NewEvents() *Events {
+	return &Events{subs: make(map[chan SessionEvent]struct{})}
+}
+
+// Subscribe returns a channel of events published from now on and a
+// function that unsubscribes it. The channel is closed by unsubscribing
+// or by Close; after Close it is returned already closed.
+func (e *Events) Subscribe() (<-chan SessionEvent, func()) {
+	ch := make(chan SessionEvent, subscriberBuffer)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	e.subs[ch] = struct{}{}
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, ok := e.subs[ch]; ok {
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends event to every subscriber.
+func (e *Events) Publish(event SessionEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close closes every subscriber channel, ending the streams reading them,
+// and makes later subscriptions return closed channels. It is called on
+// shutdown, since the HTTP server waits for open streams before it stops.
+func (e *Events) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	e.closed = true
+	for ch := range e.subs {
+		delete(e.subs, ch)
+		close(ch)
+	}
+}
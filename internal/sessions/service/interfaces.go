@@ -1,14 +1,40 @@
 package service
 
-import "time-tracker/internal/sessions/models"
+import (
+	"context"
+	"io"
+	"time"
+
+	"time-tracker/internal/sessions/models"
+)
 
 // SessionServiceInterface defines the interface for session service operations.
 type SessionServiceInterface interface {
 	StartSession(data *models.SessionStart) (*models.SessionResponse, error)
+	StartSessionContext(ctx context.Context, data *models.SessionStart) (*models.SessionResponse, error)
 	DeleteSession(id int64) error
+	DeleteSessionContext(ctx context.Context, id int64) error
 	UpdateSession(id int64, data *models.SessionUpdate) error
+	UpdateSessionContext(ctx context.Context, id int64, data *models.SessionUpdate) error
 	StopSession(data *models.SessionStop) (*models.SessionResponse, error)
+	StopSessionContext(ctx context.Context, data *models.SessionStop) (*models.SessionResponse, error)
+	PurgeBefore(before time.Time, anonymize bool) (int64, error)
+	PurgeBeforeContext(ctx context.Context, before time.Time, anonymize bool) (int64, error)
 	GetCurrent() (*CurrentSessionResponse, error)
-	GetSessions(limit, offset int, status, category *string) (*models.PaginatedResponse[models.SessionResponse], error)
-	ExportCSV(status, category *string) ([]byte, error)
+	GetCurrentContext(ctx context.Context) (*CurrentSessionResponse, error)
+	GetSessions(limit, offset int, filter *models.SessionFilter) (*models.PaginatedResponse[models.SessionResponse], error)
+	GetSessionsContext(ctx context.Context, limit, offset int, filter *models.SessionFilter) (*models.PaginatedResponse[models.SessionResponse], error)
+	ExportCSV(filter *models.SessionFilter) ([]byte, error)
+	ExportCSVTo(w io.Writer, filter *models.SessionFilter, columns []string, comma rune) error
+	ExportCSVToContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, columns []string, comma rune) error
+	ExportJSON(w io.Writer, filter *models.SessionFilter, includeTags bool) error
+	ExportJSONContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, includeTags bool) error
+	ExportNDJSON(w io.Writer, filter *models.SessionFilter, includeTags bool) error
+	ExportNDJSONContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, includeTags bool) error
+	ExportXLSX(w io.Writer, filter *models.SessionFilter, loc *time.Location) error
+	ExportXLSXContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, loc *time.Location) error
+	ExportICS(w io.Writer, filter *models.SessionFilter, includeRunning bool, now time.Time) error
+	ExportICSContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, includeRunning bool, now time.Time) error
 }
+
+var _ SessionServiceInterface = (*SessionService)(nil)
@@ -2,22 +2,40 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"time-tracker/internal/sessions/models"
 	"time-tracker/internal/sessions/repository"
 
+	"time-tracker/internal/shared/auth"
 	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/database"
 	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/shared/xlsx"
 )
 
 // Session service errors
 var (
 	ErrSessionAlreadyRunning = errors.New("a session is already running")
 	ErrNoRunningSession      = errors.New("no running session found")
+	// ErrStopNotAllowed is returned when an update sets ended_at on a
+	// running session without allow_stop
+	ErrStopNotAllowed = errors.New("setting ended_at stops the running session; send allow_stop to confirm")
+	// ErrInconsistentUpdate is returned when an update would leave a
+	// session with only one of ended_at and duration_sec
+	ErrInconsistentUpdate = errors.New("ended_at and duration_sec must be set or cleared together")
+	// ErrSessionNotFound and ErrMultipleRunning are the repository's
+	// errors, passed through as is
+	ErrSessionNotFound = repository.ErrSessionNotFound
+	ErrMultipleRunning = repository.ErrMultipleRunning
 )
 
 // CurrentSessionResponse represents the response for current session status.
@@ -25,91 +43,263 @@ type CurrentSessionResponse struct {
 	Running    bool                    `json:"running"`
 	Session    *models.SessionResponse `json:"session,omitempty"`
 	ElapsedSec *int64                  `json:"elapsed_sec,omitempty"`
+	// RemindersFired lists the reminder rules that have fired for the
+	// running session, oldest first.
+	RemindersFired []int64 `json:"reminders_fired,omitempty"`
 }
 
-// SessionService handles business logic for session operations.
+// FiredReminders looks up which reminder rules have fired for a session.
+type FiredReminders interface {
+	FiredContext(ctx context.Context, sessionID int64) ([]int64, error)
+}
+
+// SessionService handles business logic for session operations. The
+// Context methods act for the user the context carries and return
+// auth.ErrNoUser if it carries none; the others act for the default user.
 type SessionService struct {
-	repo *repository.SessionRepository
+	repo     repository.SessionRepositoryInterface
+	events   *Events
+	defaults models.Defaults
+	// reminders fills CurrentSessionResponse.RemindersFired; nil leaves it out
+	reminders FiredReminders
+
+	// maxPageSize bounds the page GetSessions returns
+	maxPageSize int
 }
 
 // NewSessionService creates a new SessionService.
-func NewSessionService(repo *repository.SessionRepository) *SessionService {
+func NewSessionService(repo repository.SessionRepositoryInterface) *SessionService {
 	return &SessionService{
-		repo: repo,
+		repo:        repo,
+		events:      NewEvents(),
+		defaults:    models.BuiltinDefaults,
+		maxPageSize: config.MaxListPageSize,
+	}
+}
+
+// SetDefaults sets the category and task given to sessions started without
+// one. Empty fields keep their current default.
+func (s *SessionService) SetDefaults(defaults models.Defaults) {
+	if defaults.Category != "" {
+		s.defaults.Category = defaults.Category
+	}
+	if defaults.Task != "" {
+		s.defaults.Task = defaults.Task
+	}
+}
+
+// Defaults returns the category and task given to sessions started without
+// one.
+func (s *SessionService) Defaults() models.Defaults {
+	return s.defaults
+}
+
+// SetMaxPageSize overrides the largest page GetSessions returns.
+func (s *SessionService) SetMaxPageSize(max int) {
+	if max > 0 {
+		s.maxPageSize = max
+	}
+}
+
+// SetReminders makes GetCurrent report the reminders fired for the running
+// session.
+func (s *SessionService) SetReminders(reminders FiredReminders) {
+	if reminders != nil {
+		s.reminders = reminders
 	}
 }
 
+// Events returns the publisher of the sessions started, stopped, updated
+// and deleted through this service.
+func (s *SessionService) Events() *Events {
+	return s.events
+}
+
 // StartSession starts a new session after checking for conflicts.
-// Returns ErrSessionAlreadyRunning if a session is already running.
+// Returns ErrSessionAlreadyRunning if a session is already running, along
+// with that session and its elapsed time.
 func (s *SessionService) StartSession(data *models.SessionStart) (*models.SessionResponse, error) {
-	if err := data.Validate(); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
-	}
+	return s.StartSessionContext(auth.DefaultUserContext(), data)
+}
 
-	// Check for existing running session
-	running, err := s.repo.GetRunning()
+// StartSessionContext is like StartSession but takes a context for cancellation.
+func (s *SessionService) StartSessionContext(ctx context.Context, data *models.SessionStart) (*models.SessionResponse, error) {
+	userID, err := auth.RequireUserID(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if running != nil {
-		return running, ErrSessionAlreadyRunning
+	if err := data.ValidateWithDefaults(s.defaults); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
 	}
 
-	return s.repo.Create(data)
+	// The repository checks for a running session and inserts in one
+	// transaction, backed by a unique index on running sessions
+	session, err := s.repo.CreateContext(ctx, userID, data)
+	if errors.Is(err, repository.ErrSessionAlreadyRunning) {
+		if session != nil {
+			if elapsed, err := ElapsedSince(session.StartedAt); err == nil {
+				session.ElapsedSec = &elapsed
+			}
+		}
+		return session, ErrSessionAlreadyRunning
+	}
+	if err != nil {
+		return session, err
+	}
+	s.events.Publish(SessionEvent{Type: EventSessionStarted, SessionID: session.ID, UserID: userID})
+	return session, nil
 }
 
 // DeleteSession deletes a session entry.
 func (s *SessionService) DeleteSession(id int64) error {
-	return s.repo.Delete(id)
+	return s.DeleteSessionContext(auth.DefaultUserContext(), id)
 }
 
-// UpdateSession updates a session entry after validation.
+// DeleteSessionContext is like DeleteSession but takes a context for cancellation.
+func (s *SessionService) DeleteSessionContext(ctx context.Context, id int64) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteContext(ctx, userID, id); err != nil {
+		return err
+	}
+	s.events.Publish(SessionEvent{Type: EventSessionDeleted, SessionID: id, UserID: userID})
+	return nil
+}
+
+// UpdateSession updates a session entry after validation. started_at may not
+// be in the future. A running session has neither ended_at nor duration_sec
+// and a stopped one has both, so:
+//   - setting ended_at on a running session stops it when AllowStop is set
+//     and returns ErrStopNotAllowed otherwise;
+//   - clearing ended_at and duration_sec together reopens a stopped session,
+//     or returns ErrSessionAlreadyRunning if another session is running;
+//   - any other change that would leave only one of them set returns
+//     ErrInconsistentUpdate.
+//
+// The duration of a stopped session is always recalculated from its times,
+// and an ended_at before started_at is a validation error.
 func (s *SessionService) UpdateSession(id int64, data *models.SessionUpdate) error {
+	return s.UpdateSessionContext(auth.DefaultUserContext(), id, data)
+}
+
+// UpdateSessionContext is like UpdateSession but takes a context for cancellation.
+func (s *SessionService) UpdateSessionContext(ctx context.Context, id int64, data *models.SessionUpdate) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
 	if err := data.Validate(); err != nil {
 		return fmt.Errorf("validation error: %w", err)
 	}
+	if data.StartedAt != nil {
+		if start, err := time.Parse(time.RFC3339, *data.StartedAt); err == nil && start.After(time.Now()) {
+			return fmt.Errorf("validation error: %w", models.FieldErr("started_at", models.ErrStartedInFuture))
+		}
+	}
 
-	// If timestamps are modified, we might need to recalculate duration
-	if data.StartedAt != nil || data.EndedAt != nil {
-		session, err := s.repo.GetByID(id)
+	// Changes to the times may change the duration and whether the session runs
+	if data.StartedAt != nil || data.EndedAt.IsSet() || data.DurationSec.IsSet() {
+		session, err := s.repo.GetByIDContext(ctx, userID, id)
 		if err != nil {
 			return err
 		}
 		if session == nil {
-			return errors.New("session not found")
+			return ErrSessionNotFound
 		}
+		if err := s.applyTimes(ctx, session, data); err != nil {
+			return err
+		}
+	}
 
-		// Only recalculate if session is stopped
-		if session.Status == string(models.SessionStatusStopped) {
-			startTimeStr := session.StartedAt
-			if data.StartedAt != nil {
-				startTimeStr = *data.StartedAt
-			}
-			endTimeStr := ""
-			if session.EndedAt != nil {
-				endTimeStr = *session.EndedAt
-			}
-			if data.EndedAt != nil {
-				endTimeStr = *data.EndedAt
-			}
+	if err := s.repo.UpdateContext(ctx, userID, id, data); err != nil {
+		// Another session was started after applyTimes checked
+		if data.Status != nil && *data.Status == string(models.SessionStatusRunning) && database.IsUniqueViolation(err) {
+			return ErrSessionAlreadyRunning
+		}
+		return err
+	}
+	s.events.Publish(SessionEvent{Type: EventSessionUpdated, SessionID: id, UserID: userID})
+	return nil
+}
 
-			if endTimeStr != "" {
-				start, err1 := time.Parse(time.RFC3339, startTimeStr)
-				end, err2 := time.Parse(time.RFC3339, endTimeStr)
-				if err1 == nil && err2 == nil {
-					duration := int64(end.Sub(start).Seconds())
-					data.DurationSec = &duration
-				}
-			}
+// applyTimes checks data's ended_at and duration_sec against the session's
+// status, setting the new status and duration the update implies.
+func (s *SessionService) applyTimes(ctx context.Context, session *models.SessionResponse, data *models.SessionUpdate) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	running := session.Status == string(models.SessionStatusRunning)
+	endedAt := data.EndedAt.Ptr()
+	if !data.EndedAt.IsSet() {
+		endedAt = session.EndedAt
+	}
+
+	switch {
+	case running && data.EndedAt.Ptr() != nil:
+		if !data.AllowStop {
+			return ErrStopNotAllowed
 		}
+		stopped := string(models.SessionStatusStopped)
+		data.Status = &stopped
+	case running:
+		// Clearing what is already clear is allowed; a duration alone is not
+		if data.DurationSec.Ptr() != nil {
+			return ErrInconsistentUpdate
+		}
+		return nil
+	case data.EndedAt.IsNull() != data.DurationSec.IsNull():
+		return ErrInconsistentUpdate
+	case data.EndedAt.IsNull():
+		other, err := s.repo.GetRunningContext(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if other != nil {
+			return ErrSessionAlreadyRunning
+		}
+		status := string(models.SessionStatusRunning)
+		data.Status = &status
+		return nil
 	}
 
-	return s.repo.Update(id, data)
+	if endedAt == nil {
+		return nil
+	}
+	startedAt := session.StartedAt
+	if data.StartedAt != nil {
+		startedAt = *data.StartedAt
+	}
+	start, err1 := time.Parse(time.RFC3339, startedAt)
+	end, err2 := time.Parse(time.RFC3339, *endedAt)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("failed to parse session times: %w", errors.Join(err1, err2))
+	}
+	if end.Before(start) {
+		field := "ended_at"
+		if !data.EndedAt.IsSet() {
+			field = "started_at"
+		}
+		return fmt.Errorf("validation error: %w", models.FieldErr(field, models.ErrEndedBeforeStart))
+	}
+	data.DurationSec = models.Some(models.RoundSeconds(end.Sub(start)))
+	return nil
 }
 
 // StopSession stops the currently running session.
 // Returns ErrNoRunningSession if no session is running.
 func (s *SessionService) StopSession(data *models.SessionStop) (*models.SessionResponse, error) {
+	return s.StopSessionContext(auth.DefaultUserContext(), data)
+}
+
+// StopSessionContext is like StopSession but takes a context for cancellation.
+func (s *SessionService) StopSessionContext(ctx context.Context, data *models.SessionStop) (*models.SessionResponse, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if data != nil {
 		if err := data.Validate(); err != nil {
 			return nil, fmt.Errorf("validation error: %w", err)
@@ -118,7 +308,7 @@ func (s *SessionService) StopSession(data *models.SessionStop) (*models.SessionR
 		data = &models.SessionStop{}
 	}
 
-	session, err := s.repo.StopRunning(data)
+	session, err := s.repo.StopRunningContext(ctx, userID, data)
 	if errors.Is(err, repository.ErrNoRunningSession) {
 		return nil, ErrNoRunningSession
 	}
@@ -126,12 +316,34 @@ func (s *SessionService) StopSession(data *models.SessionStop) (*models.SessionR
 		return nil, err
 	}
 
+	s.events.Publish(SessionEvent{Type: EventSessionStopped, SessionID: session.ID, UserID: userID})
 	return session, nil
 }
 
+// PurgeBefore deletes, or with anonymize strips the free text from, stopped
+// sessions that ended before the cutoff. The running session is kept
+// regardless of age. Returns the number of sessions affected.
+func (s *SessionService) PurgeBefore(before time.Time, anonymize bool) (int64, error) {
+	return s.PurgeBeforeContext(auth.DefaultUserContext(), before, anonymize)
+}
+
+// PurgeBeforeContext is like PurgeBefore but takes a context for cancellation.
+func (s *SessionService) PurgeBeforeContext(ctx context.Context, before time.Time, anonymize bool) (int64, error) {
+	return s.repo.PurgeBeforeContext(ctx, models.FormatRFC3339(before), anonymize)
+}
+
 // GetCurrent returns the current session status.
 func (s *SessionService) GetCurrent() (*CurrentSessionResponse, error) {
-	running, err := s.repo.GetRunning()
+	return s.GetCurrentContext(auth.DefaultUserContext())
+}
+
+// GetCurrentContext is like GetCurrent but takes a context for cancellation.
+func (s *SessionService) GetCurrentContext(ctx context.Context) (*CurrentSessionResponse, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	running, err := s.repo.GetRunningContext(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -142,95 +354,522 @@ func (s *SessionService) GetCurrent() (*CurrentSessionResponse, error) {
 		}, nil
 	}
 
-	// Calculate elapsed time
-	startTime, err := time.Parse(time.RFC3339, running.StartedAt)
+	elapsed, err := ElapsedSince(running.StartedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse started_at: %w", err)
+		return nil, err
 	}
-	elapsed := int64(time.Since(startTime).Seconds())
 
-	return &CurrentSessionResponse{
+	current := &CurrentSessionResponse{
 		Running:    true,
 		Session:    running,
 		ElapsedSec: &elapsed,
-	}, nil
+	}
+	if s.reminders != nil {
+		if current.RemindersFired, err = s.reminders.FiredContext(ctx, running.ID); err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+// ElapsedSince returns the whole seconds since startedAt, an RFC3339
+// timestamp, or zero if startedAt is in the future.
+func ElapsedSince(startedAt string) (int64, error) {
+	startTime, err := time.Parse(time.RFC3339, startedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse started_at: %w", err)
+	}
+	if elapsed := int64(time.Since(startTime).Seconds()); elapsed > 0 {
+		return elapsed, nil
+	}
+	return 0, nil
+}
+
+// GetSession returns the session with the given ID.
+func (s *SessionService) GetSession(id int64) (*models.SessionResponse, error) {
+	return s.GetSessionContext(auth.DefaultUserContext(), id)
+}
+
+// GetSessionContext is like GetSession but takes a context for cancellation.
+func (s *SessionService) GetSessionContext(ctx context.Context, id int64) (*models.SessionResponse, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.repo.GetByIDContext(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// GetCategories returns the distinct session categories, most recently used first.
+func (s *SessionService) GetCategories() ([]string, error) {
+	return s.GetCategoriesContext(auth.DefaultUserContext())
+}
+
+// GetCategoriesContext is like GetCategories but takes a context for cancellation.
+func (s *SessionService) GetCategoriesContext(ctx context.Context) ([]string, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.CategoriesContext(ctx, userID)
 }
 
 // GetSessions retrieves a paginated list of sessions with optional filters.
-func (s *SessionService) GetSessions(limit, offset int, status, category *string) (*models.PaginatedResponse[models.SessionResponse], error) {
+func (s *SessionService) GetSessions(limit, offset int, filter *models.SessionFilter) (*models.PaginatedResponse[models.SessionResponse], error) {
+	return s.GetSessionsContext(auth.DefaultUserContext(), limit, offset, filter)
+}
+
+// GetSessionsContext is like GetSessions, tracing its queries under the span in ctx.
+func (s *SessionService) GetSessionsContext(ctx context.Context, limit, offset int, filter *models.SessionFilter) (*models.PaginatedResponse[models.SessionResponse], error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = &models.SessionFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
 	// Apply default and max limits
 	if limit <= 0 {
 		limit = config.DefaultPageSize
 	}
-	if limit > config.MaxPageSize {
-		limit = config.MaxPageSize
+	if limit > s.maxPageSize {
+		limit = s.maxPageSize
 	}
 	if offset < 0 {
 		offset = 0
 	}
 
-	sessions, err := s.repo.List(limit, offset, status, category)
+	sessions, total, err := s.repo.ListWithTotalContext(ctx, userID, limit, offset, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	total, err := s.repo.Count(status, category)
-	if err != nil {
-		return nil, err
+	return models.NewPaginatedResponse(sessions, total, limit, offset), nil
+}
+
+// csvColumn describes one exportable CSV column.
+type csvColumn struct {
+	name  string
+	value func(*models.SessionResponse) string
+}
+
+// csvColumns lists every exportable column in default order.
+// New columns go at the end so positional consumers keep working.
+var csvColumns = []csvColumn{
+	{"id", func(s *models.SessionResponse) string { return fmt.Sprintf("%d", s.ID) }},
+	{"category", func(s *models.SessionResponse) string { return s.Category }},
+	{"task", func(s *models.SessionResponse) string { return s.Task }},
+	{"note", func(s *models.SessionResponse) string { return utils.PtrToString(s.Note) }},
+	{"location", func(s *models.SessionResponse) string { return utils.PtrToString(s.Location) }},
+	{"mood", func(s *models.SessionResponse) string { return utils.PtrToString(s.Mood) }},
+	{"started_at", func(s *models.SessionResponse) string { return s.StartedAt }},
+	{"ended_at", func(s *models.SessionResponse) string { return utils.PtrToString(s.EndedAt) }},
+	{"duration", func(s *models.SessionResponse) string { return utils.FormatDuration(s.DurationSec) }},
+	{"status", func(s *models.SessionResponse) string { return s.Status }},
+	{"duration_sec", func(s *models.SessionResponse) string { return utils.PtrToInt64String(s.DurationSec) }},
+	{"tags", func(s *models.SessionResponse) string { return strings.Join(s.Tags, "; ") }},
+	{"created_at", func(s *models.SessionResponse) string { return s.CreatedAt }},
+	{"updated_at", func(s *models.SessionResponse) string { return s.UpdatedAt }},
+}
+
+// ParseCSVColumns parses a comma-separated column list. An empty spec selects
+// the default columns (nil). Unknown names are reported together.
+func ParseCSVColumns(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
 	}
 
-	return &models.PaginatedResponse[models.SessionResponse]{
-		Items:  sessions,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
-	}, nil
+	names := []string{}
+	unknown := []string{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := lookupCSVColumn(name); !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		names = append(names, name)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown columns: %s", strings.Join(unknown, ", "))
+	}
+	return names, nil
+}
+
+func lookupCSVColumn(name string) (csvColumn, bool) {
+	for _, col := range csvColumns {
+		if col.name == name {
+			return col, true
+		}
+	}
+	return csvColumn{}, false
+}
+
+// csvDelimiters maps the delimiter names accepted by ParseCSVDelimiter to
+// their separator characters.
+var csvDelimiters = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+}
+
+// ParseCSVDelimiter resolves a delimiter name (comma, semicolon or tab).
+// An empty name selects comma.
+func ParseCSVDelimiter(name string) (rune, error) {
+	if name == "" {
+		return ',', nil
+	}
+	comma, ok := csvDelimiters[name]
+	if !ok {
+		return 0, fmt.Errorf("delimiter must be one of: comma, semicolon, tab")
+	}
+	return comma, nil
 }
 
 // ExportCSV exports sessions as CSV with UTF-8 BOM for Excel compatibility.
 // Includes duration in human-readable format (H:MM:SS).
-func (s *SessionService) ExportCSV(status, category *string) ([]byte, error) {
-	// Get all matching sessions (no pagination for export)
-	sessions, err := s.repo.List(config.MaxExportLimit, 0, status, category)
-	if err != nil {
+func (s *SessionService) ExportCSV(filter *models.SessionFilter) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(utils.UTF8BOM)
+
+	if err := s.ExportCSVTo(&buf, filter, nil, ','); err != nil {
 		return nil, err
 	}
 
-	var buf bytes.Buffer
-	// Write UTF-8 BOM
-	buf.Write([]byte{0xEF, 0xBB, 0xBF})
+	return buf.Bytes(), nil
+}
 
-	writer := csv.NewWriter(&buf)
+// ExportCSVTo streams all sessions matching the filter to w as CSV, one record
+// per row, without buffering the result set. The caller writes any BOM.
+// columns selects and orders the output columns; nil means all columns.
+// comma is the field separator; zero means ','.
+func (s *SessionService) ExportCSVTo(w io.Writer, filter *models.SessionFilter, columns []string, comma rune) error {
+	return s.ExportCSVToContext(auth.DefaultUserContext(), w, filter, columns, comma)
+}
+
+// ExportCSVToContext is like ExportCSVTo but takes a context for cancellation.
+func (s *SessionService) ExportCSVToContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, columns []string, comma rune) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = &models.SessionFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	selected := csvColumns
+	if len(columns) > 0 {
+		selected = make([]csvColumn, 0, len(columns))
+		for _, name := range columns {
+			col, ok := lookupCSVColumn(name)
+			if !ok {
+				return fmt.Errorf("validation error: unknown columns: %s", name)
+			}
+			selected = append(selected, col)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if comma != 0 {
+		writer.Comma = comma
+	}
 
 	// Write header
-	header := []string{"id", "category", "task", "note", "location", "mood", "started_at", "ended_at", "duration", "status"}
+	header := make([]string, len(selected))
+	withTags := false
+	for i, col := range selected {
+		header[i] = col.name
+		// Tag names are only loaded when the tags column is exported
+		withTags = withTags || col.name == "tags"
+	}
 	if err := writer.Write(header); err != nil {
-		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write data rows
-	for _, session := range sessions {
-		row := []string{
-			fmt.Sprintf("%d", session.ID),
-			session.Category,
-			session.Task,
-			utils.PtrToString(session.Note),
-			utils.PtrToString(session.Location),
-			utils.PtrToString(session.Mood),
-			session.StartedAt,
-			utils.PtrToString(session.EndedAt),
-			utils.FormatDuration(session.DurationSec),
-			session.Status,
+	row := make([]string, len(selected))
+	err = s.repo.IterateContext(ctx, userID, filter, withTags, func(session *models.SessionResponse) error {
+		for i, col := range selected {
+			row[i] = col.value(session)
 		}
 		if err := writer.Write(row); err != nil {
-			return nil, fmt.Errorf("failed to write CSV row: %w", err)
+			return fmt.Errorf("failed to write CSV row: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	writer.Flush()
 	if err := writer.Error(); err != nil {
-		return nil, fmt.Errorf("CSV writer error: %w", err)
+		return fmt.Errorf("CSV writer error: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return nil
+}
+
+// ExportJSON streams all sessions matching the filter to w as a JSON array.
+// Rows are encoded one at a time so memory use does not grow with the export size.
+func (s *SessionService) ExportJSON(w io.Writer, filter *models.SessionFilter, includeTags bool) error {
+	return s.ExportJSONContext(auth.DefaultUserContext(), w, filter, includeTags)
+}
+
+// ExportJSONContext is like ExportJSON but takes a context for cancellation.
+func (s *SessionService) ExportJSONContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, includeTags bool) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = &models.SessionFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	first := true
+	err = s.repo.IterateContext(ctx, userID, filter, includeTags, func(session *models.SessionResponse) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return encoder.Encode(session)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+// ExportNDJSON streams sessions matching the filter to w as newline-delimited
+// JSON, one object per line.
+func (s *SessionService) ExportNDJSON(w io.Writer, filter *models.SessionFilter, includeTags bool) error {
+	return s.ExportNDJSONContext(auth.DefaultUserContext(), w, filter, includeTags)
+}
+
+// ExportNDJSONContext is like ExportNDJSON but takes a context for cancellation.
+func (s *SessionService) ExportNDJSONContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, includeTags bool) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = &models.SessionFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	// json.Encoder terminates every value with a newline
+	encoder := json.NewEncoder(w)
+	return s.repo.IterateContext(ctx, userID, filter, includeTags, func(session *models.SessionResponse) error {
+		return encoder.Encode(session)
+	})
+}
+
+// ExportXLSX streams all sessions matching the filter to w as an Excel workbook.
+// Timestamps are written as date-time cells in loc and durations as decimal hours.
+func (s *SessionService) ExportXLSX(w io.Writer, filter *models.SessionFilter, loc *time.Location) error {
+	return s.ExportXLSXContext(auth.DefaultUserContext(), w, filter, loc)
+}
+
+// ExportXLSXContext is like ExportXLSX but takes a context for cancellation.
+func (s *SessionService) ExportXLSXContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, loc *time.Location) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = &models.SessionFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	book, err := xlsx.NewWriter(w, "Sessions")
+	if err != nil {
+		return err
+	}
+
+	header := []xlsx.Cell{}
+	for _, name := range []string{"id", "category", "task", "note", "location", "mood", "started_at", "ended_at", "duration_hours", "status"} {
+		header = append(header, xlsx.String(name))
+	}
+	if err := book.WriteRow(header...); err != nil {
+		return err
+	}
+
+	err = s.repo.IterateContext(ctx, userID, filter, false, func(session *models.SessionResponse) error {
+		return book.WriteRow(
+			xlsx.Int(session.ID),
+			xlsx.String(session.Category),
+			xlsx.String(session.Task),
+			xlsx.String(utils.PtrToString(session.Note)),
+			xlsx.String(utils.PtrToString(session.Location)),
+			xlsx.String(utils.PtrToString(session.Mood)),
+			timeCell(&session.StartedAt, loc),
+			timeCell(session.EndedAt, loc),
+			hoursCell(session.DurationSec),
+			xlsx.String(session.Status),
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	return book.Close()
+}
+
+// timeCell converts a stored RFC3339 timestamp to a date-time cell in loc.
+func timeCell(value *string, loc *time.Location) xlsx.Cell {
+	if value == nil {
+		return xlsx.Empty()
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return xlsx.String(*value)
+	}
+	return xlsx.Time(t.In(loc))
+}
+
+// hoursCell converts a duration in seconds to a decimal hours cell.
+func hoursCell(durationSec *int64) xlsx.Cell {
+	if durationSec == nil {
+		return xlsx.Empty()
+	}
+	return xlsx.Decimal(float64(*durationSec) / 3600)
+}
+
+// ExportICS streams sessions matching the filter to w as an iCalendar feed with
+// one VEVENT per stopped session. Running sessions are skipped unless
+// includeRunning is set, in which case they end at now.
+func (s *SessionService) ExportICS(w io.Writer, filter *models.SessionFilter, includeRunning bool, now time.Time) error {
+	return s.ExportICSContext(auth.DefaultUserContext(), w, filter, includeRunning, now)
+}
+
+// ExportICSContext is like ExportICS but takes a context for cancellation.
+func (s *SessionService) ExportICSContext(ctx context.Context, w io.Writer, filter *models.SessionFilter, includeRunning bool, now time.Time) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = &models.SessionFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	stamp := now.UTC().Format(icsTimeFormat)
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//time-tracker//sessions//EN")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	err = s.repo.IterateContext(ctx, userID, filter, false, func(session *models.SessionResponse) error {
+		start, err := time.Parse(time.RFC3339, session.StartedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse started_at: %w", err)
+		}
+
+		var end time.Time
+		switch {
+		case session.EndedAt != nil:
+			end, err = time.Parse(time.RFC3339, *session.EndedAt)
+			if err != nil {
+				return fmt.Errorf("failed to parse ended_at: %w", err)
+			}
+		case includeRunning:
+			end = now
+		default:
+			return nil
+		}
+
+		buf.Reset()
+		writeICSLine(&buf, "BEGIN:VEVENT")
+		writeICSLine(&buf, fmt.Sprintf("UID:session-%d@time-tracker", session.ID))
+		writeICSLine(&buf, "DTSTAMP:"+stamp)
+		writeICSLine(&buf, "DTSTART:"+start.UTC().Format(icsTimeFormat))
+		writeICSLine(&buf, "DTEND:"+end.UTC().Format(icsTimeFormat))
+		writeICSLine(&buf, "SUMMARY:"+escapeICSText(session.Category+": "+session.Task))
+		if session.Note != nil && *session.Note != "" {
+			writeICSLine(&buf, "DESCRIPTION:"+escapeICSText(*session.Note))
+		}
+		writeICSLine(&buf, "END:VEVENT")
+		_, err = w.Write(buf.Bytes())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "END:VCALENDAR\r\n")
+	return err
+}
+
+// icsTimeFormat is the RFC 5545 UTC date-time form.
+const icsTimeFormat = "20060102T150405Z"
+
+// escapeICSText escapes a TEXT property value per RFC 5545.
+func escapeICSText(s string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+		"\r", "",
+	).Replace(s)
+}
+
+// writeICSLine writes a content line terminated by CRLF, folding it so no
+// physical line exceeds 75 octets. Folds never split a UTF-8 sequence.
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts toward the limit
+		limit = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
 }
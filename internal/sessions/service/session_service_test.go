@@ -1,14 +1,25 @@
 package service
 
 import (
-	"os"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"pgregory.net/rapid"
 	"time-tracker/internal/sessions/models"
 	"time-tracker/internal/sessions/repository"
 
+	"time-tracker/internal/shared/auth"
 	"time-tracker/internal/shared/database"
 	"time-tracker/internal/shared/utils"
 )
@@ -20,32 +31,8 @@ import (
 // - When created, status is "running", has started_at timestamp, ended_at and duration_sec are null
 // - After stopped, status is "stopped", has ended_at timestamp, duration_sec = ended_at - started_at (seconds)
 
-func setupTestDB(t *testing.T) (*database.DB, func()) {
-	t.Helper()
-
-	tmpFile, err := os.CreateTemp("", "service_test_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	db, err := database.New(tmpFile.Name())
-	if err != nil {
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	cleanup := func() {
-		db.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return db, cleanup
-}
-
 func TestSessionService_Property4_Lifecycle(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -107,8 +94,7 @@ func TestSessionService_Property4_Lifecycle(t *testing.T) {
 // 409 Conflict with the current running Session's information.
 
 func TestSessionService_Property5_ConcurrencyControl(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -161,8 +147,7 @@ func TestSessionService_Property5_ConcurrencyControl(t *testing.T) {
 // the stopped Session should contain these updated field values.
 
 func TestSessionService_Property6_StopUpdates(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -197,11 +182,16 @@ func TestSessionService_Property6_StopUpdates(t *testing.T) {
 		}
 
 		// Stop with updates
-		stopped, err := svc.StopSession(&models.SessionStop{
-			Note:     note,
-			Location: location,
-			Mood:     mood,
-		})
+		stop := &models.SessionStop{}
+		for _, f := range []struct {
+			value *string
+			field *models.Optional[string]
+		}{{note, &stop.Note}, {location, &stop.Location}, {mood, &stop.Mood}} {
+			if f.value != nil {
+				*f.field = models.Some(*f.value)
+			}
+		}
+		stopped, err := svc.StopSession(stop)
 		if err != nil {
 			t.Fatalf("failed to stop session: %v", err)
 		}
@@ -235,8 +225,7 @@ func TestSessionService_Property6_StopUpdates(t *testing.T) {
 // - Using category filter, all returned Sessions have matching category
 
 func TestSessionService_Property7_QueryCorrectness_Current(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -282,8 +271,7 @@ func TestSessionService_Property7_QueryCorrectness_Current(t *testing.T) {
 }
 
 func TestSessionService_Property7_StatusFilter(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -315,7 +303,7 @@ func TestSessionService_Property7_StatusFilter(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		status := rapid.SampledFrom([]string{"running", "stopped"}).Draw(t, "status")
 
-		result, err := svc.GetSessions(50, 0, &status, nil)
+		result, err := svc.GetSessions(50, 0, &models.SessionFilter{Status: &status})
 		if err != nil {
 			t.Fatalf("failed to get sessions: %v", err)
 		}
@@ -330,8 +318,7 @@ func TestSessionService_Property7_StatusFilter(t *testing.T) {
 }
 
 func TestSessionService_Property7_CategoryFilter(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -355,7 +342,7 @@ func TestSessionService_Property7_CategoryFilter(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		category := rapid.SampledFrom(categories).Draw(t, "category")
 
-		result, err := svc.GetSessions(50, 0, nil, &category)
+		result, err := svc.GetSessions(50, 0, &models.SessionFilter{Category: &category})
 		if err != nil {
 			t.Fatalf("failed to get sessions: %v", err)
 		}
@@ -371,8 +358,7 @@ func TestSessionService_Property7_CategoryFilter(t *testing.T) {
 
 // TestSessionService_StopNoRunning tests stopping when no session is running.
 func TestSessionService_StopNoRunning(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -383,10 +369,367 @@ func TestSessionService_StopNoRunning(t *testing.T) {
 	}
 }
 
+func TestSessionService_ClockSkew(t *testing.T) {
+	db := database.NewForTesting(t)
+	sessionRepo := repository.NewSessionRepository(db)
+	svc := NewSessionService(sessionRepo)
+
+	session, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "skewed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	err = svc.UpdateSession(session.ID, &models.SessionUpdate{StartedAt: &future})
+	if err == nil || !strings.Contains(err.Error(), models.ErrStartedInFuture.Error()) {
+		t.Fatalf("UpdateSession with a future started_at: got %v, want %v", err, models.ErrStartedInFuture)
+	}
+
+	// The service refuses it, so write the future start straight to the
+	// repository, as a clock stepping backwards would leave it
+	if err := sessionRepo.Update(database.DefaultUserID, session.ID, &models.SessionUpdate{StartedAt: &future}); err != nil {
+		t.Fatal(err)
+	}
+	current, err := svc.GetCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.ElapsedSec == nil || *current.ElapsedSec != 0 {
+		t.Errorf("elapsed_sec = %v, want 0", current.ElapsedSec)
+	}
+
+	stopped, err := svc.StopSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.DurationSec == nil || *stopped.DurationSec != 0 {
+		t.Errorf("duration_sec = %v, want 0", stopped.DurationSec)
+	}
+
+	// Editing a stopped session to end before it starts is refused
+	ended := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	started := time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339)
+	err = svc.UpdateSession(session.ID, &models.SessionUpdate{StartedAt: &started, EndedAt: models.Some(ended)})
+	if err == nil || !strings.Contains(err.Error(), models.ErrEndedBeforeStart.Error()) {
+		t.Fatalf("UpdateSession ending before the start: got %v, want %v", err, models.ErrEndedBeforeStart)
+	}
+
+	// A negative duration written past the service is still stored as zero
+	negative := int64(-60)
+	if err := sessionRepo.Update(database.DefaultUserID, session.ID, &models.SessionUpdate{DurationSec: models.Some(negative)}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.GetSession(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DurationSec == nil || *got.DurationSec != 0 {
+		t.Errorf("duration_sec after edit = %v, want 0", got.DurationSec)
+	}
+}
+
+// TestSessionService_UpdateTransitions covers how updates to ended_at and
+// duration_sec move a session between running and stopped.
+func TestSessionService_UpdateTransitions(t *testing.T) {
+	db := database.NewForTesting(t)
+	svc := NewSessionService(repository.NewSessionRepository(db))
+
+	first, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, err := time.Parse(time.RFC3339, first.StartedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended := models.FormatRFC3339(started.Add(90 * time.Second))
+
+	// Ending a running session needs confirmation
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{EndedAt: models.Some(ended)}); err != ErrStopNotAllowed {
+		t.Fatalf("expected ErrStopNotAllowed, got %v", err)
+	}
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{DurationSec: models.Some[int64](60)}); err != ErrInconsistentUpdate {
+		t.Fatalf("expected ErrInconsistentUpdate for a running duration, got %v", err)
+	}
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{EndedAt: models.Some(ended), AllowStop: true}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.GetSession(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "stopped" || got.EndedAt == nil || *got.EndedAt != ended || got.DurationSec == nil || *got.DurationSec != 90 {
+		t.Fatalf("expected a stopped 90s session, got %+v", got)
+	}
+
+	// The end may not come before the start, whichever of them changes
+	early := models.FormatRFC3339(started.Add(-time.Minute))
+	late := models.FormatRFC3339(started.Add(2 * time.Minute))
+	var fieldErrs models.ValidationErrors
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{EndedAt: models.Some(early)}); !errors.As(err, &fieldErrs) || fieldErrs.Fields()["ended_at"] == "" {
+		t.Fatalf("expected an ended_at validation error, got %v", err)
+	}
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{StartedAt: &late}); !errors.As(err, &fieldErrs) || fieldErrs.Fields()["started_at"] == "" {
+		t.Fatalf("expected a started_at validation error, got %v", err)
+	}
+
+	// Reopening clears ended_at and duration_sec together
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{EndedAt: models.Null[string]()}); err != ErrInconsistentUpdate {
+		t.Fatalf("expected ErrInconsistentUpdate for ended_at alone, got %v", err)
+	}
+	if err := svc.UpdateSession(first.ID, &models.SessionUpdate{DurationSec: models.Null[int64]()}); err != ErrInconsistentUpdate {
+		t.Fatalf("expected ErrInconsistentUpdate for duration_sec alone, got %v", err)
+	}
+	reopen := func() *models.SessionUpdate {
+		return &models.SessionUpdate{EndedAt: models.Null[string](), DurationSec: models.Null[int64]()}
+	}
+
+	// Not while another session runs
+	second, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateSession(first.ID, reopen()); err != ErrSessionAlreadyRunning {
+		t.Fatalf("expected ErrSessionAlreadyRunning, got %v", err)
+	}
+	if err := svc.DeleteSession(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateSession(first.ID, reopen()); err != nil {
+		t.Fatal(err)
+	}
+	current, err := svc.GetCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Running || current.Session.ID != first.ID || current.Session.EndedAt != nil || current.Session.DurationSec != nil {
+		t.Fatalf("expected the reopened session to run, got %+v", current.Session)
+	}
+}
+
+// hiddenRunning is a repository that reports no running session, as if
+// one was started between the service's check and its update.
+type hiddenRunning struct {
+	repository.SessionRepositoryInterface
+}
+
+func (hiddenRunning) GetRunningContext(context.Context, int64) (*models.SessionResponse, error) {
+	return nil, nil
+}
+
+func TestSessionService_UpdateReopenRace(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := repository.NewSessionRepository(db)
+	svc := NewSessionService(repo)
+
+	first, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.StopSession(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	racing := NewSessionService(hiddenRunning{repo})
+	err = racing.UpdateSession(first.ID, &models.SessionUpdate{EndedAt: models.Null[string](), DurationSec: models.Null[int64]()})
+	if err != ErrSessionAlreadyRunning {
+		t.Fatalf("expected ErrSessionAlreadyRunning, got %v", err)
+	}
+}
+
+// Property: no sequence of updates leaves a session whose status disagrees
+// with its ended_at and duration_sec, or more than one session running.
+func TestSessionService_Property_UpdateInvariants(t *testing.T) {
+	db := database.NewForTesting(t)
+	svc := NewSessionService(repository.NewSessionRepository(db))
+
+	rapid.Check(t, func(t *rapid.T) {
+		if _, err := db.Exec(`DELETE FROM sessions`); err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for i := 0; i < 3; i++ {
+			session, err := svc.StartSession(&models.SessionStart{Category: "work", Task: "task"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, session.ID)
+			if i < 2 {
+				if _, err := svc.StopSession(nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		base := time.Now().Add(-24 * time.Hour)
+		steps := rapid.IntRange(1, 20).Draw(t, "steps")
+		for i := 0; i < steps; i++ {
+			update := &models.SessionUpdate{AllowStop: rapid.Bool().Draw(t, "allowStop")}
+			switch rapid.IntRange(0, 2).Draw(t, "endedAt") {
+			case 1:
+				update.EndedAt = models.Null[string]()
+			case 2:
+				offset := time.Duration(rapid.IntRange(0, 3600).Draw(t, "endOffset")) * time.Second
+				update.EndedAt = models.Some(models.FormatRFC3339(base.Add(offset)))
+			}
+			switch rapid.IntRange(0, 2).Draw(t, "durationSec") {
+			case 1:
+				update.DurationSec = models.Null[int64]()
+			case 2:
+				update.DurationSec = models.Some(int64(rapid.IntRange(0, 3600).Draw(t, "duration")))
+			}
+			if rapid.Bool().Draw(t, "hasStartedAt") {
+				started := models.FormatRFC3339(base.Add(-time.Duration(rapid.IntRange(0, 3600).Draw(t, "startOffset")) * time.Second))
+				update.StartedAt = &started
+			}
+			// Rejected updates are expected; only the stored rows matter
+			_ = svc.UpdateSession(rapid.SampledFrom(ids).Draw(t, "id"), update)
+
+			running := 0
+			for _, id := range ids {
+				got, err := svc.GetSession(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				switch got.Status {
+				case "running":
+					running++
+					if got.EndedAt != nil || got.DurationSec != nil {
+						t.Fatalf("running session %d has ended_at %v and duration_sec %v", id, got.EndedAt, got.DurationSec)
+					}
+				case "stopped":
+					if got.EndedAt == nil || got.DurationSec == nil {
+						t.Fatalf("stopped session %d has ended_at %v and duration_sec %v", id, got.EndedAt, got.DurationSec)
+					}
+				default:
+					t.Fatalf("session %d has status %q", id, got.Status)
+				}
+			}
+			if running > 1 {
+				t.Fatalf("%d sessions running", running)
+			}
+		}
+	})
+}
+
+func TestSessionService_UserIsolation(t *testing.T) {
+	db := database.NewForTesting(t)
+	if _, err := db.Exec(`INSERT INTO users (id, name, created_at) VALUES (2, 'bob', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewSessionService(repository.NewSessionRepository(db))
+	alice := auth.WithUserID(context.Background(), database.DefaultUserID)
+	bob := auth.WithUserID(context.Background(), 2)
+
+	// Each user may have a session running at the same time
+	own, err := svc.StartSessionContext(alice, &models.SessionStart{Category: "work", Task: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.StartSessionContext(bob, &models.SessionStart{Category: "work", Task: "bob"}); err != nil {
+		t.Fatalf("second user could not start a session: %v", err)
+	}
+
+	if _, err := svc.GetSessionContext(bob, own.ID); err == nil {
+		t.Error("another user's session was returned")
+	}
+	task := "taken"
+	if err := svc.UpdateSessionContext(bob, own.ID, &models.SessionUpdate{Task: &task}); err == nil {
+		t.Error("another user's session was updated")
+	}
+	if err := svc.DeleteSessionContext(bob, own.ID); err == nil {
+		t.Error("another user's session was deleted")
+	}
+
+	stopped, err := svc.StopSessionContext(bob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Task != "bob" {
+		t.Errorf("stopped task %q, want bob", stopped.Task)
+	}
+
+	current, err := svc.GetCurrentContext(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Running || current.Session.ID != own.ID || current.Session.Task != "alice" {
+		t.Errorf("first user's session changed: %+v", current.Session)
+	}
+
+	page, err := svc.GetSessionsContext(bob, 10, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || len(page.Items) != 1 || page.Items[0].Task != "bob" {
+		t.Errorf("second user listed %+v", page.Items)
+	}
+}
+
+func TestSessionService_ParallelStart(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	sessionRepo := repository.NewSessionRepository(db)
+	svc := NewSessionService(sessionRepo)
+
+	const workers = 20
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		started   []*models.SessionResponse
+		conflicts []*models.SessionResponse
+		failures  []error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session, err := svc.StartSession(&models.SessionStart{
+				Category: "work",
+				Task:     fmt.Sprintf("task %d", i),
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				started = append(started, session)
+			case err == ErrSessionAlreadyRunning:
+				conflicts = append(conflicts, session)
+			default:
+				failures = append(failures, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("unexpected errors: %v", failures)
+	}
+	if len(started) != 1 {
+		t.Fatalf("%d sessions started, want exactly 1", len(started))
+	}
+	for _, running := range conflicts {
+		if running == nil || running.ID != started[0].ID {
+			t.Errorf("conflict returned %+v, want running session %d", running, started[0].ID)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE status = 'running'").Scan(&count); err != nil {
+		t.Fatalf("failed to count running sessions: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d running sessions in the database, want 1", count)
+	}
+}
+
 // TestSessionService_ExportCSV tests CSV export functionality.
 func TestSessionService_ExportCSV(t *testing.T) {
-	db, cleanup := setupTestDB(t)
-	defer cleanup()
+	db := database.NewForTesting(t)
 
 	sessionRepo := repository.NewSessionRepository(db)
 	svc := NewSessionService(sessionRepo)
@@ -405,7 +748,7 @@ func TestSessionService_ExportCSV(t *testing.T) {
 	}
 
 	// Export CSV
-	csvData, err := svc.ExportCSV(nil, nil)
+	csvData, err := svc.ExportCSV(nil)
 	if err != nil {
 		t.Fatalf("failed to export CSV: %v", err)
 	}
@@ -417,12 +760,342 @@ func TestSessionService_ExportCSV(t *testing.T) {
 
 	// Verify content contains header and data
 	content := string(csvData[3:])
-	if !strings.Contains(content, "id,category,task,note,location,mood,started_at,ended_at,duration,status") {
+	if !strings.Contains(content, "id,category,task,note,location,mood,started_at,ended_at,duration,status,duration_sec,tags") {
 		t.Fatal("CSV missing header")
 	}
 	if !strings.Contains(content, "work") || !strings.Contains(content, "coding") {
 		t.Fatal("CSV missing data")
 	}
+
+	// Tag names are exported in the tags column
+	if _, err := db.Exec(`INSERT INTO tags (name, color, created_at) VALUES ('deep', '#000000', '2024-01-01T00:00:00Z'), ('focus', '#000000', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO session_tags (session_id, tag_id) SELECT s.id, t.id FROM sessions s, tags t`); err != nil {
+		t.Fatal(err)
+	}
+	csvData, err = svc.ExportCSV(nil)
+	if err != nil {
+		t.Fatalf("failed to export CSV: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(csvData[3:])).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if got := records[1][11]; got != "deep; focus" {
+		t.Fatalf("expected tags column %q, got %q", "deep; focus", got)
+	}
+}
+
+// countingWriter discards output while counting bytes and sampling the live heap.
+type countingWriter struct {
+	bytes      int64
+	writes     int
+	baseHeap   uint64
+	peakGrowth uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.bytes += int64(len(p))
+	c.writes++
+	// Sample from the first write on, so a single buffered write is caught too
+	if c.writes%200 == 1 {
+		if heap := liveHeap(); heap > c.baseHeap && heap-c.baseHeap > c.peakGrowth {
+			c.peakGrowth = heap - c.baseHeap
+		}
+	}
+	return len(p), nil
+}
+
+// liveHeap returns the heap in use after a full collection.
+func liveHeap() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func TestSessionService_ExportCSVTo_Streams(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large export in short mode")
+	}
+
+	db := database.NewForTesting(t)
+
+	const rows = 50000
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO sessions (category, task, note, started_at, ended_at, duration_sec, status) VALUES ('work', ?, ?, ?, ?, 1800, 'stopped')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	note := strings.Repeat("synthetic note ", 5)
+	for i := 0; i < rows; i++ {
+		start := base.Add(time.Duration(i) * time.Hour)
+		if _, err := stmt.Exec(fmt.Sprintf("task %d", i), note,
+			start.Format(time.RFC3339), start.Add(30*time.Minute).Format(time.RFC3339)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewSessionService(repository.NewSessionRepository(db))
+	w := &countingWriter{baseHeap: liveHeap()}
+	if err := svc.ExportCSVTo(w, nil, nil, ','); err != nil {
+		t.Fatalf("failed to export CSV: %v", err)
+	}
+
+	// Every row is exported: no truncation at the old 10000 row limit
+	if w.bytes < rows*100 {
+		t.Fatalf("expected at least %d bytes for %d rows, got %d", rows*100, rows, w.bytes)
+	}
+	// The live heap must stay far below the size of the output
+	if w.peakGrowth > uint64(w.bytes)/10 {
+		t.Fatalf("heap grew by %d bytes while exporting %d bytes", w.peakGrowth, w.bytes)
+	}
+}
+
+// xlsxRow mirrors a worksheet row for reading exported workbooks back.
+type xlsxRow struct {
+	Cells []struct {
+		Ref    string `xml:"r,attr"`
+		Type   string `xml:"t,attr"`
+		Value  string `xml:"v"`
+		Inline string `xml:"is>t"`
+	} `xml:"c"`
+}
+
+// readXLSXRows returns the rows of the first worksheet in an exported workbook.
+func readXLSXRows(t *testing.T, data []byte) []xlsxRow {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sheet struct {
+			Rows []xlsxRow `xml:"sheetData>row"`
+		}
+		if err := xml.Unmarshal(content, &sheet); err != nil {
+			t.Fatalf("invalid worksheet XML: %v", err)
+		}
+		return sheet.Rows
+	}
+	t.Fatal("workbook has no worksheet")
+	return nil
+}
+
+func TestSessionService_ExportXLSX(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	for _, row := range []struct{ category, task, started, ended string }{
+		{"work", "coding", "2024-03-01T10:00:00Z", "2024-03-01T11:30:00Z"},
+		{"work", "review", "2024-03-02T10:00:00Z", "2024-03-02T10:15:00Z"},
+		{"play", "games", "2024-03-03T10:00:00Z", "2024-03-03T12:00:00Z"},
+	} {
+		start, _ := time.Parse(time.RFC3339, row.started)
+		end, _ := time.Parse(time.RFC3339, row.ended)
+		if _, err := db.Exec(
+			`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES (?, ?, ?, ?, ?, 'stopped')`,
+			row.category, row.task, row.started, row.ended, int64(end.Sub(start).Seconds()),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := NewSessionService(repository.NewSessionRepository(db))
+	loc := time.FixedZone("UTC+8", 8*3600)
+
+	var buf bytes.Buffer
+	if err := svc.ExportXLSX(&buf, nil, loc); err != nil {
+		t.Fatalf("failed to export XLSX: %v", err)
+	}
+	rows := readXLSXRows(t, buf.Bytes())
+	if len(rows) != 4 {
+		t.Fatalf("expected header and 3 data rows, got %d rows", len(rows))
+	}
+	if rows[0].Cells[0].Inline != "id" {
+		t.Fatalf("expected header row, got %+v", rows[0])
+	}
+
+	// Filtered export, newest first: the last row is the 2024-03-01 session
+	category := "work"
+	buf.Reset()
+	if err := svc.ExportXLSX(&buf, &models.SessionFilter{Category: &category}, loc); err != nil {
+		t.Fatalf("failed to export XLSX: %v", err)
+	}
+	rows = readXLSXRows(t, buf.Bytes())
+	if len(rows) != 3 {
+		t.Fatalf("expected header and 2 data rows, got %d rows", len(rows))
+	}
+
+	cells := map[string]string{}
+	for _, c := range rows[2].Cells {
+		if c.Type == "inlineStr" {
+			cells[c.Ref] = c.Inline
+		} else {
+			cells[c.Ref] = c.Value
+		}
+	}
+	// 2024-03-01 18:00 in UTC+8 is serial 45352.75
+	expected := map[string]string{"A3": "1", "C3": "coding", "G3": "45352.75", "I3": "1.5"}
+	for ref, want := range expected {
+		if cells[ref] != want {
+			t.Errorf("cell %s = %q, expected %q", ref, cells[ref], want)
+		}
+	}
+}
+
+// parseICSEvents validates the framing of an iCalendar feed and returns the
+// unfolded, unescaped properties of each VEVENT.
+func parseICSEvents(t *testing.T, data string) []map[string]string {
+	t.Helper()
+
+	if !strings.HasSuffix(data, "\r\n") {
+		t.Fatal("feed must end with CRLF")
+	}
+	physical := strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n")
+	var lines []string
+	for _, line := range physical {
+		if len(line) > 75 {
+			t.Fatalf("line exceeds 75 octets: %q", line)
+		}
+		if strings.ContainsAny(line, "\r\n") {
+			t.Fatalf("bare line break in %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			if len(lines) == 0 {
+				t.Fatal("continuation line without a preceding line")
+			}
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Fatalf("feed is not wrapped in VCALENDAR: %q ... %q", lines[0], lines[len(lines)-1])
+	}
+
+	unescape := strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n")
+	var events []map[string]string
+	var current map[string]string
+	for _, line := range lines[1 : len(lines)-1] {
+		switch line {
+		case "BEGIN:VEVENT":
+			if current != nil {
+				t.Fatal("nested VEVENT")
+			}
+			current = map[string]string{}
+			continue
+		case "END:VEVENT":
+			if current == nil {
+				t.Fatal("END:VEVENT without BEGIN")
+			}
+			for _, required := range []string{"UID", "DTSTAMP", "DTSTART", "DTEND", "SUMMARY"} {
+				if current[required] == "" {
+					t.Fatalf("VEVENT missing %s: %v", required, current)
+				}
+			}
+			events = append(events, current)
+			current = nil
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			t.Fatalf("malformed content line %q", line)
+		}
+		if current != nil {
+			current[name] = unescape.Replace(value)
+		}
+	}
+	if current != nil {
+		t.Fatal("unterminated VEVENT")
+	}
+	return events
+}
+
+func TestSessionService_ExportICS(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	longTask := strings.Repeat("整理会议记录", 10)
+	if _, err := db.Exec(
+		`INSERT INTO sessions (category, task, note, started_at, ended_at, duration_sec, status) VALUES ('work', ?, ?, '2024-03-01T10:00:00Z', '2024-03-01T11:30:00Z', 5400, 'stopped')`,
+		longTask, "done; next, review\nfollow up",
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('study', 'reading', '2024-03-02T09:00:00Z', 'running')`,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewSessionService(repository.NewSessionRepository(db))
+	now := time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := svc.ExportICS(&buf, nil, false, now); err != nil {
+		t.Fatalf("failed to export ICS: %v", err)
+	}
+	events := parseICSEvents(t, buf.String())
+	if len(events) != 1 {
+		t.Fatalf("expected only the stopped session, got %d events", len(events))
+	}
+	event := events[0]
+	expected := map[string]string{
+		"UID":         "session-1@time-tracker",
+		"DTSTART":     "20240301T100000Z",
+		"DTEND":       "20240301T113000Z",
+		"SUMMARY":     "work: " + longTask,
+		"DESCRIPTION": "done; next, review\nfollow up",
+	}
+	for name, want := range expected {
+		if event[name] != want {
+			t.Errorf("%s = %q, expected %q", name, event[name], want)
+		}
+	}
+
+	buf.Reset()
+	if err := svc.ExportICS(&buf, nil, true, now); err != nil {
+		t.Fatalf("failed to export ICS: %v", err)
+	}
+	events = parseICSEvents(t, buf.String())
+	if len(events) != 2 {
+		t.Fatalf("expected running session to be included, got %d events", len(events))
+	}
+	if events[0]["UID"] != "session-2@time-tracker" || events[0]["DTEND"] != "20240302T100000Z" {
+		t.Fatalf("expected running session to end now, got %v", events[0])
+	}
+
+	// Date range excludes the stopped session
+	from := "2024-03-02"
+	buf.Reset()
+	if err := svc.ExportICS(&buf, &models.SessionFilter{From: &from}, false, now); err != nil {
+		t.Fatalf("failed to export ICS: %v", err)
+	}
+	if events = parseICSEvents(t, buf.String()); len(events) != 0 {
+		t.Fatalf("expected no events in range, got %d", len(events))
+	}
 }
 
 // TestSessionService_FormatDuration tests duration formatting.
@@ -452,3 +1125,29 @@ func TestSessionService_FormatDuration(t *testing.T) {
 		t.Error("FormatDuration(nil) should return empty string")
 	}
 }
+
+func TestSessionService_Defaults(t *testing.T) {
+	svc := NewSessionService(repository.NewSessionRepository(database.NewForTesting(t)))
+	svc.SetDefaults(models.Defaults{Category: "misc", Task: "untitled"})
+
+	session, err := svc.StartSession(&models.SessionStart{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Category != "misc" || session.Task != "untitled" {
+		t.Errorf("got category %q, task %q; want the configured defaults", session.Category, session.Task)
+	}
+	if _, err := svc.StopSession(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty default keeps the current one
+	svc.SetDefaults(models.Defaults{Task: "unnamed"})
+	session, err = svc.StartSession(&models.SessionStart{Task: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Category != "misc" || session.Task != "unnamed" {
+		t.Errorf("got category %q, task %q; want misc, unnamed", session.Category, session.Task)
+	}
+}
@@ -13,7 +13,7 @@ func NewSessionRepository(db *database.DB) *repository.SessionRepository {
 }
 
 // NewSessionService keeps legacy wiring stable while sessions are being migrated.
-func NewSessionService(repo *repository.SessionRepository) *service.SessionService {
+func NewSessionService(repo repository.SessionRepositoryInterface) *service.SessionService {
 	return service.NewSessionService(repo)
 }
 
@@ -28,9 +28,26 @@ type SessionStop = models.SessionStop
 type SessionUpdate = models.SessionUpdate
 
 type CurrentSessionResponse = service.CurrentSessionResponse
+type SessionEvent = service.SessionEvent
+type Events = service.Events
+type FiredReminders = service.FiredReminders
+
+// ParseCSVColumns validates a comma-separated CSV column selection.
+var ParseCSVColumns = service.ParseCSVColumns
+
+// ParseCSVDelimiter resolves a CSV delimiter name to its separator.
+var ParseCSVDelimiter = service.ParseCSVDelimiter
+
+// ElapsedSince returns the whole seconds a session started at an RFC3339
+// time has run, never less than zero.
+var ElapsedSince = service.ElapsedSince
 
 // Re-export errors commonly referenced by handlers.
 var (
 	ErrSessionAlreadyRunning = service.ErrSessionAlreadyRunning
 	ErrNoRunningSession      = service.ErrNoRunningSession
+	ErrSessionNotFound       = service.ErrSessionNotFound
+	ErrMultipleRunning       = service.ErrMultipleRunning
+	ErrStopNotAllowed        = service.ErrStopNotAllowed
+	ErrInconsistentUpdate    = service.ErrInconsistentUpdate
 )
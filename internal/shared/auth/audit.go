@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// EventAuthFailed is recorded when a request presents credentials that are rejected.
+const EventAuthFailed = "auth_failed"
+
+// AuditRecorder records security-relevant events. Recording is best-effort
+// and must never fail the request being recorded.
+type AuditRecorder interface {
+	Record(r *http.Request, eventType string, details map[string]interface{})
+}
+
+type actorKey struct{}
+
+// actorSlot lets a middleware outside the auth middlewares learn the
+// identity they authenticated; see TrackActor.
+type actorSlot struct {
+	actor string
+}
+
+type actorSlotKey struct{}
+
+// withActor returns r carrying the identity that authenticated it and the
+// user it acts for.
+func withActor(r *http.Request, actor string, userID int64) *http.Request {
+	if slot, ok := r.Context().Value(actorSlotKey{}).(*actorSlot); ok {
+		slot.actor = actor
+	}
+	ctx := context.WithValue(r.Context(), actorKey{}, actor)
+	return r.WithContext(WithUserID(ctx, userID))
+}
+
+// TrackActor prepares r so that the identity authenticated further down the
+// handler chain can be read afterwards with the returned function, which
+// falls back to Actor(r) when nothing was authenticated.
+func TrackActor(r *http.Request) (*http.Request, func() string) {
+	slot := &actorSlot{}
+	r = r.WithContext(context.WithValue(r.Context(), actorSlotKey{}, slot))
+	return r, func() string {
+		if slot.actor != "" {
+			return slot.actor
+		}
+		return Actor(r)
+	}
+}
+
+// KeyActor identifies an API key by its first characters, never the whole key.
+func KeyActor(key string) string {
+	if len(key) > 4 {
+		key = key[:4]
+	}
+	return "key:" + key + "..."
+}
+
+// UserActor identifies a Basic Auth or web login user.
+func UserActor(user string) string {
+	return "user:" + user
+}
+
+// Actor returns who made the request: the identity that authenticated it or,
+// for a rejected request, the credential that was attempted. Returns "" when
+// the request carried no credentials.
+func Actor(r *http.Request) string {
+	if actor, ok := r.Context().Value(actorKey{}).(string); ok {
+		return actor
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return KeyActor(key)
+	}
+	authHeader := r.Header.Get("Authorization")
+	if token := BearerToken(authHeader); token != "" {
+		return KeyActor(token)
+	}
+	if strings.HasPrefix(authHeader, "Basic ") {
+		if user, _, ok := parseBasicAuth(authHeader); ok {
+			return UserActor(user)
+		}
+	}
+	return ""
+}
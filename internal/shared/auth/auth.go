@@ -6,6 +6,11 @@ import (
 	"encoding/base64"
 	"net/http"
 	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/errors"
 )
 
 // VerifyAPIKey performs constant-time comparison of API keys to prevent timing attacks.
@@ -17,35 +22,58 @@ func VerifyAPIKey(provided, expected string) bool {
 	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
 }
 
+// VerifyAPIKeys reports whether provided matches any of the configured keys.
+// Every candidate is compared in constant time and the loop never exits early,
+// so timing reveals neither which key matched nor how many keys exist.
+func VerifyAPIKeys(provided string, keys []string) bool {
+	matched := 0
+	for _, key := range keys {
+		if VerifyAPIKey(provided, key) {
+			matched = 1
+		}
+	}
+	return matched == 1
+}
+
 // VerifyBasicAuth validates Basic Auth credentials.
 // Returns true if the provided credentials match the expected username and password.
 func VerifyBasicAuth(authHeader, expectedUser, expectedPass string) bool {
-	if authHeader == "" || expectedUser == "" || expectedPass == "" {
-		return false
-	}
+	user, pass, ok := parseBasicAuth(authHeader)
+	return ok && VerifyCredentials(user, pass, expectedUser, expectedPass)
+}
+
+// VerifyBasicAuthHash validates Basic Auth credentials against a bcrypt
+// password hash instead of a plaintext password.
+func VerifyBasicAuthHash(authHeader, expectedUser, passHash string) bool {
+	user, pass, ok := parseBasicAuth(authHeader)
+	return ok && VerifyCredentialsHash(user, pass, expectedUser, passHash)
+}
 
-	// Parse "Basic <base64-encoded-credentials>"
+// parseBasicAuth extracts the username and password from a
+// "Basic <base64-encoded-credentials>" header.
+func parseBasicAuth(authHeader string) (user, pass string, ok bool) {
 	const prefix = "Basic "
 	if !strings.HasPrefix(authHeader, prefix) {
-		return false
+		return "", "", false
 	}
 
 	encoded := strings.TrimPrefix(authHeader, prefix)
 	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return false
+		return "", "", false
 	}
 
 	// Split into username:password
-	credentials := string(decoded)
-	colonIdx := strings.Index(credentials, ":")
-	if colonIdx < 0 {
+	return strings.Cut(string(decoded), ":")
+}
+
+// VerifyCredentials validates a username and password, e.g. from a login form.
+// Returns false if no credentials are configured.
+func VerifyCredentials(providedUser, providedPass, expectedUser, expectedPass string) bool {
+	if expectedUser == "" || expectedPass == "" {
 		return false
 	}
 
-	providedUser := credentials[:colonIdx]
-	providedPass := credentials[colonIdx+1:]
-
 	// Use constant-time comparison for both username and password
 	userMatch := subtle.ConstantTimeCompare([]byte(providedUser), []byte(expectedUser)) == 1
 	passMatch := subtle.ConstantTimeCompare([]byte(providedPass), []byte(expectedPass)) == 1
@@ -53,49 +81,86 @@ func VerifyBasicAuth(authHeader, expectedUser, expectedPass string) bool {
 	return userMatch && passMatch
 }
 
-// APIKeyMiddleware creates an HTTP middleware that validates X-API-Key header.
-// It also allows Basic Auth if configured, to support web interface calls to API.
-func APIKeyMiddleware(expectedKey string, basicUser, basicPass string) func(http.Handler) http.Handler {
+// VerifyCredentialsHash validates a username and password against a bcrypt
+// password hash. The username is still compared in constant time, and the
+// hash is checked even when the username is wrong so timing does not reveal it.
+func VerifyCredentialsHash(providedUser, providedPass, expectedUser, passHash string) bool {
+	if expectedUser == "" || passHash == "" {
+		return false
+	}
+
+	userMatch := subtle.ConstantTimeCompare([]byte(providedUser), []byte(expectedUser)) == 1
+	passMatch := bcrypt.CompareHashAndPassword([]byte(passHash), []byte(providedPass)) == nil
+
+	return userMatch && passMatch
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header.
+// The scheme name is case-insensitive. Returns "" for any other header.
+func BearerToken(authHeader string) string {
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// APIKeyMiddleware creates an HTTP middleware that validates the API key sent
+// in the X-API-Key header or as "Authorization: Bearer <key>". When both are
+// present X-API-Key is used. It also allows Basic Auth if configured, to
+// support web interface calls to API.
+func APIKeyMiddleware(expectedKeys []string, basicUser, basicPass string) func(http.Handler) http.Handler {
+	return NewCredentialStore(Credentials{APIKeys: expectedKeys, BasicUser: basicUser, BasicPass: basicPass}).APIKeyMiddleware()
+}
+
+// AdminKeyMiddleware creates an HTTP middleware that guards the admin
+// endpoints, which reach every user's data. With adminKey set the
+// X-Admin-Key header must match it; without one only the default user may
+// pass. Requests let through are marked with WithAdmin.
+func AdminKeyMiddleware(adminKey string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// First check API Key
-			apiKey := r.Header.Get("X-API-Key")
-			if apiKey != "" && VerifyAPIKey(apiKey, expectedKey) {
-				next.ServeHTTP(w, r)
+			if err := checkAdmin(r, adminKey); err != nil {
+				errors.WriteError(w, err)
 				return
 			}
-
-			// If API Key is missing or invalid, check Basic Auth if configured
-			if basicUser != "" && basicPass != "" {
-				authHeader := r.Header.Get("Authorization")
-				if VerifyBasicAuth(authHeader, basicUser, basicPass) {
-					next.ServeHTTP(w, r)
-					return
-				}
-			}
-
-			// Neither valid, return unauthorized
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error":{"code":"UNAUTHORIZED","message":"Invalid or missing API key"}}`))
+			next.ServeHTTP(w, r.WithContext(WithAdmin(r.Context())))
 		})
 	}
 }
 
-// BasicAuthMiddleware creates an HTTP middleware that validates Basic Auth credentials.
-// Returns 401 Unauthorized with WWW-Authenticate header if credentials are missing or invalid.
+// checkAdmin returns the error to refuse r with if it may not use the admin
+// endpoints, or nil if it may.
+func checkAdmin(r *http.Request, adminKey string) error {
+	if adminKey != "" {
+		if !VerifyAPIKey(r.Header.Get("X-Admin-Key"), adminKey) {
+			return errors.UnauthorizedError("Invalid or missing admin key")
+		}
+		return nil
+	}
+	if userID, ok := UserID(r.Context()); !ok || userID != database.DefaultUserID {
+		return errors.ForbiddenError("Admin endpoints are limited to the default user while no admin key is configured")
+	}
+	return nil
+}
+
+// BasicAuthMiddleware creates an HTTP middleware that validates Basic Auth credentials,
+// acting for the default user. Returns 401 Unauthorized with WWW-Authenticate header if credentials are missing or invalid.
 func BasicAuthMiddleware(expectedUser, expectedPass string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if !VerifyBasicAuth(authHeader, expectedUser, expectedPass) {
-				w.Header().Set("WWW-Authenticate", `Basic realm="Time Tracker"`)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(`{"error":{"code":"UNAUTHORIZED","message":"Invalid or missing credentials"}}`))
+				writeBasicAuthChallenge(w)
 				return
 			}
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), database.DefaultUserID)))
 		})
 	}
 }
+
+// writeBasicAuthChallenge responds 401 with a WWW-Authenticate header.
+func writeBasicAuthChallenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="Time Tracker"`)
+	errors.WriteError(w, errors.UnauthorizedError("Invalid or missing credentials"))
+}
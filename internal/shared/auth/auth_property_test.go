@@ -20,7 +20,7 @@ func TestAPIKeyAuth_Property10_MissingKey(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		// Generate a random valid API key (at least 32 chars)
 		expectedKey := rapid.StringMatching(`[a-zA-Z0-9]{32,64}`).Draw(t, "expectedKey")
-		middleware := APIKeyMiddleware(expectedKey, "", "")
+		middleware := APIKeyMiddleware([]string{expectedKey}, "", "")
 
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -50,7 +50,7 @@ func TestAPIKeyAuth_Property10_InvalidKey(t *testing.T) {
 			return
 		}
 
-		middleware := APIKeyMiddleware(expectedKey, "", "")
+		middleware := APIKeyMiddleware([]string{expectedKey}, "", "")
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		})
@@ -72,7 +72,7 @@ func TestAPIKeyAuth_Property10_ValidKey(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		// Generate a random valid API key
 		apiKey := rapid.StringMatching(`[a-zA-Z0-9]{32,64}`).Draw(t, "apiKey")
-		middleware := APIKeyMiddleware(apiKey, "", "")
+		middleware := APIKeyMiddleware([]string{apiKey}, "", "")
 
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -91,6 +91,50 @@ func TestAPIKeyAuth_Property10_ValidKey(t *testing.T) {
 	})
 }
 
+// Feature: time-tracker, Property 10: 多个 API Key
+// *For any* 配置的 API Key 集合，其中任意一个 Key 都能通过认证，
+// 集合之外的任意字符串都返回 401
+
+func TestAPIKeyAuth_Property10_AnyConfiguredKey(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		keys := rapid.SliceOfNDistinct(rapid.StringMatching(`[a-zA-Z0-9]{32,64}`), 1, 5, rapid.ID[string]).Draw(t, "keys")
+		provided := rapid.SampledFrom(keys).Draw(t, "provided")
+
+		middleware := APIKeyMiddleware(keys, "", "")
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("X-API-Key", provided)
+		rr := httptest.NewRecorder()
+
+		middleware(handler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("configured API key should return 200, got %d", rr.Code)
+		}
+	})
+}
+
+func TestAPIKeyAuth_Property10_UnconfiguredKey(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		keys := rapid.SliceOfNDistinct(rapid.StringMatching(`[a-zA-Z0-9]{32,64}`), 1, 5, rapid.ID[string]).Draw(t, "keys")
+		provided := rapid.String().Draw(t, "provided")
+
+		// Skip if the string happens to be configured
+		for _, key := range keys {
+			if provided == key {
+				return
+			}
+		}
+
+		if VerifyAPIKeys(provided, keys) {
+			t.Fatalf("unconfigured key %q should not verify", provided)
+		}
+	})
+}
+
 // Feature: time-tracker, Property 10: Basic Auth 认证正确性 (part of Property 10)
 // **Validates: Requirements 4.11**
 
@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"context"
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -64,9 +66,57 @@ func TestVerifyBasicAuth(t *testing.T) {
 	}
 }
 
+// secret123Hash is a bcrypt hash of "secret123".
+const secret123Hash = "$2b$04$N9qo8uLOickgx2ZMRZoMyejk78QHndYRSfXgQ03pFoR4FPDPXsrBm"
+
+func TestVerifyBasicAuthHash(t *testing.T) {
+	header := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		passHash   string
+		want       bool
+	}{
+		{"correct password", header("admin", "secret123"), secret123Hash, true},
+		{"wrong password", header("admin", "secret124"), secret123Hash, false},
+		{"wrong username", header("root", "secret123"), secret123Hash, false},
+		{"plaintext is not a hash", header("admin", "secret123"), "secret123", false},
+		{"no hash configured", header("admin", "secret123"), "", false},
+		{"missing header", "", secret123Hash, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyBasicAuthHash(tt.authHeader, "admin", tt.passHash); got != tt.want {
+				t.Errorf("VerifyBasicAuthHash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialStore_BasicPassHash(t *testing.T) {
+	store := NewCredentialStore(Credentials{BasicUser: "admin", BasicPassHash: secret123Hash})
+	handler := store.BasicAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for pass, want := range map[string]int{"secret123": http.StatusOK, "wrong": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/web/sessions", nil)
+		req.SetBasicAuth("admin", pass)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("password %q: expected status %d, got %d", pass, want, rr.Code)
+		}
+	}
+}
+
 func TestAPIKeyMiddleware(t *testing.T) {
 	expectedKey := "test-api-key-32-chars-minimum!!"
-	middleware := APIKeyMiddleware(expectedKey, "", "")
+	middleware := APIKeyMiddleware([]string{expectedKey}, "", "")
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -109,6 +159,51 @@ func TestAPIKeyMiddleware(t *testing.T) {
 	})
 }
 
+func TestAPIKeyMiddleware_Bearer(t *testing.T) {
+	expectedKey := "test-api-key-32-chars-minimum!!"
+	middleware := APIKeyMiddleware([]string{expectedKey}, "", "")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+	})
+
+	tests := []struct {
+		name   string
+		apiKey string
+		auth   string
+		want   int
+	}{
+		{"valid bearer", "", "Bearer " + expectedKey, http.StatusOK},
+		{"lowercase scheme", "", "bearer " + expectedKey, http.StatusOK},
+		{"invalid bearer", "", "Bearer wrong-key", http.StatusUnauthorized},
+		{"empty bearer", "", "Bearer ", http.StatusUnauthorized},
+		{"key without scheme", "", expectedKey, http.StatusUnauthorized},
+		{"X-API-Key wins over invalid bearer", expectedKey, "Bearer wrong-key", http.StatusOK},
+		{"invalid X-API-Key wins over valid bearer", "wrong-key", "Bearer " + expectedKey, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			req.Header.Set("Authorization", tt.auth)
+			rr := httptest.NewRecorder()
+
+			middleware(handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rr.Code)
+			}
+			if rr.Code == http.StatusUnauthorized && !strings.Contains(rr.Body.String(), "Bearer") {
+				t.Errorf("expected 401 message to mention both schemes, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestBasicAuthMiddleware(t *testing.T) {
 	expectedUser := "admin"
 	expectedPass := "secret123"
@@ -160,3 +255,69 @@ func TestBasicAuthMiddleware(t *testing.T) {
 		}
 	})
 }
+
+func TestAdminKeyMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdmin(r.Context()) {
+			t.Error("admin request not marked as admin")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("no admin key configured", func(t *testing.T) {
+		// Only the default user gets in, and nobody without a user
+		for _, tt := range []struct {
+			name string
+			ctx  context.Context
+			want int
+		}{
+			{"default user", WithUserID(context.Background(), 1), http.StatusOK},
+			{"other user", WithUserID(context.Background(), 2), http.StatusForbidden},
+			{"no user", context.Background(), http.StatusForbidden},
+		} {
+			req := httptest.NewRequest("GET", "/api/v1/admin/backup", nil).WithContext(tt.ctx)
+			rr := httptest.NewRecorder()
+
+			AdminKeyMiddleware("")(handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+			}
+		}
+	})
+
+	t.Run("admin key for another user", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/admin/backup", nil).WithContext(WithUserID(context.Background(), 2))
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		rr := httptest.NewRecorder()
+
+		AdminKeyMiddleware("admin-secret")(handler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("valid admin key", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/admin/backup", nil)
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		rr := httptest.NewRecorder()
+
+		AdminKeyMiddleware("admin-secret")(handler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("missing admin key", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/admin/backup", nil)
+		rr := httptest.NewRecorder()
+
+		AdminKeyMiddleware("admin-secret")(handler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
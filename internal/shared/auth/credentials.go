@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/errors"
+)
+
+// Credentials are the secrets checked by the auth middlewares.
+type Credentials struct {
+	APIKeys   []string
+	BasicUser string
+	BasicPass string
+	// BasicPassHash is a bcrypt hash used instead of BasicPass when set
+	BasicPassHash string
+	AdminKey      string
+}
+
+// BasicAuthEnabled reports whether Basic Auth credentials are configured.
+func (c *Credentials) BasicAuthEnabled() bool {
+	return c.BasicUser != "" && (c.BasicPass != "" || c.BasicPassHash != "")
+}
+
+// VerifyPassword validates a username and password against the configured
+// web credentials, using the bcrypt hash when one is set.
+func (c *Credentials) VerifyPassword(user, pass string) bool {
+	if c.BasicPassHash != "" {
+		return VerifyCredentialsHash(user, pass, c.BasicUser, c.BasicPassHash)
+	}
+	return VerifyCredentials(user, pass, c.BasicUser, c.BasicPass)
+}
+
+// VerifyBasicAuth validates a Basic Auth header against the configured web credentials.
+func (c *Credentials) VerifyBasicAuth(authHeader string) bool {
+	user, pass, ok := parseBasicAuth(authHeader)
+	return ok && c.VerifyPassword(user, pass)
+}
+
+// API key scopes. Read-only keys may only make GET and HEAD requests.
+const (
+	ScopeFull = "full"
+	ScopeRead = "read"
+)
+
+// KeyLookup resolves API keys that are managed at runtime rather than
+// configured in the environment, returning the key's scope and the user it
+// belongs to.
+type KeyLookup interface {
+	LookupAPIKey(key string) (scope string, userID int64, ok bool)
+}
+
+// CredentialStore holds the current credentials and lets them be replaced
+// while the server is running. Middlewares created from a store read the
+// credentials on every request, so a Store takes effect immediately.
+type CredentialStore struct {
+	current  atomic.Pointer[Credentials]
+	verified *verifyCache
+	keys     KeyLookup
+	users    UserLookup
+	audit    AuditRecorder
+}
+
+// NewCredentialStore creates a CredentialStore holding creds.
+func NewCredentialStore(creds Credentials) *CredentialStore {
+	s := &CredentialStore{verified: newVerifyCache(config.BasicAuthCacheTTL)}
+	s.Store(creds)
+	return s
+}
+
+// Load returns the current credentials. The result must not be modified.
+func (s *CredentialStore) Load() *Credentials {
+	return s.current.Load()
+}
+
+// Store atomically replaces the current credentials. Passwords verified
+// under the old ones are checked afresh.
+func (s *CredentialStore) Store(creds Credentials) {
+	s.current.Store(&creds)
+	s.verified.clear()
+}
+
+// SetKeyLookup makes APIKeyMiddleware also accept keys known to lookup.
+// The configured API keys are always checked first, so they keep working
+// whatever the lookup contains. Must be called before serving requests.
+func (s *CredentialStore) SetKeyLookup(lookup KeyLookup) {
+	s.keys = lookup
+}
+
+// SetUserLookup makes the middlewares and Authenticate also accept the
+// passwords of users known to lookup. The configured Basic Auth user is
+// always checked first and acts as the default user. Must be called before
+// serving requests.
+func (s *CredentialStore) SetUserLookup(lookup UserLookup) {
+	s.users = lookup
+}
+
+// AuthRequired reports whether requests must sign in: web credentials are
+// configured, or a user managed at runtime has a password.
+func (s *CredentialStore) AuthRequired() bool {
+	return s.Load().BasicAuthEnabled() || (s.users != nil && s.users.HasUsers())
+}
+
+// Authenticate checks a username and password against the configured web
+// credentials and then the user lookup, returning the user they sign in as.
+// Passwords are bcrypt hashes, slow to check by design, so a success is
+// remembered for config.BasicAuthCacheTTL; failures are always checked.
+func (s *CredentialStore) Authenticate(user, pass string) (int64, bool) {
+	userID, gen, ok := s.verified.get(user, pass)
+	if ok {
+		return userID, true
+	}
+	if userID, ok = s.authenticate(user, pass); ok {
+		s.verified.put(gen, user, pass, userID)
+	}
+	return userID, ok
+}
+
+// authenticate is Authenticate without the cache.
+func (s *CredentialStore) authenticate(user, pass string) (int64, bool) {
+	if creds := s.Load(); creds.BasicAuthEnabled() && creds.VerifyPassword(user, pass) {
+		return database.DefaultUserID, true
+	}
+	if s.users != nil && user != "" && pass != "" {
+		return s.users.VerifyUser(user, pass)
+	}
+	return 0, false
+}
+
+// authenticateBasic is Authenticate for an Authorization header, also
+// returning the username.
+func (s *CredentialStore) authenticateBasic(authHeader string) (string, int64, bool) {
+	user, pass, ok := parseBasicAuth(authHeader)
+	if !ok {
+		return "", 0, false
+	}
+	userID, ok := s.Authenticate(user, pass)
+	return user, userID, ok
+}
+
+// SetAuditRecorder makes the middlewares record rejected credentials.
+// Must be called before serving requests.
+func (s *CredentialStore) SetAuditRecorder(rec AuditRecorder) {
+	s.audit = rec
+}
+
+// recordAuthFailure records a rejected credential, if auditing is enabled.
+func (s *CredentialStore) recordAuthFailure(r *http.Request, reason string) {
+	if s.audit != nil {
+		s.audit.Record(r, EventAuthFailed, map[string]interface{}{"reason": reason, "path": r.URL.Path})
+	}
+}
+
+// APIKeyMiddleware is like the package-level APIKeyMiddleware but checks the
+// store's current API keys, the key lookup if set, and Basic Auth credentials,
+// and makes the request act for the user they belong to. Configured keys
+// belong to the default user.
+func (s *CredentialStore) APIKeyMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			creds := s.Load()
+			authHeader := r.Header.Get("Authorization")
+
+			// First check API Key, falling back to a bearer token
+			apiKey := r.Header.Get("X-API-Key")
+			if apiKey == "" {
+				apiKey = BearerToken(authHeader)
+			}
+			if apiKey != "" && VerifyAPIKeys(apiKey, creds.APIKeys) {
+				next.ServeHTTP(w, withActor(r, KeyActor(apiKey), database.DefaultUserID))
+				return
+			}
+			if apiKey != "" && s.keys != nil {
+				if scope, userID, ok := s.keys.LookupAPIKey(apiKey); ok {
+					if scope == ScopeRead && r.Method != http.MethodGet && r.Method != http.MethodHead {
+						errors.WriteError(w, errors.ForbiddenError("API key is read-only"))
+						return
+					}
+					next.ServeHTTP(w, withActor(r, KeyActor(apiKey), userID))
+					return
+				}
+			}
+
+			// If API Key is missing or invalid, check Basic Auth
+			if user, userID, ok := s.authenticateBasic(authHeader); ok {
+				next.ServeHTTP(w, withActor(r, UserActor(user), userID))
+				return
+			}
+
+			// Neither valid, return unauthorized
+			if apiKey != "" {
+				s.recordAuthFailure(r, "invalid_api_key")
+			} else if strings.HasPrefix(authHeader, "Basic ") {
+				s.recordAuthFailure(r, "invalid_basic_auth")
+			}
+			errors.WriteError(w, errors.UnauthorizedError("Invalid or missing API key: send X-API-Key or Authorization: Bearer <key>"))
+		})
+	}
+}
+
+// BasicAuthMiddleware requires the store's current Basic Auth credentials or
+// those of a user. Requests pass through unchecked, acting for the default
+// user, only while AuthRequired is false.
+func (s *CredentialStore) BasicAuthMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.AuthRequired() {
+				next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), database.DefaultUserID)))
+				return
+			}
+			authHeader := r.Header.Get("Authorization")
+			user, userID, ok := s.authenticateBasic(authHeader)
+			if !ok {
+				if authHeader != "" {
+					s.recordAuthFailure(r, "invalid_basic_auth")
+				}
+				writeBasicAuthChallenge(w)
+				return
+			}
+			next.ServeHTTP(w, withActor(r, UserActor(user), userID))
+		})
+	}
+}
+
+// AdminKeyMiddleware is like the package-level AdminKeyMiddleware but checks
+// the store's current admin key, recording refused requests.
+func (s *CredentialStore) AdminKeyMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			adminKey := s.Load().AdminKey
+			if err := checkAdmin(r, adminKey); err != nil {
+				if adminKey != "" {
+					s.recordAuthFailure(r, "invalid_admin_key")
+				} else {
+					s.recordAuthFailure(r, "not_default_user")
+				}
+				errors.WriteError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithAdmin(r.Context())))
+		})
+	}
+}
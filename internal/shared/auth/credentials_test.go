@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"time-tracker/internal/shared/config"
+)
+
+func TestCredentialStore_SwapAPIKey(t *testing.T) {
+	oldKey := "old-api-key-32-chars-minimum!!!!"
+	newKey := "new-api-key-32-chars-minimum!!!!"
+	store := NewCredentialStore(Credentials{APIKeys: []string{oldKey}})
+
+	handler := store.APIKeyMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(key string) int {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := request(oldKey); code != http.StatusOK {
+		t.Fatalf("expected old key to pass before swap, got %d", code)
+	}
+
+	store.Store(Credentials{APIKeys: []string{newKey}})
+
+	if code := request(oldKey); code != http.StatusUnauthorized {
+		t.Errorf("expected old key to return 401 after swap, got %d", code)
+	}
+	if code := request(newKey); code != http.StatusOK {
+		t.Errorf("expected new key to return 200 after swap, got %d", code)
+	}
+}
+
+func TestCredentialStore_BasicAuth(t *testing.T) {
+	store := NewCredentialStore(Credentials{})
+	handler := store.BasicAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(user, pass string) int {
+		req := httptest.NewRequest("GET", "/web/sessions", nil)
+		if user != "" {
+			req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Without credentials the web interface is open
+	if code := request("", ""); code != http.StatusOK {
+		t.Fatalf("expected 200 without configured credentials, got %d", code)
+	}
+
+	store.Store(Credentials{BasicUser: "admin", BasicPass: "secret123"})
+
+	if code := request("", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after enabling Basic Auth, got %d", code)
+	}
+	if code := request("admin", "secret123"); code != http.StatusOK {
+		t.Errorf("expected 200 with new credentials, got %d", code)
+	}
+}
+
+type mapKeyLookup map[string]string
+
+func (m mapKeyLookup) LookupAPIKey(key string) (string, int64, bool) {
+	scope, ok := m[key]
+	return scope, 2, ok
+}
+
+func TestCredentialStore_KeyLookup(t *testing.T) {
+	envKey := "env-api-key-32-chars-minimum!!!!"
+	store := NewCredentialStore(Credentials{APIKeys: []string{envKey}})
+	store.SetKeyLookup(mapKeyLookup{"tt_full": ScopeFull, "tt_read": ScopeRead})
+
+	var userID int64
+	handler := store.APIKeyMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = UserID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method string
+		key    string
+		want   int
+		user   int64
+	}{
+		{"POST", envKey, http.StatusOK, 1},
+		{"POST", "tt_full", http.StatusOK, 2},
+		{"GET", "tt_read", http.StatusOK, 2},
+		{"HEAD", "tt_read", http.StatusOK, 2},
+		{"POST", "tt_read", http.StatusForbidden, 0},
+		{"DELETE", "tt_read", http.StatusForbidden, 0},
+		{"GET", "tt_unknown", http.StatusUnauthorized, 0},
+	}
+
+	for _, tt := range tests {
+		userID = 0
+		req := httptest.NewRequest(tt.method, "/api/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s with %s: expected %d, got %d", tt.method, tt.key, tt.want, rr.Code)
+		}
+		if userID != tt.user {
+			t.Errorf("%s with %s: acted for user %d, want %d", tt.method, tt.key, userID, tt.user)
+		}
+	}
+}
+
+type mapUserLookup map[string]string
+
+func (m mapUserLookup) VerifyUser(name, pass string) (int64, bool) {
+	if want, ok := m[name]; ok && want == pass {
+		return 2, true
+	}
+	return 0, false
+}
+
+func (m mapUserLookup) HasUsers() bool {
+	return len(m) > 0
+}
+
+func TestCredentialStore_UserLookup(t *testing.T) {
+	store := NewCredentialStore(Credentials{BasicUser: "admin", BasicPass: "secret123"})
+	store.SetUserLookup(mapUserLookup{"partner": "hunter22"})
+
+	var userID int64
+	handler := store.BasicAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = UserID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		user, pass string
+		want       int
+		userID     int64
+	}{
+		{"admin", "secret123", http.StatusOK, 1},
+		{"partner", "hunter22", http.StatusOK, 2},
+		{"partner", "wrong", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		userID = 0
+		req := httptest.NewRequest("GET", "/web/sessions", nil)
+		req.SetBasicAuth(tt.user, tt.pass)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want || userID != tt.userID {
+			t.Errorf("%s:%s = %d for user %d, want %d for user %d", tt.user, tt.pass, rr.Code, userID, tt.want, tt.userID)
+		}
+	}
+
+	// Nothing authenticated acts for no one, not the default user
+	if id, ok := UserID(context.Background()); ok {
+		t.Errorf("UserID without a user = %d, want none", id)
+	}
+	if _, err := RequireUserID(context.Background()); err != ErrNoUser {
+		t.Errorf("RequireUserID without a user: %v, want ErrNoUser", err)
+	}
+
+	// Without credentials configured requests act for the default user
+	open := NewCredentialStore(Credentials{}).BasicAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = UserID(r.Context())
+	}))
+	userID = 0
+	open.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/web/sessions", nil))
+	if userID != 1 {
+		t.Errorf("open server acted for user %d, want the default user", userID)
+	}
+
+	// A user with a password closes it even without configured credentials
+	usersOnly := NewCredentialStore(Credentials{})
+	usersOnly.SetUserLookup(mapUserLookup{"partner": "hunter22"})
+	closed := usersOnly.BasicAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = UserID(r.Context())
+	}))
+	userID = 0
+	rr := httptest.NewRecorder()
+	closed.ServeHTTP(rr, httptest.NewRequest("GET", "/web/sessions", nil))
+	if rr.Code != http.StatusUnauthorized || userID != 0 {
+		t.Errorf("users-only server without credentials = %d for user %d, want 401", rr.Code, userID)
+	}
+	req := httptest.NewRequest("GET", "/web/sessions", nil)
+	req.SetBasicAuth("partner", "hunter22")
+	rr = httptest.NewRecorder()
+	closed.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || userID != 2 {
+		t.Errorf("users-only server as partner = %d for user %d, want 200 for user 2", rr.Code, userID)
+	}
+}
+
+// countingLookup is a mapUserLookup that counts the password checks it makes.
+type countingLookup struct {
+	mapUserLookup
+	checks int
+}
+
+func (c *countingLookup) VerifyUser(name, pass string) (int64, bool) {
+	c.checks++
+	return c.mapUserLookup.VerifyUser(name, pass)
+}
+
+func TestCredentialStore_AuthenticateCache(t *testing.T) {
+	store := NewCredentialStore(Credentials{BasicUser: "admin", BasicPass: "secret123"})
+	lookup := &countingLookup{mapUserLookup: mapUserLookup{"partner": "hunter22"}}
+	store.SetUserLookup(lookup)
+	now := time.Now()
+	store.verified.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if id, ok := store.Authenticate("partner", "hunter22"); !ok || id != 2 {
+			t.Fatalf("Authenticate partner = %d, %v", id, ok)
+		}
+	}
+	if lookup.checks != 1 {
+		t.Errorf("three sign-ins checked the password %d times, want once", lookup.checks)
+	}
+
+	// Failures are never remembered
+	for i := 0; i < 2; i++ {
+		if _, ok := store.Authenticate("partner", "wrong"); ok {
+			t.Fatal("expected a wrong password to fail")
+		}
+	}
+	if lookup.checks != 3 {
+		t.Errorf("after two failures the password was checked %d times, want 3", lookup.checks)
+	}
+
+	now = now.Add(config.BasicAuthCacheTTL + time.Second)
+	if _, ok := store.Authenticate("partner", "hunter22"); !ok || lookup.checks != 4 {
+		t.Errorf("an expired success was not checked again (%d checks)", lookup.checks)
+	}
+
+	// Replacing the credentials forgets passwords checked against the old ones
+	if _, ok := store.Authenticate("admin", "secret123"); !ok {
+		t.Fatal("expected the configured credentials to work")
+	}
+	store.Store(Credentials{BasicUser: "admin", BasicPass: "newsecret"})
+	if _, ok := store.Authenticate("admin", "secret123"); ok {
+		t.Error("the old password still works after the credentials changed")
+	}
+
+	// A check that began before the change is not cached
+	_, gen, _ := store.verified.get("admin", "secret123")
+	store.Store(Credentials{BasicUser: "admin", BasicPass: "third-secret"})
+	store.verified.put(gen, "admin", "secret123", 1)
+	if _, _, ok := store.verified.get("admin", "secret123"); ok {
+		t.Error("a check from before the change was cached")
+	}
+}
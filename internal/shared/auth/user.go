@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	stderrors "errors"
+
+	"time-tracker/internal/shared/database"
+)
+
+// ErrNoUser is returned by RequireUserID for a context that acts for no
+// user, such as a request that reached a handler without passing an auth
+// middleware.
+var ErrNoUser = stderrors.New("no authenticated user")
+
+// UserLookup verifies the passwords of users managed at runtime, returning
+// the user's ID. HasUsers reports whether any of them has a password, and so
+// whether requests must sign in even without configured credentials.
+type UserLookup interface {
+	VerifyUser(name, pass string) (userID int64, ok bool)
+	HasUsers() bool
+}
+
+type userIDKey struct{}
+
+type isAdminKey struct{}
+
+// WithUserID returns ctx acting for the user with the given ID.
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// DefaultUserContext returns a background context acting for the default
+// user, for work done outside any request, such as the CLI commands and the
+// scheduled export.
+func DefaultUserContext() context.Context {
+	return WithUserID(context.Background(), database.DefaultUserID)
+}
+
+// UserID returns the user ctx acts for, as set by the auth middlewares or
+// WithUserID, and whether there is one.
+func UserID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(userIDKey{}).(int64)
+	return id, ok && id > 0
+}
+
+// RequireUserID is like UserID but returns ErrNoUser when ctx acts for no
+// user, so data is never read or written for a user nobody authenticated.
+func RequireUserID(ctx context.Context) (int64, error) {
+	id, ok := UserID(ctx)
+	if !ok {
+		return 0, ErrNoUser
+	}
+	return id, nil
+}
+
+// WithAdmin returns ctx marked as allowed to use the admin endpoints, as
+// AdminKeyMiddleware marks the requests it lets through.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, isAdminKey{}, true)
+}
+
+// IsAdmin reports whether ctx was marked by WithAdmin.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(isAdminKey{}).(bool)
+	return admin
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// maxVerifiedEntries bounds the verification cache. Reaching it empties the
+// cache, which only costs the next request of each client a bcrypt check.
+const maxVerifiedEntries = 1024
+
+// verifyCache remembers successful password checks for a short while, so a
+// client that sends Basic Auth with every request does not pay for a bcrypt
+// comparison each time. Entries are keyed by an HMAC of the username and
+// password under a key generated at startup, so neither a password nor a
+// fast unsalted hash of one is kept in memory.
+type verifyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	secret  []byte
+	gen     uint64
+	entries map[[sha256.Size]byte]verifiedEntry
+}
+
+// verifiedEntry is who a username and password signed in as, and until when
+// that is trusted without checking again.
+type verifiedEntry struct {
+	userID  int64
+	expires time.Time
+}
+
+// newVerifyCache creates a cache whose entries last ttl.
+func newVerifyCache(ttl time.Duration) *verifyCache {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("auth: failed to generate cache key: " + err.Error())
+	}
+	return &verifyCache{
+		ttl:     ttl,
+		now:     time.Now,
+		secret:  secret,
+		entries: make(map[[sha256.Size]byte]verifiedEntry),
+	}
+}
+
+func (c *verifyCache) key(user, pass string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(user))
+	mac.Write([]byte{0})
+	mac.Write([]byte(pass))
+	var key [sha256.Size]byte
+	copy(key[:], mac.Sum(nil))
+	return key
+}
+
+// get returns the user a recent successful check of user and pass signed in
+// as, and the cache's generation for a put after checking them afresh.
+func (c *verifyCache) get(user, pass string) (userID int64, gen uint64, ok bool) {
+	key := c.key(user, pass)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && c.now().After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	return entry.userID, c.gen, ok
+}
+
+// put records a successful check made at generation gen. A check that
+// started before the last clear is dropped, since it may have used the
+// credentials that were replaced.
+func (c *verifyCache) put(gen uint64, user, pass string, userID int64) {
+	key := c.key(user, pass)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	now := c.now()
+	if len(c.entries) >= maxVerifiedEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxVerifiedEntries {
+			c.entries = make(map[[sha256.Size]byte]verifiedEntry)
+		}
+	}
+	c.entries[key] = verifiedEntry{userID: userID, expires: now.Add(c.ttl)}
+}
+
+// clear forgets every check, e.g. after the credentials change.
+func (c *verifyCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[[sha256.Size]byte]verifiedEntry)
+}
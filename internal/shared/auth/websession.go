@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/errors"
+)
+
+// SessionCookieName is the cookie that carries the web login session token.
+const SessionCookieName = "timelog_session"
+
+// WebSessionStore keeps web login sessions in memory. Sessions do not survive
+// a restart; users simply log in again.
+type WebSessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]webSession
+}
+
+// webSession is who logged in and until when.
+type webSession struct {
+	user    string
+	userID  int64
+	expires time.Time
+}
+
+// NewWebSessionStore creates a store whose sessions expire after ttl.
+func NewWebSessionStore(ttl time.Duration) *WebSessionStore {
+	return &WebSessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]webSession),
+	}
+}
+
+// Create starts a new session for the named user and returns its token and
+// expiry.
+func (s *WebSessionStore) Create(user string, userID int64) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	expires := now.Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired sessions so the map does not grow without bound
+	for t, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = webSession{user: user, userID: userID, expires: expires}
+	return token, expires, nil
+}
+
+// Valid reports whether token belongs to an unexpired session.
+func (s *WebSessionStore) Valid(token string) bool {
+	_, _, ok := s.Lookup(token)
+	return ok
+}
+
+// Lookup returns the user of the unexpired session with the given token.
+func (s *WebSessionStore) Lookup(token string) (user string, userID int64, ok bool) {
+	if token == "" {
+		return "", 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok {
+		return "", 0, false
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, token)
+		return "", 0, false
+	}
+	return sess.user, sess.userID, true
+}
+
+// Delete ends the session with the given token.
+func (s *WebSessionStore) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Clear ends all sessions, e.g. after the web credentials change.
+func (s *WebSessionStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]webSession)
+}
+
+// WebAuthMiddleware protects the web interface. A valid session cookie is
+// accepted first; otherwise it falls back to Basic Auth so existing clients
+// keep working. Unauthenticated page loads are redirected to /web/login
+// instead of triggering the browser's Basic Auth dialog.
+// Requests pass through unchecked, acting for the default user, only while
+// AuthRequired is false, and /web/login itself is always reachable.
+func (s *CredentialStore) WebAuthMiddleware(sessions *WebSessionStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.AuthRequired() {
+				next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), database.DefaultUserID)))
+				return
+			}
+			if r.URL.Path == "/web/login" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cookie, err := r.Cookie(SessionCookieName); err == nil {
+				if user, userID, ok := sessions.Lookup(cookie.Value); ok {
+					next.ServeHTTP(w, withActor(r, UserActor(user), userID))
+					return
+				}
+			}
+
+			// Clients that send Basic Auth get the Basic Auth behaviour
+			if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Basic ") {
+				user, userID, ok := s.authenticateBasic(authHeader)
+				if !ok {
+					s.recordAuthFailure(r, "invalid_basic_auth")
+					writeBasicAuthChallenge(w)
+					return
+				}
+				next.ServeHTTP(w, withActor(r, UserActor(user), userID))
+				return
+			}
+
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/web/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+
+			errors.WriteError(w, errors.UnauthorizedError("Login required"))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package buildinfo holds the version metadata of the running binary.
+//
+// The values are set at build time, e.g.
+//
+//	go build -ldflags "-X time-tracker/internal/shared/buildinfo.Version=v1.2.0 \
+//	  -X time-tracker/internal/shared/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X time-tracker/internal/shared/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X. Without them Version is "dev" and Commit and
+// BuildDate come from the VCS information Go embeds, when available.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+func init() {
+	if Commit != "" && BuildDate != "" {
+		return
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && Commit == "":
+			Commit = setting.Value
+		case setting.Key == "vcs.time" && BuildDate == "":
+			BuildDate = setting.Value
+		}
+	}
+}
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build metadata. Unknown values are reported as "unknown".
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
@@ -1,18 +1,82 @@
 package config
 
+import "time"
+
 // Constants for application-wide use
 const (
 	// Default Strings
 	DefaultCategory = "未分类"
 	DefaultTask     = "未命名任务"
 
-	// Pagination
-	DefaultPageSize = 10
-	MaxPageSize     = 10
+	// Pagination; TIMELOG_MAX_PAGE_SIZE may raise MaxPageSize up to MaxPageSizeLimit
+	DefaultPageSize  = 10
+	MaxPageSize      = 10
+	MaxPageSizeLimit = 1000
+	// MaxListPageSize bounds the session service; the web list offers up to it
+	MaxListPageSize = 100
+
+	// Readiness check
+	ReadinessTimeout = 2 * time.Second
+	// How long /readyz reuses a database integrity check result
+	IntegrityCheckInterval = time.Hour
+
+	// Web login sessions
+	WebSessionTTL = 7 * 24 * time.Hour
+	// How long a successful Basic Auth or login password check is reused
+	// before bcrypt runs again
+	BasicAuthCacheTTL = time.Minute
+
+	// API keys
+	MaxAPIKeyNameLength      = 100
+	APIKeyLastUsedFlushEvery = time.Minute
+
+	// Presets
+	MaxPresetNameLength = 50
+
+	// Users
+	MaxUserNameLength     = 50
+	MinUserPasswordLength = 8
+
+	// Audit log
+	MaxAuditPageSize = 100
+
+	// Scheduled export
+	DefaultAutoExportInterval = 24 * time.Hour
+	DefaultAutoExportRetain   = 7
 
-	// Export
-	MaxExportLimit = 10000
+	// Database maintenance
+	DefaultMaintenanceInterval = 24 * time.Hour
+
+	// Data retention
+	RetentionInterval = 24 * time.Hour
+
+	// Long-session reminders
+	DefaultReminderInterval = time.Minute
+	ReminderWebhookTimeout  = 10 * time.Second
+
+	// Scheduled backup
+	DefaultBackupInterval = 24 * time.Hour
+	DefaultBackupKeep     = 7
+
+	// Compression
+	GzipMinSize = 1024
+
+	// Session event streams
+	DefaultStreamHeartbeat = 15 * time.Second
+
+	// Request bodies
+	MaxJSONBodyBytes = 1 << 20
+	MaxImportBytes   = 10 << 20
+
+	// Tags
+	MaxBulkAssign  = 10000
+	MaxTagPageSize = 100
 
 	// Statistics
 	StatsDays = 7
+
+	// TimestampLayout is RFC3339 in UTC with exactly three fractional
+	// digits, the form session timestamps are stored and compared in. The
+	// fixed width keeps text order the same as time order.
+	TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
 )
@@ -1,32 +1,136 @@
-// Package database provides SQLite connection management and table initialization.
+// Package database provides SQLite connection management and schema migrations.
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+)
+
+// Connection defaults used when Options leaves a field zero.
+const (
+	// DefaultBusyTimeout is how long a connection waits for another
+	// connection's lock before giving up with SQLITE_BUSY.
+	DefaultBusyTimeout = 5 * time.Second
 
-	_ "github.com/mattn/go-sqlite3"
+	// DefaultReadConns is the size of the read-only connection pool.
+	DefaultReadConns = 4
 )
 
+// Options configures how the database is opened. The zero value uses the
+// defaults.
+type Options struct {
+	// BusyTimeout defaults to DefaultBusyTimeout
+	BusyTimeout time.Duration
+
+	// ReadConns sizes the read-only pool behind Reader, DefaultReadConns if
+	// zero. A negative value sends reads through the write connection.
+	ReadConns int
+
+	// FullIntegrityCheck runs PRAGMA integrity_check at startup instead of
+	// the faster quick_check
+	FullIntegrityCheck bool
+
+	// EncryptionKey opens the database with SQLCipher using this key; it
+	// needs a build with the sqlcipher tag
+	EncryptionKey string
+
+	// Logger, if set, gets every statement with its duration at debug level
+	Logger *slog.Logger
+
+	// StmtCacheSize is how many prepared statements the write connection
+	// and the read pool each keep, DefaultStmtCacheSize if zero. A negative
+	// value prepares statements on every use.
+	StmtCacheSize int
+}
+
+// connectionPragmas are applied when the database is opened. They are run one
+// at a time because the SQLite drivers differ in multi-statement support.
+// Both are stored in the database file, so running them on one connection is
+// enough; connection-scoped pragmas such as foreign_keys go in the DSN.
+var connectionPragmas = []string{
+	// Only takes effect for a new, empty database
+	"PRAGMA auto_vacuum = INCREMENTAL",
+	"PRAGMA journal_mode = WAL",
+}
+
 // DB wraps the SQLite database connection with initialization logic.
+// The embedded handle is the single write connection; Reader returns a pool
+// of read-only connections that WAL lets run alongside it.
 type DB struct {
 	*sql.DB
-	path string
-	mu   sync.Mutex
+	read *sql.DB
+	// stmts and readStmts cache prepared statements for the write
+	// connection and the read pool; they are the same cache when reads use
+	// the write connection
+	stmts     *stmtCache
+	readStmts *stmtCache
+	path      string
+	dsn       string
+	key       string
+	mu        sync.Mutex
+
+	logger *slog.Logger
+
+	fullIntegrityCheck bool
 }
 
-// New creates a new database connection and initializes tables.
+// New creates a new database connection with the default options and brings
+// its schema up to date.
 func New(dbPath string) (*DB, error) {
-	sqlDB, err := sql.Open("sqlite3", dbPath)
+	return Open(dbPath, Options{})
+}
+
+// Open is like New but takes options.
+func Open(dbPath string, opts Options) (*DB, error) {
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = DefaultBusyTimeout
+	}
+	if opts.ReadConns == 0 {
+		opts.ReadConns = DefaultReadConns
+	}
+	if opts.StmtCacheSize == 0 {
+		opts.StmtCacheSize = DefaultStmtCacheSize
+	}
+	busyTimeout := strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10)
+	dsn := withParam(dbPath, pragmaParam("busy_timeout", busyTimeout))
+	// foreign_keys only lasts for the connection it is set on, and without
+	// it deleting a session leaves its session_tags rows behind. The driver
+	// sets DSN pragmas on every connection it opens, including any the pool
+	// replaces, which a one-off PRAGMA would miss.
+	dsn = withParam(dsn, pragmaParam("foreign_keys", "1"))
+
+	sqlDB, err := openPool(dsn, opts.EncryptionKey, opts.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if opts.EncryptionKey != "" {
+		if err := checkKey(context.Background(), sqlDB); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("%s: %w", dbPath, err)
+		}
+	}
+
+	// The DSN sets the busy timeout and foreign keys for every connection
+	// the driver opens; set them here too so a driver that ignores the
+	// parameters still waits and enforces them on this connection
+	pragmas := append([]string{"PRAGMA busy_timeout = " + busyTimeout, "PRAGMA foreign_keys = ON"}, connectionPragmas...)
 
 	// Enable foreign keys and WAL mode for better performance
-	if _, err := sqlDB.Exec("PRAGMA foreign_keys = ON; PRAGMA journal_mode = WAL;"); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("failed to set pragmas: %w", err)
+	for _, pragma := range pragmas {
+		if _, err := sqlDB.Exec(pragma); err != nil {
+			sqlDB.Close()
+			// Setting WAL mode reads the schema, which may be what is damaged
+			if isCorruption(err) {
+				return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+			}
+			return nil, fmt.Errorf("failed to set pragmas: %w", err)
+		}
 	}
 
 	// Configure connection pool for SQLite
@@ -34,104 +138,146 @@ func New(dbPath string) (*DB, error) {
 	// ensures that we don't run into "database is locked" errors during concurrent writes.
 	// WAL mode allows concurrent readers, but keeping it simple with 1 connection
 	// is the safest approach for SQLite unless we have high read throughput requirements.
+	// It also keeps in-memory databases alive: their contents vanish with the
+	// connection, so it must never be closed while the DB is in use.
 	sqlDB.SetMaxOpenConns(1)
 	sqlDB.SetMaxIdleConns(1)
 	sqlDB.SetConnMaxLifetime(0) // Reuse connections forever
+	sqlDB.SetConnMaxIdleTime(0)
 
 	db := &DB{
 		DB:   sqlDB,
+		read: sqlDB,
 		path: dbPath,
+		dsn:  dsn,
+		key:  opts.EncryptionKey,
+
+		logger:             opts.Logger,
+		fullIntegrityCheck: opts.FullIntegrityCheck,
+	}
+
+	// Refuse to start on a damaged file rather than fail on some later query
+	if err := db.CheckIntegrity(context.Background(), opts.FullIntegrityCheck); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("%s: %w", dbPath, err)
 	}
 
-	if err := db.initTables(); err != nil {
+	if err := db.migrate(); err != nil {
 		sqlDB.Close()
-		return nil, fmt.Errorf("failed to initialize tables: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// Another connection to an in-memory database would open an empty one
+	if opts.ReadConns > 0 && !db.InMemory() {
+		readDSN := withParam(dsn, pragmaParam("query_only", "1"))
+		read, err := openPool(readDSN, opts.EncryptionKey, opts.Logger)
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to open read pool: %w", err)
+		}
+		read.SetMaxOpenConns(opts.ReadConns)
+		read.SetMaxIdleConns(opts.ReadConns)
+		read.SetConnMaxIdleTime(5 * time.Minute)
+		db.read = read
+	}
+	db.stmts = newStmtCache(db.DB, opts.StmtCacheSize)
+	db.readStmts = db.stmts
+	if db.read != db.DB {
+		db.readStmts = newStmtCache(db.read, opts.StmtCacheSize)
 	}
 
 	return db, nil
 }
 
-// initTables creates the logs and sessions tables with indexes.
-func (db *DB) initTables() error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	// Create sessions table
-	sessionsTableSQL := `
-	CREATE TABLE IF NOT EXISTS sessions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		category TEXT NOT NULL,
-		task TEXT NOT NULL,
-		note TEXT,
-		location TEXT,
-		mood TEXT,
-		started_at TEXT NOT NULL,
-		ended_at TEXT,
-		duration_sec INTEGER,
-		status TEXT NOT NULL
-	);`
-
-	if _, err := db.Exec(sessionsTableSQL); err != nil {
-		return fmt.Errorf("failed to create sessions table: %w", err)
-	}
-
-	// Create indexes for sessions table
-	sessionsIndexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_sessions_started_at ON sessions(started_at);",
-		"CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions(status);",
-		"CREATE INDEX IF NOT EXISTS idx_sessions_category ON sessions(category);",
-	}
-
-	for _, idx := range sessionsIndexes {
-		if _, err := db.Exec(idx); err != nil {
-			return fmt.Errorf("failed to create sessions index: %w", err)
-		}
+// Reader returns the handle for queries that do not write: a pool of
+// read-only connections, or the write connection for in-memory databases
+// and when the pool is disabled. Reads through it see every committed write
+// but do not wait for a running write transaction or another long read.
+func (db *DB) Reader() *sql.DB {
+	return db.read
+}
+
+// Close closes the cached statements, the read pool and the write connection.
+func (db *DB) Close() error {
+	db.readStmts.close()
+	db.stmts.close()
+	if db.read != db.DB {
+		db.read.Close()
 	}
+	return db.DB.Close()
+}
 
-	tagsTableSQL := `
-	CREATE TABLE IF NOT EXISTS tags (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		color TEXT NOT NULL DEFAULT '#6B7280',
-		created_at TEXT NOT NULL
-	);`
+// FullIntegrityCheck reports whether the database was opened with
+// Options.FullIntegrityCheck, so later checks can use the same mode.
+func (db *DB) FullIntegrityCheck() bool {
+	return db.fullIntegrityCheck
+}
 
-	if _, err := db.Exec(tagsTableSQL); err != nil {
-		return fmt.Errorf("failed to create tags table: %w", err)
+// Path returns the database file path.
+func (db *DB) Path() string {
+	return db.path
+}
+
+// withParam appends a query parameter to a database path.
+func withParam(path, param string) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + param
 	}
+	return path + "?" + param
+}
 
-	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_tags_name ON tags(name);"); err != nil {
-		return fmt.Errorf("failed to create tags index: %w", err)
+// IsMemoryPath reports whether path names an in-memory database, either
+// ":memory:" or a URI filename such as "file::memory:?cache=shared".
+func IsMemoryPath(path string) bool {
+	if path == ":memory:" {
+		return true
 	}
+	if !strings.HasPrefix(path, "file:") {
+		return false
+	}
+	name, query, _ := strings.Cut(strings.TrimPrefix(path, "file:"), "?")
+	return name == ":memory:" || strings.Contains("&"+query+"&", "&mode=memory&")
+}
 
-	sessionTagsTableSQL := `
-	CREATE TABLE IF NOT EXISTS session_tags (
-		session_id INTEGER NOT NULL,
-		tag_id INTEGER NOT NULL,
-		PRIMARY KEY (session_id, tag_id),
-		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
-		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
-	);`
+// InMemory reports whether the database lives only in memory and is lost
+// when it is closed.
+func (db *DB) InMemory() bool {
+	return IsMemoryPath(db.path)
+}
 
-	if _, err := db.Exec(sessionTagsTableSQL); err != nil {
-		return fmt.Errorf("failed to create session_tags table: %w", err)
+// BackupTo writes a consistent snapshot of the database to path using
+// VACUUM INTO, which is safe while the database is in use under WAL.
+// path must not exist yet.
+func (db *DB) BackupTo(path string) error {
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
 	}
+	return nil
+}
 
-	sessionTagsIndexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_session_tags_session ON session_tags(session_id);",
-		"CREATE INDEX IF NOT EXISTS idx_session_tags_tag ON session_tags(tag_id);",
+// SnapshotTo is like BackupTo but runs on a connection of its own, so the
+// shared connection stays free for requests while the copy is written. Under
+// WAL the snapshot is a reader and never blocks writers. In-memory databases
+// are only reachable through the shared connection and fall back to BackupTo.
+func (db *DB) SnapshotTo(ctx context.Context, path string) error {
+	if db.InMemory() {
+		return db.BackupTo(path)
 	}
 
-	for _, idx := range sessionTagsIndexes {
-		if _, err := db.Exec(idx); err != nil {
-			return fmt.Errorf("failed to create session_tags index: %w", err)
-		}
+	conn, err := openPool(db.dsn, db.key, db.logger)
+	if err != nil {
+		return fmt.Errorf("failed to open database for backup: %w", err)
 	}
+	defer conn.Close()
 
+	if _, err := conn.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
 	return nil
 }
 
-// Path returns the database file path.
-func (db *DB) Path() string {
-	return db.path
+// IsUniqueViolation reports whether err was caused by a UNIQUE constraint
+// or unique index. It matches on the message both SQLite drivers share.
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -89,3 +91,117 @@ func TestDB_Path(t *testing.T) {
 		t.Errorf("expected path %s, got %s", dbPath, db.Path())
 	}
 }
+
+func TestNew_InMemory(t *testing.T) {
+	for _, path := range []string{":memory:", "file::memory:?cache=shared"} {
+		t.Run(path, func(t *testing.T) {
+			db, err := New(path)
+			if err != nil {
+				t.Fatalf("failed to create database: %v", err)
+			}
+			defer db.Close()
+
+			if !db.InMemory() {
+				t.Error("expected InMemory to be true")
+			}
+
+			// The schema and data must survive across statements, which only
+			// holds while the pool keeps its one connection open
+			for i := 0; i < 3; i++ {
+				if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'task', '2024-03-01T10:00:00Z', 'stopped')`); err != nil {
+					t.Fatalf("insert %d failed: %v", i, err)
+				}
+			}
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count); err != nil {
+				t.Fatalf("failed to count sessions: %v", err)
+			}
+			if count != 3 {
+				t.Errorf("expected 3 sessions, got %d", count)
+			}
+		})
+	}
+}
+
+func TestIsMemoryPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{":memory:", true},
+		{"file::memory:", true},
+		{"file::memory:?cache=shared", true},
+		{"file:demo?mode=memory&cache=shared", true},
+		{"file:demo.db?cache=shared", false},
+		{"./timelog.db", false},
+		{"memory.db", false},
+	}
+	for _, tt := range tests {
+		if got := IsMemoryPath(tt.path); got != tt.want {
+			t.Errorf("IsMemoryPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNewForTesting(t *testing.T) {
+	db := NewForTesting(t)
+	if !db.InMemory() {
+		t.Error("expected an in-memory database")
+	}
+	version, err := db.SchemaVersion()
+	if err != nil || version == 0 {
+		t.Fatalf("expected a migrated database, got version %d, err %v", version, err)
+	}
+}
+
+func TestNew_ForeignKeysOnEveryConnection(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO sessions (id, category, task, started_at, status) VALUES (1, 'work', 'task', '2024-01-01T09:00:00Z', 'running')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO tags (id, name, color, created_at) VALUES (1, 'deep', '#000000', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO session_tags (session_id, tag_id) VALUES (1, 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Grow the write pool and hold every connection, so the last one is
+	// opened after New ran its pragmas
+	ctx := context.Background()
+	db.SetMaxOpenConns(3)
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+
+		var fk int
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&fk); err != nil {
+			t.Fatal(err)
+		}
+		if fk != 1 {
+			t.Errorf("connection %d has foreign_keys = %d", i, fk)
+		}
+	}
+
+	last := conns[len(conns)-1]
+	if _, err := last.ExecContext(ctx, "DELETE FROM sessions WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := last.QueryRowContext(ctx, "SELECT COUNT(*) FROM session_tags WHERE session_id = 1").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("deleting the session left %d session_tags rows", count)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !purego
+
+package database
+
+import _ "github.com/mattn/go-sqlite3"
+
+// driverName is the database/sql driver used to open SQLite. mattn/go-sqlite3
+// needs CGO and a C toolchain; build with the purego tag to avoid both.
+const driverName = "sqlite3"
+
+// pragmaParam is the DSN parameter that sets a pragma on every connection
+// the driver opens. mattn/go-sqlite3 names each supported pragma _name.
+func pragmaParam(name, value string) string {
+	return "_" + name + "=" + value
+}
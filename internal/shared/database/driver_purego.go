@@ -0,0 +1,15 @@
+//go:build purego
+
+package database
+
+import _ "modernc.org/sqlite"
+
+// driverName is the database/sql driver used to open SQLite. The purego build
+// tag selects modernc.org/sqlite, which needs no CGO.
+const driverName = "sqlite"
+
+// pragmaParam is the DSN parameter that sets a pragma on every connection
+// the driver opens. modernc.org/sqlite takes any pragma as _pragma=name(value).
+func pragmaParam(name, value string) string {
+	return "_pragma=" + name + "(" + value + ")"
+}
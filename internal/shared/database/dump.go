@@ -0,0 +1,342 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotEmpty is returned by Import when the database already holds sessions
+// or tags and force is not set.
+var ErrNotEmpty = errors.New("database is not empty")
+
+// ErrInvalidDump is returned by Import when the document cannot be loaded:
+// it is from a newer schema, or its rows are incomplete or inconsistent.
+var ErrInvalidDump = errors.New("invalid dump")
+
+// Dump is every session, tag and tag assignment in the database, with their
+// IDs, as a document that does not depend on the storage engine.
+type Dump struct {
+	// SchemaVersion is the migration version of the database that wrote it
+	SchemaVersion int              `json:"schema_version"`
+	Sessions      []DumpSession    `json:"sessions"`
+	Tags          []DumpTag        `json:"tags"`
+	SessionTags   []DumpSessionTag `json:"session_tags"`
+}
+
+// DumpSession is a row of the sessions table.
+type DumpSession struct {
+	ID          int64   `json:"id"`
+	UserID      int64   `json:"user_id,omitempty"`
+	Category    string  `json:"category"`
+	Task        string  `json:"task"`
+	Note        *string `json:"note"`
+	Location    *string `json:"location"`
+	Mood        *string `json:"mood"`
+	StartedAt   string  `json:"started_at"`
+	EndedAt     *string `json:"ended_at"`
+	DurationSec *int64  `json:"duration_sec"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+// DumpTag is a row of the tags table.
+type DumpTag struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id,omitempty"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	CreatedAt string `json:"created_at"`
+	ParentID  *int64 `json:"parent_id"`
+	Archived  bool   `json:"archived"`
+}
+
+// DumpSessionTag is a row of the session_tags table.
+type DumpSessionTag struct {
+	SessionID int64 `json:"session_id"`
+	TagID     int64 `json:"tag_id"`
+}
+
+// DumpCounts reports how many rows an import loaded.
+type DumpCounts struct {
+	Sessions    int `json:"sessions"`
+	Tags        int `json:"tags"`
+	SessionTags int `json:"session_tags"`
+}
+
+// Export reads every session, tag and tag assignment in one read
+// transaction, so the document is consistent while writes continue. It
+// holds every user's rows, so it is only served to admins.
+func (db *DB) Export(ctx context.Context) (*Dump, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.read.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin export: %w", err)
+	}
+	defer tx.Rollback()
+
+	dump := &Dump{
+		SchemaVersion: version,
+		Sessions:      []DumpSession{},
+		Tags:          []DumpTag{},
+		SessionTags:   []DumpSessionTag{},
+	}
+
+	err = queryEach(ctx, tx, `SELECT id, user_id, category, task, note, location, mood, started_at, ended_at,
+		duration_sec, status, created_at, updated_at FROM sessions ORDER BY id`, func(rows *sql.Rows) error {
+		var s DumpSession
+		var note, location, mood, endedAt sql.NullString
+		var duration sql.NullInt64
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Category, &s.Task, &note, &location, &mood, &s.StartedAt, &endedAt,
+			&duration, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return err
+		}
+		s.Note, s.Location, s.Mood, s.EndedAt = nullString(note), nullString(location), nullString(mood), nullString(endedAt)
+		if duration.Valid {
+			s.DurationSec = &duration.Int64
+		}
+		dump.Sessions = append(dump.Sessions, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export sessions: %w", err)
+	}
+
+	err = queryEach(ctx, tx, "SELECT id, user_id, name, color, created_at, parent_id, archived FROM tags ORDER BY id", func(rows *sql.Rows) error {
+		var t DumpTag
+		var parentID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.CreatedAt, &parentID, &t.Archived); err != nil {
+			return err
+		}
+		if parentID.Valid {
+			t.ParentID = &parentID.Int64
+		}
+		dump.Tags = append(dump.Tags, t)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export tags: %w", err)
+	}
+
+	err = queryEach(ctx, tx, "SELECT session_id, tag_id FROM session_tags ORDER BY session_id, tag_id", func(rows *sql.Rows) error {
+		var st DumpSessionTag
+		if err := rows.Scan(&st.SessionID, &st.TagID); err != nil {
+			return err
+		}
+		dump.SessionTags = append(dump.SessionTags, st)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export session tags: %w", err)
+	}
+
+	return dump, nil
+}
+
+// Import loads a document written by Export, keeping its IDs, in a single
+// transaction. It refuses with ErrNotEmpty if the database already holds
+// sessions or tags, unless force is set, in which case they are replaced.
+// Documents from older schema versions load with the newer columns
+// defaulted, their rows owned by the default user; newer ones are rejected.
+// Session timestamps are stored in UTC with milliseconds whatever form the
+// document has them in.
+// Users are not part of a dump: the ones rows belong to must exist.
+func (db *DB) Import(ctx context.Context, dump *Dump, force bool) (*DumpCounts, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if dump.SchemaVersion < 1 || dump.SchemaVersion > version {
+		return nil, fmt.Errorf("%w: schema_version %d is not supported, this binary supports 1 to %d",
+			ErrInvalidDump, dump.SchemaVersion, version)
+	}
+	if err := dump.validate(); err != nil {
+		return nil, err
+	}
+
+	err = db.InTx(ctx, func(tx *sql.Tx) error {
+		if !force {
+			var exists bool
+			if err := tx.QueryRowContext(ctx,
+				"SELECT EXISTS (SELECT 1 FROM sessions) OR EXISTS (SELECT 1 FROM tags)").Scan(&exists); err != nil {
+				return fmt.Errorf("failed to check for existing data: %w", err)
+			}
+			if exists {
+				return ErrNotEmpty
+			}
+		}
+
+		if err := checkUsers(ctx, tx, dump); err != nil {
+			return err
+		}
+
+		// Tags can reference a parent with a higher ID, so foreign keys
+		// are checked once at commit instead of row by row
+		if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+			return fmt.Errorf("failed to defer foreign keys: %w", err)
+		}
+		// Presets and reminder rules are not part of a dump; presets keep
+		// their category and task but lose tags that no longer exist, and
+		// what reminders fired goes with the sessions it was for
+		for _, table := range []string{"session_tags", "preset_tags", "reminder_fires", "sessions", "tags"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+		}
+
+		for _, s := range dump.Sessions {
+			// Dumps from before version 3 have no row timestamps
+			createdAt, updatedAt := s.CreatedAt, s.UpdatedAt
+			if createdAt == "" {
+				createdAt = s.StartedAt
+			}
+			if updatedAt == "" {
+				updatedAt = createdAt
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO sessions (id, user_id, category, task, note, location, mood,
+				started_at, ended_at, duration_sec, status, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				s.ID, ownerOf(s.UserID), s.Category, s.Task, s.Note, s.Location, s.Mood,
+				s.StartedAt, s.EndedAt, s.DurationSec, s.Status, createdAt, updatedAt); err != nil {
+				return importError("session", s.ID, err)
+			}
+		}
+		// Dumps from older versions or edited by hand may hold offsets or
+		// whole seconds, which the migrations removed
+		if _, err := normalizeSessionTimestamps(ctx, tx); err != nil {
+			return err
+		}
+		for _, t := range dump.Tags {
+			color := t.Color
+			if color == "" {
+				color = "#6B7280"
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO tags (id, user_id, name, color, created_at, parent_id, archived) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				t.ID, ownerOf(t.UserID), t.Name, color, t.CreatedAt, t.ParentID, t.Archived); err != nil {
+				return importError("tag", t.ID, err)
+			}
+		}
+		for _, st := range dump.SessionTags {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO session_tags (session_id, tag_id) VALUES (?, ?)",
+				st.SessionID, st.TagID); err != nil {
+				return importError("session tag for session", st.SessionID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return nil, fmt.Errorf("%w: a tag assignment or parent refers to a missing row", ErrInvalidDump)
+		}
+		return nil, err
+	}
+
+	return &DumpCounts{
+		Sessions:    len(dump.Sessions),
+		Tags:        len(dump.Tags),
+		SessionTags: len(dump.SessionTags),
+	}, nil
+}
+
+// validate checks the fields the schema cannot, so a bad document fails
+// with a message naming the row.
+func (d *Dump) validate() error {
+	for _, s := range d.Sessions {
+		switch {
+		case s.ID <= 0:
+			return fmt.Errorf("%w: session id %d must be positive", ErrInvalidDump, s.ID)
+		case s.Category == "" || s.StartedAt == "":
+			return fmt.Errorf("%w: session %d needs a category and started_at", ErrInvalidDump, s.ID)
+		case s.Status != "running" && s.Status != "stopped":
+			return fmt.Errorf("%w: session %d has status %q", ErrInvalidDump, s.ID, s.Status)
+		}
+	}
+	for _, t := range d.Tags {
+		switch {
+		case t.ID <= 0:
+			return fmt.Errorf("%w: tag id %d must be positive", ErrInvalidDump, t.ID)
+		case t.Name == "" || t.CreatedAt == "":
+			return fmt.Errorf("%w: tag %d needs a name and created_at", ErrInvalidDump, t.ID)
+		}
+	}
+	return nil
+}
+
+// checkUsers fails with ErrInvalidDump if a row belongs to a user the
+// database does not have.
+func checkUsers(ctx context.Context, tx *sql.Tx, dump *Dump) error {
+	seen := map[int64]bool{}
+	check := func(kind string, id, userID int64) error {
+		userID = ownerOf(userID)
+		if seen[userID] {
+			return nil
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check user %d: %w", userID, err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s %d belongs to user %d, who does not exist", ErrInvalidDump, kind, id, userID)
+		}
+		seen[userID] = true
+		return nil
+	}
+	for _, s := range dump.Sessions {
+		if err := check("session", s.ID, s.UserID); err != nil {
+			return err
+		}
+	}
+	for _, t := range dump.Tags {
+		if err := check("tag", t.ID, t.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownerOf returns the user a dumped row belongs to; rows from before
+// users existed belong to the default user.
+func ownerOf(userID int64) int64 {
+	if userID == 0 {
+		return DefaultUserID
+	}
+	return userID
+}
+
+// importError reports a row the schema rejected, such as a duplicate ID or
+// a second running session, as an invalid dump.
+func importError(kind string, id int64, err error) error {
+	if strings.Contains(err.Error(), "constraint failed") {
+		return fmt.Errorf("%w: %s %d: %v", ErrInvalidDump, kind, id, err)
+	}
+	return fmt.Errorf("failed to import %s %d: %w", kind, id, err)
+}
+
+// queryEach runs query in tx and calls fn for every row.
+func queryEach(ctx context.Context, tx *sql.Tx, query string, fn func(rows *sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func seedDump(t *testing.T, db *DB) {
+	t.Helper()
+	for _, stmt := range []string{
+		// The child tag has the lower ID, so its parent is inserted after it
+		`INSERT INTO tags (id, name, color, created_at, parent_id, archived) VALUES (5, 'child', '#111111', '2024-01-01T00:00:00Z', NULL, 1)`,
+		`INSERT INTO tags (id, name, color, created_at) VALUES (9, 'parent', '#222222', '2024-01-01T00:00:00Z')`,
+		`UPDATE tags SET parent_id = 9 WHERE id = 5`,
+		`INSERT INTO sessions (id, category, task, note, started_at, ended_at, duration_sec, status, created_at, updated_at)
+			VALUES (3, 'work', 'stopped', 'a note', '2024-01-01T09:00:00.000Z', '2024-01-01T10:00:00.000Z', 3600, 'stopped', '2024-01-01T09:00:00.000Z', '2024-02-01T00:00:00.000Z')`,
+		`INSERT INTO sessions (id, category, task, started_at, status, created_at, updated_at)
+			VALUES (7, 'study', 'running', '2024-01-02T09:00:00.000Z', 'running', '2024-01-02T09:00:00.000Z', '2024-01-02T09:00:00.000Z')`,
+		`INSERT INTO session_tags (session_id, tag_id) VALUES (3, 5), (3, 9), (7, 9)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDump_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := NewForTesting(t)
+	seedDump(t, src)
+
+	dump, err := src.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(dump.Sessions) != 2 || len(dump.Tags) != 2 || len(dump.SessionTags) != 3 {
+		t.Fatalf("exported %d sessions, %d tags, %d assignments", len(dump.Sessions), len(dump.Tags), len(dump.SessionTags))
+	}
+
+	dst := NewForTesting(t)
+	counts, err := dst.Import(ctx, dump, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if *counts != (DumpCounts{Sessions: 2, Tags: 2, SessionTags: 3}) {
+		t.Errorf("Import counts = %+v", counts)
+	}
+
+	again, err := dst.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dump, again) {
+		t.Errorf("re-export differs:\n got %+v\nwant %+v", again, dump)
+	}
+
+	// New rows continue after the imported IDs
+	res, err := dst.Exec(`INSERT INTO tags (name, color, created_at) VALUES ('new', '#333333', '2024-01-01T00:00:00Z')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := res.LastInsertId(); id != 10 {
+		t.Errorf("new tag id = %d, want 10", id)
+	}
+}
+
+func TestDump_ImportRefusesNonEmpty(t *testing.T) {
+	ctx := context.Background()
+	db := NewForTesting(t)
+	seedDump(t, db)
+
+	dump := &Dump{SchemaVersion: 1, Sessions: []DumpSession{
+		{ID: 1, Category: "work", Task: "only", StartedAt: "2023-01-01T09:00:00Z", Status: "stopped"},
+	}}
+	if _, err := db.Import(ctx, dump, false); err != ErrNotEmpty {
+		t.Fatalf("Import error = %v, want ErrNotEmpty", err)
+	}
+
+	if _, err := db.Import(ctx, dump, true); err != nil {
+		t.Fatalf("Import with force: %v", err)
+	}
+	var sessions, tags int
+	if err := db.QueryRow("SELECT COUNT(*), (SELECT COUNT(*) FROM tags) FROM sessions").Scan(&sessions, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 1 || tags != 0 {
+		t.Errorf("after forced import: %d sessions, %d tags, want 1 and 0", sessions, tags)
+	}
+
+	// A version 1 dump has no row timestamps; they default to started_at,
+	// stored with milliseconds like every session timestamp
+	var startedAt, createdAt, updatedAt string
+	if err := db.QueryRow("SELECT started_at, created_at, updated_at FROM sessions").Scan(&startedAt, &createdAt, &updatedAt); err != nil {
+		t.Fatal(err)
+	}
+	if startedAt != "2023-01-01T09:00:00.000Z" || createdAt != startedAt || updatedAt != startedAt {
+		t.Errorf("started_at = %q, created_at = %q, updated_at = %q", startedAt, createdAt, updatedAt)
+	}
+}
+
+func TestDump_ImportInvalid(t *testing.T) {
+	ctx := context.Background()
+	session := func(id int64, status string) DumpSession {
+		return DumpSession{ID: id, Category: "work", StartedAt: "2024-01-01T09:00:00Z", Status: status}
+	}
+
+	for name, dump := range map[string]*Dump{
+		"newer schema":     {SchemaVersion: 99},
+		"missing version":  {},
+		"bad status":       {SchemaVersion: 1, Sessions: []DumpSession{session(1, "paused")}},
+		"duplicate id":     {SchemaVersion: 1, Sessions: []DumpSession{session(1, "stopped"), session(1, "stopped")}},
+		"two running":      {SchemaVersion: 2, Sessions: []DumpSession{session(1, "running"), session(2, "running")}},
+		"unknown user":     {SchemaVersion: 7, Sessions: []DumpSession{{ID: 1, UserID: 9, Category: "work", StartedAt: "2024-01-01T09:00:00Z", Status: "stopped"}}},
+		"dangling tag ref": {SchemaVersion: 1, Sessions: []DumpSession{session(1, "stopped")}, SessionTags: []DumpSessionTag{{SessionID: 1, TagID: 4}}},
+	} {
+		db := NewForTesting(t)
+		if _, err := db.Import(ctx, dump, false); !errors.Is(err, ErrInvalidDump) {
+			t.Errorf("%s: Import error = %v, want ErrInvalidDump", name, err)
+			continue
+		}
+		// Nothing is left behind by a failed import
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s: %d sessions left after a failed import", name, count)
+		}
+	}
+}
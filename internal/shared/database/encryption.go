@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ErrEncryptionUnsupported is returned by Open when an encryption key is set
+// but the binary was not built against SQLCipher.
+var ErrEncryptionUnsupported = errors.New("database encryption requires a build with -tags sqlcipher linked against SQLCipher")
+
+// ErrWrongKey is returned by Open when the database file cannot be read
+// with the encryption key: the key is wrong or the file is not encrypted.
+var ErrWrongKey = errors.New("cannot decrypt database: the encryption key is wrong or the file is not encrypted")
+
+// keyConnector opens connections with the SQLite driver and sets the
+// encryption key on each one before anything reads the file. SQLCipher
+// needs the key per connection, which a DSN parameter cannot carry for
+// every driver. With a logger, it wraps each connection to log statements
+// once the key is set, so the key is never logged.
+type keyConnector struct {
+	driver driver.Driver
+	dsn    string
+	key    string
+	logger *slog.Logger
+}
+
+// Connect implements driver.Connector.
+func (c *keyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if c.key != "" {
+		if err := c.setKey(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.logger != nil {
+		conn = &loggedConn{Conn: conn, logger: c.logger}
+	}
+	return conn, nil
+}
+
+func (c *keyConnector) setKey(ctx context.Context, conn driver.Conn) error {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return fmt.Errorf("driver %s cannot set an encryption key", driverName)
+	}
+	if _, err := execer.ExecContext(ctx, "PRAGMA key = "+quoteLiteral(c.key), nil); err != nil {
+		return fmt.Errorf("failed to set encryption key: %w", err)
+	}
+	return nil
+}
+
+// Driver implements driver.Connector.
+func (c *keyConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// openPool opens a handle for dsn, keyed when key is set and logging
+// statements to logger if it is not nil.
+func openPool(dsn, key string, logger *slog.Logger) (*sql.DB, error) {
+	if key == "" && logger == nil {
+		return sql.Open(driverName, dsn)
+	}
+	if key != "" && !encryptionSupported {
+		return nil, ErrEncryptionUnsupported
+	}
+
+	// sql.Open only looks the driver up, so this is how to reach it
+	probe, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	drv := probe.Driver()
+	probe.Close()
+	return sql.OpenDB(&keyConnector{driver: drv, dsn: dsn, key: key, logger: logger}), nil
+}
+
+// checkKey verifies that the linked SQLite is SQLCipher, which silently
+// ignores PRAGMA key otherwise, and that the key decrypts the file.
+func checkKey(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	linked := cipherLinked(ctx, conn)
+	conn.Close()
+	if !linked {
+		return ErrEncryptionUnsupported
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil {
+		if strings.Contains(err.Error(), "not a database") {
+			return ErrWrongKey
+		}
+		return fmt.Errorf("failed to read encrypted database: %w", err)
+	}
+	return nil
+}
+
+// cipherLinked reports whether the SQLite behind conn is SQLCipher.
+func cipherLinked(ctx context.Context, conn *sql.Conn) bool {
+	var version string
+	err := conn.QueryRowContext(ctx, "PRAGMA cipher_version").Scan(&version)
+	return err == nil && version != ""
+}
+
+// Encrypt writes an encrypted copy of the plaintext database at src to dst
+// using SQLCipher's sqlcipher_export. dst must not exist yet. The copy can
+// then replace src and be opened with Options.EncryptionKey set to key.
+func Encrypt(ctx context.Context, src, dst, key string) error {
+	if !encryptionSupported {
+		return ErrEncryptionUnsupported
+	}
+	if key == "" {
+		return fmt.Errorf("encryption key is empty")
+	}
+
+	db, err := sql.Open(driverName, src)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	// ATTACH is per connection, so every statement must share one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	if !cipherLinked(ctx, conn) {
+		return ErrEncryptionUnsupported
+	}
+	for _, stmt := range []string{
+		"ATTACH DATABASE " + quoteLiteral(dst) + " AS encrypted KEY " + quoteLiteral(key),
+		"SELECT sqlcipher_export('encrypted')",
+		"DETACH DATABASE encrypted",
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to encrypt database: %w", err)
+		}
+	}
+	return nil
+}
+
+// quoteLiteral quotes s as an SQL string literal. PRAGMA and ATTACH ... KEY
+// take no bound parameters.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
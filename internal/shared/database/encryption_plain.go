@@ -0,0 +1,7 @@
+//go:build !sqlcipher
+
+package database
+
+// encryptionSupported is false in builds without the sqlcipher tag, whose
+// bundled SQLite ignores PRAGMA key.
+const encryptionSupported = false
@@ -0,0 +1,20 @@
+//go:build !sqlcipher
+
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen_EncryptionUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	if _, err := Open(path, Options{EncryptionKey: "secret"}); !errors.Is(err, ErrEncryptionUnsupported) {
+		t.Fatalf("Open with a key error = %v, want ErrEncryptionUnsupported", err)
+	}
+	if err := Encrypt(context.Background(), path, path+".enc", "secret"); !errors.Is(err, ErrEncryptionUnsupported) {
+		t.Fatalf("Encrypt error = %v, want ErrEncryptionUnsupported", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build sqlcipher
+
+package database
+
+// encryptionSupported is set by the sqlcipher build tag, which marks a build
+// linked against SQLCipher instead of the bundled SQLite, for example:
+//
+//	CGO_CFLAGS="-DSQLITE_HAS_CODEC -I/usr/include/sqlcipher" CGO_LDFLAGS="-lsqlcipher" \
+//		go build -tags "libsqlite3 sqlcipher" ./cmd/server
+const encryptionSupported = true
@@ -0,0 +1,88 @@
+//go:build sqlcipher
+
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen_Encrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path, Options{EncryptionKey: "it's secret"})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (category, task, note, started_at, status) VALUES ('work', 'task', 'client name', '2024-01-01T09:00:00Z', 'running')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Checkpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Reads go through the read pool, which needs the key too
+	var note string
+	if err := db.Reader().QueryRow("SELECT note FROM sessions").Scan(&note); err != nil {
+		t.Fatalf("read pool: %v", err)
+	}
+	db.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("client name")) || bytes.HasPrefix(data, []byte("SQLite format 3")) {
+		t.Fatal("database file is stored in plaintext")
+	}
+
+	if _, err := Open(path, Options{EncryptionKey: "wrong"}); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("Open with the wrong key error = %v, want ErrWrongKey", err)
+	}
+
+	db, err = Open(path, Options{EncryptionKey: "it's secret"})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	if err := db.QueryRow("SELECT note FROM sessions").Scan(&note); err != nil || note != "client name" {
+		t.Fatalf("reopened note = %q, %v", note, err)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.db")
+	db, err := New(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'task', '2024-01-01T09:00:00Z', 'running')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// A plaintext file opened with a key is refused, not overwritten
+	if _, err := Open(plain, Options{EncryptionKey: "secret"}); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("Open plaintext with a key error = %v, want ErrWrongKey", err)
+	}
+
+	encrypted := filepath.Join(dir, "encrypted.db")
+	if err := Encrypt(context.Background(), plain, encrypted, "secret"); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	db, err = Open(encrypted, Options{EncryptionKey: "secret"})
+	if err != nil {
+		t.Fatalf("Open encrypted copy: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count); err != nil || count != 1 {
+		t.Fatalf("encrypted copy has %d sessions, %v", count, err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version == 0 {
+		t.Fatalf("encrypted copy schema version = %d, %v", version, err)
+	}
+}
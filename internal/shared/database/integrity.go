@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCorrupt is returned when an integrity check finds problems in the
+// database file.
+var ErrCorrupt = errors.New("database integrity check failed")
+
+// maxIntegrityErrors caps how many problems a check collects; a badly
+// damaged file can report one per row.
+const maxIntegrityErrors = 10
+
+// CheckIntegrity runs PRAGMA quick_check, or the slower integrity_check when
+// full is set, which also verifies that indexes match their tables. It
+// returns an error wrapping ErrCorrupt listing the problems found.
+func (db *DB) CheckIntegrity(ctx context.Context, full bool) error {
+	pragma := fmt.Sprintf("PRAGMA quick_check(%d)", maxIntegrityErrors)
+	if full {
+		pragma = fmt.Sprintf("PRAGMA integrity_check(%d)", maxIntegrityErrors)
+	}
+
+	rows, err := db.read.QueryContext(ctx, pragma)
+	if err != nil {
+		return integrityError(err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("failed to read integrity check: %w", err)
+		}
+		// Some versions report every problem in a single multi-line row
+		for _, problem := range strings.Split(line, "\n") {
+			if problem = strings.TrimSpace(problem); problem != "" && problem != "ok" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return integrityError(err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// integrityError wraps an error that stopped the check from completing. A
+// file damaged badly enough makes SQLite give up before reporting rows.
+func integrityError(err error) error {
+	if isCorruption(err) {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return fmt.Errorf("failed to run integrity check: %w", err)
+}
+
+// isCorruption reports whether SQLite failed because the file is damaged.
+func isCorruption(err error) bool {
+	return strings.Contains(err.Error(), "malformed") || strings.Contains(err.Error(), "not a database")
+}
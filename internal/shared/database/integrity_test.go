@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptCopy builds a database with enough rows to span several pages,
+// copies it and overwrites the middle of every page after the first in the
+// copy. The header page is left alone so SQLite still opens the file.
+func corruptCopy(t *testing.T) (good, bad string) {
+	t.Helper()
+	dir := t.TempDir()
+	good = filepath.Join(dir, "good.db")
+
+	db, err := New(good)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	_, err = db.Exec(`
+		WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < 2000)
+		INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+		SELECT 'work', 'task ' || i, '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped' FROM seq`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fold the WAL into the main file so the copy holds every row
+	if _, err := db.Checkpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var pageSize int
+	if err := db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	data, err := os.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for page := pageSize; page+pageSize <= len(data); page += pageSize {
+		for i := page + pageSize/4; i < page+pageSize/2; i++ {
+			data[i] ^= 0x5a
+		}
+	}
+	bad = filepath.Join(dir, "bad.db")
+	if err := os.WriteFile(bad, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return good, bad
+}
+
+func TestOpen_IntegrityCheck(t *testing.T) {
+	good, bad := corruptCopy(t)
+
+	for _, full := range []bool{false, true} {
+		db, err := Open(good, Options{FullIntegrityCheck: full})
+		if err != nil {
+			t.Fatalf("Open(good, full=%v): %v", full, err)
+		}
+		if err := db.CheckIntegrity(context.Background(), full); err != nil {
+			t.Errorf("CheckIntegrity(good, full=%v): %v", full, err)
+		}
+		db.Close()
+
+		_, err = Open(bad, Options{FullIntegrityCheck: full})
+		if !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Open(bad, full=%v) error = %v, want ErrCorrupt", full, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordHandler keeps the records it handles at level or above.
+type recordHandler struct {
+	level slog.Level
+
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler      { return h }
+
+// statements returns the attributes of the "sql" records handled.
+func (h *recordHandler) statements() []map[string]slog.Value {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []map[string]slog.Value
+	for _, r := range h.records {
+		if r.Message != "sql" {
+			continue
+		}
+		attrs := map[string]slog.Value{}
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value
+			return true
+		})
+		out = append(out, attrs)
+	}
+	return out
+}
+
+func TestOpen_LogsStatements(t *testing.T) {
+	h := &recordHandler{level: slog.LevelDebug}
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{Logger: slog.New(h)})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("INSERT INTO tags (name,\n  color, created_at) VALUES (?, ?, ?)", "secret-tag", "#112233", "2024-01-01T00:00:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := db.QueryRow("SELECT name FROM tags WHERE color = ?", "#112233").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT * FROM no_such_table"); err == nil {
+		t.Fatal("expected an error for a missing table")
+	}
+
+	found := map[string]map[string]slog.Value{}
+	for _, attrs := range h.statements() {
+		query := attrs["query"].String()
+		if strings.Contains(query, "secret-tag") {
+			t.Errorf("arguments were logged: %q", query)
+		}
+		if _, ok := attrs["duration_ms"]; !ok {
+			t.Errorf("no duration for %q", query)
+		}
+		found[query] = attrs
+	}
+	for _, query := range []string{
+		"INSERT INTO tags (name, color, created_at) VALUES (?, ?, ?)",
+		"SELECT name FROM tags WHERE color = ?",
+	} {
+		if _, ok := found[query]; !ok {
+			t.Errorf("statement %q was not logged", query)
+		}
+	}
+	if attrs, ok := found["SELECT * FROM no_such_table"]; !ok || !strings.Contains(attrs["error"].String(), "no such table") {
+		t.Errorf("failed statement logged as %v, want its error", attrs)
+	}
+}
+
+func TestOpen_LogsStatementsOnlyAtDebug(t *testing.T) {
+	h := &recordHandler{level: slog.LevelInfo}
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{Logger: slog.New(h)})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("INSERT INTO tags (name, color, created_at) VALUES (?, ?, ?)", "work", "#112233", "2024-01-01T00:00:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.statements(); len(got) != 0 {
+		t.Errorf("info level logged %d statements", len(got))
+	}
+}
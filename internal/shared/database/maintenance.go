@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CheckpointResult reports a WAL checkpoint.
+type CheckpointResult struct {
+	// Busy is set when another connection kept the checkpoint from completing
+	Busy              bool  `json:"busy"`
+	LogPages          int   `json:"log_pages"`
+	CheckpointedPages int   `json:"checkpointed_pages"`
+	DurationMs        int64 `json:"duration_ms"`
+}
+
+// VacuumResult reports an incremental vacuum.
+type VacuumResult struct {
+	// Full is set when a full VACUUM was needed to enable incremental vacuum
+	Full       bool  `json:"full"`
+	FreedPages int64 `json:"freed_pages"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// Checkpoint copies the WAL into the main database file and truncates the
+// WAL to zero bytes. It runs on the shared connection, so it waits for the
+// current statement and requests wait for it.
+func (db *DB) Checkpoint(ctx context.Context) (*CheckpointResult, error) {
+	start := time.Now()
+	var busy, logPages, checkpointed int
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logPages, &checkpointed); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return &CheckpointResult{
+		Busy:              busy != 0,
+		LogPages:          logPages,
+		CheckpointedPages: checkpointed,
+		DurationMs:        time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// RepairNote is appended to the note of each session StopExtraRunning stops.
+const RepairNote = "[stopped automatically: another session was running at the same time]"
+
+// ExtraRunningSessions returns how many running sessions there are beyond
+// one per user. The unique index on running sessions keeps this at zero; a
+// database edited by hand or restored from an old dump may not have it.
+func (db *DB) ExtraRunningSessions(ctx context.Context) (int64, error) {
+	var extra int64
+	if err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) - COUNT(DISTINCT user_id) FROM sessions WHERE status = 'running'",
+	).Scan(&extra); err != nil {
+		return 0, fmt.Errorf("failed to count running sessions: %w", err)
+	}
+	return extra, nil
+}
+
+// StopExtraRunning stops every running session but each user's most recently
+// started one, ending it now with RepairNote appended to its note, and
+// returns how many it stopped.
+func (db *DB) StopExtraRunning(ctx context.Context) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+	UPDATE sessions SET
+		status = 'stopped',
+		ended_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'),
+		updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'),
+		duration_sec = MAX(0, CAST(ROUND((julianday('now') - julianday(started_at)) * 86400) AS INTEGER)),
+		note = CASE WHEN note IS NULL OR note = '' THEN ?1 ELSE note || char(10) || ?1 END
+	WHERE status = 'running' AND id != (
+		SELECT latest.id FROM sessions AS latest
+		WHERE latest.status = 'running' AND latest.user_id = sessions.user_id
+		ORDER BY latest.started_at DESC, latest.id DESC LIMIT 1
+	)`, RepairNote)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stop extra running sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// IncrementalVacuum returns free pages to the file system so the database
+// file shrinks after deletes. Databases created without incremental
+// auto-vacuum are converted first with a full VACUUM, which rewrites the
+// whole file and holds the shared connection while it runs.
+func (db *DB) IncrementalVacuum(ctx context.Context) (*VacuumResult, error) {
+	start := time.Now()
+	result := &VacuumResult{}
+
+	var mode int
+	if err := db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return nil, fmt.Errorf("failed to read auto_vacuum: %w", err)
+	}
+	before, err := db.freePages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2 is INCREMENTAL
+	if mode != 2 {
+		if _, err := db.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return nil, fmt.Errorf("failed to enable incremental vacuum: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+			return nil, fmt.Errorf("failed to vacuum database: %w", err)
+		}
+		result.Full = true
+	} else {
+		// Each step frees one page, so the rows must be drained
+		rows, err := db.QueryContext(ctx, "PRAGMA incremental_vacuum")
+		if err != nil {
+			return nil, fmt.Errorf("failed to vacuum database: %w", err)
+		}
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to vacuum database: %w", err)
+		}
+	}
+
+	after, err := db.freePages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.FreedPages = before - after
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// freePages returns the number of unused pages in the database file.
+func (db *DB) freePages(ctx context.Context) (int64, error) {
+	var n int64
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to read freelist_count: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fillAndPurge inserts about 1 MB of rows and deletes them again, leaving
+// free pages behind.
+func fillAndPurge(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	for i := 0; i < 500; i++ {
+		if _, err := db.Exec(`INSERT INTO sessions (category, task, note, started_at, status) VALUES ('work', 'task', hex(randomblob(1000)), '2024-03-01T10:00:00Z', 'stopped')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("DELETE FROM sessions"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_Checkpoint(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	fillAndPurge(t, db.DB)
+
+	result, err := db.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if result.Busy {
+		t.Error("expected checkpoint to complete")
+	}
+	info, err := os.Stat(dbPath + "-wal")
+	if err != nil {
+		t.Fatalf("failed to stat WAL: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected WAL to be truncated, got %d bytes", info.Size())
+	}
+}
+
+func TestDB_IncrementalVacuum(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	fillAndPurge(t, db.DB)
+
+	result, err := db.IncrementalVacuum(context.Background())
+	if err != nil {
+		t.Fatalf("IncrementalVacuum failed: %v", err)
+	}
+	if result.Full {
+		t.Error("new databases should not need a full vacuum")
+	}
+	if result.FreedPages <= 0 {
+		t.Errorf("expected free pages to be released, got %d", result.FreedPages)
+	}
+}
+
+func TestDB_IncrementalVacuum_ConvertsLegacyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Created before incremental auto-vacuum was enabled
+	legacy, err := sql.Open(driverName, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec("CREATE TABLE notes (body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	fillAndPurge(t, db.DB)
+
+	result, err := db.IncrementalVacuum(context.Background())
+	if err != nil {
+		t.Fatalf("IncrementalVacuum failed: %v", err)
+	}
+	if !result.Full {
+		t.Error("expected a full vacuum to convert the database")
+	}
+	if result.FreedPages <= 0 {
+		t.Errorf("expected free pages to be released, got %d", result.FreedPages)
+	}
+
+	var mode int
+	if err := db.QueryRow("PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != 2 {
+		t.Errorf("auto_vacuum = %d, want 2 (incremental)", mode)
+	}
+}
+
+func TestDB_StopExtraRunning(t *testing.T) {
+	db := NewForTesting(t)
+	ctx := context.Background()
+
+	for _, stmt := range []string{
+		"DROP INDEX idx_sessions_single_running_per_user",
+		`INSERT INTO users (id, name, created_at) VALUES (2, 'other', '2024-01-01T00:00:00Z')`,
+		`INSERT INTO sessions (category, task, note, started_at, status) VALUES ('work', 'older', 'draft', '2024-01-01T09:00:00Z', 'running')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'oldest', '2024-01-01T08:00:00Z', 'running')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'newer', '2024-01-01T10:00:00Z', 'running')`,
+		`INSERT INTO sessions (user_id, category, task, started_at, status) VALUES (2, 'work', 'other user', '2024-01-01T07:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	extra, err := db.ExtraRunningSessions(ctx)
+	if err != nil {
+		t.Fatalf("ExtraRunningSessions failed: %v", err)
+	}
+	if extra != 2 {
+		t.Fatalf("extra running sessions = %d, want 2", extra)
+	}
+
+	stopped, err := db.StopExtraRunning(ctx)
+	if err != nil {
+		t.Fatalf("StopExtraRunning failed: %v", err)
+	}
+	if stopped != 2 {
+		t.Errorf("stopped = %d, want 2", stopped)
+	}
+	if extra, err := db.ExtraRunningSessions(ctx); err != nil || extra != 0 {
+		t.Errorf("extra running sessions after repair = %d, %v, want 0", extra, err)
+	}
+
+	rows, err := db.Query("SELECT task FROM sessions WHERE status = 'running' ORDER BY task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var running []string
+	for rows.Next() {
+		var task string
+		if err := rows.Scan(&task); err != nil {
+			t.Fatal(err)
+		}
+		running = append(running, task)
+	}
+	if len(running) != 2 || running[0] != "newer" || running[1] != "other user" {
+		t.Errorf("running sessions = %v, want newer and other user", running)
+	}
+
+	var note string
+	var durationSec sql.NullInt64
+	if err := db.QueryRow("SELECT note, duration_sec FROM sessions WHERE task = 'older'").Scan(&note, &durationSec); err != nil {
+		t.Fatal(err)
+	}
+	if note != "draft\n"+RepairNote || !durationSec.Valid {
+		t.Errorf("older session note = %q, duration_sec = %v", note, durationSec)
+	}
+	if err := db.QueryRow("SELECT note FROM sessions WHERE task = 'oldest'").Scan(&note); err != nil {
+		t.Fatal(err)
+	}
+	if note != RepairNote {
+		t.Errorf("oldest session note = %q, want %q", note, RepairNote)
+	}
+}
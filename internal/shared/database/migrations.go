@@ -0,0 +1,532 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSchemaTooNew is returned by New when the database was migrated by a
+// newer version of the application than this one.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// migration is one versioned schema change.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in version order. Versions must be
+// consecutive and a released migration must never change: add a new one
+// instead.
+var migrations = []migration{
+	{version: 1, name: "initial schema", up: migrateInitialSchema},
+	{version: 2, name: "single running session", up: migrateSingleRunningSession},
+	{version: 3, name: "session timestamps", up: migrateSessionTimestamps},
+	{version: 4, name: "filtered list index", up: migrateFilteredListIndex},
+	{version: 5, name: "presets", up: migratePresets},
+	{version: 6, name: "reminders", up: migrateReminders},
+	{version: 7, name: "users", up: migrateUsers},
+	{version: 8, name: "utc session timestamps", up: migrateUTCSessionTimestamps},
+	{version: 9, name: "millisecond session timestamps", up: migrateMillisecondSessionTimestamps},
+}
+
+// migrate applies the migrations the database has not seen yet, each in its
+// own transaction together with its schema_migrations row.
+func (db *DB) migrate() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	);`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, this binary supports up to %d", ErrSchemaTooNew, current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := db.apply(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs one migration and records it, rolling both back on failure.
+func (db *DB) apply(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database, or 0 if it has never been migrated.
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// migrateInitialSchema creates the schema as it was before migrations were
+// introduced. Every statement is idempotent, so it also brings databases
+// created by older versions, which have no schema_migrations table, up to
+// version 1.
+func migrateInitialSchema(tx *sql.Tx) error {
+	// Create sessions table
+	sessionsTableSQL := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		category TEXT NOT NULL,
+		task TEXT NOT NULL,
+		note TEXT,
+		location TEXT,
+		mood TEXT,
+		started_at TEXT NOT NULL,
+		ended_at TEXT,
+		duration_sec INTEGER,
+		status TEXT NOT NULL
+	);`
+
+	if _, err := tx.Exec(sessionsTableSQL); err != nil {
+		return fmt.Errorf("failed to create sessions table: %w", err)
+	}
+
+	// Create indexes for sessions table
+	sessionsIndexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_sessions_started_at ON sessions(started_at);",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions(status);",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_category ON sessions(category);",
+	}
+
+	for _, idx := range sessionsIndexes {
+		if _, err := tx.Exec(idx); err != nil {
+			return fmt.Errorf("failed to create sessions index: %w", err)
+		}
+	}
+
+	tagsTableSQL := `
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		color TEXT NOT NULL DEFAULT '#6B7280',
+		created_at TEXT NOT NULL,
+		parent_id INTEGER REFERENCES tags(id) ON DELETE SET NULL,
+		archived INTEGER NOT NULL DEFAULT 0
+	);`
+
+	if _, err := tx.Exec(tagsTableSQL); err != nil {
+		return fmt.Errorf("failed to create tags table: %w", err)
+	}
+
+	// Databases created before tag groups and archiving existed lack these columns
+	if err := ensureColumn(tx, "tags", "parent_id", "INTEGER REFERENCES tags(id) ON DELETE SET NULL"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "tags", "archived", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	tagsIndexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_tags_name ON tags(name);",
+		"CREATE INDEX IF NOT EXISTS idx_tags_parent ON tags(parent_id);",
+	}
+
+	for _, idx := range tagsIndexes {
+		if _, err := tx.Exec(idx); err != nil {
+			return fmt.Errorf("failed to create tags index: %w", err)
+		}
+	}
+
+	sessionTagsTableSQL := `
+	CREATE TABLE IF NOT EXISTS session_tags (
+		session_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (session_id, tag_id),
+		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);`
+
+	if _, err := tx.Exec(sessionTagsTableSQL); err != nil {
+		return fmt.Errorf("failed to create session_tags table: %w", err)
+	}
+
+	sessionTagsIndexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_session_tags_session ON session_tags(session_id);",
+		"CREATE INDEX IF NOT EXISTS idx_session_tags_tag ON session_tags(tag_id);",
+	}
+
+	for _, idx := range sessionTagsIndexes {
+		if _, err := tx.Exec(idx); err != nil {
+			return fmt.Errorf("failed to create session_tags index: %w", err)
+		}
+	}
+
+	// API keys created at runtime; only a hash of each key is stored
+	apiKeysTableSQL := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scope TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		last_used_at TEXT,
+		revoked_at TEXT
+	);`
+
+	if _, err := tx.Exec(apiKeysTableSQL); err != nil {
+		return fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+
+	// Security-relevant events; details is a JSON object
+	auditLogTableSQL := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		details TEXT NOT NULL
+	);`
+
+	if _, err := tx.Exec(auditLogTableSQL); err != nil {
+		return fmt.Errorf("failed to create audit_log table: %w", err)
+	}
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp);"); err != nil {
+		return fmt.Errorf("failed to create audit_log index: %w", err)
+	}
+
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is not present yet.
+// Used to bring databases created by older versions up to the current schema.
+func ensureColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to scan %s column: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
+
+// migrateSingleRunningSession lets SQLite enforce that at most one session is
+// running. Databases that already hold several running sessions, left behind
+// by the race this index closes, keep only the most recently started one
+// running; the others are stopped now.
+func migrateSingleRunningSession(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+	UPDATE sessions SET
+		status = 'stopped',
+		ended_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
+		duration_sec = MAX(0, CAST(strftime('%s', 'now') AS INTEGER) - CAST(strftime('%s', started_at) AS INTEGER))
+	WHERE status = 'running' AND id != (
+		SELECT id FROM sessions WHERE status = 'running' ORDER BY started_at DESC, id DESC LIMIT 1
+	)`); err != nil {
+		return fmt.Errorf("failed to stop extra running sessions: %w", err)
+	}
+	if _, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_single_running
+		ON sessions(status) WHERE status = 'running'`); err != nil {
+		return fmt.Errorf("failed to create running session index: %w", err)
+	}
+	return nil
+}
+
+// migrateSessionTimestamps adds created_at and updated_at to sessions. Rows
+// from before they existed take both from started_at.
+func migrateSessionTimestamps(tx *sql.Tx) error {
+	for _, column := range []string{"created_at", "updated_at"} {
+		if err := ensureColumn(tx, "sessions", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET created_at = started_at, updated_at = started_at WHERE created_at = ''`); err != nil {
+		return fmt.Errorf("failed to backfill session timestamps: %w", err)
+	}
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_sessions_updated_at ON sessions(updated_at)"); err != nil {
+		return fmt.Errorf("failed to create updated_at index: %w", err)
+	}
+	return nil
+}
+
+// migrateFilteredListIndex adds an index matching the list filters and
+// order. With only single-column indexes, a list filtered by status and
+// category picks one of them and then sorts every match; this one yields the
+// matching rows already in started_at order, and counts for the same filters
+// never read the table.
+func migrateFilteredListIndex(tx *sql.Tx) error {
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_sessions_status_category_started_at ON sessions(status, category, started_at DESC)"); err != nil {
+		return fmt.Errorf("failed to create filtered list index: %w", err)
+	}
+	return nil
+}
+
+// migratePresets adds the quick-start presets and the tags each one gives
+// the sessions started from it.
+func migratePresets(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS presets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		category TEXT NOT NULL,
+		task TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		created_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create presets table: %w", err)
+	}
+	if _, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS preset_tags (
+		preset_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (preset_id, tag_id),
+		FOREIGN KEY (preset_id) REFERENCES presets(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	)`); err != nil {
+		return fmt.Errorf("failed to create preset_tags table: %w", err)
+	}
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_preset_tags_tag ON preset_tags(tag_id)"); err != nil {
+		return fmt.Errorf("failed to create preset_tags index: %w", err)
+	}
+	return nil
+}
+
+// migrateReminders adds long-session reminder rules and the record of which
+// rules have fired for which session. A rule with no category matches every
+// session; one with no webhook URL is only logged.
+func migrateReminders(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS reminder_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		category TEXT,
+		threshold_sec INTEGER NOT NULL,
+		webhook_url TEXT,
+		created_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create reminder_rules table: %w", err)
+	}
+	if _, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS reminder_fires (
+		session_id INTEGER NOT NULL,
+		rule_id INTEGER NOT NULL,
+		fired_at TEXT NOT NULL,
+		PRIMARY KEY (session_id, rule_id),
+		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+		FOREIGN KEY (rule_id) REFERENCES reminder_rules(id) ON DELETE CASCADE
+	)`); err != nil {
+		return fmt.Errorf("failed to create reminder_fires table: %w", err)
+	}
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_reminder_fires_rule ON reminder_fires(rule_id)"); err != nil {
+		return fmt.Errorf("failed to create reminder_fires index: %w", err)
+	}
+	return nil
+}
+
+// DefaultUserID is the user that owns every row written before users
+// existed, and whom the configured credentials sign in as.
+const DefaultUserID int64 = 1
+
+// migrateUsers adds users and gives sessions, tags, presets, API keys and
+// reminder rules an owner, backfilled with the default user. Tag names
+// become unique per user, so the tags table is rebuilt; its assignments are
+// copied aside first because dropping it cascades to them. SQLite cannot add
+// a column with both a foreign key and a non-NULL default, so user_id
+// defaults to the default user and has no foreign key; users are never
+// deleted. Only one session per user can run.
+func migrateUsers(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		password_hash TEXT,
+		created_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create users table: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO users (id, name, created_at) VALUES (?, 'default', ?)`,
+		DefaultUserID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to create default user: %w", err)
+	}
+
+	for _, table := range []string{"sessions", "presets", "api_keys", "reminder_rules"} {
+		if err := ensureColumn(tx, table, "user_id", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", DefaultUserID)); err != nil {
+			return err
+		}
+	}
+	if err := rebuildTagsWithUser(tx); err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		"DROP INDEX IF EXISTS idx_sessions_single_running",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_single_running_per_user ON sessions(user_id) WHERE status = 'running'",
+		"DROP INDEX IF EXISTS idx_sessions_status_category_started_at",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_user_status_category_started_at ON sessions(user_id, status, category, started_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_presets_user ON presets(user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_reminder_rules_user ON reminder_rules(user_id)",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to index by user: %w", err)
+		}
+	}
+	return nil
+}
+
+// rebuildTagsWithUser recreates tags with a user_id and names unique per
+// user, keeping IDs and every row that refers to a tag. It does nothing if
+// tags already has a user_id.
+func rebuildTagsWithUser(tx *sql.Tx) error {
+	var done bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM pragma_table_info('tags') WHERE name = 'user_id')").Scan(&done); err != nil {
+		return fmt.Errorf("failed to inspect tags table: %w", err)
+	}
+	if done {
+		return nil
+	}
+
+	for _, stmt := range []string{
+		"CREATE TEMP TABLE tags_copy AS SELECT id, name, color, created_at, parent_id, archived FROM tags",
+		"CREATE TEMP TABLE session_tags_copy AS SELECT session_id, tag_id FROM session_tags",
+		"CREATE TEMP TABLE preset_tags_copy AS SELECT preset_id, tag_id FROM preset_tags",
+		"DROP TABLE tags",
+		fmt.Sprintf(`CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL DEFAULT %d,
+			name TEXT NOT NULL,
+			color TEXT NOT NULL DEFAULT '#6B7280',
+			created_at TEXT NOT NULL,
+			parent_id INTEGER REFERENCES tags(id) ON DELETE SET NULL,
+			archived INTEGER NOT NULL DEFAULT 0,
+			UNIQUE (user_id, name)
+		)`, DefaultUserID),
+		// Parents can have higher IDs than their children
+		"PRAGMA defer_foreign_keys = ON",
+		"INSERT INTO tags (id, name, color, created_at, parent_id, archived) SELECT id, name, color, created_at, parent_id, archived FROM tags_copy",
+		"INSERT INTO session_tags (session_id, tag_id) SELECT session_id, tag_id FROM session_tags_copy",
+		"INSERT INTO preset_tags (preset_id, tag_id) SELECT preset_id, tag_id FROM preset_tags_copy",
+		"DROP TABLE tags_copy",
+		"DROP TABLE session_tags_copy",
+		"DROP TABLE preset_tags_copy",
+		"CREATE INDEX IF NOT EXISTS idx_tags_name ON tags(name)",
+		"CREATE INDEX IF NOT EXISTS idx_tags_parent ON tags(parent_id)",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rebuild tags table: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateUTCSessionTimestamps rewrites session timestamps stored with a UTC
+// offset, such as +08:00, in the UTC "Z" form every other row uses, to whole
+// seconds. Lists sort and filter on the text, so mixed offsets put rows out
+// of order.
+func migrateUTCSessionTimestamps(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		UPDATE sessions SET
+			started_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', started_at), started_at),
+			ended_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', ended_at), ended_at),
+			created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', created_at), created_at),
+			updated_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', updated_at), updated_at)
+		WHERE started_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', started_at), started_at)
+			OR ended_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', ended_at), ended_at)
+			OR created_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', created_at), created_at)
+			OR updated_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', updated_at), updated_at)`); err != nil {
+		return fmt.Errorf("failed to normalize session timestamps: %w", err)
+	}
+	return nil
+}
+
+// migrateMillisecondSessionTimestamps gives every session timestamp the
+// three fractional digits written since, so whole-second and millisecond
+// rows sort together.
+func migrateMillisecondSessionTimestamps(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		UPDATE sessions SET
+			started_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', started_at), started_at),
+			ended_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', ended_at), ended_at),
+			created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at),
+			updated_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', updated_at), updated_at)
+		WHERE started_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', started_at), started_at)
+			OR ended_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', ended_at), ended_at)
+			OR created_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at)
+			OR updated_at IS NOT COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', updated_at), updated_at)`); err != nil {
+		return fmt.Errorf("failed to normalize session timestamps: %w", err)
+	}
+	return nil
+}
+
+// sessionTimestampColumns are the sessions columns holding RFC3339 text.
+var sessionTimestampColumns = []string{"started_at", "ended_at", "created_at", "updated_at"}
+
+// normalizeSessionTimestamps rewrites every session timestamp SQLite can
+// parse as RFC3339 UTC with milliseconds, as config.TimestampLayout, and
+// returns how many rows changed.
+// Text SQLite cannot parse is left alone.
+func normalizeSessionTimestamps(ctx context.Context, ex interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}) (int64, error) {
+	sets := make([]string, 0, len(sessionTimestampColumns))
+	changed := make([]string, 0, len(sessionTimestampColumns))
+	for _, col := range sessionTimestampColumns {
+		utc := fmt.Sprintf("COALESCE(strftime('%%Y-%%m-%%dT%%H:%%M:%%fZ', %s), %s)", col, col)
+		sets = append(sets, col+" = "+utc)
+		changed = append(changed, col+" IS NOT "+utc)
+	}
+	result, err := ex.ExecContext(ctx, "UPDATE sessions SET "+strings.Join(sets, ", ")+" WHERE "+strings.Join(changed, " OR "))
+	if err != nil {
+		return 0, fmt.Errorf("failed to normalize session timestamps: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,387 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew_RecordsSchemaVersion(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("failed to count migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("recorded %d migrations, want %d", applied, len(migrations))
+	}
+}
+
+func TestNew_UpgradesLegacyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// A database from before migrations, tag groups and archiving existed
+	legacy, err := sql.Open(driverName, dbPath)
+	if err != nil {
+		t.Fatalf("failed to open legacy database: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			category TEXT NOT NULL,
+			task TEXT NOT NULL,
+			note TEXT,
+			location TEXT,
+			mood TEXT,
+			started_at TEXT NOT NULL,
+			ended_at TEXT,
+			duration_sec INTEGER,
+			status TEXT NOT NULL
+		)`,
+		`CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			color TEXT NOT NULL DEFAULT '#6B7280',
+			created_at TEXT NOT NULL
+		)`,
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+			VALUES ('work', 'legacy', '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped')`,
+		`INSERT INTO tags (name, created_at) VALUES ('old', '2024-01-01T00:00:00Z')`,
+	} {
+		if _, err := legacy.Exec(stmt); err != nil {
+			legacy.Close()
+			t.Fatalf("failed to build legacy schema: %v", err)
+		}
+	}
+	legacy.Close()
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open legacy database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+
+	// Existing rows survive and pick up the new column defaults
+	var task string
+	if err := db.QueryRow("SELECT task FROM sessions").Scan(&task); err != nil {
+		t.Fatalf("legacy session lost: %v", err)
+	}
+	if task != "legacy" {
+		t.Errorf("session task = %q, want %q", task, "legacy")
+	}
+	var createdAt, updatedAt string
+	if err := db.QueryRow("SELECT created_at, updated_at FROM sessions").Scan(&createdAt, &updatedAt); err != nil {
+		t.Fatalf("legacy session timestamps missing: %v", err)
+	}
+	if createdAt != "2024-01-01T09:00:00.000Z" || updatedAt != "2024-01-01T09:00:00.000Z" {
+		t.Errorf("created_at = %q, updated_at = %q, want both backfilled from started_at", createdAt, updatedAt)
+	}
+	var parentID sql.NullInt64
+	var archived int
+	if err := db.QueryRow("SELECT parent_id, archived FROM tags WHERE name = 'old'").Scan(&parentID, &archived); err != nil {
+		t.Fatalf("legacy tag not upgraded: %v", err)
+	}
+	if parentID.Valid || archived != 0 {
+		t.Errorf("legacy tag parent_id = %v, archived = %d, want NULL and 0", parentID, archived)
+	}
+
+	// Everything belongs to the default user, and tag names are unique per user
+	var sessionUser, tagUser int64
+	if err := db.QueryRow("SELECT (SELECT user_id FROM sessions), (SELECT user_id FROM tags)").Scan(&sessionUser, &tagUser); err != nil {
+		t.Fatalf("user_id not backfilled: %v", err)
+	}
+	if sessionUser != DefaultUserID || tagUser != DefaultUserID {
+		t.Errorf("session user_id = %d, tag user_id = %d, want %d", sessionUser, tagUser, DefaultUserID)
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, name, created_at) VALUES (2, 'other', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO tags (user_id, name, created_at) VALUES (2, 'old', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Errorf("same tag name for another user: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO tags (user_id, name, created_at) VALUES (2, 'old', '2024-01-01T00:00:00Z')`); !IsUniqueViolation(err) {
+		t.Errorf("duplicate tag name for one user: err = %v, want a unique violation", err)
+	}
+
+	for _, table := range []string{"session_tags", "api_keys", "audit_log", "users"} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&n); err != nil {
+			t.Fatalf("failed to check %s table: %v", table, err)
+		}
+		if n != 1 {
+			t.Errorf("%s table was not created", table)
+		}
+	}
+}
+
+func TestNew_RefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	future := migrations[len(migrations)-1].version + 1
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from the future', '2030-01-01T00:00:00Z')", future); err != nil {
+		t.Fatalf("failed to insert migration row: %v", err)
+	}
+	db.Close()
+
+	db, err = New(dbPath)
+	if err == nil {
+		db.Close()
+		t.Fatal("expected New to refuse a newer schema")
+	}
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	db.Close()
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	next := saved[len(saved)-1].version + 1
+	migrations = append(append([]migration{}, saved...), migration{
+		version: next,
+		name:    "broken",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+
+	if db, err := New(dbPath); err == nil {
+		db.Close()
+		t.Fatal("expected the broken migration to fail")
+	}
+
+	// Nothing from the failed migration is left behind
+	migrations = saved
+	db, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&n); err != nil {
+		t.Fatalf("failed to check table: %v", err)
+	}
+	if n != 0 {
+		t.Error("table from the failed migration was not rolled back")
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != saved[len(saved)-1].version {
+		t.Errorf("schema version = %d, want %d", version, saved[len(saved)-1].version)
+	}
+}
+
+func TestMigrate_SessionTimestampVersions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+
+	// Open the database at each version in turn, reading back started_at
+	startedAt := func(upTo int) string {
+		t.Helper()
+		migrations = saved[:upTo]
+		db, err := New(dbPath)
+		if err != nil {
+			t.Fatalf("failed to open database at version %d: %v", upTo, err)
+		}
+		defer db.Close()
+		var got string
+		if err := db.QueryRow("SELECT started_at FROM sessions").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// A row written before version 8, with a UTC offset
+	migrations = saved[:7]
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO sessions (category, task, started_at, status, created_at, updated_at)
+		VALUES ('work', 'offset', '2024-01-01T17:00:00.250+08:00', 'running', '2024-01-01T09:00:00Z', '2024-01-01T09:00:00Z')`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 8 stays as released: UTC to whole seconds
+	if got := startedAt(8); got != "2024-01-01T09:00:00Z" {
+		t.Errorf("after version 8 started_at = %q, want whole seconds in UTC", got)
+	}
+	if got := startedAt(9); got != "2024-01-01T09:00:00.000Z" {
+		t.Errorf("after version 9 started_at = %q, want milliseconds", got)
+	}
+}
+
+func TestNew_SingleRunningSessionIndex(t *testing.T) {
+	db := NewForTesting(t)
+
+	insert := `INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'task', '2024-01-01T09:00:00Z', 'running')`
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatalf("failed to insert running session: %v", err)
+	}
+	_, err := db.Exec(insert)
+	if !IsUniqueViolation(err) {
+		t.Fatalf("second running session error = %v, want a unique violation", err)
+	}
+
+	// Another user can have a session running at the same time
+	if _, err := db.Exec(`INSERT INTO users (id, name, created_at) VALUES (2, 'other', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (user_id, category, task, started_at, status) VALUES (2, 'work', 'task', '2024-01-01T09:00:00Z', 'running')`); err != nil {
+		t.Fatalf("running session for another user: %v", err)
+	}
+
+	// Any number of stopped sessions is fine
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+			VALUES ('work', 'done', '2024-01-01T08:00:00Z', '2024-01-01T08:30:00Z', 1800, 'stopped')`); err != nil {
+			t.Fatalf("failed to insert stopped session: %v", err)
+		}
+	}
+}
+
+func TestNew_StopsExtraRunningSessions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	// Roll back to before the index existed and recreate the race's aftermath
+	for _, stmt := range []string{
+		"DROP INDEX idx_sessions_single_running_per_user",
+		"DELETE FROM schema_migrations WHERE version >= 2",
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'older', '2024-01-01T09:00:00Z', 'running')`,
+		`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'newer', '2024-01-01T10:00:00Z', 'running')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	db, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	var task string
+	if err := db.QueryRow("SELECT task FROM sessions WHERE status = 'running'").Scan(&task); err != nil {
+		t.Fatalf("failed to read running session: %v", err)
+	}
+	if task != "newer" {
+		t.Errorf("running session = %q, want %q", task, "newer")
+	}
+	var endedAt sql.NullString
+	var durationSec sql.NullInt64
+	if err := db.QueryRow("SELECT ended_at, duration_sec FROM sessions WHERE task = 'older'").Scan(&endedAt, &durationSec); err != nil {
+		t.Fatalf("failed to read stopped session: %v", err)
+	}
+	if !endedAt.Valid || !durationSec.Valid || durationSec.Int64 <= 0 {
+		t.Errorf("older session ended_at = %v, duration_sec = %v, want both set", endedAt, durationSec)
+	}
+}
+
+func TestNew_UsersKeepTagAssignments(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// A database from just before users existed
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = saved[:6]
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO sessions (id, category, task, started_at, status) VALUES (1, 'work', 'task', '2024-01-01T09:00:00Z', 'running')`,
+		`INSERT INTO tags (id, name, created_at) VALUES (1, 'child', '2024-01-01T00:00:00Z'), (2, 'parent', '2024-01-01T00:00:00Z')`,
+		`UPDATE tags SET parent_id = 2 WHERE id = 1`,
+		`INSERT INTO session_tags (session_id, tag_id) VALUES (1, 1), (1, 2)`,
+		`INSERT INTO presets (id, name, category, task, position, created_at) VALUES (1, 'p', 'work', 'task', 0, '2024-01-01T00:00:00Z')`,
+		`INSERT INTO preset_tags (preset_id, tag_id) VALUES (1, 2)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	migrations = saved
+	db, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	defer db.Close()
+
+	var sessionTags, presetTags int
+	var parentID int64
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM session_tags), (SELECT COUNT(*) FROM preset_tags),
+		(SELECT parent_id FROM tags WHERE id = 1)`).Scan(&sessionTags, &presetTags, &parentID); err != nil {
+		t.Fatal(err)
+	}
+	if sessionTags != 2 || presetTags != 1 || parentID != 2 {
+		t.Errorf("session tags = %d, preset tags = %d, parent = %d; want 2, 1 and 2", sessionTags, presetTags, parentID)
+	}
+
+	// Foreign keys still point at the rebuilt table
+	if _, err := db.Exec("DELETE FROM tags WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM session_tags), (SELECT COUNT(*) FROM preset_tags)`).Scan(&sessionTags, &presetTags); err != nil {
+		t.Fatal(err)
+	}
+	var orphaned sql.NullInt64
+	if err := db.QueryRow("SELECT parent_id FROM tags WHERE id = 1").Scan(&orphaned); err != nil {
+		t.Fatal(err)
+	}
+	if sessionTags != 1 || presetTags != 0 || orphaned.Valid {
+		t.Errorf("after deleting the parent: session tags = %d, preset tags = %d, parent = %v", sessionTags, presetTags, orphaned)
+	}
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReader_ReadOnlyPool(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if db.Reader() == db.DB {
+		t.Fatal("expected a separate read pool for a file database")
+	}
+	if _, err := db.Reader().Exec(`INSERT INTO tags (name, color, created_at) VALUES ('x', '#000000', '2024-01-01T00:00:00Z')`); err == nil {
+		t.Fatal("expected writes through the read pool to fail")
+	}
+
+	var fk, busy int
+	if err := db.Reader().QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reader().QueryRow("PRAGMA busy_timeout").Scan(&busy); err != nil {
+		t.Fatal(err)
+	}
+	if fk != 1 || busy != int(DefaultBusyTimeout.Milliseconds()) {
+		t.Errorf("read connection foreign_keys = %d, busy_timeout = %d", fk, busy)
+	}
+
+	// A read left open does not hold up the writer, and later reads see the write
+	rows, err := db.Reader().Query("SELECT id FROM sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if _, err := db.Exec(`INSERT INTO sessions (category, task, started_at, status) VALUES ('work', 'task', '2024-01-01T09:00:00Z', 'running')`); err != nil {
+		t.Fatalf("write blocked by an open read: %v", err)
+	}
+	var count int
+	if err := db.Reader().QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("read pool sees %d sessions, want 1", count)
+	}
+}
+
+func TestReader_SharedConnection(t *testing.T) {
+	if db := NewForTesting(t); db.Reader() != db.DB {
+		t.Error("expected an in-memory database to read through its only connection")
+	}
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{ReadConns: -1})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if db.Reader() != db.DB {
+		t.Error("expected ReadConns < 0 to disable the read pool")
+	}
+}
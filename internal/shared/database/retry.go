@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// retryBackoff is how long to wait before each retry of an operation that
+// failed because the database was locked. The busy timeout has already been
+// spent waiting by then, so a few spaced-out attempts are enough.
+var retryBackoff = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	250 * time.Millisecond,
+}
+
+// IsBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED: another
+// connection held a lock for longer than the busy timeout, or a WAL snapshot
+// went stale. Such operations can succeed when retried. It matches on the
+// messages both SQLite drivers share.
+func IsBusy(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "SQLITE_BUSY")
+}
+
+// Retry calls fn until it returns an error that is not IsBusy, retrying
+// with backoff a few times before giving up with the last error.
+func Retry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if !IsBusy(err) || attempt == len(retryBackoff) {
+			return err
+		}
+
+		timer := time.NewTimer(retryBackoff[attempt])
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// ExecRetry is like ExecContext but retries while the database is locked.
+func (db *DB) ExecRetry(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := Retry(ctx, func() error {
+		var err error
+		result, err = db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. If the database is locked the whole transaction is
+// retried, so fn must be safe to run more than once and should only return
+// values through variables it assigns on every run.
+func (db *DB) InTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return Retry(ctx, func() error {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer conn.Close()
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			// A failed COMMIT, such as one a deferred foreign key stops,
+			// leaves the transaction open. mattn/go-sqlite3 rolls it back but
+			// modernc.org/sqlite does not, and the next user of the
+			// connection would see its writes.
+			conn.ExecContext(context.Background(), "ROLLBACK")
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	})
+}
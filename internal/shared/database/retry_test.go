@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsBusy(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		busy bool
+	}{
+		{nil, false},
+		{errors.New("database is locked"), true},
+		{fmt.Errorf("failed to insert session: %w", errors.New("database is locked (5) (SQLITE_BUSY)")), true},
+		{errors.New("database table is locked"), true},
+		{errors.New("UNIQUE constraint failed: tags.name"), false},
+	} {
+		if got := IsBusy(tt.err); got != tt.busy {
+			t.Errorf("IsBusy(%v) = %v, want %v", tt.err, got, tt.busy)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	busy := errors.New("database is locked")
+
+	calls := 0
+	err := Retry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	// Gives up with the last error
+	calls = 0
+	err = Retry(context.Background(), func() error {
+		calls++
+		return busy
+	})
+	if err != busy || calls != len(retryBackoff)+1 {
+		t.Errorf("Retry = %v after %d calls, want busy after %d", err, calls, len(retryBackoff)+1)
+	}
+
+	// Other errors are not retried
+	calls = 0
+	other := errors.New("no such table")
+	if err := Retry(context.Background(), func() error { calls++; return other }); err != other || calls != 1 {
+		t.Errorf("Retry = %v after %d calls, want the error after 1", err, calls)
+	}
+}
+
+func TestOpen_BusyTimeout(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{BusyTimeout: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 1500 {
+		t.Errorf("busy_timeout = %d, want 1500", timeout)
+	}
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// DefaultStmtCacheSize is how many prepared statements each pool keeps.
+const DefaultStmtCacheSize = 64
+
+// stmtCache keeps prepared statements for one pool by their SQL, so hot
+// queries are compiled once rather than on every call. Beyond max the least
+// recently used statement is closed, which bounds the cache for queries
+// built from filters. Statements are counted while in use and an evicted one
+// is only closed once the last user releases it.
+type stmtCache struct {
+	db  *sql.DB
+	max int
+
+	mu     sync.Mutex
+	order  *list.List // of *cachedStmt, most recently used first
+	stmts  map[string]*list.Element
+	closed bool
+}
+
+// cachedStmt is one prepared statement and how many callers are using it.
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+}
+
+// newStmtCache returns a cache for db holding up to max statements, or nil
+// if max is not positive. A nil cache prepares nothing.
+func newStmtCache(db *sql.DB, max int) *stmtCache {
+	if max <= 0 {
+		return nil
+	}
+	return &stmtCache{db: db, max: max, order: list.New(), stmts: map[string]*list.Element{}}
+}
+
+// acquire returns the statement for query, preparing it on first use. The
+// caller must release it.
+func (c *stmtCache) acquire(ctx context.Context, query string) (*cachedStmt, error) {
+	c.mu.Lock()
+	if el, ok := c.stmts[query]; ok {
+		c.order.MoveToFront(el)
+		s := el.Value.(*cachedStmt)
+		s.users++
+		c.mu.Unlock()
+		return s, nil
+	}
+	c.mu.Unlock()
+
+	// Prepare without the lock, so other queries are not held up
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return &cachedStmt{query: query, stmt: stmt, users: 1, evicted: true}, nil
+	}
+	if el, ok := c.stmts[query]; ok {
+		// Another caller prepared it first
+		c.order.MoveToFront(el)
+		s := el.Value.(*cachedStmt)
+		s.users++
+		c.mu.Unlock()
+		stmt.Close()
+		return s, nil
+	}
+	s := &cachedStmt{query: query, stmt: stmt, users: 1}
+	c.stmts[query] = c.order.PushFront(s)
+	var unused []*sql.Stmt
+	for c.order.Len() > c.max {
+		old := c.order.Remove(c.order.Back()).(*cachedStmt)
+		delete(c.stmts, old.query)
+		old.evicted = true
+		if old.users == 0 {
+			unused = append(unused, old.stmt)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, stmt := range unused {
+		stmt.Close()
+	}
+	return s, nil
+}
+
+// release ends a use of s, closing it if it was evicted meanwhile.
+func (c *stmtCache) release(s *cachedStmt) {
+	c.mu.Lock()
+	s.users--
+	unused := s.evicted && s.users == 0
+	c.mu.Unlock()
+
+	if unused {
+		s.stmt.Close()
+	}
+}
+
+// Len returns the number of cached statements.
+func (c *stmtCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// close closes every statement not in use and marks the rest to be closed
+// on release. Statements prepared afterwards are not cached.
+func (c *stmtCache) close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.closed = true
+	var unused []*sql.Stmt
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		s := el.Value.(*cachedStmt)
+		s.evicted = true
+		if s.users == 0 {
+			unused = append(unused, s.stmt)
+		}
+	}
+	c.order.Init()
+	c.stmts = map[string]*list.Element{}
+	c.mu.Unlock()
+
+	for _, stmt := range unused {
+		stmt.Close()
+	}
+}
+
+// Stmt returns a prepared statement for query on the write connection, for
+// use in a transaction through tx.StmtContext. Get it before beginning the
+// transaction: preparing needs the write connection, which the transaction
+// holds. The returned func must be called once the statement is no longer
+// used. Without a statement cache the statement is prepared for this use
+// only and closed by the func.
+func (db *DB) Stmt(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	return prepare(ctx, db.stmts, db.DB, query)
+}
+
+// ReadStmt is like Stmt but prepares query on the read pool.
+func (db *DB) ReadStmt(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	return prepare(ctx, db.readStmts, db.read, query)
+}
+
+// prepare returns the statement for query from cache, or one prepared on db
+// for a single use if there is no cache.
+func prepare(ctx context.Context, cache *stmtCache, db *sql.DB, query string) (*sql.Stmt, func(), error) {
+	if cache == nil {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stmt, func() { stmt.Close() }, nil
+	}
+	s, err := cache.acquire(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.stmt, func() { cache.release(s) }, nil
+}
+
+// QueryCached is like Reader().QueryContext but runs a cached prepared
+// statement. Use it for queries run often enough to be worth keeping.
+func (db *DB) QueryCached(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if db.readStmts == nil {
+		return db.read.QueryContext(ctx, query, args...)
+	}
+	s, err := db.readStmts.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer db.readStmts.release(s)
+	return s.stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowCached is like Reader().QueryRowContext but runs a cached
+// prepared statement.
+func (db *DB) QueryRowCached(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if db.readStmts == nil {
+		return db.read.QueryRowContext(ctx, query, args...)
+	}
+	s, err := db.readStmts.acquire(ctx, query)
+	if err != nil {
+		// Let the plain query report the error through Scan
+		return db.read.QueryRowContext(ctx, query, args...)
+	}
+	defer db.readStmts.release(s)
+	return s.stmt.QueryRowContext(ctx, args...)
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStmtCache_ReusesAndEvicts(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{StmtCacheSize: 2})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	first, release, err := db.ReadStmt(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, releaseAgain, err := db.ReadStmt(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseAgain()
+	if again != first {
+		t.Error("expected the same query to reuse its statement")
+	}
+
+	// Two more queries push SELECT 1 out while it is still in use
+	for _, query := range []string{"SELECT 2", "SELECT 3"} {
+		var n int
+		if err := db.QueryRowCached(ctx, query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if n := db.readStmts.Len(); n != 2 {
+		t.Errorf("cache holds %d statements, want 2", n)
+	}
+	var n int
+	if err := first.QueryRowContext(ctx).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("evicted statement in use failed: %d, %v", n, err)
+	}
+	release()
+	if err := first.QueryRowContext(ctx).Scan(&n); err == nil {
+		t.Error("expected the evicted statement to be closed once released")
+	}
+
+	// The write connection has its own cache, usable in a transaction
+	insert, releaseInsert, err := db.Stmt(ctx, `INSERT INTO tags (name, color, created_at) VALUES (?, '#000000', '2024-01-01T00:00:00Z')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseInsert()
+	if err := db.InTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.StmtContext(ctx, insert).ExecContext(ctx, "prepared")
+		return err
+	}); err != nil {
+		t.Fatalf("prepared insert in a transaction: %v", err)
+	}
+}
+
+func TestStmtCache_Disabled(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{StmtCacheSize: -1})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	stmt, release, err := db.ReadStmt(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	var n int
+	if err := stmt.QueryRow().Scan(&n); err == nil {
+		t.Error("expected an uncached statement to be closed on release")
+	}
+	if err := db.QueryRowCached(context.Background(), "SELECT 2").Scan(&n); err != nil || n != 2 {
+		t.Errorf("uncached query = %d, %v", n, err)
+	}
+}
+
+// TestStmtCache_Concurrent shares a small cache between goroutines, so
+// statements are evicted while others use them. Run it with -race.
+func TestStmtCache_Concurrent(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{StmtCacheSize: 3})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				want := (g + i) % 8
+				var got int
+				if err := db.QueryRowCached(ctx, fmt.Sprintf("SELECT %d", want)).Scan(&got); err != nil || got != want {
+					errs <- fmt.Errorf("SELECT %d = %d, %v", want, got, err)
+					return
+				}
+				if g%4 == 0 {
+					stmt, release, err := db.Stmt(ctx, fmt.Sprintf("SELECT %d + ?", want))
+					if err != nil {
+						errs <- err
+						return
+					}
+					err = db.InTx(ctx, func(tx *sql.Tx) error {
+						return tx.StmtContext(ctx, stmt).QueryRowContext(ctx, 1).Scan(&got)
+					})
+					release()
+					if err != nil || got != want+1 {
+						errs <- fmt.Errorf("SELECT %d + 1 = %d, %v", want, got, err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := db.readStmts.Len() + db.stmts.Len(); n != 0 {
+		t.Errorf("%d statements left cached after Close", n)
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -42,3 +43,42 @@ func TestNew_CreatesTagsTables(t *testing.T) {
 		t.Error("session_tags table was not created")
 	}
 }
+
+func TestNew_AddsParentIDToLegacyTagsTable(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "timetracker-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	// Simulate a database created before tag groups existed
+	legacy, err := sql.Open(driverName, dbPath)
+	if err != nil {
+		t.Fatalf("failed to open legacy database: %v", err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		color TEXT NOT NULL DEFAULT '#6B7280',
+		created_at TEXT NOT NULL
+	)`); err != nil {
+		t.Fatalf("failed to create legacy tags table: %v", err)
+	}
+	legacy.Close()
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open legacy database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('tags') WHERE name = 'parent_id'").Scan(&count); err != nil {
+		t.Fatalf("failed to inspect tags table: %v", err)
+	}
+	if count != 1 {
+		t.Fatal("parent_id column was not added to legacy tags table")
+	}
+}
@@ -0,0 +1,16 @@
+package database
+
+import "testing"
+
+// NewForTesting returns a migrated in-memory database that is closed when the
+// test finishes.
+func NewForTesting(t testing.TB) *DB {
+	t.Helper()
+
+	db, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,16 @@
+package database
+
+import (
+	"context"
+
+	"time-tracker/internal/shared/tracing"
+)
+
+// StartSpan starts a span for the named database statement as a child of
+// the span in ctx. The caller must End it.
+func StartSpan(ctx context.Context, statement string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "sqlite "+statement, tracing.SpanKindClient)
+	span.SetAttribute("db.system", "sqlite")
+	span.SetAttribute("db.operation.name", statement)
+	return ctx, span
+}
@@ -3,8 +3,12 @@ package errors
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"time-tracker/internal/sessions/models"
 )
 
 // TimeTrackerError is the base error type for all application errors.
@@ -12,6 +16,8 @@ type TimeTrackerError struct {
 	Code       string `json:"code"`
 	Message    string `json:"message"`
 	StatusCode int    `json:"-"`
+	// Fields maps each input field that failed validation to its message
+	Fields map[string]string `json:"-"`
 }
 
 // Error implements the error interface.
@@ -26,9 +32,10 @@ type ErrorResponse struct {
 
 // ErrorDetail contains the error details.
 type ErrorDetail struct {
-	Code           string                 `json:"code"`
-	Message        string                 `json:"message"`
-	CurrentSession map[string]interface{} `json:"current_session,omitempty"`
+	Code           string                  `json:"code"`
+	Message        string                  `json:"message"`
+	Fields         map[string]string       `json:"fields,omitempty"`
+	CurrentSession *models.SessionResponse `json:"current_session,omitempty"`
 }
 
 // ValidationError represents a 400 Bad Request error for invalid input.
@@ -40,6 +47,19 @@ func ValidationError(message string) *TimeTrackerError {
 	}
 }
 
+// ValidationErrorFor is a ValidationError for err, an input validation
+// error as services return it, prefixed "validation error: ". The prefix is
+// dropped from the message, and if err is or wraps a
+// models.ValidationErrors, the fields it names are listed in Fields.
+func ValidationErrorFor(err error) *TimeTrackerError {
+	e := ValidationError(strings.TrimPrefix(err.Error(), "validation error: "))
+	var fieldErrs models.ValidationErrors
+	if stderrors.As(err, &fieldErrs) {
+		e.Fields = fieldErrs.Fields()
+	}
+	return e
+}
+
 // NotFoundError represents a 404 Not Found error.
 func NotFoundError(message string) *TimeTrackerError {
 	return &TimeTrackerError{
@@ -52,11 +72,12 @@ func NotFoundError(message string) *TimeTrackerError {
 // ConflictError represents a 409 Conflict error.
 type ConflictError struct {
 	*TimeTrackerError
-	CurrentSession map[string]interface{}
+	CurrentSession *models.SessionResponse
 }
 
-// NewConflictError creates a new conflict error with optional current session info.
-func NewConflictError(message string, currentSession map[string]interface{}) *ConflictError {
+// NewConflictError creates a new conflict error with the running session
+// that caused it, if any.
+func NewConflictError(message string, currentSession *models.SessionResponse) *ConflictError {
 	return &ConflictError{
 		TimeTrackerError: &TimeTrackerError{
 			Code:       "CONFLICT",
@@ -67,6 +88,25 @@ func NewConflictError(message string, currentSession map[string]interface{}) *Co
 	}
 }
 
+// MethodNotAllowedError represents a 405 Method Not Allowed error.
+type MethodNotAllowedError struct {
+	*TimeTrackerError
+	Allow []string
+}
+
+// NewMethodNotAllowedError creates a method not allowed error listing the
+// methods the path accepts, which are sent in the Allow header.
+func NewMethodNotAllowedError(allow ...string) *MethodNotAllowedError {
+	return &MethodNotAllowedError{
+		TimeTrackerError: &TimeTrackerError{
+			Code:       "METHOD_NOT_ALLOWED",
+			Message:    "Method not allowed",
+			StatusCode: http.StatusMethodNotAllowed,
+		},
+		Allow: allow,
+	}
+}
+
 // RateLimitError represents a 429 Too Many Requests error.
 type RateLimitError struct {
 	*TimeTrackerError
@@ -94,6 +134,15 @@ func UnauthorizedError(message string) *TimeTrackerError {
 	}
 }
 
+// ForbiddenError represents a 403 Forbidden error.
+func ForbiddenError(message string) *TimeTrackerError {
+	return &TimeTrackerError{
+		Code:       "FORBIDDEN",
+		Message:    message,
+		StatusCode: http.StatusForbidden,
+	}
+}
+
 // InternalError represents a 500 Internal Server Error.
 // Note: This should NOT expose internal details to the client.
 func InternalError() *TimeTrackerError {
@@ -104,52 +153,47 @@ func InternalError() *TimeTrackerError {
 	}
 }
 
-// WriteError writes an error response to the HTTP response writer.
-// It ensures no internal details are exposed in the response.
-func WriteError(w http.ResponseWriter, err error) {
-	var statusCode int
-	var response ErrorResponse
-
+// Detail returns the status code and response details for err. Errors that
+// are not application errors become a generic internal error, so no
+// internal details reach the client.
+func Detail(err error) (int, ErrorDetail) {
 	switch e := err.(type) {
 	case *ConflictError:
-		statusCode = e.StatusCode
-		response = ErrorResponse{
-			Error: ErrorDetail{
-				Code:           e.Code,
-				Message:        e.Message,
-				CurrentSession: e.CurrentSession,
-			},
+		return e.StatusCode, ErrorDetail{
+			Code:           e.Code,
+			Message:        e.Message,
+			CurrentSession: e.CurrentSession,
 		}
 	case *RateLimitError:
-		statusCode = e.StatusCode
-		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
-		response = ErrorResponse{
-			Error: ErrorDetail{
-				Code:    e.Code,
-				Message: e.Message,
-			},
-		}
+		return e.StatusCode, ErrorDetail{Code: e.Code, Message: e.Message}
+	case *MethodNotAllowedError:
+		return e.StatusCode, ErrorDetail{Code: e.Code, Message: e.Message}
 	case *TimeTrackerError:
-		statusCode = e.StatusCode
-		response = ErrorResponse{
-			Error: ErrorDetail{
-				Code:    e.Code,
-				Message: e.Message,
-			},
-		}
+		return e.StatusCode, ErrorDetail{Code: e.Code, Message: e.Message, Fields: e.Fields}
 	default:
-		// For unknown errors, return a generic internal error
-		// to avoid exposing internal details
-		statusCode = http.StatusInternalServerError
-		response = ErrorResponse{
-			Error: ErrorDetail{
-				Code:    "INTERNAL_ERROR",
-				Message: "An internal error occurred",
-			},
-		}
+		internal := InternalError()
+		return internal.StatusCode, ErrorDetail{Code: internal.Code, Message: internal.Message}
+	}
+}
+
+// SetHeaders sets the response headers that go with err: Retry-After for a
+// rate limit error and Allow for a method not allowed error.
+func SetHeaders(w http.ResponseWriter, err error) {
+	switch e := err.(type) {
+	case *RateLimitError:
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	case *MethodNotAllowedError:
+		w.Header().Set("Allow", strings.Join(e.Allow, ", "))
 	}
+}
+
+// WriteError writes an error response to the HTTP response writer.
+// It ensures no internal details are exposed in the response.
+func WriteError(w http.ResponseWriter, err error) {
+	SetHeaders(w, err)
+	statusCode, detail := Detail(err)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: detail})
 }
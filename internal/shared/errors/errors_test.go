@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"time-tracker/internal/sessions/models"
 )
 
 func TestValidationError(t *testing.T) {
@@ -32,10 +34,7 @@ func TestNotFoundError(t *testing.T) {
 }
 
 func TestConflictError(t *testing.T) {
-	session := map[string]interface{}{
-		"id":   1,
-		"task": "test task",
-	}
+	session := &models.SessionResponse{ID: 1, Task: "test task"}
 	err := NewConflictError("session already running", session)
 	if err.Code != "CONFLICT" {
 		t.Errorf("expected code CONFLICT, got %s", err.Code)
@@ -43,7 +42,7 @@ func TestConflictError(t *testing.T) {
 	if err.StatusCode != http.StatusConflict {
 		t.Errorf("expected status 409, got %d", err.StatusCode)
 	}
-	if err.CurrentSession["id"] != 1 {
+	if err.CurrentSession == nil || err.CurrentSession.ID != 1 {
 		t.Error("expected current session to contain id")
 	}
 }
@@ -107,3 +106,44 @@ func TestWriteError_UnknownError(t *testing.T) {
 		t.Errorf("expected generic message, got %s", response.Error.Message)
 	}
 }
+
+func TestWriteError_MethodNotAllowed(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteError(rr, NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rr.Code)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "GET, POST" {
+		t.Errorf("expected Allow 'GET, POST', got %q", allow)
+	}
+
+	var response ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Error.Code != "METHOD_NOT_ALLOWED" {
+		t.Errorf("expected code METHOD_NOT_ALLOWED, got %s", response.Error.Code)
+	}
+}
+
+func TestDetail(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{ValidationError("bad"), http.StatusBadRequest, "VALIDATION_ERROR"},
+		{NewConflictError("busy", nil), http.StatusConflict, "CONFLICT"},
+		{NewRateLimitError(5), http.StatusTooManyRequests, "RATE_LIMITED"},
+		{NewMethodNotAllowedError(http.MethodGet), http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+		{errors.New("disk on fire"), http.StatusInternalServerError, "INTERNAL_ERROR"},
+	}
+	for _, tt := range tests {
+		status, detail := Detail(tt.err)
+		if status != tt.status || detail.Code != tt.code {
+			t.Errorf("Detail(%v) = %d %s, want %d %s", tt.err, status, detail.Code, tt.status, tt.code)
+		}
+	}
+	if _, detail := Detail(errors.New("disk on fire")); detail.Message != "An internal error occurred" {
+		t.Errorf("internal error message leaked: %q", detail.Message)
+	}
+}
@@ -1,21 +1,66 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"time-tracker/internal/shared/buildinfo"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/database"
 )
 
 // HealthResponse represents the health check response.
 type HealthResponse struct {
 	OK bool `json:"ok"`
+	// DB is "ok" or the database error; only set by the readiness check
+	DB string `json:"db,omitempty"`
+	// Backup is "ok" or the last scheduled backup error; only set by the
+	// readiness check when scheduled backups are enabled
+	Backup string `json:"backup,omitempty"`
+	// Integrity is "ok" or the problems found by the last database integrity
+	// check, which was run at IntegrityCheckedAt; only set by the readiness
+	// check
+	Integrity          string `json:"integrity,omitempty"`
+	IntegrityCheckedAt string `json:"integrity_checked_at,omitempty"`
+	// Running is "ok" or how many running sessions there are beyond one per
+	// user; only set by the readiness check
+	Running string `json:"running,omitempty"`
+}
+
+// VersionResponse represents the version response.
+type VersionResponse struct {
+	buildinfo.Info
+	UptimeSec int64 `json:"uptime_sec"`
 }
 
 // HealthHandler handles HTTP requests for health checks.
-type HealthHandler struct{}
+type HealthHandler struct {
+	db      *database.DB
+	started time.Time
+	backup  func() error
+
+	// The integrity check reads the whole file, so its result is cached
+	integrityMu        sync.Mutex
+	integrityErr       error
+	integrityCheckedAt time.Time
+}
+
+// NewHealthHandler creates a new HealthHandler. The database is only used by
+// the readiness check.
+func NewHealthHandler(db *database.DB) *HealthHandler {
+	return &HealthHandler{db: db, started: time.Now()}
+}
 
-// NewHealthHandler creates a new HealthHandler.
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// SetBackupCheck makes the readiness check fail while check reports an
+// error, so a failing scheduled backup is visible to monitoring.
+func (h *HealthHandler) SetBackupCheck(check func() error) {
+	h.backup = check
 }
 
 // Check handles GET /healthz - returns health status.
@@ -31,11 +76,140 @@ func (h *HealthHandler) Check(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(HealthResponse{OK: true})
 }
 
-// ServeHTTP implements http.Handler for the health endpoint.
+// Ready handles GET /readyz - reports whether the database is usable,
+// passes its integrity check and holds at most one running session per user
+// and, if scheduled backups are enabled, whether the last one succeeded.
+// Returns 503 with the error when any is not.
+// This endpoint does not require authentication.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := HealthResponse{OK: true, DB: "ok"}
+	status := http.StatusOK
+	if err := h.checkDB(r.Context()); err != nil {
+		resp = HealthResponse{OK: false, DB: err.Error()}
+		status = http.StatusServiceUnavailable
+	} else {
+		checkedAt, err := h.checkIntegrity(r.Context())
+		resp.Integrity = "ok"
+		resp.IntegrityCheckedAt = checkedAt.UTC().Format(time.RFC3339)
+		if err != nil {
+			resp.OK = false
+			resp.Integrity = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+		resp.Running = "ok"
+		if err := h.checkRunning(r.Context()); err != nil {
+			resp.OK = false
+			resp.Running = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if h.backup != nil {
+		resp.Backup = "ok"
+		if err := h.backup(); err != nil {
+			resp.OK = false
+			resp.Backup = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Version handles GET /version - returns the build metadata and uptime.
+// This endpoint does not require authentication.
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VersionResponse{
+		Info:      buildinfo.Get(),
+		UptimeSec: int64(time.Since(h.started).Seconds()),
+	})
+}
+
+// checkDB pings the database and runs a trivial query. It also checks that
+// the database file still exists, since SQLite keeps working on a deleted
+// file until the process restarts.
+func (h *HealthHandler) checkDB(ctx context.Context) error {
+	if h.db == nil {
+		return fmt.Errorf("database not configured")
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.ReadinessTimeout)
+	defer cancel()
+
+	if err := h.db.PingContext(ctx); err != nil {
+		return err
+	}
+	var one int
+	if err := h.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return err
+	}
+	// In-memory databases and URI filenames have no file to check
+	if path := h.db.Path(); path != ":memory:" && !strings.HasPrefix(path, "file:") {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("database file: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkRunning fails when some user has more than one running session,
+// which the startup repair clears but nothing prevents afterwards if the
+// unique index is gone.
+func (h *HealthHandler) checkRunning(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, config.ReadinessTimeout)
+	defer cancel()
+
+	extra, err := h.db.ExtraRunningSessions(ctx)
+	if err != nil {
+		return err
+	}
+	if extra > 0 {
+		return fmt.Errorf("%d extra running sessions", extra)
+	}
+	return nil
+}
+
+// checkIntegrity returns the cached integrity check result, running the
+// check again once it is older than config.IntegrityCheckInterval. Requests
+// arriving while it runs wait for it rather than starting their own.
+func (h *HealthHandler) checkIntegrity(ctx context.Context) (time.Time, error) {
+	h.integrityMu.Lock()
+	defer h.integrityMu.Unlock()
+
+	if h.integrityCheckedAt.IsZero() || time.Since(h.integrityCheckedAt) >= config.IntegrityCheckInterval {
+		err := h.db.CheckIntegrity(ctx, h.db.FullIntegrityCheck())
+		// A check cut short by the client going away says nothing
+		if ctx.Err() != nil {
+			return h.integrityCheckedAt, ctx.Err()
+		}
+		h.integrityErr = err
+		h.integrityCheckedAt = time.Now()
+	}
+	return h.integrityCheckedAt, h.integrityErr
+}
+
+// ServeHTTP implements http.Handler for the health endpoints.
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/healthz" {
+	switch r.URL.Path {
+	case "/healthz":
 		h.Check(w, r)
-		return
+	case "/readyz":
+		h.Ready(w, r)
+	case "/version":
+		h.Version(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
 	}
-	w.WriteHeader(http.StatusNotFound)
 }
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ClientIPMiddleware resolves the client address of each request once, for
+// the rate limiter, allowlist, request log and audit log. Forwarding headers
+// are only honored when the connection comes from one of the trusted proxies:
+// the X-Forwarded-For chain is walked from the right, skipping trusted hops,
+// and the first untrusted hop is the client. X-Real-IP is used when there is
+// no X-Forwarded-For. With no trusted proxies the connection's peer address
+// is used as is. A peer on a Unix socket is always a trusted proxy, since
+// only a local process can connect; clients reaching the socket without
+// forwarding headers appear as 127.0.0.1.
+func ClientIPMiddleware(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, ok := resolveClientIP(r, trusted); ok {
+				r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, addr))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the client address resolved by ClientIPMiddleware, or the
+// connection's peer address when the middleware did not run.
+func ClientIP(r *http.Request) string {
+	return getClientIP(r)
+}
+
+// clientAddr returns the resolved client address, falling back to the peer.
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	if addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return addr, true
+	}
+	return remoteAddrIP(r)
+}
+
+// getClientIP returns the client address as a string. An unparseable
+// RemoteAddr is returned without its port.
+func getClientIP(r *http.Request) string {
+	if addr, ok := clientAddr(r); ok {
+		return addr.String()
+	}
+	addr := r.RemoteAddr
+	if lastColon := strings.LastIndexByte(addr, ':'); lastColon != -1 {
+		return addr[:lastColon]
+	}
+	return addr
+}
+
+// resolveClientIP implements the trusted proxy rules of ClientIPMiddleware.
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	peer, ok := remoteAddrIP(r)
+	if !ok || (!unixPeer(r) && !IPAllowed(peer, trusted)) {
+		return peer, ok
+	}
+
+	// Several X-Forwarded-For headers form one list
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) > 0 {
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// Nothing left of a malformed hop can be trusted
+				break
+			}
+			client = hop.WithZone("").Unmap()
+			if !IPAllowed(client, trusted) {
+				break
+			}
+		}
+		return client, true
+	}
+
+	if xri, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return xri.WithZone("").Unmap(), true
+	}
+	return peer, true
+}
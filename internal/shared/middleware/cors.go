@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"time-tracker/internal/shared/errors"
+)
+
+// CORS response values. Credentials are allowed so browsers can send Basic
+// Auth, which is why origins must be listed exactly rather than as "*".
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, X-API-Key, X-Admin-Key, traceparent"
+	corsExposeHeaders = "Content-Disposition, Retry-After, X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-TimeTracker-Version"
+	corsMaxAge        = "600"
+)
+
+// ParseCORSOrigins parses a comma-separated list of origins such as
+// "https://app.example.com, http://localhost:5173". Each entry must be a bare
+// scheme://host[:port] origin; wildcards are rejected.
+func ParseCORSOrigins(spec string) ([]string, error) {
+	var origins []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.Contains(part, "*") {
+			return nil, fmt.Errorf("wildcard origin %q is not allowed because credentials are allowed", part)
+		}
+
+		u, err := url.Parse(part)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("invalid origin %q: expected scheme://host[:port]", part)
+		}
+		origins = append(origins, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+	return origins, nil
+}
+
+// CORSMiddleware allows cross-origin requests from the given origins. It
+// answers preflight requests itself, before authentication, and adds
+// Access-Control-Allow-Origin to actual responses for allowed origins only.
+// Preflights from other origins are rejected with 403 Forbidden. It is a no-op
+// when origins is empty.
+func CORSMiddleware(origins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		allowed := make(map[string]bool, len(origins))
+		for _, origin := range origins {
+			allowed[origin] = true
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// Not a CORS request
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The response depends on the Origin header, whether allowed or not
+			w.Header().Add("Vary", "Origin")
+			ok := allowed[strings.ToLower(origin)]
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				if !ok {
+					errors.WriteError(w, errors.ForbiddenError("Origin is not allowed"))
+					return
+				}
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if ok {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCORSOrigins(t *testing.T) {
+	origins, err := ParseCORSOrigins(" https://App.example.com, http://localhost:5173/ ,,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"https://app.example.com", "http://localhost:5173"}
+	if len(origins) != len(want) {
+		t.Fatalf("expected %v, got %v", want, origins)
+	}
+	for i, o := range origins {
+		if o != want[i] {
+			t.Errorf("origin %d: expected %s, got %s", i, want[i], o)
+		}
+	}
+
+	for _, bad := range []string{"*", "https://*.example.com", "app.example.com", "ftp://example.com", "https://example.com/app", "https://user@example.com"} {
+		if _, err := ParseCORSOrigins(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	reached := false
+	handler := CORSMiddleware([]string{"https://app.example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, "/api/v1/sessions", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "x-api-key, content-type")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("preflight from allowed origin", func(t *testing.T) {
+		rr := do(http.MethodOptions, "https://app.example.com", true)
+		if rr.Code != http.StatusNoContent || reached {
+			t.Fatalf("expected preflight to be answered with 204, got %d (reached=%v)", rr.Code, reached)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("unexpected Allow-Origin %q", got)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Headers"); got != corsAllowHeaders {
+			t.Errorf("unexpected Allow-Headers %q", got)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Methods"); got != corsAllowMethods {
+			t.Errorf("unexpected Allow-Methods %q", got)
+		}
+		if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Error("expected credentials to be allowed")
+		}
+		if rr.Header().Values("Vary")[0] != "Origin" {
+			t.Errorf("expected Vary: Origin, got %v", rr.Header().Values("Vary"))
+		}
+	})
+
+	t.Run("preflight from disallowed origin", func(t *testing.T) {
+		rr := do(http.MethodOptions, "https://evil.example.com", true)
+		if rr.Code != http.StatusForbidden || reached {
+			t.Fatalf("expected 403, got %d (reached=%v)", rr.Code, reached)
+		}
+		if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Error("expected no Allow-Origin for a disallowed origin")
+		}
+	})
+
+	t.Run("actual request from allowed origin", func(t *testing.T) {
+		rr := do(http.MethodGet, "https://app.example.com", false)
+		if !reached || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+			t.Errorf("expected Allow-Origin on the response, got %v", rr.Header())
+		}
+		if rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("expected Vary: Origin, got %q", rr.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("actual request from disallowed origin", func(t *testing.T) {
+		rr := do(http.MethodGet, "https://evil.example.com", false)
+		if !reached {
+			t.Error("expected the request to reach the handler")
+		}
+		if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Error("expected no Allow-Origin for a disallowed origin")
+		}
+		if rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("expected Vary: Origin, got %q", rr.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("non-CORS request", func(t *testing.T) {
+		rr := do(http.MethodGet, "", false)
+		if !reached || rr.Code != http.StatusOK {
+			t.Fatalf("expected pass-through, got %d", rr.Code)
+		}
+		if len(rr.Header()) != 0 {
+			t.Errorf("expected no CORS headers, got %v", rr.Header())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		h := CORSMiddleware(nil)(next)
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/sessions", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Header().Get("Access-Control-Allow-Origin") != "" || rr.Header().Get("Vary") != "" {
+			t.Errorf("expected no CORS headers when disabled, got %v", rr.Header())
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	bom := []byte{0xEF, 0xBB, 0xBF}
+	largeCSV := append(append([]byte{}, bom...), []byte(strings.Repeat("1,work,coding\n", 200))...)
+
+	csvHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="sessions.csv"`)
+		w.Write(largeCSV[:3])
+		w.Write(largeCSV[3:])
+	})
+
+	t.Run("compresses large bodies", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/sessions.csv", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		rr := httptest.NewRecorder()
+
+		GzipMiddleware(1024)(csvHandler).ServeHTTP(rr, req)
+
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected gzip encoding, got %q", rr.Header().Get("Content-Encoding"))
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("expected Vary: Accept-Encoding, got %q", rr.Header().Get("Vary"))
+		}
+		if !strings.Contains(rr.Header().Get("Content-Disposition"), "sessions.csv") {
+			t.Errorf("Content-Disposition lost: %q", rr.Header().Get("Content-Disposition"))
+		}
+
+		zr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("body is not gzip: %v", err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(body, bom) {
+			t.Fatal("decompressed CSV does not start with UTF-8 BOM")
+		}
+		if !bytes.Equal(body, largeCSV) {
+			t.Fatal("decompressed body differs from original")
+		}
+	})
+
+	t.Run("skips small bodies", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/sessions", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+
+		GzipMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"items":[]}`))
+		})).ServeHTTP(rr, req)
+
+		if rr.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("small body should not be compressed")
+		}
+		if rr.Code != http.StatusCreated || rr.Body.String() != `{"items":[]}` {
+			t.Fatalf("unexpected response %d %q", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("client without gzip", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/sessions.csv", nil)
+		req.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+		rr := httptest.NewRecorder()
+
+		GzipMiddleware(1024)(csvHandler).ServeHTTP(rr, req)
+
+		if rr.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("expected identity encoding")
+		}
+		if !bytes.Equal(rr.Body.Bytes(), largeCSV) {
+			t.Fatal("body altered without compression")
+		}
+	})
+
+	t.Run("does not double compress", func(t *testing.T) {
+		var pre bytes.Buffer
+		zw := gzip.NewWriter(&pre)
+		zw.Write(largeCSV)
+		zw.Close()
+
+		req := httptest.NewRequest("GET", "/sessions.csv", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+
+		GzipMiddleware(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(pre.Bytes())
+		})).ServeHTTP(rr, req)
+
+		if !bytes.Equal(rr.Body.Bytes(), pre.Bytes()) {
+			t.Fatal("pre-compressed body was modified")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"time-tracker/internal/shared/errors"
+)
+
+// ParseCIDRs parses a comma-separated list of CIDR prefixes such as
+// "192.168.1.0/24, 2001:db8::/32". A bare address is treated as a single-host
+// prefix. An empty spec returns nil (no restriction).
+func ParseCIDRs(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", part)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", part)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// loopback stands in for the address of a peer on a Unix socket.
+var loopback = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+
+// unixPeer reports whether the request came over a Unix socket, whose peer
+// has no address: Go reports it as "" or "@". Only a process on this
+// machine, normally a reverse proxy, can connect to the socket.
+func unixPeer(r *http.Request) bool {
+	return r.RemoteAddr == "" || r.RemoteAddr == "@"
+}
+
+// remoteAddrIP returns the address of the connection's peer, ignoring
+// forwarding headers, which any client can forge. A peer on a Unix socket
+// is reported as loopback.
+func remoteAddrIP(r *http.Request) (netip.Addr, bool) {
+	if unixPeer(r) {
+		return loopback, true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	// IPv4 clients on a dual-stack listener appear as ::ffff:a.b.c.d
+	return addr.Unmap(), true
+}
+
+// IPAllowed reports whether addr is inside any of the prefixes.
+func IPAllowed(addr netip.Addr, prefixes []netip.Prefix) bool {
+	addr = addr.WithZone("").Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAllowlistMiddleware rejects requests whose client address is not in any of
+// the allowed prefixes with 403 Forbidden. It is a no-op when allowed is empty.
+// The client address is the one resolved by ClientIPMiddleware, so forwarding
+// headers are only honored from trusted proxies.
+func IPAllowlistMiddleware(allowed []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, ok := clientAddr(r)
+			if !ok || !IPAllowed(addr, allowed) {
+				errors.WriteError(w, errors.ForbiddenError("Client address is not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	prefixes, err := ParseCIDRs(" 192.168.1.0/24, 2001:db8::/32 ,203.0.113.7,, 10.1.2.3/8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"192.168.1.0/24", "2001:db8::/32", "203.0.113.7/32", "10.0.0.0/8"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("expected %d prefixes, got %v", len(want), prefixes)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d: expected %s, got %s", i, want[i], p)
+		}
+	}
+
+	if prefixes, err := ParseCIDRs(""); err != nil || prefixes != nil {
+		t.Errorf("expected empty spec to mean no restriction, got %v, %v", prefixes, err)
+	}
+
+	for _, bad := range []string{"192.168.1.0/33", "not-an-ip", "10.0.0.0/8,2001:db8::/129", "example.com/24"} {
+		if _, err := ParseCIDRs(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestIPAllowlistMiddleware(t *testing.T) {
+	allowed, err := ParseCIDRs("192.168.1.0/24,203.0.113.7,2001:db8:abcd::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := IPAllowlistMiddleware(allowed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       int
+	}{
+		{"v4 network address", "192.168.1.0:1234", http.StatusOK},
+		{"v4 inside", "192.168.1.42:1234", http.StatusOK},
+		{"v4 broadcast address", "192.168.1.255:1234", http.StatusOK},
+		{"v4 just below", "192.168.0.255:1234", http.StatusForbidden},
+		{"v4 just above", "192.168.2.0:1234", http.StatusForbidden},
+		{"v4 single host", "203.0.113.7:80", http.StatusOK},
+		{"v4 next to single host", "203.0.113.8:80", http.StatusForbidden},
+		{"v4-mapped v6 inside", "[::ffff:192.168.1.10]:1234", http.StatusOK},
+		{"v6 first address", "[2001:db8:abcd::]:443", http.StatusOK},
+		{"v6 last address", "[2001:db8:abcd:ffff:ffff:ffff:ffff:ffff]:443", http.StatusOK},
+		{"v6 just above", "[2001:db8:abce::]:443", http.StatusForbidden},
+		{"v6 just below", "[2001:db8:abcc:ffff:ffff:ffff:ffff:ffff]:443", http.StatusForbidden},
+		{"v6 with zone", "[2001:db8:abcd::1%eth0]:443", http.StatusOK},
+		{"loopback", "127.0.0.1:1234", http.StatusForbidden},
+		{"unparseable", "garbage", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestIPAllowlistMiddleware_IgnoresForwardedHeaders(t *testing.T) {
+	allowed, _ := ParseCIDRs("10.0.0.0/8")
+	handler := IPAllowlistMiddleware(allowed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected forged forwarding headers to be ignored, got %d", rr.Code)
+	}
+}
+
+func TestIPAllowlistMiddleware_Empty(t *testing.T) {
+	handler := IPAllowlistMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected no restriction without allowlist, got %d", rr.Code)
+	}
+}
+
+func TestIPAllowlistMiddleware_TrustedProxy(t *testing.T) {
+	allowed, _ := ParseCIDRs("192.168.1.0/24")
+	trusted, _ := ParseCIDRs("10.0.0.1")
+	handler := ClientIPMiddleware(trusted)(IPAllowlistMiddleware(allowed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       int
+	}{
+		{"allowed client through proxy", "10.0.0.1:1234", "192.168.1.20", http.StatusOK},
+		{"other client through proxy", "10.0.0.1:1234", "198.51.100.1", http.StatusForbidden},
+		{"forged hop before the real client", "10.0.0.1:1234", "192.168.1.20, 198.51.100.1", http.StatusForbidden},
+		{"forged header from untrusted peer", "198.51.100.1:1234", "192.168.1.20", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"time-tracker/internal/shared/auth"
+)
+
+func TestRequestLoggingMiddleware_JSON(t *testing.T) {
+	apiKey := "test-api-key-32-chars-minimum!!!"
+	var buf bytes.Buffer
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error":"conflict"}`))
+	})
+	chain := RequestIDMiddleware(RequestLoggingMiddleware(NewLogger(&buf, "json", slog.LevelInfo))(auth.APIKeyMiddleware([]string{apiKey}, "", "")(handler)))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/start?x=1", strings.NewReader(`{"task":"secret body"}`))
+	req.RemoteAddr = "192.0.2.10:51234"
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set(RequestIDHeader, "proxy-req-1")
+	chain.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one log line, got %d: %q", len(lines), buf.String())
+	}
+	if strings.Contains(lines[0], "secret body") {
+		t.Fatal("request body must not be logged")
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	expected := map[string]interface{}{
+		"msg":        "request",
+		"request_id": "proxy-req-1",
+		"method":     "POST",
+		"path":       "/api/v1/sessions/start",
+		"status":     float64(http.StatusConflict),
+		"bytes":      float64(len(`{"error":"conflict"}`)),
+		"ip":         "192.0.2.10",
+		"principal":  "key:test...",
+	}
+	for field, want := range expected {
+		if entry[field] != want {
+			t.Errorf("%s = %v, want %v", field, entry[field], want)
+		}
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("expected numeric duration_ms, got %v", entry["duration_ms"])
+	}
+}
+
+func TestRequestLoggingMiddleware_Text(t *testing.T) {
+	var buf bytes.Buffer
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.SetBasicAuth("admin", "wrong")
+	RequestLoggingMiddleware(NewLogger(&buf, "text", slog.LevelInfo))(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	for _, want := range []string{"msg=request", "method=GET", "path=/healthz", "status=200", "bytes=2", "principal=user:admin"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+}
+
+func TestRequestLoggingMiddleware_Level(t *testing.T) {
+	var buf bytes.Buffer
+	status := http.StatusOK
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	chain := RequestLoggingMiddleware(NewLogger(&buf, "json", slog.LevelWarn))(handler)
+
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if buf.Len() != 0 {
+		t.Errorf("warn level logged a successful request: %q", buf.String())
+	}
+
+	status = http.StatusServiceUnavailable
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/failing", nil))
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "ERROR" || entry["path"] != "/failing" {
+		t.Errorf("got level %v for %v, want ERROR for /failing", entry["level"], entry["path"])
+	}
+}
+
+func TestLoggingResponseWriter_Flush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	var w http.ResponseWriter = &loggingResponseWriter{ResponseWriter: rr}
+
+	w.Write([]byte("data"))
+	w.(http.Flusher).Flush()
+	if !rr.Flushed {
+		t.Error("expected Flush to reach the underlying writer")
+	}
+}
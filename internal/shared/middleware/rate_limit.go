@@ -2,155 +2,331 @@
 package middleware
 
 import (
+	"container/list"
+	"fmt"
+	"hash/maphash"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"time-tracker/internal/shared/errors"
 )
 
+// DefaultMaxTrackedKeys bounds the number of clients a RateLimiter tracks.
+// When it is reached the least recently seen client is forgotten, so a flood
+// of distinct addresses costs bounded memory.
+const DefaultMaxTrackedKeys = 100000
+
+// DefaultRateLimitShards is how many independently locked parts a
+// RateLimiter splits its clients over.
+const DefaultRateLimitShards = 32
+
 // RateLimiter implements a sliding window rate limiter based on IP address.
+// Each client has counters for the current and previous window; the previous
+// count is weighted by how much of it still overlaps the sliding window. This
+// approximates a true sliding log in constant time and memory per client.
+//
+// Clients are spread over shards by a hash of their key, each with its own
+// lock and least recently seen order, so requests from different clients
+// rarely wait for each other. The tracked-client bound and eviction apply
+// per shard.
 type RateLimiter struct {
-	mu          sync.Mutex
-	requests    map[string][]time.Time
-	limit       int
+	shards      []*rateLimitShard
+	seed        maphash.Seed
+	limit       atomic.Int64
 	window      time.Duration
+	maxKeys     int
+	now         func() time.Time
 	cleanupTick time.Duration
 	cleanupStop chan struct{}
+	stopOnce    sync.Once
+}
+
+// rateLimitShard tracks the clients whose keys hash to it.
+type rateLimitShard struct {
+	mu   sync.Mutex
+	keys map[string]*list.Element // values are *rateLimitEntry
+	lru  *list.List               // most recently seen at the front
+}
+
+// rateLimitEntry holds one client's window counters.
+type rateLimitEntry struct {
+	key   string
+	start time.Time // start of the current window
+	curr  int       // requests in the current window
+	prev  int       // requests in the previous window
 }
 
 // NewRateLimiter creates a new rate limiter with the specified limit per window.
 // Default window is 1 minute.
 func NewRateLimiter(limit int) *RateLimiter {
+	rl := newRateLimiter(limit, DefaultRateLimitShards)
+	go rl.cleanup()
+	return rl
+}
+
+// newRateLimiter returns a limiter with the given number of shards, without
+// starting its cleanup.
+func newRateLimiter(limit, shards int) *RateLimiter {
 	rl := &RateLimiter{
-		requests:    make(map[string][]time.Time),
-		limit:       limit,
+		shards:      make([]*rateLimitShard, shards),
+		seed:        maphash.MakeSeed(),
 		window:      time.Minute,
+		maxKeys:     DefaultMaxTrackedKeys,
+		now:         time.Now,
 		cleanupTick: 5 * time.Minute,
 		cleanupStop: make(chan struct{}),
 	}
-	go rl.cleanup()
+	for i := range rl.shards {
+		rl.shards[i] = &rateLimitShard{keys: make(map[string]*list.Element), lru: list.New()}
+	}
+	rl.limit.Store(int64(limit))
 	return rl
 }
 
-// cleanup periodically removes old entries to prevent memory leaks.
+// shard returns the shard tracking key.
+func (rl *RateLimiter) shard(key string) *rateLimitShard {
+	return rl.shards[maphash.String(rl.seed, key)%uint64(len(rl.shards))]
+}
+
+// cleanup periodically removes clients whose counters have expired, so memory
+// is released without waiting for eviction. It sweeps one shard per tick,
+// reaching each once every cleanupTick, so only one shard is locked at a time.
 func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.cleanupTick)
+	tick := rl.cleanupTick / time.Duration(len(rl.shards))
+	if tick <= 0 {
+		tick = rl.cleanupTick
+	}
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
-	for {
+	for next := 0; ; next = (next + 1) % len(rl.shards) {
 		select {
 		case <-ticker.C:
-			rl.mu.Lock()
-			now := time.Now()
-			for ip, times := range rl.requests {
-				var valid []time.Time
-				for _, t := range times {
-					if now.Sub(t) < rl.window {
-						valid = append(valid, t)
-					}
-				}
-				if len(valid) == 0 {
-					delete(rl.requests, ip)
-				} else {
-					rl.requests[ip] = valid
-				}
-			}
-			rl.mu.Unlock()
+			rl.shards[next].sweep(rl.now(), 2*rl.window)
 		case <-rl.cleanupStop:
 			return
 		}
 	}
 }
 
+// sweep removes the clients last counted at least expiry before now.
+func (s *rateLimitShard) sweep(now time.Time, expiry time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The list is ordered by last use, so expired entries are at the back
+	for e := s.lru.Back(); e != nil; e = s.lru.Back() {
+		entry := e.Value.(*rateLimitEntry)
+		if now.Sub(entry.start) < expiry {
+			break
+		}
+		s.lru.Remove(e)
+		delete(s.keys, entry.key)
+	}
+}
+
+// Len returns the number of clients tracked.
+func (rl *RateLimiter) Len() int {
+	n := 0
+	for _, s := range rl.shards {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// RateLimitResult describes the outcome of one request against the limiter.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int // requests left in the sliding window after this one
+	Reset      int // seconds until the current window ends
+	RetryAfter int // seconds until the next allowed request, when denied
+}
+
 // Allow checks if a request from the given IP is allowed.
 // Returns (allowed, retryAfter) where retryAfter is seconds until the next allowed request.
 func (rl *RateLimiter) Allow(ip string) (bool, int) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	res := rl.Take(ip)
+	return res.Allowed, res.RetryAfter
+}
 
-	now := time.Now()
-	windowStart := now.Add(-rl.window)
+// Take is like Allow but also reports the remaining quota.
+func (rl *RateLimiter) Take(ip string) RateLimitResult {
+	limit := int(rl.limit.Load())
+	shard := rl.shard(ip)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
-	// Filter out old requests
-	var validRequests []time.Time
-	for _, t := range rl.requests[ip] {
-		if t.After(windowStart) {
-			validRequests = append(validRequests, t)
-		}
+	now := rl.now()
+	entry := shard.entry(ip, now, rl.maxKeysPerShard())
+
+	// Roll the windows forward
+	if elapsed := now.Sub(entry.start); elapsed >= 2*rl.window {
+		entry.start, entry.prev, entry.curr = now, 0, 0
+	} else if elapsed >= rl.window {
+		entry.start, entry.prev, entry.curr = entry.start.Add(rl.window), entry.curr, 0
 	}
 
-	if len(validRequests) >= rl.limit {
-		// Calculate retry-after based on oldest request in window
-		oldestInWindow := validRequests[0]
-		retryAfter := int(rl.window.Seconds() - now.Sub(oldestInWindow).Seconds())
-		if retryAfter < 1 {
-			retryAfter = 1
-		}
-		rl.requests[ip] = validRequests
-		return false, retryAfter
-	}
-
-	// Add current request
-	validRequests = append(validRequests, now)
-	rl.requests[ip] = validRequests
-	return true, 0
-}
-
-// getClientIP extracts the client IP from the request.
-// Only uses RemoteAddr for better security unless configured otherwise.
-// X-Forwarded-For can be spoofed, so it should only be trusted if we know we are behind a proxy.
-func getClientIP(r *http.Request) string {
-	// For now, to improve security, we will rely on RemoteAddr.
-	// In a real production environment behind a trusted load balancer, we would
-	// configure trusted proxies and then check X-Forwarded-For.
-
-	// Check X-Forwarded-For header first, take first IP
-	if xff := strings.TrimSpace(r.Header.Get("X-Forwarded-For")); xff != "" {
-		parts := strings.Split(xff, ",")
-		first := strings.TrimSpace(parts[0])
-		if first != "" {
-			return first
-		}
+	elapsed := now.Sub(entry.start)
+	res := RateLimitResult{
+		Limit: limit,
+		Reset: int(math.Ceil((rl.window - elapsed).Seconds())),
+	}
+	if rl.estimate(entry, elapsed) >= float64(limit) {
+		res.RetryAfter = rl.retryAfter(entry, elapsed, limit)
+		return res
 	}
 
-	// Check X-Real-IP header
-	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
-		return xri
+	entry.curr++
+	res.Allowed = true
+	if remaining := float64(limit) - rl.estimate(entry, elapsed); remaining > 0 {
+		res.Remaining = int(remaining)
 	}
+	return res
+}
 
-	// Fall back to RemoteAddr (strip port)
-	addr := r.RemoteAddr
-	// Handle IPv6 format: [2001:db8::1]:port
-	if len(addr) > 0 && addr[0] == '[' {
-		if end := strings.IndexByte(addr, ']'); end != -1 {
-			return addr[1:end]
+// maxKeysPerShard returns each shard's share of maxKeys, at least 1, or 0
+// for no bound.
+func (rl *RateLimiter) maxKeysPerShard() int {
+	if rl.maxKeys <= 0 {
+		return 0
+	}
+	return max(rl.maxKeys/len(rl.shards), 1)
+}
+
+// entry returns the counters for key, creating them and evicting the
+// shard's least recently seen client if it already tracks maxKeys, and marks
+// key as most recently seen.
+func (s *rateLimitShard) entry(key string, now time.Time, maxKeys int) *rateLimitEntry {
+	if e, ok := s.keys[key]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*rateLimitEntry)
+	}
+	if maxKeys > 0 && s.lru.Len() >= maxKeys {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.keys, oldest.Value.(*rateLimitEntry).key)
+	}
+	entry := &rateLimitEntry{key: key, start: now}
+	s.keys[key] = s.lru.PushFront(entry)
+	return entry
+}
+
+// estimate returns the weighted request count in the sliding window ending
+// elapsed into the current window.
+func (rl *RateLimiter) estimate(entry *rateLimitEntry, elapsed time.Duration) float64 {
+	overlap := 1 - float64(elapsed)/float64(rl.window)
+	return float64(entry.prev)*overlap + float64(entry.curr)
+}
+
+// retryAfter returns the whole seconds until the estimate drops enough for
+// one more request under limit, at least 1.
+func (rl *RateLimiter) retryAfter(entry *rateLimitEntry, elapsed time.Duration, limit int) int {
+	excess := rl.estimate(entry, elapsed) - float64(limit-1)
+	window := float64(rl.window)
+
+	var wait float64
+	if entry.prev > 0 && float64(elapsed)+excess*window/float64(entry.prev) <= window {
+		// The previous window's weight decays enough before this window ends
+		wait = excess * window / float64(entry.prev)
+	} else {
+		// Wait for the next window, where this window's count decays instead
+		wait = window - float64(elapsed)
+		if entry.curr > limit-1 {
+			wait += (1 - float64(limit-1)/float64(entry.curr)) * window
 		}
 	}
-	// Handle IPv4 format: 192.168.1.1:port
-	if lastColon := strings.LastIndexByte(addr, ':'); lastColon != -1 {
-		return addr[:lastColon]
+
+	retryAfter := int(math.Ceil(time.Duration(wait).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	return retryAfter
+}
+
+// SetLimit changes the number of requests allowed per window. Counts
+// already recorded are kept and judged against the new limit. Limits below
+// 1 are ignored.
+func (rl *RateLimiter) SetLimit(limit int) {
+	if limit < 1 {
+		return
 	}
-	return addr
+	rl.limit.Store(int64(limit))
 }
 
-// Stop gracefully stops the cleanup goroutine.
+// Stop gracefully stops the cleanup goroutine. It may be called more than once.
 func (rl *RateLimiter) Stop() {
-	close(rl.cleanupStop)
+	rl.stopOnce.Do(func() { close(rl.cleanupStop) })
+}
+
+// DefaultRateLimitExemptPaths are not rate limited unless configured
+// otherwise, so health probes and page assets cannot use up a client's quota.
+var DefaultRateLimitExemptPaths = []string{"/healthz", "/readyz", "/version", "/static/"}
+
+// RateLimitOptions configures RateLimitWithOptions.
+type RateLimitOptions struct {
+	// ExemptPaths are not counted. An entry ending in "/" matches every path
+	// under it; other entries match exactly.
+	ExemptPaths []string
+}
+
+// ParseExemptPaths parses a comma-separated list of paths such as
+// "/healthz, /static/". Every entry must start with "/".
+func ParseExemptPaths(spec string) ([]string, error) {
+	var paths []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.HasPrefix(part, "/") {
+			return nil, fmt.Errorf("invalid path %q: must start with /", part)
+		}
+		paths = append(paths, part)
+	}
+	return paths, nil
+}
+
+// exempt reports whether path matches one of the exempt paths.
+func (o RateLimitOptions) exempt(path string) bool {
+	for _, p := range o.ExemptPaths {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
 }
 
-// RateLimitMiddleware creates an HTTP middleware that enforces rate limiting.
-// Returns 429 Too Many Requests with Retry-After header when limit is exceeded.
+// RateLimitMiddleware creates an HTTP middleware that enforces rate limiting
+// on every path. See RateLimitWithOptions.
 func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return RateLimitWithOptions(limiter, RateLimitOptions{})
+}
+
+// RateLimitWithOptions creates an HTTP middleware that enforces rate limiting.
+// Counted responses carry X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (seconds until the current window ends). Returns 429 Too
+// Many Requests with a Retry-After header when the limit is exceeded.
+func RateLimitWithOptions(limiter *RateLimiter, opts RateLimitOptions) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getClientIP(r)
-			allowed, retryAfter := limiter.Allow(ip)
-
-			if !allowed {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte(`{"error":{"code":"RATE_LIMITED","message":"Too many requests"}}`))
+			if opts.exempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res := limiter.Take(getClientIP(r))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(res.Reset))
+
+			if !res.Allowed {
+				errors.WriteError(w, errors.NewRateLimitError(res.RetryAfter))
 				return
 			}
 
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sliceRateLimiter is the previous timestamp-slice implementation, kept for
+// comparison in the benchmarks below.
+type sliceRateLimiter struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+	limit    int
+	window   time.Duration
+}
+
+func (rl *sliceRateLimiter) Allow(ip string) (bool, int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-rl.window)
+	var validRequests []time.Time
+	for _, t := range rl.requests[ip] {
+		if t.After(windowStart) {
+			validRequests = append(validRequests, t)
+		}
+	}
+	if len(validRequests) >= rl.limit {
+		rl.requests[ip] = validRequests
+		return false, 1
+	}
+	rl.requests[ip] = append(validRequests, now)
+	return true, 0
+}
+
+const benchDistinctIPs = 10000
+
+func benchIPs() []string {
+	ips := make([]string, benchDistinctIPs)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+	return ips
+}
+
+// Requests spread over 10k distinct IPs with the production default limit.
+func BenchmarkRateLimiter_10kIPs(b *testing.B) {
+	ips := benchIPs()
+	limiter := NewRateLimiter(100)
+	defer limiter.Stop()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		limiter.Allow(ips[i%len(ips)])
+	}
+}
+
+func BenchmarkSliceRateLimiter_10kIPs(b *testing.B) {
+	ips := benchIPs()
+	limiter := &sliceRateLimiter{requests: make(map[string][]time.Time), limit: 100, window: time.Minute}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		limiter.Allow(ips[i%len(ips)])
+	}
+}
+
+// A few clients hammering at the limit, where the old implementation rescans
+// a full slice on every request.
+func BenchmarkRateLimiter_Saturated(b *testing.B) {
+	limiter := NewRateLimiter(1000)
+	defer limiter.Stop()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		limiter.Allow("10.0.0.1")
+	}
+}
+
+func BenchmarkSliceRateLimiter_Saturated(b *testing.B) {
+	limiter := &sliceRateLimiter{requests: make(map[string][]time.Time), limit: 1000, window: time.Minute}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		limiter.Allow("10.0.0.1")
+	}
+}
+
+// Parallel clients spread over 10k IPs, all behind one lock ("1_shard")
+// and spread over the default shards ("sharded").
+func BenchmarkRateLimiter_Parallel(b *testing.B) {
+	ips := benchIPs()
+	for _, bc := range []struct {
+		name   string
+		shards int
+	}{
+		{"1_shard", 1},
+		{"sharded", DefaultRateLimitShards},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			limiter := newRateLimiter(100, bc.shards)
+
+			var next atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(next.Add(1)) * 7919
+				for pb.Next() {
+					limiter.Allow(ips[i%len(ips)])
+					i++
+				}
+			})
+		})
+	}
+}
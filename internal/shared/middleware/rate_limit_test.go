@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRateLimiter_Allow(t *testing.T) {
@@ -73,19 +77,46 @@ func TestRateLimitMiddleware(t *testing.T) {
 }
 
 func TestGetClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs("192.168.1.0/24, 10.0.0.0/8, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name       string
+		trusted    []netip.Prefix
 		xff        string
 		xri        string
 		remoteAddr string
 		want       string
 	}{
-		{"X-Forwarded-For single", "10.0.0.1", "", "192.168.1.1:12345", "10.0.0.1"},
-		{"X-Forwarded-For multiple", "10.0.0.1, 10.0.0.2", "", "192.168.1.1:12345", "10.0.0.1"},
-		{"X-Real-IP", "", "10.0.0.1", "192.168.1.1:12345", "10.0.0.1"},
-		{"RemoteAddr with port", "", "", "192.168.1.1:12345", "192.168.1.1"},
-		{"RemoteAddr without port", "", "", "192.168.1.1", "192.168.1.1"},
-		{"X-Forwarded-For takes precedence", "10.0.0.1", "10.0.0.2", "192.168.1.1:12345", "10.0.0.1"},
+		// Without trusted proxies forwarding headers are ignored
+		{"X-Forwarded-For ignored", nil, "10.0.0.1", "", "192.168.1.1:12345", "192.168.1.1"},
+		{"X-Real-IP ignored", nil, "", "10.0.0.1", "192.168.1.1:12345", "192.168.1.1"},
+		{"RemoteAddr with port", nil, "", "", "192.168.1.1:12345", "192.168.1.1"},
+		{"RemoteAddr without port", nil, "", "", "192.168.1.1", "192.168.1.1"},
+		{"RemoteAddr IPv6", nil, "", "", "[2001:db8::1]:443", "2001:db8::1"},
+		{"RemoteAddr v4-mapped", nil, "", "", "[::ffff:192.168.1.1]:443", "192.168.1.1"},
+
+		// Behind a trusted proxy
+		{"X-Forwarded-For single", trusted, "203.0.113.5", "", "192.168.1.1:12345", "203.0.113.5"},
+		{"X-Forwarded-For skips trusted hops", trusted, "203.0.113.5, 10.0.0.2", "", "192.168.1.1:12345", "203.0.113.5"},
+		{"X-Forwarded-For IPv6 client", trusted, "2001:db9::7, 2001:db8::2", "", "[2001:db8::1]:443", "2001:db9::7"},
+		{"X-Forwarded-For all trusted", trusted, "10.0.0.3, 10.0.0.2", "", "192.168.1.1:12345", "10.0.0.3"},
+		{"X-Forwarded-For malformed hop", trusted, "203.0.113.5, bogus, 10.0.0.2", "", "192.168.1.1:12345", "10.0.0.2"},
+		{"X-Real-IP", trusted, "", "203.0.113.5", "192.168.1.1:12345", "203.0.113.5"},
+		{"X-Forwarded-For takes precedence", trusted, "203.0.113.5", "203.0.113.6", "192.168.1.1:12345", "203.0.113.5"},
+		{"no forwarding headers", trusted, "", "", "192.168.1.1:12345", "192.168.1.1"},
+
+		// Headers from an untrusted peer are ignored even when proxies are configured
+		{"untrusted peer", trusted, "203.0.113.5", "203.0.113.6", "198.51.100.9:12345", "198.51.100.9"},
+
+		// A Unix socket peer is a local proxy, trusted whatever is configured
+		{"unix socket", nil, "", "", "@", "127.0.0.1"},
+		{"unix socket unnamed", nil, "", "", "", "127.0.0.1"},
+		{"unix socket X-Forwarded-For", nil, "198.51.100.1, 203.0.113.5", "", "@", "203.0.113.5"},
+		{"unix socket skips trusted hops", trusted, "203.0.113.5, 10.0.0.2", "", "@", "203.0.113.5"},
+		{"unix socket X-Real-IP", nil, "", "203.0.113.6", "@", "203.0.113.6"},
 	}
 
 	for _, tt := range tests {
@@ -99,10 +130,338 @@ func TestGetClientIP(t *testing.T) {
 			}
 			req.RemoteAddr = tt.remoteAddr
 
-			got := getClientIP(req)
+			var got string
+			ClientIPMiddleware(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = getClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
 			if got != tt.want {
 				t.Errorf("getClientIP() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+// A client that invents a new X-Forwarded-For for every request must still
+// be limited as one address, whether or not it connects through a proxy.
+func TestRateLimitMiddleware_SpoofedForwardedFor(t *testing.T) {
+	trusted, _ := ParseCIDRs("10.0.0.1")
+	limiter := NewRateLimiter(2)
+	defer limiter.Stop()
+
+	for _, tt := range []struct {
+		name       string
+		remoteAddr string
+		trusted    []netip.Prefix
+		// suffix is appended to the forged header, as a proxy adds the real peer
+		suffix string
+	}{
+		{"direct", "198.51.100.7:1234", nil, ""},
+		{"through trusted proxy", "10.0.0.1:1234", trusted, ", 198.51.100.8"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ClientIPMiddleware(tt.trusted)(RateLimitMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			limited := false
+			for i := 0; i < 5; i++ {
+				req := httptest.NewRequest("GET", "/api/test", nil)
+				req.RemoteAddr = tt.remoteAddr
+				forged := fmt.Sprintf("203.0.113.%d", i+1)
+				req.Header.Set("X-Forwarded-For", forged+tt.suffix)
+				req.Header.Set("X-Real-IP", forged)
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				if rr.Code == http.StatusTooManyRequests {
+					limited = true
+				}
+			}
+			if !limited {
+				t.Error("expected forged X-Forwarded-For values not to evade the rate limit")
+			}
+		})
+	}
+
+	if n := limiter.Len(); n != 2 {
+		t.Errorf("expected 2 tracked clients, got %d", n)
+	}
+}
+
+// newTestRateLimiter returns a limiter driven by the returned clock.
+func newTestRateLimiter(t *testing.T, limit int) (*RateLimiter, *time.Time) {
+	t.Helper()
+	limiter := NewRateLimiter(limit)
+	t.Cleanup(limiter.Stop)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestRateLimiter_SlidingWindow(t *testing.T) {
+	limiter, now := newTestRateLimiter(t, 10)
+
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow("ip"); !ok {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	ok, retryAfter := limiter.Allow("ip")
+	if ok {
+		t.Fatal("11th request should be denied")
+	}
+	// All 10 requests are in the current window, so one slot frees up once
+	// a tenth of them have slid out of the next one
+	if retryAfter != 66 {
+		t.Errorf("expected retryAfter 66, got %d", retryAfter)
+	}
+
+	// Halfway into the next window half of the previous count still applies
+	*now = now.Add(90 * time.Second)
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.Allow("ip"); !ok {
+			t.Fatalf("request %d after rollover should be allowed", i+1)
+		}
+	}
+	ok, retryAfter = limiter.Allow("ip")
+	if ok {
+		t.Fatal("expected the weighted previous window to count")
+	}
+	// One more slot frees up when another request's worth of the previous window decays
+	if retryAfter != 6 {
+		t.Errorf("expected retryAfter 6, got %d", retryAfter)
+	}
+
+	// After two idle windows nothing is carried over
+	*now = now.Add(2 * time.Minute)
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow("ip"); !ok {
+			t.Fatalf("request %d after idle period should be allowed", i+1)
+		}
+	}
+}
+
+func TestRateLimiter_RetryAfterIsHonest(t *testing.T) {
+	limiter, now := newTestRateLimiter(t, 3)
+
+	for step := 0; step < 50; step++ {
+		ok, retryAfter := limiter.Allow("ip")
+		if ok {
+			*now = now.Add(7 * time.Second)
+			continue
+		}
+		if retryAfter < 1 {
+			t.Fatalf("retryAfter must be positive, got %d", retryAfter)
+		}
+		// Waiting as told must be enough for the next request
+		*now = now.Add(time.Duration(retryAfter) * time.Second)
+		if ok, _ := limiter.Allow("ip"); !ok {
+			t.Fatalf("request still denied after waiting %ds", retryAfter)
+		}
+	}
+}
+
+func TestRateLimiter_SetLimit(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t, 5)
+
+	for i := 0; i < 2; i++ {
+		limiter.Allow("ip")
+	}
+	limiter.SetLimit(2)
+	if res := limiter.Take("ip"); res.Allowed || res.Limit != 2 {
+		t.Errorf("after lowering the limit got %+v, want a denial at limit 2", res)
+	}
+
+	limiter.SetLimit(4)
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("ip"); !ok {
+			t.Fatalf("request %d under the raised limit should be allowed", i+3)
+		}
+	}
+	if ok, _ := limiter.Allow("ip"); ok {
+		t.Error("request over the raised limit should be denied")
+	}
+
+	limiter.SetLimit(0)
+	if res := limiter.Take("other"); res.Limit != 4 {
+		t.Errorf("limit 0 changed the limit to %d", res.Limit)
+	}
+}
+
+// tracked reports whether the limiter has counters for key.
+func tracked(limiter *RateLimiter, key string) bool {
+	shard := limiter.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	_, ok := shard.keys[key]
+	return ok
+}
+
+func TestRateLimiter_EvictsLeastRecentlySeen(t *testing.T) {
+	// Eviction is per shard, so use one to see it across all keys
+	limiter := newRateLimiter(1, 1)
+	limiter.maxKeys = 3
+
+	for _, ip := range []string{"a", "b", "c"} {
+		limiter.Allow(ip)
+	}
+	// Touch "a" so "b" becomes the least recently seen
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Fatal("a should be limited")
+	}
+	limiter.Allow("d")
+
+	if n := limiter.Len(); n != 3 {
+		t.Fatalf("expected 3 tracked keys, got %d", n)
+	}
+	if tracked(limiter, "b") {
+		t.Error("expected b to be evicted")
+	}
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Error("expected a to still be limited")
+	}
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("expected evicted b to start over")
+	}
+}
+
+func TestRateLimiter_LenAcrossShards(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t, 1)
+	for i := 0; i < 100; i++ {
+		limiter.Allow(fmt.Sprintf("10.0.0.%d", i))
+	}
+	if n := limiter.Len(); n != 100 {
+		t.Fatalf("expected 100 tracked clients, got %d", n)
+	}
+
+	// The bound is split between the shards
+	limiter, _ = newTestRateLimiter(t, 1)
+	limiter.maxKeys = 2 * DefaultRateLimitShards
+	for i := 0; i < 1000; i++ {
+		limiter.Allow(fmt.Sprintf("10.0.1.%d", i))
+	}
+	if n := limiter.Len(); n > limiter.maxKeys {
+		t.Errorf("expected at most %d tracked clients, got %d", limiter.maxKeys, n)
+	}
+}
+
+func TestRateLimiter_CleanupRemovesExpired(t *testing.T) {
+	now := time.Now()
+	// Built without cleanup so it can be started with a short tick
+	limiter := newRateLimiter(1, DefaultRateLimitShards)
+	limiter.now = func() time.Time { return now }
+	limiter.cleanupTick = time.Millisecond
+
+	limiter.Allow("old")
+	now = now.Add(90 * time.Second)
+	limiter.Allow("recent")
+	now = now.Add(45 * time.Second)
+
+	go limiter.cleanup()
+	defer limiter.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		hasOld, hasRecent := tracked(limiter, "old"), tracked(limiter, "recent")
+		if !hasOld {
+			if !hasRecent {
+				t.Fatal("recent entry should be kept")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired entry was not cleaned up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRateLimitWithOptions_Headers(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t, 3)
+	handler := RateLimitWithOptions(limiter, RateLimitOptions{ExemptPaths: DefaultRateLimitExemptPaths})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i, wantRemaining := range []string{"2", "1", "0"} {
+		rr := do("/api/v1/sessions")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, rr.Code)
+		}
+		if got := rr.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q", i+1, got)
+		}
+		if got := rr.Header().Get("X-RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %s", i+1, got, wantRemaining)
+		}
+		if got := rr.Header().Get("X-RateLimit-Reset"); got != "60" {
+			t.Errorf("request %d: X-RateLimit-Reset = %q, want 60", i+1, got)
+		}
+	}
+
+	rr := do("/api/v1/sessions")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-RateLimit-Remaining") != "0" || rr.Header().Get("X-RateLimit-Limit") != "3" || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("unexpected 429 headers: %v", rr.Header())
+	}
+
+	// Exempt paths still work for a limited client and carry no quota headers
+	for _, path := range []string{"/healthz", "/static/app.css", "/static/js/app.js"} {
+		rr := do(path)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected exempt path to pass, got %d", path, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("%s: expected no rate limit headers", path)
+		}
+	}
+}
+
+func TestRateLimitWithOptions_HealthChecksDoNotConsumeQuota(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t, 2)
+	handler := RateLimitWithOptions(limiter, RateLimitOptions{ExemptPaths: DefaultRateLimitExemptPaths})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 50; i++ {
+		do("/healthz")
+	}
+	for i := 0; i < 2; i++ {
+		if code := do("/api/v1/sessions"); code != http.StatusOK {
+			t.Fatalf("API request %d after health checks: expected 200, got %d", i+1, code)
+		}
+	}
+	// Prefix entries do not match a similar-looking exact path
+	if code := do("/healthzz"); code != http.StatusTooManyRequests {
+		t.Errorf("expected /healthzz to be limited, got %d", code)
+	}
+}
+
+func TestParseExemptPaths(t *testing.T) {
+	paths, err := ParseExemptPaths(" /healthz, /static/ ,,/metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(paths, ",") != "/healthz,/static/,/metrics" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	if _, err := ParseExemptPaths("/healthz,static/"); err == nil {
+		t.Error("expected a relative path to be rejected")
+	}
+}
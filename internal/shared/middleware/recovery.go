@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"time-tracker/internal/shared/errors"
+)
+
+// RecoveryMiddleware turns a panic in next into a 500 INTERNAL_ERROR response
+// and logs the panic and stack with the request ID. If the handler had already
+// started the response, it cannot be replaced, so the connection is aborted
+// instead of leaving the client with a truncated reply that looks complete.
+func RecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &loggingResponseWriter{ResponseWriter: w}
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// Deliberate aborts are not errors
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.LogAttrs(r.Context(), slog.LevelError, "panic",
+					slog.String("request_id", RequestID(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("panic", fmt.Sprint(rec)),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				if rw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				errors.WriteError(rw, errors.InternalError())
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pgregory.net/rapid"
+)
+
+// A panic before the response starts must produce the standard 500 envelope;
+// a panic after it started must abort the response rather than append to it.
+func TestRecoveryMiddleware_Property(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		method := rapid.SampledFrom([]string{"GET", "POST", "PATCH", "DELETE"}).Draw(t, "method")
+		path := "/" + rapid.StringMatching(`[a-z]{1,10}(/[a-z]{1,10})?`).Draw(t, "path")
+		wroteFirst := rapid.Bool().Draw(t, "wroteFirst")
+		status := rapid.SampledFrom([]int{200, 201, 400, 404}).Draw(t, "status")
+		value := rapid.SampledFrom([]interface{}{"boom", 42, http.ErrBodyNotAllowed}).Draw(t, "value")
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wroteFirst {
+				w.WriteHeader(status)
+				w.Write([]byte("partial"))
+			}
+			panic(value)
+		})
+
+		var logs bytes.Buffer
+		chain := RequestIDMiddleware(RecoveryMiddleware(NewLogger(&logs, "json", slog.LevelInfo))(handler))
+		req := httptest.NewRequest(method, path, nil)
+		rr := httptest.NewRecorder()
+
+		var repanicked interface{}
+		func() {
+			defer func() { repanicked = recover() }()
+			chain.ServeHTTP(rr, req)
+		}()
+
+		if wroteFirst {
+			if repanicked != http.ErrAbortHandler {
+				t.Fatalf("expected the response to be aborted, got %v", repanicked)
+			}
+			if rr.Code != status || rr.Body.String() != "partial" {
+				t.Fatalf("response was modified after the panic: %d %q", rr.Code, rr.Body.String())
+			}
+		} else {
+			if repanicked != nil {
+				t.Fatalf("unexpected panic: %v", repanicked)
+			}
+			if rr.Code != http.StatusInternalServerError {
+				t.Fatalf("expected 500, got %d", rr.Code)
+			}
+			var body struct {
+				Error struct{ Code, Message string }
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error.Code != "INTERNAL_ERROR" {
+				t.Fatalf("expected INTERNAL_ERROR envelope, got %q", rr.Body.String())
+			}
+			if strings.Contains(rr.Body.String(), "goroutine") {
+				t.Fatal("stack leaked into the response")
+			}
+		}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatalf("expected one JSON log line, got %q", logs.String())
+		}
+		if entry["request_id"] != rr.Header().Get(RequestIDHeader) || entry["request_id"] == "" {
+			t.Fatalf("log request_id %v does not match response %q", entry["request_id"], rr.Header().Get(RequestIDHeader))
+		}
+		if stack, _ := entry["stack"].(string); !strings.Contains(stack, "goroutine") {
+			t.Fatalf("expected a stack trace in the log, got %v", entry["stack"])
+		}
+	})
+}
+
+func TestRecoveryMiddleware_AbortHandlerPassesThrough(t *testing.T) {
+	var logs bytes.Buffer
+	handler := RecoveryMiddleware(NewLogger(&logs, "json", slog.LevelInfo))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Fatalf("expected ErrAbortHandler to propagate, got %v", rec)
+		}
+		if logs.Len() != 0 {
+			t.Errorf("expected deliberate aborts not to be logged, got %q", logs.String())
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
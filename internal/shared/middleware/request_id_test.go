@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	do := func(incoming string) string {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		if incoming != "" {
+			req.Header.Set(RequestIDHeader, incoming)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Header().Get(RequestIDHeader); got != seen {
+			t.Errorf("response header %q does not match context ID %q", got, seen)
+		}
+		return seen
+	}
+
+	if id := do("abc-123_x.y"); id != "abc-123_x.y" {
+		t.Errorf("expected client ID to be reused, got %q", id)
+	}
+
+	generated := do("")
+	if len(generated) != 16 {
+		t.Errorf("expected a generated 16-char ID, got %q", generated)
+	}
+	if other := do(""); other == generated {
+		t.Error("expected generated IDs to differ")
+	}
+
+	for _, bad := range []string{"has space", "new\nline", strings.Repeat("a", maxRequestIDLength+1)} {
+		if id := do(bad); id == bad {
+			t.Errorf("expected %q to be replaced", bad)
+		}
+	}
+}
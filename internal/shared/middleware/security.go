@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // SecurityHeaders defines the security headers to be added to responses.
@@ -13,6 +16,53 @@ var SecurityHeaders = map[string]string{
 
 type CSPNonceKey struct{}
 
+// SecurityOptions extends the default security headers. The zero value
+// produces exactly the headers of SecurityHeadersMiddleware.
+type SecurityOptions struct {
+	// ScriptSrc and StyleSrc are extra CSP sources appended to script-src and style-src
+	ScriptSrc []string
+	StyleSrc  []string
+	// HSTSMaxAge, in seconds, enables Strict-Transport-Security when positive
+	HSTSMaxAge int
+}
+
+// ParseCSPSources parses a comma-separated list of CSP sources such as
+// "https://static.example.com, 'unsafe-eval'". Sources that could break out
+// of the directive (containing whitespace or ';') are rejected.
+func ParseCSPSources(spec string) ([]string, error) {
+	var sources []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.ContainsAny(part, "; \t\r\n") {
+			return nil, fmt.Errorf("invalid CSP source %q", part)
+		}
+		sources = append(sources, part)
+	}
+	return sources, nil
+}
+
+// contentSecurityPolicy builds the CSP header value. Pages rendered with a
+// nonce may also load scripts from the chart library CDN.
+func (o SecurityOptions) contentSecurityPolicy(nonce string, hasNonce bool) string {
+	scriptSrc := "'self'"
+	if hasNonce {
+		scriptSrc += " 'nonce-" + nonce + "' https://cdn.jsdelivr.net"
+	}
+	for _, src := range o.ScriptSrc {
+		scriptSrc += " " + src
+	}
+
+	styleSrc := "'self' 'unsafe-inline'"
+	for _, src := range o.StyleSrc {
+		styleSrc += " " + src
+	}
+
+	return "default-src 'self'; script-src " + scriptSrc + "; style-src " + styleSrc + "; img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; object-src 'none'"
+}
+
 // SecurityHeadersMiddleware adds security headers to all responses.
 // Headers added:
 // - X-Content-Type-Options: nosniff
@@ -20,17 +70,30 @@ type CSPNonceKey struct{}
 // - Content-Security-Policy: default-src 'self'
 // - X-XSS-Protection: 1; mode=block
 func SecurityHeadersMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for key, value := range SecurityHeaders {
-			w.Header().Set(key, value)
-		}
+	return SecurityHeadersWithOptions(SecurityOptions{})(next)
+}
 
-		cspValue := "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; object-src 'none'"
-		if nonce, ok := r.Context().Value(CSPNonceKey{}).(string); ok {
-			cspValue = "default-src 'self'; script-src 'self' 'nonce-" + nonce + "' https://cdn.jsdelivr.net; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; object-src 'none'"
-		}
-		w.Header().Set("Content-Security-Policy", cspValue)
+// SecurityHeadersWithOptions is like SecurityHeadersMiddleware but adds the
+// extra CSP sources and HSTS header configured in opts.
+func SecurityHeadersWithOptions(opts SecurityOptions) func(http.Handler) http.Handler {
+	var hsts string
+	if opts.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(opts.HSTSMaxAge)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for key, value := range SecurityHeaders {
+				w.Header().Set(key, value)
+			}
+
+			nonce, ok := r.Context().Value(CSPNonceKey{}).(string)
+			w.Header().Set("Content-Security-Policy", opts.contentSecurityPolicy(nonce, ok))
+			if hsts != "" {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
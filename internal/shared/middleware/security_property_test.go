@@ -36,8 +36,8 @@ func TestSecurityHeaders_Property12(t *testing.T) {
 
 		// Check all required security headers
 		requiredHeaders := map[string]string{
-			"X-Content-Type-Options": "nosniff",
-			"X-Frame-Options":        "DENY",
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "DENY",
 			"Content-Security-Policy": "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; object-src 'none'",
 		}
 
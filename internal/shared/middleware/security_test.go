@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -19,10 +21,10 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 
 	// Check all security headers are present
 	expectedHeaders := map[string]string{
-		"X-Content-Type-Options": "nosniff",
-		"X-Frame-Options":        "DENY",
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
 		"Content-Security-Policy": "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; object-src 'none'",
-		"X-XSS-Protection":       "1; mode=block",
+		"X-XSS-Protection":        "1; mode=block",
 	}
 
 	for header, expected := range expectedHeaders {
@@ -37,3 +39,62 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
 	}
 }
+
+func TestSecurityHeadersWithOptions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// The zero value matches SecurityHeadersMiddleware byte for byte
+	for _, withNonce := range []bool{false, true} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if withNonce {
+			req = req.WithContext(context.WithValue(req.Context(), CSPNonceKey{}, "abc"))
+		}
+		want := httptest.NewRecorder()
+		SecurityHeadersMiddleware(handler).ServeHTTP(want, req)
+		got := httptest.NewRecorder()
+		SecurityHeadersWithOptions(SecurityOptions{})(handler).ServeHTTP(got, req)
+
+		if !reflect.DeepEqual(got.Header(), want.Header()) {
+			t.Errorf("nonce=%v: zero options changed headers: %v vs %v", withNonce, got.Header(), want.Header())
+		}
+		if got.Header().Get("Strict-Transport-Security") != "" {
+			t.Error("HSTS should be disabled by default")
+		}
+	}
+
+	opts := SecurityOptions{
+		ScriptSrc:  []string{"https://static.example.com"},
+		StyleSrc:   []string{"https://fonts.example.com", "https://css.example.com"},
+		HSTSMaxAge: 31536000,
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), CSPNonceKey{}, "abc"))
+	rr := httptest.NewRecorder()
+	SecurityHeadersWithOptions(opts)(handler).ServeHTTP(rr, req)
+
+	wantCSP := "default-src 'self'; script-src 'self' 'nonce-abc' https://cdn.jsdelivr.net https://static.example.com; style-src 'self' 'unsafe-inline' https://fonts.example.com https://css.example.com; img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; object-src 'none'"
+	if got := rr.Header().Get("Content-Security-Policy"); got != wantCSP {
+		t.Errorf("CSP = %q, want %q", got, wantCSP)
+	}
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("HSTS = %q, want %q", got, "max-age=31536000")
+	}
+}
+
+func TestParseCSPSources(t *testing.T) {
+	sources, err := ParseCSPSources(" https://a.example.com, ,'unsafe-eval' ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(sources, []string{"https://a.example.com", "'unsafe-eval'"}) {
+		t.Errorf("unexpected sources: %v", sources)
+	}
+
+	for _, spec := range []string{"https://a.example.com; script-src *", "https://a.example.com https://b.example.com"} {
+		if _, err := ParseCSPSources(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"time-tracker/internal/shared/tracing"
+)
+
+// TracingMiddleware starts a server span for each request, continuing the
+// trace from an incoming traceparent header. Handlers start child spans from
+// the request context. It does nothing while tracing is disabled.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if parent, ok := tracing.ParseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path, tracing.SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+
+		rw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		span.SetAttribute("http.response.status_code", rw.status)
+		if rw.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("HTTP %d", rw.status))
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// VersionHeader carries the server version on every response.
+const VersionHeader = "X-TimeTracker-Version"
+
+// VersionHeaderMiddleware sets the X-TimeTracker-Version response header.
+func VersionHeaderMiddleware(version string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(VersionHeader, version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
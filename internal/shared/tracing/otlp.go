@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	otlpBatchSize     = 512
+	otlpQueueSize     = 2048
+	otlpFlushInterval = 5 * time.Second
+	otlpTimeout       = 10 * time.Second
+)
+
+// Config configures the OTLP exporter. An empty Endpoint disables tracing.
+type Config struct {
+	Endpoint    string // full URL of the traces endpoint, e.g. http://collector:4318/v1/traces
+	Headers     map[string]string
+	ServiceName string
+}
+
+// ConfigFromEnv reads the standard OpenTelemetry environment variables:
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (used as is) or OTEL_EXPORTER_OTLP_ENDPOINT
+// (with /v1/traces appended), OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME,
+// OTEL_TRACES_EXPORTER=none and OTEL_EXPORTER_OTLP_PROTOCOL, of which only
+// http/json is supported.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{ServiceName: os.Getenv("OTEL_SERVICE_NAME")}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "time-tracker"
+	}
+
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "none" {
+		return cfg, nil
+	}
+	if exporter != "" && exporter != "otlp" {
+		return cfg, fmt.Errorf("OTEL_TRACES_EXPORTER must be otlp or none")
+	}
+
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"} {
+		if protocol := os.Getenv(name); protocol != "" {
+			if protocol != "http/json" {
+				return cfg, fmt.Errorf("%s: only http/json is supported", name)
+			}
+			break
+		}
+	}
+
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		cfg.Endpoint = endpoint
+	} else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		cfg.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	} else {
+		return cfg, nil
+	}
+	if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return cfg, fmt.Errorf("OTLP endpoint must be an http or https URL")
+	}
+
+	if spec := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); spec != "" {
+		cfg.Headers = make(map[string]string)
+		for _, part := range strings.Split(spec, ",") {
+			key, value, ok := strings.Cut(part, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return cfg, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS must be key=value pairs")
+			}
+			if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+				value = unescaped
+			}
+			cfg.Headers[strings.TrimSpace(key)] = value
+		}
+	}
+	return cfg, nil
+}
+
+// OTLPExporter batches spans and posts them to an OTLP/HTTP endpoint.
+// Spans are dropped, not queued without bound, if the collector falls behind.
+type OTLPExporter struct {
+	cfg    Config
+	client *http.Client
+	logger *slog.Logger
+	queue  chan *Span
+	done   chan struct{}
+}
+
+// NewOTLPExporter creates an exporter and starts its background sender.
+// Call Shutdown to send the remaining spans. A nil logger logs to
+// slog.Default().
+func NewOTLPExporter(cfg Config, logger *slog.Logger) *OTLPExporter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	e := &OTLPExporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: otlpTimeout},
+		logger: logger,
+		queue:  make(chan *Span, otlpQueueSize),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+	}
+}
+
+// Shutdown stops accepting spans and sends those still queued.
+func (e *OTLPExporter) Shutdown() {
+	close(e.queue)
+	<-e.done
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, otlpBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.logger.Error("tracing: failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (e *OTLPExporter) send(spans []*Span) error {
+	body, err := json.Marshal(encodeOTLP(e.cfg.ServiceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding, see opentelemetry-proto's trace.proto. IDs are hex
+// strings and 64-bit integers are decimal strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 2 = error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+func otlpAttribute(key string, value interface{}) otlpKeyValue {
+	switch v := value.(type) {
+	case bool:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"boolValue": v}}
+	case int64:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}}
+	case float64:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"doubleValue": v}}
+	default:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"stringValue": fmt.Sprint(v)}}
+	}
+}
+
+func encodeOTLP(serviceName string, spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		}
+		if s.Parent != (SpanID{}) {
+			span.ParentSpanID = s.Parent.String()
+		}
+		for _, a := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttribute(a.Key, a.Value))
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Code: 2, Message: s.Error}
+		}
+		s.mu.Unlock()
+		encoded = append(encoded, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpAttribute("service.name", serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "time-tracker"}, Spans: encoded}},
+	}}}
+}
@@ -0,0 +1,235 @@
+// Package tracing records request and database spans and exports them to an
+// OpenTelemetry collector over OTLP/HTTP (JSON encoding).
+//
+// It implements the small part of OpenTelemetry the server needs: W3C
+// traceparent propagation, parent/child spans carried in a context, and a
+// batching OTLP exporter. Tracing is off until SetExporter is called, in
+// which case Start returns a nil span and costs nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind follows the OTLP span kinds.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// TraceID and SpanID identify traces and spans as in the W3C Trace Context.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// SpanContext is the propagated identity of a span.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// ParseTraceparent parses a W3C traceparent header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Traceparent formats sc as a W3C traceparent header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Attribute is a span attribute. Value is a string, bool, int64 or float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a timed operation. All methods are safe on a nil Span, which is
+// what Start returns while tracing is disabled.
+type Span struct {
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	Parent     SpanID // zero for a root span
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+	Error      string // set by SetError; the span status is then an error
+	ended      atomic.Bool
+	mu         sync.Mutex
+	exporter   Exporter
+}
+
+// SetAttribute adds an attribute to the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	switch v := value.(type) {
+	case int:
+		value = int64(v)
+	case string, bool, int64, float64:
+	default:
+		value = fmt.Sprint(v)
+	}
+	s.mu.Lock()
+	s.Attributes = append(s.Attributes, Attribute{Key: key, Value: value})
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter. Only the first call counts.
+func (s *Span) End() {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+	s.EndTime = time.Now()
+	s.exporter.ExportSpan(s)
+}
+
+// Exporter receives finished spans. ExportSpan must not block.
+type Exporter interface {
+	ExportSpan(s *Span)
+}
+
+type exporterHolder struct {
+	exporter Exporter
+}
+
+var current atomic.Pointer[exporterHolder]
+
+// SetExporter enables tracing with e, or disables it when e is nil.
+func SetExporter(e Exporter) {
+	if e == nil {
+		current.Store(nil)
+		return
+	}
+	current.Store(&exporterHolder{exporter: e})
+}
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithRemoteParent returns ctx with a parent received from another
+// service, so the next span started from it joins that trace.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanFromContext returns the current span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start begins a span as a child of the span (or remote parent) in ctx and
+// returns a context carrying it. While tracing is disabled it returns ctx
+// unchanged and a nil span.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	holder := current.Load()
+	if holder == nil {
+		return ctx, nil
+	}
+
+	span := &Span{Name: name, Kind: kind, StartTime: time.Now(), exporter: holder.exporter}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.Context.TraceID = parent.Context.TraceID
+		span.Context.Sampled = parent.Context.Sampled
+		span.Parent = parent.Context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok && remote.IsValid() {
+		span.Context.TraceID = remote.TraceID
+		span.Context.Sampled = remote.Sampled
+		span.Parent = remote.SpanID
+	} else {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = true
+	}
+	rand.Read(span.Context.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Recorder is an Exporter that keeps spans in memory, for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *Recorder) ExportSpan(s *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the finished spans in the order they ended.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok {
+		t.Fatal("expected valid traceparent")
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("unexpected span context: %+v", sc)
+	}
+	if sc.Traceparent() != header {
+		t.Errorf("round trip: got %q", sc.Traceparent())
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestStart_DisabledIsNoOp(t *testing.T) {
+	SetExporter(nil)
+
+	ctx := context.Background()
+	got, span := Start(ctx, "op", SpanKindInternal)
+	if span != nil || got != ctx {
+		t.Fatal("expected no span while tracing is disabled")
+	}
+	// Methods are safe on the nil span
+	span.SetAttribute("k", "v")
+	span.SetError(io.EOF)
+	span.End()
+}
+
+func TestStart_Hierarchy(t *testing.T) {
+	rec := &Recorder{}
+	SetExporter(rec)
+	defer SetExporter(nil)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithRemoteParent(context.Background(), remote)
+
+	ctx, server := Start(ctx, "server", SpanKindServer)
+	_, child := Start(ctx, "child", SpanKindClient)
+	child.End()
+	server.End()
+	server.End() // ending twice exports once
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if server.Context.TraceID != remote.TraceID || server.Parent != remote.SpanID {
+		t.Errorf("server span did not continue the remote trace: %+v", server.Context)
+	}
+	if child.Context.TraceID != remote.TraceID || child.Parent != server.Context.SpanID {
+		t.Errorf("child span is not a child of the server span")
+	}
+
+	_, root := Start(context.Background(), "root", SpanKindInternal)
+	if root.Parent != (SpanID{}) || !root.Context.IsValid() {
+		t.Errorf("expected a new root span, got %+v", root)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("unset disables tracing", func(t *testing.T) {
+		cfg, err := ConfigFromEnv()
+		if err != nil || cfg.Endpoint != "" {
+			t.Fatalf("expected no endpoint, got %+v (%v)", cfg, err)
+		}
+	})
+
+	t.Run("base endpoint", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+		t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer%20token,x-team=time")
+		t.Setenv("OTEL_SERVICE_NAME", "timelog")
+		cfg, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Endpoint != "http://collector:4318/v1/traces" || cfg.ServiceName != "timelog" {
+			t.Errorf("unexpected config: %+v", cfg)
+		}
+		if cfg.Headers["authorization"] != "Bearer token" || cfg.Headers["x-team"] != "time" {
+			t.Errorf("unexpected headers: %v", cfg.Headers)
+		}
+	})
+
+	t.Run("traces endpoint used as is", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://ignored:4318")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "https://collector/custom")
+		cfg, err := ConfigFromEnv()
+		if err != nil || cfg.Endpoint != "https://collector/custom" {
+			t.Fatalf("unexpected config: %+v (%v)", cfg, err)
+		}
+	})
+
+	t.Run("exporter none", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+		t.Setenv("OTEL_TRACES_EXPORTER", "none")
+		cfg, err := ConfigFromEnv()
+		if err != nil || cfg.Endpoint != "" {
+			t.Fatalf("expected tracing disabled, got %+v (%v)", cfg, err)
+		}
+	})
+
+	for name, env := range map[string][2]string{
+		"grpc protocol": {"OTEL_EXPORTER_OTLP_PROTOCOL", "grpc"},
+		"bad endpoint":  {"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "collector:4318"},
+		"bad exporter":  {"OTEL_TRACES_EXPORTER", "zipkin"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/v1/traces")
+			t.Setenv(env[0], env[1])
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Fatalf("expected error for %s=%s", env[0], env[1])
+			}
+		})
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	e := NewOTLPExporter(Config{Endpoint: srv.URL + "/v1/traces", ServiceName: "timelog", Headers: map[string]string{"X-Team": "time"}}, nil)
+	SetExporter(e)
+	ctx, parent := Start(context.Background(), "GET /api/v1/sessions", SpanKindServer)
+	_, child := Start(ctx, "sqlite list_sessions", SpanKindClient)
+	child.SetAttribute("rows", 3)
+	child.SetError(io.EOF)
+	child.End()
+	parent.End()
+	SetExporter(nil)
+	e.Shutdown()
+
+	if header.Get("Content-Type") != "application/json" || header.Get("X-Team") != "time" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+
+	var req otlpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("export is not JSON: %v\n%s", err, body)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID || spans[0].TraceID != spans[1].TraceID {
+		t.Errorf("unexpected span hierarchy: %+v", spans)
+	}
+	if spans[0].Status.Code != 2 || spans[0].Kind != SpanKindClient {
+		t.Errorf("unexpected child span: %+v", spans[0])
+	}
+	if !strings.Contains(string(body), `"intValue":"3"`) || !strings.Contains(string(body), `"stringValue":"timelog"`) {
+		t.Errorf("unexpected attribute encoding: %s", body)
+	}
+}
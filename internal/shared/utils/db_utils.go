@@ -27,7 +27,9 @@ func BuildWhereClause(conditions []string) string {
 }
 
 // BuildUpdateQueryFromStruct builds update parts for a given struct.
-// It assumes the struct fields are pointers.
+// It assumes the struct fields are pointers, or implement IsSet to tell a
+// given field from an omitted one; those are passed to the query as they
+// are, so they should be driver.Valuers that can write NULL.
 // It takes a map of "FieldName" -> "column_name".
 func BuildUpdateQueryFromStruct(data interface{}, fieldToCol map[string]string) ([]string, []interface{}) {
 	val := reflect.ValueOf(data)
@@ -48,6 +50,13 @@ func BuildUpdateQueryFromStruct(data interface{}, fieldToCol map[string]string)
 		}
 
 		value := val.Field(i)
+		if opt, ok := value.Interface().(interface{ IsSet() bool }); ok {
+			if opt.IsSet() {
+				updates = append(updates, fmt.Sprintf("%s = ?", colName))
+				args = append(args, opt)
+			}
+			continue
+		}
 		if !value.IsNil() {
 			// value is a pointer, we need the value it points to
 			updates = append(updates, fmt.Sprintf("%s = ?", colName))
@@ -6,6 +6,9 @@ import (
 	"strconv"
 )
 
+// UTF8BOM is the byte order mark prepended to CSV exports for Excel compatibility.
+var UTF8BOM = []byte{0xEF, 0xBB, 0xBF}
+
 // FormatDuration formats duration in seconds to H:MM:SS format.
 func FormatDuration(durationSec *int64) string {
 	if durationSec == nil {
@@ -26,6 +29,14 @@ func PtrToString(s *string) string {
 	return *s
 }
 
+// PtrToInt64String formats an int64 pointer, returning empty string if nil.
+func PtrToInt64String(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
 // ParsePaginationParams parses limit and offset from query parameters.
 func ParsePaginationParams(query url.Values, defaultLimit int, maxLimit int) (int, int) {
 	limit := defaultLimit
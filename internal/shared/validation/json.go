@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ErrEmptyBody is returned by DecodeJSON when the request has no body, so
+// handlers whose body is optional can tell it from an invalid one.
+var ErrEmptyBody = errors.New("request body must not be empty")
+
+// DecodeJSON decodes the request body into dst. The body is limited to
+// maxBytes, must be a single JSON value and may only contain fields that dst
+// declares. The returned error's message is safe to show to the client.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return jsonDecodeError(err)
+	}
+	// Anything but the end of the body after the value is an error
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return jsonDecodeError(err)
+		}
+		return fmt.Errorf("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// jsonDecodeError describes a decoding failure without echoing the body.
+func jsonDecodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return fmt.Errorf("request body must not exceed %d bytes", tooLarge.Limit)
+	case errors.Is(err, io.EOF):
+		return ErrEmptyBody
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("request body contains incomplete JSON")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("request body contains invalid JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Errorf("request body must be %s", jsonTypeName(typeErr.Type))
+		}
+		return fmt.Errorf("field %q must be %s", typeErr.Field, jsonTypeName(typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return fmt.Errorf("Invalid JSON body")
+	}
+}
+
+// jsonTypeName describes a Go type in JSON terms.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
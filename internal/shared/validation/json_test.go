@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type input struct {
+		Category string  `json:"category"`
+		Task     string  `json:"task"`
+		Note     *string `json:"note"`
+		ID       int64   `json:"id"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"valid", `{"category":"work","task":"x","note":null}`, ""},
+		{"valid with trailing whitespace", "{\"task\":\"x\"}\n\t ", ""},
+		{"unknown field", `{"catagory":"work"}`, `unknown field "catagory"`},
+		{"trailing garbage", `{"task":"x"} garbage`, "request body must contain a single JSON value"},
+		{"two values", `{"task":"x"}{"task":"y"}`, "request body must contain a single JSON value"},
+		{"empty", ``, "request body must not be empty"},
+		{"truncated", `{"task":`, "request body contains incomplete JSON"},
+		{"syntax error", `{"task" "x"}`, "request body contains invalid JSON at offset 9"},
+		{"wrong field type", `{"id":"7"}`, `field "id" must be a number`},
+		{"wrong pointer field type", `{"note":3}`, `field "note" must be a string`},
+		{"not an object", `["x"]`, "request body must be an object"},
+		{"oversized", `{"task":"` + strings.Repeat("a", 100) + `"}`, "request body must not exceed 64 bytes"},
+		{"oversized after value", `{"task":"x"}` + strings.Repeat(" ", 100), "request body must not exceed 64 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			var dst input
+			err := DecodeJSON(httptest.NewRecorder(), req, &dst, 64)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@
 package validation
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"time-tracker/internal/shared/config"
 )
 
 // SanitizeString cleans a string input by:
@@ -99,17 +103,85 @@ func ParseIntParam(s string, defaultVal, minVal, maxVal int) int {
 	return val
 }
 
-// ValidateStringLength checks if a string length is within bounds.
+// ValidateStringLength checks if a string length, counted in runes, is
+// within bounds.
 func ValidateStringLength(s string, minLen, maxLen int) bool {
-	length := len(s)
+	length := utf8.RuneCountInString(s)
 	return length >= minLen && length <= maxLen
 }
 
-// TruncateString truncates a string to the specified maximum length.
-// If the string is already within the limit, it is returned unchanged.
+// TruncateString truncates a string to at most maxLen runes, never cutting
+// one in half. Invalid UTF-8 sequences are dropped, so the result is always
+// valid UTF-8. A string already within the limit is returned unchanged.
 func TruncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	if maxLen <= 0 {
+		return ""
+	}
+	n := 0
+	for i := range s {
+		if n == maxLen {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+// ParseTimeBound parses a filter bound given either as an RFC3339 timestamp or
+// as a bare YYYY-MM-DD date interpreted as midnight in loc.
+// When end is true a bare date is moved to the following midnight so it can be
+// used as an exclusive upper bound covering the whole day.
+// The result is returned as an RFC3339 UTC string with milliseconds, matching
+// the stored format.
+func ParseTimeBound(s string, loc *time.Location, end bool) (string, error) {
+	s = strings.TrimSpace(s)
+	if loc == nil {
+		loc = time.UTC
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(config.TimestampLayout), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return "", err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.UTC().Format(config.TimestampLayout), nil
+}
+
+// ResolveRange converts a relative range name (today, yesterday, this_week,
+// this_month, last_7_days, last_30_days) into RFC3339 UTC [from, to) bounds,
+// using calendar days in loc. Weeks start on Monday.
+func ResolveRange(name string, now time.Time, loc *time.Location) (string, string, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	var from, to time.Time
+	switch strings.TrimSpace(name) {
+	case "today":
+		from, to = today, tomorrow
+	case "yesterday":
+		from, to = today.AddDate(0, 0, -1), today
+	case "this_week":
+		offset := (int(today.Weekday()) + 6) % 7
+		from, to = today.AddDate(0, 0, -offset), tomorrow
+	case "this_month":
+		from, to = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc), tomorrow
+	case "last_7_days":
+		from, to = today.AddDate(0, 0, -6), tomorrow
+	case "last_30_days":
+		from, to = today.AddDate(0, 0, -29), tomorrow
+	default:
+		return "", "", fmt.Errorf("unknown range %q", name)
 	}
-	return s[:maxLen]
+	return from.UTC().Format(config.TimestampLayout), to.UTC().Format(config.TimestampLayout), nil
 }
@@ -2,6 +2,10 @@ package validation
 
 import (
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"pgregory.net/rapid"
 )
 
 func TestSanitizeString(t *testing.T) {
@@ -213,6 +217,27 @@ func TestValidateStringLength(t *testing.T) {
 			maxLen:   10,
 			expected: true,
 		},
+		{
+			name:     "CJK at maximum",
+			input:    "阅读英语文章十分钟",
+			minLen:   1,
+			maxLen:   9,
+			expected: true,
+		},
+		{
+			name:     "CJK over maximum",
+			input:    "阅读英语文章十分钟",
+			minLen:   1,
+			maxLen:   8,
+			expected: false,
+		},
+		{
+			name:     "emoji at maximum",
+			input:    "😀😀😀",
+			minLen:   1,
+			maxLen:   3,
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -251,6 +276,36 @@ func TestTruncateString(t *testing.T) {
 			maxLen:   5,
 			expected: "hello",
 		},
+		{
+			name:     "CJK truncated on a rune boundary",
+			input:    "阅读英语文章",
+			maxLen:   4,
+			expected: "阅读英语",
+		},
+		{
+			name:     "CJK exact length",
+			input:    "阅读英语",
+			maxLen:   4,
+			expected: "阅读英语",
+		},
+		{
+			name:     "emoji truncated",
+			input:    "😀好😀好",
+			maxLen:   3,
+			expected: "😀好😀",
+		},
+		{
+			name:     "invalid UTF-8 dropped",
+			input:    "ab\xffcd",
+			maxLen:   3,
+			expected: "abc",
+		},
+		{
+			name:     "zero length",
+			input:    "阅读",
+			maxLen:   0,
+			expected: "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -264,6 +319,25 @@ func TestTruncateString(t *testing.T) {
 	}
 }
 
+func TestTruncateString_AlwaysValidUTF8(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		input := rapid.String().Draw(t, "input")
+		if rapid.Bool().Draw(t, "corrupt") && len(input) > 0 {
+			// Cut somewhere inside the bytes, possibly mid-rune
+			input = input[:rapid.IntRange(0, len(input)-1).Draw(t, "cut")]
+		}
+		maxLen := rapid.IntRange(0, 20).Draw(t, "maxLen")
+
+		result := TruncateString(input, maxLen)
+		if !utf8.ValidString(result) {
+			t.Fatalf("TruncateString(%q, %d) = %q is not valid UTF-8", input, maxLen, result)
+		}
+		if n := utf8.RuneCountInString(result); n > maxLen {
+			t.Fatalf("TruncateString(%q, %d) kept %d runes", input, maxLen, n)
+		}
+	})
+}
+
 func TestContainsControlChars(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -352,6 +426,39 @@ func TestRemoveControlChars(t *testing.T) {
 	}
 }
 
+func TestResolveRange(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	// Wednesday 2024-03-13 01:30 in UTC+8, still 2024-03-12 in UTC
+	now := time.Date(2024, 3, 12, 17, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"today", "2024-03-12T16:00:00.000Z", "2024-03-13T16:00:00.000Z"},
+		{"yesterday", "2024-03-11T16:00:00.000Z", "2024-03-12T16:00:00.000Z"},
+		{"this_week", "2024-03-10T16:00:00.000Z", "2024-03-13T16:00:00.000Z"},
+		{"this_month", "2024-02-29T16:00:00.000Z", "2024-03-13T16:00:00.000Z"},
+		{"last_7_days", "2024-03-06T16:00:00.000Z", "2024-03-13T16:00:00.000Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := ResolveRange(tt.name, now, loc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if from != tt.from || to != tt.to {
+				t.Errorf("ResolveRange(%q) = [%s, %s), want [%s, %s)", tt.name, from, to, tt.from, tt.to)
+			}
+		})
+	}
+
+	if _, _, err := ResolveRange("fortnight", now, loc); err == nil {
+		t.Error("expected error for unknown range")
+	}
+}
+
 // Helper function to create string pointer
 func strPtr(s string) *string {
 	return &s
@@ -6,7 +6,11 @@ import (
 	"strconv"
 	"strings"
 
+	"time-tracker/internal/audit"
+	"time-tracker/internal/shared/config"
 	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/shared/validation"
 )
 
 // SessionTagsRequest is the request body for assigning tags to a session
@@ -15,22 +19,62 @@ type SessionTagsRequest struct {
 }
 
 type TagsHandler struct {
-	service *TagService
+	service      *TagService
+	audit        *audit.Logger
+	maxBodyBytes int64
 }
 
 func NewTagsHandler(svc *TagService) *TagsHandler {
-	return &TagsHandler{service: svc}
+	return &TagsHandler{service: svc, maxBodyBytes: config.MaxJSONBodyBytes}
+}
+
+// SetAudit records tag deletions and bulk assignments to the audit log.
+func (h *TagsHandler) SetAudit(logger *audit.Logger) {
+	h.audit = logger
+}
+
+// SetMaxBodyBytes overrides the maximum size of a JSON request body.
+func (h *TagsHandler) SetMaxBodyBytes(max int64) {
+	if max > 0 {
+		h.maxBodyBytes = max
+	}
 }
 
 func (h *TagsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	switch {
-	case path == "/api/v1/tags" && r.Method == http.MethodPost:
-		h.Create(w, r)
-	case path == "/api/v1/tags" && r.Method == http.MethodGet:
-		h.List(w, r)
-	case strings.HasPrefix(path, "/api/v1/tags/") && r.Method == http.MethodGet:
-		h.Get(w, r)
+	case path == "/api/v1/tags":
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+		}
+	case strings.HasPrefix(path, "/api/v1/tags/") && strings.HasSuffix(path, "/bulk-assign"):
+		if r.Method == http.MethodPost {
+			h.BulkAssign(w, r)
+		} else {
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodPost))
+		}
+	case path == "/api/v1/tags/stats":
+		if r.Method == http.MethodGet {
+			h.Stats(w, r)
+		} else {
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet))
+		}
+	case strings.HasPrefix(path, "/api/v1/tags/"):
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		case http.MethodPatch:
+			h.Update(w, r)
+		case http.MethodDelete:
+			h.Delete(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPatch, http.MethodDelete))
+		}
 	// Session-tags association endpoints
 	case strings.HasPrefix(path, "/api/v1/sessions/") && strings.HasSuffix(path, "/tags"):
 		switch r.Method {
@@ -39,14 +83,14 @@ func (h *TagsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case http.MethodGet:
 			h.ListSessionTags(w, r)
 		default:
-			errors.WriteError(w, errors.NotFoundError("Method not allowed"))
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
 		}
 	case strings.HasPrefix(path, "/api/v1/sessions/") && strings.Count(path, "/") == 6:
 		// DELETE /api/v1/sessions/:id/tags/:tag_id
 		if r.Method == http.MethodDelete {
 			h.RemoveTagFromSession(w, r)
 		} else {
-			errors.WriteError(w, errors.NotFoundError("Method not allowed"))
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodDelete))
 		}
 	default:
 		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
@@ -55,14 +99,14 @@ func (h *TagsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (h *TagsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input TagCreate
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		errors.WriteError(w, errors.ValidationError("Invalid JSON body"))
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
 		return
 	}
-	created, err := h.service.Create(&input)
+	created, err := h.service.CreateContext(r.Context(), &input)
 	if err != nil {
 		if strings.Contains(err.Error(), "validation error") {
-			errors.WriteError(w, errors.ValidationError(strings.TrimPrefix(err.Error(), "validation error: ")))
+			errors.WriteError(w, errors.ValidationErrorFor(err))
 			return
 		}
 		errors.WriteError(w, err)
@@ -74,7 +118,36 @@ func (h *TagsHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TagsHandler) List(w http.ResponseWriter, r *http.Request) {
-	items, err := h.service.List()
+	query := r.URL.Query()
+
+	var filter TagFilter
+	if p := query.Get("parent_id"); p != "" {
+		parentID, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || parentID < 0 {
+			errors.WriteError(w, errors.ValidationError("Invalid parent_id"))
+			return
+		}
+		filter.ParentID = &parentID
+	}
+	if prefix := validation.SanitizeString(query.Get("name_prefix")); prefix != "" {
+		filter.NamePrefix = &prefix
+	}
+	filter.IncludeArchived = query.Get("include_archived") == "true"
+
+	// The paginated envelope is opt-in until clients have migrated off the bare array
+	if query.Get("paginated") == "true" {
+		limit, offset := utils.ParsePaginationParams(query, config.DefaultPageSize, config.MaxTagPageSize)
+		page, err := h.service.ListPageContext(r.Context(), filter, limit, offset)
+		if err != nil {
+			errors.WriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	items, err := h.service.ListContext(r.Context(), filter)
 	if err != nil {
 		errors.WriteError(w, err)
 		return
@@ -90,19 +163,92 @@ func (h *TagsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		errors.WriteError(w, errors.ValidationError("Invalid id"))
 		return
 	}
-	tag, err := h.service.Get(id)
+	tag, err := h.service.GetContext(r.Context(), id)
 	if err != nil {
+		if err == ErrTagNotFound {
+			errors.WriteError(w, errors.NotFoundError("Tag not found"))
+			return
+		}
 		errors.WriteError(w, err)
 		return
 	}
-	if tag == nil {
-		errors.WriteError(w, errors.NotFoundError("Tag not found"))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tag)
+}
+
+// Update handles PATCH /api/v1/tags/:id
+func (h *TagsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/v1/tags/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		errors.WriteError(w, errors.ValidationError("Invalid id"))
+		return
+	}
+
+	var input TagUpdate
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+
+	tag, err := h.service.UpdateContext(r.Context(), id, &input)
+	if err != nil {
+		if err == ErrTagNotFound {
+			errors.WriteError(w, errors.NotFoundError("Tag not found"))
+			return
+		}
+		if strings.Contains(err.Error(), "validation error") {
+			errors.WriteError(w, errors.ValidationErrorFor(err))
+			return
+		}
+		errors.WriteError(w, err)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(tag)
 }
 
+// Delete handles DELETE /api/v1/tags/:id
+// Tags with children are refused unless ?orphan_children=true is given.
+func (h *TagsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/v1/tags/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		errors.WriteError(w, errors.ValidationError("Invalid id"))
+		return
+	}
+
+	orphanChildren := r.URL.Query().Get("orphan_children") == "true"
+	if err := h.service.DeleteContext(r.Context(), id, orphanChildren); err != nil {
+		switch err {
+		case ErrTagNotFound:
+			errors.WriteError(w, errors.NotFoundError("Tag not found"))
+		case ErrTagHasChildren:
+			errors.WriteError(w, errors.NewConflictError("Tag has child tags; retry with orphan_children=true to detach them", nil))
+		default:
+			errors.WriteError(w, err)
+		}
+		return
+	}
+	h.audit.Record(r, audit.EventTagDeleted, map[string]interface{}{"tag_id": id, "orphan_children": orphanChildren})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Stats handles GET /api/v1/tags/stats - tracked time per tag
+// With ?rollup=true child totals are added to their parents.
+func (h *TagsHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	rollup := r.URL.Query().Get("rollup") == "true"
+	stats, err := h.service.StatsContext(r.Context(), rollup)
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
 // AssignTagsToSession assigns tags to a session
 func (h *TagsHandler) AssignTagsToSession(w http.ResponseWriter, r *http.Request) {
 	// Extract session ID from path
@@ -115,12 +261,20 @@ func (h *TagsHandler) AssignTagsToSession(w http.ResponseWriter, r *http.Request
 	}
 
 	var input SessionTagsRequest
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		errors.WriteError(w, errors.ValidationError("Invalid JSON body"))
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
 		return
 	}
 
-	if err := h.service.AssignToSession(sessionID, input.TagIDs); err != nil {
+	if err := h.service.AssignToSessionContext(r.Context(), sessionID, input.TagIDs); err != nil {
+		if err == ErrSessionMissing {
+			errors.WriteError(w, errors.NotFoundError("Session not found"))
+			return
+		}
+		if strings.Contains(err.Error(), "validation error") {
+			errors.WriteError(w, errors.ValidationErrorFor(err))
+			return
+		}
 		errors.WriteError(w, err)
 		return
 	}
@@ -149,7 +303,11 @@ func (h *TagsHandler) RemoveTagFromSession(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := h.service.RemoveFromSession(sessionID, tagID); err != nil {
+	if err := h.service.RemoveFromSessionContext(r.Context(), sessionID, tagID); err != nil {
+		if err == ErrAssociationNotFound {
+			errors.WriteError(w, errors.NotFoundError("Session does not have this tag"))
+			return
+		}
 		errors.WriteError(w, err)
 		return
 	}
@@ -167,7 +325,7 @@ func (h *TagsHandler) ListSessionTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tags, err := h.service.ListForSession(sessionID)
+	tags, err := h.service.ListForSessionContext(r.Context(), sessionID)
 	if err != nil {
 		errors.WriteError(w, err)
 		return
@@ -176,3 +334,38 @@ func (h *TagsHandler) ListSessionTags(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(tags)
 }
+
+// BulkAssign handles POST /api/v1/tags/:id/bulk-assign - tags all sessions matching a filter
+func (h *TagsHandler) BulkAssign(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/tags/")
+	path = strings.TrimSuffix(path, "/bulk-assign")
+	tagID, err := strconv.ParseInt(path, 10, 64)
+	if err != nil || tagID <= 0 {
+		errors.WriteError(w, errors.ValidationError("Invalid tag id"))
+		return
+	}
+
+	var input BulkAssignFilter
+	if err := validation.DecodeJSON(w, r, &input, h.maxBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+
+	result, err := h.service.BulkAssignContext(r.Context(), tagID, &input)
+	if err != nil {
+		if err == ErrTagNotFound {
+			errors.WriteError(w, errors.NotFoundError("Tag not found"))
+			return
+		}
+		if strings.Contains(err.Error(), "validation error") {
+			errors.WriteError(w, errors.ValidationErrorFor(err))
+			return
+		}
+		errors.WriteError(w, err)
+		return
+	}
+	h.audit.Record(r, audit.EventTagBulkAssigned, map[string]interface{}{"tag_id": tagID, "filter": input, "tagged": result.Tagged})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
@@ -2,36 +2,27 @@ package tags
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strconv"
 	"strings"
 	"testing"
 
 	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
 	"time-tracker/internal/shared/database"
 )
 
 func TestTagsHandler_CreateAndList(t *testing.T) {
-	tmp, err := os.CreateTemp("", "tags_handler_*.db")
-	if err != nil {
-		t.Fatal(err)
-	}
-	_ = tmp.Close()
-	defer os.Remove(tmp.Name())
-
-	db, err := database.New(tmp.Name())
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := database.NewForTesting(t)
 
 	repo := NewTagRepository(db)
 	svc := NewTagService(repo)
 	h := NewTagsHandler(svc)
 
-	createReq := httptest.NewRequest(http.MethodPost, "/api/v1/tags", strings.NewReader(`{"name":"工作","color":"#3B82F6"}`))
+	createReq := newRequest(http.MethodPost, "/api/v1/tags", strings.NewReader(`{"name":"工作","color":"#3B82F6"}`))
 	createReq.Header.Set("Content-Type", "application/json")
 	createW := httptest.NewRecorder()
 	h.ServeHTTP(createW, createReq)
@@ -51,7 +42,7 @@ func TestTagsHandler_CreateAndList(t *testing.T) {
 		t.Fatalf("expected name %q, got %q", "工作", created.Name)
 	}
 
-	listReq := httptest.NewRequest(http.MethodGet, "/api/v1/tags", nil)
+	listReq := newRequest(http.MethodGet, "/api/v1/tags", nil)
 	listW := httptest.NewRecorder()
 	h.ServeHTTP(listW, listReq)
 
@@ -69,18 +60,7 @@ func TestTagsHandler_CreateAndList(t *testing.T) {
 }
 
 func TestTagsHandler_SessionTagsAssociations(t *testing.T) {
-	tmp, err := os.CreateTemp("", "tags_session_*.db")
-	if err != nil {
-		t.Fatal(err)
-	}
-	_ = tmp.Close()
-	defer os.Remove(tmp.Name())
-
-	db, err := database.New(tmp.Name())
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := database.NewForTesting(t)
 
 	// Setup sessions and tags
 	sessionRepo := sessions.NewSessionRepository(db)
@@ -115,7 +95,7 @@ func TestTagsHandler_SessionTagsAssociations(t *testing.T) {
 	tag2ID := strconv.FormatInt(tag2.ID, 10)
 	assignPath := "/api/v1/sessions/" + sessionID + "/tags"
 	t.Logf("Assign path: %q", assignPath)
-	assignReq := httptest.NewRequest(http.MethodPost, assignPath,
+	assignReq := newRequest(http.MethodPost, assignPath,
 		strings.NewReader(`{"tag_ids":[`+tag1ID+`,`+tag2ID+`]}`))
 	assignReq.Header.Set("Content-Type", "application/json")
 	assignW := httptest.NewRecorder()
@@ -127,7 +107,7 @@ func TestTagsHandler_SessionTagsAssociations(t *testing.T) {
 	}
 
 	// Test GET /api/v1/sessions/:id/tags - list session tags
-	listReq := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/"+sessionID+"/tags", nil)
+	listReq := newRequest(http.MethodGet, "/api/v1/sessions/"+sessionID+"/tags", nil)
 	listW := httptest.NewRecorder()
 	h.ServeHTTP(listW, listReq)
 
@@ -144,7 +124,7 @@ func TestTagsHandler_SessionTagsAssociations(t *testing.T) {
 	}
 
 	// Test DELETE /api/v1/sessions/:id/tags/:tag_id - remove tag
-	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/v1/sessions/"+sessionID+"/tags/"+tag1ID, nil)
+	deleteReq := newRequest(http.MethodDelete, "/api/v1/sessions/"+sessionID+"/tags/"+tag1ID, nil)
 	deleteW := httptest.NewRecorder()
 	h.ServeHTTP(deleteW, deleteReq)
 
@@ -153,7 +133,7 @@ func TestTagsHandler_SessionTagsAssociations(t *testing.T) {
 	}
 
 	// Verify only one tag remains
-	listReq2 := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/"+sessionID+"/tags", nil)
+	listReq2 := newRequest(http.MethodGet, "/api/v1/sessions/"+sessionID+"/tags", nil)
 	listW2 := httptest.NewRecorder()
 	h.ServeHTTP(listW2, listReq2)
 
@@ -164,4 +144,140 @@ func TestTagsHandler_SessionTagsAssociations(t *testing.T) {
 	if len(remainingTags) != 1 {
 		t.Fatalf("expected 1 tag after deletion, got %d", len(remainingTags))
 	}
+
+	// Missing rows are 404s and unknown tags 400s, never 500s
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"get missing tag", http.MethodGet, "/api/v1/tags/999", "", http.StatusNotFound},
+		{"remove tag already removed", http.MethodDelete, "/api/v1/sessions/" + sessionID + "/tags/" + tag1ID, "", http.StatusNotFound},
+		{"remove from missing session", http.MethodDelete, "/api/v1/sessions/999/tags/" + tag2ID, "", http.StatusNotFound},
+		{"assign to missing session", http.MethodPost, "/api/v1/sessions/999/tags", `{"tag_ids":[` + tag2ID + `]}`, http.StatusNotFound},
+		{"assign missing tag", http.MethodPost, assignPath, `{"tag_ids":[999]}`, http.StatusBadRequest},
+	} {
+		req := newRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTagsHandler_MethodNotAllowed(t *testing.T) {
+	db := database.NewForTesting(t)
+	h := NewTagsHandler(NewTagService(NewTagRepository(db)))
+
+	for _, tt := range []struct {
+		method, path, allow string
+	}{
+		{http.MethodPut, "/api/v1/tags", "GET, POST"},
+		{http.MethodPost, "/api/v1/tags/stats", "GET"},
+		{http.MethodGet, "/api/v1/tags/1/bulk-assign", "POST"},
+		{http.MethodPut, "/api/v1/tags/1", "GET, PATCH, DELETE"},
+		{http.MethodDelete, "/api/v1/sessions/1/tags", "GET, POST"},
+		{http.MethodGet, "/api/v1/sessions/1/tags/1", "DELETE"},
+	} {
+		req := newRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status 405, got %d", tt.method, tt.path, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+		if !strings.Contains(w.Body.String(), "METHOD_NOT_ALLOWED") {
+			t.Errorf("%s %s: expected code METHOD_NOT_ALLOWED, got %s", tt.method, tt.path, w.Body.String())
+		}
+	}
+}
+
+func TestTagsHandler_ListPaginated(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	svc := NewTagService(NewTagRepository(db))
+	h := NewTagsHandler(svc)
+	for _, name := range []string{"client-a", "client-b", "client-c", "personal"} {
+		if _, err := svc.Create(&TagCreate{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := newRequest(http.MethodGet, "/api/v1/tags?paginated=true&name_prefix=client-&limit=2&offset=1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var page models.PaginatedResponse[Tag]
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode paginated response: %v", err)
+	}
+	if page.Total != 3 {
+		t.Fatalf("expected total 3, got %d", page.Total)
+	}
+	if len(page.Items) != 2 || page.Items[0].Name != "client-b" {
+		t.Fatalf("unexpected page items: %+v", page.Items)
+	}
+
+	// Without the flag the bare array is still returned
+	req = newRequest(http.MethodGet, "/api/v1/tags?name_prefix=client-", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var items []Tag
+	if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+		t.Fatalf("failed to decode list response: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 tags, got %d", len(items))
+	}
+}
+
+func TestTagsHandler_StrictJSON(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	h := NewTagsHandler(NewTagService(NewTagRepository(db)))
+	h.SetMaxBodyBytes(128)
+
+	for body, want := range map[string]string{
+		`{"name":"工作","colour":"#3B82F6"}`:            `unknown field "colour"`,
+		`{"name":"` + strings.Repeat("x", 200) + `"}`: "request body must not exceed 128 bytes",
+	} {
+		req := newRequest(http.MethodPost, "/api/v1/tags", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var resp struct {
+			Error struct{ Message string }
+		}
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp.Error.Message != want {
+			t.Errorf("expected 400 with %q, got %d %q", want, w.Code, resp.Error.Message)
+		}
+	}
+
+	req := newRequest(http.MethodGet, "/api/v1/tags", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected no tags to be created, got %s", w.Body.String())
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
 }
@@ -0,0 +1,41 @@
+package tags
+
+import "context"
+
+// TagRepositoryInterface defines the interface for tag repository operations.
+// TagService depends only on this, so tests can substitute a repository.
+// Every operation acts on the tags and sessions of one user.
+type TagRepositoryInterface interface {
+	Create(userID int64, input *TagCreate) (*Tag, error)
+	CreateContext(ctx context.Context, userID int64, input *TagCreate) (*Tag, error)
+	CreateIfMissing(userID int64, input *TagCreate) (bool, error)
+	CreateIfMissingContext(ctx context.Context, userID int64, input *TagCreate) (bool, error)
+	GetByID(userID, id int64) (*Tag, error)
+	GetByIDContext(ctx context.Context, userID, id int64) (*Tag, error)
+	GetByName(userID int64, name string) (*Tag, error)
+	GetByNameContext(ctx context.Context, userID int64, name string) (*Tag, error)
+	List(userID int64, filter TagFilter) ([]Tag, error)
+	ListContext(ctx context.Context, userID int64, filter TagFilter) ([]Tag, error)
+	ListPage(userID int64, filter TagFilter, limit, offset int) ([]Tag, error)
+	ListPageContext(ctx context.Context, userID int64, filter TagFilter, limit, offset int) ([]Tag, error)
+	Count(userID int64, filter TagFilter) (int64, error)
+	CountContext(ctx context.Context, userID int64, filter TagFilter) (int64, error)
+	Update(userID, id int64, input *TagUpdate) error
+	UpdateContext(ctx context.Context, userID, id int64, input *TagUpdate) error
+	CountChildren(userID, id int64) (int64, error)
+	CountChildrenContext(ctx context.Context, userID, id int64) (int64, error)
+	Delete(userID, id int64) error
+	DeleteContext(ctx context.Context, userID, id int64) error
+	Stats(userID int64) ([]TagStat, error)
+	StatsContext(ctx context.Context, userID int64) ([]TagStat, error)
+	AssignToSession(userID, sessionID int64, tagIDs []int64) error
+	AssignToSessionContext(ctx context.Context, userID, sessionID int64, tagIDs []int64) error
+	RemoveFromSession(userID, sessionID, tagID int64) error
+	RemoveFromSessionContext(ctx context.Context, userID, sessionID, tagID int64) error
+	ListForSession(userID, sessionID int64) ([]Tag, error)
+	ListForSessionContext(ctx context.Context, userID, sessionID int64) ([]Tag, error)
+	BulkAssign(userID, tagID int64, filter *BulkAssignFilter, max int) (int64, error)
+	BulkAssignContext(ctx context.Context, userID, tagID int64, filter *BulkAssignFilter, max int) (int64, error)
+}
+
+var _ TagRepositoryInterface = (*TagRepository)(nil)
@@ -2,7 +2,10 @@ package tags
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"time-tracker/internal/shared/validation"
 )
@@ -12,14 +15,56 @@ type Tag struct {
 	Name      string `json:"name"`
 	Color     string `json:"color"`
 	CreatedAt string `json:"created_at"`
+	ParentID  *int64 `json:"parent_id"`
+	Archived  bool   `json:"archived"`
 }
 
 type TagCreate struct {
-	Name  string `json:"name"`
-	Color string `json:"color"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	ParentID *int64 `json:"parent_id,omitempty"`
 }
 
-var ErrNameRequired = errors.New("name is required")
+// TagUpdate holds the fields of a PATCH request. Nil fields are left unchanged;
+// a parent_id of 0 detaches the tag from its parent.
+type TagUpdate struct {
+	Name     *string `json:"name,omitempty"`
+	Color    *string `json:"color,omitempty"`
+	ParentID *int64  `json:"parent_id,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// TagFilter narrows the tags returned by List.
+// A ParentID of 0 selects top-level tags. Archived tags are excluded
+// unless IncludeArchived is set.
+type TagFilter struct {
+	ParentID        *int64
+	NamePrefix      *string
+	IncludeArchived bool
+}
+
+// TagStat is the total tracked time for a tag across stopped sessions.
+type TagStat struct {
+	TagID        int64  `json:"tag_id"`
+	TagName      string `json:"tag_name"`
+	ParentID     *int64 `json:"parent_id"`
+	SessionCount int64  `json:"session_count"`
+	TotalSeconds int64  `json:"total_seconds"`
+}
+
+var (
+	ErrNameRequired   = errors.New("name is required")
+	ErrColorRequired  = errors.New("color must not be empty")
+	ErrInvalidParent  = errors.New("parent_id must be a positive tag id")
+	ErrParentNotFound = errors.New("parent tag not found")
+	ErrTagCycle       = errors.New("parent_id would create a cycle")
+	ErrTagHasChildren = errors.New("tag has child tags")
+	ErrTagArchived    = errors.New("tag is archived")
+	ErrSessionMissing = errors.New("session not found")
+	// ErrAssociationNotFound is returned when removing a tag a session
+	// does not carry.
+	ErrAssociationNotFound = errors.New("session-tag association not found")
+)
 
 func (t *TagCreate) Validate() error {
 	t.Name = validation.SanitizeString(t.Name)
@@ -33,5 +78,114 @@ func (t *TagCreate) Validate() error {
 		t.Color = "#6B7280"
 	}
 
+	if t.ParentID != nil && *t.ParentID <= 0 {
+		return ErrInvalidParent
+	}
+
 	return nil
 }
+
+func (t *TagUpdate) Validate() error {
+	if t.Name != nil {
+		name := validation.SanitizeString(*t.Name)
+		if name == "" {
+			return ErrNameRequired
+		}
+		t.Name = &name
+	}
+	if t.Color != nil {
+		color := strings.TrimSpace(*t.Color)
+		if color == "" {
+			return ErrColorRequired
+		}
+		t.Color = &color
+	}
+	if t.ParentID != nil && *t.ParentID < 0 {
+		return ErrInvalidParent
+	}
+
+	return nil
+}
+
+// BulkAssignFilter selects the sessions a tag should be bulk-assigned to.
+// From/To accept RFC3339 timestamps or YYYY-MM-DD dates (UTC); To is exclusive.
+type BulkAssignFilter struct {
+	Category     *string `json:"category,omitempty"`
+	From         *string `json:"from,omitempty"`
+	To           *string `json:"to,omitempty"`
+	TaskContains *string `json:"task_contains,omitempty"`
+	Confirm      bool    `json:"confirm,omitempty"`
+}
+
+// BulkAssignResult reports how many sessions were newly tagged.
+type BulkAssignResult struct {
+	Tagged int64 `json:"tagged"`
+}
+
+var (
+	ErrInvalidFrom       = errors.New("from must be an RFC3339 timestamp or YYYY-MM-DD date")
+	ErrInvalidTo         = errors.New("to must be an RFC3339 timestamp or YYYY-MM-DD date")
+	ErrFilterTooBroad    = errors.New("filter matches all sessions; set confirm to true to proceed")
+	ErrBulkAssignTooMany = errors.New("filter matches too many sessions")
+)
+
+// Validate sanitizes the filter and normalizes the date bounds to RFC3339 UTC.
+// An empty filter would match every session and is only allowed with Confirm set.
+func (f *BulkAssignFilter) Validate() error {
+	f.Category = validation.SanitizeStringPtr(f.Category)
+	f.TaskContains = validation.SanitizeStringPtr(f.TaskContains)
+	f.From = validation.SanitizeStringPtr(f.From)
+	f.To = validation.SanitizeStringPtr(f.To)
+
+	if f.From != nil {
+		from, err := validation.ParseTimeBound(*f.From, time.UTC, false)
+		if err != nil {
+			return ErrInvalidFrom
+		}
+		f.From = &from
+	}
+	if f.To != nil {
+		to, err := validation.ParseTimeBound(*f.To, time.UTC, true)
+		if err != nil {
+			return ErrInvalidTo
+		}
+		f.To = &to
+	}
+
+	if f.Category == nil && f.From == nil && f.To == nil && f.TaskContains == nil && !f.Confirm {
+		return ErrFilterTooBroad
+	}
+
+	return nil
+}
+
+var seedColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// ParseSeedTags parses a comma-separated list of name:color pairs such as
+// "工作:#3B82F6,学习:#10B981". Empty entries are skipped; any malformed pair
+// produces an error naming it.
+func ParseSeedTags(spec string) ([]TagCreate, error) {
+	out := []TagCreate{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		sep := strings.LastIndex(entry, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid seed tag %q: expected name:color", entry)
+		}
+		name := validation.SanitizeString(entry[:sep])
+		color := strings.TrimSpace(entry[sep+1:])
+		if name == "" {
+			return nil, fmt.Errorf("invalid seed tag %q: name is empty", entry)
+		}
+		if !seedColorPattern.MatchString(color) {
+			return nil, fmt.Errorf("invalid seed tag %q: color must look like #RRGGBB", entry)
+		}
+
+		out = append(out, TagCreate{Name: name, Color: color})
+	}
+	return out, nil
+}
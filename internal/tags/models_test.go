@@ -1,6 +1,9 @@
 package tags
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestTag_Validate_NameRequired(t *testing.T) {
 	tag := TagCreate{Name: "   "}
@@ -8,3 +11,31 @@ func TestTag_Validate_NameRequired(t *testing.T) {
 		t.Fatalf("expected validation error")
 	}
 }
+
+func TestParseSeedTags(t *testing.T) {
+	seeds, err := ParseSeedTags("工作:#3B82F6, 学习:#10B981,")
+	if err != nil {
+		t.Fatalf("expected valid spec, got %v", err)
+	}
+	if len(seeds) != 2 || seeds[0].Name != "工作" || seeds[1].Color != "#10B981" {
+		t.Fatalf("unexpected seeds: %+v", seeds)
+	}
+
+	invalid := []string{
+		"work",
+		"work:#3B82F6,play",
+		":#3B82F6",
+		"work:blue",
+		"work:#3B82F",
+	}
+	for _, spec := range invalid {
+		if _, err := ParseSeedTags(spec); err == nil {
+			t.Errorf("expected error for spec %q", spec)
+		}
+	}
+
+	_, err = ParseSeedTags("work:#3B82F6,play:red")
+	if err == nil || !strings.Contains(err.Error(), `"play:red"`) {
+		t.Fatalf("expected error naming the bad pair, got %v", err)
+	}
+}
@@ -1,10 +1,13 @@
 package tags
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/utils"
 )
 
 type TagRepository struct {
@@ -15,10 +18,37 @@ func NewTagRepository(db *database.DB) *TagRepository {
 	return &TagRepository{db: db}
 }
 
-func (r *TagRepository) Create(input *TagCreate) (*Tag, error) {
-	res, err := r.db.Exec(
-		`INSERT INTO tags (name, color, created_at) VALUES (?, ?, strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
-		input.Name, input.Color,
+// tagColumns is the column list shared by every tag query.
+const tagColumns = "id, name, color, created_at, parent_id, archived"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTag(row rowScanner) (*Tag, error) {
+	var t Tag
+	var parentID sql.NullInt64
+	if err := row.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt, &parentID, &t.Archived); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		t.ParentID = &parentID.Int64
+	}
+	return &t, nil
+}
+
+func (r *TagRepository) Create(userID int64, input *TagCreate) (*Tag, error) {
+	return r.CreateContext(context.Background(), userID, input)
+}
+
+// CreateContext is like Create, recording a span as a child of the one in ctx.
+func (r *TagRepository) CreateContext(ctx context.Context, userID int64, input *TagCreate) (created *Tag, err error) {
+	ctx, span := database.StartSpan(ctx, "create_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	res, err := r.db.ExecRetry(ctx,
+		`INSERT INTO tags (user_id, name, color, parent_id, created_at) VALUES (?, ?, ?, ?, strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
+		userID, input.Name, input.Color, input.ParentID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert tag: %w", err)
@@ -27,24 +57,146 @@ func (r *TagRepository) Create(input *TagCreate) (*Tag, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get last insert id: %w", err)
 	}
-	return r.GetByID(id)
+	return r.GetByIDContext(ctx, userID, id)
 }
 
-func (r *TagRepository) GetByID(id int64) (*Tag, error) {
-	var t Tag
-	err := r.db.QueryRow(`SELECT id, name, color, created_at FROM tags WHERE id = ?`, id).
-		Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt)
+// CreateIfMissing inserts the tag unless the user has one with the same name.
+// Returns true if a row was inserted.
+func (r *TagRepository) CreateIfMissing(userID int64, input *TagCreate) (bool, error) {
+	return r.CreateIfMissingContext(context.Background(), userID, input)
+}
+
+// CreateIfMissingContext is like CreateIfMissing, recording a span as a child of the one in ctx.
+func (r *TagRepository) CreateIfMissingContext(ctx context.Context, userID int64, input *TagCreate) (created bool, err error) {
+	ctx, span := database.StartSpan(ctx, "create_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	res, err := r.db.ExecRetry(ctx,
+		`INSERT OR IGNORE INTO tags (user_id, name, color, created_at) VALUES (?, ?, ?, strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
+		userID, input.Name, input.Color,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert tag: %w", err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check insert result: %w", err)
+	}
+	return inserted > 0, nil
+}
+
+// GetByID returns the user's tag with the given ID, or nil if the user has
+// none.
+func (r *TagRepository) GetByID(userID, id int64) (*Tag, error) {
+	return r.GetByIDContext(context.Background(), userID, id)
+}
+
+// GetByIDContext is like GetByID, recording a span as a child of the one in ctx.
+func (r *TagRepository) GetByIDContext(ctx context.Context, userID, id int64) (found *Tag, err error) {
+	ctx, span := database.StartSpan(ctx, "get_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	t, err := scanTag(r.db.Reader().QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags WHERE id = ? AND user_id = ?`, id, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tag: %w", err)
 	}
-	return &t, nil
+	return t, nil
+}
+
+// GetByName returns the user's tag with the given name, or nil if none exists.
+func (r *TagRepository) GetByName(userID int64, name string) (*Tag, error) {
+	return r.GetByNameContext(context.Background(), userID, name)
 }
 
-func (r *TagRepository) List() ([]Tag, error) {
-	rows, err := r.db.Query(`SELECT id, name, color, created_at FROM tags ORDER BY name ASC`)
+// GetByNameContext is like GetByName, recording a span as a child of the one in ctx.
+func (r *TagRepository) GetByNameContext(ctx context.Context, userID int64, name string) (found *Tag, err error) {
+	ctx, span := database.StartSpan(ctx, "get_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	t, err := scanTag(r.db.Reader().QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags WHERE user_id = ? AND name = ?`, userID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag: %w", err)
+	}
+	return t, nil
+}
+
+// where builds the WHERE clause for a tag filter on the user's tags.
+func (f TagFilter) where(userID int64) (string, []interface{}) {
+	args := []interface{}{userID}
+	conditions := []string{"user_id = ?"}
+
+	if f.ParentID != nil {
+		if *f.ParentID == 0 {
+			conditions = append(conditions, "parent_id IS NULL")
+		} else {
+			conditions = append(conditions, "parent_id = ?")
+			args = append(args, *f.ParentID)
+		}
+	}
+	if !f.IncludeArchived {
+		conditions = append(conditions, "archived = 0")
+	}
+	if f.NamePrefix != nil && *f.NamePrefix != "" {
+		conditions = append(conditions, "substr(name, 1, length(?)) = ?")
+		args = append(args, *f.NamePrefix, *f.NamePrefix)
+	}
+
+	return utils.BuildWhereClause(conditions), args
+}
+
+func (r *TagRepository) List(userID int64, filter TagFilter) ([]Tag, error) {
+	return r.ListContext(context.Background(), userID, filter)
+}
+
+// ListContext is like List, recording a span as a child of the one in ctx.
+func (r *TagRepository) ListContext(ctx context.Context, userID int64, filter TagFilter) (tags []Tag, err error) {
+	ctx, span := database.StartSpan(ctx, "list_tags")
+	defer func() { span.SetError(err); span.End() }()
+
+	where, args := filter.where(userID)
+	return r.queryTags(ctx, `SELECT `+tagColumns+` FROM tags`+where+` ORDER BY name ASC`, args...)
+}
+
+// ListPage returns one page of the user's tags matching the filter, ordered by name.
+func (r *TagRepository) ListPage(userID int64, filter TagFilter, limit, offset int) ([]Tag, error) {
+	return r.ListPageContext(context.Background(), userID, filter, limit, offset)
+}
+
+// ListPageContext is like ListPage, recording a span as a child of the one in ctx.
+func (r *TagRepository) ListPageContext(ctx context.Context, userID int64, filter TagFilter, limit, offset int) (tags []Tag, err error) {
+	ctx, span := database.StartSpan(ctx, "list_tags")
+	defer func() { span.SetError(err); span.End() }()
+
+	where, args := filter.where(userID)
+	args = append(args, limit, offset)
+	return r.queryTags(ctx, `SELECT `+tagColumns+` FROM tags`+where+` ORDER BY name ASC LIMIT ? OFFSET ?`, args...)
+}
+
+// Count returns the number of the user's tags matching the filter.
+func (r *TagRepository) Count(userID int64, filter TagFilter) (int64, error) {
+	return r.CountContext(context.Background(), userID, filter)
+}
+
+// CountContext is like Count, recording a span as a child of the one in ctx.
+func (r *TagRepository) CountContext(ctx context.Context, userID int64, filter TagFilter) (count int64, err error) {
+	ctx, span := database.StartSpan(ctx, "count_tags")
+	defer func() { span.SetError(err); span.End() }()
+
+	where, args := filter.where(userID)
+	if err := r.db.Reader().QueryRowContext(ctx, `SELECT COUNT(*) FROM tags`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count tags: %w", err)
+	}
+	return count, nil
+}
+
+func (r *TagRepository) queryTags(ctx context.Context, query string, args ...interface{}) ([]Tag, error) {
+	rows, err := r.db.Reader().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tags: %w", err)
 	}
@@ -52,11 +204,11 @@ func (r *TagRepository) List() ([]Tag, error) {
 
 	out := []Tag{}
 	for rows.Next() {
-		var t Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt); err != nil {
+		t, err := scanTag(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan tag: %w", err)
 		}
-		out = append(out, t)
+		out = append(out, *t)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("tags rows error: %w", err)
@@ -65,23 +217,199 @@ func (r *TagRepository) List() ([]Tag, error) {
 	return out, nil
 }
 
-func (r *TagRepository) AssignToSession(sessionID int64, tagIDs []int64) error {
-	for _, tagID := range tagIDs {
-		_, err := r.db.Exec(
-			`INSERT OR IGNORE INTO session_tags (session_id, tag_id) VALUES (?, ?)`,
-			sessionID, tagID,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to assign tag %d to session %d: %w", tagID, sessionID, err)
+// Update applies the non-nil fields of input to the user's tag.
+// A ParentID of 0 clears the parent.
+func (r *TagRepository) Update(userID, id int64, input *TagUpdate) error {
+	return r.UpdateContext(context.Background(), userID, id, input)
+}
+
+// UpdateContext is like Update, recording a span as a child of the one in ctx.
+func (r *TagRepository) UpdateContext(ctx context.Context, userID, id int64, input *TagUpdate) (err error) {
+	ctx, span := database.StartSpan(ctx, "update_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	updates := []string{}
+	args := []interface{}{}
+
+	if input.Name != nil {
+		updates = append(updates, "name = ?")
+		args = append(args, *input.Name)
+	}
+	if input.Color != nil {
+		updates = append(updates, "color = ?")
+		args = append(args, *input.Color)
+	}
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			updates = append(updates, "parent_id = NULL")
+		} else {
+			updates = append(updates, "parent_id = ?")
+			args = append(args, *input.ParentID)
 		}
 	}
+
+	if input.Archived != nil {
+		updates = append(updates, "archived = ?")
+		args = append(args, *input.Archived)
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	args = append(args, id, userID)
+	if _, err := r.db.ExecRetry(ctx, "UPDATE tags SET "+strings.Join(updates, ", ")+" WHERE id = ? AND user_id = ?", args...); err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
 	return nil
 }
 
-func (r *TagRepository) RemoveFromSession(sessionID, tagID int64) error {
-	res, err := r.db.Exec(
-		`DELETE FROM session_tags WHERE session_id = ? AND tag_id = ?`,
-		sessionID, tagID,
+// CountChildren returns the number of the user's tags whose parent is id.
+func (r *TagRepository) CountChildren(userID, id int64) (int64, error) {
+	return r.CountChildrenContext(context.Background(), userID, id)
+}
+
+// CountChildrenContext is like CountChildren, recording a span as a child of the one in ctx.
+func (r *TagRepository) CountChildrenContext(ctx context.Context, userID, id int64) (count int64, err error) {
+	ctx, span := database.StartSpan(ctx, "count_child_tags")
+	defer func() { span.SetError(err); span.End() }()
+
+	if err := r.db.Reader().QueryRowContext(ctx, `SELECT COUNT(*) FROM tags WHERE parent_id = ? AND user_id = ?`, id, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count child tags: %w", err)
+	}
+	return count, nil
+}
+
+// Delete removes one of the user's tags, detaching its children, sessions
+// and presets. Returns ErrTagNotFound if the user has no such tag.
+func (r *TagRepository) Delete(userID, id int64) error {
+	return r.DeleteContext(context.Background(), userID, id)
+}
+
+// DeleteContext is like Delete, recording a span as a child of the one in ctx.
+func (r *TagRepository) DeleteContext(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := database.StartSpan(ctx, "delete_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		var owned bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM tags WHERE id = ? AND user_id = ?)`, id, userID).Scan(&owned); err != nil {
+			return fmt.Errorf("failed to query tag: %w", err)
+		}
+		if !owned {
+			return ErrTagNotFound
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE tags SET parent_id = NULL WHERE parent_id = ? AND user_id = ?`, id, userID); err != nil {
+			return fmt.Errorf("failed to orphan child tags: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM session_tags WHERE tag_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to remove tag associations: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM preset_tags WHERE tag_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to remove tag from presets: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete tag: %w", err)
+		}
+		return nil
+	})
+}
+
+// Stats returns per-tag session counts and total duration of stopped
+// sessions, for the user's tags.
+func (r *TagRepository) Stats(userID int64) ([]TagStat, error) {
+	return r.StatsContext(context.Background(), userID)
+}
+
+// StatsContext is like Stats, recording a span as a child of the one in ctx.
+func (r *TagRepository) StatsContext(ctx context.Context, userID int64) (stats []TagStat, err error) {
+	ctx, span := database.StartSpan(ctx, "tag_stats")
+	defer func() { span.SetError(err); span.End() }()
+
+	rows, err := r.db.Reader().QueryContext(ctx,
+		`SELECT t.id, t.name, t.parent_id, COUNT(s.id), COALESCE(SUM(s.duration_sec), 0)
+			FROM tags t
+			LEFT JOIN session_tags st ON st.tag_id = t.id
+			LEFT JOIN sessions s ON s.id = st.session_id AND s.status = 'stopped'
+			WHERE t.user_id = ?
+			GROUP BY t.id
+			ORDER BY t.name ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag stats: %w", err)
+	}
+	defer rows.Close()
+
+	out := []TagStat{}
+	for rows.Next() {
+		var st TagStat
+		var parentID sql.NullInt64
+		if err := rows.Scan(&st.TagID, &st.TagName, &parentID, &st.SessionCount, &st.TotalSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan tag stat: %w", err)
+		}
+		if parentID.Valid {
+			st.ParentID = &parentID.Int64
+		}
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("tag stats rows error: %w", err)
+	}
+	return out, nil
+}
+
+// AssignToSession assigns tags to one of the user's sessions. Returns
+// ErrSessionMissing if the user has no such session.
+func (r *TagRepository) AssignToSession(userID, sessionID int64, tagIDs []int64) error {
+	return r.AssignToSessionContext(context.Background(), userID, sessionID, tagIDs)
+}
+
+// AssignToSessionContext is like AssignToSession, recording a span as a child
+// of the one in ctx. The tags are assigned in one transaction, so a failure
+// leaves the session's tags as they were.
+func (r *TagRepository) AssignToSessionContext(ctx context.Context, userID, sessionID int64, tagIDs []int64) (err error) {
+	ctx, span := database.StartSpan(ctx, "assign_session_tags")
+	defer func() { span.SetError(err); span.End() }()
+
+	return r.db.InTx(ctx, func(tx *sql.Tx) error {
+		var owned bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = ? AND user_id = ?)`, sessionID, userID,
+		).Scan(&owned); err != nil {
+			return fmt.Errorf("failed to query session: %w", err)
+		}
+		if !owned {
+			return ErrSessionMissing
+		}
+		for _, tagID := range tagIDs {
+			_, err := tx.ExecContext(ctx,
+				`INSERT OR IGNORE INTO session_tags (session_id, tag_id) VALUES (?, ?)`,
+				sessionID, tagID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to assign tag %d to session %d: %w", tagID, sessionID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveFromSession removes a tag from one of the user's sessions.
+// Returns ErrAssociationNotFound if the session does not carry the tag.
+func (r *TagRepository) RemoveFromSession(userID, sessionID, tagID int64) error {
+	return r.RemoveFromSessionContext(context.Background(), userID, sessionID, tagID)
+}
+
+// RemoveFromSessionContext is like RemoveFromSession, recording a span as a child of the one in ctx.
+func (r *TagRepository) RemoveFromSessionContext(ctx context.Context, userID, sessionID, tagID int64) (err error) {
+	ctx, span := database.StartSpan(ctx, "remove_session_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	res, err := r.db.ExecRetry(ctx,
+		`DELETE FROM session_tags WHERE session_id = ? AND tag_id = ?
+		 AND session_id IN (SELECT id FROM sessions WHERE user_id = ?)`,
+		sessionID, tagID, userID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to remove tag %d from session %d: %w", tagID, sessionID, err)
@@ -92,20 +420,31 @@ func (r *TagRepository) RemoveFromSession(sessionID, tagID int64) error {
 		return fmt.Errorf("failed to check remove result: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("session-tag association not found")
+		return ErrAssociationNotFound
 	}
 
 	return nil
 }
 
-func (r *TagRepository) ListForSession(sessionID int64) ([]Tag, error) {
-	rows, err := r.db.Query(
-		`SELECT t.id, t.name, t.color, t.created_at
+// ListForSession returns the tags of one of the user's sessions; none if
+// the user has no such session.
+func (r *TagRepository) ListForSession(userID, sessionID int64) ([]Tag, error) {
+	return r.ListForSessionContext(context.Background(), userID, sessionID)
+}
+
+// ListForSessionContext is like ListForSession, recording a span as a child of the one in ctx.
+func (r *TagRepository) ListForSessionContext(ctx context.Context, userID, sessionID int64) (tags []Tag, err error) {
+	ctx, span := database.StartSpan(ctx, "list_session_tags")
+	defer func() { span.SetError(err); span.End() }()
+
+	rows, err := r.db.Reader().QueryContext(ctx,
+		`SELECT t.id, t.name, t.color, t.created_at, t.parent_id, t.archived
 			FROM tags t
 			INNER JOIN session_tags st ON st.tag_id = t.id
-			WHERE st.session_id = ?
+			INNER JOIN sessions s ON s.id = st.session_id
+			WHERE st.session_id = ? AND s.user_id = ?
 			ORDER BY t.name ASC`,
-		sessionID,
+		sessionID, userID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query session tags: %w", err)
@@ -114,14 +453,78 @@ func (r *TagRepository) ListForSession(sessionID int64) ([]Tag, error) {
 
 	out := []Tag{}
 	for rows.Next() {
-		var t Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt); err != nil {
+		t, err := scanTag(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan session tag: %w", err)
 		}
-		out = append(out, t)
+		out = append(out, *t)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("tags rows error: %w", err)
 	}
 	return out, nil
 }
+
+// BulkAssign tags every one of the user's sessions matching the filter in a single transaction.
+// Sessions that already carry the tag are ignored. If more than max sessions
+// match, nothing is written and ErrBulkAssignTooMany is returned.
+// Returns the number of sessions newly tagged.
+func (r *TagRepository) BulkAssign(userID, tagID int64, filter *BulkAssignFilter, max int) (int64, error) {
+	return r.BulkAssignContext(context.Background(), userID, tagID, filter, max)
+}
+
+// BulkAssignContext is like BulkAssign, recording a span as a child of the one in ctx.
+func (r *TagRepository) BulkAssignContext(ctx context.Context, userID, tagID int64, filter *BulkAssignFilter, max int) (assigned int64, err error) {
+	ctx, span := database.StartSpan(ctx, "bulk_assign_tag")
+	defer func() { span.SetError(err); span.End() }()
+
+	conditions := []string{"user_id = ?"}
+	args := []interface{}{userID}
+
+	if filter.Category != nil {
+		conditions = append(conditions, "category = ?")
+		args = append(args, *filter.Category)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "started_at < ?")
+		args = append(args, *filter.To)
+	}
+	if filter.TaskContains != nil {
+		conditions = append(conditions, "instr(task, ?) > 0")
+		args = append(args, *filter.TaskContains)
+	}
+	where := utils.BuildWhereClause(conditions)
+
+	var tagged int64
+	err = r.db.InTx(ctx, func(tx *sql.Tx) error {
+		var matched int64
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions"+where, args...).Scan(&matched); err != nil {
+			return fmt.Errorf("failed to count matching sessions: %w", err)
+		}
+		if max > 0 && matched > int64(max) {
+			return ErrBulkAssignTooMany
+		}
+
+		insertArgs := append([]interface{}{tagID}, args...)
+		res, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO session_tags (session_id, tag_id) SELECT id, ? FROM sessions"+where,
+			insertArgs...,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to bulk assign tag %d: %w", tagID, err)
+		}
+		tagged, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check bulk assign result: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return tagged, nil
+}
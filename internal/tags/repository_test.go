@@ -1,41 +1,106 @@
 package tags
 
 import (
-	"os"
+	"context"
+	"strings"
 	"testing"
 
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
 	"time-tracker/internal/shared/database"
+	"time-tracker/internal/shared/tracing"
 )
 
 func TestTagRepository_CreateAndList(t *testing.T) {
-	tmp, err := os.CreateTemp("", "tags_repo_*.db")
+	db := database.NewForTesting(t)
+
+	repo := NewTagRepository(db)
+
+	created, err := repo.Create(database.DefaultUserID, &TagCreate{Name: "工作", Color: "#3B82F6"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	_ = tmp.Close()
-	defer os.Remove(tmp.Name())
+	if created.ID == 0 {
+		t.Fatalf("expected id")
+	}
 
-	db, err := database.New(tmp.Name())
+	items, err := repo.List(database.DefaultUserID, TagFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer db.Close()
+	if len(items) != 1 {
+		t.Fatalf("expected 1, got %d", len(items))
+	}
+}
 
+func TestTagRepository_AssignToSessionIsAtomic(t *testing.T) {
+	db := database.NewForTesting(t)
 	repo := NewTagRepository(db)
 
-	created, err := repo.Create(&TagCreate{Name: "工作", Color: "#3B82F6"})
+	session, err := sessions.NewSessionRepository(db).Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "tagged"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if created.ID == 0 {
-		t.Fatalf("expected id")
+	tag, err := repo.Create(database.DefaultUserID, &TagCreate{Name: "client-x"})
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	items, err := repo.List()
+	// The unknown tag fails the foreign key after the first insert, which
+	// must be rolled back with it
+	if err := repo.AssignToSession(database.DefaultUserID, session.ID, []int64{tag.ID, 9999}); err == nil {
+		t.Fatal("expected assigning an unknown tag to fail")
+	}
+	assigned, err := repo.ListForSession(database.DefaultUserID, session.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(items) != 1 {
-		t.Fatalf("expected 1, got %d", len(items))
+	if len(assigned) != 0 {
+		t.Errorf("session kept %d tags from the failed assignment, want none", len(assigned))
+	}
+
+	if err := repo.AssignToSession(database.DefaultUserID, session.ID, []int64{tag.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if assigned, _ = repo.ListForSession(database.DefaultUserID, session.ID); len(assigned) != 1 {
+		t.Errorf("session has %d tags, want 1", len(assigned))
+	}
+}
+
+func TestTagRepository_Spans(t *testing.T) {
+	db := database.NewForTesting(t)
+	repo := NewTagRepository(db)
+	session, err := sessions.NewSessionRepository(db).Create(database.DefaultUserID, &models.SessionStart{Category: "work", Task: "tagged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &tracing.Recorder{}
+	tracing.SetExporter(rec)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	ctx := context.Background()
+	tag, err := repo.CreateContext(ctx, database.DefaultUserID, &TagCreate{Name: "traced"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AssignToSessionContext(ctx, database.DefaultUserID, session.ID, []int64{tag.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ListForSessionContext(ctx, database.DefaultUserID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteContext(ctx, database.DefaultUserID, tag.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create reads the new tag back, so its get_tag span ends first
+	var names []string
+	for _, span := range rec.Spans() {
+		names = append(names, span.Name)
+	}
+	want := "sqlite get_tag,sqlite create_tag,sqlite assign_session_tags,sqlite list_session_tags,sqlite delete_tag"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("spans = %s, want %s", got, want)
 	}
 }
@@ -1,41 +1,418 @@
 package tags
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/config"
+)
+
+var ErrTagNotFound = errors.New("tag not found")
 
 type TagService struct {
-	repo *TagRepository
+	repo          TagRepositoryInterface
+	bulkAssignMax int
 }
 
-func NewTagService(repo *TagRepository) *TagService {
-	return &TagService{repo: repo}
+func NewTagService(repo TagRepositoryInterface) *TagService {
+	return &TagService{repo: repo, bulkAssignMax: config.MaxBulkAssign}
+}
+
+// SetBulkAssignMax overrides the maximum number of sessions a single
+// bulk-assign call may touch.
+func (s *TagService) SetBulkAssignMax(max int) {
+	if max > 0 {
+		s.bulkAssignMax = max
+	}
 }
 
 func (s *TagService) Create(input *TagCreate) (*Tag, error) {
+	return s.CreateContext(auth.DefaultUserContext(), input)
+}
+
+// CreateContext is like Create but takes a context for cancellation.
+func (s *TagService) CreateContext(ctx context.Context, input *TagCreate) (*Tag, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if input.ParentID != nil {
+		parent, err := s.repo.GetByIDContext(ctx, userID, *input.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, fmt.Errorf("validation error: %w", ErrParentNotFound)
+		}
+	}
+	return s.repo.CreateContext(ctx, userID, input)
+}
+
+// Seed creates the given tags, skipping names that already exist.
+// Returns the number of tags created.
+func (s *TagService) Seed(seeds []TagCreate) (int, error) {
+	return s.SeedContext(auth.DefaultUserContext(), seeds)
+}
+
+// SeedContext is like Seed but takes a context for cancellation.
+func (s *TagService) SeedContext(ctx context.Context, seeds []TagCreate) (int, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	for i := range seeds {
+		inserted, err := s.repo.CreateIfMissingContext(ctx, userID, &seeds[i])
+		if err != nil {
+			return created, err
+		}
+		if inserted {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// EnsureByName returns the tag with the given name, creating it with the
+// default color if it does not exist yet.
+func (s *TagService) EnsureByName(name string) (*Tag, error) {
+	return s.EnsureByNameContext(auth.DefaultUserContext(), name)
+}
+
+// EnsureByNameContext is like EnsureByName but takes a context for cancellation.
+func (s *TagService) EnsureByNameContext(ctx context.Context, name string) (*Tag, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	input := &TagCreate{Name: name}
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if _, err := s.repo.CreateIfMissingContext(ctx, userID, input); err != nil {
+		return nil, err
+	}
+	tag, err := s.repo.GetByNameContext(ctx, userID, input.Name)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+func (s *TagService) List(filter TagFilter) ([]Tag, error) {
+	return s.ListContext(auth.DefaultUserContext(), filter)
+}
+
+// ListContext is like List but takes a context for cancellation.
+func (s *TagService) ListContext(ctx context.Context, filter TagFilter) ([]Tag, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListContext(ctx, userID, filter)
+}
+
+// ListPage returns a page of tags wrapped with pagination metadata.
+func (s *TagService) ListPage(filter TagFilter, limit, offset int) (*models.PaginatedResponse[Tag], error) {
+	return s.ListPageContext(auth.DefaultUserContext(), filter, limit, offset)
+}
+
+// ListPageContext is like ListPage but takes a context for cancellation.
+func (s *TagService) ListPageContext(ctx context.Context, filter TagFilter, limit, offset int) (*models.PaginatedResponse[Tag], error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = config.DefaultPageSize
+	}
+	if limit > config.MaxTagPageSize {
+		limit = config.MaxTagPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	items, err := s.repo.ListPageContext(ctx, userID, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.repo.CountContext(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(items, total, limit, offset), nil
+}
+
+// Update applies a partial update to a tag, rejecting parent changes that
+// would make the tag its own ancestor.
+// Returns ErrTagNotFound if the tag does not exist.
+func (s *TagService) Update(id int64, input *TagUpdate) (*Tag, error) {
+	return s.UpdateContext(auth.DefaultUserContext(), id, input)
+}
+
+// UpdateContext is like Update but takes a context for cancellation.
+func (s *TagService) UpdateContext(ctx context.Context, id int64, input *TagUpdate) (*Tag, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if err := input.Validate(); err != nil {
 		return nil, fmt.Errorf("validation error: %w", err)
 	}
-	return s.repo.Create(input)
+
+	tag, err := s.repo.GetByIDContext(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+
+	if input.ParentID != nil && *input.ParentID != 0 {
+		if err := s.checkParent(ctx, id, *input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.UpdateContext(ctx, userID, id, input); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByIDContext(ctx, userID, id)
+}
+
+// checkParent walks up from parentID and fails if it reaches id.
+func (s *TagService) checkParent(ctx context.Context, id, parentID int64) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	seen := map[int64]bool{}
+	for current := parentID; ; {
+		if current == id {
+			return fmt.Errorf("validation error: %w", ErrTagCycle)
+		}
+		if seen[current] {
+			return nil
+		}
+		seen[current] = true
+
+		ancestor, err := s.repo.GetByIDContext(ctx, userID, current)
+		if err != nil {
+			return err
+		}
+		if ancestor == nil {
+			if current == parentID {
+				return fmt.Errorf("validation error: %w", ErrParentNotFound)
+			}
+			return nil
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+		current = *ancestor.ParentID
+	}
+}
+
+// Delete removes a tag. A tag with children is only deleted when
+// orphanChildren is true, in which case the children become top-level tags.
+// Returns ErrTagNotFound or ErrTagHasChildren.
+func (s *TagService) Delete(id int64, orphanChildren bool) error {
+	return s.DeleteContext(auth.DefaultUserContext(), id, orphanChildren)
+}
+
+// DeleteContext is like Delete but takes a context for cancellation.
+func (s *TagService) DeleteContext(ctx context.Context, id int64, orphanChildren bool) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	tag, err := s.repo.GetByIDContext(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if tag == nil {
+		return ErrTagNotFound
+	}
+
+	if !orphanChildren {
+		children, err := s.repo.CountChildrenContext(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrTagHasChildren
+		}
+	}
+
+	return s.repo.DeleteContext(ctx, userID, id)
 }
 
-func (s *TagService) List() ([]Tag, error) {
-	return s.repo.List()
+// Stats returns tracked time per tag. With rollup, each tag's totals also
+// include those of all its descendants.
+func (s *TagService) Stats(rollup bool) ([]TagStat, error) {
+	return s.StatsContext(auth.DefaultUserContext(), rollup)
+}
+
+// StatsContext is like Stats but takes a context for cancellation.
+func (s *TagService) StatsContext(ctx context.Context, rollup bool) ([]TagStat, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.repo.StatsContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !rollup {
+		return stats, nil
+	}
+
+	index := make(map[int64]int, len(stats))
+	for i, st := range stats {
+		index[st.TagID] = i
+	}
+
+	rolled := make([]TagStat, len(stats))
+	copy(rolled, stats)
+	for _, st := range stats {
+		seen := map[int64]bool{st.TagID: true}
+		for parent := st.ParentID; parent != nil && !seen[*parent]; {
+			i, ok := index[*parent]
+			if !ok {
+				break
+			}
+			seen[*parent] = true
+			rolled[i].SessionCount += st.SessionCount
+			rolled[i].TotalSeconds += st.TotalSeconds
+			parent = stats[i].ParentID
+		}
+	}
+	return rolled, nil
 }
 
+// Get returns a tag. Returns ErrTagNotFound if it does not exist.
 func (s *TagService) Get(id int64) (*Tag, error) {
-	return s.repo.GetByID(id)
+	return s.GetContext(auth.DefaultUserContext(), id)
+}
+
+// GetContext is like Get but takes a context for cancellation.
+func (s *TagService) GetContext(ctx context.Context, id int64) (*Tag, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := s.repo.GetByIDContext(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
 }
 
-// AssignToSession assigns tags to a session
+// AssignToSession assigns tags to a session.
+// Archived tags cannot be assigned; existing associations are unaffected.
+// Returns ErrSessionMissing if the session does not exist.
 func (s *TagService) AssignToSession(sessionID int64, tagIDs []int64) error {
-	return s.repo.AssignToSession(sessionID, tagIDs)
+	return s.AssignToSessionContext(auth.DefaultUserContext(), sessionID, tagIDs)
+}
+
+// AssignToSessionContext is like AssignToSession but takes a context for cancellation.
+func (s *TagService) AssignToSessionContext(ctx context.Context, sessionID int64, tagIDs []int64) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tagID := range tagIDs {
+		tag, err := s.repo.GetByIDContext(ctx, userID, tagID)
+		if err != nil {
+			return err
+		}
+		if tag == nil {
+			return fmt.Errorf("validation error: tag %d: %w", tagID, ErrTagNotFound)
+		}
+		if tag.Archived {
+			return fmt.Errorf("validation error: tag %d: %w", tagID, ErrTagArchived)
+		}
+	}
+	return s.repo.AssignToSessionContext(ctx, userID, sessionID, tagIDs)
 }
 
-// RemoveFromSession removes a tag from a session
+// RemoveFromSession removes a tag from a session.
+// Returns ErrAssociationNotFound if the session does not carry the tag.
 func (s *TagService) RemoveFromSession(sessionID, tagID int64) error {
-	return s.repo.RemoveFromSession(sessionID, tagID)
+	return s.RemoveFromSessionContext(auth.DefaultUserContext(), sessionID, tagID)
+}
+
+// RemoveFromSessionContext is like RemoveFromSession but takes a context for cancellation.
+func (s *TagService) RemoveFromSessionContext(ctx context.Context, sessionID, tagID int64) error {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.RemoveFromSessionContext(ctx, userID, sessionID, tagID)
 }
 
 // ListForSession returns all tags for a session
 func (s *TagService) ListForSession(sessionID int64) ([]Tag, error) {
-	return s.repo.ListForSession(sessionID)
+	return s.ListForSessionContext(auth.DefaultUserContext(), sessionID)
+}
+
+// ListForSessionContext is like ListForSession but takes a context for cancellation.
+func (s *TagService) ListForSessionContext(ctx context.Context, sessionID int64) ([]Tag, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListForSessionContext(ctx, userID, sessionID)
+}
+
+// BulkAssign assigns a tag to every session matching the filter.
+// Returns ErrTagNotFound if the tag does not exist.
+func (s *TagService) BulkAssign(tagID int64, filter *BulkAssignFilter) (*BulkAssignResult, error) {
+	return s.BulkAssignContext(auth.DefaultUserContext(), tagID, filter)
+}
+
+// BulkAssignContext is like BulkAssign but takes a context for cancellation.
+func (s *TagService) BulkAssignContext(ctx context.Context, tagID int64, filter *BulkAssignFilter) (*BulkAssignResult, error) {
+	userID, err := auth.RequireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	tag, err := s.repo.GetByIDContext(ctx, userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+	if tag.Archived {
+		return nil, fmt.Errorf("validation error: %w", ErrTagArchived)
+	}
+
+	tagged, err := s.repo.BulkAssignContext(ctx, userID, tagID, filter, s.bulkAssignMax)
+	if errors.Is(err, ErrBulkAssignTooMany) {
+		return nil, fmt.Errorf("validation error: %w (maximum %d)", err, s.bulkAssignMax)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &BulkAssignResult{Tagged: tagged}, nil
 }
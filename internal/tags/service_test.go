@@ -1,31 +1,22 @@
 package tags
 
 import (
-	"os"
+	"context"
+	"errors"
 	"strings"
 	"testing"
 
+	"time-tracker/internal/shared/auth"
 	"time-tracker/internal/shared/database"
 )
 
 func TestTagService_DuplicateName(t *testing.T) {
-	tmp, err := os.CreateTemp("", "tags_svc_*.db")
-	if err != nil {
-		t.Fatal(err)
-	}
-	_ = tmp.Close()
-	defer os.Remove(tmp.Name())
-
-	db, err := database.New(tmp.Name())
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := database.NewForTesting(t)
 
 	repo := NewTagRepository(db)
 	svc := NewTagService(repo)
 
-	_, err = svc.Create(&TagCreate{Name: "work", Color: "#3B82F6"})
+	_, err := svc.Create(&TagCreate{Name: "work", Color: "#3B82F6"})
 	if err != nil {
 		t.Fatalf("expected first create ok, got %v", err)
 	}
@@ -39,3 +30,297 @@ func TestTagService_DuplicateName(t *testing.T) {
 		t.Fatalf("unexpected duplicate error: %v", err)
 	}
 }
+
+func TestTagService_UserIsolation(t *testing.T) {
+	db := database.NewForTesting(t)
+	if _, err := db.Exec(`INSERT INTO users (id, name, created_at) VALUES (2, 'bob', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	res, err := db.Exec(`INSERT INTO sessions (user_id, category, task, started_at, status) VALUES (2, 'work', 'bob', '2024-03-01T10:00:00Z', 'stopped')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSession, _ := res.LastInsertId()
+
+	svc := NewTagService(NewTagRepository(db))
+	alice := auth.WithUserID(context.Background(), database.DefaultUserID)
+	bob := auth.WithUserID(context.Background(), 2)
+
+	// Tag names are unique per user only
+	own, err := svc.CreateContext(alice, &TagCreate{Name: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateContext(bob, &TagCreate{Name: "work"}); err != nil {
+		t.Fatalf("second user could not reuse the tag name: %v", err)
+	}
+
+	if tag, err := svc.GetContext(bob, own.ID); err != ErrTagNotFound {
+		t.Errorf("another user's tag was returned: %+v, %v", tag, err)
+	}
+	if err := svc.DeleteContext(bob, own.ID, false); err != ErrTagNotFound {
+		t.Errorf("deleting another user's tag: got %v, want ErrTagNotFound", err)
+	}
+	if err := svc.AssignToSessionContext(bob, bobSession, []int64{own.ID}); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("assigning another user's tag: got %v, want ErrTagNotFound", err)
+	}
+	if err := svc.AssignToSessionContext(alice, bobSession, []int64{own.ID}); err != ErrSessionMissing {
+		t.Errorf("tagging another user's session: got %v, want ErrSessionMissing", err)
+	}
+
+	list, err := svc.ListContext(bob, TagFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID == own.ID {
+		t.Errorf("second user listed %+v", list)
+	}
+}
+
+func TestTagService_BulkAssign(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	for _, row := range []struct{ category, task string }{
+		{"client-x", "design review"},
+		{"client-x", "invoice"},
+		{"client-y", "design review"},
+	} {
+		if _, err := db.Exec(
+			`INSERT INTO sessions (category, task, started_at, status) VALUES (?, ?, '2024-03-01T10:00:00Z', 'stopped')`,
+			row.category, row.task,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := NewTagService(NewTagRepository(db))
+	tag, err := svc.Create(&TagCreate{Name: "client-x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	category := "client-x"
+	result, err := svc.BulkAssign(tag.ID, &BulkAssignFilter{Category: &category})
+	if err != nil {
+		t.Fatalf("bulk assign failed: %v", err)
+	}
+	if result.Tagged != 2 {
+		t.Fatalf("expected 2 sessions tagged, got %d", result.Tagged)
+	}
+
+	// Already-tagged sessions are not counted again
+	task := "design"
+	result, err = svc.BulkAssign(tag.ID, &BulkAssignFilter{TaskContains: &task})
+	if err != nil {
+		t.Fatalf("bulk assign failed: %v", err)
+	}
+	if result.Tagged != 1 {
+		t.Fatalf("expected 1 newly tagged session, got %d", result.Tagged)
+	}
+
+	// An empty filter requires confirmation
+	if _, err := svc.BulkAssign(tag.ID, &BulkAssignFilter{}); err == nil || !strings.Contains(err.Error(), "validation error") {
+		t.Fatalf("expected validation error for empty filter, got %v", err)
+	}
+
+	// The configured cap rejects oversized matches
+	svc.SetBulkAssignMax(1)
+	if _, err := svc.BulkAssign(tag.ID, &BulkAssignFilter{Confirm: true}); err == nil || !strings.Contains(err.Error(), "validation error") {
+		t.Fatalf("expected validation error above cap, got %v", err)
+	}
+
+	if _, err := svc.BulkAssign(tag.ID+100, &BulkAssignFilter{Confirm: true}); err != ErrTagNotFound {
+		t.Fatalf("expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestTagService_Hierarchy(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	svc := NewTagService(NewTagRepository(db))
+
+	clients, err := svc.Create(&TagCreate{Name: "clients"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := svc.Create(&TagCreate{Name: "acme", ParentID: &clients.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acme.ParentID == nil || *acme.ParentID != clients.ID {
+		t.Fatalf("expected parent_id %d, got %v", clients.ID, acme.ParentID)
+	}
+
+	// clients -> acme -> clients would be a cycle
+	if _, err := svc.Update(clients.ID, &TagUpdate{ParentID: &acme.ID}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+
+	children, err := svc.List(TagFilter{ParentID: &clients.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 1 || children[0].ID != acme.ID {
+		t.Fatalf("expected only acme under clients, got %+v", children)
+	}
+
+	if _, err := db.Exec(
+		`INSERT INTO sessions (category, task, started_at, duration_sec, status) VALUES ('work', 'call', '2024-03-01T10:00:00Z', 600, 'stopped')`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AssignToSession(1, []int64{acme.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := svc.Stats(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range stats {
+		if st.TotalSeconds != 600 {
+			t.Fatalf("expected rolled-up total 600 for %s, got %d", st.TagName, st.TotalSeconds)
+		}
+	}
+
+	if err := svc.Delete(clients.ID, false); err != ErrTagHasChildren {
+		t.Fatalf("expected ErrTagHasChildren, got %v", err)
+	}
+	if err := svc.Delete(clients.ID, true); err != nil {
+		t.Fatalf("expected orphaning delete to succeed, got %v", err)
+	}
+	orphan, err := svc.Get(acme.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orphan.ParentID != nil {
+		t.Fatalf("expected acme to become top-level, got parent %d", *orphan.ParentID)
+	}
+}
+
+func TestTagService_Seed(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	svc := NewTagService(NewTagRepository(db))
+	seeds, err := ParseSeedTags("work:#3B82F6,study:#10B981")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fresh database: everything is created
+	created, err := svc.Seed(seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 2 {
+		t.Fatalf("expected 2 tags created, got %d", created)
+	}
+
+	// Partially seeded: only the missing name is added, existing color kept
+	seeds, err = ParseSeedTags("work:#000000,health:#EF4444")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err = svc.Seed(seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 {
+		t.Fatalf("expected 1 tag created, got %d", created)
+	}
+
+	items, err := svc.List(TagFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 tags, got %d", len(items))
+	}
+	for _, tag := range items {
+		if tag.Name == "work" && tag.Color != "#3B82F6" {
+			t.Fatalf("expected existing tag color to be kept, got %s", tag.Color)
+		}
+	}
+}
+
+func TestTagService_Archive(t *testing.T) {
+	db := database.NewForTesting(t)
+
+	res, err := db.Exec(`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES ('work', 'old project', '2024-03-01T10:00:00Z', '2024-03-01T11:00:00Z', 3600, 'stopped')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, _ := res.LastInsertId()
+
+	svc := NewTagService(NewTagRepository(db))
+	tag, err := svc.Create(&TagCreate{Name: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AssignToSession(sessionID, []int64{tag.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	archived := true
+	updated, err := svc.Update(tag.ID, &TagUpdate{Archived: &archived})
+	if err != nil {
+		t.Fatalf("archive failed: %v", err)
+	}
+	if !updated.Archived {
+		t.Fatalf("expected tag to be archived")
+	}
+
+	// Hidden from the default list, visible when requested
+	list, err := svc.List(TagFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected archived tag to be hidden, got %d tags", len(list))
+	}
+	list, err = svc.List(TagFilter{IncludeArchived: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Archived {
+		t.Fatalf("expected archived tag with include_archived, got %+v", list)
+	}
+
+	// Existing associations and stats are untouched
+	sessionTags, err := svc.ListForSession(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessionTags) != 1 || sessionTags[0].ID != tag.ID {
+		t.Fatalf("expected existing association to remain, got %+v", sessionTags)
+	}
+	stats, err := svc.Stats(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].TotalSeconds != 3600 {
+		t.Fatalf("expected stats to include archived tag, got %+v", stats)
+	}
+
+	// New assignments are rejected
+	if err := svc.AssignToSession(sessionID, []int64{tag.ID}); err == nil || !strings.Contains(err.Error(), "validation error") {
+		t.Fatalf("expected validation error assigning archived tag, got %v", err)
+	}
+	category := "work"
+	if _, err := svc.BulkAssign(tag.ID, &BulkAssignFilter{Category: &category}); err == nil || !strings.Contains(err.Error(), "validation error") {
+		t.Fatalf("expected validation error bulk assigning archived tag, got %v", err)
+	}
+
+	// Unarchiving restores normal behaviour
+	archived = false
+	if _, err := svc.Update(tag.ID, &TagUpdate{Archived: &archived}); err != nil {
+		t.Fatal(err)
+	}
+	list, err = svc.List(TagFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected unarchived tag in default list, got %d", len(list))
+	}
+}
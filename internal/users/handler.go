@@ -0,0 +1,79 @@
+package users
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/validation"
+)
+
+type UsersHandler struct {
+	service *UserService
+	audit   *audit.Logger
+}
+
+func NewUsersHandler(svc *UserService) *UsersHandler {
+	return &UsersHandler{service: svc}
+}
+
+// SetAudit records user creation to the audit log.
+func (h *UsersHandler) SetAudit(logger *audit.Logger) {
+	h.audit = logger
+}
+
+func (h *UsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api/v1/admin/users":
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			errors.WriteError(w, errors.NewMethodNotAllowedError(http.MethodGet, http.MethodPost))
+		}
+	default:
+		errors.WriteError(w, errors.NotFoundError("Endpoint not found"))
+	}
+}
+
+// Create handles POST /api/v1/admin/users - creates a user.
+func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input UserCreate
+	if err := validation.DecodeJSON(w, r, &input, config.MaxJSONBodyBytes); err != nil {
+		errors.WriteError(w, errors.ValidationError(err.Error()))
+		return
+	}
+	created, err := h.service.CreateContext(r.Context(), &input)
+	if err != nil {
+		switch {
+		case err == ErrUserExists:
+			errors.WriteError(w, errors.NewConflictError("A user with this name already exists", nil))
+		case strings.Contains(err.Error(), "validation error"):
+			errors.WriteError(w, errors.ValidationErrorFor(err))
+		default:
+			errors.WriteError(w, err)
+		}
+		return
+	}
+	h.audit.Record(r, audit.EventUserCreated, map[string]interface{}{"id": created.ID, "name": created.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+// List handles GET /api/v1/admin/users - lists users without their passwords.
+func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.ListContext(r.Context())
+	if err != nil {
+		errors.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(users)
+}
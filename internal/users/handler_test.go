@@ -0,0 +1,46 @@
+package users
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUsersHandler(t *testing.T) {
+	h := NewUsersHandler(setupTestService(t))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	createW := do(http.MethodPost, "/api/v1/admin/users", `{"name":"partner","password":"hunter22"}`)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", createW.Code, createW.Body.String())
+	}
+	if strings.Contains(createW.Body.String(), "hunter22") || strings.Contains(createW.Body.String(), "password") {
+		t.Fatalf("create response exposes the password: %s", createW.Body.String())
+	}
+
+	listW := do(http.MethodGet, "/api/v1/admin/users", "")
+	if listW.Code != http.StatusOK || !strings.Contains(listW.Body.String(), `"name":"partner"`) || strings.Contains(listW.Body.String(), "$2a$") {
+		t.Fatalf("list: %d %s", listW.Code, listW.Body.String())
+	}
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, `{"name":"partner","password":"hunter22"}`, http.StatusConflict},
+		{http.MethodPost, `{"name":"other","password":"short"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"name":"other"`, http.StatusBadRequest},
+		{http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.method, "/api/v1/admin/users", tt.body); rr.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.body, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
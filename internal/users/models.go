@@ -0,0 +1,48 @@
+// Package users manages the people sharing one server. Every session, tag,
+// preset and reminder rule belongs to a user, and requests only see the
+// data of the user they authenticated as.
+package users
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"time-tracker/internal/shared/config"
+	"time-tracker/internal/shared/validation"
+)
+
+// User is an account. The password hash is never returned.
+type User struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// UserCreate is the input for creating a user, who signs in with name and
+// password through Basic Auth or the web login.
+type UserCreate struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+var (
+	ErrNameRequired     = errors.New("name is required")
+	ErrNameTooLong      = errors.New("name must be at most 50 characters")
+	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
+	ErrUserExists       = errors.New("a user with this name already exists")
+)
+
+// Validate sanitizes the name. Passwords are kept as given.
+func (u *UserCreate) Validate() error {
+	u.Name = validation.SanitizeString(u.Name)
+	if u.Name == "" {
+		return ErrNameRequired
+	}
+	if !validation.ValidateStringLength(u.Name, 1, config.MaxUserNameLength) {
+		return ErrNameTooLong
+	}
+	if utf8.RuneCountInString(u.Password) < config.MinUserPasswordLength {
+		return ErrPasswordTooShort
+	}
+	return nil
+}
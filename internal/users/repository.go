@@ -0,0 +1,87 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"time-tracker/internal/shared/database"
+)
+
+type UserRepository struct {
+	db *database.DB
+}
+
+func NewUserRepository(db *database.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// CreateContext inserts a user. Returns ErrUserExists if the name is taken.
+func (r *UserRepository) CreateContext(ctx context.Context, name, passwordHash string) (*User, error) {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (name, password_hash, created_at) VALUES (?, ?, strftime('%Y-%m-%dT%H:%M:%SZ','now'))`,
+		name, passwordHash,
+	)
+	if database.IsUniqueViolation(err) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert user: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	var u User
+	if err := r.db.QueryRowContext(ctx, `SELECT id, name, created_at FROM users WHERE id = ?`, id).Scan(&u.ID, &u.Name, &u.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return &u, nil
+}
+
+// ListContext returns every user, oldest first.
+func (r *UserRepository) ListContext(ctx context.Context) ([]User, error) {
+	rows, err := r.db.Reader().QueryContext(ctx, `SELECT id, name, created_at FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate users: %w", err)
+	}
+	return users, nil
+}
+
+// HasPasswords reports whether any user has a password, and so can sign in.
+func (r *UserRepository) HasPasswords() (bool, error) {
+	var exists bool
+	err := r.db.Reader().QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE password_hash IS NOT NULL AND password_hash != '')`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to query users: %w", err)
+	}
+	return exists, nil
+}
+
+// PasswordHash returns the ID and password hash of the named user. ok is
+// false if there is no such user or they have no password, like the
+// default user, who signs in with the configured credentials.
+func (r *UserRepository) PasswordHash(name string) (id int64, hash string, ok bool, err error) {
+	var h sql.NullString
+	err = r.db.Reader().QueryRow(`SELECT id, password_hash FROM users WHERE name = ?`, name).Scan(&id, &h)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("failed to query user: %w", err)
+	}
+	return id, h.String, h.Valid && h.String != "", nil
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserService manages users and verifies their passwords for the auth
+// middlewares.
+type UserService struct {
+	repo   *UserRepository
+	cost   int
+	logger *slog.Logger
+
+	// hasUsers is set once a user with a password exists; users are never
+	// deleted, so it is never cleared
+	hasUsers atomic.Bool
+}
+
+// NewUserService creates a UserService. A nil logger logs to
+// slog.Default().
+func NewUserService(repo *UserRepository, logger *slog.Logger) *UserService {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &UserService{repo: repo, cost: bcrypt.DefaultCost, logger: logger}
+}
+
+// Create adds a user with a bcrypt hash of their password.
+func (s *UserService) Create(input *UserCreate) (*User, error) {
+	return s.CreateContext(context.Background(), input)
+}
+
+// CreateContext is like Create but takes a context for cancellation.
+func (s *UserService) CreateContext(ctx context.Context, input *UserCreate) (*User, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), s.cost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	return s.repo.CreateContext(ctx, input.Name, string(hash))
+}
+
+// List returns every user, oldest first.
+func (s *UserService) List() ([]User, error) {
+	return s.ListContext(context.Background())
+}
+
+// ListContext is like List but takes a context for cancellation.
+func (s *UserService) ListContext(ctx context.Context) ([]User, error) {
+	return s.repo.ListContext(ctx)
+}
+
+// VerifyUser implements auth.UserLookup. A failed lookup is logged and
+// treated as a wrong password.
+func (s *UserService) VerifyUser(name, pass string) (int64, bool) {
+	id, hash, ok, err := s.repo.PasswordHash(name)
+	if err != nil {
+		s.logger.Error("failed to look up user", "error", err)
+		return 0, false
+	}
+	if !ok || bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// HasUsers implements auth.UserLookup. A failed lookup is logged and treated
+// as there being users, so an error never leaves the server open.
+func (s *UserService) HasUsers() bool {
+	if s.hasUsers.Load() {
+		return true
+	}
+	exists, err := s.repo.HasPasswords()
+	if err != nil {
+		s.logger.Error("failed to look up users", "error", err)
+		return true
+	}
+	if exists {
+		s.hasUsers.Store(true)
+	}
+	return exists
+}
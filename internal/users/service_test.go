@@ -0,0 +1,75 @@
+package users
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"time-tracker/internal/shared/database"
+)
+
+func setupTestService(t *testing.T) *UserService {
+	t.Helper()
+	svc := NewUserService(NewUserRepository(database.NewForTesting(t)), nil)
+	svc.cost = bcrypt.MinCost
+	return svc
+}
+
+func TestUserService_CreateAndVerify(t *testing.T) {
+	svc := setupTestService(t)
+	// The default user has no password, so it does not count
+	if svc.HasUsers() {
+		t.Fatal("HasUsers = true before any user was created")
+	}
+
+	created, err := svc.Create(&UserCreate{Name: " partner ", Password: "hunter22"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Name != "partner" || created.ID == database.DefaultUserID || created.CreatedAt == "" {
+		t.Fatalf("unexpected user: %+v", created)
+	}
+
+	if id, ok := svc.VerifyUser("partner", "hunter22"); !ok || id != created.ID {
+		t.Errorf("VerifyUser with the right password = %d, %v", id, ok)
+	}
+	if _, ok := svc.VerifyUser("partner", "hunter23"); ok {
+		t.Error("expected a wrong password to be rejected")
+	}
+	if _, ok := svc.VerifyUser("nobody", "hunter22"); ok {
+		t.Error("expected an unknown user to be rejected")
+	}
+	// The default user signs in with the configured credentials only
+	if _, ok := svc.VerifyUser("default", ""); ok {
+		t.Error("expected the default user to have no password")
+	}
+
+	if !svc.HasUsers() {
+		t.Error("HasUsers = false after creating a user")
+	}
+
+	if _, err := svc.Create(&UserCreate{Name: "partner", Password: "different"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("duplicate name: err = %v, want ErrUserExists", err)
+	}
+
+	list, err := svc.List()
+	if err != nil || len(list) != 2 || list[0].ID != database.DefaultUserID || list[1].ID != created.ID {
+		t.Errorf("List = %+v, %v; want the default user and the new one", list, err)
+	}
+}
+
+func TestUserService_Validation(t *testing.T) {
+	svc := setupTestService(t)
+	for name, input := range map[string]UserCreate{
+		"no name":        {Password: "hunter22"},
+		"blank name":     {Name: "   ", Password: "hunter22"},
+		"name too long":  {Name: strings.Repeat("x", 51), Password: "hunter22"},
+		"short password": {Name: "partner", Password: "hunter2"},
+	} {
+		if _, err := svc.Create(&input); err == nil || !strings.Contains(err.Error(), "validation error") {
+			t.Errorf("%s: err = %v, want a validation error", name, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/shared/validation"
+)
+
+// flashCookieName holds a message for the next page after a form action.
+const flashCookieName = "timelog_flash"
+
+// ActionResponse is the JSON body of every /web/*/actions/* response. Code
+// is one of the error codes of the API, set when OK is false.
+type ActionResponse struct {
+	OK      bool   `json:"ok"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Flash is a message shown once on the page a form action redirects to.
+type Flash struct {
+	Kind    string `json:"kind"` // "success" or "error"
+	Message string `json:"message"`
+}
+
+// isFormPost reports whether r is a plain HTML form submission, made when
+// the page is used without JavaScript. Such requests get a redirect with a
+// flash message instead of a JSON body.
+func isFormPost(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+// decodeAction reads an action's input into v from its JSON body, or from
+// a form post with fromForm. Actions without a form fallback pass nil.
+func (h *WebHandler) decodeAction(w http.ResponseWriter, r *http.Request, v interface{}, fromForm func(url.Values) error) error {
+	if !isFormPost(r) {
+		if err := validation.DecodeJSON(w, r, v, h.maxBodyBytes); err != nil {
+			return errors.ValidationError(err.Error())
+		}
+		return nil
+	}
+	if fromForm == nil {
+		return errors.ValidationError("request body must be JSON")
+	}
+	// A page on another site can submit a form, and the browser would add
+	// cached Basic Auth credentials; JSON bodies need a same-origin fetch
+	if !sameOrigin(r) {
+		return errors.ValidationError("cross-site form submissions are not allowed")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		return errors.ValidationError("invalid form body")
+	}
+	return fromForm(r.PostForm)
+}
+
+// sameOrigin reports whether a form post came from a page of this site,
+// going by Sec-Fetch-Site or, from older browsers, Origin. Requests with
+// neither are not from a browser form on another site.
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+	return true
+}
+
+// writeActionOK reports a successful action: as JSON, or for a form post
+// as a flash message on the page at back.
+func (h *WebHandler) writeActionOK(w http.ResponseWriter, r *http.Request, back, message string) {
+	if isFormPost(r) {
+		setFlash(w, Flash{Kind: "success", Message: message})
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	writeActionJSON(w, http.StatusOK, ActionResponse{OK: true, Message: message})
+}
+
+// writeActionError reports a failed action like writeActionOK, with the
+// status and code errors.WriteError would use for err.
+func (h *WebHandler) writeActionError(w http.ResponseWriter, r *http.Request, back string, err error) {
+	status, detail := errors.Detail(err)
+	if status == http.StatusInternalServerError {
+		h.logger.Error("web action failed", "path", r.URL.Path, "error", err)
+	}
+	if isFormPost(r) {
+		setFlash(w, Flash{Kind: "error", Message: detail.Message})
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	errors.SetHeaders(w, err)
+	writeActionJSON(w, status, ActionResponse{Code: detail.Code, Message: detail.Message})
+}
+
+func writeActionJSON(w http.ResponseWriter, status int, resp ActionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// methodNotAllowed is the error for an action called with the wrong
+// method; every action takes POST only.
+func methodNotAllowed() error {
+	return errors.NewMethodNotAllowedError(http.MethodPost)
+}
+
+// sessionActionError maps an error from the session service to the API's
+// error vocabulary.
+func sessionActionError(err error) error {
+	switch {
+	case err == sessions.ErrSessionAlreadyRunning:
+		return errors.NewConflictError("A session is already running", nil)
+	case err == sessions.ErrStopNotAllowed:
+		return errors.NewConflictError("Setting ended_at stops the running session; send allow_stop to confirm", nil)
+	case err == sessions.ErrInconsistentUpdate:
+		return errors.NewConflictError("ended_at and duration_sec must be set or cleared together", nil)
+	case err == sessions.ErrNoRunningSession:
+		return errors.NotFoundError("No running session found")
+	case stderrors.Is(err, sessions.ErrSessionNotFound):
+		return errors.NotFoundError("Session not found")
+	case strings.HasPrefix(err.Error(), "validation error: "):
+		return errors.ValidationErrorFor(err)
+	default:
+		return err
+	}
+}
+
+// setFlash stores flash for the next page the browser loads.
+func setFlash(w http.ResponseWriter, flash Flash) {
+	value, err := json.Marshal(flash)
+	if err != nil {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/web/",
+		MaxAge:   60,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// takeFlash returns the pending flash message, if any, and clears it so it
+// is shown once. It must be called before the response is written.
+func takeFlash(w http.ResponseWriter, r *http.Request) *Flash {
+	cookie, err := r.Cookie(flashCookieName)
+	if err != nil {
+		return nil
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookieName,
+		Value:    "",
+		Path:     "/web/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	var flash Flash
+	if err := json.Unmarshal(value, &flash); err != nil || flash.Message == "" {
+		return nil
+	}
+	if flash.Kind != "success" {
+		flash.Kind = "error"
+	}
+	return &flash
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postAction posts a JSON action and decodes the response.
+func postAction(t *testing.T, handler http.Handler, path, body string) (int, ActionResponse) {
+	t.Helper()
+	req := newRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("%s: Content-Type = %q, body %q", path, ct, rr.Body.String())
+	}
+	var resp ActionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("%s: invalid JSON body: %v", path, err)
+	}
+	return rr.Code, resp
+}
+
+func TestWebActions_StrictJSON(t *testing.T) {
+	handler, cleanup := setupWebTestEnv(t)
+	defer cleanup()
+	handler.SetMaxBodyBytes(128)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"start with unknown field", "/web/sessions/actions/start", `{"catagory":"work","task":"x"}`, `unknown field "catagory"`},
+		{"start oversized", "/web/sessions/actions/start", `{"task":"` + strings.Repeat("x", 200) + `"}`, "request body must not exceed 128 bytes"},
+		{"delete with trailing garbage", "/web/sessions/actions/delete", `{"id":1} {"id":2}`, "request body must contain a single JSON value"},
+		{"archive with wrong type", "/web/tags/actions/archive", `{"id":1,"archived":"yes"}`, `field "archived" must be a boolean`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := postAction(t, handler, tt.path, tt.body)
+			if status != http.StatusBadRequest || resp.OK || resp.Code != "VALIDATION_ERROR" || resp.Message != tt.want {
+				t.Errorf("expected 400 VALIDATION_ERROR %q, got %d %+v", tt.want, status, resp)
+			}
+		})
+	}
+
+	// A valid body still works
+	if status, resp := postAction(t, handler, "/web/sessions/actions/start", `{"category":"work","task":"x"}`); status != http.StatusOK || !resp.OK {
+		t.Errorf("expected valid start to succeed, got %d %+v", status, resp)
+	}
+}
+
+func TestWebActions_Results(t *testing.T) {
+	handler, cleanup := setupWebTestEnv(t)
+	defer cleanup()
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"start with long category", "/web/sessions/actions/start", `{"category":"` + strings.Repeat("x", 1000) + `"}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"start", "/web/sessions/actions/start", `{"category":"work","task":"x"}`, http.StatusOK, ""},
+		{"start while running", "/web/sessions/actions/start", `{"category":"work","task":"y"}`, http.StatusConflict, "CONFLICT"},
+		{"stop", "/web/sessions/actions/stop", `{}`, http.StatusOK, ""},
+		{"stop with nothing running", "/web/sessions/actions/stop", `{}`, http.StatusNotFound, "NOT_FOUND"},
+		{"update", "/web/sessions/actions/update", `{"id":1,"task":"renamed"}`, http.StatusOK, ""},
+		{"update missing", "/web/sessions/actions/update", `{"id":999,"task":"renamed"}`, http.StatusNotFound, "NOT_FOUND"},
+		{"update missing with times", "/web/sessions/actions/update", `{"id":999,"started_at":"2024-01-01T00:00:00Z"}`, http.StatusNotFound, "NOT_FOUND"},
+		{"update with bad time", "/web/sessions/actions/update", `{"id":1,"started_at":"yesterday"}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"update with long task", "/web/sessions/actions/update", `{"id":1,"task":"` + strings.Repeat("x", 201) + `"}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"delete missing", "/web/sessions/actions/delete", `{"id":999}`, http.StatusNotFound, "NOT_FOUND"},
+		{"delete", "/web/sessions/actions/delete", `{"id":1}`, http.StatusOK, ""},
+		{"archive missing", "/web/tags/actions/archive", `{"id":999,"archived":true}`, http.StatusNotFound, "NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		status, resp := postAction(t, handler, tt.path, tt.body)
+		if status != tt.status || resp.OK != (tt.code == "") || resp.Code != tt.code || resp.Message == "" {
+			t.Errorf("%s: got %d %+v, want %d %q", tt.name, status, resp, tt.status, tt.code)
+		}
+	}
+
+	req := newRequest(http.MethodGet, "/web/sessions/actions/stop", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed || !strings.Contains(rr.Body.String(), `"code":"METHOD_NOT_ALLOWED"`) {
+		t.Errorf("GET action: got %d %q, want a 405 JSON body", rr.Code, rr.Body.String())
+	}
+	if allow := rr.Header().Get("Allow"); allow != "POST" {
+		t.Errorf("GET action: Allow = %q, want POST", allow)
+	}
+}
+
+func TestWebActions_FormFlash(t *testing.T) {
+	handler, cleanup := setupWebTestEnv(t)
+	defer cleanup()
+
+	// postForm submits a form as a browser without JavaScript would and
+	// follows the redirect, returning the page it lands on
+	postForm := func(path string, form url.Values) string {
+		t.Helper()
+		req := newRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/web/sessions" {
+			t.Fatalf("%s: got %d to %q, want a redirect to the sessions page", path, rr.Code, rr.Header().Get("Location"))
+		}
+
+		page := newRequest(http.MethodGet, "/web/sessions", nil)
+		for _, c := range rr.Result().Cookies() {
+			page.AddCookie(c)
+		}
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, page)
+
+		// The message is shown once
+		cleared := false
+		for _, c := range rr.Result().Cookies() {
+			cleared = cleared || (c.Name == flashCookieName && c.MaxAge < 0)
+		}
+		if !cleared {
+			t.Errorf("%s: flash cookie not cleared after it was shown", path)
+		}
+		return rr.Body.String()
+	}
+
+	if body := postForm("/web/sessions/actions/start", url.Values{"category": {"work"}, "task": {"form"}}); !strings.Contains(body, `<p class="flash-success">已开始计时</p>`) {
+		t.Errorf("start: page does not show the success message: %s", body)
+	}
+	if body := postForm("/web/sessions/actions/start", url.Values{"category": {"work"}, "task": {"again"}}); !strings.Contains(body, `<p class="flash-error">A session is already running</p>`) {
+		t.Errorf("second start: page does not show the conflict: %s", body)
+	}
+	if body := postForm("/web/sessions/actions/delete", url.Values{"id": {"one"}}); !strings.Contains(body, `<p class="flash-error">id must be an integer</p>`) {
+		t.Errorf("delete: page does not show the validation error: %s", body)
+	}
+
+	// Another site cannot submit the forms
+	for _, header := range []http.Header{
+		{"Sec-Fetch-Site": {"cross-site"}},
+		{"Origin": {"https://evil.example"}},
+	} {
+		req := newRequest(http.MethodPost, "/web/sessions/actions/stop", strings.NewReader(""))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("cross-site stop: got %d", rr.Code)
+		}
+	}
+	current, err := handler.sessionService.GetCurrent()
+	if err != nil || !current.Running {
+		t.Errorf("cross-site form stopped the session: %+v %v", current, err)
+	}
+
+	// A page without a pending message shows none
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest(http.MethodGet, "/web/sessions", nil))
+	if strings.Contains(rr.Body.String(), "flash-") {
+		t.Errorf("unexpected flash message: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,20 @@
+package web
+
+import "net/http"
+
+// Docs handles GET /web/docs - renders the API reference. The page is a
+// shell; main.js fills it in from /openapi.json, so it cannot drift from
+// the document.
+func (h *WebHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":      "API 文档",
+		"ActivePage": "docs",
+	}
+
+	h.renderTemplate(w, r, h.docsTemplate, "base", data)
+}
@@ -0,0 +1,246 @@
+package web
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
+	"time-tracker/internal/shared/utils"
+	"time-tracker/internal/shared/validation"
+)
+
+// dateTimeLocalLayout is the value format of a datetime-local input with
+// seconds; browsers leave them off when they are zero.
+const dateTimeLocalLayout = "2006-01-02T15:04:05"
+
+// EditFormData is a session as shown on the edit page. StartedAt and
+// EndedAt are datetime-local values in the display timezone.
+type EditFormData struct {
+	ID        int64
+	Running   bool
+	Category  string
+	Task      string
+	Note      string
+	Location  string
+	Mood      string
+	StartedAt string
+	EndedAt   string
+}
+
+// editSessionID returns the ID in a /web/sessions/{id}/edit path.
+func editSessionID(path string) (int64, bool) {
+	rest, ok := strings.CutPrefix(path, "/web/sessions/")
+	if !ok {
+		return 0, false
+	}
+	idStr, ok := strings.CutSuffix(rest, "/edit")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// EditSession handles GET and POST /web/sessions/{id}/edit - a form for
+// editing one session that works without JavaScript. A valid post saves
+// the session and returns to the list; an invalid one shows the form again
+// with an error next to each field at fault.
+func (h *WebHandler) EditSession(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+
+	session, err := h.sessionService.GetSessionContext(r.Context(), id)
+	if err != nil {
+		if stderrors.Is(err, sessions.ErrSessionNotFound) {
+			h.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to fetch session", http.StatusInternalServerError)
+		return
+	}
+	running := session.Status == string(models.SessionStatusRunning)
+
+	if r.Method == http.MethodGet {
+		form := EditFormData{
+			ID:        session.ID,
+			Running:   running,
+			Category:  session.Category,
+			Task:      session.Task,
+			Note:      utils.PtrToString(session.Note),
+			Location:  utils.PtrToString(session.Location),
+			Mood:      utils.PtrToString(session.Mood),
+			StartedAt: h.formatDateTimeLocal(session.StartedAt),
+		}
+		if session.EndedAt != nil {
+			form.EndedAt = h.formatDateTimeLocal(*session.EndedAt)
+		}
+		h.renderEdit(w, r, http.StatusOK, form, nil)
+		return
+	}
+
+	// Same check as the form actions: another site could post this form
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-site form submissions are not allowed", http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form body", http.StatusBadRequest)
+		return
+	}
+	form := EditFormData{
+		ID:        id,
+		Running:   running,
+		Category:  r.PostForm.Get("category"),
+		Task:      r.PostForm.Get("task"),
+		Note:      r.PostForm.Get("note"),
+		Location:  r.PostForm.Get("location"),
+		Mood:      r.PostForm.Get("mood"),
+		StartedAt: r.PostForm.Get("started_at"),
+		EndedAt:   r.PostForm.Get("ended_at"),
+	}
+
+	update, fieldErrors := h.parseEditForm(form, session)
+	if len(fieldErrors) == 0 {
+		err := h.sessionService.UpdateSessionContext(r.Context(), id, update)
+		if err == nil {
+			setFlash(w, Flash{Kind: "success", Message: "记录已更新"})
+			http.Redirect(w, r, "/web/sessions", http.StatusSeeOther)
+			return
+		}
+		fieldErrors = editFieldErrors(err)
+		if fieldErrors == nil {
+			if stderrors.Is(err, sessions.ErrSessionNotFound) {
+				h.NotFound(w, r)
+				return
+			}
+			h.logger.Error("web edit failed", "session_id", id, "error", err)
+			http.Error(w, "Failed to update session", http.StatusInternalServerError)
+			return
+		}
+	}
+	h.renderEdit(w, r, http.StatusBadRequest, form, fieldErrors)
+}
+
+// parseEditForm turns the posted form for session into an update, or
+// reports what is wrong with it that the update's own validation would not
+// catch. The end time is only edited on stopped sessions, so editing cannot
+// leave a running session with an end. Times left as they were are not
+// updated, so their milliseconds survive the form's whole seconds.
+func (h *WebHandler) parseEditForm(form EditFormData, session *models.SessionResponse) (*sessions.SessionUpdate, map[string]string) {
+	update := &sessions.SessionUpdate{
+		Category: &form.Category,
+		Task:     &form.Task,
+		Note:     formDetail(form.Note),
+		Location: formDetail(form.Location),
+		Mood:     formDetail(form.Mood),
+	}
+	fieldErrors := map[string]string{}
+
+	// A blank field would be taken as not given and keep its old value
+	if validation.SanitizeString(form.Category) == "" {
+		fieldErrors["category"] = models.ErrCategoryRequired.Error()
+	}
+	if validation.SanitizeString(form.Task) == "" {
+		fieldErrors["task"] = models.ErrTaskRequired.Error()
+	}
+
+	startedAt, startChanged, startErr := h.formTime(form.StartedAt, session.StartedAt)
+	if startErr != nil {
+		fieldErrors["started_at"] = "started_at must be a date and time"
+	} else if startChanged {
+		started := models.FormatRFC3339(startedAt)
+		update.StartedAt = &started
+	}
+	if !form.Running {
+		endedAt, endChanged, err := h.formTime(form.EndedAt, utils.PtrToString(session.EndedAt))
+		switch {
+		case err != nil:
+			fieldErrors["ended_at"] = "ended_at must be a date and time"
+		case startErr == nil && endedAt.Before(startedAt):
+			fieldErrors["ended_at"] = "ended_at must not be before started_at"
+		case endChanged:
+			update.EndedAt = models.Some(models.FormatRFC3339(endedAt))
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+	return update, nil
+}
+
+// formDetail turns a posted note, location or mood into an update field.
+// A field the form leaves blank clears the old value.
+func formDetail(value string) models.Optional[string] {
+	if validation.SanitizeString(value) == "" {
+		return models.Null[string]()
+	}
+	return models.Some(value)
+}
+
+// editFieldErrors returns the field errors for a validation error from
+// the session service, or nil for any other error.
+func editFieldErrors(err error) map[string]string {
+	var fieldErrs models.ValidationErrors
+	if stderrors.As(err, &fieldErrs) {
+		return fieldErrs.Fields()
+	}
+	return nil
+}
+
+// parseDateTimeLocal reads a datetime-local value in the display timezone.
+func (h *WebHandler) parseDateTimeLocal(value string) (time.Time, error) {
+	t, err := time.ParseInLocation(dateTimeLocalLayout, value, h.timezone)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02T15:04", value, h.timezone)
+	}
+	return t, err
+}
+
+// formTime reads a posted datetime-local value and reports whether it
+// differs from orig, the RFC3339 time the form was filled with. The field
+// only holds whole seconds, so a value within orig's second is unchanged
+// and orig is returned as it is.
+func (h *WebHandler) formTime(value, orig string) (time.Time, bool, error) {
+	t, err := h.parseDateTimeLocal(value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if o, err := time.Parse(time.RFC3339, orig); err == nil && t.Equal(o.Truncate(time.Second)) {
+		return o, false, nil
+	}
+	return t, true, nil
+}
+
+// formatDateTimeLocal converts an RFC3339 timestamp to a datetime-local
+// value in the display timezone.
+func (h *WebHandler) formatDateTimeLocal(rfc3339 string) string {
+	t, err := time.Parse(time.RFC3339, rfc3339)
+	if err != nil {
+		return ""
+	}
+	return t.In(h.timezone).Format(dateTimeLocalLayout)
+}
+
+func (h *WebHandler) renderEdit(w http.ResponseWriter, r *http.Request, status int, form EditFormData, fieldErrors map[string]string) {
+	data := map[string]interface{}{
+		"Title":      "编辑记录",
+		"ActivePage": "edit",
+		"Form":       form,
+		"Errors":     fieldErrors,
+		"TimeZone":   h.timezone.String(),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	h.renderTemplate(w, r, h.editTemplate, "base", data)
+}
@@ -3,18 +3,41 @@ package web
 import (
 	"fmt"
 	"html/template"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
+	"time-tracker/internal/audit"
+	"time-tracker/internal/presets"
 	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/buildinfo"
+	"time-tracker/internal/shared/config"
 	"time-tracker/internal/shared/middleware"
+	"time-tracker/internal/tags"
+	"time-tracker/templates"
 )
 // WebHandler handles HTTP requests for web interface.
 type WebHandler struct {
 	sessionService   *sessions.SessionService
+	tagService       *tags.TagService
+	presetService    *presets.PresetService
 	sessionsTemplate *template.Template
+	tagsTemplate     *template.Template
+	loginTemplate    *template.Template
+	editTemplate     *template.Template
+	notFoundTemplate *template.Template
+	docsTemplate     *template.Template
+	static           *staticAssets
 	timezone         *time.Location
-	apiKey           string
+	credentials      *auth.CredentialStore
+	webSessions      *auth.WebSessionStore
+	audit            *audit.Logger
+	maxBodyBytes     int64
+	streamHeartbeat  time.Duration
+	logger           *slog.Logger
 }
 // SessionViewData represents a session for display in templates.
 type SessionViewData struct {
@@ -43,25 +66,126 @@ type SessionsPageData struct {
 	PrevPage       int
 	NextPage       int
 	RunningSession *SessionViewData
+	RunningTags    []tags.Tag
 	Categories     []string
-	APIKey         string
 }
-// NewWebHandler creates a new WebHandler.
-func NewWebHandler(sessionSvc *sessions.SessionService, templatesPath string, tz *time.Location, apiKey string) (*WebHandler, error) {
-	sessionsTmpl, err := template.ParseFiles(templatesPath+"/base.html", templatesPath+"/sessions.html")
+// NewWebHandler creates a new WebHandler with the templates and static/
+// assets in fsys, or the ones embedded in the binary if fsys is nil.
+func NewWebHandler(sessionSvc *sessions.SessionService, tagSvc *tags.TagService, fsys fs.FS, tz *time.Location) (*WebHandler, error) {
+	if fsys == nil {
+		fsys = templates.FS
+	}
+	staticFS, err := fs.Sub(fsys, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open static assets: %w", err)
+	}
+	static, err := newStaticAssets(staticFS)
+	if err != nil {
+		return nil, err
+	}
+	funcs := template.FuncMap{"assetPath": static.assetPath}
+	sessionsTmpl, err := template.New("sessions").Funcs(funcs).ParseFS(fsys, "base.html", "sessions.html")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse sessions template: %w", err)
 	}
+	tagsTmpl, err := template.New("tags").Funcs(funcs).ParseFS(fsys, "base.html", "tags.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tags template: %w", err)
+	}
+	loginTmpl, err := template.New("login").Funcs(funcs).ParseFS(fsys, "base.html", "login.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse login template: %w", err)
+	}
+	editTmpl, err := template.New("edit").Funcs(funcs).ParseFS(fsys, "base.html", "edit.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse edit template: %w", err)
+	}
+	notFoundTmpl, err := template.New("notfound").Funcs(funcs).ParseFS(fsys, "base.html", "notfound.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse not found template: %w", err)
+	}
+	docsTmpl, err := template.New("docs").Funcs(funcs).ParseFS(fsys, "base.html", "docs.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse docs template: %w", err)
+	}
 	if tz == nil {
 		tz = time.UTC
 	}
 	return &WebHandler{
 		sessionService:   sessionSvc,
+		tagService:       tagSvc,
 		sessionsTemplate: sessionsTmpl,
+		tagsTemplate:     tagsTmpl,
+		loginTemplate:    loginTmpl,
+		editTemplate:     editTmpl,
+		notFoundTemplate: notFoundTmpl,
+		docsTemplate:     docsTmpl,
+		static:           static,
 		timezone:         tz,
-		apiKey:           apiKey,
+		maxBodyBytes:     config.MaxJSONBodyBytes,
+		streamHeartbeat:  config.DefaultStreamHeartbeat,
+		logger:           slog.Default(),
 	}, nil
 }
+
+// Static returns the handler for /static/, serving the static/ directory of
+// the handler's file system with content types from the file extensions.
+// Templates link to the files with assetPath, which gives them names that
+// browsers may cache for good.
+func (h *WebHandler) Static() http.Handler {
+	return h.static
+}
+
+// StaticFile returns a handler serving the file name of static/ at any
+// path, for files such as /favicon.ico that clients look for at the root.
+func (h *WebHandler) StaticFile(name string) http.Handler {
+	return h.static.file(name)
+}
+
+// NotFound renders the 404 page.
+func (h *WebHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Title": "页面不存在",
+		"Path":  r.URL.Path,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	h.renderTemplate(w, r, h.notFoundTemplate, "base", data)
+}
+
+// writeMethodNotAllowed answers a page request made with a method the page
+// does not take, listing the ones it does in the Allow header.
+func writeMethodNotAllowed(w http.ResponseWriter, allow ...string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// SetMaxBodyBytes overrides the maximum size of a JSON request body.
+func (h *WebHandler) SetMaxBodyBytes(max int64) {
+	if max > 0 {
+		h.maxBodyBytes = max
+	}
+}
+
+// SetPresets shows the quick-start presets on the sessions page.
+func (h *WebHandler) SetPresets(svc *presets.PresetService) {
+	h.presetService = svc
+}
+
+// SetStreamHeartbeat sets how often the session stream sends the elapsed time.
+func (h *WebHandler) SetStreamHeartbeat(interval time.Duration) {
+	if interval > 0 {
+		h.streamHeartbeat = interval
+	}
+}
+
+// SetLogger sets the logger for failures the user only sees as a generic error.
+func (h *WebHandler) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		h.logger = logger
+	}
+}
+
 // renderTemplate renders a template with the given data.
 func (h *WebHandler) renderTemplate(w http.ResponseWriter, r *http.Request, tmpl *template.Template, templateName string, data interface{}) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -72,6 +196,8 @@ func (h *WebHandler) renderTemplate(w http.ResponseWriter, r *http.Request, tmpl
 	if nonce, ok := r.Context().Value(middleware.CSPNonceKey{}).(string); ok {
 		pageData["ScriptNonce"] = nonce
 	}
+	pageData["LoginEnabled"] = h.loginEnabled()
+	pageData["Version"] = buildinfo.Version
 	if err := tmpl.ExecuteTemplate(w, templateName, pageData); err != nil {
 		http.Error(w, "Template error: "+err.Error(), http.StatusInternalServerError)
 	}
@@ -97,15 +223,37 @@ func (h *WebHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch path {
 	case "/web/sessions":
 		h.Sessions(w, r)
+	case "/web/sessions/stream":
+		h.Stream(w, r)
+	case "/web/sessions/partials/table":
+		h.SessionsPartial(w, r, "sessions-table")
+	case "/web/sessions/partials/running":
+		h.SessionsPartial(w, r, "sessions-running")
 	case "/web/sessions/actions/start":
 		h.WebStartSession(w, r)
+	case "/web/sessions/actions/start-preset":
+		h.WebStartPreset(w, r)
 	case "/web/sessions/actions/stop":
 		h.WebStopSession(w, r)
 	case "/web/sessions/actions/delete":
 		h.WebDeleteSession(w, r)
 	case "/web/sessions/actions/update":
 		h.WebUpdateSession(w, r)
+	case "/web/tags":
+		h.Tags(w, r)
+	case "/web/tags/actions/archive":
+		h.WebArchiveTag(w, r)
+	case "/web/docs":
+		h.Docs(w, r)
+	case "/web/login":
+		h.Login(w, r)
+	case "/web/logout":
+		h.Logout(w, r)
 	default:
-		http.NotFound(w, r)
+		if id, ok := editSessionID(path); ok {
+			h.EditSession(w, r, id)
+			return
+		}
+		h.NotFound(w, r)
 	}
 }
@@ -0,0 +1,589 @@
+package web
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/presets"
+	"time-tracker/internal/sessions"
+	"time-tracker/internal/shared/auth"
+	"time-tracker/internal/shared/database"
+	"time-tracker/internal/tags"
+)
+
+func TestNewWebHandler_Embedded(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler, err := NewWebHandler(
+		sessions.NewSessionService(sessions.NewSessionRepository(db)),
+		tags.NewTagService(tags.NewTagRepository(db)),
+		nil, time.UTC)
+	if err != nil {
+		t.Fatalf("failed to parse embedded templates: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/sessions", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<html") {
+		t.Errorf("sessions page: %d %.100q", w.Code, w.Body.String())
+	}
+
+	// The API reference is filled in by main.js, which is served from here
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/docs", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `id="api-docs"`) || !strings.Contains(w.Body.String(), `data-page="docs"`) {
+		t.Errorf("docs page: %d %.100q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, "/web/docs", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST docs page: expected 405, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Static().ServeHTTP(w, newRequest(http.MethodGet, "/static/js/main.js", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("embedded main.js: expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("main.js Content-Type = %q", ct)
+	}
+}
+
+func TestWebHandler_Static(t *testing.T) {
+	handler, cleanup := setupWebTestEnv(t)
+	defer cleanup()
+
+	w := httptest.NewRecorder()
+	handler.Static().ServeHTTP(w, newRequest(http.MethodGet, "/static/app.js", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `console.log("test")` {
+		t.Fatalf("app.js: %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("app.js Content-Type = %q", ct)
+	}
+
+	// Templates are not reachable as static files
+	w = httptest.NewRecorder()
+	handler.Static().ServeHTTP(w, newRequest(http.MethodGet, "/static/../base.html", nil))
+	if w.Code == http.StatusOK {
+		t.Error("base.html served from /static/")
+	}
+}
+
+func TestWebHandler_StaticCaching(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler, err := NewWebHandler(
+		sessions.NewSessionService(sessions.NewSessionRepository(db)),
+		tags.NewTagService(tags.NewTagRepository(db)),
+		nil, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := newRequest(http.MethodGet, path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		handler.Static().ServeHTTP(w, req)
+		return w
+	}
+
+	// The plain name is revalidated with its ETag
+	plain := get("/static/js/main.js", nil)
+	etag := plain.Header().Get("ETag")
+	if plain.Code != http.StatusOK || etag == "" {
+		t.Fatalf("main.js: %d, ETag %q", plain.Code, etag)
+	}
+	if cc := plain.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("main.js Cache-Control = %q, want no-cache", cc)
+	}
+	w := get("/static/js/main.js", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("main.js with matching If-None-Match: %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if w := get("/static/js/main.js", http.Header{"If-None-Match": {`"stale"`}}); w.Code != http.StatusOK {
+		t.Errorf("main.js with stale If-None-Match: %d", w.Code)
+	}
+
+	// Pages link to the fingerprinted name, which is cached for good
+	page := httptest.NewRecorder()
+	handler.ServeHTTP(page, newRequest(http.MethodGet, "/web/sessions", nil))
+	m := regexp.MustCompile(`src="(/static/js/main\.[0-9a-f]{12}\.js)"`).FindStringSubmatch(page.Body.String())
+	if m == nil {
+		t.Fatalf("sessions page does not link a fingerprinted main.js:\n%s", page.Body.String())
+	}
+	hashed := get(m[1], nil)
+	if hashed.Code != http.StatusOK || hashed.Body.String() != plain.Body.String() {
+		t.Fatalf("%s: %d, same body %v", m[1], hashed.Code, hashed.Body.String() == plain.Body.String())
+	}
+	if cc := hashed.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Errorf("%s Cache-Control = %q", m[1], cc)
+	}
+	if ct := hashed.Header().Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("%s Content-Type = %q", m[1], ct)
+	}
+
+	// Directories are not listed, and a wrong hash is not served
+	for _, path := range []string{"/static/", "/static/js/", "/static/js", "/static/js/main.000000000000.js"} {
+		if w := get(path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestSessions_Filters(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler := newTestWebHandler(t, db)
+
+	// 12 tagged sessions on 10 March in Asia/Shanghai, and decoys just
+	// outside the day, untagged on the day, and tagged with another tag
+	for _, stmt := range []string{
+		`INSERT INTO tags (id, name, color, created_at) VALUES (1, 'deep', '#000000', '2024-01-01T00:00:00Z'), (2, 'other', '#000000', '2024-01-01T00:00:00Z')`,
+		`WITH RECURSIVE seq(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM seq WHERE i < 11)
+			INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+			SELECT 'work', 'match', strftime('%Y-%m-%dT%H:%M:%SZ', '2024-03-09T16:00:00', '+' || (i * 60) || ' minutes'), '2024-03-10T15:00:00Z', 60, 'stopped' FROM seq`,
+		`INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status) VALUES
+			('work', 'day before', '2024-03-09T15:59:59Z', '2024-03-09T16:30:00Z', 60, 'stopped'),
+			('work', 'day after', '2024-03-10T16:00:00Z', '2024-03-10T17:00:00Z', 60, 'stopped'),
+			('work', 'untagged', '2024-03-10T01:00:00Z', '2024-03-10T02:00:00Z', 60, 'stopped'),
+			('work', 'other tag', '2024-03-10T01:00:00Z', '2024-03-10T02:00:00Z', 60, 'stopped')`,
+		`INSERT INTO session_tags (session_id, tag_id) SELECT id, 1 FROM sessions WHERE task IN ('match', 'day before', 'day after')`,
+		`INSERT INTO session_tags (session_id, tag_id) SELECT id, 2 FROM sessions WHERE task = 'other tag'`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(target string) (tasks []string, links map[string]string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", target, w.Code, w.Body.String())
+		}
+		for _, m := range regexp.MustCompile(`<li>([^<]*)</li>`).FindAllStringSubmatch(w.Body.String(), -1) {
+			tasks = append(tasks, m[1])
+		}
+		links = map[string]string{}
+		for _, m := range regexp.MustCompile(`<a id="(\w+)" href="([^"]*)">`).FindAllStringSubmatch(w.Body.String(), -1) {
+			links[m[1]] = html.UnescapeString(m[2])
+		}
+		return tasks, links
+	}
+
+	tasks, links := get("/web/sessions?from=2024-03-10&to=2024-03-10&tag_id=1")
+	if len(tasks) != 10 {
+		t.Fatalf("page 1 has %d sessions, want 10", len(tasks))
+	}
+	next, err := url.Parse(links["next"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"from": {"2024-03-10"}, "to": {"2024-03-10"}, "tag_id": {"1"}, "page": {"2"}}
+	if next.Path != "/web/sessions" || next.Query().Encode() != want.Encode() {
+		t.Errorf("next link = %q, want the filters and page=2", links["next"])
+	}
+
+	tasks, links = get(links["next"])
+	if strings.Join(tasks, ",") != "match,match" {
+		t.Errorf("page 2 tasks = %v, want the two remaining matches", tasks)
+	}
+	if prev, _ := url.Parse(links["prev"]); prev.Query().Get("page") != "1" || prev.Query().Get("tag_id") != "1" {
+		t.Errorf("prev link = %q, want the filters and page=1", links["prev"])
+	}
+
+	// The export gets the day resolved in the display timezone
+	export, err := url.Parse(links["export"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := export.Query(); q.Get("from") != "2024-03-09T16:00:00.000Z" || q.Get("to") != "2024-03-10T16:00:00.000Z" || q.Get("tag_id") != "1" {
+		t.Errorf("export link = %q", links["export"])
+	}
+
+	// Invalid filters are dropped rather than failing the page
+	tasks, links = get("/web/sessions?from=yesterday&tag_id=x")
+	if len(tasks) != 10 || strings.Contains(links["next"], "from=") || strings.Contains(links["next"], "tag_id=") {
+		t.Errorf("invalid filters: %d sessions, next link %q", len(tasks), links["next"])
+	}
+}
+
+func TestSessions_PageSize(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler := newTestWebHandler(t, db)
+	if _, err := db.Exec(`
+		WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < 60)
+		INSERT INTO sessions (category, task, started_at, ended_at, duration_sec, status)
+		SELECT 'work', 'task ' || i, strftime('%Y-%m-%dT%H:%M:%SZ', '2024-01-01', '+' || i || ' hours'), NULL, NULL, 'stopped'
+		FROM seq`); err != nil {
+		t.Fatal(err)
+	}
+
+	rangeRe := regexp.MustCompile(`<p id="range">([^<]*)</p>`)
+	nextRe := regexp.MustCompile(`<a id="next" href="([^"]*)">`)
+	for _, tt := range []struct {
+		target string
+		items  int
+		rng    string
+		next   url.Values
+	}{
+		{"/web/sessions", 10, "1-10 of 60, page 1 of 6", url.Values{"page": {"2"}}},
+		{"/web/sessions?per_page=25&category=work", 25, "1-25 of 60, page 1 of 3", url.Values{"page": {"2"}, "per_page": {"25"}, "category": {"work"}}},
+		// Past the end shows the last page
+		{"/web/sessions?per_page=25&page=9", 10, "51-60 of 60, page 3 of 3", url.Values{"page": {"4"}, "per_page": {"25"}}},
+		// Sizes that are not offered get the default
+		{"/web/sessions?per_page=30", 10, "1-10 of 60, page 1 of 6", url.Values{"page": {"2"}}},
+		{"/web/sessions?per_page=lots", 10, "1-10 of 60, page 1 of 6", url.Values{"page": {"2"}}},
+		// No matches is page 1 of 1 with an empty range
+		{"/web/sessions?category=none&page=3", 0, "0-0 of 0, page 1 of 1", url.Values{"page": {"2"}, "category": {"none"}}},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, tt.target, nil))
+		body := w.Body.String()
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", tt.target, w.Code, body)
+		}
+
+		if items := strings.Count(body, "<li>"); items != tt.items {
+			t.Errorf("%s: %d sessions, want %d", tt.target, items, tt.items)
+		}
+		if m := rangeRe.FindStringSubmatch(body); m == nil || m[1] != tt.rng {
+			t.Errorf("%s: range %v, want %q", tt.target, m, tt.rng)
+		}
+		m := nextRe.FindStringSubmatch(body)
+		if m == nil {
+			t.Fatalf("%s: no next link", tt.target)
+		}
+		next, err := url.Parse(html.UnescapeString(m[1]))
+		if err != nil || next.Query().Encode() != tt.next.Encode() {
+			t.Errorf("%s: next link %q, want query %q", tt.target, m[1], tt.next.Encode())
+		}
+	}
+}
+
+func TestSessions_CategoriesAndRunningTags(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler, err := NewWebHandler(
+		sessions.NewSessionService(sessions.NewSessionRepository(db)),
+		tags.NewTagService(tags.NewTagRepository(db)),
+		nil, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO tags (id, name, color, created_at) VALUES (1, 'deep', '#112233', '2024-01-01T00:00:00Z'), (2, 'unused', '#445566', '2024-01-01T00:00:00Z')`,
+		`INSERT INTO sessions (id, category, task, started_at, ended_at, duration_sec, status) VALUES
+			(1, 'study', 'old', '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped'),
+			(2, 'work', 'older', '2023-01-01T09:00:00Z', '2023-01-01T10:00:00Z', 3600, 'stopped')`,
+		`INSERT INTO sessions (id, category, task, started_at, status) VALUES (3, 'writing', 'now', '2024-02-01T09:00:00Z', 'running')`,
+		`INSERT INTO session_tags (session_id, tag_id) VALUES (3, 1), (1, 2)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/sessions", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("sessions page: %d %s", w.Code, body)
+	}
+
+	// Every category is suggested, most recently used first
+	options := regexp.MustCompile(`<option value="([^"]*)">`).FindAllStringSubmatch(body[strings.Index(body, `<datalist id="categoryOptions">`):], 3)
+	var got []string
+	for _, m := range options {
+		got = append(got, m[1])
+	}
+	if strings.Join(got, ",") != "writing,study,work" {
+		t.Errorf("category suggestions = %v, want writing, study, work", got)
+	}
+
+	// Only the running session's tags are shown in the banner
+	chips := regexp.MustCompile(`class="tag-chip"[^>]*background-color: ([^;"]*);">([^<]*)</span>`).FindAllStringSubmatch(body, -1)
+	if len(chips) != 1 || chips[0][1] != "#112233" || chips[0][2] != "deep" {
+		t.Errorf("running tag chips = %v, want deep in #112233", chips)
+	}
+}
+
+func TestSessions_Partials(t *testing.T) {
+	db := database.NewForTesting(t)
+	handler, err := NewWebHandler(
+		sessions.NewSessionService(sessions.NewSessionRepository(db)),
+		tags.NewTagService(tags.NewTagRepository(db)),
+		nil, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, category, task, started_at, ended_at, duration_sec, status) VALUES
+		(1, 'work', 'review', '2024-01-01T09:00:00Z', '2024-01-01T10:00:00Z', 3600, 'stopped'),
+		(2, 'study', 'reading', '2024-01-02T09:00:00Z', '2024-01-02T10:00:00Z', 3600, 'stopped'),
+		(3, 'work', 'coding', '2024-02-01T09:00:00Z', NULL, NULL, 'running')`); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, path, nil))
+		body := w.Body.String()
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", path, w.Code, body)
+		}
+		if strings.Contains(body, "<html") || strings.Contains(body, "<nav") {
+			t.Errorf("GET %s rendered the page around the partial:\n%s", path, body)
+		}
+		return body
+	}
+
+	// The table takes the page's filters
+	table := get("/web/sessions/partials/table?category=work")
+	if !strings.Contains(table, "<table>") || !strings.Contains(table, "coding") || !strings.Contains(table, "review") {
+		t.Errorf("table partial is missing the work sessions:\n%s", table)
+	}
+	if strings.Contains(table, "reading") {
+		t.Errorf("table partial ignored the category filter:\n%s", table)
+	}
+	if strings.Contains(table, "startSessionForm") {
+		t.Errorf("table partial includes the running banner:\n%s", table)
+	}
+
+	running := get("/web/sessions/partials/running")
+	if !strings.Contains(running, "work - coding") || !strings.Contains(running, `id="stopSessionForm"`) {
+		t.Errorf("running partial is missing the running session:\n%s", running)
+	}
+	if strings.Contains(running, "<table>") {
+		t.Errorf("running partial includes the table:\n%s", running)
+	}
+
+	// With nothing running the banner is the start form
+	if _, err := db.Exec(`DELETE FROM sessions WHERE id = 3`); err != nil {
+		t.Fatal(err)
+	}
+	if running := get("/web/sessions/partials/running"); !strings.Contains(running, `id="startSessionForm"`) {
+		t.Errorf("running partial without a session lacks the start form:\n%s", running)
+	}
+
+	// The full page still renders both inside their containers
+	page := httptest.NewRecorder()
+	handler.ServeHTTP(page, newRequest(http.MethodGet, "/web/sessions", nil))
+	body := page.Body.String()
+	if !strings.Contains(body, `id="running-panel"`) || !strings.Contains(body, `id="sessions-table"`) || !strings.Contains(body, "<table>") {
+		t.Errorf("sessions page lacks the partial containers:\n%s", body)
+	}
+}
+
+func TestEditSession(t *testing.T) {
+	db := database.NewForTesting(t)
+	tz, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("Asia/Shanghai timezone not available")
+	}
+	svc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+	handler, err := NewWebHandler(svc, tags.NewTagService(tags.NewTagRepository(db)), nil, tz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, category, task, note, started_at, ended_at, duration_sec, status) VALUES
+		(1, 'work', 'review', 'first pass', '2024-03-10T01:00:00Z', '2024-03-10T02:30:15Z', 5415, 'stopped'),
+		(2, 'study', 'reading', NULL, '2024-03-11T01:00:00Z', NULL, NULL, 'running'),
+		(3, 'work', 'precise', NULL, '2024-03-12T01:00:00.250Z', '2024-03-12T02:00:00.750Z', 3601, 'stopped')`); err != nil {
+		t.Fatal(err)
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := newRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// The form shows the session with local times
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/sessions/1/edit", nil))
+	body := html.UnescapeString(w.Body.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("edit page: %d %s", w.Code, body)
+	}
+	for _, want := range []string{`action="/web/sessions/1/edit"`, `value="review"`, ">first pass</textarea>", `value="2024-03-10T09:00:00"`, `value="2024-03-10T10:30:15"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("edit page lacks %s", want)
+		}
+	}
+
+	// A running session's end is not editable
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/sessions/2/edit", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `name="ended_at"`) {
+		t.Errorf("running session edit page: %d, has ended_at %v", w.Code, strings.Contains(w.Body.String(), `name="ended_at"`))
+	}
+
+	for _, path := range []string{"/web/sessions/99/edit", "/web/sessions/abc/edit", "/web/sessions/0/edit", "/web/sessions/1/edit/more"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, w.Code)
+		}
+	}
+
+	// Invalid fields are shown next to their inputs and nothing is saved
+	valid := url.Values{
+		"category":   {"work"},
+		"task":       {"code review"},
+		"note":       {"second pass"},
+		"location":   {"office"},
+		"mood":       {"focused"},
+		"started_at": {"2024-03-10T08:00"},
+		"ended_at":   {"2024-03-10T10:00:30"},
+	}
+	for name, tc := range map[string]struct{ field, value, want string }{
+		"mood too long": {"mood", strings.Repeat("m", 21), "mood must be at most 20 characters"},
+		"task missing":  {"task", " ", "task is required"},
+		"bad start":     {"started_at", "yesterday", "started_at must be a date and time"},
+		"end before":    {"ended_at", "2024-03-10T07:59", "ended_at must not be before started_at"},
+		"end missing":   {"ended_at", "", "ended_at must be a date and time"},
+	} {
+		form := url.Values{}
+		for key, values := range valid {
+			form[key] = values
+		}
+		form.Set(tc.field, tc.value)
+		w := post("/web/sessions/1/edit", form)
+		body := html.UnescapeString(w.Body.String())
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", name, w.Code)
+			continue
+		}
+		if !strings.Contains(body, `<span class="field-error">`+tc.want+`</span>`) {
+			t.Errorf("%s: no field error %q in\n%s", name, tc.want, body)
+		}
+		// The form keeps what was entered
+		if !strings.Contains(body, `value="code review"`) && tc.field != "task" {
+			t.Errorf("%s: the form lost the entered task", name)
+		}
+	}
+	var task string
+	if err := db.QueryRow(`SELECT task FROM sessions WHERE id = 1`).Scan(&task); err != nil || task != "review" {
+		t.Fatalf("invalid posts changed the session: task %q, %v", task, err)
+	}
+
+	// Another site cannot post the form
+	req := newRequest(http.MethodPost, "/web/sessions/1/edit", strings.NewReader(valid.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("cross-site post: %d, want 403", w.Code)
+	}
+
+	// A valid post saves in UTC and returns to the list with a message
+	w = post("/web/sessions/1/edit", valid)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/web/sessions" {
+		t.Fatalf("valid post: %d to %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Set-Cookie"), flashCookieName+"=") {
+		t.Error("valid post set no flash message")
+	}
+	session, err := svc.GetSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Task != "code review" || *session.Note != "second pass" || *session.Location != "office" || *session.Mood != "focused" {
+		t.Errorf("saved fields: %+v", session)
+	}
+	if session.StartedAt != "2024-03-10T00:00:00.000Z" || *session.EndedAt != "2024-03-10T02:00:30.000Z" || *session.DurationSec != 7230 {
+		t.Errorf("saved times: %s to %s, %d s", session.StartedAt, *session.EndedAt, *session.DurationSec)
+	}
+
+	// Times left as shown keep their milliseconds, even with the seconds
+	// dropped as browsers do when they are zero
+	w = post("/web/sessions/3/edit", url.Values{
+		"category":   {"work"},
+		"task":       {"precise"},
+		"started_at": {"2024-03-12T09:00:00"},
+		"ended_at":   {"2024-03-12T10:00"},
+	})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("unchanged times post: %d: %s", w.Code, w.Body.String())
+	}
+	session, err = svc.GetSession(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.StartedAt != "2024-03-12T01:00:00.250Z" || *session.EndedAt != "2024-03-12T02:00:00.750Z" || *session.DurationSec != 3601 {
+		t.Errorf("unchanged times were rewritten: %s to %s, %d s", session.StartedAt, *session.EndedAt, *session.DurationSec)
+	}
+}
+
+func TestSessions_Presets(t *testing.T) {
+	db := database.NewForTesting(t)
+	sessionSvc := sessions.NewSessionService(sessions.NewSessionRepository(db))
+	tagSvc := tags.NewTagService(tags.NewTagRepository(db))
+	handler, err := NewWebHandler(sessionSvc, tagSvc, nil, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without presets configured the action finds none
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, "/web/sessions/actions/start-preset", strings.NewReader(`{"id":1}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("start-preset without presets: %d", w.Code)
+	}
+
+	presetSvc := presets.NewPresetService(presets.NewPresetRepository(db), sessionSvc, tagSvc)
+	handler.SetPresets(presetSvc)
+	preset, err := presetSvc.Create(&presets.PresetCreate{Name: "Review", Category: "work", Task: "code review"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/sessions", nil))
+	if !regexp.MustCompile(`action="/web/sessions/actions/start-preset">\s*<input type="hidden" name="id" value="` + strconv.FormatInt(preset.ID, 10) + `">\s*<button[^>]*>Review</button>`).MatchString(w.Body.String()) {
+		t.Fatalf("sessions page lacks the preset button:\n%s", w.Body.String())
+	}
+
+	req := newRequest(http.MethodPost, "/web/sessions/actions/start-preset", strings.NewReader("id="+strconv.FormatInt(preset.ID, 10)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("start-preset form post: %d %s", w.Code, w.Body.String())
+	}
+	current, err := sessionSvc.GetCurrent()
+	if err != nil || !current.Running || current.Session.Task != "code review" {
+		t.Fatalf("no session started from the preset: %+v, %v", current, err)
+	}
+
+	// The buttons are only offered while nothing is running, and a second
+	// start conflicts as usual
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodGet, "/web/sessions/partials/running", nil))
+	if strings.Contains(w.Body.String(), "preset-form") {
+		t.Error("preset buttons shown while a session runs")
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, "/web/sessions/actions/start-preset", strings.NewReader(`{"id":`+strconv.FormatInt(preset.ID, 10)+`}`)))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"code":"CONFLICT"`) {
+		t.Errorf("second start-preset: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// newRequest is httptest.NewRequest acting for the default user, as the auth
+// middlewares would make it.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(auth.DefaultUserContext())
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"time-tracker/internal/audit"
+	"time-tracker/internal/shared/auth"
+)
+
+// SetAuth enables cookie login with the given credentials and session store.
+// Without it /web/login redirects straight to the sessions page.
+func (h *WebHandler) SetAuth(creds *auth.CredentialStore, webSessions *auth.WebSessionStore) {
+	h.credentials = creds
+	h.webSessions = webSessions
+}
+
+// SetAudit records failed logins and session deletions to the audit log.
+func (h *WebHandler) SetAudit(logger *audit.Logger) {
+	h.audit = logger
+}
+
+// loginEnabled reports whether pages require signing in, with the web
+// credentials or as a user with a password.
+func (h *WebHandler) loginEnabled() bool {
+	return h.credentials != nil && h.webSessions != nil && h.credentials.AuthRequired()
+}
+
+// Login handles GET /web/login (login form) and POST /web/login (credential check).
+// A successful login sets an HttpOnly session cookie and redirects to next.
+func (h *WebHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if !h.loginEnabled() {
+		http.Redirect(w, r, "/web/sessions", http.StatusSeeOther)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.renderLogin(w, r, http.StatusOK, r.URL.Query().Get("next"), "")
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form body", http.StatusBadRequest)
+			return
+		}
+		next := r.PostForm.Get("next")
+		username := r.PostForm.Get("username")
+		userID, ok := h.credentials.Authenticate(username, r.PostForm.Get("password"))
+		if !ok {
+			h.audit.RecordActor(r, audit.EventAuthFailed, auth.UserActor(username), map[string]interface{}{"reason": "invalid_login", "path": r.URL.Path})
+			h.renderLogin(w, r, http.StatusUnauthorized, next, "用户名或密码错误")
+			return
+		}
+
+		token, expires, err := h.webSessions.Create(username, userID)
+		if err != nil {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     auth.SessionCookieName,
+			Value:    token,
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, safeRedirect(next), http.StatusSeeOther)
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// Logout handles POST /web/logout - ends the server-side session and clears the cookie.
+func (h *WebHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	if cookie, err := r.Cookie(auth.SessionCookieName); err == nil && h.webSessions != nil {
+		h.webSessions.Delete(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/web/login", http.StatusSeeOther)
+}
+func (h *WebHandler) renderLogin(w http.ResponseWriter, r *http.Request, status int, next, errMsg string) {
+	data := map[string]interface{}{
+		"Title":      "登录",
+		"ActivePage": "login",
+		"Next":       next,
+		"Error":      errMsg,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	h.renderTemplate(w, r, h.loginTemplate, "base", data)
+}
+
+// safeRedirect returns next if it is a local path, so the login form cannot be
+// used to redirect to another site. Anything else goes to the sessions page.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/web/sessions"
+	}
+	return next
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/shared/auth"
+)
+
+func setupLoginTestEnv(t *testing.T) (http.Handler, *auth.WebSessionStore, func()) {
+	handler, cleanup := setupWebTestEnv(t)
+	creds := auth.NewCredentialStore(auth.Credentials{BasicUser: "admin", BasicPass: "secret123"})
+	webSessions := auth.NewWebSessionStore(time.Hour)
+	handler.SetAuth(creds, webSessions)
+	return creds.WebAuthMiddleware(webSessions)(handler), webSessions, cleanup
+}
+
+func postLogin(h http.Handler, user, pass, next string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {user}, "password": {pass}, "next": {next}}
+	req := httptest.NewRequest(http.MethodPost, "/web/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLogin_Flow(t *testing.T) {
+	h, webSessions, cleanup := setupLoginTestEnv(t)
+	defer cleanup()
+
+	// Unauthenticated page loads are redirected to the login form
+	req := httptest.NewRequest(http.MethodGet, "/web/sessions?page=2", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/web/login?next=%2Fweb%2Fsessions%3Fpage%3D2" {
+		t.Fatalf("expected redirect to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr.Header().Get("WWW-Authenticate") != "" {
+		t.Fatal("redirect must not trigger the Basic Auth dialog")
+	}
+
+	// The login form itself is reachable
+	req = httptest.NewRequest(http.MethodGet, "/web/login?next=/web/tags", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "/web/tags") {
+		t.Fatalf("expected login form, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Wrong password
+	rr = postLogin(h, "admin", "wrong", "/web/tags")
+	if rr.Code != http.StatusUnauthorized || len(rr.Result().Cookies()) != 0 {
+		t.Fatalf("expected 401 without cookie, got %d", rr.Code)
+	}
+
+	// Correct credentials set a session cookie and redirect to next
+	rr = postLogin(h, "admin", "secret123", "/web/tags")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/web/tags" {
+		t.Fatalf("expected redirect to next, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected one cookie, got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != auth.SessionCookieName || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("unexpected cookie attributes: %+v", cookie)
+	}
+
+	// The cookie grants access
+	req = httptest.NewRequest(http.MethodGet, "/web/sessions", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 with session cookie, got %d", rr.Code)
+	}
+
+	// Logout clears the cookie and deletes the server-side session
+	req = httptest.NewRequest(http.MethodPost, "/web/logout", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after logout, got %d", rr.Code)
+	}
+	if cleared := rr.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Fatalf("expected cookie to be cleared, got %+v", cleared)
+	}
+	if webSessions.Valid(cookie.Value) {
+		t.Fatal("expected session to be deleted on logout")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/web/sessions", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected old cookie to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestLogin_BasicAuthFallback(t *testing.T) {
+	h, _, cleanup := setupLoginTestEnv(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/web/sessions", nil)
+	req.SetBasicAuth("admin", "secret123")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected Basic Auth to keep working, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/web/sessions", nil)
+	req.SetBasicAuth("admin", "wrong")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong Basic Auth, got %d", rr.Code)
+	}
+}
+
+// partnerLookup knows one user, partner, with ID 2.
+type partnerLookup struct{}
+
+func (partnerLookup) VerifyUser(name, pass string) (int64, bool) {
+	return 2, name == "partner" && pass == "hunter22"
+}
+
+func (partnerLookup) HasUsers() bool { return true }
+
+func TestLogin_UserWithoutConfiguredCredentials(t *testing.T) {
+	handler, cleanup := setupWebTestEnv(t)
+	defer cleanup()
+	creds := auth.NewCredentialStore(auth.Credentials{})
+	creds.SetUserLookup(partnerLookup{})
+	webSessions := auth.NewWebSessionStore(time.Hour)
+	handler.SetAuth(creds, webSessions)
+	h := creds.WebAuthMiddleware(webSessions)(handler)
+
+	// Pages are not open just because TIMELOG_BASIC_USER is unset
+	req := httptest.NewRequest(http.MethodGet, "/web/sessions", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || !strings.HasPrefix(rr.Header().Get("Location"), "/web/login") {
+		t.Fatalf("expected redirect to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = postLogin(h, "partner", "hunter22", "/web/sessions")
+	if rr.Code != http.StatusSeeOther || len(rr.Result().Cookies()) != 1 {
+		t.Fatalf("expected the user to log in, got %d", rr.Code)
+	}
+	if _, userID, ok := webSessions.Lookup(rr.Result().Cookies()[0].Value); !ok || userID != 2 {
+		t.Fatalf("session acts for user %d, want 2", userID)
+	}
+}
+
+func TestLogin_OpenRedirect(t *testing.T) {
+	h, _, cleanup := setupLoginTestEnv(t)
+	defer cleanup()
+
+	for _, next := range []string{"https://evil.example", "//evil.example", "/\\evil.example", ""} {
+		rr := postLogin(h, "admin", "secret123", next)
+		if loc := rr.Header().Get("Location"); loc != "/web/sessions" {
+			t.Errorf("next=%q: expected redirect to /web/sessions, got %q", next, loc)
+		}
+	}
+}
@@ -1,35 +1,76 @@
 package web
 
 import (
-	"encoding/json"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 
+	"time-tracker/internal/audit"
+	"time-tracker/internal/handler"
+	"time-tracker/internal/presets"
 	"time-tracker/internal/sessions"
+	"time-tracker/internal/sessions/models"
 
+	"time-tracker/internal/shared/errors"
 	"time-tracker/internal/shared/utils"
 	"time-tracker/internal/shared/validation"
+	"time-tracker/internal/tags"
 )
 
+// pageSizes are the page sizes offered on the sessions page; the first is
+// the default.
+var pageSizes = []int{10, 25, 50, 100}
+
 // Sessions handles GET /web/sessions - displays the sessions list page.
 func (h *WebHandler) Sessions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, http.MethodGet)
 		return
 	}
 
-	query := r.URL.Query()
+	data, err := h.sessionsData(r)
+	if err != nil {
+		http.Error(w, "Failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+	data["Flash"] = takeFlash(w, r)
 
-	// Parse pagination
-	page := 1
-	if p := query.Get("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
-			page = parsed
-		}
+	h.renderTemplate(w, r, h.sessionsTemplate, "base", data)
+}
+
+// SessionsPartial handles GET /web/sessions/partials/{table,running} -
+// renders one fragment of the sessions page, without the page around it,
+// for the page to swap in after a change. The table takes the same query
+// parameters as the page.
+func (h *WebHandler) SessionsPartial(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
 	}
 
-	limit := 10
-	offset := (page - 1) * limit
+	data, err := h.sessionsData(r)
+	if err != nil {
+		http.Error(w, "Failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	h.renderTemplate(w, r, h.sessionsTemplate, name, data)
+}
+
+// sessionsData builds the template data of the sessions page from the
+// filters and pagination in r's query.
+func (h *WebHandler) sessionsData(r *http.Request) (map[string]interface{}, error) {
+	query := r.URL.Query()
+
+	// Parse pagination; a page size outside the offered ones gets the default
+	page := validation.ParseIntParam(query.Get("page"), 1, 1, math.MaxInt32)
+	limit := validation.ParseIntParam(query.Get("per_page"), pageSizes[0], pageSizes[0], pageSizes[len(pageSizes)-1])
+	if !slices.Contains(pageSizes, limit) {
+		limit = pageSizes[0]
+	}
 
 	// Parse and sanitize filters
 	var category *string
@@ -44,13 +85,45 @@ func (h *WebHandler) Sessions(w http.ResponseWriter, r *http.Request) {
 		status = &statusStr
 	}
 
+	// Dates are whole days in the display timezone; invalid ones are ignored
+	filter := &models.SessionFilter{Status: status, Category: category}
+	fromStr := query.Get("from")
+	if from, err := validation.ParseTimeBound(fromStr, h.timezone, false); err == nil {
+		filter.From = &from
+	} else {
+		fromStr = ""
+	}
+	toStr := query.Get("to")
+	if to, err := validation.ParseTimeBound(toStr, h.timezone, true); err == nil {
+		filter.To = &to
+	} else {
+		toStr = ""
+	}
+	var tagID int64
+	tagStr := query.Get("tag_id")
+	if parsed, err := strconv.ParseInt(tagStr, 10, 64); err == nil && parsed > 0 {
+		tagID = parsed
+		filter.TagID = &tagID
+	} else {
+		tagStr = ""
+	}
+
 	// Get sessions from service
-	result, err := h.sessionService.GetSessions(limit, offset, status, category)
+	result, err := h.sessionService.GetSessionsContext(r.Context(), limit, (page-1)*limit, filter)
 	if err != nil {
-		http.Error(w, "Failed to fetch sessions", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
+	// A page past the end, say from a stale link after deletions, shows the last one
+	totalPages := max(result.TotalPages, 1)
+	if result.Page > totalPages {
+		result, err = h.sessionService.GetSessionsContext(r.Context(), limit, (totalPages-1)*limit, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	page = result.Page
+
 	// Convert to view data
 	sessions := make([]SessionViewData, len(result.Items))
 	for i, session := range result.Items {
@@ -70,15 +143,16 @@ func (h *WebHandler) Sessions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Calculate pagination
-	totalPages := int((result.Total + int64(limit) - 1) / int64(limit))
-	if totalPages < 1 {
-		totalPages = 1
+	// The range of items shown, counted from 1
+	showingFrom, showingTo := 0, 0
+	if len(sessions) > 0 {
+		showingFrom = result.Offset + 1
+		showingTo = result.Offset + len(sessions)
 	}
 
 	// Get current running session
 	var runningSessionView *SessionViewData
-	currentResp, err := h.sessionService.GetCurrent()
+	currentResp, err := h.sessionService.GetCurrentContext(r.Context())
 	if err == nil && currentResp.Running && currentResp.Session != nil {
 		running := currentResp.Session
 		runningSessionView = &SessionViewData{
@@ -94,27 +168,87 @@ func (h *WebHandler) Sessions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data := map[string]interface{}{
+	// Tags for the selector, the running session's tags and the categories
+	// suggested by the start form; a failure only hides them
+	tagOptions, _ := h.tagService.ListContext(r.Context(), tags.TagFilter{})
+	var runningTags []tags.Tag
+	if runningSessionView != nil {
+		runningTags, _ = h.tagService.ListForSessionContext(r.Context(), runningSessionView.ID)
+	}
+	categories, _ := h.sessionService.GetCategoriesContext(r.Context())
+	var presetButtons []presets.Preset
+	if h.presetService != nil {
+		presetButtons, _ = h.presetService.ListContext(r.Context())
+	}
+
+	// Links keep the active filters. The CSV export reads bare dates as
+	// UTC, so it gets the bounds resolved in the display timezone instead.
+	filterQuery, exportQuery := url.Values{}, url.Values{}
+	for _, param := range []struct{ key, value, export string }{
+		{"category", categoryStr, categoryStr},
+		{"status", statusStr, statusStr},
+		{"from", fromStr, utils.PtrToString(filter.From)},
+		{"to", toStr, utils.PtrToString(filter.To)},
+		{"tag_id", tagStr, tagStr},
+	} {
+		if param.value != "" {
+			filterQuery.Set(param.key, param.value)
+			exportQuery.Set(param.key, param.export)
+		}
+	}
+	if limit != pageSizes[0] {
+		filterQuery.Set("per_page", strconv.Itoa(limit))
+	}
+	pageURL := func(n int) string {
+		filterQuery.Set("page", strconv.Itoa(n))
+		defer filterQuery.Del("page")
+		return "/web/sessions?" + filterQuery.Encode()
+	}
+
+	return map[string]interface{}{
 		"Title":          "计时",
 		"ActivePage":     "sessions",
 		"Sessions":       sessions,
 		"Category":       categoryStr,
 		"Status":         statusStr,
+		"From":           fromStr,
+		"To":             toStr,
+		"TagID":          tagID,
+		"Tags":           tagOptions,
 		"CurrentPage":    page,
 		"TotalPages":     totalPages,
+		"HasMore":        result.HasMore,
+		"Total":          result.Total,
+		"PerPage":        limit,
+		"PageSizes":      pageSizes,
+		"ShowingFrom":    showingFrom,
+		"ShowingTo":      showingTo,
 		"PrevPage":       page - 1,
 		"NextPage":       page + 1,
+		"PrevPageURL":    pageURL(page - 1),
+		"NextPageURL":    pageURL(page + 1),
+		"ExportURL":      "/sessions.csv?" + exportQuery.Encode(),
 		"RunningSession": runningSessionView,
-		"APIKey":         h.apiKey,
-	}
+		"RunningTags":    runningTags,
+		"Categories":     categories,
+		"Presets":        presetButtons,
+	}, nil
+}
 
-	h.renderTemplate(w, r, h.sessionsTemplate, "base", data)
+// Stream handles GET /web/sessions/stream - streams the current session as
+// Server-Sent Events so the page can keep its elapsed time current.
+func (h *WebHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	handler.StreamSession(w, r, h.sessionService, h.streamHeartbeat, h.logger)
 }
 
 // WebStartSession handles POST /web/sessions/actions/start - starts a new session via web interface.
 func (h *WebHandler) WebStartSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.writeActionError(w, r, "/web/sessions", methodNotAllowed())
 		return
 	}
 
@@ -123,8 +257,16 @@ func (h *WebHandler) WebStartSession(w http.ResponseWriter, r *http.Request) {
 		Task     string  `json:"task"`
 		Note     *string `json:"note"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+	err := h.decodeAction(w, r, &input, func(form url.Values) error {
+		input.Category = form.Get("category")
+		input.Task = form.Get("task")
+		if note := form.Get("note"); note != "" {
+			input.Note = &note
+		}
+		return nil
+	})
+	if err != nil {
+		h.writeActionError(w, r, "/web/sessions", err)
 		return
 	}
 
@@ -134,69 +276,114 @@ func (h *WebHandler) WebStartSession(w http.ResponseWriter, r *http.Request) {
 		Note:     input.Note,
 	}
 
-	_, err := h.sessionService.StartSession(&startInput)
+	if _, err := h.sessionService.StartSessionContext(r.Context(), &startInput); err != nil {
+		h.writeActionError(w, r, "/web/sessions", sessionActionError(err))
+		return
+	}
+
+	h.writeActionOK(w, r, "/web/sessions", "已开始计时")
+}
+
+// WebStartPreset handles POST /web/sessions/actions/start-preset - starts a
+// session from a quick-start preset.
+func (h *WebHandler) WebStartPreset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeActionError(w, r, "/web/sessions", methodNotAllowed())
+		return
+	}
+
+	var input struct {
+		ID int64 `json:"id"`
+	}
+	err := h.decodeAction(w, r, &input, func(form url.Values) error {
+		id, err := strconv.ParseInt(form.Get("id"), 10, 64)
+		if err != nil {
+			return errors.ValidationError("id must be an integer")
+		}
+		input.ID = id
+		return nil
+	})
 	if err != nil {
-		if err == sessions.ErrSessionAlreadyRunning {
-			http.Error(w, "Session already running", http.StatusConflict)
-			return
+		h.writeActionError(w, r, "/web/sessions", err)
+		return
+	}
+	if h.presetService == nil {
+		h.writeActionError(w, r, "/web/sessions", errors.NotFoundError("Preset not found"))
+		return
+	}
+
+	if _, err := h.presetService.StartContext(r.Context(), input.ID); err != nil {
+		if err == presets.ErrPresetNotFound {
+			err = errors.NotFoundError("Preset not found")
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.writeActionError(w, r, "/web/sessions", sessionActionError(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.writeActionOK(w, r, "/web/sessions", "已开始计时")
 }
 
 // WebStopSession handles POST /web/sessions/actions/stop - stops the current session via web interface.
 func (h *WebHandler) WebStopSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.writeActionError(w, r, "/web/sessions", methodNotAllowed())
 		return
 	}
 
-	// Body is empty for stop from web
-	stopInput := &sessions.SessionStop{}
-
-	_, err := h.sessionService.StopSession(stopInput)
-	if err != nil {
-		if err == sessions.ErrNoRunningSession {
-			http.Error(w, "No running session found", http.StatusNotFound)
+	// Body is empty for stop from web, but a form post is still checked
+	if isFormPost(r) {
+		if err := h.decodeAction(w, r, nil, func(url.Values) error { return nil }); err != nil {
+			h.writeActionError(w, r, "/web/sessions", err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	stopInput := &sessions.SessionStop{}
+
+	if _, err := h.sessionService.StopSessionContext(r.Context(), stopInput); err != nil {
+		h.writeActionError(w, r, "/web/sessions", sessionActionError(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.writeActionOK(w, r, "/web/sessions", "已结束计时")
 }
 
 // WebDeleteSession handles POST /web/sessions/actions/delete - deletes a session.
 func (h *WebHandler) WebDeleteSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.writeActionError(w, r, "/web/sessions", methodNotAllowed())
 		return
 	}
 
 	var input struct {
 		ID int64 `json:"id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+	err := h.decodeAction(w, r, &input, func(form url.Values) error {
+		id, err := strconv.ParseInt(form.Get("id"), 10, 64)
+		if err != nil {
+			return errors.ValidationError("id must be an integer")
+		}
+		input.ID = id
+		return nil
+	})
+	if err != nil {
+		h.writeActionError(w, r, "/web/sessions", err)
 		return
 	}
 
-	if err := h.sessionService.DeleteSession(input.ID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.sessionService.DeleteSessionContext(r.Context(), input.ID); err != nil {
+		h.writeActionError(w, r, "/web/sessions", sessionActionError(err))
 		return
 	}
+	h.audit.Record(r, audit.EventSessionDeleted, map[string]interface{}{"session_id": input.ID})
 
-	w.WriteHeader(http.StatusOK)
+	h.writeActionOK(w, r, "/web/sessions", "记录已删除")
 }
 
-// WebUpdateSession handles POST /web/sessions/actions/update - updates a session.
+// WebUpdateSession handles POST /web/sessions/actions/update - updates a
+// session. It takes JSON only, as the edit dialog needs JavaScript anyway.
 func (h *WebHandler) WebUpdateSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.writeActionError(w, r, "/web/sessions", methodNotAllowed())
 		return
 	}
 
@@ -204,15 +391,15 @@ func (h *WebHandler) WebUpdateSession(w http.ResponseWriter, r *http.Request) {
 		ID int64 `json:"id"`
 		sessions.SessionUpdate
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+	if err := h.decodeAction(w, r, &input, nil); err != nil {
+		h.writeActionError(w, r, "/web/sessions", err)
 		return
 	}
 
-	if err := h.sessionService.UpdateSession(input.ID, &input.SessionUpdate); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.sessionService.UpdateSessionContext(r.Context(), input.ID, &input.SessionUpdate); err != nil {
+		h.writeActionError(w, r, "/web/sessions", sessionActionError(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.writeActionOK(w, r, "/web/sessions", "记录已更新")
 }
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// assetHashLen is how many hex digits of a file's SHA-256 go into its
+// fingerprinted name.
+const assetHashLen = 12
+
+// staticAsset is one file of static/, read once at startup.
+type staticAsset struct {
+	data    []byte
+	modTime time.Time
+	etag    string
+	hashed  string // name with the content hash before the extension
+}
+
+// staticAssets serves the files of static/ under /static/. A file is
+// served under its own name with an ETag, and Last-Modified where the
+// file system has modification times, so browsers revalidate it; and
+// under its fingerprinted name, which changes with its content, as
+// immutable. Directories are not listed.
+type staticAssets struct {
+	files  map[string]*staticAsset // by name, e.g. "js/main.js"
+	byHash map[string]*staticAsset // by fingerprinted name, e.g. "js/main.0123456789ab.js"
+}
+
+// newStaticAssets reads every file of fsys and fingerprints it.
+func newStaticAssets(fsys fs.FS) (*staticAssets, error) {
+	assets := &staticAssets{
+		files:  make(map[string]*staticAsset),
+		byHash: make(map[string]*staticAsset),
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])[:assetHashLen]
+		ext := path.Ext(name)
+		asset := &staticAsset{
+			data:    data,
+			modTime: info.ModTime(),
+			etag:    `"` + hash + `"`,
+			hashed:  strings.TrimSuffix(name, ext) + "." + hash + ext,
+		}
+		assets.files[name] = asset
+		assets.byHash[asset.hashed] = asset
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static assets: %w", err)
+	}
+	return assets, nil
+}
+
+// assetPath returns the URL of the static file name, fingerprinted when the
+// file exists. It is the assetPath function of the templates.
+func (a *staticAssets) assetPath(name string) string {
+	if asset, ok := a.files[name]; ok {
+		return "/static/" + asset.hashed
+	}
+	return "/static/" + name
+}
+
+// ServeHTTP serves the file at r.URL.Path below /static/.
+func (a *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+
+	if asset, ok := a.byHash[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		a.serve(w, r, name, asset)
+		return
+	}
+	if asset, ok := a.files[name]; ok {
+		// Cached copies are checked against the ETag before each use
+		w.Header().Set("Cache-Control", "no-cache")
+		a.serve(w, r, name, asset)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// file returns a handler serving the file name, revalidated like a file
+// requested by its plain name. A missing file is not found.
+func (a *staticAssets) file(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asset, ok := a.files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		a.serve(w, r, name, asset)
+	})
+}
+
+// serve writes asset, or 304 Not Modified when the request's validators
+// match it.
+func (a *staticAssets) serve(w http.ResponseWriter, r *http.Request, name string, asset *staticAsset) {
+	w.Header().Set("ETag", asset.etag)
+	http.ServeContent(w, r, name, asset.modTime, bytes.NewReader(asset.data))
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"time-tracker/internal/shared/errors"
+	"time-tracker/internal/tags"
+)
+
+// Tags handles GET /web/tags - displays all tags including archived ones.
+func (h *WebHandler) Tags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	items, err := h.tagService.ListContext(r.Context(), tags.TagFilter{IncludeArchived: true})
+	if err != nil {
+		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":      "标签",
+		"ActivePage": "tags",
+		"Tags":       items,
+		"Flash":      takeFlash(w, r),
+	}
+
+	h.renderTemplate(w, r, h.tagsTemplate, "base", data)
+}
+
+// WebArchiveTag handles POST /web/tags/actions/archive - archives or restores a tag.
+func (h *WebHandler) WebArchiveTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeActionError(w, r, "/web/tags", methodNotAllowed())
+		return
+	}
+
+	var input struct {
+		ID       int64 `json:"id"`
+		Archived bool  `json:"archived"`
+	}
+	err := h.decodeAction(w, r, &input, func(form url.Values) error {
+		id, err := strconv.ParseInt(form.Get("id"), 10, 64)
+		if err != nil {
+			return errors.ValidationError("id must be an integer")
+		}
+		archived, err := strconv.ParseBool(form.Get("archived"))
+		if err != nil {
+			return errors.ValidationError("archived must be true or false")
+		}
+		input.ID, input.Archived = id, archived
+		return nil
+	})
+	if err != nil {
+		h.writeActionError(w, r, "/web/tags", err)
+		return
+	}
+
+	if _, err := h.tagService.UpdateContext(r.Context(), input.ID, &tags.TagUpdate{Archived: &input.Archived}); err != nil {
+		if err == tags.ErrTagNotFound {
+			err = errors.NotFoundError("Tag not found")
+		}
+		h.writeActionError(w, r, "/web/tags", err)
+		return
+	}
+
+	message := "标签已恢复"
+	if input.Archived {
+		message = "标签已归档"
+	}
+	h.writeActionOK(w, r, "/web/tags", message)
+}
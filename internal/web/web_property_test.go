@@ -4,8 +4,8 @@ import (
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"pgregory.net/rapid"
@@ -13,51 +13,47 @@ import (
 	"time-tracker/internal/sessions"
 	"time-tracker/internal/shared/auth"
 	"time-tracker/internal/shared/database"
+	"time-tracker/internal/tags"
 )
 
 // setupWebTestEnv creates a test environment with in-memory database.
 func setupWebTestEnv(t *testing.T) (*WebHandler, func()) {
-	// Create temp database
-	tmpFile, err := os.CreateTemp("", "web_test_*.db")
+	// Create in-memory database
+	db, err := database.New(":memory:")
 	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-	db, err := database.New(tmpFile.Name())
-	if err != nil {
-		os.Remove(tmpFile.Name())
 		t.Fatalf("failed to create database: %v", err)
 	}
+	handler := newTestWebHandler(t, db)
+	cleanup := func() {
+		db.Close()
+	}
+	return handler, cleanup
+}
+
+// newTestWebHandler creates a WebHandler on db with minimal test templates.
+// The sessions page lists tasks and the pagination and export links.
+func newTestWebHandler(t *testing.T, db *database.DB) *WebHandler {
+	t.Helper()
 	sessionRepo := sessions.NewSessionRepository(db)
 	sessionSvc := sessions.NewSessionService(sessionRepo)
-	// Create templates directory for testing
-	tmpDir, err := os.MkdirTemp("", "templates_test")
-	if err != nil {
-		db.Close()
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to create temp dir: %v", err)
+	tagSvc := tags.NewTagService(tags.NewTagRepository(db))
+	templatesFS := fstest.MapFS{
+		"base.html":     {Data: []byte(`{{define "base"}}<!DOCTYPE html><html><body>{{with .Flash}}<p class="flash-{{.Kind}}">{{.Message}}</p>{{end}}{{block "content" .}}{{end}}</body></html>{{end}}`)},
+		"sessions.html": {Data: []byte(`{{template "base" .}}{{define "content"}}<div>Sessions: {{len .Sessions}}</div>{{range .Sessions}}<li>{{.Task}}</li>{{end}}<p id="range">{{.ShowingFrom}}-{{.ShowingTo}} of {{.Total}}, page {{.CurrentPage}} of {{.TotalPages}}</p><a id="prev" href="{{.PrevPageURL}}"></a><a id="next" href="{{.NextPageURL}}"></a><a id="export" href="{{.ExportURL}}"></a>{{end}}`)},
+		"tags.html":     {Data: []byte(`{{template "base" .}}{{define "content"}}{{range .Tags}}<div>{{.Name}}{{if .Archived}} archived{{end}}</div>{{end}}{{end}}`)},
+		"login.html":    {Data: []byte(`{{template "base" .}}{{define "content"}}<form>{{.Error}}<input name="next" value="{{.Next}}"></form>{{end}}`)},
+		"edit.html":     {Data: []byte(`{{template "base" .}}{{define "content"}}<form>{{.Form.Task}}</form>{{end}}`)},
+		"notfound.html": {Data: []byte(`{{template "base" .}}{{define "content"}}<p>not found: {{.Path}}</p>{{end}}`)},
+		"docs.html":     {Data: []byte(`{{template "base" .}}{{define "content"}}<div id="api-docs"></div>{{end}}`)},
+		"static/app.js": {Data: []byte(`console.log("test")`)},
 	}
-	// Create minimal test templates
-	baseHTML := `{{define "base"}}<!DOCTYPE html><html><body>{{block "content" .}}{{end}}</body></html>{{end}}`
-	sessionsHTML := `{{template "base" .}}{{define "content"}}<div>Sessions: {{len .Sessions}}</div>{{end}}`
-	os.WriteFile(tmpDir+"/base.html", []byte(baseHTML), 0644)
-	os.WriteFile(tmpDir+"/sessions.html", []byte(sessionsHTML), 0644)
 
 	tz, _ := time.LoadLocation("Asia/Shanghai")
-	apiKey := "test-api-key-32-characters-long"
-	handler, err := NewWebHandler(sessionSvc, tmpDir, tz, apiKey)
+	handler, err := NewWebHandler(sessionSvc, tagSvc, templatesFS, tz)
 	if err != nil {
-		db.Close()
-		os.Remove(tmpFile.Name())
-		os.RemoveAll(tmpDir)
 		t.Fatalf("failed to create web handler: %v", err)
 	}
-	cleanup := func() {
-		db.Close()
-		os.Remove(tmpFile.Name())
-		os.RemoveAll(tmpDir)
-	}
-	return handler, cleanup
+	return handler
 }
 // Feature: time-tracker, Property 15: Web Basic Auth 正确性
 // *For any* 访问 /web/* 或 /*.csv 端点的请求（当配置了 Basic Auth 时）：
@@ -80,7 +76,7 @@ func TestWebBasicAuth_Property15_MissingAuth(t *testing.T) {
 		pathIdx := rapid.IntRange(0, len(paths)-1).Draw(rt, "pathIdx")
 		path := paths[pathIdx]
 		// Request without Authorization header
-		req := httptest.NewRequest("GET", path, nil)
+		req := newRequest("GET", path, nil)
 		rr := httptest.NewRecorder()
 		protectedHandler.ServeHTTP(rr, req)
 		// Should return 401 Unauthorized
@@ -113,7 +109,7 @@ func TestWebBasicAuth_Property15_InvalidAuth(t *testing.T) {
 		path := paths[pathIdx]
 		// Create invalid Basic Auth header
 		authHeader := "Basic " + base64.StdEncoding.EncodeToString([]byte(providedUser+":"+providedPass))
-		req := httptest.NewRequest("GET", path, nil)
+		req := newRequest("GET", path, nil)
 		req.Header.Set("Authorization", authHeader)
 		rr := httptest.NewRecorder()
 		protectedHandler.ServeHTTP(rr, req)
@@ -137,7 +133,7 @@ func TestWebBasicAuth_Property15_ValidAuth(t *testing.T) {
 		path := paths[pathIdx]
 		// Create valid Basic Auth header
 		authHeader := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
-		req := httptest.NewRequest("GET", path, nil)
+		req := newRequest("GET", path, nil)
 		req.Header.Set("Authorization", authHeader)
 		rr := httptest.NewRecorder()
 		protectedHandler.ServeHTTP(rr, req)
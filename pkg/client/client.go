@@ -0,0 +1,108 @@
+// Package client is a Go client for the time tracker API.
+//
+//	c := client.New("http://localhost:7070", apiKey)
+//	session, err := c.StartSession(ctx, client.StartRequest{Category: "work", Task: "review"})
+//	var conflict *client.ConflictError
+//	if errors.As(err, &conflict) {
+//		fmt.Println("already running:", conflict.CurrentSession.Task)
+//	}
+//
+// Every method takes a context for cancellation. Error responses become
+// an *APIError, or a *ConflictError or *RateLimitError, which errors.Is
+// matches against ErrNotFound, ErrConflict and the other sentinels.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// userAgent identifies the client in the server's request log.
+const userAgent = "time-tracker-client"
+
+// Client calls the API of one time tracker server. It is safe for
+// concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// New creates a Client for the server at baseURL, such as
+// "http://localhost:7070", authenticating with apiKey.
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// SetHTTPClient overrides the HTTP client, e.g. for timeouts or a custom
+// transport.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	if hc != nil {
+		c.httpClient = hc
+	}
+}
+
+// send performs a request to path with query and, unless body is nil, body
+// encoded as JSON. Error responses are returned as errors; the caller
+// closes the body of a successful response.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	return resp, nil
+}
+
+// do is like send, decoding the response into out unless out is nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
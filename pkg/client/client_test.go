@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"time-tracker/internal/app"
+	"time-tracker/internal/shared/middleware"
+)
+
+const testAPIKey = "test-api-key-32-chars-minimum!!!"
+
+// newTestClient serves the real application and returns a client for it,
+// so the client cannot drift from the server.
+func newTestClient(t *testing.T, configure func(*app.Config)) *Client {
+	t.Helper()
+	cfg := &app.Config{
+		APIKey:    testAPIKey,
+		APIKeys:   []string{testAPIKey},
+		DBPath:    filepath.Join(t.TempDir(), "client_test.db"),
+		Timezone:  "UTC",
+		RateLimit: 100,
+		Port:      "0",
+
+		RateLimitExemptPaths: middleware.DefaultRateLimitExemptPaths,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	a, err := app.New(cfg, middleware.NewLogger(io.Discard, "text", slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		srv.Close()
+		a.Shutdown()
+	})
+	return New(srv.URL+"/", testAPIKey)
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestClient_SessionLifecycle(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	current, err := c.Current(ctx)
+	if err != nil || current.Running {
+		t.Fatalf("Current before start = %+v, %v", current, err)
+	}
+
+	started, err := c.StartSession(ctx, StartRequest{Category: "work", Task: "write the client", Mood: strPtr("focused")})
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if started.ID == 0 || started.Status != "running" || started.StartedAt.IsZero() || started.EndedAt != nil {
+		t.Errorf("started session = %+v", started)
+	}
+
+	current, err = c.Current(ctx)
+	if err != nil || !current.Running || current.Session == nil || current.Session.ID != started.ID || current.ElapsedSec == nil {
+		t.Fatalf("Current while running = %+v, %v", current, err)
+	}
+
+	_, err = c.StartSession(ctx, StartRequest{Category: "work", Task: "another"})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("second start: got %v, want a ConflictError", err)
+	}
+	if conflict.StatusCode != 409 || conflict.CurrentSession == nil || conflict.CurrentSession.ID != started.ID || conflict.CurrentSession.Task != "write the client" {
+		t.Errorf("conflict = %+v, current session %+v", conflict.APIError, conflict.CurrentSession)
+	}
+
+	stopped, err := c.StopSession(ctx, &StopRequest{Note: strPtr("done")})
+	if err != nil {
+		t.Fatalf("StopSession: %v", err)
+	}
+	if stopped.Status != "stopped" || stopped.EndedAt == nil || stopped.Note == nil || *stopped.Note != "done" {
+		t.Errorf("stopped session = %+v", stopped)
+	}
+
+	_, err = c.StopSession(ctx, nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("stop without a running session: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestClient_ListAndIterate(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	for _, category := range []string{"work", "study", "work", "work", "study"} {
+		if _, err := c.StartSession(ctx, StartRequest{Category: category, Task: "task"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.StopSession(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := c.ListSessions(ctx, ListOptions{Filter: Filter{Category: "work"}, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Limit != 2 {
+		t.Errorf("page = total %d, %d items, limit %d; want 3, 2, 2", page.Total, len(page.Items), page.Limit)
+	}
+
+	it := c.Sessions(ctx, ListOptions{Filter: Filter{Status: "stopped"}, Limit: 2})
+	seen := map[int64]bool{}
+	for it.Next() {
+		seen[it.Session().ID] = true
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration: %v", err)
+	}
+	if len(seen) != 5 {
+		t.Errorf("iterated over %d sessions, want 5", len(seen))
+	}
+
+	it = c.Sessions(ctx, ListOptions{Filter: Filter{From: "not a date"}})
+	if it.Next() || !errors.Is(it.Err(), ErrValidation) {
+		t.Errorf("bad filter: got %v, want ErrValidation", it.Err())
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportCSV(ctx, &buf, Filter{Category: "study"}); err != nil {
+		t.Fatalf("ExportCSV: %v", err)
+	}
+	if lines := strings.Count(strings.TrimSpace(buf.String()), "\n"); lines != 2 {
+		t.Errorf("export has %d data rows, want 2:\n%s", lines, buf.String())
+	}
+}
+
+func TestClient_Tags(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	parent, err := c.CreateTag(ctx, TagCreate{Name: "clients", Color: "#112233"})
+	if err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	child, err := c.CreateTag(ctx, TagCreate{Name: "acme", Color: "#445566", ParentID: &parent.ID})
+	if err != nil {
+		t.Fatalf("CreateTag child: %v", err)
+	}
+
+	got, err := c.GetTag(ctx, child.ID)
+	if err != nil || got.Name != "acme" || got.ParentID == nil || *got.ParentID != parent.ID {
+		t.Fatalf("GetTag = %+v, %v", got, err)
+	}
+
+	archived := true
+	if _, err := c.UpdateTag(ctx, child.ID, TagUpdate{Name: strPtr("acme corp"), Archived: &archived}); err != nil {
+		t.Fatalf("UpdateTag: %v", err)
+	}
+	tags, err := c.ListTags(ctx, TagListOptions{})
+	if err != nil || len(tags) != 1 || tags[0].ID != parent.ID {
+		t.Errorf("ListTags without archived = %+v, %v", tags, err)
+	}
+	tags, err = c.ListTags(ctx, TagListOptions{ParentID: &parent.ID, IncludeArchived: true})
+	if err != nil || len(tags) != 1 || tags[0].Name != "acme corp" || !tags[0].Archived {
+		t.Errorf("ListTags children = %+v, %v", tags, err)
+	}
+
+	session, err := c.StartSession(ctx, StartRequest{Category: "work", Task: "tagged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AssignTags(ctx, session.ID, []int64{parent.ID}); err != nil {
+		t.Fatalf("AssignTags: %v", err)
+	}
+	tags, err = c.SessionTags(ctx, session.ID)
+	if err != nil || len(tags) != 1 || tags[0].ID != parent.ID {
+		t.Errorf("SessionTags = %+v, %v", tags, err)
+	}
+	if err := c.AssignTags(ctx, session.ID, []int64{child.ID}); !errors.Is(err, ErrValidation) {
+		t.Errorf("assigning an archived tag: got %v, want ErrValidation", err)
+	}
+
+	if err := c.DeleteTag(ctx, parent.ID, false); !errors.Is(err, ErrConflict) {
+		t.Errorf("deleting a parent: got %v, want ErrConflict", err)
+	}
+	if err := c.DeleteTag(ctx, parent.ID, true); err != nil {
+		t.Errorf("DeleteTag: %v", err)
+	}
+	if _, err := c.GetTag(ctx, parent.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted tag: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	c := newTestClient(t, func(cfg *app.Config) { cfg.RateLimit = 2 })
+	ctx := context.Background()
+
+	bad := New(c.baseURL, "wrong-key-that-is-long-enough-000000")
+	if _, err := bad.Current(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong key: got %v, want ErrUnauthorized", err)
+	}
+
+	_, err := c.CreateTag(ctx, TagCreate{Name: " "})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "VALIDATION_ERROR" || apiErr.StatusCode != 400 || apiErr.Message == "" {
+		t.Errorf("invalid tag: got %#v", err)
+	}
+
+	_, err = c.Current(ctx)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("third request: got %v, want a RateLimitError", err)
+	}
+	if limited.RetryAfter < time.Second {
+		t.Errorf("RetryAfter = %v, want at least a second", limited.RetryAfter)
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sentinels for errors.Is, matched by the error code of the response.
+var (
+	ErrValidation   = errors.New("validation error")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// sentinels maps the API's error codes to their sentinels.
+var sentinels = map[string]error{
+	"VALIDATION_ERROR": ErrValidation,
+	"UNAUTHORIZED":     ErrUnauthorized,
+	"NOT_FOUND":        ErrNotFound,
+	"CONFLICT":         ErrConflict,
+	"RATE_LIMITED":     ErrRateLimited,
+}
+
+// APIError is an error response from the API.
+type APIError struct {
+	StatusCode int
+	Code       string // such as "NOT_FOUND"; empty if the body was not the error envelope
+	Message    string
+	// Fields maps each input field that failed validation to its message;
+	// only set for validation errors that name their fields
+	Fields map[string]string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("time tracker: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("time tracker: %s: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is the sentinel for e's code.
+func (e *APIError) Is(target error) bool {
+	sentinel, ok := sentinels[e.Code]
+	return ok && sentinel == target
+}
+
+// ConflictError is returned when starting a session while another runs,
+// among other conflicts. It matches ErrConflict.
+type ConflictError struct {
+	*APIError
+
+	// CurrentSession is the running session, with ElapsedSec set; nil for
+	// conflicts that do not involve it
+	CurrentSession *Session
+}
+
+// Unwrap returns the underlying APIError.
+func (e *ConflictError) Unwrap() error { return e.APIError }
+
+// RateLimitError is returned once the client has used up its quota. It
+// matches ErrRateLimited.
+type RateLimitError struct {
+	*APIError
+
+	// RetryAfter is how long to wait before the next request is allowed
+	RetryAfter time.Duration
+}
+
+// Unwrap returns the underlying APIError.
+func (e *RateLimitError) Unwrap() error { return e.APIError }
+
+// errorEnvelope is the JSON body of an error response.
+type errorEnvelope struct {
+	Error struct {
+		Code           string            `json:"code"`
+		Message        string            `json:"message"`
+		Fields         map[string]string `json:"fields"`
+		CurrentSession *Session          `json:"current_session"`
+	} `json:"error"`
+}
+
+// parseError turns an error response into an error.
+func parseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var envelope errorEnvelope
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Code != "" {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Fields = envelope.Error.Fields
+	}
+
+	switch resp.StatusCode {
+	case http.StatusConflict:
+		return &ConflictError{APIError: apiErr, CurrentSession: envelope.Error.CurrentSession}
+	case http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &RateLimitError{APIError: apiErr, RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	return apiErr
+}
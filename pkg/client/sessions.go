@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Session is a tracked session. EndedAt, DurationSec and DurationMs are nil
+// while it runs.
+type Session struct {
+	ID          int64      `json:"id"`
+	Category    string     `json:"category"`
+	Task        string     `json:"task"`
+	Note        *string    `json:"note,omitempty"`
+	Location    *string    `json:"location,omitempty"`
+	Mood        *string    `json:"mood,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	DurationSec *int64     `json:"duration_sec,omitempty"`
+	DurationMs  *int64     `json:"duration_ms,omitempty"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Tags        []string   `json:"tags,omitempty"`
+	// ElapsedSec is set only on ConflictError.CurrentSession
+	ElapsedSec *int64 `json:"elapsed_sec,omitempty"`
+}
+
+// StartRequest describes a session to start. An empty category or task
+// gets the server's default.
+type StartRequest struct {
+	Category string  `json:"category"`
+	Task     string  `json:"task"`
+	Note     *string `json:"note,omitempty"`
+	Location *string `json:"location,omitempty"`
+	Mood     *string `json:"mood,omitempty"`
+}
+
+// StopRequest sets details of a session as it is stopped.
+type StopRequest struct {
+	Note     *string `json:"note,omitempty"`
+	Location *string `json:"location,omitempty"`
+	Mood     *string `json:"mood,omitempty"`
+}
+
+// Current is the current session status.
+type Current struct {
+	Running    bool     `json:"running"`
+	Session    *Session `json:"session,omitempty"`
+	ElapsedSec *int64   `json:"elapsed_sec,omitempty"`
+	// RemindersFired lists the reminder rules fired for the running session
+	RemindersFired []int64 `json:"reminders_fired,omitempty"`
+}
+
+// Filter selects sessions to list or export. Empty fields do not filter.
+// From and To take RFC3339 timestamps or YYYY-MM-DD dates; Range takes a
+// relative range such as "today" or "this_month" instead.
+type Filter struct {
+	Status   string // "running" or "stopped"
+	Category string
+	From     string
+	To       string
+	Range    string
+	TagID    int64
+	Sort     string // "started_at" (the default) or "updated_at"
+}
+
+// values returns the filter as query parameters.
+func (f Filter) values() url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"status":   f.Status,
+		"category": f.Category,
+		"from":     f.From,
+		"to":       f.To,
+		"range":    f.Range,
+		"sort":     f.Sort,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if f.TagID != 0 {
+		query.Set("tag_id", strconv.FormatInt(f.TagID, 10))
+	}
+	return query
+}
+
+// ListOptions selects a page of sessions. A zero Limit uses the server's
+// default page size.
+type ListOptions struct {
+	Filter
+	Limit  int
+	Offset int
+}
+
+// SessionPage is one page of sessions, newest first, and the number of
+// sessions matching the filter. Page counts from 1; HasMore is set while
+// sessions remain after this page.
+type SessionPage struct {
+	Items      []Session `json:"items"`
+	Total      int64     `json:"total"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	Page       int       `json:"page"`
+	TotalPages int       `json:"total_pages"`
+	HasMore    bool      `json:"has_more"`
+}
+
+// StartSession starts a session. If one is already running it returns a
+// *ConflictError holding that session.
+func (c *Client) StartSession(ctx context.Context, req StartRequest) (*Session, error) {
+	var session Session
+	if err := c.do(ctx, http.MethodPost, "/api/v1/sessions/start", nil, req, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// StopSession stops the running session, setting the details in req unless
+// it is nil. Without a running session it returns an error matching
+// ErrNotFound.
+func (c *Client) StopSession(ctx context.Context, req *StopRequest) (*Session, error) {
+	var body interface{}
+	if req != nil {
+		body = req
+	}
+	var session Session
+	if err := c.do(ctx, http.MethodPost, "/api/v1/sessions/stop", nil, body, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Current returns the running session, if any, and its elapsed time.
+func (c *Client) Current(ctx context.Context) (*Current, error) {
+	var current Current
+	if err := c.do(ctx, http.MethodGet, "/api/v1/sessions/current", nil, nil, &current); err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// ListSessions returns one page of sessions. See Sessions to go through
+// every page.
+func (c *Client) ListSessions(ctx context.Context, opts ListOptions) (*SessionPage, error) {
+	query := opts.values()
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	var page SessionPage
+	if err := c.do(ctx, http.MethodGet, "/api/v1/sessions", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Sessions returns an iterator over every session matching opts, starting
+// at opts.Offset and fetching opts.Limit sessions per request.
+//
+//	it := c.Sessions(ctx, client.ListOptions{Filter: client.Filter{Range: "today"}})
+//	for it.Next() {
+//		fmt.Println(it.Session().Task)
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+func (c *Client) Sessions(ctx context.Context, opts ListOptions) *SessionIterator {
+	return &SessionIterator{client: c, ctx: ctx, opts: opts}
+}
+
+// SessionIterator goes through the pages of a session list. Sessions
+// started or deleted while it runs may shift the pages, so a session can
+// be seen twice or not at all.
+type SessionIterator struct {
+	client *Client
+	ctx    context.Context
+	opts   ListOptions
+
+	page    []Session
+	current Session
+	done    bool
+	err     error
+}
+
+// Next advances to the next session, fetching the next page when needed.
+// It returns false at the end of the list or on an error.
+func (it *SessionIterator) Next() bool {
+	if len(it.page) == 0 && !it.done {
+		page, err := it.client.ListSessions(it.ctx, it.opts)
+		if err != nil {
+			it.err, it.done = err, true
+			return false
+		}
+		it.page = page.Items
+		it.opts.Offset += len(page.Items)
+		if len(page.Items) == 0 || !page.HasMore {
+			it.done = true
+		}
+	}
+	if len(it.page) == 0 {
+		return false
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Session returns the session Next advanced to.
+func (it *SessionIterator) Session() Session {
+	return it.current
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *SessionIterator) Err() error {
+	return it.err
+}
+
+// ExportCSV writes the sessions matching filter to w as CSV, in the
+// server's column order and with a UTF-8 byte order mark.
+func (c *Client) ExportCSV(ctx context.Context, w io.Writer, filter Filter) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v1/sessions.csv", filter.values(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Tag is a label for sessions. Tags may be nested under a parent tag.
+type Tag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	ParentID  *int64    `json:"parent_id"`
+	Archived  bool      `json:"archived"`
+}
+
+// TagCreate describes a tag to create. Color is "#RRGGBB"; empty gives the
+// default gray.
+type TagCreate struct {
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	ParentID *int64 `json:"parent_id,omitempty"`
+}
+
+// TagUpdate changes the fields of a tag that are not nil.
+type TagUpdate struct {
+	Name     *string `json:"name,omitempty"`
+	Color    *string `json:"color,omitempty"`
+	ParentID *int64  `json:"parent_id,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// TagListOptions narrows the tags ListTags returns. A ParentID of 0
+// selects top-level tags.
+type TagListOptions struct {
+	ParentID        *int64
+	NamePrefix      string
+	IncludeArchived bool
+}
+
+// ListTags returns the tags matching opts, archived ones only when asked.
+func (c *Client) ListTags(ctx context.Context, opts TagListOptions) ([]Tag, error) {
+	query := url.Values{}
+	if opts.ParentID != nil {
+		query.Set("parent_id", strconv.FormatInt(*opts.ParentID, 10))
+	}
+	if opts.NamePrefix != "" {
+		query.Set("name_prefix", opts.NamePrefix)
+	}
+	if opts.IncludeArchived {
+		query.Set("include_archived", "true")
+	}
+	var tags []Tag
+	if err := c.do(ctx, http.MethodGet, "/api/v1/tags", query, nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetTag returns the tag with the given ID, or an error matching
+// ErrNotFound.
+func (c *Client) GetTag(ctx context.Context, id int64) (*Tag, error) {
+	var tag Tag
+	if err := c.do(ctx, http.MethodGet, tagPath(id), nil, nil, &tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// CreateTag creates a tag.
+func (c *Client) CreateTag(ctx context.Context, req TagCreate) (*Tag, error) {
+	var tag Tag
+	if err := c.do(ctx, http.MethodPost, "/api/v1/tags", nil, req, &tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// UpdateTag changes a tag and returns it as updated.
+func (c *Client) UpdateTag(ctx context.Context, id int64, req TagUpdate) (*Tag, error) {
+	var tag Tag
+	if err := c.do(ctx, http.MethodPatch, tagPath(id), nil, req, &tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// DeleteTag deletes a tag. A tag with child tags is refused with an error
+// matching ErrConflict unless orphanChildren is set, which detaches them.
+func (c *Client) DeleteTag(ctx context.Context, id int64, orphanChildren bool) error {
+	var query url.Values
+	if orphanChildren {
+		query = url.Values{"orphan_children": {"true"}}
+	}
+	return c.do(ctx, http.MethodDelete, tagPath(id), query, nil, nil)
+}
+
+func tagPath(id int64) string {
+	return "/api/v1/tags/" + strconv.FormatInt(id, 10)
+}
+
+// AssignTags adds tags to a session. Tags it already has are kept.
+func (c *Client) AssignTags(ctx context.Context, sessionID int64, tagIDs []int64) error {
+	body := struct {
+		TagIDs []int64 `json:"tag_ids"`
+	}{tagIDs}
+	return c.do(ctx, http.MethodPost, sessionTagsPath(sessionID), nil, body, nil)
+}
+
+// SessionTags returns the tags of a session.
+func (c *Client) SessionTags(ctx context.Context, sessionID int64) ([]Tag, error) {
+	var tags []Tag
+	if err := c.do(ctx, http.MethodGet, sessionTagsPath(sessionID), nil, nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func sessionTagsPath(sessionID int64) string {
+	return "/api/v1/sessions/" + strconv.FormatInt(sessionID, 10) + "/tags"
+}
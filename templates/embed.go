@@ -0,0 +1,11 @@
+// Package templates embeds the web interface templates and static assets, so
+// the binary runs from any working directory.
+package templates
+
+import "embed"
+
+// FS holds the page templates at its root and the assets served under
+// /static/ in static/.
+//
+//go:embed *.html static
+var FS embed.FS
@@ -2,45 +2,99 @@ document.addEventListener('DOMContentLoaded', () => {
   const page = document.body.dataset.page
   if (!page) return
 
+  showStoredFlash()
+
   switch (page) {
     case 'sessions':
       initSessionsPage()
       break
+    case 'tags':
+      initTagsPage()
+      break
+    case 'docs':
+      initDocsPage()
+      break
   }
 })
 
-// Helper function to get API key from page
-function getAPIKey() {
-  const metaTag = document.querySelector('meta[name="api-key"]')
-  return metaTag ? metaTag.getAttribute('content') : ''
-}
-
 function initSessionsPage() {
-  const startTimeInput = document.getElementById('running-start-time')
-  const timerDisplay = document.getElementById('timer-display')
+  const runningPanel = document.getElementById('running-panel')
+  const sessionsTable = document.getElementById('sessions-table')
 
-  // Timer logic
-  if (startTimeInput && timerDisplay) {
-    const startTime = new Date(startTimeInput.value)
+  // How far the server's clock is ahead of ours, from the heartbeats
+  let clockOffset = 0
 
-    const updateTimer = () => {
-      const now = new Date()
-      const diff = Math.floor((now - startTime) / 1000)
+  // Timer logic; the running banner is swapped, so look it up each tick
+  const updateTimer = () => {
+    const startTimeInput = document.getElementById('running-start-time')
+    const timerDisplay = document.getElementById('timer-display')
+    if (!startTimeInput || !timerDisplay) return
 
-      if (diff < 0) {
-        timerDisplay.textContent = '0:00:00'
-        return
-      }
+    const now = Date.now() + clockOffset
+    const diff = Math.floor((now - new Date(startTimeInput.value)) / 1000)
+
+    if (diff < 0) {
+      timerDisplay.textContent = '0:00:00'
+      return
+    }
+
+    const hours = Math.floor(diff / 3600)
+    const minutes = Math.floor((diff % 3600) / 60)
+    const seconds = diff % 60
+
+    timerDisplay.textContent = `${hours}:${minutes.toString().padStart(2, '0')}:${seconds.toString().padStart(2, '0')}`
+  }
 
-      const hours = Math.floor(diff / 3600)
-      const minutes = Math.floor((diff % 3600) / 60)
-      const seconds = diff % 60
+  updateTimer()
+  setInterval(updateTimer, 1000)
 
-      timerDisplay.textContent = `${hours}:${minutes.toString().padStart(2, '0')}:${seconds.toString().padStart(2, '0')}`
+  // refresh swaps in the running banner and the table as they are now,
+  // keeping the filters and page of the address bar
+  const refresh = () => {
+    const fetchPartial = (name) => fetch(`/web/sessions/partials/${name}${window.location.search}`, {
+      credentials: 'same-origin'
+    }).then(response => {
+      if (!response.ok) throw new Error(response.statusText)
+      return response.text()
+    })
+
+    Promise.all([fetchPartial('running'), fetchPartial('table')])
+      .then(([running, table]) => {
+        runningPanel.innerHTML = running
+        sessionsTable.innerHTML = table
+        updateTimer()
+      })
+      .catch(() => window.location.reload())
+  }
+
+  // Follow sessions started, stopped or edited elsewhere
+  if (window.EventSource) {
+    const stream = new EventSource('/web/sessions/stream')
+    stream.addEventListener('snapshot', (event) => {
+      // A reconnect after missed changes shows up as a different state
+      const running = Boolean(document.getElementById('running-start-time'))
+      if (JSON.parse(event.data).running !== running) {
+        refresh()
+      }
+    })
+    stream.addEventListener('heartbeat', (event) => {
+      // The server's elapsed time corrects for a skewed local clock
+      const data = JSON.parse(event.data)
+      const startTimeInput = document.getElementById('running-start-time')
+      if (startTimeInput && data.elapsed_sec !== undefined) {
+        clockOffset = new Date(startTimeInput.value).getTime() + data.elapsed_sec * 1000 - Date.now()
+        updateTimer()
+      }
+    })
+    for (const name of ['started', 'stopped', 'updated', 'deleted']) {
+      stream.addEventListener(name, refresh)
     }
+  }
 
-    updateTimer()
-    setInterval(updateTimer, 1000)
+  // Actions show their message and swap in the changed fragments
+  const onSuccess = (result) => {
+    showFlash(result.message)
+    refresh()
   }
 
   // Session Action Functions
@@ -49,63 +103,23 @@ function initSessionsPage() {
     const task = document.getElementById('startTask').value.trim()
     const note = document.getElementById('startNote').value.trim()
 
-    const baseUrl = window.location.origin;
-    fetch(`${baseUrl}/api/v1/sessions/start`, {
-      method: 'POST',
-      headers: {
-        'Content-Type': 'application/json',
-        'X-API-Key': getAPIKey()
-      },
-      body: JSON.stringify({ category, task, note }),
-      credentials: 'same-origin'
-    }).then(response => {
-      if (response.ok) {
-        window.location.reload()
-      } else {
-        response.text().then(text => alert('开始计时失败: ' + text))
-      }
-    }).catch(err => alert('请求错误: ' + err))
+    postAction('/web/sessions/actions/start', { category, task, note }, '开始计时失败', onSuccess)
+  }
+
+  window.startPreset = (id) => {
+    postAction('/web/sessions/actions/start-preset', { id: Number(id) }, '开始计时失败', onSuccess)
   }
 
   window.stopSession = () => {
     if (!confirm('确定结束当前计时吗？')) return
 
-    const baseUrl = window.location.origin;
-    fetch(`${baseUrl}/api/v1/sessions/stop`, {
-      method: 'POST',
-      headers: {
-        'Content-Type': 'application/json',
-        'X-API-Key': getAPIKey()
-      },
-      body: JSON.stringify({}),
-      credentials: 'same-origin'
-    }).then(response => {
-      if (response.ok) {
-        window.location.reload()
-      } else {
-        response.text().then(text => alert('结束计时失败: ' + text))
-      }
-    }).catch(err => alert('请求错误: ' + err))
+    postAction('/web/sessions/actions/stop', {}, '结束计时失败', onSuccess)
   }
 
   window.deleteSession = (id) => {
     if (!confirm('确定删除这条记录吗？此操作无法撤销。')) return
 
-    const baseUrl = window.location.origin;
-    fetch(`${baseUrl}/api/v1/sessions/${id}`, {
-      method: 'DELETE',
-      headers: {
-        'Content-Type': 'application/json',
-        'X-API-Key': getAPIKey()
-      },
-      credentials: 'same-origin'
-    }).then(response => {
-      if (response.ok) {
-        window.location.reload()
-      } else {
-        response.text().then(text => alert('删除失败: ' + text))
-      }
-    }).catch(err => alert('请求错误: ' + err))
+    postAction('/web/sessions/actions/delete', { id: Number(id) }, '删除失败', onSuccess)
   }
 
   // Edit Modal Functions
@@ -141,44 +155,37 @@ function initSessionsPage() {
     }
 
     const payload = {
+      id: Number(id),
       category,
       task,
       note,
-      start_time: toRFC3339(startedAt)
+      started_at: toRFC3339(startedAt)
     }
 
     if (endedAt) {
-      payload.end_time = toRFC3339(endedAt)
+      payload.ended_at = toRFC3339(endedAt)
     }
 
-    const baseUrl = window.location.origin;
-    fetch(`${baseUrl}/api/v1/sessions/${id}`, {
-      method: 'PUT',
-      headers: {
-        'Content-Type': 'application/json',
-        'X-API-Key': getAPIKey()
-      },
-      body: JSON.stringify(payload),
-      credentials: 'same-origin'
-    }).then(response => {
-      if (response.ok) {
-        window.location.reload()
-      } else {
-        response.text().then(text => alert('保存失败: ' + text))
-      }
-    }).catch(err => alert('请求错误: ' + err))
-  }
-
-  // Attach event listeners to buttons
-  const startBtn = document.getElementById('startSessionBtn')
-  if (startBtn) {
-    startBtn.addEventListener('click', window.startSession)
+    postAction('/web/sessions/actions/update', payload, '保存失败', (result) => {
+      window.closeEditModal()
+      onSuccess(result)
+    })
   }
 
-  const stopBtn = document.getElementById('stopSessionBtn')
-  if (stopBtn) {
-    stopBtn.addEventListener('click', window.stopSession)
-  }
+  // Attach event listeners; the forms post directly without JavaScript.
+  // The listeners sit on the containers, whose contents are swapped.
+  runningPanel.addEventListener('submit', (e) => {
+    if (e.target.classList.contains('preset-form')) {
+      e.preventDefault()
+      window.startPreset(e.target.elements.id.value)
+    } else if (e.target.id === 'startSessionForm') {
+      e.preventDefault()
+      window.startSession()
+    } else if (e.target.id === 'stopSessionForm') {
+      e.preventDefault()
+      window.stopSession()
+    }
+  })
 
   const cancelEditBtn = document.getElementById('cancelEditBtn')
   if (cancelEditBtn) {
@@ -191,24 +198,24 @@ function initSessionsPage() {
   }
 
   // Event delegation for edit and delete buttons
-  const tableContainer = document.querySelector('.table-container')
-  if (tableContainer) {
-    tableContainer.addEventListener('click', (e) => {
-      // Handle edit button (and its children)
-      const editBtn = e.target.closest('.btn-edit')
-      if (editBtn) {
-        window.openEditSession(editBtn)
-        return
-      }
+  sessionsTable.addEventListener('click', (e) => {
+    // Handle edit button (and its children)
+    const editBtn = e.target.closest('.btn-edit')
+    if (editBtn) {
+      // The link opens the edit page when JavaScript is off
+      e.preventDefault()
+      window.openEditSession(editBtn)
+      return
+    }
 
-      // Handle delete button (and its children)
-      const deleteBtn = e.target.closest('.btn-delete')
-      if (deleteBtn) {
-        window.deleteSession(deleteBtn.dataset.id)
-        return
-      }
-    })
-  }
+    // Handle delete button (and its children)
+    const deleteBtn = e.target.closest('.btn-delete')
+    if (deleteBtn) {
+      e.preventDefault()
+      window.deleteSession(deleteBtn.dataset.id)
+      return
+    }
+  })
 
   // Close modal when clicking outside
   const editModal = document.getElementById('editModal')
@@ -219,7 +226,202 @@ function initSessionsPage() {
   }
 }
 
+function initTagsPage() {
+  const tableContainer = document.querySelector('.table-container')
+  if (!tableContainer) return
+
+  tableContainer.addEventListener('click', (e) => {
+    const archiveBtn = e.target.closest('.btn-archive')
+    if (!archiveBtn) return
+
+    e.preventDefault()
+    postAction('/web/tags/actions/archive', {
+      id: Number(archiveBtn.dataset.id),
+      archived: archiveBtn.dataset.archived === 'true'
+    }, '操作失败')
+  })
+}
+
+// initDocsPage renders the OpenAPI document as a list of operations by tag,
+// followed by the schemas they refer to. Text from the document is only
+// ever set as textContent.
+function initDocsPage() {
+  const root = document.getElementById('api-docs')
+
+  const el = (tag, className, text) => {
+    const node = document.createElement(tag)
+    if (className) node.className = className
+    if (text !== undefined) node.textContent = text
+    return node
+  }
+  const refName = (ref) => ref.split('/').pop()
+  const resolve = (spec, obj) => obj && obj.$ref
+    ? obj.$ref.split('/').slice(1).reduce((node, key) => node && node[key], spec)
+    : obj
+  const describeSchema = (schema) => {
+    if (!schema) return ''
+    if (schema.$ref) return refName(schema.$ref)
+    if (schema.type === 'array') return `${describeSchema(schema.items)}[]`
+    if (schema.oneOf) return schema.oneOf.map(describeSchema).join(' | ')
+    if (schema.allOf) return schema.allOf.map(describeSchema).filter(Boolean).join(' & ')
+    let type = schema.type || 'object'
+    if (schema.enum) type += ` (${schema.enum.join(', ')})`
+    return type
+  }
+  const table = (headers, rows) => {
+    const t = el('table')
+    const head = el('tr')
+    headers.forEach(h => head.appendChild(el('th', '', h)))
+    t.appendChild(el('thead')).appendChild(head)
+    const body = t.appendChild(el('tbody'))
+    rows.forEach(cells => {
+      const row = body.appendChild(el('tr'))
+      cells.forEach(c => row.appendChild(el('td', '', c)))
+    })
+    return t
+  }
+
+  const renderOperation = (spec, method, path, pathItem, op) => {
+    const details = el('details', 'docs-op')
+    const summary = details.appendChild(el('summary'))
+    summary.appendChild(el('span', `docs-method docs-method-${method}`, method.toUpperCase()))
+    summary.appendChild(el('span', '', path))
+    summary.appendChild(el('span', 'docs-summary', op.summary || ''))
+
+    const body = details.appendChild(el('div', 'docs-op-body'))
+    if (op.description) body.appendChild(el('p', '', op.description))
+
+    const params = [...(pathItem.parameters || []), ...(op.parameters || [])].map(p => resolve(spec, p))
+    if (params.length) {
+      body.appendChild(el('h4', '', '参数'))
+      body.appendChild(table(['名称', '位置', '类型', '说明'], params.map(p => [
+        p.name + (p.required ? ' *' : ''), p.in, describeSchema(p.schema), p.description || ''
+      ])))
+    }
+
+    if (op.requestBody) {
+      const content = op.requestBody.content || {}
+      body.appendChild(el('h4', '', '请求体'))
+      body.appendChild(table(['类型', '结构'], Object.keys(content).map(type => [type, describeSchema(content[type].schema)])))
+    }
+
+    body.appendChild(el('h4', '', '响应'))
+    body.appendChild(table(['状态码', '说明', '结构'], Object.keys(op.responses).map(code => {
+      const response = resolve(spec, op.responses[code])
+      const content = response.content || {}
+      return [code, response.description || '', Object.keys(content).map(type => `${type}: ${describeSchema(content[type].schema)}`).join('; ')]
+    })))
+    return details
+  }
+
+  const render = (spec) => {
+    root.textContent = ''
+    const sections = new Map((spec.tags || []).map(tag => {
+      const section = el('div', 'table-container docs-section')
+      section.appendChild(el('h2', '', tag.name))
+      if (tag.description) section.appendChild(el('p', '', tag.description))
+      return [tag.name, section]
+    }))
+
+    Object.keys(spec.paths).forEach(path => {
+      const pathItem = spec.paths[path]
+      Object.keys(pathItem).filter(key => key !== 'parameters').forEach(method => {
+        const op = pathItem[method]
+        const tag = (op.tags && op.tags[0]) || 'other'
+        if (!sections.has(tag)) {
+          const section = el('div', 'table-container docs-section')
+          section.appendChild(el('h2', '', tag))
+          sections.set(tag, section)
+        }
+        sections.get(tag).appendChild(renderOperation(spec, method, path, pathItem, op))
+      })
+    })
+    sections.forEach(section => root.appendChild(section))
+
+    const schemas = (spec.components && spec.components.schemas) || {}
+    const schemaSection = el('div', 'table-container docs-section')
+    schemaSection.appendChild(el('h2', '', '数据结构'))
+    Object.keys(schemas).forEach(name => {
+      const schema = schemas[name]
+      const block = schemaSection.appendChild(el('div', 'docs-schema'))
+      block.id = `schema-${name}`
+      block.appendChild(el('h3', '', name))
+      if (schema.description) block.appendChild(el('p', '', schema.description))
+      const props = schema.properties || {}
+      const required = schema.required || []
+      if (Object.keys(props).length) {
+        block.appendChild(table(['字段', '类型', '说明'], Object.keys(props).map(key => [
+          key + (required.includes(key) ? ' *' : ''), describeSchema(props[key]), props[key].description || ''
+        ])))
+      } else {
+        block.appendChild(el('p', '', describeSchema(schema)))
+      }
+    })
+    root.appendChild(schemaSection)
+  }
+
+  fetch('/openapi.json', { credentials: 'same-origin' })
+    .then(response => {
+      if (!response.ok) throw new Error(response.statusText)
+      return response.json()
+    })
+    .then(render)
+    .catch(err => {
+      root.textContent = ''
+      root.appendChild(el('div', 'table-container empty-state', '加载 API 文档失败: ' + err.message))
+    })
+}
+
 // Helper Functions
+
+// postAction sends a web action as JSON. The response is { ok, code, message }
+// either way; on success onSuccess gets it, or without one the page reloads
+// and shows the message.
+function postAction(path, payload, failureLabel, onSuccess) {
+  fetch(`${window.location.origin}${path}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json'
+    },
+    body: JSON.stringify(payload),
+    credentials: 'same-origin'
+  }).then(response => response.json()
+    .catch(() => ({ ok: false, message: response.statusText }))
+    .then(result => {
+      if (!result.ok) {
+        alert(`${failureLabel}: ${result.message}`)
+      } else if (onSuccess) {
+        onSuccess(result)
+      } else {
+        sessionStorage.setItem('flash', result.message)
+        window.location.reload()
+      }
+    })
+  ).catch(err => alert('请求错误: ' + err))
+}
+
+// showStoredFlash shows the message of an action that reloaded the page, the
+// way the server shows one after a form post.
+function showStoredFlash() {
+  const message = sessionStorage.getItem('flash')
+  if (!message) return
+  sessionStorage.removeItem('flash')
+  showFlash(message)
+}
+
+// showFlash shows a success message in place of any shown before.
+function showFlash(message) {
+  const container = document.querySelector('body > .container')
+  if (!container) return
+  container.querySelectorAll(':scope > .flash').forEach(el => el.remove())
+
+  const flash = document.createElement('div')
+  flash.className = 'flash flash-success'
+  flash.setAttribute('role', 'status')
+  flash.textContent = message
+  container.prepend(flash)
+}
+
 function formatForInput(isoStr) {
   if (!isoStr) return ''
   const date = new Date(isoStr)